@@ -845,6 +845,10 @@ func (fb *filterBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*t
 	return fb.bc.GetHeaderByHash(hash), nil
 }
 
+func (fb *filterBackend) ChainConfig() *params.ChainConfig { return fb.bc.Config() }
+
+func (fb *filterBackend) CurrentHeader() *types.Header { return fb.bc.CurrentHeader() }
+
 func (fb *filterBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
 	return fb.backend.pendingBlock, fb.backend.pendingReceipts
 }
@@ -0,0 +1,230 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// dump is the JSON output of the decoder.
+type dump struct {
+	Transactions []*txDump      `json:"transactions"`
+	Channels     []*channelDump `json:"channels"`
+}
+
+// txDump is a batch inbox transaction and the frames in its data. Data of
+// derivation version 0 holds batches instead of frames.
+type txDump struct {
+	L1Block uint64         `json:"l1Block"`
+	Hash    common.Hash    `json:"hash"`
+	Sender  common.Address `json:"sender"`
+	Size    int            `json:"size"`
+	Frames  []*frameDump   `json:"frames,omitempty"`
+	Batches []*batchDump   `json:"batches,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// frameDump is a frame of a channel.
+type frameDump struct {
+	Channel   string      `json:"channel"`
+	Number    uint16      `json:"number"`
+	Size      int         `json:"size"`
+	IsLast    bool        `json:"isLast"`
+	L1Block   uint64      `json:"l1Block"`
+	Tx        common.Hash `json:"tx"`
+	Duplicate bool        `json:"duplicate,omitempty"` // ignored, the frame was read before
+
+	data []byte
+}
+
+// channelDump is a channel reassembled from its frames.
+type channelDump struct {
+	ID       string       `json:"id"`
+	Opened   uint64       `json:"opened"`             // L1 block of the first frame
+	Closed   uint64       `json:"closed,omitempty"`   // L1 block of the frame that completed it
+	Complete bool         `json:"complete"`           // whether all frames were read
+	TimedOut bool         `json:"timedOut,omitempty"` // completed after the channel timeout
+	Frames   []*frameDump `json:"frames"`
+	Batches  []*batchDump `json:"batches,omitempty"`
+	Error    string       `json:"error,omitempty"`
+
+	frames map[uint16]*frameDump // first frame with each number
+	last   int                   // number of the last frame, -1 if unknown
+}
+
+// batchDump is a decoded batch.
+type batchDump struct {
+	ParentHash   common.Hash     `json:"parentHash"`
+	EpochNum     uint64          `json:"epochNum"`
+	EpochHash    common.Hash     `json:"epochHash"`
+	Timestamp    uint64          `json:"timestamp"`
+	TxHashes     []common.Hash   `json:"txHashes"`
+	Transactions []hexutil.Bytes `json:"transactions"`
+}
+
+func newBatchDump(b *derive.BatchData) *batchDump {
+	d := &batchDump{
+		ParentHash:   b.ParentHash,
+		EpochNum:     b.EpochNum,
+		EpochHash:    b.EpochHash,
+		Timestamp:    b.Timestamp,
+		TxHashes:     make([]common.Hash, 0, len(b.Transactions)),
+		Transactions: b.Transactions,
+	}
+	for _, enc := range b.Transactions {
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			// Invalid transactions are kept, derivation drops the batch.
+			d.TxHashes = append(d.TxHashes, common.Hash{})
+			continue
+		}
+		d.TxHashes = append(d.TxHashes, tx.Hash())
+	}
+	return d
+}
+
+func newBatchDumps(batches []*derive.BatchData) []*batchDump {
+	dumps := make([]*batchDump, len(batches))
+	for i, b := range batches {
+		dumps[i] = newBatchDump(b)
+	}
+	return dumps
+}
+
+// decoder collects the batch inbox transactions of L1 blocks and reassembles
+// their channels. Unlike the channel bank of the derivation pipeline, it keeps
+// the channels that are incomplete, timed out or invalid, so that they can be
+// inspected.
+type decoder struct {
+	cfg     *rollup.Config
+	signer  types.Signer
+	batcher *common.Address // sender to filter by, nil for all
+
+	txs      []*txDump
+	channels map[derive.ChannelID]*channelDump
+	order    []*channelDump // by first frame
+}
+
+func newDecoder(cfg *rollup.Config, batcher *common.Address) *decoder {
+	return &decoder{
+		cfg:      cfg,
+		signer:   types.LatestSignerForChainID(cfg.L1ChainID),
+		batcher:  batcher,
+		channels: make(map[derive.ChannelID]*channelDump),
+	}
+}
+
+// addBlock decodes the batch inbox transactions of an L1 block.
+func (d *decoder) addBlock(block *types.Block) {
+	for _, tx := range block.Transactions() {
+		if to := tx.To(); to == nil || *to != d.cfg.BatchInboxAddress {
+			continue
+		}
+		sender, err := types.Sender(d.signer, tx)
+		if err != nil {
+			log.Warn("Skipping batch inbox tx with invalid signature", "l1block", block.NumberU64(), "hash", tx.Hash(), "err", err)
+			continue
+		}
+		if d.batcher != nil && sender != *d.batcher {
+			continue
+		}
+		d.addTx(block.NumberU64(), tx, sender)
+	}
+}
+
+func (d *decoder) addTx(l1Block uint64, tx *types.Transaction, sender common.Address) {
+	dump := &txDump{L1Block: l1Block, Hash: tx.Hash(), Sender: sender, Size: len(tx.Data())}
+	d.txs = append(d.txs, dump)
+
+	data := tx.Data()
+	if len(data) > 0 && data[0] == derive.DerivationVersion0 {
+		batches, err := derive.DecodeBatches(data)
+		if err != nil {
+			dump.Error = err.Error()
+		}
+		dump.Batches = newBatchDumps(batches)
+		return
+	}
+	frames, err := derive.DecodeFrames(data)
+	if err != nil {
+		dump.Error = err.Error()
+		return
+	}
+	for _, f := range frames {
+		fd := &frameDump{
+			Channel: f.ID.String(),
+			Number:  f.Number,
+			Size:    len(f.Data),
+			IsLast:  f.IsLast,
+			L1Block: l1Block,
+			Tx:      dump.Hash,
+			data:    f.Data,
+		}
+		dump.Frames = append(dump.Frames, fd)
+		d.addFrame(f.ID, fd)
+	}
+}
+
+func (d *decoder) addFrame(id derive.ChannelID, f *frameDump) {
+	ch := d.channels[id]
+	if ch == nil {
+		ch = &channelDump{ID: f.Channel, Opened: f.L1Block, frames: make(map[uint16]*frameDump), last: -1}
+		d.channels[id] = ch
+		d.order = append(d.order, ch)
+	}
+	ch.Frames = append(ch.Frames, f)
+	if _, ok := ch.frames[f.Number]; ok || ch.Complete {
+		f.Duplicate = true
+		return
+	}
+	ch.frames[f.Number] = f
+	if f.IsLast && ch.last < 0 {
+		ch.last = int(f.Number)
+	}
+	if ch.last < 0 {
+		return
+	}
+	// The channel is complete once the frames up to the last are read.
+	for i := 0; i <= ch.last; i++ {
+		if ch.frames[uint16(i)] == nil {
+			return
+		}
+	}
+	ch.Complete, ch.Closed = true, f.L1Block
+	ch.TimedOut = ch.Opened+derive.ChannelTimeout(d.cfg) < f.L1Block
+
+	var data []byte
+	for i := 0; i <= ch.last; i++ {
+		data = append(data, ch.frames[uint16(i)].data...)
+	}
+	batches, err := derive.DecodeChannel(data)
+	if err != nil {
+		ch.Error = err.Error()
+		return
+	}
+	ch.Batches = newBatchDumps(batches)
+}
+
+// finish returns the decoded transactions and channels.
+func (d *decoder) finish() *dump {
+	return &dump{Transactions: d.txs, Channels: d.order}
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// batch-decoder fetches the transactions sent to the batch inbox in a range of
+// L1 blocks, reassembles their channels and dumps the decoded batches as JSON,
+// to debug derivation mismatches and malformed batcher output.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	rollupConfigFlag = &cli.StringFlag{
+		Name:     "rollup.config",
+		Usage:    "rollup configuration file, for the batch inbox address and the L1 chain ID",
+		Required: true,
+	}
+	startBlockFlag = &cli.Uint64Flag{
+		Name:  "start-block",
+		Usage: "first L1 block to read, the L1 genesis of the rollup if unset",
+	}
+	endBlockFlag = &cli.Uint64Flag{
+		Name:  "end-block",
+		Usage: "last L1 block to read, the L1 head if unset",
+	}
+	batcherFlag = &cli.StringFlag{
+		Name:  "batcher",
+		Usage: "only decode the transactions of this sender, all senders if unset",
+	}
+	outFlag = &cli.StringFlag{
+		Name:  "out",
+		Usage: "file to write the JSON dump to, standard output if unset",
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "batch inbox decoder")
+	app.Flags = []cli.Flag{
+		l1RPCFlag,
+		rollupConfigFlag,
+		startBlockFlag,
+		endBlockFlag,
+		batcherFlag,
+		outFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	cfg, err := rollup.LoadConfig(ctx.String(rollupConfigFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load rollup configuration: %v", err)
+	}
+	if cfg.DataAvailability != "" && cfg.DataAvailability != derive.CalldataSourceName {
+		return fmt.Errorf("data availability source %q not supported, only calldata is decoded", cfg.DataAvailability)
+	}
+	var batcher *common.Address
+	if ctx.IsSet(batcherFlag.Name) {
+		addr := ctx.String(batcherFlag.Name)
+		if !common.IsHexAddress(addr) {
+			return fmt.Errorf("invalid batcher address %q", addr)
+		}
+		batcher = new(common.Address)
+		*batcher = common.HexToAddress(addr)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+
+	start, end := cfg.Genesis.L1.Number, ctx.Uint64(endBlockFlag.Name)
+	if ctx.IsSet(startBlockFlag.Name) {
+		start = ctx.Uint64(startBlockFlag.Name)
+	}
+	if !ctx.IsSet(endBlockFlag.Name) {
+		head, err := l1.HeaderByNumber(ctx.Context, nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch L1 head: %v", err)
+		}
+		end = head.Number.Uint64()
+	}
+	if end < start {
+		return fmt.Errorf("end block %d before start block %d", end, start)
+	}
+
+	d := newDecoder(cfg, batcher)
+	for number := start; number <= end; number++ {
+		block, err := l1.BlockByNumber(ctx.Context, new(big.Int).SetUint64(number))
+		if err != nil {
+			return fmt.Errorf("failed to fetch L1 block %d: %v", number, err)
+		}
+		d.addBlock(block)
+		if number%1000 == 0 {
+			log.Info("Reading L1 blocks", "number", number, "end", end, "transactions", len(d.txs))
+		}
+	}
+	dump := d.finish()
+	log.Info("Decoded batch inbox", "start", start, "end", end, "transactions", len(dump.Transactions), "channels", len(dump.Channels))
+
+	out := io.Writer(os.Stdout)
+	if path := ctx.String(outFlag.Name); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
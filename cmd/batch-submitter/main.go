@@ -0,0 +1,250 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// batch-submitter posts the batches of sequenced L2 blocks to the L1 batch inbox.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/exp"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/batcher"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	l2RPCFlag = &cli.StringFlag{
+		Name:     "l2",
+		Usage:    "HTTP or WebSocket endpoint of the L2 node whose blocks are submitted",
+		Required: true,
+	}
+	keyFlag = &cli.StringFlag{
+		Name:     "key",
+		Usage:    "file containing the hex encoded private key of the batch sender",
+		Required: true,
+	}
+	inboxFlag = &cli.StringFlag{
+		Name:     "inbox",
+		Usage:    "L1 address of the batch inbox",
+		Required: true,
+	}
+	cursorFlag = &cli.StringFlag{
+		Name:  "cursor",
+		Usage: "file that persists the last submitted L2 block",
+		Value: "batcher-cursor.json",
+	}
+	minSizeFlag = &cli.Uint64Flag{
+		Name:  "min-size",
+		Usage: "amount of batch data (bytes) that triggers a submission",
+		Value: batcher.DefaultConfig.MinSubmitSize,
+	}
+	maxSizeFlag = &cli.Uint64Flag{
+		Name:  "max-size",
+		Usage: "maximum amount of channel data (bytes) per L1 transaction",
+		Value: batcher.DefaultConfig.MaxSubmitSize,
+	}
+	maxChannelSizeFlag = &cli.Uint64Flag{
+		Name:  "max-channel-size",
+		Usage: "maximum amount of uncompressed batch data (bytes) per channel",
+		Value: batcher.DefaultConfig.MaxChannelSize,
+	}
+	maxDelayFlag = &cli.DurationFlag{
+		Name:  "max-delay",
+		Usage: "maximum time an L2 block waits to be submitted",
+		Value: batcher.DefaultConfig.MaxDelay,
+	}
+	maxGasPriceFlag = &cli.Uint64Flag{
+		Name:  "max-gas-price",
+		Usage: "highest L1 fee cap (gwei) to submit at, 0 for no limit",
+	}
+	resubmitTimeoutFlag = &cli.DurationFlag{
+		Name:  "resubmit-timeout",
+		Usage: "time after which a batch transaction that is not included is replaced with higher fees",
+		Value: batcher.DefaultConfig.ResubmitTimeout,
+	}
+	maxInflightFlag = &cli.IntFlag{
+		Name:  "max-inflight",
+		Usage: "number of channels that are submitted concurrently before the oldest one is confirmed",
+		Value: batcher.DefaultConfig.MaxInflight,
+	}
+	pollIntervalFlag = &cli.DurationFlag{
+		Name:  "poll-interval",
+		Usage: "interval at which L2 blocks and submissions are polled",
+		Value: batcher.DefaultConfig.PollInterval,
+	}
+	metricsFlag = &cli.BoolFlag{
+		Name:  "metrics",
+		Usage: "enable metrics collection and reporting",
+	}
+	metricsAddrFlag = &cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "listening address of the metrics HTTP server, serving Prometheus metrics at /debug/metrics/prometheus",
+		Value: "127.0.0.1:6060",
+	}
+	pprofFlag = &cli.BoolFlag{
+		Name:  "pprof",
+		Usage: "enable the pprof HTTP server, serving the runtime profiles at /debug/pprof",
+	}
+	pprofAddrFlag = &cli.StringFlag{
+		Name:  "pprof.addr",
+		Usage: "listening address of the pprof HTTP server",
+		Value: "127.0.0.1:6061",
+	}
+	blockProfileRateFlag = &cli.IntFlag{
+		Name:  "pprof.blockprofilerate",
+		Usage: "turn on block profiling with the given rate (see runtime.SetBlockProfileRate)",
+	}
+	mutexProfileRateFlag = &cli.IntFlag{
+		Name:  "pprof.mutexprofilerate",
+		Usage: "turn on mutex profiling with the given rate (see runtime.SetMutexProfileFraction)",
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 batch submitter")
+	app.Flags = []cli.Flag{
+		l1RPCFlag,
+		l2RPCFlag,
+		keyFlag,
+		inboxFlag,
+		cursorFlag,
+		minSizeFlag,
+		maxSizeFlag,
+		maxChannelSizeFlag,
+		maxDelayFlag,
+		maxGasPriceFlag,
+		resubmitTimeoutFlag,
+		maxInflightFlag,
+		pollIntervalFlag,
+		metricsFlag,
+		metricsAddrFlag,
+		pprofFlag,
+		pprofAddrFlag,
+		blockProfileRateFlag,
+		mutexProfileRateFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	// Metrics collection is switched on by the metrics flag before any code
+	// runs, see the metrics package.
+	if metrics.Enabled {
+		exp.Setup(ctx.String(metricsAddrFlag.Name))
+		go metrics.CollectProcessMetrics(3 * time.Second)
+	}
+	if ctx.Bool(pprofFlag.Name) {
+		runtime.SetBlockProfileRate(ctx.Int(blockProfileRateFlag.Name))
+		runtime.SetMutexProfileFraction(ctx.Int(mutexProfileRateFlag.Name))
+		debug.StartPProf(ctx.String(pprofAddrFlag.Name), false)
+	}
+
+	key, err := crypto.LoadECDSA(ctx.String(keyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load batch sender key: %v", err)
+	}
+	inbox := ctx.String(inboxFlag.Name)
+	if !common.IsHexAddress(inbox) {
+		return fmt.Errorf("invalid inbox address %q", inbox)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+	l2, err := ethclient.Dial(ctx.String(l2RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L2: %v", err)
+	}
+	defer l2.Close()
+
+	chainID, err := l1.ChainID(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 chain ID: %v", err)
+	}
+	cfg := batcher.Config{
+		L1ChainID:         chainID,
+		BatchInboxAddress: common.HexToAddress(inbox),
+		MinSubmitSize:     ctx.Uint64(minSizeFlag.Name),
+		MaxSubmitSize:     ctx.Uint64(maxSizeFlag.Name),
+		MaxChannelSize:    ctx.Uint64(maxChannelSizeFlag.Name),
+		MaxDelay:          ctx.Duration(maxDelayFlag.Name),
+		ResubmitTimeout:   ctx.Duration(resubmitTimeoutFlag.Name),
+		MaxInflight:       ctx.Int(maxInflightFlag.Name),
+		PollInterval:      ctx.Duration(pollIntervalFlag.Name),
+		CursorFile:        ctx.String(cursorFlag.Name),
+	}
+	if gwei := ctx.Uint64(maxGasPriceFlag.Name); gwei > 0 {
+		cfg.MaxGasPrice = new(big.Int).Mul(new(big.Int).SetUint64(gwei), big.NewInt(params.GWei))
+	}
+	submitter, err := batcher.New(cfg, l1, l2, key, rollup.ComponentLogger(log.Root(), rollup.LogBatcher))
+	if err != nil {
+		return err
+	}
+	submitter.Start()
+	log.Info("Batch submitter started", "sender", crypto.PubkeyToAddress(key.PublicKey), "inbox", cfg.BatchInboxAddress)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down batch submitter")
+	submitter.Stop()
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// challenger checks the output roots proposed to the L1 output oracle against
+// the outputs of a trusted rollup node, and reports invalid proposals.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup/challenger"
+	"github.com/ethereum/go-ethereum/rollup/node"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	rollupRPCFlag = &cli.StringFlag{
+		Name:     "rollup",
+		Usage:    "HTTP or WebSocket endpoint of the trusted rollup node that proposals are checked against",
+		Required: true,
+	}
+	oracleFlag = &cli.StringFlag{
+		Name:     "oracle",
+		Usage:    "L1 address of the L2 output oracle",
+		Required: true,
+	}
+	startBlockFlag = &cli.Uint64Flag{
+		Name:  "start-block",
+		Usage: "L1 block from which proposals are checked",
+	}
+	confirmationsFlag = &cli.Uint64Flag{
+		Name:  "confirmations",
+		Usage: "number of L1 blocks on top of a proposal before it is checked",
+		Value: challenger.DefaultConfig.Confirmations,
+	}
+	pollIntervalFlag = &cli.DurationFlag{
+		Name:  "poll-interval",
+		Usage: "interval at which L1 and the rollup node are polled",
+		Value: challenger.DefaultConfig.PollInterval,
+	}
+	maxRetryIntervalFlag = &cli.DurationFlag{
+		Name:  "max-retry-interval",
+		Usage: "longest interval to back off to after failures",
+		Value: challenger.DefaultConfig.MaxRetryInterval,
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 output challenger")
+	app.Flags = []cli.Flag{
+		l1RPCFlag,
+		rollupRPCFlag,
+		oracleFlag,
+		startBlockFlag,
+		confirmationsFlag,
+		pollIntervalFlag,
+		maxRetryIntervalFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	oracle := ctx.String(oracleFlag.Name)
+	if !common.IsHexAddress(oracle) {
+		return fmt.Errorf("invalid output oracle address %q", oracle)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+	rollupNode, err := node.Dial(context.Background(), ctx.String(rollupRPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to rollup node: %v", err)
+	}
+	defer rollupNode.Close()
+
+	cfg := challenger.DefaultConfig
+	cfg.OutputOracleAddress = common.HexToAddress(oracle)
+	cfg.StartBlock = ctx.Uint64(startBlockFlag.Name)
+	cfg.Confirmations = ctx.Uint64(confirmationsFlag.Name)
+	cfg.PollInterval = ctx.Duration(pollIntervalFlag.Name)
+	cfg.MaxRetryInterval = ctx.Duration(maxRetryIntervalFlag.Name)
+
+	// Invalid proposals are only reported until disputes are supported.
+	c := challenger.New(cfg, l1, rollupNode, nil, log.Root())
+	c.Start()
+	log.Info("Challenger started", "oracle", cfg.OutputOracleAddress, "start", cfg.StartBlock)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down challenger")
+	c.Stop()
+	return nil
+}
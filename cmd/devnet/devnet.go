@@ -0,0 +1,390 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/batcher"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/driver"
+	"github.com/ethereum/go-ethereum/rollup/engine"
+	"github.com/ethereum/go-ethereum/rollup/genesis"
+	rollupnode "github.com/ethereum/go-ethereum/rollup/node"
+	"github.com/ethereum/go-ethereum/rollup/proposer"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// L1 addresses of the stand-in contracts of the devnet.
+var (
+	depositContractAddr = common.HexToAddress("0xde90517000000000000000000000000000000001")
+	outputOracleAddr    = common.HexToAddress("0x0a7c1e0000000000000000000000000000000002")
+)
+
+// depositContractCode is a minimal stand-in for the L1 deposit contract. It
+// emits the deposit event of the caller, with the padded target address and the
+// ABI encoded opaque data taken from the calldata:
+//
+//	calldatacopy(0, 32, calldatasize-32)
+//	log4(0, calldatasize-32, DepositEventABIHash, caller, calldataload(0), 0)
+//
+// Unlike the real contract, it does not check that the value matches the mint.
+var depositContractCode = append(append(
+	common.FromHex("0x602036038060206000376000600035337f"),
+	derive.DepositEventABIHash[:]...),
+	common.FromHex("0x846000a400")...,
+)
+
+// outputOracleCode is a minimal stand-in for the L2 output oracle. The
+// latestBlockNumber() call returns the L2 block of the last proposal, and any
+// other call is taken as a proposeL2Output call:
+//
+//	if shr(224, calldataload(0)) == latestBlockNumber.selector {
+//		mstore(0, sload(0))
+//		return(0, 32)
+//	}
+//	sstore(0, calldataload(36))
+//	index := sload(1)
+//	sstore(1, index+1)
+//	mstore(0, timestamp)
+//	log4(0, 32, OutputProposed.id, calldataload(4), index, calldataload(36))
+//
+// Unlike the real contract, it does not check the proposer or the proposed
+// block.
+var outputOracleCode = func() []byte {
+	selector := bridge.L2OutputOracleABI.Methods["latestBlockNumber"].ID
+	event := bridge.L2OutputOracleABI.Events["OutputProposed"].ID
+	code := common.FromHex("0x60003560e01c63")
+	code = append(code, selector...)
+	code = append(code, common.FromHex("0x14604e576024358060005560015480600101600155426000526004357f")...)
+	code = append(code, event[:]...)
+	return append(code, common.FromHex("0x60206000a4005b60005460005260206000f3")...)
+}()
+
+// stackConfig contains the settings of the devnet.
+type stackConfig struct {
+	L1HTTP      string // listening address of the L1 node
+	L2HTTP      string // listening address of the L2 node
+	RollupHTTP  string // listening address of the rollup node API
+	L1BlockTime time.Duration
+	L2BlockTime uint64 // seconds between two L2 blocks
+	// OutputInterval is the number of L2 blocks between two proposed outputs.
+	OutputInterval uint64
+	// Accounts are funded on L1 and L2. The batcher and the proposer are only
+	// funded on L1.
+	Accounts []*ecdsa.PrivateKey
+	Batcher  *ecdsa.PrivateKey
+	Proposer *ecdsa.PrivateKey
+}
+
+// ethNode is an in-process execution client with the engine API enabled.
+type ethNode struct {
+	node   *node.Node
+	eth    *eth.Ethereum
+	client *ethclient.Client
+	engine *engine.Client
+}
+
+// stack is a running devnet: an L1 chain that produces blocks at a fixed
+// interval, an L2 execution engine driven by a sequencing rollup node, and the
+// batch and output submitters of the rollup.
+type stack struct {
+	cfg      *rollup.Config
+	dir      string // temporary files of the submitters
+	l1       *ethNode
+	l2       *ethNode
+	driver   *driver.Driver
+	rpc      *http.Server
+	batcher  *batcher.Submitter
+	proposer *proposer.Submitter
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startStack starts a devnet with a fresh genesis. Nothing is persisted, every
+// run starts a new chain.
+func startStack(sc *stackConfig) (s *stack, err error) {
+	funds := new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+	l1Alloc := core.GenesisAlloc{
+		crypto.PubkeyToAddress(sc.Batcher.PublicKey):  {Balance: funds},
+		crypto.PubkeyToAddress(sc.Proposer.PublicKey): {Balance: funds},
+		depositContractAddr:                           {Balance: new(big.Int), Code: depositContractCode},
+		outputOracleAddr:                              {Balance: new(big.Int), Code: outputOracleCode},
+	}
+	l2Alloc := make(core.GenesisAlloc)
+	for _, key := range sc.Accounts {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		l1Alloc[addr] = core.GenesisAccount{Balance: funds}
+		l2Alloc[addr] = core.GenesisAccount{Balance: funds}
+	}
+	l1Genesis := &core.Genesis{
+		Config:     l1ChainConfig(),
+		Timestamp:  uint64(time.Now().Unix()),
+		GasLimit:   30_000_000,
+		Difficulty: new(big.Int),
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+		Alloc:      l1Alloc,
+	}
+	l1Anchor := l1Genesis.ToBlock(nil).Header()
+	deployCfg := &genesis.DeployConfig{
+		L1ChainID:              l1Genesis.Config.ChainID.Uint64(),
+		L2ChainID:              901,
+		L2BlockTime:            sc.L2BlockTime,
+		MaxSequencerDrift:      600,
+		SequencerWindowSize:    100,
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     crypto.PubkeyToAddress(sc.Batcher.PublicKey),
+		DepositContractAddress: depositContractAddr,
+		L2GenesisGasLimit:      30_000_000,
+		FundedAccounts:         l2Alloc,
+	}
+	if err := deployCfg.Check(); err != nil {
+		return nil, err
+	}
+	l2Genesis, err := genesis.BuildL2Genesis(deployCfg, l1Anchor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build L2 genesis: %v", err)
+	}
+	s = &stack{
+		cfg:  genesis.BuildRollupConfig(deployCfg, l1Anchor, l2Genesis.ToBlock(nil)),
+		stop: make(chan struct{}),
+	}
+	defer func() {
+		if err != nil {
+			s.close()
+		}
+	}()
+	if s.dir, err = os.MkdirTemp("", "devnet"); err != nil {
+		return nil, err
+	}
+
+	if s.l1, err = startEthNode("l1", l1Genesis, sc.L1HTTP); err != nil {
+		return nil, err
+	}
+	if s.l2, err = startEthNode("l2", l2Genesis, sc.L2HTTP); err != nil {
+		return nil, err
+	}
+	s.driver, err = driver.NewDriver(s.cfg, driver.Config{
+		Confirmations: derive.Confirmations{FinalityDepth: 4},
+		Sequencing:    true,
+	}, derive.NewL1Source(s.l1.client), s.l2.engine, log.New("role", "rollup-node"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollup node: %v", err)
+	}
+	s.driver.Start()
+
+	handler := rpc.NewServer()
+	for _, api := range rollupnode.APIs(s.cfg, s.driver, s.l2.engine) {
+		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("tcp", sc.RollupHTTP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start rollup node RPC server: %v", err)
+	}
+	s.rpc = &http.Server{Handler: rollupnode.NewHandler(handler)}
+	go s.rpc.Serve(listener)
+
+	bcfg := batcher.DefaultConfig
+	bcfg.L1ChainID = s.cfg.L1ChainID
+	bcfg.BatchInboxAddress = s.cfg.BatchInboxAddress
+	bcfg.MaxDelay = 2 * sc.L1BlockTime
+	bcfg.PollInterval = time.Second
+	bcfg.CursorFile = filepath.Join(s.dir, "batcher-cursor.json")
+	s.batcher, err = batcher.New(bcfg, s.l1.client, s.l2.client, sc.Batcher, log.New("role", "batcher"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch submitter: %v", err)
+	}
+	s.batcher.Start()
+
+	pcfg := proposer.DefaultConfig
+	pcfg.L1ChainID = s.cfg.L1ChainID
+	pcfg.OutputOracleAddress = outputOracleAddr
+	pcfg.SubmissionInterval = sc.OutputInterval
+	pcfg.PollInterval = time.Second
+	rollupClient := rollupnode.NewClient(rpc.DialInProc(handler))
+	s.proposer, err = proposer.New(pcfg, s.l1.client, rollupClient, sc.Proposer, log.New("role", "proposer"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output submitter: %v", err)
+	}
+	s.proposer.Start()
+
+	s.wg.Add(1)
+	go s.produceL1Blocks(sc.L1BlockTime)
+	return s, nil
+}
+
+// close stops all components of the devnet and removes its files.
+func (s *stack) close() {
+	close(s.stop)
+	s.wg.Wait()
+	if s.proposer != nil {
+		s.proposer.Stop()
+	}
+	if s.batcher != nil {
+		s.batcher.Stop()
+	}
+	if s.rpc != nil {
+		s.rpc.Close()
+	}
+	if s.driver != nil {
+		s.driver.Stop()
+	}
+	for _, n := range []*ethNode{s.l2, s.l1} {
+		if n != nil {
+			n.close()
+		}
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+}
+
+func l1ChainConfig() *params.ChainConfig {
+	config := *params.AllEthashProtocolChanges
+	config.ChainID = big.NewInt(900)
+	config.TerminalTotalDifficulty = new(big.Int)
+	config.TerminalTotalDifficultyPassed = true
+	return &config
+}
+
+// startEthNode starts an execution client with the given genesis, which serves
+// the user APIs over HTTP and only progresses through the engine API. The
+// chain is kept in memory.
+func startEthNode(name string, genesis *core.Genesis, httpAddr string) (*ethNode, error) {
+	host, port, err := net.SplitHostPort(httpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s HTTP address %q: %v", name, httpAddr, err)
+	}
+	httpPort, err := net.LookupPort("tcp", port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s HTTP port %q: %v", name, port, err)
+	}
+	stack, err := node.New(&node.Config{
+		Name:             name,
+		P2P:              p2p.Config{NoDiscovery: true, NoDial: true},
+		HTTPHost:         host,
+		HTTPPort:         httpPort,
+		HTTPModules:      []string{"eth", "net", "web3", "txpool"},
+		HTTPVirtualHosts: []string{"*"},
+		HTTPCors:         []string{"*"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s node: %v", name, err)
+	}
+	ethcfg := ethconfig.Defaults
+	ethcfg.Genesis = genesis
+	ethcfg.SyncMode = downloader.FullSync
+	ethcfg.Ethash.PowMode = ethash.ModeFake
+	backend, err := eth.New(stack, &ethcfg)
+	if err != nil {
+		stack.Close()
+		return nil, fmt.Errorf("failed to create %s eth service: %v", name, err)
+	}
+	if err := catalyst.Register(stack, backend); err != nil {
+		stack.Close()
+		return nil, fmt.Errorf("failed to register engine API of %s: %v", name, err)
+	}
+	if err := stack.Start(); err != nil {
+		stack.Close()
+		return nil, fmt.Errorf("failed to start %s node: %v", name, err)
+	}
+	rpcClient, _ := stack.Attach()
+	return &ethNode{
+		node:   stack,
+		eth:    backend,
+		client: ethclient.NewClient(rpcClient),
+		engine: engine.NewClient(rpcClient),
+	}, nil
+}
+
+func (n *ethNode) close() {
+	n.client.Close()
+	n.node.Close()
+}
+
+// produceL1Blocks builds an L1 block out of the transaction pool of the L1 node
+// at every interval, and reports it to the rollup node.
+func (s *stack) produceL1Blocks(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			head, err := s.buildL1Block(ctx)
+			if err == nil {
+				s.driver.OnL1Head(ctx, rollup.L1BlockRefFromHeader(head))
+			} else {
+				log.Error("Failed to build L1 block", "err", err)
+			}
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *stack) buildL1Block(ctx context.Context) (*types.Header, error) {
+	parent := s.l1.eth.BlockChain().CurrentHeader()
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= parent.Time {
+		timestamp = parent.Time + 1
+	}
+	hash := parent.Hash()
+	attrs := &beacon.PayloadAttributesV1{
+		Timestamp:             timestamp,
+		Random:                crypto.Keccak256Hash(hash[:]),
+		SuggestedFeeRecipient: common.HexToAddress("0xc0ffee"),
+	}
+	fc := beacon.ForkchoiceStateV1{HeadBlockHash: hash, SafeBlockHash: hash, FinalizedBlockHash: hash}
+	payload, err := derive.InsertHeadBlock(ctx, s.l1.engine, fc, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return s.l1.client.HeaderByHash(ctx, payload.BlockHash)
+}
@@ -0,0 +1,176 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// devnet runs a complete local rollup in a single process: an L1 chain, an L2
+// execution engine driven by a sequencing rollup node, and the batch and output
+// submitters. The chains start from a fresh genesis with funded development
+// accounts, which are printed on startup. Nothing is persisted.
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1HTTPFlag = &cli.StringFlag{
+		Name:  "l1.http",
+		Usage: "listening address of the HTTP-RPC server of the L1 node",
+		Value: "127.0.0.1:8545",
+	}
+	l2HTTPFlag = &cli.StringFlag{
+		Name:  "l2.http",
+		Usage: "listening address of the HTTP-RPC server of the L2 node",
+		Value: "127.0.0.1:9545",
+	}
+	rollupHTTPFlag = &cli.StringFlag{
+		Name:  "rollup.http",
+		Usage: "listening address of the HTTP-RPC server of the rollup node",
+		Value: "127.0.0.1:7545",
+	}
+	l1BlockTimeFlag = &cli.DurationFlag{
+		Name:  "l1.blocktime",
+		Usage: "interval at which L1 blocks are produced",
+		Value: 4 * time.Second,
+	}
+	l2BlockTimeFlag = &cli.Uint64Flag{
+		Name:  "l2.blocktime",
+		Usage: "number of seconds between two L2 blocks",
+		Value: 2,
+	}
+	outputIntervalFlag = &cli.Uint64Flag{
+		Name:  "output-interval",
+		Usage: "number of L2 blocks between two proposed outputs",
+		Value: 10,
+	}
+	accountsFlag = &cli.IntFlag{
+		Name:  "accounts",
+		Usage: "number of development accounts funded on L1 and L2",
+		Value: 5,
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlWarn),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "local rollup devnet")
+	app.Flags = []cli.Flag{
+		l1HTTPFlag,
+		l2HTTPFlag,
+		rollupHTTPFlag,
+		l1BlockTimeFlag,
+		l2BlockTimeFlag,
+		outputIntervalFlag,
+		accountsFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	if ctx.Uint64(l2BlockTimeFlag.Name) == 0 {
+		return fmt.Errorf("L2 block time must be positive")
+	}
+	if ctx.Duration(l1BlockTimeFlag.Name) <= 0 {
+		return fmt.Errorf("L1 block time must be positive")
+	}
+	sc := &stackConfig{
+		L1HTTP:         ctx.String(l1HTTPFlag.Name),
+		L2HTTP:         ctx.String(l2HTTPFlag.Name),
+		RollupHTTP:     ctx.String(rollupHTTPFlag.Name),
+		L1BlockTime:    ctx.Duration(l1BlockTimeFlag.Name),
+		L2BlockTime:    ctx.Uint64(l2BlockTimeFlag.Name),
+		OutputInterval: ctx.Uint64(outputIntervalFlag.Name),
+		Batcher:        devKey("batcher", 0),
+		Proposer:       devKey("proposer", 0),
+	}
+	for i := 0; i < ctx.Int(accountsFlag.Name); i++ {
+		sc.Accounts = append(sc.Accounts, devKey("account", i))
+	}
+	s, err := startStack(sc)
+	if err != nil {
+		return err
+	}
+	printStack(s, sc)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	fmt.Println("Shutting down devnet")
+	s.close()
+	return nil
+}
+
+// devKey derives the well-known key of a development account, so the accounts
+// are the same in every run.
+func devKey(role string, index int) *ecdsa.PrivateKey {
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], uint64(index))
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("devnet "+role), seed[:]))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// printStack prints the endpoints, the contracts and the accounts of the
+// devnet.
+func printStack(s *stack, sc *stackConfig) {
+	fmt.Printf("Devnet running\n\n")
+	fmt.Printf("L1 RPC:          http://%s (chain %v)\n", sc.L1HTTP, s.cfg.L1ChainID)
+	fmt.Printf("L2 RPC:          http://%s (chain %v)\n", sc.L2HTTP, s.cfg.L2ChainID)
+	fmt.Printf("Rollup node RPC: http://%s\n\n", sc.RollupHTTP)
+	fmt.Printf("Deposit contract: %s\n", depositContractAddr)
+	fmt.Printf("Output oracle:    %s\n", outputOracleAddr)
+	fmt.Printf("Batch inbox:      %s\n\n", s.cfg.BatchInboxAddress)
+	fmt.Printf("Batcher:  %s\n", crypto.PubkeyToAddress(sc.Batcher.PublicKey))
+	fmt.Printf("Proposer: %s\n\n", crypto.PubkeyToAddress(sc.Proposer.PublicKey))
+	fmt.Printf("Accounts, funded with 1000 ETH on L1 and L2:\n")
+	for _, key := range sc.Accounts {
+		fmt.Printf("  %s  %s\n", crypto.PubkeyToAddress(key.PublicKey), hexutil.Encode(crypto.FromECDSA(key)))
+	}
+}
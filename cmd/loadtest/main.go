@@ -0,0 +1,243 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// loadtest sends a mix of transfers, ERC20 calls and deposits to an L2 devnet at
+// a target rate, and reports their inclusion latency and the gas throughput of
+// the chain.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l2RPCFlag = &cli.StringFlag{
+		Name:     "l2",
+		Usage:    "HTTP or WebSocket endpoint of the L2 node receiving the transactions",
+		Required: true,
+	}
+	l1RPCFlag = &cli.StringFlag{
+		Name:  "l1",
+		Usage: "HTTP or WebSocket endpoint of the L1 node, needed for deposits",
+	}
+	keyFlag = &cli.StringFlag{
+		Name:     "key",
+		Usage:    "file containing the hex encoded private key that funds the test accounts and sends the deposits",
+		Required: true,
+	}
+	mixFlag = &cli.StringFlag{
+		Name:  "mix",
+		Usage: "weights of the transaction kinds (transfer, erc20, deposit)",
+		Value: "transfer=1",
+	}
+	tpsFlag = &cli.Float64Flag{
+		Name:  "tps",
+		Usage: "target rate of sent transactions per second",
+		Value: 10,
+	}
+	durationFlag = &cli.DurationFlag{
+		Name:  "duration",
+		Usage: "how long transactions are sent",
+		Value: time.Minute,
+	}
+	drainFlag = &cli.DurationFlag{
+		Name:  "drain",
+		Usage: "how long to wait for the sent transactions to be included",
+		Value: 2 * time.Minute,
+	}
+	accountsFlag = &cli.IntFlag{
+		Name:  "accounts",
+		Usage: "number of L2 accounts sending transactions",
+		Value: 16,
+	}
+	fundFlag = &cli.Uint64Flag{
+		Name:  "fund",
+		Usage: "L2 balance (gwei) given to every account",
+		Value: params.Ether / params.GWei,
+	}
+	tipFlag = &cli.Uint64Flag{
+		Name:  "tip",
+		Usage: "gas tip cap (gwei) of the sent transactions",
+		Value: 1,
+	}
+	erc20Flag = &cli.StringFlag{
+		Name:  "erc20",
+		Usage: "L2 address of the ERC20 token of the erc20 transactions, held by the key",
+	}
+	erc20FundFlag = &cli.Uint64Flag{
+		Name:  "erc20.fund",
+		Usage: "token amount given to every account",
+		Value: 1_000_000,
+	}
+	erc20GasFlag = &cli.Uint64Flag{
+		Name:  "erc20.gas",
+		Usage: "gas limit of the ERC20 transfers",
+		Value: 100_000,
+	}
+	depositContractFlag = &cli.StringFlag{
+		Name:  "deposit-contract",
+		Usage: "L1 address of the deposit contract, needed for deposits",
+	}
+	pollIntervalFlag = &cli.DurationFlag{
+		Name:  "poll-interval",
+		Usage: "interval at which new L2 blocks are polled",
+		Value: 250 * time.Millisecond,
+	}
+	reportIntervalFlag = &cli.DurationFlag{
+		Name:  "report-interval",
+		Usage: "interval at which the progress is logged",
+		Value: 10 * time.Second,
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 load test")
+	app.Flags = []cli.Flag{
+		l2RPCFlag,
+		l1RPCFlag,
+		keyFlag,
+		mixFlag,
+		tpsFlag,
+		durationFlag,
+		drainFlag,
+		accountsFlag,
+		fundFlag,
+		tipFlag,
+		erc20Flag,
+		erc20FundFlag,
+		erc20GasFlag,
+		depositContractFlag,
+		pollIntervalFlag,
+		reportIntervalFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	key, err := crypto.LoadECDSA(ctx.String(keyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load key: %v", err)
+	}
+	mix, err := parseMix(ctx.String(mixFlag.Name))
+	if err != nil {
+		return err
+	}
+	gwei := big.NewInt(params.GWei)
+	cfg := config{
+		Mix:       mix,
+		TPS:       ctx.Float64(tpsFlag.Name),
+		Accounts:  ctx.Int(accountsFlag.Name),
+		Fund:      new(big.Int).Mul(new(big.Int).SetUint64(ctx.Uint64(fundFlag.Name)), gwei),
+		Tip:       new(big.Int).Mul(new(big.Int).SetUint64(ctx.Uint64(tipFlag.Name)), gwei),
+		TokenFund: new(big.Int).SetUint64(ctx.Uint64(erc20FundFlag.Name)),
+		TokenGas:  ctx.Uint64(erc20GasFlag.Name),
+	}
+	if cfg.TPS <= 0 {
+		return errors.New("the target rate must be positive")
+	}
+	if cfg.Accounts <= 0 {
+		return errors.New("at least one account is needed")
+	}
+	if mix[kindERC20] > 0 {
+		token := ctx.String(erc20Flag.Name)
+		if !common.IsHexAddress(token) {
+			return fmt.Errorf("erc20 transactions need a token address, have %q", token)
+		}
+		cfg.Token = common.HexToAddress(token)
+	}
+
+	l2, err := ethclient.Dial(ctx.String(l2RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L2: %v", err)
+	}
+	defer l2.Close()
+	var l1 *ethclient.Client
+	if mix[kindDeposit] > 0 {
+		contract := ctx.String(depositContractFlag.Name)
+		if !common.IsHexAddress(contract) {
+			return fmt.Errorf("deposits need a deposit contract address, have %q", contract)
+		}
+		cfg.DepositContract = common.HexToAddress(contract)
+		if !ctx.IsSet(l1RPCFlag.Name) {
+			return errors.New("deposits need an L1 endpoint")
+		}
+		if l1, err = ethclient.Dial(ctx.String(l1RPCFlag.Name)); err != nil {
+			return fmt.Errorf("failed to connect to L1: %v", err)
+		}
+		defer l1.Close()
+	}
+
+	// Stop sending transactions on interrupt, the results so far are still
+	// reported.
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigc
+		log.Info("Interrupted, stopping load test")
+		cancel()
+	}()
+
+	s, err := newSpammer(runCtx, cfg, l1, l2, key)
+	if err != nil {
+		return err
+	}
+	if err := s.fund(runCtx); err != nil {
+		return err
+	}
+	s.run(runCtx, ctx.Duration(durationFlag.Name), ctx.Duration(drainFlag.Name), ctx.Duration(pollIntervalFlag.Name), ctx.Duration(reportIntervalFlag.Name))
+	s.report(os.Stdout)
+	return nil
+}
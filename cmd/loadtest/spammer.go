@@ -0,0 +1,682 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// txKind is the kind of a generated transaction.
+type txKind int
+
+const (
+	kindTransfer txKind = iota // ETH transfer between the accounts
+	kindERC20                  // ERC20 transfer between the accounts
+	kindDeposit                // deposit sent through the L1 deposit contract
+	numKinds
+)
+
+var kindNames = [numKinds]string{"transfer", "erc20", "deposit"}
+
+func (k txKind) String() string { return kindNames[k] }
+
+// parseMix parses a transaction mix of the form "transfer=8,erc20=1,deposit=1"
+// into the weights of the transaction kinds.
+func parseMix(s string) ([numKinds]uint64, error) {
+	var (
+		mix   [numKinds]uint64
+		total uint64
+	)
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return mix, fmt.Errorf("invalid mix entry %q, want kind=weight", part)
+		}
+		name, weight := kv[0], kv[1]
+		kind := txKind(-1)
+		for k := range kindNames {
+			if kindNames[k] == name {
+				kind = txKind(k)
+			}
+		}
+		if kind < 0 {
+			return mix, fmt.Errorf("unknown transaction kind %q", name)
+		}
+		w, err := strconv.ParseUint(weight, 10, 64)
+		if err != nil {
+			return mix, fmt.Errorf("invalid weight of %s: %v", name, err)
+		}
+		mix[kind] = w
+		total += w
+	}
+	if total == 0 {
+		return mix, errors.New("empty transaction mix")
+	}
+	return mix, nil
+}
+
+// erc20TransferSelector is the selector of transfer(address,uint256).
+var erc20TransferSelector = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+
+// erc20Transfer returns the calldata of an ERC20 transfer.
+func erc20Transfer(to common.Address, amount *big.Int) []byte {
+	data := make([]byte, 0, 4+2*common.HashLength)
+	data = append(data, erc20TransferSelector...)
+	data = append(data, common.LeftPadBytes(to[:], common.HashLength)...)
+	return append(data, common.LeftPadBytes(amount.Bytes(), common.HashLength)...)
+}
+
+const (
+	// depositGas is the L2 gas limit of the generated deposits.
+	depositGas = 100_000
+
+	// depositL1Gas is the L1 gas limit of the transactions that send deposits.
+	depositL1Gas = 150_000
+
+	// fundGas is the gas limit of the transactions that fund the accounts.
+	fundGas = 100_000
+)
+
+// config contains the settings of a load test.
+type config struct {
+	Mix      [numKinds]uint64 // Weights of the transaction kinds
+	TPS      float64          // Target rate of sent transactions
+	Accounts int              // Number of L2 accounts sending transactions
+	Fund     *big.Int         // L2 balance (wei) given to every account
+	Tip      *big.Int         // Gas tip cap of the sent transactions
+
+	Token     common.Address // ERC20 token of the erc20 transactions
+	TokenFund *big.Int       // Token amount given to every account
+	TokenGas  uint64         // Gas limit of the ERC20 transfers
+
+	DepositContract common.Address // L1 deposit contract
+}
+
+// account is a sender of transactions with a locally tracked nonce.
+type account struct {
+	key    *ecdsa.PrivateKey
+	addr   common.Address
+	nonce  uint64
+	queue  chan txKind // Transactions to send, owned by the sending worker
+	client *ethclient.Client
+	signer types.Signer
+}
+
+func newAccount(ctx context.Context, client *ethclient.Client, chainID *big.Int, key *ecdsa.PrivateKey) (*account, error) {
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch nonce of %s: %v", addr, err)
+	}
+	return &account{
+		key:    key,
+		addr:   addr,
+		nonce:  nonce,
+		queue:  make(chan txKind, 16),
+		client: client,
+		signer: types.LatestSignerForChainID(chainID),
+	}, nil
+}
+
+// send signs and sends a transaction with the next nonce of the account. If the
+// node rejects it, the nonce is fetched again, as the rejected transaction may
+// have consumed it or not.
+func (a *account) send(ctx context.Context, to *common.Address, value *big.Int, gas uint64, data []byte, tip, feeCap *big.Int) (*types.Transaction, error) {
+	tx, err := types.SignNewTx(a.key, a.signer, &types.DynamicFeeTx{
+		ChainID:   a.signer.ChainID(),
+		Nonce:     a.nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := a.client.SendTransaction(ctx, tx); err != nil {
+		if nonce, nerr := a.client.PendingNonceAt(ctx, a.addr); nerr == nil {
+			a.nonce = nonce
+		}
+		return nil, err
+	}
+	a.nonce++
+	return tx, nil
+}
+
+// sentTx is a sent transaction that is not included yet.
+type sentTx struct {
+	kind txKind
+	time time.Time
+}
+
+// kindStats are the statistics of a transaction kind.
+type kindStats struct {
+	sent      uint64
+	failed    uint64
+	included  uint64
+	latencies []time.Duration
+}
+
+// spammer sends transactions to an L2 chain at a target rate and tracks their
+// inclusion. The latency of a transaction is the time from sending it until it
+// is seen in an L2 block, deposits are sent on L1.
+type spammer struct {
+	cfg    config
+	l1, l2 *ethclient.Client
+
+	funder    *account   // L2 account of the key, funds the other accounts
+	depositor *account   // L1 account of the key, sends the deposits
+	accounts  []*account // L2 accounts sending the generated transactions
+
+	next     uint64             // Number of the next L2 block to observe, owned by the tracker
+	tracking context.CancelFunc // Stops the block tracking
+	tracked  chan struct{}      // Closed when the block tracking stopped
+	l1Waits  sync.WaitGroup     // Goroutines waiting for L1 deposit receipts
+
+	lock     sync.Mutex
+	feeCap   *big.Int                  // Fee cap of the L2 transactions
+	pending  map[common.Hash]sentTx    // Sent transactions waiting for inclusion
+	deposits map[common.Hash]time.Time // Included deposits not matched to an L1 transaction yet
+	stats    [numKinds]kindStats       // Statistics of the sent transactions
+	dropped  uint64                    // Transactions skipped because their sender lagged behind
+	first    *types.Header             // First L2 block observed
+	last     *types.Header             // Last L2 block observed
+	gasUsed  uint64                    // Gas used by the blocks after the first one
+	started  time.Time                 // Start of the load test
+	rng      *rand.Rand                // Randomness of the generated transactions
+}
+
+// newSpammer creates a spammer for the L2 chain. The L1 client is only needed
+// if the mix contains deposits. The accounts sending the transactions are
+// derived from the key.
+func newSpammer(ctx context.Context, cfg config, l1, l2 *ethclient.Client, key *ecdsa.PrivateKey) (*spammer, error) {
+	l2ChainID, err := l2.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 chain ID: %v", err)
+	}
+	head, err := l2.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 head: %v", err)
+	}
+	s := &spammer{
+		cfg:      cfg,
+		l1:       l1,
+		l2:       l2,
+		pending:  make(map[common.Hash]sentTx),
+		deposits: make(map[common.Hash]time.Time),
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		next:     head.Number.Uint64() + 1,
+	}
+	s.setFeeCap(head)
+
+	if s.funder, err = newAccount(ctx, l2, l2ChainID, key); err != nil {
+		return nil, err
+	}
+	if cfg.Mix[kindDeposit] > 0 {
+		l1ChainID, err := l1.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch L1 chain ID: %v", err)
+		}
+		if s.depositor, err = newAccount(ctx, l1, l1ChainID, key); err != nil {
+			return nil, err
+		}
+	}
+	seed := crypto.FromECDSA(key)
+	for i := 0; i < cfg.Accounts; i++ {
+		accKey, err := crypto.ToECDSA(crypto.Keccak256(seed, big.NewInt(int64(i)).Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		acc, err := newAccount(ctx, l2, l2ChainID, accKey)
+		if err != nil {
+			return nil, err
+		}
+		s.accounts = append(s.accounts, acc)
+	}
+	return s, nil
+}
+
+// setFeeCap sets the fee cap of the L2 transactions to allow for the base fee
+// to double.
+func (s *spammer) setFeeCap(head *types.Header) {
+	feeCap := new(big.Int).Set(s.cfg.Tip)
+	if head.BaseFee != nil {
+		feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, common.Big2))
+	}
+	s.feeCap = feeCap
+}
+
+func (s *spammer) l2FeeCap() *big.Int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.feeCap
+}
+
+// fund tops up the ETH and token balances of the accounts from the key, and
+// waits for the funding to be included.
+func (s *spammer) fund(ctx context.Context) error {
+	var txs []*types.Transaction
+	for _, acc := range s.accounts {
+		balance, err := s.l2.BalanceAt(ctx, acc.addr, nil)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(s.cfg.Fund) < 0 {
+			tx, err := s.funder.send(ctx, &acc.addr, new(big.Int).Sub(s.cfg.Fund, balance), fundGas, nil, s.cfg.Tip, s.l2FeeCap())
+			if err != nil {
+				return fmt.Errorf("failed to fund %s: %v", acc.addr, err)
+			}
+			txs = append(txs, tx)
+		}
+		if s.cfg.Mix[kindERC20] > 0 && s.cfg.TokenFund.Sign() > 0 {
+			tx, err := s.funder.send(ctx, &s.cfg.Token, new(big.Int), s.cfg.TokenGas, erc20Transfer(acc.addr, s.cfg.TokenFund), s.cfg.Tip, s.l2FeeCap())
+			if err != nil {
+				return fmt.Errorf("failed to send tokens to %s: %v", acc.addr, err)
+			}
+			txs = append(txs, tx)
+		}
+	}
+	for _, tx := range txs {
+		receipt, err := waitForReceipt(ctx, s.l2, tx.Hash())
+		if err != nil {
+			return err
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return fmt.Errorf("funding transaction %s failed", tx.Hash())
+		}
+	}
+	log.Info("Funded accounts", "accounts", len(s.accounts), "txs", len(txs))
+	return nil
+}
+
+// waitForReceipt polls the receipt of the transaction until it is included.
+func waitForReceipt(ctx context.Context, client *ethclient.Client, hash common.Hash) (*types.Receipt, error) {
+	for {
+		receipt, err := client.TransactionReceipt(ctx, hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// run sends transactions at the target rate for the given duration, or until
+// the context is cancelled. It then waits up to drain for the sent transactions
+// to be included.
+func (s *spammer) run(ctx context.Context, duration, drain, poll, reportInterval time.Duration) {
+	trackCtx, cancel := context.WithCancel(context.Background())
+	s.tracking, s.tracked = cancel, make(chan struct{})
+	go s.track(trackCtx, poll, reportInterval)
+
+	var workers sync.WaitGroup
+	senders := s.accounts
+	if s.depositor != nil {
+		senders = append(senders[:len(senders):len(senders)], s.depositor)
+	}
+	for _, acc := range senders {
+		workers.Add(1)
+		go func(acc *account) {
+			defer workers.Done()
+			for kind := range acc.queue {
+				s.sendTx(ctx, acc, kind)
+			}
+		}(acc)
+	}
+
+	s.lock.Lock()
+	s.started = time.Now()
+	s.lock.Unlock()
+	log.Info("Starting load test", "tps", s.cfg.TPS, "duration", duration, "accounts", len(s.accounts))
+
+	var (
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / s.cfg.TPS))
+		end    = time.NewTimer(duration)
+		total  uint64
+		sender int
+	)
+	for _, w := range s.cfg.Mix {
+		total += w
+	}
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-end.C:
+			break loop
+		case <-ticker.C:
+			kind, pick := kindTransfer, uint64(s.rngIntn(int(total)))
+			for k, w := range s.cfg.Mix {
+				if pick < w {
+					kind = txKind(k)
+					break
+				}
+				pick -= w
+			}
+			acc := s.depositor
+			if kind != kindDeposit {
+				acc = s.accounts[sender%len(s.accounts)]
+				sender++
+			}
+			select {
+			case acc.queue <- kind:
+			default:
+				s.lock.Lock()
+				s.dropped++
+				s.lock.Unlock()
+			}
+		}
+	}
+	ticker.Stop()
+	end.Stop()
+	for _, acc := range senders {
+		close(acc.queue)
+	}
+	workers.Wait()
+
+	// Wait for the sent transactions to be included.
+	drainCtx, cancelDrain := context.WithTimeout(ctx, drain)
+	defer cancelDrain()
+	for s.numPending() > 0 {
+		select {
+		case <-drainCtx.Done():
+			log.Warn("Stopped waiting for transactions", "pending", s.numPending())
+			s.stop()
+			return
+		case <-time.After(poll):
+		}
+	}
+	s.stop()
+}
+
+// stop stops the tracking of blocks and deposits.
+func (s *spammer) stop() {
+	s.tracking()
+	s.l1Waits.Wait()
+	<-s.tracked
+}
+
+// numPending returns the number of sent transactions that are not included yet,
+// including deposits that are not included on L1 yet.
+func (s *spammer) numPending() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var sent, done uint64
+	for _, st := range s.stats {
+		sent += st.sent
+		done += st.included + st.failed
+	}
+	return int(sent - done)
+}
+
+// sendTx sends a transaction of the given kind from the account.
+func (s *spammer) sendTx(ctx context.Context, acc *account, kind txKind) {
+	var (
+		to     = s.accounts[s.rngIntn(len(s.accounts))].addr
+		now    = time.Now()
+		tx     *types.Transaction
+		err    error
+		feeCap = s.l2FeeCap()
+	)
+	switch kind {
+	case kindTransfer:
+		tx, err = acc.send(ctx, &to, big.NewInt(1), params.TxGas, nil, s.cfg.Tip, feeCap)
+	case kindERC20:
+		tx, err = acc.send(ctx, &s.cfg.Token, new(big.Int), s.cfg.TokenGas, erc20Transfer(to, common.Big1), s.cfg.Tip, feeCap)
+	case kindDeposit:
+		tx, err = s.sendDeposit(ctx, acc, to)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stats[kind].sent++
+	if err != nil {
+		s.stats[kind].failed++
+		log.Debug("Failed to send transaction", "kind", kind, "from", acc.addr, "err", err)
+		return
+	}
+	if kind != kindDeposit {
+		s.pending[tx.Hash()] = sentTx{kind: kind, time: now}
+		return
+	}
+	// The L2 hash of a deposit is only known once the L1 transaction is
+	// included, as its source hash is derived from the L1 log.
+	s.l1Waits.Add(1)
+	go func() {
+		defer s.l1Waits.Done()
+		s.waitForDeposit(tx, acc.signer, now)
+	}()
+}
+
+// rngIntn returns a random number below n. It is safe for concurrent use.
+func (s *spammer) rngIntn(n int) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.rng.Intn(n)
+}
+
+// sendDeposit sends an L1 transaction to the deposit contract that deposits a
+// transfer of one gwei to the given L2 account. The calldata holds the target
+// and the opaque data of the deposit event, like the devnet deposit contract
+// expects.
+func (s *spammer) sendDeposit(ctx context.Context, acc *account, to common.Address) (*types.Transaction, error) {
+	head, err := s.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := big.NewInt(params.GWei)
+	ev := derive.MarshalDepositLogEvent(s.cfg.DepositContract, &types.DepositTx{
+		From:  acc.addr,
+		To:    &to,
+		Mint:  value,
+		Value: value,
+		Gas:   depositGas,
+	})
+	data := append(ev.Topics[2].Bytes(), ev.Data...)
+	feeCap := new(big.Int).Set(s.cfg.Tip)
+	if head.BaseFee != nil {
+		feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, common.Big2))
+	}
+	return acc.send(ctx, &s.cfg.DepositContract, value, depositL1Gas, data, s.cfg.Tip, feeCap)
+}
+
+// waitForDeposit waits for the L1 transaction of a deposit, and then tracks the
+// inclusion of the L2 deposit it emitted.
+func (s *spammer) waitForDeposit(tx *types.Transaction, signer types.Signer, sent time.Time) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.tracked:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	receipt, err := waitForReceipt(ctx, s.l1, tx.Hash())
+	var deposits []*types.DepositTx
+	if err == nil {
+		deposits, err = derive.UserDeposits(signer, types.Transactions{tx}, []*types.Receipt{receipt}, s.cfg.DepositContract)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err != nil || len(deposits) != 1 {
+		s.stats[kindDeposit].failed++
+		log.Debug("Deposit not emitted on L1", "tx", tx.Hash(), "err", err)
+		return
+	}
+	l2Hash := types.NewTx(deposits[0]).Hash()
+	if included, ok := s.deposits[l2Hash]; ok {
+		delete(s.deposits, l2Hash)
+		s.include(kindDeposit, included.Sub(sent))
+		return
+	}
+	s.pending[l2Hash] = sentTx{kind: kindDeposit, time: sent}
+}
+
+// include records the inclusion of a transaction. The lock must be held.
+func (s *spammer) include(kind txKind, latency time.Duration) {
+	s.stats[kind].included++
+	s.stats[kind].latencies = append(s.stats[kind].latencies, latency)
+}
+
+// track polls the new L2 blocks for the inclusion of the sent transactions,
+// and logs the progress at the given interval.
+func (s *spammer) track(ctx context.Context, poll, reportInterval time.Duration) {
+	defer close(s.tracked)
+
+	pollTimer := time.NewTicker(poll)
+	defer pollTimer.Stop()
+	report := time.NewTicker(reportInterval)
+	defer report.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-report.C:
+			s.logProgress()
+		case <-pollTimer.C:
+			head, err := s.l2.BlockNumber(ctx)
+			if err != nil {
+				log.Debug("Failed to fetch L2 head", "err", err)
+				continue
+			}
+			for ; s.next <= head; s.next++ {
+				block, err := s.l2.BlockByNumber(ctx, new(big.Int).SetUint64(s.next))
+				if err != nil {
+					log.Debug("Failed to fetch L2 block", "number", s.next, "err", err)
+					break
+				}
+				s.processBlock(block, time.Now())
+			}
+		}
+	}
+}
+
+// processBlock records the inclusion of the sent transactions in the block.
+func (s *spammer) processBlock(block *types.Block, seen time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.setFeeCap(block.Header())
+	if s.first == nil {
+		s.first = block.Header()
+	} else {
+		s.gasUsed += block.GasUsed()
+	}
+	s.last = block.Header()
+
+	for _, tx := range block.Transactions() {
+		if sent, ok := s.pending[tx.Hash()]; ok {
+			delete(s.pending, tx.Hash())
+			s.include(sent.kind, seen.Sub(sent.time))
+			continue
+		}
+		if s.depositor != nil && tx.Type() == types.DepositTxType && !tx.IsSystemTx() {
+			s.deposits[tx.Hash()] = seen
+		}
+	}
+}
+
+// logProgress logs the progress of the load test.
+func (s *spammer) logProgress() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var sent, included, failed uint64
+	for _, st := range s.stats {
+		sent += st.sent
+		included += st.included
+		failed += st.failed
+	}
+	elapsed := time.Since(s.started)
+	log.Info("Load test progress", "sent", sent, "included", included, "failed", failed, "dropped", s.dropped,
+		"tps", fmt.Sprintf("%.1f", float64(sent)/elapsed.Seconds()), "mgas/s", fmt.Sprintf("%.2f", s.gasPerSecond()/1e6))
+}
+
+// gasPerSecond returns the gas used per second of block time by the observed
+// blocks. The lock must be held.
+func (s *spammer) gasPerSecond() float64 {
+	if s.first == nil || s.last.Time <= s.first.Time {
+		return 0
+	}
+	return float64(s.gasUsed) / float64(s.last.Time-s.first.Time)
+}
+
+// report writes the statistics of the load test.
+func (s *spammer) report(w io.Writer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fmt.Fprintf(w, "%-10s %8s %8s %8s %10s %10s %10s %10s\n", "kind", "sent", "failed", "included", "p50", "p90", "p99", "max")
+	for kind, st := range s.stats {
+		if s.cfg.Mix[kind] == 0 {
+			continue
+		}
+		sort.Slice(st.latencies, func(i, j int) bool { return st.latencies[i] < st.latencies[j] })
+		fmt.Fprintf(w, "%-10s %8d %8d %8d %10v %10v %10v %10v\n", txKind(kind), st.sent, st.failed, st.included,
+			percentile(st.latencies, 50), percentile(st.latencies, 90), percentile(st.latencies, 99), percentile(st.latencies, 100))
+	}
+	fmt.Fprintf(w, "\ndropped:   %d transactions, the senders could not keep up\n", s.dropped)
+	fmt.Fprintf(w, "pending:   %d transactions\n", len(s.pending))
+	if s.first != nil {
+		fmt.Fprintf(w, "blocks:    %d to %d\n", s.first.Number, s.last.Number)
+	}
+	fmt.Fprintf(w, "gas:       %d, %.2f Mgas/s\n", s.gasUsed, s.gasPerSecond()/1e6)
+}
+
+// percentile returns the p-th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	return sorted[i].Round(time.Millisecond)
+}
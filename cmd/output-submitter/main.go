@@ -0,0 +1,203 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// output-submitter proposes the output roots of safe L2 blocks to the L1 output
+// oracle.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup/node"
+	"github.com/ethereum/go-ethereum/rollup/proposer"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	rollupRPCFlag = &cli.StringFlag{
+		Name:     "rollup",
+		Usage:    "HTTP or WebSocket endpoint of the rollup node whose outputs are proposed",
+		Required: true,
+	}
+	keyFlag = &cli.StringFlag{
+		Name:     "key",
+		Usage:    "file containing the hex encoded private key of the proposer",
+		Required: true,
+	}
+	oracleFlag = &cli.StringFlag{
+		Name:     "oracle",
+		Usage:    "L1 address of the L2 output oracle",
+		Required: true,
+	}
+	intervalFlag = &cli.Uint64Flag{
+		Name:  "interval",
+		Usage: "number of L2 blocks between two outputs",
+		Value: proposer.DefaultConfig.SubmissionInterval,
+	}
+	resubmitFlag = &cli.DurationFlag{
+		Name:  "resubmit-timeout",
+		Usage: "time after which a pending proposal is resubmitted with higher fees",
+		Value: proposer.DefaultConfig.ResubmitTimeout,
+	}
+	maxGasPriceFlag = &cli.Uint64Flag{
+		Name:  "max-gas-price",
+		Usage: "highest L1 fee cap (gwei) to propose at, 0 for no limit",
+	}
+	pollIntervalFlag = &cli.DurationFlag{
+		Name:  "poll-interval",
+		Usage: "interval at which the rollup node and proposals are polled",
+		Value: proposer.DefaultConfig.PollInterval,
+	}
+	maxRetryIntervalFlag = &cli.DurationFlag{
+		Name:  "max-retry-interval",
+		Usage: "longest interval to back off to after failures",
+		Value: proposer.DefaultConfig.MaxRetryInterval,
+	}
+	pprofFlag = &cli.BoolFlag{
+		Name:  "pprof",
+		Usage: "enable the pprof HTTP server, serving the runtime profiles at /debug/pprof",
+	}
+	pprofAddrFlag = &cli.StringFlag{
+		Name:  "pprof.addr",
+		Usage: "listening address of the pprof HTTP server",
+		Value: "127.0.0.1:6061",
+	}
+	blockProfileRateFlag = &cli.IntFlag{
+		Name:  "pprof.blockprofilerate",
+		Usage: "turn on block profiling with the given rate (see runtime.SetBlockProfileRate)",
+	}
+	mutexProfileRateFlag = &cli.IntFlag{
+		Name:  "pprof.mutexprofilerate",
+		Usage: "turn on mutex profiling with the given rate (see runtime.SetMutexProfileFraction)",
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 output submitter")
+	app.Flags = []cli.Flag{
+		l1RPCFlag,
+		rollupRPCFlag,
+		keyFlag,
+		oracleFlag,
+		intervalFlag,
+		resubmitFlag,
+		maxGasPriceFlag,
+		pollIntervalFlag,
+		maxRetryIntervalFlag,
+		pprofFlag,
+		pprofAddrFlag,
+		blockProfileRateFlag,
+		mutexProfileRateFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	if ctx.Bool(pprofFlag.Name) {
+		runtime.SetBlockProfileRate(ctx.Int(blockProfileRateFlag.Name))
+		runtime.SetMutexProfileFraction(ctx.Int(mutexProfileRateFlag.Name))
+		debug.StartPProf(ctx.String(pprofAddrFlag.Name), false)
+	}
+
+	key, err := crypto.LoadECDSA(ctx.String(keyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load proposer key: %v", err)
+	}
+	oracle := ctx.String(oracleFlag.Name)
+	if !common.IsHexAddress(oracle) {
+		return fmt.Errorf("invalid output oracle address %q", oracle)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+	rollupNode, err := node.Dial(context.Background(), ctx.String(rollupRPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to rollup node: %v", err)
+	}
+	defer rollupNode.Close()
+
+	chainID, err := l1.ChainID(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 chain ID: %v", err)
+	}
+	cfg := proposer.Config{
+		L1ChainID:           chainID,
+		OutputOracleAddress: common.HexToAddress(oracle),
+		SubmissionInterval:  ctx.Uint64(intervalFlag.Name),
+		ResubmitTimeout:     ctx.Duration(resubmitFlag.Name),
+		PollInterval:        ctx.Duration(pollIntervalFlag.Name),
+		MaxRetryInterval:    ctx.Duration(maxRetryIntervalFlag.Name),
+	}
+	if gwei := ctx.Uint64(maxGasPriceFlag.Name); gwei > 0 {
+		cfg.MaxGasPrice = new(big.Int).Mul(new(big.Int).SetUint64(gwei), big.NewInt(params.GWei))
+	}
+	submitter, err := proposer.New(cfg, l1, rollupNode, key, log.Root())
+	if err != nil {
+		return err
+	}
+	submitter.Start()
+	log.Info("Output submitter started", "proposer", crypto.PubkeyToAddress(key.PublicKey), "oracle", cfg.OutputOracleAddress, "interval", cfg.SubmissionInterval)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down output submitter")
+	submitter.Stop()
+	return nil
+}
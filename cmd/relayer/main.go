@@ -0,0 +1,213 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// relayer finalizes the messages sent from L2 to L1 on L1, once the outputs
+// they are proven against are final.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup/node"
+	"github.com/ethereum/go-ethereum/rollup/relayer"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	l2RPCFlag = &cli.StringFlag{
+		Name:     "l2",
+		Usage:    "HTTP or WebSocket endpoint of the L2 node",
+		Required: true,
+	}
+	rollupRPCFlag = &cli.StringFlag{
+		Name:     "rollup",
+		Usage:    "HTTP or WebSocket endpoint of the rollup node that messages are proven with",
+		Required: true,
+	}
+	keyFlag = &cli.StringFlag{
+		Name:     "key",
+		Usage:    "file containing the hex encoded private key of the relayer",
+		Required: true,
+	}
+	portalFlag = &cli.StringFlag{
+		Name:     "portal",
+		Usage:    "L1 address of the portal",
+		Required: true,
+	}
+	oracleFlag = &cli.StringFlag{
+		Name:     "oracle",
+		Usage:    "L1 address of the L2 output oracle",
+		Required: true,
+	}
+	stateFlag = &cli.StringFlag{
+		Name:  "state",
+		Usage: "file the relayed messages are persisted in",
+		Value: "relayer-state.json",
+	}
+	l1StartBlockFlag = &cli.Uint64Flag{
+		Name:  "l1-start-block",
+		Usage: "L1 block from which output proposals are fetched",
+	}
+	l2StartBlockFlag = &cli.Uint64Flag{
+		Name:  "l2-start-block",
+		Usage: "L2 block from which messages are relayed, if there is no state file yet",
+	}
+	finalizationPeriodFlag = &cli.DurationFlag{
+		Name:  "finalization-period",
+		Usage: "time after its proposal that an output is final",
+		Value: relayer.DefaultConfig.FinalizationPeriod,
+	}
+	resubmitFlag = &cli.DurationFlag{
+		Name:  "resubmit-timeout",
+		Usage: "time after which a pending relay transaction is resubmitted with higher fees",
+		Value: relayer.DefaultConfig.ResubmitTimeout,
+	}
+	maxGasPriceFlag = &cli.Uint64Flag{
+		Name:  "max-gas-price",
+		Usage: "highest L1 fee cap (gwei) to relay at, 0 for no limit",
+	}
+	pollIntervalFlag = &cli.DurationFlag{
+		Name:  "poll-interval",
+		Usage: "interval at which L1, L2 and the rollup node are polled",
+		Value: relayer.DefaultConfig.PollInterval,
+	}
+	maxRetryIntervalFlag = &cli.DurationFlag{
+		Name:  "max-retry-interval",
+		Usage: "longest interval to back off to after failures",
+		Value: relayer.DefaultConfig.MaxRetryInterval,
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 to L1 message relayer")
+	app.Flags = []cli.Flag{
+		l1RPCFlag,
+		l2RPCFlag,
+		rollupRPCFlag,
+		keyFlag,
+		portalFlag,
+		oracleFlag,
+		stateFlag,
+		l1StartBlockFlag,
+		l2StartBlockFlag,
+		finalizationPeriodFlag,
+		resubmitFlag,
+		maxGasPriceFlag,
+		pollIntervalFlag,
+		maxRetryIntervalFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	key, err := crypto.LoadECDSA(ctx.String(keyFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load relayer key: %v", err)
+	}
+	portal, oracle := ctx.String(portalFlag.Name), ctx.String(oracleFlag.Name)
+	if !common.IsHexAddress(portal) {
+		return fmt.Errorf("invalid portal address %q", portal)
+	}
+	if !common.IsHexAddress(oracle) {
+		return fmt.Errorf("invalid output oracle address %q", oracle)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+	l2, err := ethclient.Dial(ctx.String(l2RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L2: %v", err)
+	}
+	defer l2.Close()
+	rollupNode, err := node.Dial(context.Background(), ctx.String(rollupRPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to rollup node: %v", err)
+	}
+	defer rollupNode.Close()
+
+	chainID, err := l1.ChainID(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 chain ID: %v", err)
+	}
+	cfg := relayer.DefaultConfig
+	cfg.L1ChainID = chainID
+	cfg.PortalAddress = common.HexToAddress(portal)
+	cfg.OutputOracleAddress = common.HexToAddress(oracle)
+	cfg.StateFile = ctx.String(stateFlag.Name)
+	cfg.L1StartBlock = ctx.Uint64(l1StartBlockFlag.Name)
+	cfg.L2StartBlock = ctx.Uint64(l2StartBlockFlag.Name)
+	cfg.FinalizationPeriod = ctx.Duration(finalizationPeriodFlag.Name)
+	cfg.ResubmitTimeout = ctx.Duration(resubmitFlag.Name)
+	cfg.PollInterval = ctx.Duration(pollIntervalFlag.Name)
+	cfg.MaxRetryInterval = ctx.Duration(maxRetryIntervalFlag.Name)
+	if gwei := ctx.Uint64(maxGasPriceFlag.Name); gwei > 0 {
+		cfg.MaxGasPrice = new(big.Int).Mul(new(big.Int).SetUint64(gwei), big.NewInt(params.GWei))
+	}
+	r, err := relayer.New(cfg, l1, l2, rollupNode, key, log.Root())
+	if err != nil {
+		return err
+	}
+	r.Start()
+	log.Info("Relayer started", "relayer", crypto.PubkeyToAddress(key.PublicKey), "portal", cfg.PortalAddress, "oracle", cfg.OutputOracleAddress)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down relayer")
+	r.Stop()
+	return nil
+}
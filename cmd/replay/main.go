@@ -0,0 +1,181 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// replay derives a range of L2 blocks again from L1 and compares them with the
+// blocks of a reference L2 node. It reports the first block that differs, with
+// the batch and the deposit it differs on, as JSON, to debug consensus splits.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/replay"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	l2RPCFlag = &cli.StringFlag{
+		Name:     "l2",
+		Usage:    "HTTP or WebSocket endpoint of the reference L2 node, which must serve debug_dbGet and keep the state before the start block",
+		Required: true,
+	}
+	rollupConfigFlag = &cli.StringFlag{
+		Name:     "rollup.config",
+		Usage:    "rollup configuration file",
+		Required: true,
+	}
+	l2GenesisFlag = &cli.StringFlag{
+		Name:     "l2.genesis",
+		Usage:    "L2 genesis file, for the chain config of the L2 chain",
+		Required: true,
+	}
+	startBlockFlag = &cli.Uint64Flag{
+		Name:     "start-block",
+		Usage:    "first L2 block to derive",
+		Required: true,
+	}
+	endBlockFlag = &cli.Uint64Flag{
+		Name:  "end-block",
+		Usage: "last L2 block to derive, the start block if unset",
+	}
+	outFlag = &cli.StringFlag{
+		Name:  "out",
+		Usage: "file to write the JSON report to, standard output if unset",
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 derivation replay")
+	app.Flags = []cli.Flag{
+		l1RPCFlag,
+		l2RPCFlag,
+		rollupConfigFlag,
+		l2GenesisFlag,
+		startBlockFlag,
+		endBlockFlag,
+		outFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	rollupCfg, err := rollup.LoadConfig(ctx.String(rollupConfigFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load rollup configuration: %v", err)
+	}
+	gspec, err := loadGenesis(ctx.String(l2GenesisFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to load L2 genesis: %v", err)
+	}
+	cfg := replay.Config{
+		Rollup:  rollupCfg,
+		L2Chain: gspec.Config,
+		Start:   ctx.Uint64(startBlockFlag.Name),
+		End:     ctx.Uint64(startBlockFlag.Name),
+	}
+	if ctx.IsSet(endBlockFlag.Name) {
+		cfg.End = ctx.Uint64(endBlockFlag.Name)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+	l2, err := rpc.DialContext(ctx.Context, ctx.String(l2RPCFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to connect to L2: %v", err)
+	}
+	defer l2.Close()
+
+	log.Info("Replaying L2 blocks", "start", cfg.Start, "end", cfg.End)
+	res, err := replay.Run(ctx.Context, cfg, derive.NewL1Source(l1), replay.NewRPCOracle(ctx.Context, l2), ethclient.NewClient(l2), log.Root())
+	if err != nil {
+		return err
+	}
+	out := io.Writer(os.Stdout)
+	if path := ctx.String(outFlag.Name); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+	if d := res.Divergence; d != nil {
+		return fmt.Errorf("block %d differs from the reference chain: derived %s, reference %s", d.Number, d.DerivedHash, d.ReferenceHash)
+	}
+	log.Info("Replayed blocks match the reference chain", "start", cfg.Start, "end", cfg.End)
+	return nil
+}
+
+// loadGenesis reads an L2 genesis file.
+func loadGenesis(path string) (*core.Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var gspec core.Genesis
+	if err := json.Unmarshal(data, &gspec); err != nil {
+		return nil, err
+	}
+	if gspec.Config == nil {
+		return nil, fmt.Errorf("%s has no chain config", path)
+	}
+	return &gspec, nil
+}
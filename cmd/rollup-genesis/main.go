@@ -0,0 +1,242 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// rollup-genesis generates the genesis of a new L2 chain and the matching rollup
+// configuration, or migrates the state of a legacy chain into one.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/rollup/genesis"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	deployConfigFlag = &cli.StringFlag{
+		Name:     "deploy-config",
+		Usage:    "JSON file describing the L2 chain",
+		Required: true,
+	}
+	l1RPCFlag = &cli.StringFlag{
+		Name:     "l1",
+		Usage:    "HTTP or WebSocket endpoint of the L1 node",
+		Required: true,
+	}
+	l1BlockFlag = &cli.Int64Flag{
+		Name:  "l1-block",
+		Usage: "number of the L1 block to anchor the L2 chain to, -1 for the latest block",
+		Value: -1,
+	}
+	l2OutFlag = &cli.StringFlag{
+		Name:  "outfile.l2",
+		Usage: "file to write the L2 genesis to",
+		Value: "genesis-l2.json",
+	}
+	rollupOutFlag = &cli.StringFlag{
+		Name:  "outfile.rollup",
+		Usage: "file to write the rollup configuration to",
+		Value: "rollup.json",
+	}
+
+	legacyDumpFlag = &cli.StringFlag{
+		Name:     "legacy-dump",
+		Usage:    "JSON state dump of the last block of the legacy chain",
+		Required: true,
+	}
+	legacyHeaderFlag = &cli.StringFlag{
+		Name:     "legacy-header",
+		Usage:    "JSON header of the last block of the legacy chain",
+		Required: true,
+	}
+	legacyWithdrawalsFlag = &cli.StringFlag{
+		Name:  "legacy-withdrawals",
+		Usage: "JSON list of the withdrawals sent on the legacy chain",
+	}
+	dryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "check the migration and print a summary, without writing the outputs",
+	}
+	diffReportFlag = &cli.StringFlag{
+		Name:  "diff-report",
+		Usage: "file to write the changes of the migrated accounts to",
+	}
+	headerOutFlag = &cli.StringFlag{
+		Name:  "outfile.header",
+		Usage: "file to write the header of the L2 genesis block to",
+		Value: "genesis-header.json",
+	}
+)
+
+var migrateCommand = &cli.Command{
+	Name:  "migrate",
+	Usage: "Migrate the state of a legacy chain into the genesis of an L2 chain",
+	Description: `
+The migration moves the balances of the legacy ETH token into the account
+balances, replaces the predeploys and converts the legacy withdrawals. The
+genesis block follows the last block of the legacy chain.`,
+	Flags: []cli.Flag{
+		legacyDumpFlag,
+		legacyHeaderFlag,
+		legacyWithdrawalsFlag,
+		dryRunFlag,
+		diffReportFlag,
+		headerOutFlag,
+	},
+	Action: migrate,
+}
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 genesis generator")
+	app.Flags = []cli.Flag{
+		deployConfigFlag,
+		l1RPCFlag,
+		l1BlockFlag,
+		l2OutFlag,
+		rollupOutFlag,
+	}
+	app.Action = run
+	app.Commands = []*cli.Command{migrateCommand}
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig reads the deploy config and fetches the L1 anchor block.
+func loadConfig(ctx *cli.Context) (*genesis.DeployConfig, *types.Header, error) {
+	cfg, err := genesis.LoadDeployConfig(ctx.String(deployConfigFlag.Name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load deploy config: %v", err)
+	}
+	l1, err := ethclient.Dial(ctx.String(l1RPCFlag.Name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+
+	var number *big.Int
+	if n := ctx.Int64(l1BlockFlag.Name); n >= 0 {
+		number = big.NewInt(n)
+	}
+	anchor, err := l1.HeaderByNumber(context.Background(), number)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch L1 anchor block: %v", err)
+	}
+	return cfg, anchor, nil
+}
+
+func run(ctx *cli.Context) error {
+	cfg, anchor, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	l2Genesis, err := genesis.BuildL2Genesis(cfg, anchor)
+	if err != nil {
+		return err
+	}
+	return writeGenesis(ctx, cfg, anchor, l2Genesis)
+}
+
+// writeGenesis writes the L2 genesis and the matching rollup configuration.
+func writeGenesis(ctx *cli.Context, cfg *genesis.DeployConfig, anchor *types.Header, l2Genesis *core.Genesis) error {
+	rollupCfg := genesis.BuildRollupConfig(cfg, anchor, l2Genesis.ToBlock(nil))
+	if err := rollupCfg.Check(); err != nil {
+		return err
+	}
+	if err := writeJSON(ctx.String(l2OutFlag.Name), l2Genesis); err != nil {
+		return err
+	}
+	return rollupCfg.Save(ctx.String(rollupOutFlag.Name))
+}
+
+func migrate(ctx *cli.Context) error {
+	var (
+		dump        state.Dump
+		head        types.Header
+		withdrawals []*genesis.LegacyWithdrawal
+	)
+	if err := readJSON(ctx.String(legacyDumpFlag.Name), &dump); err != nil {
+		return fmt.Errorf("failed to load legacy state dump: %v", err)
+	}
+	if err := readJSON(ctx.String(legacyHeaderFlag.Name), &head); err != nil {
+		return fmt.Errorf("failed to load legacy header: %v", err)
+	}
+	if path := ctx.String(legacyWithdrawalsFlag.Name); path != "" {
+		if err := readJSON(path, &withdrawals); err != nil {
+			return fmt.Errorf("failed to load legacy withdrawals: %v", err)
+		}
+	}
+	cfg, anchor, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	l2Genesis, report, err := genesis.MigrateLegacyState(cfg, &dump, withdrawals, &head, anchor)
+	if err != nil {
+		return err
+	}
+	block := l2Genesis.ToBlock(nil)
+	fmt.Printf("Migrated %d accounts, %d changed\n", report.Accounts, len(report.Diffs))
+	fmt.Printf("Minted %v wei, converted %d withdrawals\n", report.Minted, report.Withdrawals)
+	fmt.Printf("Genesis block %d, hash %s, state root %s\n", block.NumberU64(), block.Hash(), block.Root())
+
+	if path := ctx.String(diffReportFlag.Name); path != "" {
+		if err := writeJSON(path, report); err != nil {
+			return err
+		}
+	}
+	if ctx.Bool(dryRunFlag.Name) {
+		return nil
+	}
+	if err := writeJSON(ctx.String(headerOutFlag.Name), block.Header()); err != nil {
+		return err
+	}
+	return writeGenesis(ctx, cfg, anchor, l2Genesis)
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup/driver"
+	"github.com/naoina/toml"
+	"github.com/urfave/cli/v2"
+)
+
+// These settings ensure that TOML keys use the same names as Go struct fields.
+var tomlSettings = toml.Config{
+	NormFieldName: func(rt reflect.Type, key string) string {
+		return key
+	},
+	FieldToKey: func(rt reflect.Type, field string) string {
+		return field
+	},
+	MissingField: func(rt reflect.Type, field string) error {
+		return fmt.Errorf("field '%s' is not defined in %s", field, rt.String())
+	},
+}
+
+// p2pConfig contains the settings of the payload gossip network.
+type p2pConfig struct {
+	ListenAddr  string   // empty to disable the gossip
+	Bootnodes   []string `toml:",omitempty"`
+	StaticNodes []string `toml:",omitempty"` // peers that are always kept connected
+	NoDiscovery bool     // disables the discv5 discovery of peers
+	NAT         string   `toml:",omitempty"` // NAT port mapping mechanism, see nat.Parse
+	NodeKey     string   `toml:",omitempty"` // file of the node key, ephemeral if empty
+	MaxPeers    int
+
+	// SequencerAddress signs the payloads that are accepted from the network,
+	// unless the system config on L1 authorizes other signers.
+	SequencerAddress common.Address
+	// SequencerKey is the file of the key that signs published payloads.
+	SequencerKey string `toml:",omitempty"`
+}
+
+// nodeConfig is the configuration of the rollup node. It is assembled from the
+// defaults, the TOML config file, the environment and the command line flags,
+// in increasing order of precedence.
+type nodeConfig struct {
+	L1              string        // endpoint of the L1 node
+	Engine          string        // endpoint of the engine API of the L2 execution engine
+	EngineJWTSecret string        `toml:",omitempty"`
+	EngineTimeout   time.Duration // time the engine has to answer an Engine API call
+	Rollup          string        // rollup configuration file
+	// L2Genesis is the genesis file of the L2 chain, whose chain config the
+	// rollup configuration is checked against.
+	L2Genesis string `toml:",omitempty"`
+
+	Sequencer     bool
+	ELSync        bool
+	HeadsFile     string
+	SafeDepth     uint64
+	FinalityDepth uint64
+	RPCAddr       string
+
+	// SequencerInterrupt is how long sequenced blocks stay open to be restarted
+	// on a new L1 origin with deposits, 0 to seal them right away.
+	SequencerInterrupt time.Duration `toml:",omitempty"`
+
+	P2P p2pConfig
+	// BackfillRPC is the endpoint of a trusted L2 node that missing unsafe
+	// payloads are requested from.
+	BackfillRPC string `toml:",omitempty"`
+	// DerivationTrace is the number of recent derivation steps served by
+	// optimism_derivationTrace, 0 to disable tracing.
+	DerivationTrace int `toml:",omitempty"`
+	// DepositIndex is the directory of the database that indexes the derived
+	// deposits for optimism_depositByL1Hash, empty to disable the index.
+	DepositIndex string `toml:",omitempty"`
+
+	Metrics     bool
+	MetricsAddr string
+	// Pprof serves the runtime profiles of net/http/pprof on PprofAddr.
+	Pprof     bool
+	PprofAddr string
+	// BlockProfileRate and MutexProfileRate turn on the block and mutex
+	// profiles, see runtime.SetBlockProfileRate and
+	// runtime.SetMutexProfileFraction. Zero leaves them off.
+	BlockProfileRate int `toml:",omitempty"`
+	MutexProfileRate int `toml:",omitempty"`
+	Verbosity        int
+}
+
+var defaultConfig = nodeConfig{
+	EngineTimeout: driver.DefaultEngineTimeout,
+	HeadsFile:     "rollup-heads.json",
+	RPCAddr:       "127.0.0.1:9545",
+	P2P: p2pConfig{
+		ListenAddr: ":9222",
+		MaxPeers:   30,
+	},
+	MetricsAddr: "127.0.0.1:6060",
+	PprofAddr:   "127.0.0.1:6061",
+	Verbosity:   int(log.LvlInfo),
+}
+
+// loadConfig reads the TOML config file over the given configuration.
+func loadConfig(file string, cfg *nodeConfig) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = tomlSettings.NewDecoder(bufio.NewReader(f)).Decode(cfg)
+	// Add file name to errors that have a line number.
+	if _, ok := err.(*toml.LineError); ok {
+		err = errors.New(file + ", " + err.Error())
+	}
+	return err
+}
+
+// makeConfig assembles the node configuration. Flags that are set, on the
+// command line or through their environment variables, override the config
+// file, which overrides the defaults.
+func makeConfig(ctx *cli.Context) (nodeConfig, error) {
+	cfg := defaultConfig
+	if file := ctx.String(configFileFlag.Name); file != "" {
+		if err := loadConfig(file, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	setString := func(flag *cli.StringFlag, v *string) {
+		if ctx.IsSet(flag.Name) {
+			*v = ctx.String(flag.Name)
+		}
+	}
+	setUint64 := func(flag *cli.Uint64Flag, v *uint64) {
+		if ctx.IsSet(flag.Name) {
+			*v = ctx.Uint64(flag.Name)
+		}
+	}
+	setBool := func(flag *cli.BoolFlag, v *bool) {
+		if ctx.IsSet(flag.Name) {
+			*v = ctx.Bool(flag.Name)
+		}
+	}
+	setDuration := func(flag *cli.DurationFlag, v *time.Duration) {
+		if ctx.IsSet(flag.Name) {
+			*v = ctx.Duration(flag.Name)
+		}
+	}
+	setInt := func(flag *cli.IntFlag, v *int) {
+		if ctx.IsSet(flag.Name) {
+			*v = ctx.Int(flag.Name)
+		}
+	}
+	setString(l1RPCFlag, &cfg.L1)
+	setString(engineRPCFlag, &cfg.Engine)
+	setString(engineJWTSecretFlag, &cfg.EngineJWTSecret)
+	setDuration(engineTimeoutFlag, &cfg.EngineTimeout)
+	setString(rollupConfigFlag, &cfg.Rollup)
+	setString(l2GenesisFlag, &cfg.L2Genesis)
+	setBool(sequencerFlag, &cfg.Sequencer)
+	setDuration(sequencerInterruptFlag, &cfg.SequencerInterrupt)
+	setBool(elSyncFlag, &cfg.ELSync)
+	setString(headsFlag, &cfg.HeadsFile)
+	setUint64(safeDepthFlag, &cfg.SafeDepth)
+	setUint64(finalityDepthFlag, &cfg.FinalityDepth)
+	setString(rpcAddrFlag, &cfg.RPCAddr)
+	setString(p2pListenAddrFlag, &cfg.P2P.ListenAddr)
+	if ctx.IsSet(p2pBootnodesFlag.Name) {
+		cfg.P2P.Bootnodes = ctx.StringSlice(p2pBootnodesFlag.Name)
+	}
+	if ctx.IsSet(p2pStaticFlag.Name) {
+		cfg.P2P.StaticNodes = ctx.StringSlice(p2pStaticFlag.Name)
+	}
+	setBool(p2pNoDiscoveryFlag, &cfg.P2P.NoDiscovery)
+	setString(p2pNATFlag, &cfg.P2P.NAT)
+	setString(p2pNodeKeyFlag, &cfg.P2P.NodeKey)
+	setInt(p2pMaxPeersFlag, &cfg.P2P.MaxPeers)
+	if ctx.IsSet(p2pSequencerAddrFlag.Name) {
+		addr := ctx.String(p2pSequencerAddrFlag.Name)
+		if !common.IsHexAddress(addr) {
+			return cfg, fmt.Errorf("invalid sequencer address %q", addr)
+		}
+		cfg.P2P.SequencerAddress = common.HexToAddress(addr)
+	}
+	setString(p2pSequencerKeyFlag, &cfg.P2P.SequencerKey)
+	setString(backfillRPCFlag, &cfg.BackfillRPC)
+	setInt(derivationTraceFlag, &cfg.DerivationTrace)
+	setString(depositIndexFlag, &cfg.DepositIndex)
+	setBool(metricsFlag, &cfg.Metrics)
+	setString(metricsAddrFlag, &cfg.MetricsAddr)
+	setBool(pprofFlag, &cfg.Pprof)
+	setString(pprofAddrFlag, &cfg.PprofAddr)
+	setInt(blockProfileRateFlag, &cfg.BlockProfileRate)
+	setInt(mutexProfileRateFlag, &cfg.MutexProfileRate)
+	setInt(verbosityFlag, &cfg.Verbosity)
+	return cfg, nil
+}
+
+// check verifies that the configuration is complete.
+func (cfg *nodeConfig) check() error {
+	switch {
+	case cfg.L1 == "":
+		return errors.New("missing L1 endpoint")
+	case cfg.Engine == "":
+		return errors.New("missing engine endpoint")
+	case cfg.Rollup == "":
+		return errors.New("missing rollup configuration file")
+	case cfg.P2P.ListenAddr != "" && cfg.P2P.SequencerAddress == (common.Address{}) && cfg.P2P.SequencerKey == "":
+		return errors.New("payload gossip requires the sequencer address")
+	}
+	return nil
+}
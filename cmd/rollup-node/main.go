@@ -0,0 +1,654 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// rollup-node derives the L2 chain from L1 and drives the L2 execution engine
+// through the engine API. It optionally sequences new L2 blocks.
+//
+// Every flag can also be set through the environment variable listed in its
+// usage, or in a TOML config file. Flags take precedence over the environment,
+// which takes precedence over the config file.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/exp"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/nat"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/driver"
+	"github.com/ethereum/go-ethereum/rollup/engine"
+	"github.com/ethereum/go-ethereum/rollup/gossip"
+	"github.com/ethereum/go-ethereum/rollup/node"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	configFileFlag = &cli.StringFlag{
+		Name:    "config",
+		Usage:   "TOML configuration file",
+		EnvVars: []string{"ROLLUP_NODE_CONFIG"},
+	}
+	l1RPCFlag = &cli.StringFlag{
+		Name:    "l1",
+		Usage:   "HTTP or WebSocket endpoint of the L1 node",
+		EnvVars: []string{"ROLLUP_NODE_L1"},
+	}
+	engineRPCFlag = &cli.StringFlag{
+		Name:    "engine",
+		Usage:   "HTTP or WebSocket endpoint of the engine API of the L2 execution engine",
+		EnvVars: []string{"ROLLUP_NODE_ENGINE"},
+	}
+	engineJWTSecretFlag = &cli.StringFlag{
+		Name:    "engine.jwt-secret",
+		Usage:   "file containing the hex encoded JWT secret that authenticates the node to the engine API",
+		EnvVars: []string{"ROLLUP_NODE_ENGINE_JWT_SECRET"},
+	}
+	engineTimeoutFlag = &cli.DurationFlag{
+		Name:    "engine.timeout",
+		Usage:   "time the engine has to answer an engine API call before it is retried",
+		Value:   defaultConfig.EngineTimeout,
+		EnvVars: []string{"ROLLUP_NODE_ENGINE_TIMEOUT"},
+	}
+	rollupConfigFlag = &cli.StringFlag{
+		Name:    "rollup.config",
+		Usage:   "JSON file containing the rollup configuration",
+		EnvVars: []string{"ROLLUP_NODE_ROLLUP_CONFIG"},
+	}
+	l2GenesisFlag = &cli.StringFlag{
+		Name:    "l2.genesis",
+		Usage:   "genesis file of the L2 chain, to check the upgrade times of the rollup configuration against",
+		EnvVars: []string{"ROLLUP_NODE_L2_GENESIS"},
+	}
+	sequencerFlag = &cli.BoolFlag{
+		Name:    "sequencer",
+		Usage:   "sequence new L2 blocks from the start",
+		EnvVars: []string{"ROLLUP_NODE_SEQUENCER"},
+	}
+	sequencerInterruptFlag = &cli.DurationFlag{
+		Name:    "sequencer.interrupt-window",
+		Usage:   "time a sequenced block stays open, to be restarted on a new L1 origin with deposits (0 = seal right away)",
+		EnvVars: []string{"ROLLUP_NODE_SEQUENCER_INTERRUPT_WINDOW"},
+	}
+	elSyncFlag = &cli.BoolFlag{
+		Name:    "el-sync",
+		Usage:   "let the engine sync the chain from its peers before deriving, on a new node",
+		EnvVars: []string{"ROLLUP_NODE_EL_SYNC"},
+	}
+	headsFlag = &cli.StringFlag{
+		Name:    "heads",
+		Usage:   "file that persists the L2 heads across restarts",
+		Value:   defaultConfig.HeadsFile,
+		EnvVars: []string{"ROLLUP_NODE_HEADS"},
+	}
+	safeDepthFlag = &cli.Uint64Flag{
+		Name:    "safe-depth",
+		Usage:   "number of L1 confirmations of a batch before its L2 blocks are safe",
+		EnvVars: []string{"ROLLUP_NODE_SAFE_DEPTH"},
+	}
+	finalityDepthFlag = &cli.Uint64Flag{
+		Name:    "finality-depth",
+		Usage:   "number of L1 confirmations of a batch before its L2 blocks are finalized, 0 to follow L1 finality",
+		EnvVars: []string{"ROLLUP_NODE_FINALITY_DEPTH"},
+	}
+	rpcAddrFlag = &cli.StringFlag{
+		Name:    "rpc.addr",
+		Usage:   "listening address of the HTTP and WebSocket RPC server of the node",
+		Value:   defaultConfig.RPCAddr,
+		EnvVars: []string{"ROLLUP_NODE_RPC_ADDR"},
+	}
+	p2pListenAddrFlag = &cli.StringFlag{
+		Name:    "p2p.addr",
+		Usage:   "listening address of the payload gossip, empty to disable the gossip",
+		Value:   defaultConfig.P2P.ListenAddr,
+		EnvVars: []string{"ROLLUP_NODE_P2P_ADDR"},
+	}
+	p2pBootnodesFlag = &cli.StringSliceFlag{
+		Name:    "p2p.bootnodes",
+		Usage:   "comma separated enode or ENR URLs of the discovery bootstrap nodes",
+		EnvVars: []string{"ROLLUP_NODE_P2P_BOOTNODES"},
+	}
+	p2pStaticFlag = &cli.StringSliceFlag{
+		Name:    "p2p.static",
+		Usage:   "comma separated enode URLs of gossip peers that are always kept connected",
+		EnvVars: []string{"ROLLUP_NODE_P2P_STATIC"},
+	}
+	p2pNoDiscoveryFlag = &cli.BoolFlag{
+		Name:    "p2p.nodiscover",
+		Usage:   "disable the discv5 discovery of gossip peers, only the static peers are connected",
+		EnvVars: []string{"ROLLUP_NODE_P2P_NODISCOVER"},
+	}
+	p2pNATFlag = &cli.StringFlag{
+		Name:    "p2p.nat",
+		Usage:   "NAT port mapping mechanism (any|none|upnp|pmp|pmp:<IP>|extip:<IP>)",
+		EnvVars: []string{"ROLLUP_NODE_P2P_NAT"},
+	}
+	p2pNodeKeyFlag = &cli.StringFlag{
+		Name:    "p2p.nodekey",
+		Usage:   "file containing the hex encoded p2p node key, an ephemeral key is used if empty",
+		EnvVars: []string{"ROLLUP_NODE_P2P_NODEKEY"},
+	}
+	p2pMaxPeersFlag = &cli.IntFlag{
+		Name:    "p2p.maxpeers",
+		Usage:   "maximum number of gossip peers",
+		Value:   defaultConfig.P2P.MaxPeers,
+		EnvVars: []string{"ROLLUP_NODE_P2P_MAXPEERS"},
+	}
+	p2pSequencerAddrFlag = &cli.StringFlag{
+		Name:    "p2p.sequencer.address",
+		Usage:   "address of the sequencer whose signed payloads are accepted from the gossip, unless the system config authorizes other signers",
+		EnvVars: []string{"ROLLUP_NODE_P2P_SEQUENCER_ADDRESS"},
+	}
+	p2pSequencerKeyFlag = &cli.StringFlag{
+		Name:    "p2p.sequencer.key",
+		Usage:   "file containing the hex encoded key that signs the published payloads of the sequencer",
+		EnvVars: []string{"ROLLUP_NODE_P2P_SEQUENCER_KEY"},
+	}
+	backfillRPCFlag = &cli.StringFlag{
+		Name:    "backfill.rpc",
+		Usage:   "HTTP or WebSocket endpoint of a trusted L2 node, such as the sequencer, that missing unsafe payloads are requested from",
+		EnvVars: []string{"ROLLUP_NODE_BACKFILL_RPC"},
+	}
+	derivationTraceFlag = &cli.IntFlag{
+		Name:    "derivation.trace",
+		Usage:   "number of recent derivation steps to record for optimism_derivationTrace, 0 to disable",
+		EnvVars: []string{"ROLLUP_NODE_DERIVATION_TRACE"},
+	}
+	depositIndexFlag = &cli.StringFlag{
+		Name:    "deposit-index",
+		Usage:   "directory of the database indexing the derived deposits for optimism_depositByL1Hash, disabled if empty",
+		EnvVars: []string{"ROLLUP_NODE_DEPOSIT_INDEX"},
+	}
+	metricsFlag = &cli.BoolFlag{
+		Name:    "metrics",
+		Usage:   "enable metrics collection and reporting",
+		EnvVars: []string{"ROLLUP_NODE_METRICS"},
+	}
+	metricsAddrFlag = &cli.StringFlag{
+		Name:    "metrics.addr",
+		Usage:   "listening address of the metrics HTTP server, serving Prometheus metrics at /debug/metrics/prometheus",
+		Value:   defaultConfig.MetricsAddr,
+		EnvVars: []string{"ROLLUP_NODE_METRICS_ADDR"},
+	}
+	pprofFlag = &cli.BoolFlag{
+		Name:    "pprof",
+		Usage:   "enable the pprof HTTP server, serving the runtime profiles at /debug/pprof",
+		EnvVars: []string{"ROLLUP_NODE_PPROF"},
+	}
+	pprofAddrFlag = &cli.StringFlag{
+		Name:    "pprof.addr",
+		Usage:   "listening address of the pprof HTTP server",
+		Value:   defaultConfig.PprofAddr,
+		EnvVars: []string{"ROLLUP_NODE_PPROF_ADDR"},
+	}
+	blockProfileRateFlag = &cli.IntFlag{
+		Name:    "pprof.blockprofilerate",
+		Usage:   "turn on block profiling with the given rate (see runtime.SetBlockProfileRate)",
+		EnvVars: []string{"ROLLUP_NODE_PPROF_BLOCK_PROFILE_RATE"},
+	}
+	mutexProfileRateFlag = &cli.IntFlag{
+		Name:    "pprof.mutexprofilerate",
+		Usage:   "turn on mutex profiling with the given rate (see runtime.SetMutexProfileFraction)",
+		EnvVars: []string{"ROLLUP_NODE_PPROF_MUTEX_PROFILE_RATE"},
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:    "verbosity",
+		Usage:   "log verbosity (0-5)",
+		Value:   defaultConfig.Verbosity,
+		EnvVars: []string{"ROLLUP_NODE_VERBOSITY"},
+	}
+)
+
+var nodeFlags = []cli.Flag{
+	configFileFlag,
+	l1RPCFlag,
+	engineRPCFlag,
+	engineJWTSecretFlag,
+	engineTimeoutFlag,
+	rollupConfigFlag,
+	l2GenesisFlag,
+	sequencerFlag,
+	sequencerInterruptFlag,
+	elSyncFlag,
+	headsFlag,
+	safeDepthFlag,
+	finalityDepthFlag,
+	rpcAddrFlag,
+	p2pListenAddrFlag,
+	p2pBootnodesFlag,
+	p2pStaticFlag,
+	p2pNoDiscoveryFlag,
+	p2pNATFlag,
+	p2pNodeKeyFlag,
+	p2pMaxPeersFlag,
+	p2pSequencerAddrFlag,
+	p2pSequencerKeyFlag,
+	backfillRPCFlag,
+	derivationTraceFlag,
+	depositIndexFlag,
+	metricsFlag,
+	metricsAddrFlag,
+	pprofFlag,
+	pprofAddrFlag,
+	blockProfileRateFlag,
+	mutexProfileRateFlag,
+	verbosityFlag,
+}
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 rollup node")
+	app.Flags = nodeFlags
+	app.Commands = []*cli.Command{
+		{
+			Action:      dumpConfig,
+			Name:        "dumpconfig",
+			Usage:       "Show configuration values",
+			ArgsUsage:   "",
+			Description: `The dumpconfig command shows the configuration assembled from the config file, the environment and the global flags.`,
+		},
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// dumpConfig is the dumpconfig command.
+func dumpConfig(ctx *cli.Context) error {
+	cfg, err := makeConfig(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := tomlSettings.Marshal(&cfg)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+func run(ctx *cli.Context) error {
+	cfg, err := makeConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := cfg.check(); err != nil {
+		return err
+	}
+	// The log levels of the node components can be changed through the admin
+	// API while the node runs.
+	logHandler := rollup.NewComponentLogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)), log.Lvl(cfg.Verbosity))
+	log.Root().SetHandler(logHandler)
+
+	// Metrics collection is switched on by the metrics flag before any code
+	// runs, see the metrics package. When enabled through the environment or
+	// the config file, only the process metrics are collected.
+	if cfg.Metrics {
+		if !metrics.Enabled {
+			log.Warn("Metrics not enabled on the command line, node metrics are not collected")
+			metrics.Enabled = true
+		}
+		exp.Setup(cfg.MetricsAddr)
+		go metrics.CollectProcessMetrics(3 * time.Second)
+	}
+	if cfg.Pprof {
+		runtime.SetBlockProfileRate(cfg.BlockProfileRate)
+		runtime.SetMutexProfileFraction(cfg.MutexProfileRate)
+		debug.StartPProf(cfg.PprofAddr, false)
+	}
+
+	rollupCfg, err := rollup.LoadConfig(cfg.Rollup)
+	if err != nil {
+		return fmt.Errorf("failed to load rollup configuration: %v", err)
+	}
+	// The engine applies the L2 upgrades of its own chain config, which must
+	// agree with the rollup configuration.
+	if cfg.L2Genesis != "" {
+		if err := checkL2Genesis(rollupCfg, cfg.L2Genesis); err != nil {
+			return err
+		}
+	} else {
+		log.Warn("No L2 genesis file, the rollup configuration is not checked against the chain config of the engine")
+	}
+	l1, err := ethclient.Dial(cfg.L1)
+	if err != nil {
+		return fmt.Errorf("failed to connect to L1: %v", err)
+	}
+	defer l1.Close()
+	eng, err := dialEngine(cfg.Engine, cfg.EngineJWTSecret)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the engine: %v", err)
+	}
+	defer eng.Close()
+
+	// Missing unsafe payloads are requested from the trusted node first, and
+	// from the gossip peers otherwise.
+	var backfill []driver.PayloadSource
+	if cfg.BackfillRPC != "" {
+		l2, err := ethclient.Dial(cfg.BackfillRPC)
+		if err != nil {
+			return fmt.Errorf("failed to connect to the backfill node: %v", err)
+		}
+		defer l2.Close()
+		backfill = append(backfill, driver.NewRPCPayloadSource(l2))
+	}
+	var g *gossipNode
+	if cfg.P2P.ListenAddr != "" {
+		if g, err = startGossip(cfg.P2P, rollupCfg); err != nil {
+			return err
+		}
+		defer g.stop()
+		backfill = append(backfill, g.gossip)
+	}
+
+	// The deposit index is closed after the driver stops.
+	var depositIndex ethdb.KeyValueStore
+	if cfg.DepositIndex != "" {
+		db, err := leveldb.New(cfg.DepositIndex, 16, 16, "rollup/depositindex/", false)
+		if err != nil {
+			return fmt.Errorf("failed to open deposit index: %v", err)
+		}
+		defer db.Close()
+		depositIndex = db
+	}
+
+	d, err := driver.NewDriver(rollupCfg, driver.Config{
+		Confirmations: derive.Confirmations{
+			SafeDepth:     cfg.SafeDepth,
+			FinalityDepth: cfg.FinalityDepth,
+		},
+		Sequencing:      cfg.Sequencer,
+		ELSync:          cfg.ELSync,
+		HeadsFile:       cfg.HeadsFile,
+		Backfill:        backfill,
+		EngineTimeout:   cfg.EngineTimeout,
+		TraceSize:       cfg.DerivationTrace,
+		DepositIndex:    depositIndex,
+		InterruptWindow: cfg.SequencerInterrupt,
+	}, derive.NewL1Source(l1), eng, log.Root())
+	if err != nil {
+		return err
+	}
+	d.Start()
+	defer d.Stop()
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go followL1(runCtx, l1, d)
+
+	if g != nil {
+		// The signers of the system config replace the sequencer address
+		// once they are read from L1.
+		g.gossip.SetSigners(d)
+		go forwardPayloads(runCtx, g.gossip, d)
+		if cfg.P2P.SequencerKey != "" {
+			go publishPayloads(runCtx, g.gossip, d)
+		}
+	}
+
+	apis := append(node.APIs(rollupCfg, d, eng), rpc.API{Namespace: "admin", Service: node.NewLogAPI(logHandler)})
+	if g != nil {
+		apis = append(apis, g.gossip.APIs()...)
+	}
+	srv, err := startRPC(cfg.RPCAddr, apis)
+	if err != nil {
+		return err
+	}
+	defer srv.Close()
+	log.Info("Rollup node started", "l2", rollupCfg.L2ChainID, "sequencer", cfg.Sequencer, "rpc", cfg.RPCAddr)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down rollup node")
+	return nil
+}
+
+// checkL2Genesis checks the rollup configuration against the chain config in
+// the given L2 genesis file.
+func checkL2Genesis(rollupCfg *rollup.Config, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read L2 genesis: %v", err)
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return fmt.Errorf("invalid L2 genesis %s: %v", file, err)
+	}
+	if genesis.Config == nil {
+		return fmt.Errorf("L2 genesis %s has no chain config", file)
+	}
+	if err := rollupCfg.CheckChainConfig(genesis.Config); err != nil {
+		return fmt.Errorf("rollup configuration does not match the L2 genesis %s: %v", file, err)
+	}
+	return nil
+}
+
+// dialEngine connects to the engine API, which is authenticated if a JWT secret
+// file is given.
+func dialEngine(url, jwtSecret string) (*engine.Client, error) {
+	if jwtSecret == "" {
+		log.Warn("Connecting to the engine API without authentication")
+		return engine.Dial(context.Background(), url)
+	}
+	secret, err := engine.LoadJWTSecret(jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return engine.DialWithJWT(context.Background(), url, secret)
+}
+
+// followL1 reports new L1 heads to the driver. Without notification support on
+// the L1 endpoint, the driver relies on polling.
+func followL1(ctx context.Context, l1 *ethclient.Client, d *driver.Driver) {
+	heads := make(chan *types.Header, 10)
+	sub, err := l1.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		log.Info("Not subscribed to L1 heads, polling instead", "err", err)
+		return
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case head := <-heads:
+			if err := d.OnL1Head(ctx, rollup.L1BlockRefFromHeader(head)); err != nil {
+				return
+			}
+		case err := <-sub.Err():
+			log.Warn("L1 head subscription failed, polling instead", "err", err)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// forwardPayloads passes the payloads received from the gossip to the driver.
+func forwardPayloads(ctx context.Context, g *gossip.Gossip, d *driver.Driver) {
+	payloads := make(chan *beacon.ExecutableDataV1, 10)
+	sub := g.SubscribePayloads(payloads)
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case payload := <-payloads:
+			if err := d.OnUnsafePayload(ctx, payload); err != nil {
+				return
+			}
+		case <-sub.Err():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publishPayloads gossips the payloads built by the sequencer of the node.
+func publishPayloads(ctx context.Context, g *gossip.Gossip, d *driver.Driver) {
+	payloads := make(chan *beacon.ExecutableDataV1, 10)
+	sub := d.SubscribeSequencedPayloads(payloads)
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case payload := <-payloads:
+			if err := g.Publish(payload); err != nil {
+				log.Warn("Failed to publish sequenced payload", "hash", payload.BlockHash, "number", payload.Number, "err", err)
+			}
+		case <-sub.Err():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// gossipNode is the payload gossip, run by its own p2p server.
+type gossipNode struct {
+	gossip *gossip.Gossip
+	server *p2p.Server
+}
+
+func startGossip(cfg p2pConfig, rollupCfg *rollup.Config) (*gossipNode, error) {
+	gcfg := gossip.Config{
+		ChainID:          rollupCfg.L2ChainID,
+		SequencerAddress: cfg.SequencerAddress,
+	}
+	if cfg.SequencerKey != "" {
+		key, err := crypto.LoadECDSA(cfg.SequencerKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load sequencer key: %v", err)
+		}
+		gcfg.SequencerKey = key
+		if gcfg.SequencerAddress == (common.Address{}) {
+			gcfg.SequencerAddress = crypto.PubkeyToAddress(key.PublicKey)
+		}
+	}
+	nodeKey, err := crypto.GenerateKey()
+	if cfg.NodeKey != "" {
+		nodeKey, err = crypto.LoadECDSA(cfg.NodeKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load node key: %v", err)
+	}
+	bootnodes, err := parseNodes(cfg.Bootnodes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootnode: %v", err)
+	}
+	static, err := parseNodes(cfg.StaticNodes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid static peer: %v", err)
+	}
+	natm, err := nat.Parse(cfg.NAT)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NAT option %q: %v", cfg.NAT, err)
+	}
+	logger := rollup.ComponentLogger(log.Root(), rollup.LogP2P)
+	g := gossip.New(gcfg, logger)
+	// Peers are discovered with discv5 only, the nodes of other chains are
+	// filtered out by the chain ID in their node record. Static peers are
+	// also trusted, so they are connected above the peer limit.
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey:       nodeKey,
+		MaxPeers:         cfg.MaxPeers,
+		Name:             "rollup-node",
+		ListenAddr:       cfg.ListenAddr,
+		NoDiscovery:      true,
+		DiscoveryV5:      !cfg.NoDiscovery,
+		BootstrapNodesV5: bootnodes,
+		StaticNodes:      static,
+		TrustedNodes:     static,
+		NAT:              natm,
+		Protocols:        g.Protocols(),
+		Logger:           logger,
+	}}
+	if err := server.Start(); err != nil {
+		g.Close()
+		return nil, fmt.Errorf("failed to start payload gossip: %v", err)
+	}
+	if server.DiscV5 != nil {
+		g.SetDiscovery(server.DiscV5.RandomNodes())
+	}
+	log.Info("Payload gossip started", "enode", server.Self().URLv4(), "sequencer", gcfg.SequencerAddress)
+	return &gossipNode{gossip: g, server: server}, nil
+}
+
+// parseNodes parses enode or ENR URLs.
+func parseNodes(urls []string) ([]*enode.Node, error) {
+	var nodes []*enode.Node
+	for _, url := range urls {
+		n, err := enode.Parse(enode.ValidSchemes, url)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", url, err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func (n *gossipNode) stop() {
+	n.server.Stop()
+	n.gossip.Close()
+}
+
+// startRPC serves the given APIs over HTTP and WebSocket.
+func startRPC(addr string, apis []rpc.API) (*http.Server, error) {
+	handler := rpc.NewServer()
+	for _, api := range apis {
+		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start RPC server: %v", err)
+	}
+	srv := &http.Server{Handler: node.NewHandler(handler)}
+	go srv.Serve(listener)
+	return srv, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// tx-proxy accepts eth_sendRawTransaction calls of users and replicas, rejects
+// deposits and oversized transactions, limits the transaction rate and forwards
+// the rest to the current sequencer.
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/metrics/exp"
+	"github.com/ethereum/go-ethereum/rollup/txproxy"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/urfave/cli/v2"
+)
+
+// Git SHA1 commit hash of the release (set via linker flags)
+var gitCommit = ""
+var gitDate = ""
+
+var app *cli.App
+
+var (
+	sequencerFlag = &cli.StringSliceFlag{
+		Name:     "sequencer",
+		Usage:    "HTTP endpoint of the sequencer, repeated for standby sequencers that are failed over to in order",
+		Required: true,
+	}
+	chainIDFlag = &cli.Uint64Flag{
+		Name:     "l2-chain-id",
+		Usage:    "chain ID that transactions must be signed for",
+		Required: true,
+	}
+	rpcAddrFlag = &cli.StringFlag{
+		Name:  "rpc.addr",
+		Usage: "listening address of the HTTP-RPC server of the proxy",
+		Value: "127.0.0.1:8545",
+	}
+	maxTxSizeFlag = &cli.Uint64Flag{
+		Name:  "max-tx-size",
+		Usage: "largest accepted transaction, in bytes",
+		Value: txproxy.DefaultConfig.MaxTxSize,
+	}
+	rateFlag = &cli.Float64Flag{
+		Name:  "rate",
+		Usage: "transactions per second forwarded in total, 0 for no limit",
+		Value: txproxy.DefaultConfig.Rate,
+	}
+	burstFlag = &cli.IntFlag{
+		Name:  "burst",
+		Usage: "transactions forwarded in total in a burst",
+		Value: txproxy.DefaultConfig.Burst,
+	}
+	senderRateFlag = &cli.Float64Flag{
+		Name:  "sender-rate",
+		Usage: "transactions per second forwarded per sender, 0 for no limit",
+		Value: txproxy.DefaultConfig.SenderRate,
+	}
+	senderBurstFlag = &cli.IntFlag{
+		Name:  "sender-burst",
+		Usage: "transactions forwarded per sender in a burst",
+		Value: txproxy.DefaultConfig.SenderBurst,
+	}
+	timeoutFlag = &cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "time the sequencer has to accept a transaction",
+		Value: txproxy.DefaultConfig.Timeout,
+	}
+	metricsFlag = &cli.BoolFlag{
+		Name:  "metrics",
+		Usage: "enable metrics collection and reporting",
+	}
+	metricsAddrFlag = &cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "listening address of the metrics HTTP server, serving Prometheus metrics at /debug/metrics/prometheus",
+		Value: "127.0.0.1:6060",
+	}
+	verbosityFlag = &cli.IntFlag{
+		Name:  "verbosity",
+		Usage: "log verbosity (0-5)",
+		Value: int(log.LvlInfo),
+	}
+)
+
+func init() {
+	app = flags.NewApp(gitCommit, gitDate, "L2 transaction ingress proxy")
+	app.Flags = []cli.Flag{
+		sequencerFlag,
+		chainIDFlag,
+		rpcAddrFlag,
+		maxTxSizeFlag,
+		rateFlag,
+		burstFlag,
+		senderRateFlag,
+		senderBurstFlag,
+		timeoutFlag,
+		metricsFlag,
+		metricsAddrFlag,
+		verbosityFlag,
+	}
+	app.Action = run
+}
+
+func main() {
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.Int(verbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	// Metrics collection is switched on by the metrics flag before any code
+	// runs, see the metrics package.
+	if metrics.Enabled {
+		exp.Setup(ctx.String(metricsAddrFlag.Name))
+		go metrics.CollectProcessMetrics(3 * time.Second)
+	}
+
+	var sequencers []*rpc.Client
+	for _, url := range ctx.StringSlice(sequencerFlag.Name) {
+		client, err := rpc.DialContext(context.Background(), url)
+		if err != nil {
+			return fmt.Errorf("failed to connect to sequencer %s: %v", url, err)
+		}
+		defer client.Close()
+		sequencers = append(sequencers, client)
+	}
+	cfg := txproxy.Config{
+		L2ChainID:   new(big.Int).SetUint64(ctx.Uint64(chainIDFlag.Name)),
+		MaxTxSize:   ctx.Uint64(maxTxSizeFlag.Name),
+		Rate:        ctx.Float64(rateFlag.Name),
+		Burst:       ctx.Int(burstFlag.Name),
+		SenderRate:  ctx.Float64(senderRateFlag.Name),
+		SenderBurst: ctx.Int(senderBurstFlag.Name),
+		Timeout:     ctx.Duration(timeoutFlag.Name),
+	}
+	proxy, err := txproxy.New(cfg, sequencers, log.Root())
+	if err != nil {
+		return err
+	}
+
+	handler := rpc.NewServer()
+	for _, api := range proxy.APIs() {
+		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+			return err
+		}
+	}
+	listener, err := net.Listen("tcp", ctx.String(rpcAddrFlag.Name))
+	if err != nil {
+		return fmt.Errorf("failed to start RPC server: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(listener)
+	log.Info("Transaction proxy started", "addr", listener.Addr(), "sequencers", len(sequencers), "chainid", cfg.L2ChainID)
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Shutting down transaction proxy")
+	srv.Close()
+	handler.Stop()
+	return nil
+}
@@ -881,6 +881,22 @@ var (
 		Usage:    "HTTP endpoint for the sequencer mempool",
 		Category: flags.RollupCategory,
 	}
+	RollupHistoricalRPCFlag = &cli.StringFlag{
+		Name:     "rollup.historicalrpc",
+		Usage:    "RPC endpoint of the legacy node serving the history and state before the migration block",
+		Category: flags.RollupCategory,
+	}
+	RollupHistoricalRPCTimeoutFlag = &cli.DurationFlag{
+		Name:     "rollup.historicalrpctimeout",
+		Usage:    "Timeout of the requests forwarded to the historical RPC endpoint",
+		Value:    ethconfig.Defaults.RollupHistoricalRPCTimeout,
+		Category: flags.RollupCategory,
+	}
+	RollupSupplyCheckFlag = &cli.BoolFlag{
+		Name:     "rollup.supplycheck",
+		Usage:    "Verify that every block grows the ETH supply by at most the ETH minted by its deposits (debug, slow)",
+		Category: flags.RollupCategory,
+	}
 
 	// Metrics flags
 	MetricsEnabledFlag = &cli.BoolFlag{
@@ -1860,6 +1876,11 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RollupSequencerHTTPFlag.Name) && !ctx.IsSet(MiningEnabledFlag.Name) {
 		cfg.RollupSequencerHTTP = ctx.String(RollupSequencerHTTPFlag.Name)
 	}
+	if ctx.IsSet(RollupHistoricalRPCFlag.Name) {
+		cfg.RollupHistoricalRPC = ctx.String(RollupHistoricalRPCFlag.Name)
+	}
+	cfg.RollupHistoricalRPCTimeout = ctx.Duration(RollupHistoricalRPCTimeoutFlag.Name)
+	cfg.RollupSupplyCheck = ctx.Bool(RollupSupplyCheckFlag.Name)
 	// Override any default configs for hard coded networks.
 	switch {
 	case ctx.Bool(MainnetFlag.Name):
@@ -35,7 +35,13 @@ func VerifyEip1559Header(config *params.ChainConfig, parent, header *types.Heade
 	if !config.IsLondon(parent.Number) {
 		parentGasLimit = parent.GasLimit * params.ElasticityMultiplier
 	}
-	if err := VerifyGaslimit(parentGasLimit, header.GasLimit); err != nil {
+	if config.Optimism != nil {
+		// The gas limit of rollup blocks is set by the system config on L1,
+		// it may change by any amount between two blocks.
+		if header.GasLimit < params.MinGasLimit {
+			return fmt.Errorf("invalid gas limit below %d", params.MinGasLimit)
+		}
+	} else if err := VerifyGaslimit(parentGasLimit, header.GasLimit); err != nil {
 		return err
 	}
 	// Verify the header is not malformed
@@ -20,6 +20,7 @@ func (p PayloadAttributesV1) MarshalJSON() ([]byte, error) {
 		SuggestedFeeRecipient common.Address  `json:"suggestedFeeRecipient"  gencodec:"required"`
 		Transactions          []hexutil.Bytes `json:"transactions,omitempty"  gencodec:"optional"`
 		NoTxPool              bool            `json:"noTxPool,omitempty" gencodec:"optional"`
+		GasLimit              *hexutil.Uint64 `json:"gasLimit,omitempty" gencodec:"optional"`
 	}
 	var enc PayloadAttributesV1
 	enc.Timestamp = hexutil.Uint64(p.Timestamp)
@@ -32,6 +33,7 @@ func (p PayloadAttributesV1) MarshalJSON() ([]byte, error) {
 		}
 	}
 	enc.NoTxPool = p.NoTxPool
+	enc.GasLimit = (*hexutil.Uint64)(p.GasLimit)
 	return json.Marshal(&enc)
 }
 
@@ -43,6 +45,7 @@ func (p *PayloadAttributesV1) UnmarshalJSON(input []byte) error {
 		SuggestedFeeRecipient *common.Address `json:"suggestedFeeRecipient"  gencodec:"required"`
 		Transactions          []hexutil.Bytes `json:"transactions,omitempty"  gencodec:"optional"`
 		NoTxPool              *bool           `json:"noTxPool,omitempty" gencodec:"optional"`
+		GasLimit              *hexutil.Uint64 `json:"gasLimit,omitempty" gencodec:"optional"`
 	}
 	var dec PayloadAttributesV1
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -69,5 +72,8 @@ func (p *PayloadAttributesV1) UnmarshalJSON(input []byte) error {
 	if dec.NoTxPool != nil {
 		p.NoTxPool = *dec.NoTxPool
 	}
+	if dec.GasLimit != nil {
+		p.GasLimit = (*uint64)(dec.GasLimit)
+	}
 	return nil
 }
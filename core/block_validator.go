@@ -65,12 +65,113 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
+	if err := validateDeposits(v.config, block.Transactions()); err != nil {
+		return err
+	}
 	if !v.bc.HasBlockAndState(block.ParentHash(), block.NumberU64()-1) {
 		if !v.bc.HasBlock(block.ParentHash(), block.NumberU64()-1) {
 			return consensus.ErrUnknownAncestor
 		}
 		return consensus.ErrPrunedAncestor
 	}
+	if v.config.IsL1InfoChecked(block.Time()) {
+		parent := v.bc.GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return consensus.ErrUnknownAncestor
+		}
+		// The genesis block of a rollup chain has no L1 info deposit, and blocks
+		// before the check may have none either.
+		if parent.Hash() == v.bc.genesisBlock.Hash() || !v.config.IsL1InfoChecked(parent.Time()) {
+			parent = nil
+		}
+		if err := validateL1Info(v.config, block, parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateL1Info checks that the first transaction of a rollup block is a well
+// formed L1 info deposit, and that the L1 origin it sets follows the origin of
+// the parent: the block either continues the epoch of the parent with the next
+// sequence number, or starts the next epoch with sequence number 0. The parent
+// is nil if it has no L1 origin to follow.
+func validateL1Info(config *params.ChainConfig, block, parent *types.Block) error {
+	info, err := l1InfoOf(config, block)
+	if err != nil {
+		return err
+	}
+	if info.Time > block.Time() {
+		return fmt.Errorf("%w: L1 origin time %d after block time %d", ErrInvalidL1Info, info.Time, block.Time())
+	}
+	if parent == nil {
+		return nil
+	}
+	prev, err := l1InfoOf(config, parent)
+	if err != nil {
+		return fmt.Errorf("parent %d: %w", parent.NumberU64(), err)
+	}
+	switch info.Number {
+	case prev.Number:
+		if info.BlockHash != prev.BlockHash {
+			return fmt.Errorf("%w: L1 origin %d is %x, parent has %x", ErrInvalidL1Info, info.Number, info.BlockHash, prev.BlockHash)
+		}
+		if info.SequenceNumber != prev.SequenceNumber+1 {
+			return fmt.Errorf("%w: sequence number %d, want %d", ErrInvalidL1Info, info.SequenceNumber, prev.SequenceNumber+1)
+		}
+	case prev.Number + 1:
+		if info.SequenceNumber != 0 {
+			return fmt.Errorf("%w: sequence number %d at the start of epoch %d", ErrInvalidL1Info, info.SequenceNumber, info.Number)
+		}
+		if info.Time < prev.Time {
+			return fmt.Errorf("%w: L1 origin time %d before parent origin time %d", ErrInvalidL1Info, info.Time, prev.Time)
+		}
+	default:
+		return fmt.Errorf("%w: L1 origin %d does not follow parent origin %d", ErrInvalidL1Info, info.Number, prev.Number)
+	}
+	return nil
+}
+
+// l1InfoOf decodes the L1 info deposit at the start of a rollup block. Its
+// source hash may be bound to the L2 chain ID, see types.ChainDepositSourceHash.
+func l1InfoOf(config *params.ChainConfig, block *types.Block) (*types.L1BlockInfo, error) {
+	txs := block.Transactions()
+	if len(txs) == 0 || txs[0].Type() != types.DepositTxType {
+		return nil, fmt.Errorf("%w: block %d", ErrMissingL1Info, block.NumberU64())
+	}
+	tx := txs[0]
+	from, err := types.Sender(types.LatestSigner(config), tx)
+	if err != nil {
+		return nil, err
+	}
+	if from != types.L1InfoDepositerAddress || tx.To() == nil || *tx.To() != types.L1BlockAddr || !tx.IsSystemTx() {
+		return nil, fmt.Errorf("%w: block %d starts with deposit %x", ErrMissingL1Info, block.NumberU64(), tx.SourceHash())
+	}
+	var info types.L1BlockInfo
+	if err := info.UnmarshalBinary(tx.Data()); err != nil {
+		return nil, fmt.Errorf("%w: block %d: %v", ErrInvalidL1Info, block.NumberU64(), err)
+	}
+	source := types.L1InfoDepositSourceHash(info.BlockHash, info.SequenceNumber)
+	if tx.SourceHash() != source && (config.ChainID == nil || tx.SourceHash() != types.ChainDepositSourceHash(config.ChainID, source)) {
+		return nil, fmt.Errorf("%w: block %d has source hash %x for L1 origin %x and sequence number %d", ErrInvalidL1Info, block.NumberU64(), tx.SourceHash(), info.BlockHash, info.SequenceNumber)
+	}
+	return &info, nil
+}
+
+// validateDeposits checks that deposit transactions are only included on rollup
+// chains, and only at the start of a block, ahead of all other transactions.
+func validateDeposits(config *params.ChainConfig, txs types.Transactions) error {
+	for i, tx := range txs {
+		if tx.Type() != types.DepositTxType {
+			continue
+		}
+		if config.Optimism == nil {
+			return fmt.Errorf("%w: deposit transaction %d on a non-rollup chain", ErrTxTypeNotSupported, i)
+		}
+		if i > 0 && txs[i-1].Type() != types.DepositTxType {
+			return fmt.Errorf("%w: transaction %d", ErrDepositAfterTx, i)
+		}
+	}
 	return nil
 }
 
@@ -18,6 +18,7 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"math/big"
 	"runtime"
 	"testing"
@@ -403,3 +404,189 @@ func TestCalcGasLimit(t *testing.T) {
 		}
 	}
 }
+
+// Tests that deposits are only accepted on rollup chains, at the start of a
+// block.
+func TestValidateDeposits(t *testing.T) {
+	var (
+		rollupConfig = *params.TestChainConfig
+		deposit      = types.NewTx(&types.DepositTx{Gas: 21000, Value: new(big.Int)})
+		tx           = types.NewTransaction(0, common.Address{}, new(big.Int), 21000, big.NewInt(1), nil)
+	)
+	rollupConfig.Optimism = &params.OptimismConfig{}
+
+	for i, tt := range []struct {
+		config *params.ChainConfig
+		txs    types.Transactions
+		err    error
+	}{
+		{&rollupConfig, types.Transactions{deposit, deposit, tx, tx}, nil},
+		{&rollupConfig, types.Transactions{tx}, nil},
+		{&rollupConfig, types.Transactions{deposit, tx, deposit}, ErrDepositAfterTx},
+		{&rollupConfig, types.Transactions{tx, deposit}, ErrDepositAfterTx},
+		{params.TestChainConfig, types.Transactions{deposit, tx}, ErrTxTypeNotSupported},
+	} {
+		if err := validateDeposits(tt.config, tt.txs); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that blocks inserted along with their receipts, as during snap sync,
+// are checked for misplaced deposits too.
+func TestInsertReceiptChainDeposits(t *testing.T) {
+	rollupConfig := *params.TestChainConfig
+	rollupConfig.Optimism = &params.OptimismConfig{}
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		genesis = (&Genesis{Config: &rollupConfig, BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+	)
+	blocks, receipts := GenerateChain(&rollupConfig, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.AddTx(types.NewTx(&types.DepositTx{From: common.Address{1}, Gas: 21000, Value: new(big.Int)}))
+	})
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	for i, tt := range []struct {
+		config *params.ChainConfig
+		err    error
+	}{
+		{&rollupConfig, nil},
+		{params.TestChainConfig, ErrTxTypeNotSupported},
+	} {
+		db := rawdb.NewMemoryDatabase()
+		(&Genesis{Config: tt.config, BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+		chain, err := NewBlockChain(db, nil, tt.config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("test %d: failed to create chain: %v", i, err)
+		}
+		if n, err := chain.InsertHeaderChain(headers, 1); err != nil {
+			t.Fatalf("test %d: failed to insert header %d: %v", i, n, err)
+		}
+		if _, err := chain.InsertReceiptChain(blocks, receipts, 0); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+		chain.Stop()
+	}
+}
+
+// Tests that rollup blocks must start with an L1 info deposit whose L1 origin
+// follows the origin of the parent.
+func TestValidateL1Info(t *testing.T) {
+	rollupConfig := *params.TestChainConfig
+	rollupConfig.Optimism = &params.OptimismConfig{}
+
+	var (
+		origin1 = &types.Header{Number: big.NewInt(1), Time: 12, BaseFee: big.NewInt(7)}
+		origin2 = &types.Header{Number: big.NewInt(2), ParentHash: origin1.Hash(), Time: 24, BaseFee: big.NewInt(7)}
+		other1  = &types.Header{Number: big.NewInt(1), Time: 13, BaseFee: big.NewInt(7)}
+		origin3 = &types.Header{Number: big.NewInt(3), ParentHash: origin2.Hash(), Time: 36, BaseFee: big.NewInt(7)}
+	)
+	l1Info := func(origin *types.Header, seqNumber uint64, edit func(*types.DepositTx)) *types.Transaction {
+		dep, err := types.NewL1InfoDeposit(origin, seqNumber)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if edit != nil {
+			edit(dep)
+		}
+		return types.NewTx(dep)
+	}
+	block := func(number, time uint64, txs ...*types.Transaction) *types.Block {
+		return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), Time: time}).WithBody(txs, nil)
+	}
+	var (
+		parent = block(5, 30, l1Info(origin1, 1, nil))
+		tx     = types.NewTransaction(0, common.Address{}, new(big.Int), 21000, big.NewInt(1), nil)
+		user   = types.NewTx(&types.DepositTx{From: common.Address{1}, Gas: 21000, Value: new(big.Int)})
+	)
+	for i, tt := range []struct {
+		block  *types.Block
+		parent *types.Block
+		err    error
+	}{
+		// Valid epoch continuation and start of the next epoch.
+		{block(6, 32, l1Info(origin1, 2, nil), tx), parent, nil},
+		{block(6, 32, l1Info(origin2, 0, nil)), parent, nil},
+		{block(1, 14, l1Info(origin1, 0, nil)), nil, nil},
+		// The source hash may be bound to the chain ID.
+		{block(6, 32, l1Info(origin1, 2, func(dep *types.DepositTx) {
+			dep.SourceHash = types.ChainDepositSourceHash(rollupConfig.ChainID, dep.SourceHash)
+		})), parent, nil},
+
+		// Missing or malformed L1 info deposits.
+		{block(6, 32), parent, ErrMissingL1Info},
+		{block(6, 32, tx), parent, ErrMissingL1Info},
+		{block(6, 32, user), parent, ErrMissingL1Info},
+		{block(6, 32, l1Info(origin1, 2, func(dep *types.DepositTx) { dep.IsSystemTransaction = false })), parent, ErrMissingL1Info},
+		{block(6, 32, l1Info(origin1, 2, func(dep *types.DepositTx) { dep.From = common.Address{1} })), parent, ErrMissingL1Info},
+		{block(6, 32, l1Info(origin1, 2, func(dep *types.DepositTx) { dep.Data = dep.Data[:4] })), parent, ErrInvalidL1Info},
+		{block(6, 32, l1Info(origin1, 2, func(dep *types.DepositTx) { dep.SourceHash = common.Hash{1} })), parent, ErrInvalidL1Info},
+		{block(1, 10, l1Info(origin1, 0, nil)), nil, ErrInvalidL1Info},
+
+		// L1 origins that do not follow the parent.
+		{block(6, 32, l1Info(origin1, 1, nil)), parent, ErrInvalidL1Info},
+		{block(6, 32, l1Info(origin1, 3, nil)), parent, ErrInvalidL1Info},
+		{block(6, 32, l1Info(other1, 2, nil)), parent, ErrInvalidL1Info},
+		{block(6, 32, l1Info(origin2, 2, nil)), parent, ErrInvalidL1Info},
+		{block(6, 40, l1Info(origin3, 0, nil)), parent, ErrInvalidL1Info},
+		{block(6, 40, l1Info(&types.Header{Number: big.NewInt(0)}, 0, nil)), parent, ErrInvalidL1Info},
+		{block(6, 32, l1Info(origin2, 0, nil)), block(5, 30, tx), ErrMissingL1Info},
+	} {
+		if err := validateL1Info(&rollupConfig, tt.block, tt.parent); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that blocks without a valid L1 info deposit are rejected on import once
+// the L1 info check is active, and accepted before.
+func TestInsertChainL1Info(t *testing.T) {
+	var (
+		checkTime    = uint64(20)
+		rollupConfig = *params.TestChainConfig
+		origin       = &types.Header{Number: big.NewInt(1), Time: 5}
+		db           = rawdb.NewMemoryDatabase()
+	)
+	rollupConfig.Optimism = &params.OptimismConfig{L1InfoCheckTime: &checkTime}
+	gspec := &Genesis{Config: &rollupConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+	genesis := gspec.MustCommit(db)
+
+	// The blocks continue epoch 1, except for the first one, which has no L1
+	// info deposit as the check is not active yet.
+	blocks, _ := GenerateChain(&rollupConfig, genesis, ethash.NewFaker(), db, 3, func(i int, b *BlockGen) {
+		if i == 0 {
+			return
+		}
+		tx, err := types.NewL1InfoDepositTx(origin, uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	// The last block repeats the sequence number of its parent.
+	invalid, _ := GenerateChain(&rollupConfig, blocks[1], ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		tx, err := types.NewL1InfoDepositTx(origin, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+
+	db = rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, err := NewBlockChain(db, nil, &rollupConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(types.Blocks{blocks[0], blocks[1], invalid[0]}); n != 2 || !errors.Is(err, ErrInvalidL1Info) {
+		t.Fatalf("block %d: error mismatch: have %v, want %v", n, err, ErrInvalidL1Info)
+	}
+	if n, err := chain.InsertChain(blocks[2:]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+}
@@ -213,6 +213,8 @@ type BlockChain struct {
 	processor  Processor // Block transaction processor interface
 	forker     *ForkChoice
 	vmConfig   vm.Config
+
+	supplyCheck bool // whether the rollup supply invariants are checked
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -963,6 +965,11 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 					blockChain[i-1].Hash().Bytes()[:4], i, blockChain[i].NumberU64(), blockChain[i].Hash().Bytes()[:4], blockChain[i].ParentHash().Bytes()[:4])
 			}
 		}
+		// Bodies inserted along with their receipts skip the body validation of
+		// block imports, check the placement of deposits here instead.
+		if err := validateDeposits(bc.chainConfig, blockChain[i].Transactions()); err != nil {
+			return i, fmt.Errorf("invalid block #%d [%x..]: %w", blockChain[i].NumberU64(), blockChain[i].Hash().Bytes()[:4], err)
+		}
 		if blockChain[i].NumberU64() <= ancientLimit {
 			ancientBlocks, ancientReceipts = append(ancientBlocks, blockChain[i]), append(ancientReceipts, receiptChain[i])
 		} else {
@@ -1691,6 +1698,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
 		}
+		if bc.supplyCheck {
+			if err := bc.checkSupply(block, parent, statedb); err != nil {
+				bc.reportBlock(block, receipts, err)
+				atomic.StoreUint32(&followupInterrupt, 1)
+				return it.index, err
+			}
+		}
 		proctime := time.Since(start)
 
 		// Update the metrics touched during block validation
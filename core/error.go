@@ -78,6 +78,18 @@ var (
 	// current network configuration.
 	ErrTxTypeNotSupported = types.ErrTxTypeNotSupported
 
+	// ErrDepositAfterTx is returned if a block contains a deposit transaction
+	// after a regular transaction. Deposits must come first.
+	ErrDepositAfterTx = errors.New("deposit transaction after regular transaction")
+
+	// ErrMissingL1Info is returned if the first transaction of a rollup block
+	// is not the L1 info deposit.
+	ErrMissingL1Info = errors.New("missing L1 info deposit")
+
+	// ErrInvalidL1Info is returned if the L1 info deposit of a rollup block is
+	// malformed, or its L1 origin does not follow the origin of the parent.
+	ErrInvalidL1Info = errors.New("invalid L1 info deposit")
+
 	// ErrTipAboveFeeCap is a sanity error to ensure no one is able to specify a
 	// transaction with a tip higher than the total fee cap.
 	ErrTipAboveFeeCap = errors.New("max priority fee per gas higher than max fee per gas")
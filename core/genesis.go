@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -313,13 +314,29 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		applyOverrides(newcfg)
 	}
 	// Check config compatibility and write the config. Compatibility errors
-	// are returned to the caller unless we're already at block zero.
-	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
+	// are returned to the caller unless we're already at block zero. A time
+	// based fork moved before the genesis rewinds the chain to block zero.
+	headHash := rawdb.ReadHeadHeaderHash(db)
+	height := rawdb.ReadHeaderNumber(db, headHash)
 	if height == nil {
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
-	compatErr := storedcfg.CheckCompatible(newcfg, *height)
-	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
+	head := rawdb.ReadHeader(db, headHash, *height)
+	if head == nil {
+		return newcfg, stored, fmt.Errorf("missing head header %x", headHash)
+	}
+	compatErr := storedcfg.CheckCompatible(newcfg, *height, head.Time)
+	if compatErr != nil && compatErr.RewindToTime > 0 {
+		// Rewind to the last block before the time based fork.
+		first := sort.Search(int(*height)+1, func(n int) bool {
+			header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, uint64(n)), uint64(n))
+			return header == nil || header.Time > compatErr.RewindToTime
+		})
+		if first > 0 {
+			compatErr.RewindTo = uint64(first - 1)
+		}
+	}
+	if compatErr != nil && ((*height != 0 && compatErr.RewindTo != 0) || (head.Time != 0 && compatErr.RewindToTime != 0)) {
 		return newcfg, stored, compatErr
 	}
 	rawdb.WriteChainConfig(db, stored, newcfg)
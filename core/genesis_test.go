@@ -48,8 +48,16 @@ func TestSetupGenesis(t *testing.T) {
 			},
 		}
 		oldcustomg = customg
+
+		regolithTime, newRegolithTime = uint64(1000), uint64(10)
+		timeg                         = Genesis{
+			Config:    &params.ChainConfig{Optimism: &params.OptimismConfig{RegolithTime: &newRegolithTime}},
+			Timestamp: 100,
+		}
+		oldtimeg = timeg
 	)
 	oldcustomg.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(2)}
+	oldtimeg.Config = &params.ChainConfig{Optimism: &params.OptimismConfig{RegolithTime: &regolithTime}}
 	tests := []struct {
 		name       string
 		fn         func(ethdb.Database) (*params.ChainConfig, common.Hash, error)
@@ -135,6 +143,33 @@ func TestSetupGenesis(t *testing.T) {
 				RewindTo:     1,
 			},
 		},
+		{
+			name: "incompatible time fork before the genesis in DB",
+			fn: func(db ethdb.Database) (*params.ChainConfig, common.Hash, error) {
+				// Commit the 'old' genesis block with Regolith at 1000 and
+				// advance past the genesis, then move Regolith before the
+				// genesis timestamp.
+				genesis := oldtimeg.MustCommit(db)
+
+				bc, _ := NewBlockChain(db, nil, oldtimeg.Config, ethash.NewFullFaker(), vm.Config{}, nil, nil)
+				defer bc.Stop()
+
+				blocks, _ := GenerateChain(oldtimeg.Config, genesis, ethash.NewFaker(), db, 4, nil)
+				if n, err := bc.InsertChain(blocks); err != nil {
+					t.Fatalf("block %d: %v", n, err)
+				}
+				// This should return a compatibility error rewinding to the genesis.
+				return SetupGenesisBlock(db, &timeg)
+			},
+			wantHash:   timeg.ToBlock(nil).Hash(),
+			wantConfig: timeg.Config,
+			wantErr: &params.ConfigCompatError{
+				What:         "Regolith fork timestamp",
+				StoredTime:   &regolithTime,
+				NewTime:      &newRegolithTime,
+				RewindToTime: newRegolithTime - 1,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,60 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// MintedSupply is the ETH minted by the deposits of the blocks from Since up to
+// and including a block.
+type MintedSupply struct {
+	Minted *big.Int
+	Since  uint64 // first block whose deposits are counted
+}
+
+// ReadMintedSupply retrieves the ETH minted by deposits up to the block with
+// the given hash, or nil if it was not tracked.
+func ReadMintedSupply(db ethdb.KeyValueReader, hash common.Hash) *MintedSupply {
+	data, _ := db.Get(mintedSupplyKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	supply := new(MintedSupply)
+	if err := rlp.DecodeBytes(data, supply); err != nil {
+		log.Error("Invalid minted supply RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return supply
+}
+
+// WriteMintedSupply stores the ETH minted by deposits up to the block with the
+// given hash.
+func WriteMintedSupply(db ethdb.KeyValueWriter, hash common.Hash, supply *MintedSupply) {
+	data, err := rlp.EncodeToBytes(supply)
+	if err != nil {
+		log.Crit("Failed to RLP encode minted supply", "err", err)
+	}
+	if err := db.Put(mintedSupplyKey(hash), data); err != nil {
+		log.Crit("Failed to store minted supply", "err", err)
+	}
+}
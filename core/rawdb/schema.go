@@ -104,6 +104,8 @@ var (
 	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
 	genesisPrefix  = []byte("ethereum-genesis-") // genesis state prefix for the db
 
+	mintedSupplyPrefix = []byte("rollup-minted-") // mintedSupplyPrefix + hash -> ETH minted by deposits up to the block
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -251,3 +253,8 @@ func configKey(hash common.Hash) []byte {
 func genesisKey(hash common.Hash) []byte {
 	return append(genesisPrefix, hash.Bytes()...)
 }
+
+// mintedSupplyKey = mintedSupplyPrefix + hash
+func mintedSupplyKey(hash common.Hash) []byte {
+	return append(mintedSupplyPrefix, hash.Bytes()...)
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PredeployStateReader is the part of the state that predeploys are read from.
+type PredeployStateReader interface {
+	GetState(addr common.Address, slot common.Hash) common.Hash
+}
+
+// PredeployStateWriter is the part of the state that predeploys are written to.
+type PredeployStateWriter interface {
+	SetState(addr common.Address, slot common.Hash, value common.Hash)
+}
+
+// GasPriceOracle holds the L1 fee parameters of the GasPriceOracle predeploy,
+// along with the L1 base fee, which the oracle reads from the L1Block
+// predeploy. The L1 data fee of a transaction is
+//
+//	(rollupDataGas + overhead) * l1BaseFee * scalar / 10^decimals
+type GasPriceOracle struct {
+	L1BaseFee *big.Int
+	Overhead  *big.Int
+	Scalar    *big.Int
+	Decimals  *big.Int
+}
+
+// ReadGasPriceOracle reads the L1 fee parameters from the state.
+func ReadGasPriceOracle(db PredeployStateReader) *GasPriceOracle {
+	return &GasPriceOracle{
+		L1BaseFee: db.GetState(L1BlockAddr, L1BaseFeeSlot).Big(),
+		Overhead:  db.GetState(OVM_GasPriceOracleAddr, OverheadSlot).Big(),
+		Scalar:    db.GetState(OVM_GasPriceOracleAddr, ScalarSlot).Big(),
+		Decimals:  db.GetState(OVM_GasPriceOracleAddr, DecimalsSlot).Big(),
+	}
+}
+
+// WriteGasPriceOracle writes the L1 fee parameters to the state. Unset
+// parameters are left unchanged.
+func WriteGasPriceOracle(db PredeployStateWriter, gpo *GasPriceOracle) {
+	if gpo.L1BaseFee != nil {
+		db.SetState(L1BlockAddr, L1BaseFeeSlot, common.BigToHash(gpo.L1BaseFee))
+	}
+	for slot, value := range gpo.OracleStorage() {
+		db.SetState(OVM_GasPriceOracleAddr, slot, value)
+	}
+}
+
+// OracleStorage returns the storage of the GasPriceOracle predeploy that holds
+// the set parameters, for genesis allocations. The L1 base fee is not part of
+// it, since it is stored in the L1Block predeploy.
+func (gpo *GasPriceOracle) OracleStorage() map[common.Hash]common.Hash {
+	storage := make(map[common.Hash]common.Hash)
+	if gpo.Overhead != nil {
+		storage[OverheadSlot] = common.BigToHash(gpo.Overhead)
+	}
+	if gpo.Scalar != nil {
+		storage[ScalarSlot] = common.BigToHash(gpo.Scalar)
+	}
+	if gpo.Decimals != nil {
+		storage[DecimalsSlot] = common.BigToHash(gpo.Decimals)
+	}
+	return storage
+}
+
+// L1Cost returns the L1 data fee of a transaction with the given rollup data
+// gas.
+func (gpo *GasPriceOracle) L1Cost(rollupDataGas uint64) *big.Int {
+	return gpo.l1Cost(rollupDataGas, new(big.Int).Exp(big10, gpo.Decimals, nil))
+}
+
+func (gpo *GasPriceOracle) l1Cost(rollupDataGas uint64, divisor *big.Int) *big.Int {
+	l1GasUsed := new(big.Int).SetUint64(rollupDataGas)
+	l1GasUsed = l1GasUsed.Add(l1GasUsed, gpo.Overhead)
+	l1Cost := l1GasUsed.Mul(l1GasUsed, gpo.L1BaseFee)
+	l1Cost = l1Cost.Mul(l1Cost, gpo.Scalar)
+	return l1Cost.Div(l1Cost, divisor)
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+)
+
+func TestGasPriceOracleState(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	gpo := &GasPriceOracle{
+		L1BaseFee: big.NewInt(1000),
+		Overhead:  big.NewInt(2100),
+		Scalar:    big.NewInt(1_500_000),
+		Decimals:  big.NewInt(6),
+	}
+	WriteGasPriceOracle(statedb, gpo)
+	if have := statedb.GetState(OVM_GasPriceOracleAddr, ScalarSlot); have != common.BigToHash(gpo.Scalar) {
+		t.Fatalf("scalar slot %s, want %v", have, gpo.Scalar)
+	}
+	read := ReadGasPriceOracle(statedb)
+	if read.L1BaseFee.Cmp(gpo.L1BaseFee) != 0 || read.Overhead.Cmp(gpo.Overhead) != 0 || read.Scalar.Cmp(gpo.Scalar) != 0 || read.Decimals.Cmp(gpo.Decimals) != 0 {
+		t.Fatalf("read %+v, want %+v", read, gpo)
+	}
+	// (1000 + 2100) * 1000 * 1.5
+	if cost := read.L1Cost(1000); cost.Cmp(big.NewInt(4_650_000)) != 0 {
+		t.Fatalf("L1 cost %v, want 4650000", cost)
+	}
+
+	// Unset parameters are left unchanged.
+	WriteGasPriceOracle(statedb, &GasPriceOracle{L1BaseFee: big.NewInt(2000)})
+	if read := ReadGasPriceOracle(statedb); read.L1BaseFee.Int64() != 2000 || read.Scalar.Cmp(gpo.Scalar) != 0 {
+		t.Fatalf("partial write changed the parameters to %+v", read)
+	}
+	if storage := (&GasPriceOracle{Scalar: big.NewInt(1)}).OracleStorage(); len(storage) != 1 || storage[ScalarSlot] != common.BigToHash(common.Big1) {
+		t.Fatalf("unexpected oracle storage %v", storage)
+	}
+}
@@ -36,7 +36,7 @@ var (
 
 var (
 	OVM_GasPriceOracleAddr = common.HexToAddress("0x420000000000000000000000000000000000000F")
-	L1BlockAddr            = common.HexToAddress("0x4200000000000000000000000000000000000015")
+	L1BlockAddr            = types.L1BlockAddr
 )
 
 // NewL1CostFunc returns a function used for calculating L1 fee cost.
@@ -44,25 +44,20 @@ var (
 // It returns nil if there is no applicable cost function.
 func NewL1CostFunc(config *params.ChainConfig, statedb vm.StateDB) vm.L1CostFunc {
 	cacheBlockNum := ^uint64(0)
-	var l1BaseFee, overhead, scalar, decimals, divisor *big.Int
+	var (
+		gpo     *GasPriceOracle
+		divisor *big.Int
+	)
 	return func(blockNum uint64, msg vm.RollupMessage) *big.Int {
 		rollupDataGas := msg.RollupDataGas() // Only fake txs for RPC view-calls are 0.
 		if config.Optimism == nil || msg.Nonce() == types.DepositsNonce || rollupDataGas == 0 {
 			return nil
 		}
 		if blockNum != cacheBlockNum {
-			l1BaseFee = statedb.GetState(L1BlockAddr, L1BaseFeeSlot).Big()
-			overhead = statedb.GetState(OVM_GasPriceOracleAddr, OverheadSlot).Big()
-			scalar = statedb.GetState(OVM_GasPriceOracleAddr, ScalarSlot).Big()
-			decimals = statedb.GetState(OVM_GasPriceOracleAddr, DecimalsSlot).Big()
-			divisor = new(big.Int).Exp(big10, decimals, nil)
+			gpo = ReadGasPriceOracle(statedb)
+			divisor = new(big.Int).Exp(big10, gpo.Decimals, nil)
 			cacheBlockNum = blockNum
 		}
-		l1GasUsed := new(big.Int).SetUint64(rollupDataGas)
-		l1GasUsed = l1GasUsed.Add(l1GasUsed, overhead)
-		l1Cost := l1GasUsed.Mul(l1GasUsed, l1BaseFee)
-		l1Cost = l1Cost.Mul(l1Cost, scalar)
-		l1Cost = l1Cost.Div(l1Cost, divisor)
-		return l1Cost
+		return gpo.l1Cost(rollupDataGas, divisor)
 	}
 }
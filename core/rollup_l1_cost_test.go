@@ -0,0 +1,109 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that user transactions pay the L1 data fee to the L1 fee recipient, on
+// top of the L2 execution fee, and that deposits pay no L1 data fee.
+func TestL1CostCharging(t *testing.T) {
+	var (
+		config = *params.AllEthashProtocolChanges
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		db     = rawdb.NewMemoryDatabase()
+		funds  = big.NewInt(params.Ether)
+	)
+	config.Optimism = &params.OptimismConfig{
+		BaseFeeRecipient: common.HexToAddress("0x4200000000000000000000000000000000000019"),
+		L1FeeRecipient:   common.HexToAddress("0x420000000000000000000000000000000000001a"),
+	}
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: GenesisAlloc{
+			addr: {Balance: funds},
+			L1BlockAddr: {Balance: common.Big0, Storage: map[common.Hash]common.Hash{
+				L1BaseFeeSlot: common.BigToHash(big.NewInt(1000)),
+			}},
+			OVM_GasPriceOracleAddr: {Balance: common.Big0, Storage: map[common.Hash]common.Hash{
+				OverheadSlot: common.BigToHash(big.NewInt(2100)),
+				ScalarSlot:   common.BigToHash(big.NewInt(1_500_000)),
+				DecimalsSlot: common.BigToHash(big.NewInt(6)),
+			}},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	var tx *types.Transaction
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x01"),
+			From:       addr,
+			To:         &to,
+			Value:      new(big.Int),
+			Gas:        100_000,
+		}))
+		var err error
+		tx, err = types.SignNewTx(key, types.LatestSigner(&config), &types.DynamicFeeTx{
+			ChainID:   config.ChainID,
+			Nonce:     b.TxNonce(addr),
+			To:        &to,
+			Gas:       50_000,
+			GasFeeCap: b.header.BaseFee,
+			Value:     big.NewInt(1),
+			Data:      []byte{0, 1, 2, 3},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	statedb, err := state.New(blocks[0].Root(), state.NewDatabase(db), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (rollupDataGas + overhead) * l1BaseFee * scalar / 10^decimals
+	l1Cost := new(big.Int).SetUint64(tx.RollupDataGas() + 2100)
+	l1Cost.Mul(l1Cost, big.NewInt(1000))
+	l1Cost.Mul(l1Cost, big.NewInt(1_500_000))
+	l1Cost.Div(l1Cost, big.NewInt(1_000_000))
+
+	if have := statedb.GetBalance(config.Optimism.L1FeeRecipient); have.Cmp(l1Cost) != 0 {
+		t.Errorf("L1 fee recipient balance mismatch: have %v, want %v", have, l1Cost)
+	}
+	l2Fee := new(big.Int).Mul(new(big.Int).SetUint64(receipts[0][1].GasUsed), blocks[0].BaseFee())
+	if have := statedb.GetBalance(config.Optimism.BaseFeeRecipient); have.Cmp(l2Fee) != 0 {
+		t.Errorf("base fee recipient balance mismatch: have %v, want %v", have, l2Fee)
+	}
+	spent := new(big.Int).Add(l1Cost, l2Fee)
+	spent.Add(spent, tx.Value())
+	if have, want := statedb.GetBalance(addr), new(big.Int).Sub(funds, spent); have.Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, want)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrSupplyInvariant is returned when a block creates more ETH than its
+// deposits minted, or rewinds the nonce of an account.
+var ErrSupplyInvariant = errors.New("rollup supply invariant violated")
+
+// checkSupplyInvariants verifies that the ETH supply of a processed rollup
+// block grew by at most the ETH minted by its deposits, since fees only move
+// ETH and withdrawals burn it, and that no nonce of an account without code
+// decreased. Only the accounts that the block modified are compared with the
+// parent state, not the whole supply. It returns the ETH minted by the block.
+//
+// Accounts with code are left out of the nonce check, since a contract that is
+// destroyed and created again in the same block starts over with a new nonce.
+func checkSupplyInvariants(block *types.Block, parent, statedb *state.StateDB) (*big.Int, error) {
+	minted := new(big.Int)
+	for _, tx := range block.Transactions() {
+		if mint := tx.Mint(); mint != nil {
+			minted.Add(minted, mint)
+		}
+	}
+	growth := new(big.Int)
+	for _, addr := range statedb.DirtyAccounts() {
+		growth.Add(growth, statedb.GetBalance(addr))
+		growth.Sub(growth, parent.GetBalance(addr))
+
+		if parent.GetCodeSize(addr) > 0 || statedb.GetCodeSize(addr) > 0 {
+			continue
+		}
+		if prev, nonce := parent.GetNonce(addr), statedb.GetNonce(addr); nonce < prev {
+			return nil, fmt.Errorf("%w: nonce of %s decreased from %d to %d", ErrSupplyInvariant, addr, prev, nonce)
+		}
+	}
+	if growth.Cmp(minted) > 0 {
+		return nil, fmt.Errorf("%w: supply grew by %v, deposits minted %v", ErrSupplyInvariant, growth, minted)
+	}
+	return minted, nil
+}
+
+// EnableSupplyCheck makes the chain verify the supply invariants of every
+// block it processes, and track the ETH minted by deposits, for debugging
+// rollup chains. It must be called before blocks are inserted.
+func (bc *BlockChain) EnableSupplyCheck() {
+	bc.supplyCheck = true
+}
+
+// checkSupply verifies the supply invariants of a processed block, and records
+// the ETH minted up to the block. Minting is counted from the first checked
+// block on, unless the parent block was tracked.
+func (bc *BlockChain) checkSupply(block *types.Block, parent *types.Header, statedb *state.StateDB) error {
+	parentState, err := state.New(parent.Root, bc.stateCache, bc.snaps)
+	if err != nil {
+		return err
+	}
+	minted, err := checkSupplyInvariants(block, parentState, statedb)
+	if err != nil {
+		return err
+	}
+	supply := &rawdb.MintedSupply{Minted: minted, Since: block.NumberU64()}
+	if prev := rawdb.ReadMintedSupply(bc.db, block.ParentHash()); prev != nil {
+		supply.Minted.Add(supply.Minted, prev.Minted)
+		supply.Since = prev.Since
+	}
+	rawdb.WriteMintedSupply(bc.db, block.Hash(), supply)
+	return nil
+}
+
+// GetMintedSupply returns the ETH minted by deposits up to the block with the
+// given hash, or nil if the supply check did not track it.
+func (bc *BlockChain) GetMintedSupply(hash common.Hash) *rawdb.MintedSupply {
+	return rawdb.ReadMintedSupply(bc.db, hash)
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that the supply check accepts the blocks that move ETH around and mint
+// it through deposits, and rejects the blocks that create ETH out of thin air
+// or rewind nonces.
+func TestSupplyInvariants(t *testing.T) {
+	var (
+		alice = common.HexToAddress("0xaaaa")
+		bob   = common.HexToAddress("0xbbbb")
+		db    = state.NewDatabase(rawdb.NewMemoryDatabase())
+	)
+	genesis, _ := state.New(common.Hash{}, db, nil)
+	genesis.AddBalance(alice, big.NewInt(10))
+	genesis.SetNonce(alice, 3)
+	root, err := genesis.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(types.Transactions{
+		types.NewTx(&types.DepositTx{To: &bob, Mint: big.NewInt(5), Value: new(big.Int), Gas: 21000}),
+	}, nil)
+
+	tests := []struct {
+		name  string
+		apply func(s *state.StateDB)
+		fail  bool
+	}{
+		{"transfer and mint", func(s *state.StateDB) {
+			s.SubBalance(alice, big.NewInt(4))
+			s.AddBalance(bob, big.NewInt(9))
+			s.SetNonce(alice, 4)
+		}, false},
+		{"burn", func(s *state.StateDB) {
+			s.SubBalance(alice, big.NewInt(10))
+		}, false},
+		{"inflation", func(s *state.StateDB) {
+			s.AddBalance(bob, big.NewInt(6))
+		}, true},
+		{"nonce rewind", func(s *state.StateDB) {
+			s.AddBalance(bob, big.NewInt(5))
+			s.SetNonce(alice, 2)
+		}, true},
+	}
+	for _, tt := range tests {
+		parent, _ := state.New(root, db, nil)
+		statedb, _ := state.New(root, db, nil)
+		tt.apply(statedb)
+		statedb.Finalise(true)
+
+		minted, err := checkSupplyInvariants(block, parent, statedb)
+		if tt.fail {
+			if !errors.Is(err, ErrSupplyInvariant) {
+				t.Errorf("%s: invariant violation not detected: %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if minted.Cmp(big.NewInt(5)) != 0 {
+			t.Errorf("%s: minted %v, want 5", tt.name, minted)
+		}
+	}
+}
@@ -98,6 +98,15 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	txContext := NewEVMTxContext(msg)
 	evm.Reset(txContext, statedb)
 
+	// Deposits carry no nonce of their own, they are executed with the nonce of
+	// their sender, which also determines the address of the contracts they
+	// create. From Regolith onwards, it is recorded in the receipt.
+	nonce := tx.Nonce()
+	if tx.Type() == types.DepositTxType {
+		nonce = statedb.GetNonce(msg.From())
+	}
+	isRegolithDeposit := tx.Type() == types.DepositTxType && config.IsRegolith(evm.Context.Time.Uint64())
+
 	// Apply the transaction to the current state (included in the env).
 	result, err := ApplyMessage(evm, msg, gp)
 	if err != nil {
@@ -123,10 +132,13 @@ func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainCon
 	}
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = result.UsedGas
+	if isRegolithDeposit {
+		receipt.DepositNonce = &nonce
+	}
 
 	// If the transaction created a contract, store the creation address in the receipt.
 	if msg.To() == nil {
-		receipt.ContractAddress = crypto.CreateAddress(evm.TxContext.Origin, tx.Nonce())
+		receipt.ContractAddress = crypto.CreateAddress(evm.TxContext.Origin, nonce)
 	}
 
 	// Set the receipt logs and create the bloom filter.
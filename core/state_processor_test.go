@@ -299,6 +299,564 @@ func TestStateProcessorErrors(t *testing.T) {
 	}
 }
 
+// TestStateProcessorDepositGasUsed tests that the gas used by deposits, including
+// failed deposits and unmetered system deposits, is accounted for in the gasUsed
+// header field, and that the header matches the re-execution on import.
+func TestStateProcessorDepositGasUsed(t *testing.T) {
+	var (
+		config = &params.ChainConfig{
+			ChainID:             big.NewInt(1),
+			HomesteadBlock:      big.NewInt(0),
+			EIP150Block:         big.NewInt(0),
+			EIP155Block:         big.NewInt(0),
+			EIP158Block:         big.NewInt(0),
+			ByzantiumBlock:      big.NewInt(0),
+			ConstantinopleBlock: big.NewInt(0),
+			PetersburgBlock:     big.NewInt(0),
+			IstanbulBlock:       big.NewInt(0),
+			MuirGlacierBlock:    big.NewInt(0),
+			BerlinBlock:         big.NewInt(0),
+			LondonBlock:         big.NewInt(0),
+			Ethash:              new(params.EthashConfig),
+			Optimism: &params.OptimismConfig{
+				BaseFeeRecipient: common.HexToAddress("0x4200000000000000000000000000000000000019"),
+				L1FeeRecipient:   common.HexToAddress("0x420000000000000000000000000000000000001a"),
+			},
+		}
+		signer    = types.LatestSigner(config)
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr      = crypto.PubkeyToAddress(key.PublicKey)
+		depositor = common.HexToAddress("0xdeadbeef")
+		to        = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		db        = rawdb.NewMemoryDatabase()
+		gspec     = &Genesis{
+			Config: config,
+			Alloc: GenesisAlloc{
+				addr: GenesisAccount{Balance: big.NewInt(params.Ether)},
+				// Reverting contract, to fail a deposit
+				to: GenesisAccount{Code: []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}, Balance: common.Big0},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, receipts := GenerateChain(config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		// System deposit: unmetered
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash:          common.HexToHash("0x01"),
+			From:                depositor,
+			To:                  &addr,
+			Value:               new(big.Int),
+			Gas:                 1_000_000,
+			IsSystemTransaction: true,
+		}))
+		// Successful user deposit, minting and transferring
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x02"),
+			From:       depositor,
+			To:         &addr,
+			Mint:       big.NewInt(100),
+			Value:      big.NewInt(50),
+			Gas:        100_000,
+		}))
+		// Failing user deposit
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x03"),
+			From:       depositor,
+			To:         &to,
+			Value:      new(big.Int),
+			Gas:        80_000,
+		}))
+		// Regular transaction
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(addr), to, new(big.Int), 50_000, b.header.BaseFee, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddTx(tx)
+	})
+	var sum uint64
+	for _, receipt := range receipts[0] {
+		sum += receipt.GasUsed
+	}
+	if have := blocks[0].GasUsed(); have != sum {
+		t.Fatalf("header gasUsed mismatch: have %d, want %d", have, sum)
+	}
+	if have := receipts[0][len(receipts[0])-1].CumulativeGasUsed; have != sum {
+		t.Fatalf("cumulativeGasUsed mismatch: have %d, want %d", have, sum)
+	}
+	if receipts[0][0].GasUsed != 0 {
+		t.Errorf("system deposit gasUsed mismatch: have %d, want 0", receipts[0][0].GasUsed)
+	}
+	if receipts[0][1].GasUsed != 100_000 || receipts[0][2].GasUsed != 80_000 {
+		t.Errorf("deposit gasUsed mismatch: have %d and %d, want 100000 and 80000", receipts[0][1].GasUsed, receipts[0][2].GasUsed)
+	}
+	if receipts[0][2].Status != types.ReceiptStatusFailed {
+		t.Errorf("reverting deposit did not fail")
+	}
+	// Re-execute the block on import, which validates the gasUsed header field.
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import block with deposits: %v", err)
+	}
+}
+
+// Tests the gas metering of deposits against the metering of regular
+// transactions: deposits pay no intrinsic gas, since they paid for it on L1,
+// and their receipts record the whole gas limit, without any refunds.
+func TestStateProcessorDepositGasMetering(t *testing.T) {
+	var (
+		config = *params.AllEthashProtocolChanges
+		db     = rawdb.NewMemoryDatabase()
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		signer = types.LatestSigner(&config)
+
+		depositor = common.HexToAddress("0xdeadbeef")
+		// Contracts that store to a fresh slot, and that clear a slot for a
+		// refund. One of each for deposits and regular transactions.
+		store      = []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+		clear      = []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+		depStore   = common.HexToAddress("0xaa01")
+		txStore    = common.HexToAddress("0xaa02")
+		depClear   = common.HexToAddress("0xbb01")
+		txClear    = common.HexToAddress("0xbb02")
+		slotIsSet  = map[common.Hash]common.Hash{{}: common.BigToHash(common.Big1)}
+		storeLimit = uint64(25_000) // enough to store, not enough to also pay intrinsic gas
+		gspec      = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				addr:     {Balance: big.NewInt(params.Ether)},
+				depStore: {Code: store, Balance: common.Big0},
+				txStore:  {Code: store, Balance: common.Big0},
+				depClear: {Code: clear, Storage: slotIsSet, Balance: common.Big0},
+				txClear:  {Code: clear, Storage: slotIsSet, Balance: common.Big0},
+			},
+		}
+	)
+	config.Optimism = &params.OptimismConfig{}
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x01"),
+			From:       depositor,
+			To:         &depStore,
+			Value:      new(big.Int),
+			Gas:        storeLimit,
+		}))
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x02"),
+			From:       depositor,
+			To:         &depClear,
+			Value:      new(big.Int),
+			Gas:        100_000,
+		}))
+		for _, tx := range []*types.Transaction{
+			types.NewTransaction(b.TxNonce(addr), txStore, new(big.Int), storeLimit, b.header.BaseFee, nil),
+			types.NewTransaction(b.TxNonce(addr)+1, txClear, new(big.Int), 100_000, b.header.BaseFee, nil),
+		} {
+			signed, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.AddTx(signed)
+		}
+	})
+	depStoreReceipt, depClearReceipt := receipts[0][0], receipts[0][1]
+	txStoreReceipt, txClearReceipt := receipts[0][2], receipts[0][3]
+
+	// Without intrinsic gas, the deposit can store with the limit that is too
+	// low for the regular transaction.
+	if depStoreReceipt.Status != types.ReceiptStatusSuccessful {
+		t.Error("deposit ran out of gas paying for intrinsic gas")
+	}
+	if txStoreReceipt.Status != types.ReceiptStatusFailed {
+		t.Error("regular transaction did not pay intrinsic gas")
+	}
+	// Both clear the slot, only the regular transaction is refunded.
+	if depClearReceipt.Status != types.ReceiptStatusSuccessful || txClearReceipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("clearing storage failed")
+	}
+	if depStoreReceipt.GasUsed != storeLimit || depClearReceipt.GasUsed != 100_000 {
+		t.Errorf("deposit gasUsed mismatch: have %d and %d, want the gas limits", depStoreReceipt.GasUsed, depClearReceipt.GasUsed)
+	}
+	if unrefunded := params.TxGas + 3 + 3 + params.SstoreResetGasEIP2200; txClearReceipt.GasUsed >= unrefunded {
+		t.Errorf("regular transaction not refunded: gasUsed %d, unrefunded %d", txClearReceipt.GasUsed, unrefunded)
+	}
+
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import block with deposits: %v", err)
+	}
+	state, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := state.GetBalance(depositor); balance.Sign() != 0 {
+		t.Errorf("depositor was credited %v", balance)
+	}
+	if state.GetState(depStore, common.Hash{}) != common.BigToHash(common.Big1) || state.GetState(depClear, common.Hash{}) != (common.Hash{}) {
+		t.Error("deposits did not modify storage")
+	}
+}
+
+// Tests that deposits record their whole gas limit before Regolith, and the
+// gas they consumed after it, still without refunds.
+func TestStateProcessorDepositGasRegolith(t *testing.T) {
+	var (
+		config       = *params.AllEthashProtocolChanges
+		db           = rawdb.NewMemoryDatabase()
+		depositor    = common.HexToAddress("0xdeadbeef")
+		store        = common.HexToAddress("0xaa01")
+		regolithTime = uint64(20) // the second block, generated 10 seconds apart
+		gspec        = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				store: {Code: []byte{byte(vm.PUSH1), 1, byte(vm.TIMESTAMP), byte(vm.SSTORE), byte(vm.STOP)}, Balance: common.Big0},
+			},
+		}
+	)
+	config.Optimism = &params.OptimismConfig{RegolithTime: &regolithTime}
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.BigToHash(big.NewInt(int64(i))),
+			From:       depositor,
+			To:         &store,
+			Value:      new(big.Int),
+			Gas:        100_000,
+		}))
+	})
+	if blocks[1].Time() != regolithTime {
+		t.Fatalf("second block at time %d, want %d", blocks[1].Time(), regolithTime)
+	}
+	if have := receipts[0][0].GasUsed; have != 100_000 {
+		t.Errorf("pre-Regolith deposit gasUsed mismatch: have %d, want 100000", have)
+	}
+	// PUSH1, TIMESTAMP and a cold SSTORE to a fresh slot.
+	want := uint64(3 + 2 + params.SstoreSetGasEIP2200 + params.ColdSloadCostEIP2929)
+	if have := receipts[1][0].GasUsed; have != want {
+		t.Errorf("Regolith deposit gasUsed mismatch: have %d, want %d", have, want)
+	}
+	if have := blocks[1].GasUsed(); have != want {
+		t.Errorf("header gasUsed mismatch: have %d, want %d", have, want)
+	}
+
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks with deposits: %v", err)
+	}
+}
+
+// Tests that deposits are not limited by the block gas limit once they are
+// exempt from it, and are recorded as using no block gas.
+func TestStateProcessorDepositGasExempt(t *testing.T) {
+	var (
+		config     = *params.AllEthashProtocolChanges
+		db         = rawdb.NewMemoryDatabase()
+		depositor  = common.HexToAddress("0xdeadbeef")
+		exemptTime = uint64(20) // the second block, generated 10 seconds apart
+		gspec      = &Genesis{Config: &config}
+	)
+	config.Optimism = &params.OptimismConfig{DepositGasExemptTime: &exemptTime}
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		// Before the exemption, the deposits must fit in the block.
+		count, gas := 1, uint64(100_000)
+		if i == 1 {
+			count, gas = 3, genesis.GasLimit()/2
+		}
+		for j := 0; j < count; j++ {
+			b.AddTx(types.NewTx(&types.DepositTx{
+				SourceHash: common.BigToHash(big.NewInt(int64(i*10 + j))),
+				From:       depositor,
+				To:         &depositor,
+				Value:      new(big.Int),
+				Gas:        gas,
+			}))
+		}
+	})
+	if have := blocks[0].GasUsed(); have != 100_000 {
+		t.Errorf("header gasUsed before the exemption mismatch: have %d, want 100000", have)
+	}
+	if have := blocks[1].GasUsed(); have != 0 {
+		t.Errorf("header gasUsed after the exemption mismatch: have %d, want 0", have)
+	}
+	for i, receipt := range receipts[1] {
+		if receipt.GasUsed != 0 || receipt.Status != types.ReceiptStatusSuccessful {
+			t.Errorf("exempt deposit %d: gasUsed %d, status %d", i, receipt.GasUsed, receipt.Status)
+		}
+	}
+
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks with exempt deposits: %v", err)
+	}
+}
+
+// Tests that deposits record the nonce they were executed with from Regolith
+// onwards, and that contracts they create are found at the address derived
+// from it.
+func TestStateProcessorDepositNonce(t *testing.T) {
+	var (
+		config       = *params.AllEthashProtocolChanges
+		db           = rawdb.NewMemoryDatabase()
+		depositor    = common.HexToAddress("0xdeadbeef")
+		regolithTime = uint64(20) // the second block, generated 10 seconds apart
+		gspec        = &Genesis{Config: &config}
+		// Deploys a contract with a single STOP as its code.
+		initCode = []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+	)
+	config.Optimism = &params.OptimismConfig{RegolithTime: &regolithTime}
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.BigToHash(big.NewInt(int64(i))),
+			From:       depositor,
+			Value:      new(big.Int),
+			Gas:        100_000,
+			Data:       initCode,
+		}))
+	})
+	if nonce := receipts[0][0].DepositNonce; nonce != nil {
+		t.Errorf("pre-Regolith deposit has nonce %d", *nonce)
+	}
+	if nonce := receipts[1][0].DepositNonce; nonce == nil || *nonce != 1 {
+		t.Fatalf("Regolith deposit nonce mismatch: have %v, want 1", nonce)
+	}
+	want := crypto.CreateAddress(depositor, 1)
+	if have := receipts[1][0].ContractAddress; have != want {
+		t.Errorf("contract address mismatch: have %s, want %s", have, want)
+	}
+
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks with deposits: %v", err)
+	}
+	state, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetCodeSize(want) != 1 {
+		t.Errorf("no contract deployed at %s", want)
+	}
+	stored := chain.GetReceiptsByHash(blocks[1].Hash())
+	if len(stored) != 1 || stored[0].DepositNonce == nil || *stored[0].DepositNonce != 1 || stored[0].ContractAddress != want {
+		t.Errorf("stored receipt mismatch: %+v", stored)
+	}
+}
+
+// Tests that deposits create contracts at the address derived from the nonce of
+// their sender, whether the sender is an account or the alias of an L1 contract,
+// and that failed creations consume their gas and still advance the nonce.
+func TestStateProcessorDepositCreation(t *testing.T) {
+	var (
+		config       = *params.AllEthashProtocolChanges
+		db           = rawdb.NewMemoryDatabase()
+		depositor    = common.HexToAddress("0xdeadbeef")
+		regolithTime = uint64(20) // the second block, generated 10 seconds apart
+		gspec        = &Genesis{Config: &config}
+
+		// The aliased sender of the deposits made by the L1 contract at 0xcafe.
+		aliased = common.HexToAddress("0x111100000000000000000000000000000000dc0f")
+		// Deploys a contract with a single STOP as its code.
+		deploy = []byte{byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+		revert = []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}
+		loop   = []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.JUMP)}
+	)
+	config.Optimism = &params.OptimismConfig{RegolithTime: &regolithTime}
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 2, func(i int, b *BlockGen) {
+		creations := []struct {
+			from common.Address
+			code []byte
+		}{{aliased, deploy}, {depositor, revert}}
+		if i == 1 {
+			creations = []struct {
+				from common.Address
+				code []byte
+			}{{depositor, revert}, {depositor, loop}, {depositor, deploy}, {aliased, deploy}}
+		}
+		for j, c := range creations {
+			b.AddTx(types.NewTx(&types.DepositTx{
+				SourceHash: common.BigToHash(big.NewInt(int64(i*10 + j))),
+				From:       c.from,
+				Value:      new(big.Int),
+				Gas:        100_000,
+				Data:       c.code,
+			}))
+		}
+	})
+	tests := []struct {
+		receipt *types.Receipt
+		status  uint64
+		address common.Address
+		gasUsed uint64 // 0 if only less than the gas limit
+	}{
+		// Before Regolith, deposits record their whole gas limit.
+		{receipts[0][0], types.ReceiptStatusSuccessful, crypto.CreateAddress(aliased, 0), 100_000},
+		{receipts[0][1], types.ReceiptStatusFailed, crypto.CreateAddress(depositor, 0), 100_000},
+		// From Regolith, a revert returns the unused gas, running out of gas
+		// consumes all of it.
+		{receipts[1][0], types.ReceiptStatusFailed, crypto.CreateAddress(depositor, 1), 0},
+		{receipts[1][1], types.ReceiptStatusFailed, crypto.CreateAddress(depositor, 2), 100_000},
+		{receipts[1][2], types.ReceiptStatusSuccessful, crypto.CreateAddress(depositor, 3), 0},
+		{receipts[1][3], types.ReceiptStatusSuccessful, crypto.CreateAddress(aliased, 1), 0},
+	}
+	for i, tt := range tests {
+		if tt.receipt.Status != tt.status {
+			t.Errorf("creation %d: status %d, want %d", i, tt.receipt.Status, tt.status)
+		}
+		if tt.receipt.ContractAddress != tt.address {
+			t.Errorf("creation %d: contract address %s, want %s", i, tt.receipt.ContractAddress, tt.address)
+		}
+		if tt.gasUsed != 0 && tt.receipt.GasUsed != tt.gasUsed {
+			t.Errorf("creation %d: gasUsed %d, want %d", i, tt.receipt.GasUsed, tt.gasUsed)
+		}
+		if tt.gasUsed == 0 && (tt.receipt.GasUsed == 0 || tt.receipt.GasUsed >= 100_000) {
+			t.Errorf("creation %d: gasUsed %d, want less than the gas limit", i, tt.receipt.GasUsed)
+		}
+	}
+
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import blocks with deposits: %v", err)
+	}
+	state, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		deployed := state.GetCodeSize(tt.address) == 1
+		if want := tt.status == types.ReceiptStatusSuccessful; deployed != want {
+			t.Errorf("creation %d: contract deployed %v, want %v", i, deployed, want)
+		}
+	}
+	if nonce := state.GetNonce(depositor); nonce != 4 {
+		t.Errorf("depositor nonce %d, want 4", nonce)
+	}
+	if nonce := state.GetNonce(aliased); nonce != 2 {
+		t.Errorf("aliased sender nonce %d, want 2", nonce)
+	}
+	// Without a recorded nonce, the stored receipts of the deposits before
+	// Regolith cannot derive the contract address.
+	if stored := chain.GetReceiptsByHash(blocks[0].Hash()); stored[0].ContractAddress != (common.Address{}) {
+		t.Errorf("pre-Regolith stored receipt has contract address %s", stored[0].ContractAddress)
+	}
+	for i, receipt := range chain.GetReceiptsByHash(blocks[1].Hash()) {
+		if want := receipts[1][i].ContractAddress; receipt.ContractAddress != want {
+			t.Errorf("stored receipt %d: contract address %s, want %s", i, receipt.ContractAddress, want)
+		}
+	}
+}
+
+// Tests that the minted value of a deposit is credited before execution, and
+// kept even if the deposit fails.
+func TestStateProcessorDepositMint(t *testing.T) {
+	var (
+		config = *params.AllEthashProtocolChanges
+		db     = rawdb.NewMemoryDatabase()
+		to     = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		// One depositor per case, so the balances are easy to tell apart.
+		reverted = common.HexToAddress("0x01")
+		tooMuch  = common.HexToAddress("0x02")
+		gspec    = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				// Reverting contract, to fail a deposit
+				to: GenesisAccount{Code: []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}, Balance: common.Big0},
+			},
+		}
+	)
+	config.Optimism = &params.OptimismConfig{}
+	genesis := gspec.MustCommit(db)
+	blocks, receipts := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		// The call reverts: the transfer is undone, the mint is not.
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x01"),
+			From:       reverted,
+			To:         &to,
+			Mint:       big.NewInt(100),
+			Value:      big.NewInt(50),
+			Gas:        100_000,
+		}))
+		// The value exceeds the balance after minting: the deposit is
+		// invalid, but the mint is kept.
+		b.AddTx(types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x02"),
+			From:       tooMuch,
+			To:         &to,
+			Mint:       big.NewInt(100),
+			Value:      big.NewInt(150),
+			Gas:        100_000,
+		}))
+	})
+	for i, receipt := range receipts[0] {
+		if receipt.Status != types.ReceiptStatusFailed {
+			t.Errorf("deposit %d did not fail", i)
+		}
+	}
+	importDb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(importDb)
+	chain, err := NewBlockChain(importDb, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import block with deposits: %v", err)
+	}
+	state, err := chain.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []common.Address{reverted, tooMuch} {
+		if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(100)) != 0 {
+			t.Errorf("balance of %s mismatch: have %v, want 100", addr, balance)
+		}
+		if nonce := state.GetNonce(addr); nonce != 1 {
+			t.Errorf("nonce of %s mismatch: have %d, want 1", addr, nonce)
+		}
+	}
+	if balance := state.GetBalance(to); balance.Sign() != 0 {
+		t.Errorf("failed deposits transferred %v", balance)
+	}
+}
+
 // GenerateBadBlock constructs a "block" which contains the transactions. The transactions are not expected to be
 // valid, and no proper post-state can be made. But from the perspective of the blockchain, the block is sufficiently
 // valid to be considered for import:
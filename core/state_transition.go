@@ -234,8 +234,9 @@ func (st *StateTransition) preCheck() error {
 		// Gas is free, but no refunds!
 		st.initialGas = st.msg.Gas()
 		st.gas += st.msg.Gas() // Add gas here in order to be able to execute calls.
-		// Don't touch the gas pool for system transactions
-		if st.msg.IsSystemTx() {
+		// Don't touch the gas pool for system transactions, nor for deposits
+		// that are exempt from the block gas limit.
+		if st.msg.IsSystemTx() || st.depositGasExempt() {
 			return nil
 		}
 		return st.gp.SubGas(st.msg.Gas()) // gas used by deposits may not be used by other txs
@@ -301,6 +302,8 @@ func (st *StateTransition) preCheck() error {
 // However if any consensus issue encountered, return the error directly with
 // nil evm execution result.
 func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
+	// Deposits mint before anything else, outside of the snapshot, so that the
+	// minted value is kept even if the deposit fails.
 	if mint := st.msg.Mint(); mint != nil {
 		st.state.AddBalance(st.msg.From(), mint)
 	}
@@ -316,7 +319,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		// Record deposits as using all their gas (matches the gas pool)
 		// System Transactions are special & are not recorded as using any gas (anywhere)
 		gasUsed := st.msg.Gas()
-		if st.msg.IsSystemTx() {
+		if st.msg.IsSystemTx() || st.depositGasExempt() {
 			gasUsed = 0
 		}
 		result = &ExecutionResult{
@@ -359,15 +362,19 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 		contractCreation = msg.To() == nil
 	)
 
-	// Check clauses 4-5, subtract intrinsic gas if everything is correct
-	gas, err := IntrinsicGas(st.data, st.msg.AccessList(), contractCreation, rules.IsHomestead, rules.IsIstanbul)
-	if err != nil {
-		return nil, err
-	}
-	if st.gas < gas {
-		return nil, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, st.gas, gas)
+	// Check clauses 4-5, subtract intrinsic gas if everything is correct.
+	// Deposits paid for their intrinsic gas on L1, all of their gas is
+	// available for execution.
+	if msg.Nonce() != types.DepositsNonce {
+		gas, err := IntrinsicGas(st.data, st.msg.AccessList(), contractCreation, rules.IsHomestead, rules.IsIstanbul)
+		if err != nil {
+			return nil, err
+		}
+		if st.gas < gas {
+			return nil, fmt.Errorf("%w: have %d, want %d", ErrIntrinsicGas, st.gas, gas)
+		}
+		st.gas -= gas
 	}
-	st.gas -= gas
 
 	// Check clause 6
 	if msg.Value().Sign() > 0 && !st.evm.Context.CanTransfer(st.state, msg.From(), msg.Value()) {
@@ -390,14 +397,22 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
 
-	// if deposit: skip refunds, skip tipping coinbase
+	// if deposit: skip refunds, skip tipping coinbase. The depositor did not
+	// buy the gas, so neither unused gas nor the refund counter is credited.
 	if st.msg.Nonce() == types.DepositsNonce {
 		// Record deposits as using all their gas (matches the gas pool)
 		// System Transactions are special & are not recorded as using any gas (anywhere)
 		gasUsed := st.msg.Gas()
-		if st.msg.IsSystemTx() {
+		if st.msg.IsSystemTx() || st.depositGasExempt() {
 			gasUsed = 0
+		} else if st.evm.ChainConfig().IsRegolith(st.evm.Context.Time.Uint64()) {
+			// Since Regolith, deposits are recorded as using the gas they
+			// consumed, the unused gas is returned to the block.
+			gasUsed = st.gasUsed()
+			st.gp.AddGas(st.gas)
 		}
+		// Report the recorded gas to the tracers, like for any other transaction.
+		st.gas = st.msg.Gas() - gasUsed
 		return &ExecutionResult{
 			UsedGas:    gasUsed,
 			Err:        vmerr,
@@ -428,8 +443,10 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 
 	if optimismConfig := st.evm.ChainConfig().Optimism; optimismConfig != nil {
 		st.state.AddBalance(optimismConfig.BaseFeeRecipient, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.evm.Context.BaseFee))
-		if cost := st.evm.Context.L1CostFunc(st.evm.Context.BlockNumber.Uint64(), st.msg); cost != nil {
-			st.state.AddBalance(optimismConfig.L1FeeRecipient, cost)
+		if st.evm.Context.L1CostFunc != nil {
+			if cost := st.evm.Context.L1CostFunc(st.evm.Context.BlockNumber.Uint64(), st.msg); cost != nil {
+				st.state.AddBalance(optimismConfig.L1FeeRecipient, cost)
+			}
 		}
 	}
 
@@ -457,6 +474,13 @@ func (st *StateTransition) refundGas(refundQuotient uint64) {
 	st.gp.AddGas(st.gas)
 }
 
+// depositGasExempt returns whether deposits are exempt from the block gas limit.
+// Exempt deposits take no gas from the gas pool and are recorded as using no
+// gas, like system transactions.
+func (st *StateTransition) depositGasExempt() bool {
+	return st.evm.ChainConfig().IsDepositGasExempt(st.evm.Context.Time.Uint64())
+}
+
 // gasUsed returns the amount of gas used up by the state transition.
 func (st *StateTransition) gasUsed() uint64 {
 	return st.initialGas - st.gas
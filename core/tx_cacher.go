@@ -99,7 +99,12 @@ func (cacher *txSenderCacher) recoverFromBlocks(signer types.Signer, blocks []*t
 	}
 	txs := make([]*types.Transaction, 0, count)
 	for _, block := range blocks {
-		txs = append(txs, block.Transactions()...)
+		for _, tx := range block.Transactions() {
+			// Deposits carry their sender, there is nothing to recover
+			if tx.Type() != types.DepositTxType {
+				txs = append(txs, tx)
+			}
+		}
 	}
 	cacher.recover(signer, txs)
 }
@@ -106,6 +106,11 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 		// New transaction parsed, queue up for later, import if threshold is reached
 		total++
 
+		// Deposits are not valid pool transactions, drop them from old journals
+		if tx.Type() == types.DepositTxType {
+			dropped++
+			continue
+		}
 		if batch = append(batch, tx); batch.Len() > 1024 {
 			loadBatch(batch)
 			batch = batch[:0]
@@ -808,8 +808,9 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
-	// Only journal if it's enabled and the transaction is local
-	if pool.journal == nil || !pool.locals.contains(from) {
+	// Only journal if it's enabled and the transaction is local. Deposits
+	// are never journaled, they are not valid pool transactions.
+	if pool.journal == nil || !pool.locals.contains(from) || tx.Type() == types.DepositTxType {
 		return
 	}
 	if err := pool.journal.insert(tx); err != nil {
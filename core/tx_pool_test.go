@@ -17,12 +17,15 @@
 package core
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -34,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -2559,3 +2563,79 @@ func BenchmarkPoolMultiAccountBatchInsert(b *testing.B) {
 		pool.AddRemotesSync([]*types.Transaction{tx})
 	}
 }
+
+// Tests that deposits are neither accepted into the pool, nor loaded from or
+// written to the journal.
+func TestTransactionPoolRejectsDeposits(t *testing.T) {
+	t.Parallel()
+
+	journal := filepath.Join(t.TempDir(), "transactions.rlp")
+
+	key, _ := crypto.GenerateKey()
+	var (
+		tx      = transaction(0, 100000, key)
+		deposit = types.NewTx(&types.DepositTx{
+			From:  crypto.PubkeyToAddress(key.PublicKey),
+			Value: new(big.Int),
+			Gas:   100000,
+		})
+	)
+	// Journal a deposit along with a local transaction, as a buggy or malicious
+	// older version could have.
+	file, err := os.Create(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range []*types.Transaction{deposit, tx} {
+		if err := rlp.Encode(file, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.Close()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = journal
+	config.Rejournal = time.Second
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pool.Get(deposit.Hash()) != nil {
+		t.Fatal("journaled deposit loaded into the pool")
+	}
+	if pool.Get(tx.Hash()) == nil {
+		t.Fatal("journaled transaction not loaded")
+	}
+	for _, local := range []bool{true, false} {
+		var err error
+		if local {
+			err = pool.AddLocal(deposit)
+		} else {
+			err = pool.addRemoteSync(deposit)
+		}
+		if !errors.Is(err, ErrTxTypeNotSupported) {
+			t.Errorf("deposit accepted (local: %v): %v", local, err)
+		}
+	}
+	// The journal is rotated after loading, without the deposit.
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := rlp.NewStream(bytes.NewReader(data), 0)
+	for {
+		var journaled types.Transaction
+		if err := stream.Decode(&journaled); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if journaled.Type() == types.DepositTxType {
+			t.Fatal("deposit kept in the journal")
+		}
+	}
+}
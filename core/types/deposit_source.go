@@ -0,0 +1,102 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Source hash domains, used to separate the source hashes of the different kinds
+// of deposits. A source hash commits to both the domain and a domain-specific
+// identifier of the deposit, so deposits of different kinds can never collide.
+const (
+	UserDepositSourceDomain         = 0
+	L1InfoDepositSourceDomain       = 1
+	UpgradeDepositSourceDomain      = 2
+	SystemConfigDepositSourceDomain = 3
+	ChainDepositSourceDomain        = 4
+)
+
+// UserDepositSourceHash computes the source hash of a user deposit, identified
+// by the hash of the L1 block that contains the deposit event and the index of
+// the event log within that block:
+//
+//	keccak256(bytes32(0) ++ keccak256(l1BlockHash ++ bytes32(logIndex)))
+func UserDepositSourceHash(l1BlockHash common.Hash, logIndex uint64) common.Hash {
+	var input [64]byte
+	copy(input[:32], l1BlockHash[:])
+	binary.BigEndian.PutUint64(input[64-8:], logIndex)
+	return depositSourceHash(UserDepositSourceDomain, crypto.Keccak256Hash(input[:]))
+}
+
+// L1InfoDepositSourceHash computes the source hash of an L1 info deposit,
+// identified by the hash of the L1 origin and the sequence number of the L2
+// block within the epoch:
+//
+//	keccak256(bytes32(1) ++ keccak256(l1BlockHash ++ bytes32(seqNumber)))
+func L1InfoDepositSourceHash(l1BlockHash common.Hash, seqNumber uint64) common.Hash {
+	var input [64]byte
+	copy(input[:32], l1BlockHash[:])
+	binary.BigEndian.PutUint64(input[64-8:], seqNumber)
+	return depositSourceHash(L1InfoDepositSourceDomain, crypto.Keccak256Hash(input[:]))
+}
+
+// UpgradeDepositSourceHash computes the source hash of a network upgrade deposit,
+// identified by a description of its intent, which must be unique per upgrade
+// transaction:
+//
+//	keccak256(bytes32(2) ++ keccak256(intent))
+func UpgradeDepositSourceHash(intent string) common.Hash {
+	return depositSourceHash(UpgradeDepositSourceDomain, crypto.Keccak256Hash([]byte(intent)))
+}
+
+// SystemConfigDepositSourceHash computes the source hash of a deposit that
+// applies a system config update, identified by the hash of the L1 block that
+// contains the update event and the index of the event log within that block:
+//
+//	keccak256(bytes32(3) ++ keccak256(l1BlockHash ++ bytes32(logIndex)))
+func SystemConfigDepositSourceHash(l1BlockHash common.Hash, logIndex uint64) common.Hash {
+	var input [64]byte
+	copy(input[:32], l1BlockHash[:])
+	binary.BigEndian.PutUint64(input[64-8:], logIndex)
+	return depositSourceHash(SystemConfigDepositSourceDomain, crypto.Keccak256Hash(input[:]))
+}
+
+// ChainDepositSourceHash binds the source hash of a deposit of any other domain
+// to the L2 chain that includes it, so that chains deriving from the same L1
+// blocks never include the same deposit:
+//
+//	keccak256(bytes32(4) ++ keccak256(bytes32(chainID) ++ sourceHash))
+func ChainDepositSourceHash(chainID *big.Int, sourceHash common.Hash) common.Hash {
+	var input [64]byte
+	chainID.FillBytes(input[:32])
+	copy(input[32:], sourceHash[:])
+	return depositSourceHash(ChainDepositSourceDomain, crypto.Keccak256Hash(input[:]))
+}
+
+// depositSourceHash computes a source hash from a domain and a domain-specific
+// deposit identifier.
+func depositSourceHash(domain uint64, depositID common.Hash) common.Hash {
+	var input [64]byte
+	binary.BigEndian.PutUint64(input[32-8:32], domain)
+	copy(input[32:], depositID[:])
+	return crypto.Keccak256Hash(input[:])
+}
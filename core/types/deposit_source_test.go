@@ -0,0 +1,90 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Source hash test vectors. Other implementations of deposit derivation must
+// produce the same source hashes for these inputs.
+var depositSourceVectors = []struct {
+	name string
+	hash common.Hash
+	want common.Hash
+}{
+	{
+		name: "user deposit",
+		hash: UserDepositSourceHash(common.HexToHash("0xc00e5d67c2755389aded7d8b151cbd5bcdf7ed275ad5e028b664880fc7581c77"), 4),
+		want: common.HexToHash("0xea35d3c24d61d36e4f0c4126acac226410d049fc3b2c173f7563e4e6fe8d773a"),
+	},
+	{
+		name: "L1 info deposit",
+		hash: L1InfoDepositSourceHash(common.HexToHash("0xc00e5d67c2755389aded7d8b151cbd5bcdf7ed275ad5e028b664880fc7581c77"), 4),
+		want: common.HexToHash("0x0586c503340591999b8b38bc9834bb16aec7d5bc00eb5587ab139c9ddab81977"),
+	},
+	{
+		name: "upgrade deposit",
+		hash: UpgradeDepositSourceHash("Ecotone: L1 Block Proxy Update"),
+		want: common.HexToHash("0x18acb38c5ff1c238a7460ebc1b421fa49ec4874bdf1e0a530d234104e5e67dbc"),
+	},
+	{
+		name: "chain bound user deposit",
+		hash: ChainDepositSourceHash(big.NewInt(901), UserDepositSourceHash(common.HexToHash("0xc00e5d67c2755389aded7d8b151cbd5bcdf7ed275ad5e028b664880fc7581c77"), 4)),
+		want: common.HexToHash("0x9e3438485ef78c714bb1c042b4ea66260005e69631f4cb56d7b9ed9ef1d31fcb"),
+	},
+}
+
+func TestDepositSourceHashVectors(t *testing.T) {
+	for _, v := range depositSourceVectors {
+		if v.hash != v.want {
+			t.Errorf("%s: source hash mismatch: have %s, want %s", v.name, v.hash, v.want)
+		}
+	}
+}
+
+func TestDepositSourceHashDomains(t *testing.T) {
+	// The same deposit identifier yields different source hashes in different
+	// domains.
+	blockHash := common.HexToHash("0x01")
+	user := UserDepositSourceHash(blockHash, 7)
+	l1Info := L1InfoDepositSourceHash(blockHash, 7)
+	if user == l1Info {
+		t.Fatal("user and L1 info deposits share a source hash")
+	}
+	// Different identifiers within a domain yield different source hashes.
+	if UserDepositSourceHash(blockHash, 7) == UserDepositSourceHash(blockHash, 8) {
+		t.Fatal("log index not committed to")
+	}
+	if L1InfoDepositSourceHash(blockHash, 0) == L1InfoDepositSourceHash(common.HexToHash("0x02"), 0) {
+		t.Fatal("L1 block hash not committed to")
+	}
+	if UpgradeDepositSourceHash("a") == UpgradeDepositSourceHash("b") {
+		t.Fatal("upgrade intent not committed to")
+	}
+	// Chain bound source hashes differ per chain, and from the unbound one.
+	chainA, chainB := ChainDepositSourceHash(big.NewInt(10), user), ChainDepositSourceHash(big.NewInt(11), user)
+	if chainA == chainB || chainA == user {
+		t.Fatal("chain ID not committed to")
+	}
+	if ChainDepositSourceHash(big.NewInt(10), l1Info) == chainA {
+		t.Fatal("bound source hash not committed to")
+	}
+}
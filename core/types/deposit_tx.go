@@ -17,13 +17,25 @@
 package types
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
 const DepositTxType = 0x7E
 
+var (
+	ErrNotDeposit            = errors.New("transaction is not a deposit")
+	ErrDepositOriginUnknown  = errors.New("unknown deposit origin")
+	ErrDepositSourceConflict = errors.New("conflicting deposits with the same source hash")
+	ErrDepositOriginConflict = errors.New("conflicting deposit origins")
+	ErrDepositSignature      = errors.New("deposits do not have a signature")
+)
+
 type DepositTx struct {
 	// SourceHash uniquely identifies the source of the deposit
 	SourceHash common.Hash
@@ -89,3 +101,156 @@ func (tx *DepositTx) rawSignatureValues() (v, r, s *big.Int) {
 func (tx *DepositTx) setSignatureValues(chainID, v, r, s *big.Int) {
 	// this is a noop for deposit transactions
 }
+
+// IsSelfFunded reports whether the minted amount covers the transferred value,
+// i.e. whether the deposit can execute its value transfer without relying on
+// any prior balance of the sender. A nil Mint is treated as zero.
+func (tx *DepositTx) IsSelfFunded() bool {
+	mint := tx.Mint
+	if mint == nil {
+		mint = common.Big0
+	}
+	value := tx.Value
+	if value == nil {
+		value = common.Big0
+	}
+	return mint.Cmp(value) >= 0
+}
+
+// ShortID returns a compact identifier of the deposit for use in log lines,
+// consisting of the type prefix and the first 8 hex characters of the source hash.
+func (tx *DepositTx) ShortID() string {
+	return fmt.Sprintf("dep:%x", tx.SourceHash[:4])
+}
+
+// AppliedMarker returns the idempotency key of the deposit: the source hash
+// uniquely identifies a deposit, and a deposit is never applied twice.
+func (tx *DepositTx) AppliedMarker() common.Hash {
+	return tx.SourceHash
+}
+
+// AppliedSet tracks the deposits that have already been applied, e.g. while
+// re-deriving blocks during recovery. The zero value is ready for use.
+type AppliedSet struct {
+	markers map[common.Hash]struct{}
+}
+
+// Add marks the deposit with the given applied-marker as applied.
+func (s *AppliedSet) Add(marker common.Hash) {
+	if s.markers == nil {
+		s.markers = make(map[common.Hash]struct{})
+	}
+	s.markers[marker] = struct{}{}
+}
+
+// Contains reports whether the deposit with the given applied-marker was applied.
+func (s *AppliedSet) Contains(marker common.Hash) bool {
+	_, ok := s.markers[marker]
+	return ok
+}
+
+// Len returns the number of applied deposits in the set.
+func (s *AppliedSet) Len() int {
+	return len(s.markers)
+}
+
+// WithNewSource returns a copy of the deposit as transaction, with the source
+// hash recomputed for a user deposit emitted in the given L1 block at the given
+// log index. This models how the identity of a deposit changes if it is
+// re-included in a different L1 block after a reorg.
+func (tx *DepositTx) WithNewSource(l1BlockHash common.Hash, logIndex uint64) *Transaction {
+	cpy := tx.copy().(*DepositTx)
+	cpy.SourceHash = UserDepositSourceHash(l1BlockHash, logIndex)
+	return NewTx(cpy)
+}
+
+// OriginInfo describes where on L1 a deposit originated from.
+type OriginInfo struct {
+	L1BlockHash   common.Hash
+	L1BlockNumber uint64
+	LogIndex      uint64
+}
+
+// CanonicalizeDeposits returns the deposits in the canonical order for block
+// inclusion: ordered by L1 block number and log index of their origin, with exact
+// duplicates removed. The origin of every deposit is looked up by source hash.
+//
+// An error is returned if a transaction is not a deposit, if its origin is not
+// known, if two different deposits share a source hash, or if two different
+// deposits claim the same position on L1 (or disagree on an L1 block hash).
+func CanonicalizeDeposits(txs []*Transaction, origins map[common.Hash]OriginInfo) ([]*Transaction, error) {
+	var (
+		seen   = make(map[common.Hash]*Transaction, len(txs))
+		blocks = make(map[uint64]common.Hash)
+		result = make([]*Transaction, 0, len(txs))
+	)
+	for _, tx := range txs {
+		if tx.Type() != DepositTxType {
+			return nil, fmt.Errorf("%w: %s", ErrNotDeposit, tx.Hash())
+		}
+		source := tx.SourceHash()
+		origin, ok := origins[source]
+		if !ok {
+			return nil, fmt.Errorf("%w: source %s", ErrDepositOriginUnknown, source)
+		}
+		if prev, ok := seen[source]; ok {
+			if prev.Hash() != tx.Hash() {
+				return nil, fmt.Errorf("%w: source %s", ErrDepositSourceConflict, source)
+			}
+			continue
+		}
+		if hash, ok := blocks[origin.L1BlockNumber]; ok && hash != origin.L1BlockHash {
+			return nil, fmt.Errorf("%w: L1 block %d has hashes %s and %s", ErrDepositOriginConflict, origin.L1BlockNumber, hash, origin.L1BlockHash)
+		}
+		blocks[origin.L1BlockNumber] = origin.L1BlockHash
+		seen[source] = tx
+		result = append(result, tx)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := origins[result[i].SourceHash()], origins[result[j].SourceHash()]
+		if a.L1BlockNumber != b.L1BlockNumber {
+			return a.L1BlockNumber < b.L1BlockNumber
+		}
+		return a.LogIndex < b.LogIndex
+	})
+	for i := 1; i < len(result); i++ {
+		a, b := origins[result[i-1].SourceHash()], origins[result[i].SourceHash()]
+		if a.L1BlockNumber == b.L1BlockNumber && a.LogIndex == b.LogIndex {
+			return nil, fmt.Errorf("%w: L1 block %d log %d claimed twice", ErrDepositOriginConflict, a.L1BlockNumber, a.LogIndex)
+		}
+	}
+	return result, nil
+}
+
+// EstimateBatchSize returns the total canonical encoding size of the given
+// transactions, as they would be carried in a batch, together with a rough
+// estimate of the size after compression. The estimate is derived from the
+// Shannon entropy of the byte distribution of the concatenated encodings, and is
+// meant as a quick tuning aid, not as an exact prediction of any compressor.
+func EstimateBatchSize(txs []*Transaction) (rlpBytes, compressedEstimate int) {
+	var counts [256]uint64
+	for _, tx := range txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			continue
+		}
+		rlpBytes += len(data)
+		for _, b := range data {
+			counts[b]++
+		}
+	}
+	if rlpBytes == 0 {
+		return 0, 0
+	}
+	total := float64(rlpBytes)
+	var entropy float64 // bits per byte
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / total
+		entropy -= p * math.Log2(p)
+	}
+	compressedEstimate = int(math.Ceil(total * entropy / 8))
+	return rlpBytes, compressedEstimate
+}
@@ -0,0 +1,520 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	depositTo = common.HexToAddress("0x4200000000000000000000000000000000000015")
+
+	testDeposit = NewTx(&DepositTx{
+		SourceHash: common.HexToHash("0x2a6a4f0ab5e6fd2e6ad0fd5c8e8ab6e5cd85ac7c0d71e1e8a0d2b4e0b35f41c1"),
+		From:       testAddr,
+		To:         &depositTo,
+		Mint:       big.NewInt(1000),
+		Value:      big.NewInt(10),
+		Gas:        50000,
+		Data:       common.FromHex("5544"),
+	})
+)
+
+func TestEstimateBatchSize(t *testing.T) {
+	txs := []*Transaction{
+		testDeposit,
+		NewTx(&DepositTx{
+			From:  testAddr,
+			Value: new(big.Int),
+			Gas:   1_000_000,
+			Data:  bytes.Repeat([]byte{0xff}, 1000),
+		}),
+		signedEip2718Tx,
+	}
+	var want int
+	for _, tx := range txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += len(data)
+	}
+	size, compressed := EstimateBatchSize(txs)
+	if size != want {
+		t.Errorf("encoded size mismatch: have %d, want %d", size, want)
+	}
+	if compressed <= 0 || compressed >= size {
+		t.Errorf("compressed estimate out of range: have %d, encoded size %d", compressed, size)
+	}
+	if size, compressed := EstimateBatchSize(nil); size != 0 || compressed != 0 {
+		t.Errorf("empty batch: have %d/%d, want 0/0", size, compressed)
+	}
+}
+
+func TestDepositIsSelfFunded(t *testing.T) {
+	tests := []struct {
+		name        string
+		mint, value *big.Int
+		want        bool
+	}{
+		{"self-funded", big.NewInt(100), big.NewInt(100), true},
+		{"over-funded", big.NewInt(101), big.NewInt(100), true},
+		{"under-funded", big.NewInt(99), big.NewInt(100), false},
+		{"no mint", nil, big.NewInt(1), false},
+		{"no mint, no value", nil, new(big.Int), true},
+		{"mint only", big.NewInt(100), new(big.Int), true},
+	}
+	for _, tt := range tests {
+		dep := &DepositTx{Mint: tt.mint, Value: tt.value}
+		if have := dep.IsSelfFunded(); have != tt.want {
+			t.Errorf("%s: have %v, want %v", tt.name, have, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeDeposits(t *testing.T) {
+	newDeposit := func(source byte, gas uint64) *Transaction {
+		return NewTx(&DepositTx{
+			SourceHash: common.Hash{source},
+			From:       testAddr,
+			Value:      new(big.Int),
+			Gas:        gas,
+		})
+	}
+	var (
+		blockA = common.Hash{0xaa}
+		blockB = common.Hash{0xbb}
+
+		d1 = newDeposit(1, 21000)
+		d2 = newDeposit(2, 21000)
+		d3 = newDeposit(3, 21000)
+		d4 = newDeposit(4, 21000)
+
+		origins = map[common.Hash]OriginInfo{
+			d1.SourceHash(): {L1BlockHash: blockA, L1BlockNumber: 10, LogIndex: 0},
+			d2.SourceHash(): {L1BlockHash: blockA, L1BlockNumber: 10, LogIndex: 3},
+			d3.SourceHash(): {L1BlockHash: blockB, L1BlockNumber: 11, LogIndex: 1},
+			d4.SourceHash(): {L1BlockHash: blockB, L1BlockNumber: 11, LogIndex: 2},
+		}
+	)
+	have, err := CanonicalizeDeposits([]*Transaction{d4, d2, d3, d1, d2, d4}, origins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*Transaction{d1, d2, d3, d4}
+	if len(have) != len(want) {
+		t.Fatalf("length mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i].Hash() != want[i].Hash() {
+			t.Errorf("deposit %d mismatch: have source %s, want %s", i, have[i].SourceHash(), want[i].SourceHash())
+		}
+	}
+
+	// Failure cases
+	conflicting := newDeposit(1, 50000)
+	if _, err := CanonicalizeDeposits([]*Transaction{d1, conflicting}, origins); !errors.Is(err, ErrDepositSourceConflict) {
+		t.Errorf("source conflict: have %v, want %v", err, ErrDepositSourceConflict)
+	}
+	if _, err := CanonicalizeDeposits([]*Transaction{d1, newDeposit(5, 21000)}, origins); !errors.Is(err, ErrDepositOriginUnknown) {
+		t.Errorf("unknown origin: have %v, want %v", err, ErrDepositOriginUnknown)
+	}
+	if _, err := CanonicalizeDeposits([]*Transaction{d1, signedEip2718Tx}, origins); !errors.Is(err, ErrNotDeposit) {
+		t.Errorf("non-deposit: have %v, want %v", err, ErrNotDeposit)
+	}
+	d5 := newDeposit(5, 21000)
+	reorged := map[common.Hash]OriginInfo{
+		d1.SourceHash(): origins[d1.SourceHash()],
+		d5.SourceHash(): {L1BlockHash: blockB, L1BlockNumber: 10, LogIndex: 4},
+	}
+	if _, err := CanonicalizeDeposits([]*Transaction{d1, d5}, reorged); !errors.Is(err, ErrDepositOriginConflict) {
+		t.Errorf("block hash conflict: have %v, want %v", err, ErrDepositOriginConflict)
+	}
+	samePosition := map[common.Hash]OriginInfo{
+		d1.SourceHash(): origins[d1.SourceHash()],
+		d5.SourceHash(): origins[d1.SourceHash()],
+	}
+	if _, err := CanonicalizeDeposits([]*Transaction{d1, d5}, samePosition); !errors.Is(err, ErrDepositOriginConflict) {
+		t.Errorf("position conflict: have %v, want %v", err, ErrDepositOriginConflict)
+	}
+}
+
+func TestAppliedSet(t *testing.T) {
+	var (
+		set AppliedSet
+		a   = &DepositTx{SourceHash: common.Hash{1}}
+		b   = &DepositTx{SourceHash: common.Hash{2}}
+	)
+	if a.AppliedMarker() != a.SourceHash {
+		t.Fatalf("applied marker mismatch: have %s, want %s", a.AppliedMarker(), a.SourceHash)
+	}
+	if set.Contains(a.AppliedMarker()) {
+		t.Fatal("empty set contains marker")
+	}
+	set.Add(a.AppliedMarker())
+	set.Add(a.AppliedMarker())
+	if !set.Contains(a.AppliedMarker()) {
+		t.Error("set is missing added marker")
+	}
+	if set.Contains(b.AppliedMarker()) {
+		t.Error("set contains marker that was not added")
+	}
+	if set.Len() != 1 {
+		t.Errorf("set length mismatch: have %d, want 1", set.Len())
+	}
+}
+
+func TestDepositWithNewSource(t *testing.T) {
+	var (
+		orig        = testDeposit.inner.(*DepositTx)
+		l1BlockHash = common.HexToHash("0xbeef")
+		logIndex    = uint64(7)
+	)
+	resourced := orig.WithNewSource(l1BlockHash, logIndex)
+	if have, want := resourced.SourceHash(), UserDepositSourceHash(l1BlockHash, logIndex); have != want {
+		t.Fatalf("source hash mismatch: have %s, want %s", have, want)
+	}
+	if resourced.Hash() == testDeposit.Hash() {
+		t.Fatal("re-sourced deposit has the same hash as the original")
+	}
+	// All other fields must be preserved, and the original must be untouched.
+	cpy := *resourced.inner.(*DepositTx)
+	cpy.SourceHash = orig.SourceHash
+	if NewTx(&cpy).Hash() != testDeposit.Hash() {
+		t.Error("re-sourcing modified fields other than the source hash")
+	}
+	if testDeposit.SourceHash() != orig.SourceHash {
+		t.Error("re-sourcing modified the original deposit")
+	}
+}
+
+func TestDepositShortID(t *testing.T) {
+	dep := &DepositTx{SourceHash: common.HexToHash("0x2a6a4f0ab5e6fd2e6ad0fd5c8e8ab6e5cd85ac7c0d71e1e8a0d2b4e0b35f41c1")}
+	if have, want := dep.ShortID(), "dep:2a6a4f0a"; have != want {
+		t.Errorf("short id mismatch: have %s, want %s", have, want)
+	}
+}
+
+// depositTestMatrix is a representative set of deposits, covering contract
+// creation, minting, empty fields and extreme values.
+var depositTestMatrix = []*DepositTx{
+	{
+		SourceHash: common.HexToHash("0x01"),
+		From:       testAddr,
+		To:         &depositTo,
+		Mint:       big.NewInt(1000),
+		Value:      big.NewInt(10),
+		Gas:        50000,
+		Data:       common.FromHex("5544"),
+	},
+	{ // contract creation, no mint
+		SourceHash: common.HexToHash("0x02"),
+		From:       testAddr,
+		Value:      new(big.Int),
+		Gas:        1_000_000,
+		Data:       common.FromHex("6080604052"),
+	},
+	{ // mint only, empty data
+		SourceHash: common.HexToHash("0x03"),
+		From:       testAddr,
+		To:         &testAddr,
+		Mint:       big.NewInt(1),
+		Value:      new(big.Int),
+		Gas:        21000,
+	},
+	{ // system transaction
+		SourceHash:          common.HexToHash("0x04"),
+		From:                common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+		To:                  &depositTo,
+		Value:               new(big.Int),
+		Gas:                 150_000_000,
+		IsSystemTransaction: true,
+		Data:                bytes.Repeat([]byte{0xab}, 260),
+	},
+	{ // extreme values
+		SourceHash: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		From:       common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"),
+		To:         &depositTo,
+		Mint:       new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1),
+		Value:      new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1),
+		Gas:        math.MaxUint64,
+	},
+}
+
+// AssertDepositRoundTrip encodes the deposit to JSON and to its binary encoding,
+// decodes both, and asserts that the original and both decoded deposits are equal.
+// Nil To and Mint fields must stay nil, while empty data is equal to nil data.
+func AssertDepositRoundTrip(t *testing.T, tx *Transaction) {
+	t.Helper()
+	orig, ok := tx.inner.(*DepositTx)
+	if !ok {
+		t.Fatalf("not a deposit transaction: type %d", tx.Type())
+	}
+	fromJSON, err := encodeDecodeJSON(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary, err := encodeDecodeBinary(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, dec := range map[string]*Transaction{"json": fromJSON, "binary": fromBinary} {
+		if err := assertEqual(tx, dec); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		cpy, ok := dec.inner.(*DepositTx)
+		if !ok {
+			t.Errorf("%s: decoded as type %d", name, dec.Type())
+			continue
+		}
+		if err := assertDepositEqual(orig, cpy); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func assertDepositEqual(orig, cpy *DepositTx) error {
+	if orig.SourceHash != cpy.SourceHash {
+		return fmt.Errorf("source hash mismatch: want %s, got %s", orig.SourceHash, cpy.SourceHash)
+	}
+	if orig.From != cpy.From {
+		return fmt.Errorf("from mismatch: want %s, got %s", orig.From, cpy.From)
+	}
+	if (orig.To == nil) != (cpy.To == nil) || (orig.To != nil && *orig.To != *cpy.To) {
+		return fmt.Errorf("to mismatch: want %v, got %v", orig.To, cpy.To)
+	}
+	if (orig.Mint == nil) != (cpy.Mint == nil) || (orig.Mint != nil && orig.Mint.Cmp(cpy.Mint) != 0) {
+		return fmt.Errorf("mint mismatch: want %v, got %v", orig.Mint, cpy.Mint)
+	}
+	if orig.Value.Cmp(cpy.Value) != 0 {
+		return fmt.Errorf("value mismatch: want %v, got %v", orig.Value, cpy.Value)
+	}
+	if orig.Gas != cpy.Gas {
+		return fmt.Errorf("gas mismatch: want %d, got %d", orig.Gas, cpy.Gas)
+	}
+	if orig.IsSystemTransaction != cpy.IsSystemTransaction {
+		return fmt.Errorf("system tx mismatch: want %v, got %v", orig.IsSystemTransaction, cpy.IsSystemTransaction)
+	}
+	if !bytes.Equal(orig.Data, cpy.Data) {
+		return fmt.Errorf("data mismatch: want %x, got %x", orig.Data, cpy.Data)
+	}
+	return nil
+}
+
+func TestDepositRoundTrip(t *testing.T) {
+	for i, dep := range depositTestMatrix {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			AssertDepositRoundTrip(t, NewTx(dep))
+		})
+	}
+}
+
+// Tests that a zero mint decodes the same way from JSON and binary.
+func TestDepositZeroMintDecoding(t *testing.T) {
+	tx := NewTx(&DepositTx{
+		SourceHash: common.HexToHash("0x05"),
+		From:       testAddr,
+		Mint:       new(big.Int),
+		Value:      new(big.Int),
+		Gas:        21000,
+	})
+	fromJSON, err := encodeDecodeJSON(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromBinary, err := encodeDecodeBinary(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromJSON.Mint() != nil || fromBinary.Mint() != nil {
+		t.Errorf("zero mint not decoded as nil: json %v, binary %v", fromJSON.Mint(), fromBinary.Mint())
+	}
+	if fromJSON.Hash() != tx.Hash() || fromBinary.Hash() != tx.Hash() {
+		t.Error("zero mint decoding changed the transaction hash")
+	}
+}
+
+// Tests decoding of deposits in the JSON format served over RPC, where the
+// fields of other transaction types are present as null.
+func TestDepositUnmarshalJSON(t *testing.T) {
+	input := `{
+		"type": "0x7e",
+		"sourceHash": "0x0000000000000000000000000000000000000000000000000000000000000001",
+		"from": "0xb94f5374fce5edbc8e2a8697c15331677e6ebf0b",
+		"to": null,
+		"mint": "0x3e8",
+		"value": "0xa",
+		"gas": "0xc350",
+		"isSystemTx": false,
+		"input": "0x5544",
+		"nonce": "0x0",
+		"gasPrice": null,
+		"v": null,
+		"r": null,
+		"s": null,
+		"hash": "0x0000000000000000000000000000000000000000000000000000000000000000"
+	}`
+	var tx Transaction
+	if err := json.Unmarshal([]byte(input), &tx); err != nil {
+		t.Fatalf("failed to decode deposit: %v", err)
+	}
+	want := NewTx(&DepositTx{
+		SourceHash: common.HexToHash("0x01"),
+		From:       testAddr,
+		Mint:       big.NewInt(1000),
+		Value:      big.NewInt(10),
+		Gas:        50000,
+		Data:       common.FromHex("5544"),
+	})
+	if tx.Hash() != want.Hash() {
+		t.Errorf("decoded deposit mismatch: have %s, want %s", tx.Hash(), want.Hash())
+	}
+
+	// Required fields must be present.
+	for _, field := range []string{"gas", "value", "input", "from", "sourceHash"} {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(input), &fields); err != nil {
+			t.Fatal(err)
+		}
+		delete(fields, field)
+		data, _ := json.Marshal(fields)
+		if err := json.Unmarshal(data, new(Transaction)); err == nil {
+			t.Errorf("missing field %q: expected error", field)
+		}
+	}
+	// Fields of other transaction types must be absent.
+	for _, field := range []string{`"gasPrice": "0x1"`, `"nonce": "0x1"`, `"v": "0x1"`} {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(input), &fields); err != nil {
+			t.Fatal(err)
+		}
+		var extra map[string]interface{}
+		if err := json.Unmarshal([]byte("{"+field+"}"), &extra); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range extra {
+			fields[k] = v
+		}
+		data, _ := json.Marshal(fields)
+		if err := json.Unmarshal(data, new(Transaction)); err == nil {
+			t.Errorf("unexpected field %s: expected error", field)
+		}
+	}
+}
+
+// Deposit encoding test vectors. Other implementations must encode these
+// deposits to exactly the same bytes, and decode the bytes to the same deposits.
+var depositEncodingVectors = []struct {
+	name string
+	tx   *DepositTx
+	enc  string
+	hash common.Hash
+}{
+	{
+		name: "call with mint",
+		tx: &DepositTx{
+			SourceHash: common.HexToHash("0x01"),
+			From:       common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+			To:         &depositTo,
+			Mint:       big.NewInt(1e18),
+			Value:      big.NewInt(1e17),
+			Gas:        100000,
+			Data:       common.FromHex("0xcafe"),
+		},
+		enc:  "0x7ef865a0000000000000000000000000000000000000000000000000000000000000000194deaddeaddeaddeaddeaddeaddeaddeaddead0001944200000000000000000000000000000000000015880de0b6b3a764000088016345785d8a0000830186a08082cafe",
+		hash: common.HexToHash("0x0789be1c2357af567edaca7a314fccb9c70588cf0aa9841e20caa5499248ba93"),
+	},
+	{
+		name: "contract creation, nil mint",
+		tx: &DepositTx{
+			SourceHash: common.HexToHash("0x02"),
+			From:       common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+			Value:      new(big.Int),
+			Gas:        1000000,
+			Data:       common.FromHex("0x6080604052"),
+		},
+		enc:  "0x7ef844a0000000000000000000000000000000000000000000000000000000000000000294deaddeaddeaddeaddeaddeaddeaddeaddead0001808080830f424080856080604052",
+		hash: common.HexToHash("0x455606a053bee7be24dec9fcc3056b65ebd27fc1735f7c1652c2fec63c044376"),
+	},
+	{
+		name: "empty data",
+		tx: &DepositTx{
+			SourceHash: common.HexToHash("0x03"),
+			From:       common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+			To:         &depositTo,
+			Value:      new(big.Int),
+			Gas:        21000,
+		},
+		enc:  "0x7ef852a0000000000000000000000000000000000000000000000000000000000000000394deaddeaddeaddeaddeaddeaddeaddeaddead000194420000000000000000000000000000000000001580808252088080",
+		hash: common.HexToHash("0xb04be6c009cab3946794a05e44e76db1bb106318457057cb06e359d538aaefe4"),
+	},
+	{
+		name: "zero values",
+		tx: &DepositTx{
+			SourceHash: common.HexToHash("0x04"),
+			Value:      new(big.Int),
+		},
+		enc:  "0x7ef83ca00000000000000000000000000000000000000000000000000000000000000004940000000000000000000000000000000000000000808080808080",
+		hash: common.HexToHash("0x0d836e2e2b807721bec5bef73ecc193b9a7f2afd174017fb7a30456f6745f964"),
+	},
+	{
+		name: "max gas system transaction",
+		tx: &DepositTx{
+			SourceHash:          common.HexToHash("0x05"),
+			From:                common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+			To:                  &depositTo,
+			Value:               new(big.Int),
+			Gas:                 math.MaxUint64,
+			IsSystemTransaction: true,
+		},
+		enc:  "0x7ef858a0000000000000000000000000000000000000000000000000000000000000000594deaddeaddeaddeaddeaddeaddeaddeaddead0001944200000000000000000000000000000000000015808088ffffffffffffffff0180",
+		hash: common.HexToHash("0x1dcd84dd0268a17e6cc9fe728a5fafcc11e2207e5e090f9744466a76a6bf1571"),
+	},
+}
+
+func TestDepositEncodingVectors(t *testing.T) {
+	for _, v := range depositEncodingVectors {
+		tx := NewTx(v.tx)
+		enc, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if hexutil.Encode(enc) != v.enc {
+			t.Errorf("%s: encoding mismatch:\nhave %x\nwant %s", v.name, enc, v.enc)
+		}
+		if tx.Hash() != v.hash {
+			t.Errorf("%s: hash mismatch: have %s, want %s", v.name, tx.Hash(), v.hash)
+		}
+		var dec Transaction
+		if err := dec.UnmarshalBinary(common.FromHex(v.enc)); err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if err := assertDepositEqual(v.tx, dec.inner.(*DepositTx)); err != nil {
+			t.Errorf("%s: %v", v.name, err)
+		}
+	}
+}
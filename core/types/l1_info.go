@@ -0,0 +1,162 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// L1InfoFuncSignature is the signature of the L1Block predeploy function that is
+// called by the L1 info deposit at the start of every L2 block.
+const L1InfoFuncSignature = "setL1BlockValues(uint64,uint64,uint256,bytes32,uint64)"
+
+// L1InfoArgumentsLength is the length of the ABI encoded arguments of the L1 info
+// function call, excluding the function selector.
+const L1InfoArgumentsLength = 5 * 32
+
+// L1InfoDepositGas is the gas limit of the L1 info deposit. The deposit is a
+// system transaction, so its gas is not charged against the block.
+const L1InfoDepositGas = 150_000_000
+
+// L1InfoFuncBytes4 is the function selector of the L1 info function.
+var L1InfoFuncBytes4 = crypto.Keccak256([]byte(L1InfoFuncSignature))[:4]
+
+var (
+	// L1InfoDepositerAddress is the sender of the L1 info deposit.
+	L1InfoDepositerAddress = common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001")
+	// L1BlockAddr is the address of the L1Block predeploy, which stores the
+	// attributes of the current L1 origin.
+	L1BlockAddr = common.HexToAddress("0x4200000000000000000000000000000000000015")
+)
+
+var errInvalidL1Info = errors.New("invalid L1 info deposit data")
+
+// L1BlockInfo holds the attributes of the L1 origin of an L2 block, as set by
+// the L1 info deposit of the block.
+type L1BlockInfo struct {
+	Number         uint64
+	Time           uint64
+	BaseFee        *big.Int
+	BlockHash      common.Hash
+	SequenceNumber uint64 // number of L2 blocks since the start of the epoch
+}
+
+// L1BlockInfoFromHeader returns the L1 info of the given L1 origin header, for
+// the L2 block with the given sequence number within the epoch.
+func L1BlockInfoFromHeader(header *Header, seqNumber uint64) *L1BlockInfo {
+	info := &L1BlockInfo{
+		Number:         header.Number.Uint64(),
+		Time:           header.Time,
+		BaseFee:        new(big.Int),
+		BlockHash:      header.Hash(),
+		SequenceNumber: seqNumber,
+	}
+	if header.BaseFee != nil {
+		info.BaseFee.Set(header.BaseFee)
+	}
+	return info
+}
+
+// MarshalBinary encodes the L1 info as the calldata of the L1 info deposit.
+func (info *L1BlockInfo) MarshalBinary() ([]byte, error) {
+	if info.BaseFee != nil && (info.BaseFee.Sign() < 0 || info.BaseFee.BitLen() > 256) {
+		return nil, fmt.Errorf("%w: base fee %v out of range", errInvalidL1Info, info.BaseFee)
+	}
+	data := make([]byte, 4+L1InfoArgumentsLength)
+	copy(data, L1InfoFuncBytes4)
+	args := data[4:]
+	binary.BigEndian.PutUint64(args[32-8:32], info.Number)
+	binary.BigEndian.PutUint64(args[64-8:64], info.Time)
+	if info.BaseFee != nil {
+		info.BaseFee.FillBytes(args[64:96])
+	}
+	copy(args[96:128], info.BlockHash[:])
+	binary.BigEndian.PutUint64(args[160-8:160], info.SequenceNumber)
+	return data, nil
+}
+
+// NewL1InfoDepositTx creates the L1 info deposit, the first transaction of every
+// L2 block, which calls the L1Block predeploy to make the attributes of the L1
+// origin available on L2.
+func NewL1InfoDepositTx(l1Origin *Header, seqNumber uint64) (*Transaction, error) {
+	dep, err := NewL1InfoDeposit(l1Origin, seqNumber)
+	if err != nil {
+		return nil, err
+	}
+	return NewTx(dep), nil
+}
+
+// NewL1InfoDeposit is like NewL1InfoDepositTx, but returns the deposit before it
+// is wrapped into a transaction.
+func NewL1InfoDeposit(l1Origin *Header, seqNumber uint64) (*DepositTx, error) {
+	info := L1BlockInfoFromHeader(l1Origin, seqNumber)
+	data, err := info.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	to := L1BlockAddr
+	return &DepositTx{
+		SourceHash:          L1InfoDepositSourceHash(info.BlockHash, seqNumber),
+		From:                L1InfoDepositerAddress,
+		To:                  &to,
+		Value:               new(big.Int),
+		Gas:                 L1InfoDepositGas,
+		IsSystemTransaction: true,
+		Data:                data,
+	}, nil
+}
+
+// UnmarshalBinary decodes the calldata of an L1 info deposit.
+func (info *L1BlockInfo) UnmarshalBinary(data []byte) error {
+	if len(data) != 4+L1InfoArgumentsLength {
+		return fmt.Errorf("%w: data has length %d", errInvalidL1Info, len(data))
+	}
+	if !bytes.Equal(data[:4], L1InfoFuncBytes4) {
+		return fmt.Errorf("%w: function selector %x", errInvalidL1Info, data[:4])
+	}
+	var err error
+	args := data[4:]
+	if info.Number, err = readUint64Word(args[0:32]); err != nil {
+		return fmt.Errorf("%w: number: %v", errInvalidL1Info, err)
+	}
+	if info.Time, err = readUint64Word(args[32:64]); err != nil {
+		return fmt.Errorf("%w: time: %v", errInvalidL1Info, err)
+	}
+	info.BaseFee = new(big.Int).SetBytes(args[64:96])
+	info.BlockHash = common.BytesToHash(args[96:128])
+	if info.SequenceNumber, err = readUint64Word(args[128:160]); err != nil {
+		return fmt.Errorf("%w: sequence number: %v", errInvalidL1Info, err)
+	}
+	return nil
+}
+
+// readUint64Word reads an ABI encoded uint64 from a 32 byte word.
+func readUint64Word(word []byte) (uint64, error) {
+	for _, b := range word[:24] {
+		if b != 0 {
+			return 0, errors.New("value exceeds 64 bits")
+		}
+	}
+	return binary.BigEndian.Uint64(word[24:]), nil
+}
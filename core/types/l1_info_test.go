@@ -0,0 +1,133 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// encodeL1InfoForTest ABI encodes the L1 info function call.
+func encodeL1InfoForTest(info *L1BlockInfo) []byte {
+	data := make([]byte, 4+L1InfoArgumentsLength)
+	copy(data, L1InfoFuncBytes4)
+	new(big.Int).SetUint64(info.Number).FillBytes(data[4 : 4+32])
+	new(big.Int).SetUint64(info.Time).FillBytes(data[4+32 : 4+64])
+	info.BaseFee.FillBytes(data[4+64 : 4+96])
+	copy(data[4+96:4+128], info.BlockHash[:])
+	new(big.Int).SetUint64(info.SequenceNumber).FillBytes(data[4+128 : 4+160])
+	return data
+}
+
+func TestL1BlockInfoUnmarshalBinary(t *testing.T) {
+	want := &L1BlockInfo{
+		Number:         123,
+		Time:           1655000000,
+		BaseFee:        big.NewInt(7_000_000_000),
+		BlockHash:      common.HexToHash("0xdeadbeef"),
+		SequenceNumber: 4,
+	}
+	data := encodeL1InfoForTest(want)
+	var have L1BlockInfo
+	if err := have.UnmarshalBinary(data); err != nil {
+		t.Fatalf("failed to decode L1 info: %v", err)
+	}
+	if have.Number != want.Number || have.Time != want.Time || have.BaseFee.Cmp(want.BaseFee) != 0 ||
+		have.BlockHash != want.BlockHash || have.SequenceNumber != want.SequenceNumber {
+		t.Errorf("decoded L1 info mismatch: have %+v, want %+v", have, want)
+	}
+
+	// Invalid inputs
+	if err := have.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("expected error for short data")
+	}
+	bad := common.CopyBytes(data)
+	bad[0] ^= 0xff
+	if err := have.UnmarshalBinary(bad); err == nil {
+		t.Error("expected error for wrong function selector")
+	}
+	bad = common.CopyBytes(data)
+	bad[4] = 1 // number exceeding 64 bits
+	if err := have.UnmarshalBinary(bad); err == nil {
+		t.Error("expected error for oversized number")
+	}
+}
+
+func TestNewL1InfoDepositTx(t *testing.T) {
+	header := &Header{
+		ParentHash: common.HexToHash("0x01"),
+		Number:     big.NewInt(123),
+		Time:       1655000000,
+		BaseFee:    big.NewInt(7_000_000_000),
+		Difficulty: new(big.Int),
+	}
+	tx, err := NewL1InfoDepositTx(header, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Type() != DepositTxType {
+		t.Fatalf("wrong tx type %d", tx.Type())
+	}
+	dep := tx.inner.(*DepositTx)
+	if dep.From != L1InfoDepositerAddress || *dep.To != L1BlockAddr {
+		t.Errorf("wrong sender or recipient: %s -> %s", dep.From, dep.To)
+	}
+	if !dep.IsSystemTransaction || dep.Gas != L1InfoDepositGas || dep.Mint != nil || dep.Value.Sign() != 0 {
+		t.Errorf("unexpected deposit fields: %+v", dep)
+	}
+	if want := L1InfoDepositSourceHash(header.Hash(), 4); dep.SourceHash != want {
+		t.Errorf("wrong source hash: have %s, want %s", dep.SourceHash, want)
+	}
+	// The calldata matches an independent encoding and decodes to the header.
+	want := L1BlockInfoFromHeader(header, 4)
+	if !bytes.Equal(tx.Data(), encodeL1InfoForTest(want)) {
+		t.Errorf("calldata mismatch:\nhave %x\nwant %x", tx.Data(), encodeL1InfoForTest(want))
+	}
+	var info L1BlockInfo
+	if err := info.UnmarshalBinary(tx.Data()); err != nil {
+		t.Fatal(err)
+	}
+	if info.Number != 123 || info.Time != header.Time || info.BaseFee.Cmp(header.BaseFee) != 0 ||
+		info.BlockHash != header.Hash() || info.SequenceNumber != 4 {
+		t.Errorf("decoded L1 info mismatch: %+v", info)
+	}
+	AssertDepositRoundTrip(t, tx)
+
+	// Pre-London L1 headers have no base fee.
+	header.BaseFee = nil
+	tx, err = NewL1InfoDepositTx(header, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := info.UnmarshalBinary(tx.Data()); err != nil || info.BaseFee.Sign() != 0 {
+		t.Errorf("unexpected base fee %v (err %v)", info.BaseFee, err)
+	}
+}
+
+func TestL1BlockInfoMarshalBinaryInvalid(t *testing.T) {
+	info := &L1BlockInfo{BaseFee: big.NewInt(-1)}
+	if _, err := info.MarshalBinary(); err == nil {
+		t.Error("expected error for negative base fee")
+	}
+	info.BaseFee = new(big.Int).Lsh(common.Big1, 256)
+	if _, err := info.MarshalBinary(); err == nil {
+		t.Error("expected error for oversized base fee")
+	}
+}
@@ -58,6 +58,11 @@ type Receipt struct {
 	Bloom             Bloom  `json:"logsBloom"         gencodec:"required"`
 	Logs              []*Log `json:"logs"              gencodec:"required"`
 
+	// DepositNonce is the nonce of the sender of a deposit transaction before
+	// it was executed. It is only set for deposits from Regolith onwards, and is
+	// then part of the consensus encoding of the receipt.
+	DepositNonce *uint64 `json:"depositNonce,omitempty"`
+
 	// Implementation fields: These fields are added by geth when processing a transaction.
 	// They are stored in the chain database.
 	TxHash          common.Hash    `json:"transactionHash" gencodec:"required"`
@@ -69,6 +74,13 @@ type Receipt struct {
 	BlockHash        common.Hash `json:"blockHash,omitempty"`
 	BlockNumber      *big.Int    `json:"blockNumber,omitempty"`
 	TransactionIndex uint        `json:"transactionIndex"`
+
+	// L1 origin information: These fields are only set for deposit transactions,
+	// and are derived from the L1 info deposit at the start of the block. The
+	// L1 log that emitted a user deposit is looked up by its source hash in the
+	// deposit index of the rollup node.
+	L1BlockHash   *common.Hash `json:"l1BlockHash,omitempty"`
+	L1BlockNumber *big.Int     `json:"l1BlockNumber,omitempty"`
 }
 
 type receiptMarshaling struct {
@@ -79,6 +91,8 @@ type receiptMarshaling struct {
 	GasUsed           hexutil.Uint64
 	BlockNumber       *hexutil.Big
 	TransactionIndex  hexutil.Uint
+	L1BlockNumber     *hexutil.Big
+	DepositNonce      *hexutil.Uint64
 }
 
 // receiptRLP is the consensus encoding of a receipt.
@@ -89,11 +103,22 @@ type receiptRLP struct {
 	Logs              []*Log
 }
 
+// depositReceiptRLP is the consensus encoding of a deposit receipt. The deposit
+// nonce is only present in receipts from Regolith onwards.
+type depositReceiptRLP struct {
+	PostStateOrStatus []byte
+	CumulativeGasUsed uint64
+	Bloom             Bloom
+	Logs              []*Log
+	DepositNonce      *uint64 `rlp:"optional"`
+}
+
 // storedReceiptRLP is the storage encoding of a receipt.
 type storedReceiptRLP struct {
 	PostStateOrStatus []byte
 	CumulativeGasUsed uint64
 	Logs              []*LogForStorage
+	DepositNonce      *uint64 `rlp:"optional"`
 }
 
 // v4StoredReceiptRLP is the storage encoding of a receipt used in database version 4.
@@ -136,23 +161,31 @@ func NewReceipt(root []byte, failed bool, cumulativeGasUsed uint64) *Receipt {
 // EncodeRLP implements rlp.Encoder, and flattens the consensus fields of a receipt
 // into an RLP stream. If no post state is present, byzantium fork is assumed.
 func (r *Receipt) EncodeRLP(w io.Writer) error {
-	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
 	if r.Type == LegacyTxType {
-		return rlp.Encode(w, data)
+		return rlp.Encode(w, r.consensusRLP())
 	}
 	buf := encodeBufferPool.Get().(*bytes.Buffer)
 	defer encodeBufferPool.Put(buf)
 	buf.Reset()
-	if err := r.encodeTyped(data, buf); err != nil {
+	if err := r.encodeTyped(buf); err != nil {
 		return err
 	}
 	return rlp.Encode(w, buf.Bytes())
 }
 
+// consensusRLP returns the consensus fields of the receipt, in the encoding of
+// its type.
+func (r *Receipt) consensusRLP() interface{} {
+	if r.Type == DepositTxType {
+		return &depositReceiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs, r.DepositNonce}
+	}
+	return &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+}
+
 // encodeTyped writes the canonical encoding of a typed receipt to w.
-func (r *Receipt) encodeTyped(data *receiptRLP, w *bytes.Buffer) error {
+func (r *Receipt) encodeTyped(w *bytes.Buffer) error {
 	w.WriteByte(r.Type)
-	return rlp.Encode(w, data)
+	return rlp.Encode(w, r.consensusRLP())
 }
 
 // MarshalBinary returns the consensus encoding of the receipt.
@@ -160,9 +193,8 @@ func (r *Receipt) MarshalBinary() ([]byte, error) {
 	if r.Type == LegacyTxType {
 		return rlp.EncodeToBytes(r)
 	}
-	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
 	var buf bytes.Buffer
-	err := r.encodeTyped(data, &buf)
+	err := r.encodeTyped(&buf)
 	return buf.Bytes(), err
 }
 
@@ -214,7 +246,7 @@ func (r *Receipt) decodeTyped(b []byte) error {
 		return errShortTypedReceipt
 	}
 	switch b[0] {
-	case DynamicFeeTxType, AccessListTxType, DepositTxType:
+	case DynamicFeeTxType, AccessListTxType:
 		var data receiptRLP
 		err := rlp.DecodeBytes(b[1:], &data)
 		if err != nil {
@@ -222,6 +254,15 @@ func (r *Receipt) decodeTyped(b []byte) error {
 		}
 		r.Type = b[0]
 		return r.setFromRLP(data)
+	case DepositTxType:
+		var data depositReceiptRLP
+		err := rlp.DecodeBytes(b[1:], &data)
+		if err != nil {
+			return err
+		}
+		r.Type = b[0]
+		r.DepositNonce = data.DepositNonce
+		return r.setFromRLP(receiptRLP{data.PostStateOrStatus, data.CumulativeGasUsed, data.Bloom, data.Logs})
 	default:
 		return ErrTxTypeNotSupported
 	}
@@ -285,6 +326,9 @@ func (r *ReceiptForStorage) EncodeRLP(_w io.Writer) error {
 		}
 	}
 	w.ListEnd(logList)
+	if r.DepositNonce != nil {
+		w.WriteUint64(*r.DepositNonce)
+	}
 	w.ListEnd(outerList)
 	return w.Flush()
 }
@@ -323,6 +367,7 @@ func decodeStoredReceiptRLP(r *ReceiptForStorage, blob []byte) error {
 		r.Logs[i] = (*Log)(log)
 	}
 	r.Bloom = CreateBloom(Receipts{(*Receipt)(r)})
+	r.DepositNonce = stored.DepositNonce
 
 	return nil
 }
@@ -377,7 +422,7 @@ func (rs Receipts) Len() int { return len(rs) }
 // EncodeIndex encodes the i'th receipt to w.
 func (rs Receipts) EncodeIndex(i int, w *bytes.Buffer) {
 	r := rs[i]
-	data := &receiptRLP{r.statusEncoding(), r.CumulativeGasUsed, r.Bloom, r.Logs}
+	data := r.consensusRLP()
 	switch r.Type {
 	case LegacyTxType:
 		rlp.Encode(w, data)
@@ -406,6 +451,15 @@ func (rs Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, nu
 	if len(txs) != len(rs) {
 		return errors.New("transaction and receipt count mismatch")
 	}
+	// Deposits are attributed to the L1 origin of the block, as set by the
+	// L1 info deposit at the start of the block.
+	var l1Info *L1BlockInfo
+	if len(txs) > 0 && txs[0].Type() == DepositTxType {
+		info := new(L1BlockInfo)
+		if err := info.UnmarshalBinary(txs[0].Data()); err == nil {
+			l1Info = info
+		}
+	}
 	for i := 0; i < len(rs); i++ {
 		// The transaction type and hash can be retrieved from the transaction itself
 		rs[i].Type = txs[i].Type()
@@ -416,11 +470,18 @@ func (rs Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, nu
 		rs[i].BlockNumber = new(big.Int).SetUint64(number)
 		rs[i].TransactionIndex = uint(i)
 
-		// The contract address can be derived from the transaction itself
-		if txs[i].To() == nil {
+		// The contract address can be derived from the transaction itself.
+		// Deposits do not carry a nonce, the one they were executed with is
+		// recorded in the receipt from Regolith onwards. The address of the
+		// contracts created by earlier deposits is unknown.
+		if txs[i].To() == nil && (txs[i].Type() != DepositTxType || rs[i].DepositNonce != nil) {
 			// Deriving the signer is expensive, only do if it's actually needed
 			from, _ := Sender(signer, txs[i])
-			rs[i].ContractAddress = crypto.CreateAddress(from, txs[i].Nonce())
+			nonce := txs[i].Nonce()
+			if rs[i].DepositNonce != nil {
+				nonce = *rs[i].DepositNonce
+			}
+			rs[i].ContractAddress = crypto.CreateAddress(from, nonce)
 		}
 		// The used gas can be calculated based on previous r
 		if i == 0 {
@@ -428,6 +489,11 @@ func (rs Receipts) DeriveFields(config *params.ChainConfig, hash common.Hash, nu
 		} else {
 			rs[i].GasUsed = rs[i].CumulativeGasUsed - rs[i-1].CumulativeGasUsed
 		}
+		if l1Info != nil && txs[i].Type() == DepositTxType {
+			l1Hash := l1Info.BlockHash
+			rs[i].L1BlockHash = &l1Hash
+			rs[i].L1BlockNumber = new(big.Int).SetUint64(l1Info.Number)
+		}
 		// The derived log fields can simply be set from the block and transaction
 		for j := 0; j < len(rs[i].Logs); j++ {
 			rs[i].Logs[j].BlockNumber = number
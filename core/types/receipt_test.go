@@ -337,6 +337,146 @@ func TestDeriveFields(t *testing.T) {
 	}
 }
 
+// Tests that deposit receipts are annotated with their L1 origin.
+func TestDeriveFieldsDeposits(t *testing.T) {
+	var (
+		l1Hash = common.HexToHash("0xdeadbeef")
+		l1Info = &L1BlockInfo{Number: 1234, Time: 5678, BaseFee: big.NewInt(7), BlockHash: l1Hash}
+		to     = common.HexToAddress("0x2")
+	)
+	txs := Transactions{
+		NewTx(&DepositTx{
+			SourceHash:          common.HexToHash("0x01"),
+			From:                common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+			To:                  &to,
+			Value:               new(big.Int),
+			Gas:                 1_000_000,
+			IsSystemTransaction: true,
+			Data:                encodeL1InfoForTest(l1Info),
+		}),
+		NewTx(&DepositTx{
+			SourceHash: UserDepositSourceHash(l1Hash, 3),
+			From:       common.HexToAddress("0x1"),
+			To:         &to,
+			Value:      new(big.Int),
+			Gas:        50000,
+		}),
+		NewTx(&DepositTx{
+			SourceHash: ChainDepositSourceHash(params.TestChainConfig.ChainID, UserDepositSourceHash(l1Hash, 17)),
+			From:       common.HexToAddress("0x1"),
+			To:         &to,
+			Value:      new(big.Int),
+			Gas:        50000,
+		}),
+		NewTx(&LegacyTx{
+			To:       &to,
+			Nonce:    1,
+			Value:    big.NewInt(1),
+			Gas:      21000,
+			GasPrice: big.NewInt(1),
+		}),
+	}
+	receipts := Receipts{
+		{Type: DepositTxType, CumulativeGasUsed: 0, Logs: []*Log{}},
+		{Type: DepositTxType, CumulativeGasUsed: 50000, Logs: []*Log{}},
+		{Type: DepositTxType, CumulativeGasUsed: 100000, Logs: []*Log{}},
+		{Type: LegacyTxType, CumulativeGasUsed: 121000, Logs: []*Log{}},
+	}
+	if err := receipts.DeriveFields(params.TestChainConfig, common.Hash{1}, 1, txs); err != nil {
+		t.Fatalf("DeriveFields(...) = %v, want <nil>", err)
+	}
+	for i, r := range receipts[:3] {
+		if r.L1BlockHash == nil || *r.L1BlockHash != l1Hash {
+			t.Errorf("receipt %d: L1 block hash mismatch: have %v, want %s", i, r.L1BlockHash, l1Hash)
+		}
+		if r.L1BlockNumber == nil || r.L1BlockNumber.Uint64() != l1Info.Number {
+			t.Errorf("receipt %d: L1 block number mismatch: have %v, want %d", i, r.L1BlockNumber, l1Info.Number)
+		}
+	}
+	if r := receipts[3]; r.L1BlockHash != nil || r.L1BlockNumber != nil {
+		t.Error("regular transaction receipt has L1 origin fields")
+	}
+
+	// JSON round trip
+	data, err := receipts[1].MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Receipt
+	if err := dec.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec.L1BlockHash, receipts[1].L1BlockHash) || dec.L1BlockNumber.Cmp(receipts[1].L1BlockNumber) != 0 {
+		t.Errorf("L1 origin fields lost in JSON round trip: %s", data)
+	}
+}
+
+// Tests that the deposit nonce is only part of the encodings of a deposit
+// receipt if it is set, and survives them.
+func TestDepositReceiptNonce(t *testing.T) {
+	receipt := &Receipt{
+		Type:              DepositTxType,
+		Status:            ReceiptStatusSuccessful,
+		CumulativeGasUsed: 50000,
+		Logs:              []*Log{},
+	}
+	// Without a nonce, the encoding is the one of any other typed receipt.
+	have, err := receipt.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal binary error: %v", err)
+	}
+	plain, _ := rlp.EncodeToBytes(&receiptRLP{receipt.statusEncoding(), receipt.CumulativeGasUsed, receipt.Bloom, receipt.Logs})
+	if want := append([]byte{DepositTxType}, plain...); !bytes.Equal(have, want) {
+		t.Errorf("encoding without nonce mismatch: got %x want %x", have, want)
+	}
+
+	nonce := uint64(1234)
+	receipt.DepositNonce = &nonce
+	withNonce, err := receipt.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal binary error: %v", err)
+	}
+	if bytes.Equal(withNonce, have) {
+		t.Error("deposit nonce not part of the consensus encoding")
+	}
+	buf := new(bytes.Buffer)
+	Receipts{receipt}.EncodeIndex(0, buf)
+	if !bytes.Equal(withNonce, buf.Bytes()) {
+		t.Errorf("BinaryMarshal and EncodeIndex mismatch, got %x want %x", withNonce, buf.Bytes())
+	}
+	dec := new(Receipt)
+	if err := dec.UnmarshalBinary(withNonce); err != nil {
+		t.Fatalf("unmarshal binary error: %v", err)
+	}
+	if dec.DepositNonce == nil || *dec.DepositNonce != nonce {
+		t.Errorf("deposit nonce mismatch after consensus round trip: have %v, want %d", dec.DepositNonce, nonce)
+	}
+
+	// Storage round trip
+	enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+	if err != nil {
+		t.Fatalf("storage encoding error: %v", err)
+	}
+	stored := new(ReceiptForStorage)
+	if err := rlp.DecodeBytes(enc, stored); err != nil {
+		t.Fatalf("storage decoding error: %v", err)
+	}
+	if stored.DepositNonce == nil || *stored.DepositNonce != nonce {
+		t.Errorf("deposit nonce mismatch after storage round trip: have %v, want %d", stored.DepositNonce, nonce)
+	}
+
+	// The contract address of a deposit is derived from the recorded nonce.
+	from := common.HexToAddress("0x1")
+	txs := Transactions{NewTx(&DepositTx{From: from, Value: new(big.Int), Gas: 50000})}
+	receipts := Receipts{(*Receipt)(stored)}
+	if err := receipts.DeriveFields(params.TestChainConfig, common.Hash{1}, 1, txs); err != nil {
+		t.Fatalf("DeriveFields(...) = %v, want <nil>", err)
+	}
+	if want := crypto.CreateAddress(from, nonce); receipts[0].ContractAddress != want {
+		t.Errorf("contract address mismatch: have %s, want %s", receipts[0].ContractAddress, want)
+	}
+}
+
 // TestTypedReceiptEncodingDecoding reproduces a flaw that existed in the receipt
 // rlp decoder, which failed due to a shadowing error.
 func TestTypedReceiptEncodingDecoding(t *testing.T) {
@@ -481,6 +621,8 @@ func clearComputedFieldsOnReceipt(t *testing.T, receipt *Receipt) {
 	receipt.TransactionIndex = math.MaxUint32
 	receipt.ContractAddress = common.Address{}
 	receipt.GasUsed = 0
+	receipt.L1BlockHash = nil
+	receipt.L1BlockNumber = nil
 
 	clearComputedFieldsOnLogs(t, receipt.Logs)
 }
@@ -193,6 +193,11 @@ func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
 	case DepositTxType:
 		var inner DepositTx
 		err := rlp.DecodeBytes(b[1:], &inner)
+		// The rlp "nil" tag does not apply to big integers: an empty mint
+		// decodes as zero. Restore nil, as there is nothing to mint.
+		if inner.Mint != nil && inner.Mint.Sign() == 0 {
+			inner.Mint = nil
+		}
 		return &inner, err
 	default:
 		return nil, ErrTxTypeNotSupported
@@ -290,13 +290,19 @@ func (t *Transaction) UnmarshalJSON(input []byte) error {
 		if dec.To != nil {
 			itx.To = dec.To
 		}
+		if dec.Gas == nil {
+			return errors.New("missing required field 'gas' in transaction")
+		}
 		itx.Gas = uint64(*dec.Gas)
 		if dec.Value == nil {
 			return errors.New("missing required field 'value' in transaction")
 		}
 		itx.Value = (*big.Int)(dec.Value)
 		// mint may be omitted or nil if there is nothing to mint.
-		itx.Mint = (*big.Int)(dec.Mint)
+		// A zero mint is decoded as nil, matching the binary encoding.
+		if dec.Mint != nil && dec.Mint.ToInt().Sign() != 0 {
+			itx.Mint = (*big.Int)(dec.Mint)
+		}
 		if dec.Data == nil {
 			return errors.New("missing required field 'input' in transaction")
 		}
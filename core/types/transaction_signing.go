@@ -92,6 +92,9 @@ func LatestSignerForChainID(chainID *big.Int) Signer {
 
 // SignTx signs the transaction using the given signer and private key.
 func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
+	if tx.Type() == DepositTxType {
+		return nil, ErrDepositSignature
+	}
 	h := s.Hash(tx)
 	sig, err := crypto.Sign(h[:], prv)
 	if err != nil {
@@ -103,6 +106,9 @@ func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, err
 // SignNewTx creates a transaction and signs it.
 func SignNewTx(prv *ecdsa.PrivateKey, s Signer, txdata TxData) (*Transaction, error) {
 	tx := NewTx(txdata)
+	if tx.Type() == DepositTxType {
+		return nil, ErrDepositSignature
+	}
 	h := s.Hash(tx)
 	sig, err := crypto.Sign(h[:], prv)
 	if err != nil {
@@ -128,7 +134,13 @@ func MustSignNewTx(prv *ecdsa.PrivateKey, s Signer, txdata TxData) *Transaction
 // Sender may cache the address, allowing it to be used regardless of
 // signing method. The cache is invalidated if the cached signer does
 // not match the signer used in the current call.
+//
+// Deposits are not signed, their sender is authenticated on L1. Their sender is
+// returned for any signer.
 func Sender(signer Signer, tx *Transaction) (common.Address, error) {
+	if dep, ok := tx.inner.(*DepositTx); ok {
+		return dep.From, nil
+	}
 	if sc := tx.from.Load(); sc != nil {
 		sigCache := sc.(sigCache)
 		// If the signer used to derive from in a previous
@@ -205,7 +217,7 @@ func (s londonSigner) Equal(s2 Signer) bool {
 
 func (s londonSigner) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
 	if tx.Type() == DepositTxType {
-		return nil, nil, nil, fmt.Errorf("deposits do not have a signature")
+		return nil, nil, nil, ErrDepositSignature
 	}
 	txdata, ok := tx.inner.(*DynamicFeeTx)
 	if !ok {
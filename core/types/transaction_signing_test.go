@@ -135,3 +135,44 @@ func TestChainId(t *testing.T) {
 		t.Error("expected no error")
 	}
 }
+
+func TestDepositSender(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := common.HexToAddress("0xf00d")
+	to := common.HexToAddress("0x1234")
+	tx := NewTx(&DepositTx{From: from, To: &to, Mint: big.NewInt(10), Value: big.NewInt(1), Gas: 50000})
+
+	signers := []Signer{
+		NewLondonSigner(big.NewInt(901)),
+		NewEIP2930Signer(big.NewInt(901)),
+		NewEIP155Signer(big.NewInt(901)),
+		HomesteadSigner{},
+		FrontierSigner{},
+	}
+	for _, signer := range signers {
+		sender, err := Sender(signer, tx)
+		if err != nil {
+			t.Fatalf("%T: %v", signer, err)
+		}
+		if sender != from {
+			t.Fatalf("%T: sender mismatch: have %s, want %s", signer, sender, from)
+		}
+		msg, err := tx.AsMessage(signer, big.NewInt(7))
+		if err != nil {
+			t.Fatalf("%T: %v", signer, err)
+		}
+		if msg.From() != from || msg.Mint().Cmp(big.NewInt(10)) != 0 {
+			t.Fatalf("%T: unexpected message from %s, mint %v", signer, msg.From(), msg.Mint())
+		}
+	}
+	// Deposits cannot be signed.
+	if _, err := SignTx(tx, signers[0], key); err != ErrDepositSignature {
+		t.Fatalf("signed deposit: %v", err)
+	}
+	if _, err := SignNewTx(key, signers[0], &DepositTx{From: from, Value: new(big.Int)}); err != ErrDepositSignature {
+		t.Fatalf("signed new deposit: %v", err)
+	}
+	if _, err := tx.WithSignature(signers[0], make([]byte, 65)); err != ErrDepositSignature {
+		t.Fatalf("added signature to deposit: %v", err)
+	}
+}
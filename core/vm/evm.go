@@ -94,6 +94,7 @@ type TxContext struct {
 	// Message information
 	Origin   common.Address // Provides information for ORIGIN
 	GasPrice *big.Int       // Provides information for GASPRICE
+	Mint     *big.Int       // Value minted to the origin by a deposit, nil otherwise
 }
 
 // EVM is the Ethereum Virtual Machine base object and provides
@@ -445,6 +445,51 @@ func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeRes
 	return result, nil
 }
 
+// MintedSupplyResult is the ETH minted by deposits up to a block.
+type MintedSupplyResult struct {
+	Minted *hexutil.Big   `json:"minted"`
+	Since  hexutil.Uint64 `json:"since"` // first block whose deposits are counted
+}
+
+// MintedSupply returns the ETH minted by the deposits up to and including the
+// given block. It is only tracked for the blocks that were processed with the
+// supply check enabled, counting from the first checked block.
+func (api *DebugAPI) MintedSupply(blockNrOrHash rpc.BlockNumberOrHash) (*MintedSupplyResult, error) {
+	var header *types.Header
+	if number, ok := blockNrOrHash.Number(); ok {
+		switch number {
+		case rpc.PendingBlockNumber:
+			return nil, errors.New("minted supply of the pending block is not tracked")
+		case rpc.LatestBlockNumber:
+			header = api.eth.blockchain.CurrentHeader()
+		case rpc.FinalizedBlockNumber:
+			if block := api.eth.blockchain.CurrentFinalizedBlock(); block != nil {
+				header = block.Header()
+			}
+		case rpc.SafeBlockNumber:
+			if block := api.eth.blockchain.CurrentSafeBlock(); block != nil {
+				header = block.Header()
+			}
+		default:
+			header = api.eth.blockchain.GetHeaderByNumber(uint64(number))
+		}
+		if header == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+	} else if hash, ok := blockNrOrHash.Hash(); ok {
+		if header = api.eth.blockchain.GetHeaderByHash(hash); header == nil {
+			return nil, fmt.Errorf("block %s not found", hash.Hex())
+		}
+	} else {
+		return nil, errors.New("either block number or block hash must be specified")
+	}
+	supply := api.eth.blockchain.GetMintedSupply(header.Hash())
+	if supply == nil {
+		return nil, fmt.Errorf("minted supply of block #%d not tracked, see --rollup.supplycheck", header.Number)
+	}
+	return &MintedSupplyResult{Minted: (*hexutil.Big)(supply.Minted), Since: hexutil.Uint64(supply.Since)}, nil
+}
+
 // GetModifiedAccountsByNumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
 // code hash, or storage hash.
@@ -77,6 +77,7 @@ type Ethereum struct {
 	snapDialCandidates enode.Iterator
 	merger             *consensus.Merger
 	seqRPCService      *rpc.Client
+	historicalRPC      *rpc.Client
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
@@ -209,6 +210,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.RollupSupplyCheck {
+		log.Warn("Checking the supply invariants of every block, block processing is slower")
+		eth.blockchain.EnableSupplyCheck()
+	}
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -276,6 +281,15 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		}
 		eth.seqRPCService = client
 	}
+	if config.RollupHistoricalRPC != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), config.RollupHistoricalRPCTimeout)
+		client, err := rpc.DialContext(ctx, config.RollupHistoricalRPC)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		eth.historicalRPC = client
+	}
 
 	// Start the RPC service
 	eth.netRPCService = ethapi.NewNetAPI(eth.p2pServer, config.NetworkId)
@@ -574,6 +588,9 @@ func (s *Ethereum) Stop() error {
 	if s.seqRPCService != nil {
 		s.seqRPCService.Close()
 	}
+	if s.historicalRPC != nil {
+		s.historicalRPC.Close()
+	}
 
 	// Clean shutdown marker as the last thing before closing db
 	s.shutdownTracker.Stop()
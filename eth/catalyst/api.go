@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/beacon"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/log"
@@ -263,17 +264,19 @@ func (api *ConsensusAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, pa
 	// sealed by the beacon client. The payload will be requested later, and we
 	// might replace it arbitrarily many times in between.
 	if payloadAttributes != nil {
-		// Decode forceTxs. TODO: How to handle tx validity.
+		// Decode the transactions that are forced into the block, ahead of any
+		// transactions of the pool. Their validity is checked when they are
+		// executed, any of them failing fails the payload.
 		forceTxs := make(types.Transactions, 0, len(payloadAttributes.Transactions))
 		for i, otx := range payloadAttributes.Transactions {
 			var tx types.Transaction
 			if err := tx.UnmarshalBinary(otx); err != nil {
-				return beacon.STATUS_INVALID, fmt.Errorf("transaction %d is not valid: %v", i, err)
+				return beacon.STATUS_INVALID, beacon.InvalidPayloadAttributes.With(fmt.Errorf("transaction %d is not valid: %v", i, err))
 			}
 			forceTxs = append(forceTxs, &tx)
 		}
 		// Create an empty block first which can be used as a fallback
-		empty, err := api.eth.Miner().GetSealingBlockSync(update.HeadBlockHash, payloadAttributes.Timestamp, payloadAttributes.SuggestedFeeRecipient, payloadAttributes.Random, true, forceTxs)
+		empty, err := api.eth.Miner().GetSealingBlockSync(update.HeadBlockHash, payloadAttributes.Timestamp, payloadAttributes.SuggestedFeeRecipient, payloadAttributes.Random, true, forceTxs, payloadAttributes.GasLimit)
 		if err != nil {
 			log.Error("Failed to create empty sealing payload", "err", err)
 			return beacon.STATUS_INVALID, beacon.InvalidPayloadAttributes.With(err)
@@ -285,7 +288,7 @@ func (api *ConsensusAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, pa
 		}
 		// Send a request to generate a full block in the background.
 		// The result can be obtained via the returned channel.
-		resCh, err := api.eth.Miner().GetSealingBlockAsync(update.HeadBlockHash, payloadAttributes.Timestamp, payloadAttributes.SuggestedFeeRecipient, payloadAttributes.Random, false, forceTxs)
+		resCh, err := api.eth.Miner().GetSealingBlockAsync(update.HeadBlockHash, payloadAttributes.Timestamp, payloadAttributes.SuggestedFeeRecipient, payloadAttributes.Random, false, forceTxs, payloadAttributes.GasLimit)
 		if err != nil {
 			log.Error("Failed to create async sealing payload", "err", err)
 			return valid(nil), beacon.InvalidPayloadAttributes.With(err)
@@ -432,6 +435,18 @@ func computePayloadId(headBlockHash common.Hash, params *beacon.PayloadAttribute
 	binary.Write(hasher, binary.BigEndian, params.Timestamp)
 	hasher.Write(params.Random[:])
 	hasher.Write(params.SuggestedFeeRecipient[:])
+	if params.GasLimit != nil {
+		binary.Write(hasher, binary.BigEndian, *params.GasLimit)
+	}
+	// The rollup fields select other blocks too. They are left out if unset,
+	// so that the IDs of the other payloads do not change.
+	if params.NoTxPool || len(params.Transactions) > 0 {
+		binary.Write(hasher, binary.BigEndian, params.NoTxPool)
+		binary.Write(hasher, binary.BigEndian, uint64(len(params.Transactions)))
+		for _, tx := range params.Transactions {
+			hasher.Write(crypto.Keccak256(tx))
+		}
+	}
 	var out beacon.PayloadID
 	copy(out[:], hasher.Sum(nil)[:8])
 	return out
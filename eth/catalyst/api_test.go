@@ -184,6 +184,82 @@ func TestEth2PrepareAndGetPayload(t *testing.T) {
 	}
 }
 
+// Tests that the transactions of the rollup payload attributes are forced in
+// front of the transactions of the pool, which are left out with noTxPool.
+func TestRollupPayloadAttributes(t *testing.T) {
+	genesis, blocks := generatePreMergeChain(10)
+	genesis.Config.TerminalTotalDifficulty.Sub(genesis.Config.TerminalTotalDifficulty, blocks[9].Difficulty())
+	n, ethservice := startEthService(t, genesis, blocks[:9])
+	defer n.Close()
+
+	api := NewConsensusAPI(ethservice)
+	signer := types.LatestSigner(ethservice.BlockChain().Config())
+	var txs []*types.Transaction
+	for nonce := uint64(9); nonce < 11; nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(2*params.InitialBaseFee), nil), signer, testKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	ethservice.TxPool().AddLocals(txs)
+	forced, err := txs[0].MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcState := beacon.ForkchoiceStateV1{HeadBlockHash: blocks[8].Hash()}
+	tests := []struct {
+		noTxPool bool
+		want     []common.Hash
+	}{
+		{false, []common.Hash{txs[0].Hash(), txs[1].Hash()}},
+		{true, []common.Hash{txs[0].Hash()}},
+	}
+	ids := make(map[beacon.PayloadID]bool)
+	for _, tt := range tests {
+		attrs := beacon.PayloadAttributesV1{
+			Timestamp:    blocks[8].Time() + 5,
+			Transactions: [][]byte{forced},
+			NoTxPool:     tt.noTxPool,
+		}
+		resp, err := api.ForkchoiceUpdatedV1(fcState, &attrs)
+		if err != nil {
+			t.Fatalf("noTxPool %v: error preparing payload: %v", tt.noTxPool, err)
+		}
+		id := *resp.PayloadID
+		if id != computePayloadId(fcState.HeadBlockHash, &attrs) {
+			t.Fatalf("noTxPool %v: unexpected payload ID", tt.noTxPool)
+		}
+		ids[id] = true
+		data, err := api.GetPayloadV1(id)
+		if err != nil {
+			t.Fatalf("noTxPool %v: error getting payload: %v", tt.noTxPool, err)
+		}
+		if len(data.Transactions) != len(tt.want) {
+			t.Fatalf("noTxPool %v: payload has %d transactions, want %d", tt.noTxPool, len(data.Transactions), len(tt.want))
+		}
+		for i, enc := range data.Transactions {
+			var tx types.Transaction
+			if err := tx.UnmarshalBinary(enc); err != nil {
+				t.Fatal(err)
+			}
+			if tx.Hash() != tt.want[i] {
+				t.Errorf("noTxPool %v: transaction %d is %s, want %s", tt.noTxPool, i, tx.Hash(), tt.want[i])
+			}
+		}
+	}
+	// The rollup fields make the payload IDs differ from the plain one.
+	ids[computePayloadId(fcState.HeadBlockHash, &beacon.PayloadAttributesV1{Timestamp: blocks[8].Time() + 5})] = true
+	if len(ids) != 3 {
+		t.Errorf("payload IDs collide: %d distinct, want 3", len(ids))
+	}
+	// Undecodable transactions are rejected.
+	attrs := beacon.PayloadAttributesV1{Timestamp: blocks[8].Time() + 5, Transactions: [][]byte{{0x01}}}
+	if _, err := api.ForkchoiceUpdatedV1(fcState, &attrs); err == nil {
+		t.Fatal("payload attributes with an invalid transaction accepted")
+	}
+}
+
 func checkLogEvents(t *testing.T, logsCh <-chan []*types.Log, rmLogsCh <-chan core.RemovedLogsEvent, wantNew, wantRemoved int) {
 	t.Helper()
 
@@ -603,7 +679,7 @@ func TestNewPayloadOnInvalidChain(t *testing.T) {
 }
 
 func assembleBlock(api *ConsensusAPI, parentHash common.Hash, params *beacon.PayloadAttributesV1) (*beacon.ExecutableDataV1, error) {
-	block, err := api.eth.Miner().GetSealingBlockSync(parentHash, params.Timestamp, params.SuggestedFeeRecipient, params.Random, false, nil)
+	block, err := api.eth.Miner().GetSealingBlockSync(parentHash, params.Timestamp, params.SuggestedFeeRecipient, params.Random, false, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -846,7 +922,7 @@ func TestNewPayloadOnInvalidTerminalBlock(t *testing.T) {
 		Random:                crypto.Keccak256Hash([]byte{byte(1)}),
 		SuggestedFeeRecipient: parent.Coinbase(),
 	}
-	empty, err := api.eth.Miner().GetSealingBlockSync(parent.Hash(), params.Timestamp, params.SuggestedFeeRecipient, params.Random, true, nil)
+	empty, err := api.eth.Miner().GetSealingBlockSync(parent.Hash(), params.Timestamp, params.SuggestedFeeRecipient, params.Random, true, nil, nil)
 	if err != nil {
 		t.Fatalf("error preparing payload, err=%v", err)
 	}
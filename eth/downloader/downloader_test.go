@@ -60,6 +60,12 @@ func newTester(t *testing.T) *downloadTester {
 
 // newTester creates a new downloader test mocker.
 func newTesterWithNotification(t *testing.T, success func()) *downloadTester {
+	return newTesterWithConfig(t, params.TestChainConfig, success)
+}
+
+// newTesterWithConfig creates a new downloader test mocker, whose local chain
+// uses the given chain configuration.
+func newTesterWithConfig(t *testing.T, config *params.ChainConfig, success func()) *downloadTester {
 	freezer := t.TempDir()
 	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), freezer, "", false)
 	if err != nil {
@@ -70,7 +76,7 @@ func newTesterWithNotification(t *testing.T, success func()) *downloadTester {
 	})
 	core.GenesisBlockForTesting(db, testAddress, big.NewInt(1000000000000000))
 
-	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	chain, err := core.NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -113,13 +119,19 @@ func (dl *downloadTester) sync(id string, td *big.Int, mode SyncMode) error {
 
 // newPeer registers a new block download source into the downloader.
 func (dl *downloadTester) newPeer(id string, version uint, blocks []*types.Block) *downloadTesterPeer {
+	return dl.newPeerWithChain(id, version, newTestBlockchain(blocks))
+}
+
+// newPeerWithChain registers a new download source serving the given chain
+// into the downloader.
+func (dl *downloadTester) newPeerWithChain(id string, version uint, chain *core.BlockChain) *downloadTesterPeer {
 	dl.lock.Lock()
 	defer dl.lock.Unlock()
 
 	peer := &downloadTesterPeer{
 		dl:              dl,
 		id:              id,
-		chain:           newTestBlockchain(blocks),
+		chain:           chain,
 		withholdHeaders: make(map[common.Hash]struct{}),
 	}
 	dl.peers[id] = peer
@@ -1489,3 +1501,80 @@ func testBeaconSync(t *testing.T, protocol uint, mode SyncMode) {
 		})
 	}
 }
+
+// Tests that rollup chains, whose blocks start with unsigned deposits, can be
+// synchronised, and that the receipts of the deposits are reconstructed.
+func TestDepositSync66Full(t *testing.T) { testDepositSync(t, eth.ETH66, FullSync) }
+func TestDepositSync66Snap(t *testing.T) { testDepositSync(t, eth.ETH66, SnapSync) }
+func TestDepositSync67Full(t *testing.T) { testDepositSync(t, eth.ETH67, FullSync) }
+func TestDepositSync67Snap(t *testing.T) { testDepositSync(t, eth.ETH67, SnapSync) }
+
+func testDepositSync(t *testing.T, protocol uint, mode SyncMode) {
+	const deposits = 4 // user deposits per block
+
+	blocks := blockCacheMaxItems - 15
+	config := *params.TestChainConfig
+	config.Optimism = &params.OptimismConfig{}
+
+	// Every block starts with the L1 info deposit, followed by user deposits
+	// from every other log of its L1 origin.
+	db := rawdb.NewMemoryDatabase()
+	genesis := core.GenesisBlockForTesting(db, testAddress, big.NewInt(1000000000000000))
+	to := common.HexToAddress("0xdead")
+	chain, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, blocks, func(i int, block *core.BlockGen) {
+		l1 := &types.Header{Number: big.NewInt(int64(i)), Time: uint64(i) * 12, BaseFee: big.NewInt(7), Difficulty: new(big.Int)}
+		info, err := types.NewL1InfoDepositTx(l1, 0)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(info)
+		for j := 0; j < deposits; j++ {
+			block.AddTx(types.NewTx(&types.DepositTx{
+				SourceHash: types.UserDepositSourceHash(l1.Hash(), uint64(2*j)),
+				From:       common.BigToAddress(big.NewInt(int64(j + 1))),
+				To:         &to,
+				Mint:       big.NewInt(1),
+				Value:      big.NewInt(1),
+				Gas:        params.TxGas,
+			}))
+		}
+	})
+	source, err := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Stop()
+	if n, err := source.InsertChain(chain); err != nil {
+		t.Fatalf("block %d: %v", n, err)
+	}
+
+	tester := newTesterWithConfig(t, &config, nil)
+	defer tester.terminate()
+
+	tester.newPeerWithChain("peer", protocol, source)
+	if err := tester.sync("peer", nil, mode); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, blocks+1)
+
+	signer := types.LatestSigner(&config)
+	for _, block := range chain {
+		receipts := tester.chain.GetReceiptsByHash(block.Hash())
+		if len(receipts) != deposits+1 {
+			t.Fatalf("block %d: have %d receipts, want %d", block.NumberU64(), len(receipts), deposits+1)
+		}
+		l1Hash := receipts[0].L1BlockHash
+		if l1Hash == nil {
+			t.Fatalf("block %d: unexpected L1 info receipt %+v", block.NumberU64(), receipts[0])
+		}
+		for j, receipt := range receipts[1:] {
+			if receipt.Type != types.DepositTxType || receipt.Status != types.ReceiptStatusSuccessful || *receipt.L1BlockHash != *l1Hash {
+				t.Fatalf("block %d, deposit %d: unexpected receipt %+v", block.NumberU64(), j, receipt)
+			}
+		}
+		txs := tester.chain.GetBlockByHash(block.Hash()).Transactions()
+		if from, err := types.Sender(signer, txs[deposits]); err != nil || from != common.BigToAddress(big.NewInt(deposits)) {
+			t.Fatalf("block %d: sender %x, err %v", block.NumberU64(), from, err)
+		}
+	}
+}
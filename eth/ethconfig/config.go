@@ -93,6 +93,8 @@ var Defaults = Config{
 	RPCEVMTimeout: 5 * time.Second,
 	GPO:           FullNodeGPO,
 	RPCTxFeeCap:   1, // 1 ether
+
+	RollupHistoricalRPCTimeout: 5 * time.Second,
 }
 
 func init() {
@@ -211,7 +213,10 @@ type Config struct {
 	// OverrideTerminalTotalDifficultyPassed (TODO: remove after the fork)
 	OverrideTerminalTotalDifficultyPassed *bool `toml:",omitempty"`
 
-	RollupSequencerHTTP string
+	RollupSequencerHTTP        string
+	RollupHistoricalRPC        string
+	RollupHistoricalRPCTimeout time.Duration
+	RollupSupplyCheck          bool
 }
 
 // CreateConsensusEngine creates a consensus engine for the given chain configuration.
@@ -57,6 +57,15 @@ func (b *testBackend) ChainDb() ethdb.Database {
 	return b.db
 }
 
+func (b *testBackend) ChainConfig() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func (b *testBackend) CurrentHeader() *types.Header {
+	hdr, _ := b.HeaderByNumber(context.TODO(), rpc.LatestBlockNumber)
+	return hdr
+}
+
 func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
 	var (
 		hash common.Hash
@@ -272,6 +281,67 @@ func TestPendingTxFilter(t *testing.T) {
 	}
 }
 
+// TestPendingTxSubscriptionFullTx tests that a full-tx pending transaction
+// subscription renders deposit transactions with their deposit fields.
+func TestPendingTxSubscriptionFullTx(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewFilterAPI(backend, false, deadline)
+		server  = rpc.NewServer()
+
+		to      = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		deposit = types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x01"),
+			From:       common.HexToAddress("0xdeadbeef"),
+			To:         &to,
+			Mint:       big.NewInt(100),
+			Value:      big.NewInt(10),
+			Gas:        50000,
+		})
+	)
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ch := make(chan map[string]interface{})
+	sub, err := client.Subscribe(context.Background(), "eth", ch, "newPendingTransactions", true)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	time.Sleep(1 * time.Second)
+	backend.txFeed.Send(core.NewTxsEvent{Txs: []*types.Transaction{deposit}})
+
+	select {
+	case tx := <-ch:
+		want := map[string]interface{}{
+			"type":       "0x7e",
+			"hash":       deposit.Hash().Hex(),
+			"sourceHash": deposit.SourceHash().Hex(),
+			"from":       "0x00000000000000000000000000000000deadbeef",
+			"mint":       "0x64",
+			"value":      "0xa",
+			"isSystemTx": false,
+		}
+		for field, value := range want {
+			if tx[field] != value {
+				t.Errorf("field %q mismatch: have %v, want %v", field, tx[field], value)
+			}
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for pending transaction notification")
+	}
+}
+
 // TestLogFilterCreation test whether a given filter criteria makes sense.
 // If not it must return an error.
 func TestLogFilterCreation(t *testing.T) {
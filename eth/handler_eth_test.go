@@ -293,6 +293,64 @@ func testRecvTransactions(t *testing.T, protocol uint) {
 	}
 }
 
+// This test checks that a peer gossiping deposit transactions is dropped, and
+// that the deposits are not added to the pool.
+func TestRecvDepositTransactions66(t *testing.T) { testRecvDepositTransactions(t, eth.ETH66) }
+
+func testRecvDepositTransactions(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	handler := newTestHandler()
+	defer handler.close()
+
+	handler.handler.acceptTxs = 1 // mark synced to accept transactions
+
+	txs := make(chan core.NewTxsEvent)
+	sub := handler.txpool.SubscribeNewTxsEvent(txs)
+	defer sub.Unsubscribe()
+
+	p2pSrc, p2pSink := p2p.MsgPipe()
+	defer p2pSrc.Close()
+	defer p2pSink.Close()
+
+	src := eth.NewPeer(protocol, p2p.NewPeerPipe(enode.ID{1}, "", nil, p2pSrc), p2pSrc, handler.txpool)
+	sink := eth.NewPeer(protocol, p2p.NewPeerPipe(enode.ID{2}, "", nil, p2pSink), p2pSink, handler.txpool)
+	defer src.Close()
+	defer sink.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- handler.handler.runEthPeer(sink, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(handler.handler), peer)
+		})
+	}()
+	var (
+		genesis = handler.chain.Genesis()
+		head    = handler.chain.CurrentBlock()
+		td      = handler.chain.GetTd(head.Hash(), head.NumberU64())
+	)
+	if err := src.Handshake(1, td, head.Hash(), genesis.Hash(), forkid.NewIDWithChain(handler.chain), forkid.NewFilter(handler.chain)); err != nil {
+		t.Fatalf("failed to run protocol handshake")
+	}
+	deposit := types.NewTx(&types.DepositTx{From: testAddr, Value: new(big.Int), Gas: 100000})
+	if err := src.SendTransactions([]*types.Transaction{deposit}); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Errorf("peer gossiping deposits not dropped")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("peer gossiping deposits not dropped within 2 seconds")
+	}
+	select {
+	case event := <-txs:
+		t.Errorf("deposit added to the pool: %v", event.Txs)
+	default:
+	}
+}
+
 // This test checks that pending transactions are sent.
 func TestSendTransactions66(t *testing.T) { testSendTransactions(t, eth.ETH66) }
 
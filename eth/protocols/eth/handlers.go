@@ -500,6 +500,10 @@ func handleTransactions(backend Backend, msg Decoder, peer *Peer) error {
 		if tx == nil {
 			return fmt.Errorf("%w: transaction %d is nil", errDecode, i)
 		}
+		// Deposits are derived from L1 and never gossiped, drop the peer
+		if tx.Type() == types.DepositTxType {
+			return fmt.Errorf("%w: transaction %d", errDepositTx, i)
+		}
 		peer.markTransaction(tx.Hash())
 	}
 	return backend.Handle(peer, &txs)
@@ -520,6 +524,10 @@ func handlePooledTransactions66(backend Backend, msg Decoder, peer *Peer) error
 		if tx == nil {
 			return fmt.Errorf("%w: transaction %d is nil", errDecode, i)
 		}
+		// Deposits are derived from L1 and never gossiped, drop the peer
+		if tx.Type() == types.DepositTxType {
+			return fmt.Errorf("%w: transaction %d", errDepositTx, i)
+		}
 		peer.markTransaction(tx.Hash())
 	}
 	requestTracker.Fulfil(peer.id, peer.version, PooledTransactionsMsg, txs.RequestId)
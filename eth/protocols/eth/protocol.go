@@ -76,6 +76,7 @@ var (
 	errNetworkIDMismatch       = errors.New("network ID mismatch")
 	errGenesisMismatch         = errors.New("genesis mismatch")
 	errForkIDRejected          = errors.New("fork ID rejected")
+	errDepositTx               = errors.New("deposit transaction in gossip")
 )
 
 // Packet represents a p2p message in the `eth` protocol.
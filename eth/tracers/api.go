@@ -20,8 +20,10 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"os"
 	"runtime"
 	"sync"
@@ -79,6 +81,8 @@ type Backend interface {
 	// so this method should be called with the parent.
 	StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive, preferDisk bool) (*state.StateDB, error)
 	StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error)
+
+	ethapi.HistoricalBackend
 }
 
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
@@ -434,6 +438,12 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 // TraceBlockByNumber returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
+	if number >= 0 && api.backend.ChainConfig().IsHistorical(big.NewInt(number.Int64())) {
+		var res []*txTraceResult
+		if ok, err := ethapi.CallHistorical(ctx, api.backend, &res, "debug_traceBlockByNumber", number, config); ok {
+			return res, err
+		}
+	}
 	block, err := api.blockByNumber(ctx, number)
 	if err != nil {
 		return nil, err
@@ -444,10 +454,21 @@ func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber,
 // TraceBlockByHash returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
-	block, err := api.blockByHash(ctx, hash)
+	block, err := api.backend.BlockByHash(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
+	if block == nil {
+		// Unknown blocks may be part of the legacy chain
+		if !api.backend.ChainConfig().IsMigrated() {
+			return nil, fmt.Errorf("block %s not found", hash.Hex())
+		}
+		var res []*txTraceResult
+		if ok, err := ethapi.CallHistorical(ctx, api.backend, &res, "debug_traceBlockByHash", hash, config); ok {
+			return res, err
+		}
+		return nil, fmt.Errorf("block %s not found", hash.Hex())
+	}
 	return api.traceBlock(ctx, block, config)
 }
 
@@ -781,10 +802,17 @@ func containsTx(block *types.Block, hash common.Hash) bool {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
-	_, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
+	tx, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
+	if tx == nil && api.backend.ChainConfig().IsMigrated() {
+		// Unknown transactions may be part of the legacy chain
+		var res json.RawMessage
+		if ok, err := ethapi.CallHistorical(ctx, api.backend, &res, "debug_traceTransaction", hash, config); ok {
+			return res, err
+		}
+	}
 	// It shouldn't happen in practice.
 	if blockNumber == 0 {
 		return nil, errors.New("genesis is not traceable")
@@ -829,6 +857,12 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 			// of what the next actual block is likely to contain.
 			return nil, errors.New("tracing on top of pending is not supported")
 		}
+		if number >= 0 && api.backend.ChainConfig().IsHistorical(big.NewInt(number.Int64())) {
+			var res json.RawMessage
+			if ok, err := ethapi.CallHistorical(ctx, api.backend, &res, "debug_traceCall", args, blockNrOrHash, config); ok {
+				return res, err
+			}
+		}
 		block, err = api.blockByNumber(ctx, number)
 	} else {
 		return nil, errors.New("invalid arguments; neither block nor hash specified")
@@ -57,6 +57,7 @@ type testBackend struct {
 	engine      consensus.Engine
 	chaindb     ethdb.Database
 	chain       *core.BlockChain
+	historical  *rpc.Client
 }
 
 func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
@@ -345,6 +346,83 @@ func TestTraceTransaction(t *testing.T) {
 	}
 }
 
+func (b *testBackend) CallHistorical(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if b.historical == nil {
+		return ethapi.ErrNoHistoricalRPC
+	}
+	return b.historical.CallContext(ctx, result, method, args...)
+}
+
+// historicalAPI is the debug API of a legacy node.
+type historicalAPI struct{}
+
+func (historicalAPI) TraceBlockByNumber(number rpc.BlockNumber, config *TraceConfig) []*txTraceResult {
+	return []*txTraceResult{{Result: fmt.Sprintf("legacy block %d", number)}}
+}
+
+func (historicalAPI) TraceBlockByHash(hash common.Hash, config *TraceConfig) []*txTraceResult {
+	return []*txTraceResult{{Result: "legacy block " + hash.Hex()}}
+}
+
+func TestTraceHistorical(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	backend := newTestBackend(t, 4, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+	})
+	// The chain was migrated at block 2, the legacy node serves the blocks
+	// before it.
+	config := *backend.chainConfig
+	config.Optimism = &params.OptimismConfig{MigrationBlock: big.NewInt(2)}
+	backend.chainConfig = &config
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", historicalAPI{}); err != nil {
+		t.Fatal(err)
+	}
+	backend.historical = rpc.DialInProc(server)
+	defer backend.historical.Close()
+	api := NewAPI(backend)
+
+	unknown := common.HexToHash("0x1234")
+	for i, tc := range []struct {
+		trace func() ([]*txTraceResult, error)
+		want  string
+	}{
+		{
+			trace: func() ([]*txTraceResult, error) { return api.TraceBlockByNumber(context.Background(), 1, nil) },
+			want:  `[{"result":"legacy block 1"}]`,
+		},
+		{
+			trace: func() ([]*txTraceResult, error) { return api.TraceBlockByHash(context.Background(), unknown, nil) },
+			want:  `[{"result":"legacy block ` + unknown.Hex() + `"}]`,
+		},
+		{
+			trace: func() ([]*txTraceResult, error) { return api.TraceBlockByNumber(context.Background(), 2, nil) },
+			want:  `[{"result":{"gas":21000,"failed":false,"returnValue":"","structLogs":[]}}]`,
+		},
+	} {
+		result, err := tc.trace()
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if have, _ := json.Marshal(result); string(have) != tc.want {
+			t.Errorf("test %d: result mismatch, have\n%s\nwant\n%s", i, have, tc.want)
+		}
+	}
+	// Chains that were not migrated do not forward unknown hashes.
+	config.Optimism = &params.OptimismConfig{}
+	if _, err := api.TraceBlockByHash(context.Background(), unknown, nil); err == nil {
+		t.Error("unknown block forwarded without a migration block")
+	}
+}
+
 func TestTraceBlock(t *testing.T) {
 	t.Parallel()
 
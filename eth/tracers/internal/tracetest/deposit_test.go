@@ -0,0 +1,160 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// traceDeposit executes a deposit that mints 1000 wei to its sender and calls
+// a contract with 10 wei, with the given tracer.
+func traceDeposit(t *testing.T, tracer vm.EVMLogger) (from, to common.Address) {
+	t.Helper()
+	from, to = common.HexToAddress("0xdeadbeef"), common.HexToAddress("0xc0de")
+	var (
+		config = *params.AllEthashProtocolChanges
+		alloc  = core.GenesisAlloc{
+			from: {Balance: big.NewInt(5), Nonce: 3},
+			// Stores the call value in slot 0.
+			to: {Code: []byte{byte(vm.CALLVALUE), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}, Balance: big.NewInt(1)},
+		}
+		tx = types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x01"),
+			From:       from,
+			To:         &to,
+			Mint:       big.NewInt(1000),
+			Value:      big.NewInt(10),
+			Gas:        100_000,
+		})
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: big.NewInt(1),
+			Time:        big.NewInt(100),
+			Difficulty:  new(big.Int),
+			BaseFee:     big.NewInt(7),
+			GasLimit:    30_000_000,
+		}
+		_, statedb = tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+	)
+	config.Optimism = &params.OptimismConfig{}
+	msg, err := tx.AsMessage(types.LatestSigner(&config), context.BaseFee)
+	if err != nil {
+		t.Fatalf("failed to prepare deposit for tracing: %v", err)
+	}
+	evm := vm.NewEVM(context, core.NewEVMTxContext(msg), statedb, &config, vm.Config{Debug: true, Tracer: tracer})
+	if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		t.Fatalf("failed to execute deposit: %v", err)
+	}
+	return from, to
+}
+
+func TestTraceDepositStructLogger(t *testing.T) {
+	tracer := logger.NewStructLogger(nil)
+	traceDeposit(t, tracer)
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result logger.ExecutionResult
+	if err := json.Unmarshal(res, &result); err != nil {
+		t.Fatal(err)
+	}
+	// Deposits are recorded as using all of their gas.
+	if result.Failed || result.Gas != 100_000 {
+		t.Errorf("failed %v, gas used %d, want 100000", result.Failed, result.Gas)
+	}
+	if n := len(result.StructLogs); n != 4 {
+		t.Errorf("%d steps, want 4", n)
+	}
+}
+
+func TestTraceDepositCallTracer(t *testing.T) {
+	for _, name := range []string{"callTracer", "callTracerLegacy"} {
+		tracer, err := tracers.New(name, new(tracers.Context))
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, to := traceDeposit(t, tracer)
+		res, err := tracer.GetResult()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var call struct {
+			Type    string
+			From    common.Address
+			To      common.Address
+			Value   string
+			Gas     string
+			GasUsed string
+			Error   string
+		}
+		if err := json.Unmarshal(res, &call); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if call.Type != "CALL" || call.From != from || call.To != to || call.Value != "0xa" || call.Error != "" {
+			t.Errorf("%s: unexpected call %+v", name, call)
+		}
+		// Deposits pay no intrinsic gas, the call gets the whole gas limit.
+		// The gas used by the call is CALLVALUE, PUSH1 and a fresh SSTORE.
+		if want := params.SstoreSetGasEIP2200 + params.ColdSloadCostEIP2929 + 5; call.Gas != "0x186a0" || call.GasUsed != hexutil.EncodeUint64(want) {
+			t.Errorf("%s: gas %s, gas used %s, want 0x186a0 and %#x", name, call.Gas, call.GasUsed, want)
+		}
+	}
+}
+
+func TestTraceDepositPrestateTracer(t *testing.T) {
+	for _, name := range []string{"prestateTracer", "prestateTracerLegacy"} {
+		tracer, err := tracers.New(name, new(tracers.Context))
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, to := traceDeposit(t, tracer)
+		res, err := tracer.GetResult()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var prestate map[common.Address]struct {
+			Balance string
+			Nonce   uint64
+		}
+		if err := json.Unmarshal(res, &prestate); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// The balance before the deposit excludes the minted value, and
+		// deposits buy no gas.
+		if acc := prestate[from]; acc.Balance != "0x5" || acc.Nonce != 3 {
+			t.Errorf("%s: sender prestate %+v, want balance 0x5 and nonce 3", name, acc)
+		}
+		if acc := prestate[to]; acc.Balance != "0x1" {
+			t.Errorf("%s: recipient prestate %+v, want balance 0x1", name, acc)
+		}
+	}
+}
@@ -224,6 +224,14 @@ func (t *jsTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Addr
 		return
 	}
 	t.ctx["value"] = valueBig
+	if mint := env.TxContext.Mint; mint != nil {
+		mintBig, err := t.toBig(t.vm, mint.String())
+		if err != nil {
+			t.err = err
+			return
+		}
+		t.ctx["mint"] = mintBig
+	}
 	t.ctx["block"] = t.vm.ToValue(env.Context.BlockNumber.Uint64())
 	// Update list of precompiles based on current block
 	rules := env.ChainConfig().Rules(env.Context.BlockNumber, env.Context.Random != nil)
@@ -64,6 +64,11 @@
 		this.prestate[toHex(ctx.to)].balance   = '0x'+toBal.subtract(ctx.value).toString(16);
 		this.prestate[toHex(ctx.from)].balance = '0x'+fromBal.add(ctx.value).add((ctx.gasUsed + ctx.intrinsicGas) * ctx.gasPrice).toString(16);
 
+		// Deposits mint to the sender before the transaction is executed.
+		if (ctx.mint !== undefined) {
+			this.prestate[toHex(ctx.from)].balance = '0x'+bigInt(this.prestate[toHex(ctx.from)].balance.slice(2), 16).subtract(ctx.mint).toString(16);
+		}
+
 		// Decrement the caller's nonce, and remove empty create targets
 		this.prestate[toHex(ctx.from)].nonce--;
 		if (ctx.type == 'CREATE') {
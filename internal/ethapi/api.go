@@ -170,7 +170,7 @@ func (s *TxPoolAPI) Content() map[string]map[string]map[string]*RPCTransaction {
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+			dump[txPoolDumpKey(tx)] = NewRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -178,7 +178,7 @@ func (s *TxPoolAPI) Content() map[string]map[string]map[string]*RPCTransaction {
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+			dump[txPoolDumpKey(tx)] = NewRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -194,14 +194,14 @@ func (s *TxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCT
 	// Build the pending transactions
 	dump := make(map[string]*RPCTransaction, len(pending))
 	for _, tx := range pending {
-		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+		dump[txPoolDumpKey(tx)] = NewRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
 	}
 	content["pending"] = dump
 
 	// Build the queued transactions
 	dump = make(map[string]*RPCTransaction, len(queue))
 	for _, tx := range queue {
-		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+		dump[txPoolDumpKey(tx)] = NewRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
 	}
 	content["queued"] = dump
 
@@ -226,18 +226,11 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	}
 	pending, queue := s.b.TxPoolContent()
 
-	// Define a formatter to flatten a transaction into a string
-	var format = func(tx *types.Transaction) string {
-		if to := tx.To(); to != nil {
-			return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
-		}
-		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
-	}
 	// Flatten the pending transactions
 	for account, txs := range pending {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[txPoolDumpKey(tx)] = inspectTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -245,13 +238,42 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	for account, txs := range queue {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[txPoolDumpKey(tx)] = inspectTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
 	return content
 }
 
+// txPoolDumpKey returns the key of a transaction in a per-account transaction
+// dump. Transactions are keyed by nonce, except for deposits: these all share
+// the same sentinel nonce and are keyed by their source hash instead.
+func txPoolDumpKey(tx *types.Transaction) string {
+	if tx.Type() == types.DepositTxType {
+		return tx.SourceHash().Hex()
+	}
+	return fmt.Sprintf("%d", tx.Nonce())
+}
+
+// inspectTransaction flattens a transaction into a string.
+func inspectTransaction(tx *types.Transaction) string {
+	if tx.Type() == types.DepositTxType {
+		mint := tx.Mint()
+		if mint == nil {
+			mint = new(big.Int)
+		}
+		to := "contract creation"
+		if tx.To() != nil {
+			to = tx.To().Hex()
+		}
+		return fmt.Sprintf("deposit %s: %s: %v wei + %v wei minted + %v gas", tx.SourceHash().Hex(), to, tx.Value(), mint, tx.Gas())
+	}
+	if to := tx.To(); to != nil {
+		return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+	}
+	return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
+}
+
 // EthereumAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type EthereumAccountAPI struct {
@@ -627,8 +649,13 @@ func (s *BlockChainAPI) BlockNumber() hexutil.Uint64 {
 
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
-// block numbers are also allowed.
+// block numbers are also allowed. The state before the migration block of a
+// migrated rollup chain is served by the legacy node.
 func (s *BlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	var res *hexutil.Big
+	if ok, err := callHistoricalState(ctx, s.b, blockNrOrHash, &res, "eth_getBalance", address, blockNrOrHash); ok {
+		return res, err
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -736,6 +763,12 @@ func (s *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash) m
 // * When fullTx is true all transactions in the block are returned, otherwise
 //   only the transaction hash is returned.
 func (s *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	if number >= 0 && s.b.ChainConfig().IsHistorical(big.NewInt(number.Int64())) {
+		var res map[string]interface{}
+		if ok, err := CallHistorical(ctx, s.b, &res, "eth_getBlockByNumber", number, fullTx); ok {
+			return res, err
+		}
+	}
 	block, err := s.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
 		response, err := s.rpcMarshalBlock(ctx, block, true, fullTx)
@@ -757,6 +790,13 @@ func (s *BlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fu
 	if block != nil {
 		return s.rpcMarshalBlock(ctx, block, true, fullTx)
 	}
+	if err == nil && s.b.ChainConfig().IsMigrated() {
+		// Unknown blocks may be part of the legacy chain
+		var res map[string]interface{}
+		if ok, err := CallHistorical(ctx, s.b, &res, "eth_getBlockByHash", hash, fullTx); ok {
+			return res, err
+		}
+	}
 	return nil, err
 }
 
@@ -809,7 +849,12 @@ func (s *BlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, blockHash
 }
 
 // GetCode returns the code stored at the given address in the state for the given block number.
+// The state before the migration block of a migrated rollup chain is served by the legacy node.
 func (s *BlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	var res hexutil.Bytes
+	if ok, err := callHistoricalState(ctx, s.b, blockNrOrHash, &res, "eth_getCode", address, blockNrOrHash); ok {
+		return res, err
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -820,8 +865,13 @@ func (s *BlockChainAPI) GetCode(ctx context.Context, address common.Address, blo
 
 // GetStorageAt returns the storage from the state at the given address, key and
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
-// numbers are also allowed.
+// numbers are also allowed. The state before the migration block of a migrated
+// rollup chain is served by the legacy node.
 func (s *BlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	var legacy hexutil.Bytes
+	if ok, err := callHistoricalState(ctx, s.b, blockNrOrHash, &legacy, "eth_getStorageAt", address, key, blockNrOrHash); ok {
+		return legacy, err
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -882,6 +932,13 @@ func (diff *StateOverride) Apply(state *state.StateDB) error {
 	return nil
 }
 
+// DepositOverrides turn a call into the execution of a deposit transaction with
+// the given deposit fields. The depositor is the sender of the call.
+type DepositOverrides struct {
+	SourceHash common.Hash  `json:"sourceHash"`
+	Mint       *hexutil.Big `json:"mint"`
+}
+
 // BlockOverrides is a set of header fields to override.
 type BlockOverrides struct {
 	Number     *hexutil.Big
@@ -921,7 +978,7 @@ func (diff *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
 	}
 }
 
-func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
+func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, deposit *DepositOverrides, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
@@ -944,7 +1001,12 @@ func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash
 	defer cancel()
 
 	// Get a new instance of the EVM.
-	msg, err := args.ToMessage(globalGasCap, header.BaseFee)
+	var msg types.Message
+	if deposit != nil {
+		msg, err = args.ToDepositMessage(globalGasCap, b.ChainConfig().ChainID, deposit)
+	} else {
+		msg, err = args.ToMessage(globalGasCap, header.BaseFee)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1008,12 +1070,28 @@ func (e *revertError) ErrorData() interface{} {
 
 // Call executes the given transaction on the state for the given block number.
 //
-// Additionally, the caller can specify a batch of contract for fields overriding.
+// Additionally, the caller can specify a batch of contract for fields overriding,
+// and the deposit fields to execute the call as a deposit transaction, to check
+// whether a deposit will succeed on L2 before it is sent on L1.
+//
+// Calls on the state before the migration block of a migrated rollup chain are
+// executed by the legacy node.
 //
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
-func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
-	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
+func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, depositOverrides *DepositOverrides) (hexutil.Bytes, error) {
+	callArgs := []interface{}{args, blockNrOrHash}
+	if overrides != nil || depositOverrides != nil {
+		callArgs = append(callArgs, overrides)
+	}
+	if depositOverrides != nil {
+		callArgs = append(callArgs, depositOverrides)
+	}
+	var legacy hexutil.Bytes
+	if ok, err := callHistoricalState(ctx, s.b, blockNrOrHash, &legacy, "eth_call", callArgs...); ok {
+		return legacy, err
+	}
+	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, depositOverrides, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
@@ -1062,7 +1140,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 	}
 	// Recap the highest gas limit with account's available balance.
 	if feeCap.BitLen() != 0 {
-		state, _, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+		state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 		if err != nil {
 			return 0, err
 		}
@@ -1074,6 +1152,19 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 			}
 			available.Sub(available, args.Value.ToInt())
 		}
+		// The sender also pays the L1 data fee of the transaction. It barely
+		// depends on the gas limit, so deduct it for the highest one up front.
+		costArgs := args
+		costArgs.Gas = (*hexutil.Uint64)(&hi)
+		if costArgs.Nonce == nil {
+			nonce := state.GetNonce(*args.From)
+			costArgs.Nonce = (*hexutil.Uint64)(&nonce)
+		}
+		l1Fee := l1DataFee(b.ChainConfig(), state, header, costArgs.toTransaction())
+		if l1Fee.Cmp(available) >= 0 {
+			return 0, errors.New("insufficient funds for L1 data fee")
+		}
+		available.Sub(available, l1Fee)
 		allowance := new(big.Int).Div(available, feeCap)
 
 		// If the allowance is larger than maximum uint64, skip checking
@@ -1098,7 +1189,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 	executable := func(gas uint64) (bool, *core.ExecutionResult, error) {
 		args.Gas = (*hexutil.Uint64)(&gas)
 
-		result, err := DoCall(ctx, b, args, blockNrOrHash, nil, 0, gasCap)
+		result, err := DoCall(ctx, b, args, blockNrOrHash, nil, nil, 0, gasCap)
 		if err != nil {
 			if errors.Is(err, core.ErrIntrinsicGas) {
 				return true, nil, nil // Special case, raise gas limit
@@ -1154,6 +1245,34 @@ func (s *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, b
 	return DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
 }
 
+// GetL1Fee returns the L1 data fee that the given transaction is charged on top
+// of its L2 execution fee. Unset fields are filled in the same way as for
+// eth_sendTransaction, so wallets can combine it with eth_estimateGas to quote
+// the total cost of a transaction.
+func (s *BlockChainAPI) GetL1Fee(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, bNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(l1DataFee(s.b.ChainConfig(), state, header, args.toTransaction())), nil
+}
+
+// l1DataFee returns the L1 data fee of a transaction, which is zero on chains
+// that are not rollups.
+func l1DataFee(config *params.ChainConfig, state *state.StateDB, header *types.Header, tx *types.Transaction) *big.Int {
+	if fee := core.NewL1CostFunc(config, state)(header.Number.Uint64(), tx); fee != nil {
+		return fee
+	}
+	return new(big.Int)
+}
+
 // RPCMarshalHeader converts the given header to the RPC output .
 func RPCMarshalHeader(head *types.Header) map[string]interface{} {
 	result := map[string]interface{}{
@@ -1343,8 +1462,8 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction, current *types.Header, config *params.ChainConfig) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction, current *types.Header, config *params.ChainConfig) *RPCTransaction {
 	var baseFee *big.Int
 	blockNumber := uint64(0)
 	if current != nil {
@@ -1549,7 +1668,12 @@ func (s *TransactionAPI) GetRawTransactionByBlockHashAndIndex(ctx context.Contex
 }
 
 // GetTransactionCount returns the number of transactions the given address has sent for the given block number
+// The state before the migration block of a migrated rollup chain is served by the legacy node.
 func (s *TransactionAPI) GetTransactionCount(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Uint64, error) {
+	var res *hexutil.Uint64
+	if ok, err := callHistoricalState(ctx, s.b, blockNrOrHash, &res, "eth_getTransactionCount", address, blockNrOrHash); ok {
+		return res, err
+	}
 	// Ask transaction pool for the nonce which includes pending transactions
 	if blockNr, ok := blockNrOrHash.Number(); ok && blockNr == rpc.PendingBlockNumber {
 		nonce, err := s.b.GetPoolNonce(ctx, address)
@@ -1583,7 +1707,14 @@ func (s *TransactionAPI) GetTransactionByHash(ctx context.Context, hash common.H
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx, s.b.CurrentHeader(), s.b.ChainConfig()), nil
+		return NewRPCPendingTransaction(tx, s.b.CurrentHeader(), s.b.ChainConfig()), nil
+	}
+	// Unknown transactions may be part of the legacy chain
+	if s.b.ChainConfig().IsMigrated() {
+		var res *RPCTransaction
+		if ok, err := CallHistorical(ctx, s.b, &res, "eth_getTransactionByHash", hash); ok {
+			return res, err
+		}
 	}
 
 	// Transaction unknown, return as such
@@ -1615,6 +1746,17 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 		// as per specification.
 		return nil, nil
 	}
+	if tx == nil {
+		// Unknown transactions may be part of the legacy chain
+		if !s.b.ChainConfig().IsMigrated() {
+			return nil, nil
+		}
+		var res map[string]interface{}
+		if ok, err := CallHistorical(ctx, s.b, &res, "eth_getTransactionReceipt", hash); ok {
+			return res, err
+		}
+		return nil, nil
+	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
 	if err != nil {
 		return nil, err
@@ -1644,16 +1786,15 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 		"type":              hexutil.Uint(tx.Type()),
 	}
 	// Assign the effective gas price paid
-	if !s.b.ChainConfig().IsLondon(bigblock) {
-		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
-	} else {
+	var baseFee *big.Int
+	if s.b.ChainConfig().IsLondon(bigblock) && tx.Type() != types.DepositTxType {
 		header, err := s.b.HeaderByHash(ctx, blockHash)
 		if err != nil {
 			return nil, err
 		}
-		gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
-		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
+		baseFee = header.BaseFee
 	}
+	fields["effectiveGasPrice"] = hexutil.Uint64(effectiveGasPrice(tx, baseFee).Uint64())
 	// Assign receipt status or post state.
 	if len(receipt.PostState) > 0 {
 		fields["root"] = hexutil.Bytes(receipt.PostState)
@@ -1667,9 +1808,31 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Deposits are annotated with the L1 block they originate from
+	if receipt.L1BlockHash != nil {
+		fields["l1BlockHash"] = receipt.L1BlockHash
+		fields["l1BlockNumber"] = (*hexutil.Big)(receipt.L1BlockNumber)
+	}
+	if receipt.DepositNonce != nil {
+		fields["depositNonce"] = hexutil.Uint64(*receipt.DepositNonce)
+	}
 	return fields, nil
 }
 
+// effectiveGasPrice returns the price per gas that the sender of an included
+// transaction paid, given the base fee of its block, which is nil before London.
+// Deposits bought their gas on L1, they paid nothing for it on L2.
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	switch {
+	case tx.Type() == types.DepositTxType:
+		return new(big.Int)
+	case baseFee == nil:
+		return tx.GasPrice()
+	default:
+		return new(big.Int).Add(baseFee, tx.EffectiveGasTipValue(baseFee))
+	}
+}
+
 // sign is a helper function that signs a transaction with the private key of the given address.
 func (s *TransactionAPI) sign(addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
 	// Look up the wallet containing the requested signer
@@ -1685,6 +1848,10 @@ func (s *TransactionAPI) sign(addr common.Address, tx *types.Transaction) (*type
 
 // SubmitTransaction is a helper function that submits tx to txPool and logs a message.
 func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+	// Deposits are only included by the rollup node, through the Engine API.
+	if tx.Type() == types.DepositTxType {
+		return common.Hash{}, errors.New("deposit transactions cannot be submitted over RPC")
+	}
 	// If the transaction fee cap is already specified, ensure the
 	// fee of the given transaction is _reasonable_.
 	if err := checkTxFee(tx.GasPrice(), tx.Gas(), b.RPCTxFeeCap()); err != nil {
@@ -1853,7 +2020,7 @@ func (s *TransactionAPI) PendingTransactions() ([]*RPCTransaction, error) {
 	for _, tx := range pending {
 		from, _ := types.Sender(s.signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig()))
+			transactions = append(transactions, NewRPCPendingTransaction(tx, curHeader, s.b.ChainConfig()))
 		}
 	}
 	return transactions, nil
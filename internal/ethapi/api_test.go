@@ -0,0 +1,318 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	testTo      = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+	testFrom    = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+	testDeposit = types.NewTx(&types.DepositTx{
+		SourceHash: common.HexToHash("0x01"),
+		From:       testFrom,
+		To:         &testTo,
+		Mint:       big.NewInt(100),
+		Value:      big.NewInt(10),
+		Gas:        50000,
+	})
+)
+
+// Tests that a dump of transactions that includes deposits renders the deposit
+// fields, and keeps deposits of the same sender apart.
+func TestTxPoolDumpDeposits(t *testing.T) {
+	var (
+		header = &types.Header{Number: big.NewInt(1), BaseFee: big.NewInt(params.InitialBaseFee)}
+		other  = types.NewTx(&types.DepositTx{
+			SourceHash: common.HexToHash("0x02"),
+			From:       testFrom,
+			Value:      new(big.Int),
+			Gas:        21000,
+		})
+		legacy = types.NewTransaction(3, testTo, big.NewInt(1), 21000, big.NewInt(1), nil)
+	)
+	keys := make(map[string]struct{})
+	for _, tx := range []*types.Transaction{testDeposit, other, legacy} {
+		keys[txPoolDumpKey(tx)] = struct{}{}
+	}
+	if len(keys) != 3 {
+		t.Fatalf("dump keys collide: have %d distinct keys, want 3", len(keys))
+	}
+	if key := txPoolDumpKey(legacy); key != "3" {
+		t.Errorf("legacy dump key mismatch: have %s, want 3", key)
+	}
+
+	rpcTx := NewRPCPendingTransaction(testDeposit, header, params.TestChainConfig)
+	if rpcTx.Type != types.DepositTxType {
+		t.Errorf("type mismatch: have %d, want %d", rpcTx.Type, types.DepositTxType)
+	}
+	if rpcTx.From != testFrom {
+		t.Errorf("from mismatch: have %s, want %s", rpcTx.From, testFrom)
+	}
+	if rpcTx.SourceHash == nil || *rpcTx.SourceHash != testDeposit.SourceHash() {
+		t.Errorf("source hash mismatch: have %v, want %s", rpcTx.SourceHash, testDeposit.SourceHash())
+	}
+	if rpcTx.Mint == nil || rpcTx.Mint.ToInt().Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("mint mismatch: have %v, want 100", rpcTx.Mint)
+	}
+	if rpcTx.BlockHash != nil {
+		t.Errorf("pending deposit has block hash %s", rpcTx.BlockHash)
+	}
+
+	want := "deposit 0x0000000000000000000000000000000000000000000000000000000000000001: 0xB794F5EA0ba39494Ce83A213fffBa74279579268: 10 wei + 100 wei minted + 50000 gas"
+	if have := inspectTransaction(testDeposit); have != want {
+		t.Errorf("inspect mismatch:\nhave %s\nwant %s", have, want)
+	}
+	want = "deposit 0x0000000000000000000000000000000000000000000000000000000000000002: contract creation: 0 wei + 0 wei minted + 21000 gas"
+	if have := inspectTransaction(other); have != want {
+		t.Errorf("inspect mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+// Tests that deposits are rejected before they reach the transaction pool.
+func TestSubmitTransactionRejectsDeposits(t *testing.T) {
+	// The backend is never reached, deposits are rejected up front.
+	if _, err := SubmitTransaction(context.Background(), nil, testDeposit); err == nil {
+		t.Fatal("deposit transaction accepted over RPC")
+	}
+}
+
+// Tests that the L1 data fee quoted over RPC follows the gas price oracle, and
+// is zero for deposits and on chains that are not rollups.
+func TestL1DataFee(t *testing.T) {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetState(core.L1BlockAddr, core.L1BaseFeeSlot, common.BigToHash(big.NewInt(1000)))
+	statedb.SetState(core.OVM_GasPriceOracleAddr, core.OverheadSlot, common.BigToHash(big.NewInt(2100)))
+	statedb.SetState(core.OVM_GasPriceOracleAddr, core.ScalarSlot, common.BigToHash(big.NewInt(1_000_000)))
+	statedb.SetState(core.OVM_GasPriceOracleAddr, core.DecimalsSlot, common.BigToHash(big.NewInt(6)))
+
+	var (
+		header = &types.Header{Number: big.NewInt(1)}
+		tx     = types.NewTransaction(0, testTo, big.NewInt(1), 21000, big.NewInt(1), []byte{1, 2, 3})
+		config = *params.TestChainConfig
+	)
+	if fee := l1DataFee(&config, statedb, header, tx); fee.Sign() != 0 {
+		t.Errorf("L1 data fee charged without rollup config: %v", fee)
+	}
+	config.Optimism = &params.OptimismConfig{}
+	want := new(big.Int).SetUint64((tx.RollupDataGas() + 2100) * 1000)
+	if fee := l1DataFee(&config, statedb, header, tx); fee.Cmp(want) != 0 {
+		t.Errorf("L1 data fee mismatch: have %v, want %v", fee, want)
+	}
+	if fee := l1DataFee(&config, statedb, header, testDeposit); fee.Sign() != 0 {
+		t.Errorf("L1 data fee charged for deposit: %v", fee)
+	}
+}
+
+// Tests that receipts report the gas price that was paid, which is zero for
+// deposits, rather than the base fee of their block.
+func TestEffectiveGasPrice(t *testing.T) {
+	var (
+		baseFee = big.NewInt(1000)
+		legacy  = types.NewTransaction(0, testTo, new(big.Int), 21000, big.NewInt(1500), nil)
+		dynamic = types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(100), GasFeeCap: big.NewInt(2000), Gas: 21000})
+	)
+	tests := []struct {
+		tx      *types.Transaction
+		baseFee *big.Int
+		want    int64
+	}{
+		{testDeposit, nil, 0},
+		{testDeposit, baseFee, 0},
+		{legacy, nil, 1500},
+		{legacy, baseFee, 1500},
+		{dynamic, baseFee, 1100},
+	}
+	for i, test := range tests {
+		if have := effectiveGasPrice(test.tx, test.baseFee); have.Cmp(big.NewInt(test.want)) != 0 {
+			t.Errorf("test %d: effective gas price mismatch: have %v, want %d", i, have, test.want)
+		}
+	}
+}
+
+// Tests that calls with deposit overrides execute like deposits: the depositor
+// needs no funds besides the minted value, and a failing deposit keeps the mint.
+func TestDepositCall(t *testing.T) {
+	var (
+		config  = params.TestChainConfig
+		header  = &types.Header{Number: big.NewInt(1), BaseFee: big.NewInt(params.InitialBaseFee), Difficulty: new(big.Int)}
+		deposit = &DepositOverrides{SourceHash: common.HexToHash("0x01"), Mint: (*hexutil.Big)(big.NewInt(100))}
+		gas     = hexutil.Uint64(50000)
+	)
+	call := func(value int64) (*state.StateDB, *core.ExecutionResult) {
+		t.Helper()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		args := TransactionArgs{From: &testFrom, To: &testTo, Value: (*hexutil.Big)(big.NewInt(value)), Gas: &gas}
+		msg, err := args.ToDepositMessage(0, config.ChainID, deposit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Nonce() != types.DepositsNonce || msg.Mint().Cmp(big.NewInt(100)) != 0 {
+			t.Fatalf("not a deposit message: nonce %d, mint %v", msg.Nonce(), msg.Mint())
+		}
+		blockCtx := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+		evm := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, config, vm.Config{NoBaseFee: true})
+		result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(uint64(gas)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return statedb, result
+	}
+	statedb, result := call(10)
+	if result.Err != nil {
+		t.Fatalf("deposit failed: %v", result.Err)
+	}
+	if balance := statedb.GetBalance(testTo); balance.Cmp(big.NewInt(10)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 10", balance)
+	}
+	statedb, result = call(1000)
+	if result.Err == nil {
+		t.Fatal("deposit of more than the minted value succeeded")
+	}
+	if balance := statedb.GetBalance(testFrom); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("depositor balance mismatch: have %v, want the minted 100", balance)
+	}
+
+	nonce := hexutil.Uint64(1)
+	for _, args := range []TransactionArgs{
+		{From: &testFrom, GasPrice: (*hexutil.Big)(big.NewInt(1))},
+		{From: &testFrom, Nonce: &nonce},
+	} {
+		if _, err := args.ToDepositMessage(0, config.ChainID, deposit); err == nil {
+			t.Errorf("deposit message with fees or nonce accepted: %+v", args)
+		}
+	}
+}
+
+// historicalBackend is a backend of a chain without blocks or pooled
+// transactions, that records the requests forwarded to the legacy node.
+type historicalBackend struct {
+	Backend
+	config    *params.ChainConfig
+	noLegacy  bool // no legacy node is configured
+	forwarded []string
+}
+
+func (b *historicalBackend) ChainConfig() *params.ChainConfig { return b.config }
+
+func (b *historicalBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return nil, nil
+}
+
+func (b *historicalBackend) GetTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	return nil, common.Hash{}, 0, 0, nil
+}
+
+func (b *historicalBackend) GetPoolTransaction(hash common.Hash) *types.Transaction { return nil }
+
+func (b *historicalBackend) CallHistorical(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if b.noLegacy {
+		return ErrNoHistoricalRPC
+	}
+	b.forwarded = append(b.forwarded, method)
+	return nil
+}
+
+// Tests that lookups of unknown hashes are forwarded to the legacy node, but
+// only on chains that were migrated from a legacy chain.
+func TestHistoricalHashLookups(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		hash = common.HexToHash("0x1234")
+	)
+	for _, migrated := range []bool{false, true} {
+		config := *params.TestChainConfig
+		config.Optimism = &params.OptimismConfig{}
+		if migrated {
+			config.Optimism.MigrationBlock = big.NewInt(100)
+		}
+		b := &historicalBackend{config: &config}
+		if _, err := NewBlockChainAPI(b).GetBlockByHash(ctx, hash, false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewTransactionAPI(b, nil).GetTransactionByHash(ctx, hash); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewTransactionAPI(b, nil).GetTransactionReceipt(ctx, hash); err != nil {
+			t.Fatal(err)
+		}
+		var want []string
+		if migrated {
+			want = []string{"eth_getBlockByHash", "eth_getTransactionByHash", "eth_getTransactionReceipt"}
+		}
+		if !reflect.DeepEqual(b.forwarded, want) {
+			t.Errorf("migrated %t: forwarded %v, want %v", migrated, b.forwarded, want)
+		}
+	}
+}
+
+// Tests that state queries at blocks before the migration block are forwarded
+// to the legacy node, and rejected if no legacy node is configured.
+func TestHistoricalStateQueries(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		block  = rpc.BlockNumberOrHashWithNumber(50)
+		config = *params.TestChainConfig
+	)
+	config.Optimism = &params.OptimismConfig{MigrationBlock: big.NewInt(100)}
+
+	for _, noLegacy := range []bool{false, true} {
+		var (
+			b      = &historicalBackend{config: &config, noLegacy: noLegacy}
+			api    = NewBlockChainAPI(b)
+			errs   []error
+			record = func(_ interface{}, err error) { errs = append(errs, err) }
+		)
+		record(api.GetBalance(ctx, testFrom, block))
+		record(api.GetCode(ctx, testFrom, block))
+		record(api.GetStorageAt(ctx, testFrom, "0x0", block))
+		record(api.Call(ctx, TransactionArgs{From: &testFrom}, block, nil, nil))
+		record(NewTransactionAPI(b, nil).GetTransactionCount(ctx, testFrom, block))
+
+		var (
+			want    []string
+			wantErr error
+		)
+		if noLegacy {
+			wantErr = ErrPreMigrationState
+		} else {
+			want = []string{"eth_getBalance", "eth_getCode", "eth_getStorageAt", "eth_call", "eth_getTransactionCount"}
+		}
+		for i, err := range errs {
+			if err != wantErr {
+				t.Errorf("no legacy node %t: query %d: error %v, want %v", noLegacy, i, err, wantErr)
+			}
+		}
+		if !reflect.DeepEqual(b.forwarded, want) {
+			t.Errorf("no legacy node %t: forwarded %v, want %v", noLegacy, b.forwarded, want)
+		}
+	}
+}
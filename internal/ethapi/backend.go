@@ -19,6 +19,7 @@ package ethapi
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"time"
 
@@ -37,6 +38,10 @@ import (
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// ErrNoHistoricalRPC is returned by Backend.CallHistorical if no legacy node
+// serves the history of the chain.
+var ErrNoHistoricalRPC = errors.New("no historical RPC endpoint configured")
+
 // Backend interface provides the common API services (that are provided by
 // both full and light clients) with access to necessary functions.
 type Backend interface {
@@ -94,6 +99,57 @@ type Backend interface {
 
 	ChainConfig() *params.ChainConfig
 	Engine() consensus.Engine
+
+	// Rollup API
+	HistoricalBackend
+}
+
+// HistoricalBackend forwards requests for the history of a migrated rollup
+// chain, before its migration block, to the legacy node.
+type HistoricalBackend interface {
+	// CallHistorical forwards a request to the legacy node. It returns
+	// ErrNoHistoricalRPC if no legacy node is configured.
+	CallHistorical(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// CallHistorical forwards a request to the legacy node of a migrated rollup
+// chain, if one is configured. It reports whether the request was forwarded.
+func CallHistorical(ctx context.Context, b HistoricalBackend, result interface{}, method string, args ...interface{}) (bool, error) {
+	err := b.CallHistorical(ctx, result, method, args...)
+	if errors.Is(err, ErrNoHistoricalRPC) {
+		return false, nil
+	}
+	return true, err
+}
+
+// ErrPreMigrationState is returned for state queries at blocks before the
+// migration block of a migrated rollup chain, whose state the local node does
+// not have, if no legacy node is configured to forward them to.
+var ErrPreMigrationState = errors.New("pre-migration state is served by the legacy node")
+
+// callHistoricalState forwards a state query at a block of the legacy chain to
+// the legacy node. It reports whether the block is a legacy block: a block
+// number before the migration block, or a block hash unknown to the local node.
+// Queries at legacy block numbers fail with ErrPreMigrationState if no legacy
+// node is configured, while unknown hashes are looked up locally as usual.
+func callHistoricalState(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, result interface{}, method string, args ...interface{}) (bool, error) {
+	if !b.ChainConfig().IsMigrated() {
+		return false, nil
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		if number < 0 || !b.ChainConfig().IsHistorical(big.NewInt(number.Int64())) {
+			return false, nil
+		}
+		if ok, err := CallHistorical(ctx, b, result, method, args...); ok {
+			return true, err
+		}
+		return true, ErrPreMigrationState
+	}
+	hash, _ := blockNrOrHash.Hash()
+	if header, err := b.HeaderByHash(ctx, hash); header != nil || err != nil {
+		return false, nil
+	}
+	return CallHistorical(ctx, b, result, method, args...)
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
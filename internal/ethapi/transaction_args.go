@@ -249,6 +249,44 @@ func (args *TransactionArgs) ToMessage(globalGasCap uint64, baseFee *big.Int) (t
 	return msg, nil
 }
 
+// ToDepositMessage converts the transaction arguments to the message of a
+// deposit transaction with the given deposit fields, which is executed like the
+// L2 chain executes the deposits of L1. Deposits do not pay fees and have no
+// nonce, so the fee and nonce arguments must not be set.
+func (args *TransactionArgs) ToDepositMessage(globalGasCap uint64, chainID *big.Int, deposit *DepositOverrides) (types.Message, error) {
+	if args.GasPrice != nil || args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil {
+		return types.Message{}, errors.New("deposits do not pay fees, gasPrice, maxFeePerGas and maxPriorityFeePerGas must not be specified")
+	}
+	if args.Nonce != nil {
+		return types.Message{}, errors.New("deposits do not have a nonce")
+	}
+	gas := globalGasCap
+	if gas == 0 {
+		gas = uint64(math.MaxUint64 / 2)
+	}
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	}
+	if globalGasCap != 0 && globalGasCap < gas {
+		log.Warn("Caller gas above allowance, capping", "requested", gas, "cap", globalGasCap)
+		gas = globalGasCap
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	tx := types.NewTx(&types.DepositTx{
+		SourceHash: deposit.SourceHash,
+		From:       args.from(),
+		To:         args.To,
+		Mint:       (*big.Int)(deposit.Mint),
+		Value:      value,
+		Gas:        gas,
+		Data:       args.data(),
+	})
+	return tx.AsMessage(types.LatestSignerForChainID(chainID), nil)
+}
+
 // toTransaction converts the arguments to a transaction.
 // This assumes that setDefaults has been called.
 func (args *TransactionArgs) toTransaction() *types.Transaction {
@@ -408,6 +408,25 @@ func (c *CliqueConfig) String() string {
 type OptimismConfig struct {
 	BaseFeeRecipient common.Address `json:"baseFeeRecipient"`
 	L1FeeRecipient   common.Address `json:"l1FeeRecipient"`
+
+	// MigrationBlock is the first block of a chain that was migrated from a
+	// legacy chain, nil if the chain was not migrated. The history before it is
+	// served by the legacy node.
+	MigrationBlock *big.Int `json:"migrationBlock,omitempty"`
+
+	// Rollup upgrades are scheduled by block timestamp, which L2 blocks derive
+	// from their L1 origin, rather than by block number.
+	RegolithTime *uint64 `json:"regolithTime,omitempty"` // Regolith switch time (nil = no fork, 0 = already on regolith)
+
+	// DepositGasExemptTime is the time from which the gas of deposits, which
+	// is paid on L1, no longer counts toward the block gas limit, so that many
+	// deposits in an epoch cannot crowd out user transactions (nil = never).
+	DepositGasExemptTime *uint64 `json:"depositGasExemptTime,omitempty"`
+
+	// L1InfoCheckTime is the time from which every block must start with a
+	// well formed L1 info deposit, whose L1 origin follows the origin of the
+	// parent block (nil = never).
+	L1InfoCheckTime *uint64 `json:"l1InfoCheckTime,omitempty"`
 }
 
 // String implements the stringer interface, returning the optimism fee config details.
@@ -444,6 +463,15 @@ func (c *ChainConfig) String() string {
 		}
 	case c.Optimism != nil:
 		banner += "Consensus: Optimism\n"
+		if c.Optimism.RegolithTime != nil {
+			banner += fmt.Sprintf(" - Regolith:                    @%-10v\n", *c.Optimism.RegolithTime)
+		}
+		if c.Optimism.DepositGasExemptTime != nil {
+			banner += fmt.Sprintf(" - Deposit gas exemption:       @%-10v\n", *c.Optimism.DepositGasExemptTime)
+		}
+		if c.Optimism.L1InfoCheckTime != nil {
+			banner += fmt.Sprintf(" - L1 info check:               @%-10v\n", *c.Optimism.L1InfoCheckTime)
+		}
 	default:
 		banner += "Consensus: unknown\n"
 	}
@@ -559,6 +587,40 @@ func (c *ChainConfig) IsLondon(num *big.Int) bool {
 	return isForked(c.LondonBlock, num)
 }
 
+// IsHistorical returns whether num is a block of the legacy chain, before the
+// migration block of a migrated rollup chain.
+func (c *ChainConfig) IsHistorical(num *big.Int) bool {
+	if !c.IsMigrated() || num == nil {
+		return false
+	}
+	return num.Cmp(c.Optimism.MigrationBlock) < 0
+}
+
+// IsMigrated returns whether the chain is a rollup chain migrated from a legacy
+// chain, whose blocks and transactions are unknown to the local node.
+func (c *ChainConfig) IsMigrated() bool {
+	return c.Optimism != nil && c.Optimism.MigrationBlock != nil
+}
+
+// IsRegolith returns whether time is either equal to the Regolith fork time or
+// greater. Regolith changes the gas accounting of deposits.
+func (c *ChainConfig) IsRegolith(time uint64) bool {
+	return c.Optimism != nil && isTimestampForked(c.Optimism.RegolithTime, time)
+}
+
+// IsDepositGasExempt returns whether time is either equal to the time from which
+// deposits are exempt from the block gas limit or greater. Exempt deposits are
+// recorded as using no block gas, like system transactions.
+func (c *ChainConfig) IsDepositGasExempt(time uint64) bool {
+	return c.Optimism != nil && isTimestampForked(c.Optimism.DepositGasExemptTime, time)
+}
+
+// IsL1InfoChecked returns whether time is either equal to the time from which
+// the L1 info deposits of blocks are validated or greater.
+func (c *ChainConfig) IsL1InfoChecked(time uint64) bool {
+	return c.Optimism != nil && isTimestampForked(c.Optimism.L1InfoCheckTime, time)
+}
+
 // IsArrowGlacier returns whether num is either equal to the Arrow Glacier (EIP-4345) fork block or greater.
 func (c *ChainConfig) IsArrowGlacier(num *big.Int) bool {
 	return isForked(c.ArrowGlacierBlock, num)
@@ -589,18 +651,24 @@ func (c *ChainConfig) IsCancun(num *big.Int) bool {
 
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
-func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
-	bhead := new(big.Int).SetUint64(height)
-
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64, time uint64) *ConfigCompatError {
+	var (
+		bhead = new(big.Int).SetUint64(height)
+		btime = time
+	)
 	// Iterate checkCompatible to find the lowest conflict.
 	var lasterr *ConfigCompatError
 	for {
-		err := c.checkCompatible(newcfg, bhead)
-		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo) {
+		err := c.checkCompatible(newcfg, bhead, btime)
+		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo && err.RewindToTime == lasterr.RewindToTime) {
 			break
 		}
 		lasterr = err
-		bhead.SetUint64(err.RewindTo)
+		if err.RewindToTime > 0 {
+			btime = err.RewindToTime
+		} else {
+			bhead.SetUint64(err.RewindTo)
+		}
 	}
 	return lasterr
 }
@@ -654,7 +722,7 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 	return nil
 }
 
-func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int, time uint64) *ConfigCompatError {
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
 	}
@@ -716,9 +784,39 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.CancunBlock, newcfg.CancunBlock, head) {
 		return newCompatError("Cancun fork block", c.CancunBlock, newcfg.CancunBlock)
 	}
+	if isTimestampForkIncompatible(c.regolithTime(), newcfg.regolithTime(), time) {
+		return newTimestampCompatError("Regolith fork timestamp", c.regolithTime(), newcfg.regolithTime())
+	}
+	if isTimestampForkIncompatible(c.depositGasExemptTime(), newcfg.depositGasExemptTime(), time) {
+		return newTimestampCompatError("Deposit gas exemption timestamp", c.depositGasExemptTime(), newcfg.depositGasExemptTime())
+	}
+	if isTimestampForkIncompatible(c.l1InfoCheckTime(), newcfg.l1InfoCheckTime(), time) {
+		return newTimestampCompatError("L1 info check timestamp", c.l1InfoCheckTime(), newcfg.l1InfoCheckTime())
+	}
 	return nil
 }
 
+func (c *ChainConfig) regolithTime() *uint64 {
+	if c.Optimism == nil {
+		return nil
+	}
+	return c.Optimism.RegolithTime
+}
+
+func (c *ChainConfig) depositGasExemptTime() *uint64 {
+	if c.Optimism == nil {
+		return nil
+	}
+	return c.Optimism.DepositGasExemptTime
+}
+
+func (c *ChainConfig) l1InfoCheckTime() *uint64 {
+	if c.Optimism == nil {
+		return nil
+	}
+	return c.Optimism.L1InfoCheckTime
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -733,6 +831,31 @@ func isForked(s, head *big.Int) bool {
 	return s.Cmp(head) <= 0
 }
 
+// isTimestampForkIncompatible returns true if a fork scheduled at timestamp s1
+// cannot be rescheduled to timestamp s2 because head is already past the fork.
+func isTimestampForkIncompatible(s1, s2 *uint64, head uint64) bool {
+	return (isTimestampForked(s1, head) || isTimestampForked(s2, head)) && !configTimestampEqual(s1, s2)
+}
+
+// isTimestampForked returns whether a fork scheduled at timestamp s is active
+// at the given head timestamp.
+func isTimestampForked(s *uint64, head uint64) bool {
+	if s == nil {
+		return false
+	}
+	return *s <= head
+}
+
+func configTimestampEqual(x, y *uint64) bool {
+	if x == nil {
+		return y == nil
+	}
+	if y == nil {
+		return x == nil
+	}
+	return *x == *y
+}
+
 func configNumEqual(x, y *big.Int) bool {
 	if x == nil {
 		return y == nil
@@ -747,10 +870,15 @@ func configNumEqual(x, y *big.Int) bool {
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
 	What string
-	// block numbers of the stored and new configurations
+	// block numbers of the stored and new configurations if block based forking
 	StoredConfig, NewConfig *big.Int
+	// timestamps of the stored and new configurations if time based forking
+	StoredTime, NewTime *uint64
 	// the block number to which the local chain must be rewound to correct the error
 	RewindTo uint64
+	// the timestamp before which the local chain must be rewound to correct the
+	// error, the block number to rewind to is resolved by the caller
+	RewindToTime uint64
 }
 
 func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatError {
@@ -763,17 +891,44 @@ func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatEr
 	default:
 		rew = newblock
 	}
-	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	err := &ConfigCompatError{What: what, StoredConfig: storedblock, NewConfig: newblock}
 	if rew != nil && rew.Sign() > 0 {
 		err.RewindTo = rew.Uint64() - 1
 	}
 	return err
 }
 
+func newTimestampCompatError(what string, storedtime, newtime *uint64) *ConfigCompatError {
+	var rew *uint64
+	switch {
+	case storedtime == nil:
+		rew = newtime
+	case newtime == nil || *storedtime < *newtime:
+		rew = storedtime
+	default:
+		rew = newtime
+	}
+	err := &ConfigCompatError{What: what, StoredTime: storedtime, NewTime: newtime}
+	if rew != nil && *rew > 0 {
+		err.RewindToTime = *rew - 1
+	}
+	return err
+}
+
 func (err *ConfigCompatError) Error() string {
+	if err.StoredTime != nil || err.NewTime != nil {
+		return fmt.Sprintf("mismatching %s in database (have timestamp %s, want timestamp %s, rewindto timestamp %d)", err.What, timestampString(err.StoredTime), timestampString(err.NewTime), err.RewindToTime)
+	}
 	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
 
+func timestampString(t *uint64) string {
+	if t == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*t)
+}
+
 // Rules wraps ChainConfig and is merely syntactic sugar or can be used for functions
 // that do not have or require information about the block.
 //
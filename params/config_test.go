@@ -24,9 +24,9 @@ import (
 
 func TestCheckCompatible(t *testing.T) {
 	type test struct {
-		stored, new *ChainConfig
-		head        uint64
-		wantErr     *ConfigCompatError
+		stored, new    *ChainConfig
+		head, headTime uint64
+		wantErr        *ConfigCompatError
 	}
 	tests := []test{
 		{stored: AllEthashProtocolChanges, new: AllEthashProtocolChanges, head: 0, wantErr: nil},
@@ -87,12 +87,94 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:   &ChainConfig{Optimism: &OptimismConfig{RegolithTime: newUint64(10)}},
+			new:      &ChainConfig{Optimism: &OptimismConfig{RegolithTime: newUint64(20)}},
+			headTime: 9,
+			wantErr:  nil,
+		},
+		{
+			stored:   &ChainConfig{Optimism: &OptimismConfig{RegolithTime: newUint64(10)}},
+			new:      &ChainConfig{Optimism: &OptimismConfig{RegolithTime: newUint64(20)}},
+			headTime: 25,
+			wantErr: &ConfigCompatError{
+				What:         "Regolith fork timestamp",
+				StoredTime:   newUint64(10),
+				NewTime:      newUint64(20),
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored:   &ChainConfig{Optimism: &OptimismConfig{}},
+			new:      &ChainConfig{Optimism: &OptimismConfig{RegolithTime: newUint64(20)}},
+			headTime: 25,
+			wantErr: &ConfigCompatError{
+				What:         "Regolith fork timestamp",
+				StoredTime:   nil,
+				NewTime:      newUint64(20),
+				RewindToTime: 19,
+			},
+		},
+		{
+			stored:   &ChainConfig{Optimism: &OptimismConfig{DepositGasExemptTime: newUint64(10)}},
+			new:      &ChainConfig{Optimism: &OptimismConfig{}},
+			headTime: 25,
+			wantErr: &ConfigCompatError{
+				What:         "Deposit gas exemption timestamp",
+				StoredTime:   newUint64(10),
+				NewTime:      nil,
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored:   &ChainConfig{Optimism: &OptimismConfig{}},
+			new:      &ChainConfig{Optimism: &OptimismConfig{L1InfoCheckTime: newUint64(20)}},
+			headTime: 25,
+			wantErr: &ConfigCompatError{
+				What:         "L1 info check timestamp",
+				StoredTime:   nil,
+				NewTime:      newUint64(20),
+				RewindToTime: 19,
+			},
+		},
 	}
 
 	for _, test := range tests {
-		err := test.stored.CheckCompatible(test.new, test.head)
+		err := test.stored.CheckCompatible(test.new, test.head, test.headTime)
 		if !reflect.DeepEqual(err, test.wantErr) {
 			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, err, test.wantErr)
 		}
 	}
 }
+
+func newUint64(val uint64) *uint64 { return &val }
+
+func TestIsRegolith(t *testing.T) {
+	config := &ChainConfig{Optimism: &OptimismConfig{RegolithTime: newUint64(10)}}
+	if config.IsRegolith(9) || !config.IsRegolith(10) || !config.IsRegolith(11) {
+		t.Error("wrong Regolith activation")
+	}
+	if (&ChainConfig{Optimism: &OptimismConfig{}}).IsRegolith(10) || AllEthashProtocolChanges.IsRegolith(10) {
+		t.Error("Regolith active without being scheduled")
+	}
+}
+
+func TestIsDepositGasExempt(t *testing.T) {
+	config := &ChainConfig{Optimism: &OptimismConfig{DepositGasExemptTime: newUint64(10)}}
+	if config.IsDepositGasExempt(9) || !config.IsDepositGasExempt(10) || !config.IsDepositGasExempt(11) {
+		t.Error("wrong deposit gas exemption activation")
+	}
+	if (&ChainConfig{Optimism: &OptimismConfig{}}).IsDepositGasExempt(10) || AllEthashProtocolChanges.IsDepositGasExempt(10) {
+		t.Error("deposit gas exemption active without being scheduled")
+	}
+}
+
+func TestIsL1InfoChecked(t *testing.T) {
+	config := &ChainConfig{Optimism: &OptimismConfig{L1InfoCheckTime: newUint64(10)}}
+	if config.IsL1InfoChecked(9) || !config.IsL1InfoChecked(10) || !config.IsL1InfoChecked(11) {
+		t.Error("wrong L1 info check activation")
+	}
+	if (&ChainConfig{Optimism: &OptimismConfig{}}).IsL1InfoChecked(10) || AllEthashProtocolChanges.IsL1InfoChecked(10) {
+		t.Error("L1 info check active without being scheduled")
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package actions tests the rollup deterministically. Unlike the end-to-end
+// tests, nothing runs on timers: the test advances the L1 chain, the sequencer,
+// the batcher and the verifier one action at a time, so that reorgs, missing
+// batches and sequencer drift can be arranged exactly.
+//
+// The actors run on the in-memory L1 chain and engines of the testutils
+// package, which do not execute transactions.
+package actions
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/driver"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+var (
+	// BatcherKey is the key of the batch sender of the test rollup.
+	BatcherKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	// DepositorKey is the key of the sender of the L1 deposit transactions.
+	DepositorKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+)
+
+// maxSteps bounds the derivation steps of a single action, to fail tests that
+// would never run out of L1 data.
+const maxSteps = 10_000
+
+// Setup is a rollup on an in-memory L1 chain.
+type Setup struct {
+	Cfg       *rollup.Config
+	L1        *L1Miner
+	L2Genesis *types.Block
+}
+
+// NewSetup creates a rollup whose genesis is anchored at the genesis of a new
+// L1 chain. The L2 block time is 2 seconds, half the L1 block time.
+func NewSetup() *Setup {
+	l1 := testutils.NewL1Chain()
+	l2Genesis := types.NewBlockWithHeader(&types.Header{
+		Number:     new(big.Int),
+		Time:       l1.Head().Time(),
+		BaseFee:    big.NewInt(1),
+		Difficulty: new(big.Int),
+	})
+	cfg := &rollup.Config{
+		Genesis: rollup.Genesis{
+			L1:     rollup.BlockID{Hash: l1.Head().Hash(), Number: 0},
+			L2:     rollup.BlockID{Hash: l2Genesis.Hash(), Number: 0},
+			L2Time: l2Genesis.Time(),
+		},
+		BlockTime:              2,
+		MaxSequencerDrift:      20,
+		SeqWindowSize:          10,
+		L1ChainID:              big.NewInt(900),
+		L2ChainID:              big.NewInt(901),
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     crypto.PubkeyToAddress(BatcherKey.PublicKey),
+		DepositContractAddress: common.HexToAddress("0xde90517000000000000000000000000000000001"),
+		FeeRecipientAddress:    derive.SequencerFeeVaultAddr,
+	}
+	return &Setup{
+		Cfg:       cfg,
+		L1:        &L1Miner{L1Chain: l1, cfg: cfg},
+		L2Genesis: l2Genesis,
+	}
+}
+
+// L1Miner builds the L1 chain out of the transactions that were included.
+type L1Miner struct {
+	*testutils.L1Chain
+	cfg *rollup.Config
+
+	pending []*types.Transaction
+	logs    [][]*types.Log
+	nonce   uint64 // of the deposit transactions
+}
+
+// ActIncludeTx adds a transaction to the next L1 block.
+func (m *L1Miner) ActIncludeTx(txs ...*types.Transaction) {
+	for _, tx := range txs {
+		m.pending = append(m.pending, tx)
+		m.logs = append(m.logs, nil)
+	}
+}
+
+// ActDeposit adds a transaction to the next L1 block, which emits the event of
+// the given deposit from the deposit contract. The transaction is sent with the
+// depositor key, deposits from any other account are aliased like the deposits
+// of L1 contracts.
+func (m *L1Miner) ActDeposit(dep *types.DepositTx) {
+	signer := types.LatestSignerForChainID(m.cfg.L1ChainID)
+	tx := types.MustSignNewTx(DepositorKey, signer, &types.LegacyTx{Nonce: m.nonce, To: &m.cfg.DepositContractAddress, Gas: 100_000, GasPrice: big.NewInt(10)})
+	m.nonce++
+	m.pending = append(m.pending, tx)
+	m.logs = append(m.logs, []*types.Log{derive.MarshalDepositLogEvent(m.cfg.DepositContractAddress, dep)})
+}
+
+// ActBuildBlock adds a block with the included transactions to the chain.
+func (m *L1Miner) ActBuildBlock() *types.Block {
+	block := m.AddBlockWithLogs(m.pending, m.logs)
+	m.pending, m.logs = nil, nil
+	return block
+}
+
+// ActBuildBlocks adds n blocks to the chain. The included transactions go into
+// the first one.
+func (m *L1Miner) ActBuildBlocks(n int) {
+	for i := 0; i < n; i++ {
+		m.ActBuildBlock()
+	}
+}
+
+// Sequencer builds L2 blocks on its own engine.
+type Sequencer struct {
+	*driver.Sequencer
+	Engine *testutils.Engine
+
+	l1 *L1Miner
+}
+
+// NewSequencer creates a sequencer that builds on top of the L2 genesis block.
+func (s *Setup) NewSequencer() *Sequencer {
+	engine := testutils.NewEngine(s.L2Genesis)
+	return &Sequencer{
+		Sequencer: driver.NewSequencer(s.Cfg, s.L1, engine, s.Cfg.L2GenesisRef(), log.New("role", "sequencer")),
+		Engine:    engine,
+		l1:        s.L1,
+	}
+}
+
+// ActBuildL2Block builds the next L2 block, which includes the transactions
+// that were added to the pool of the engine.
+func (s *Sequencer) ActBuildL2Block(t *testing.T) rollup.L2BlockRef {
+	t.Helper()
+	ref, err := s.BuildBlock(context.Background())
+	if err != nil {
+		t.Fatalf("failed to build L2 block %d: %v", s.Head().Number+1, err)
+	}
+	return ref
+}
+
+// ActBuildToL1Head builds L2 blocks until the L1 origin of the head is the head
+// of the L1 chain.
+func (s *Sequencer) ActBuildToL1Head(t *testing.T) {
+	t.Helper()
+	for s.Head().L1Origin.Number < s.l1.Head().NumberU64() {
+		s.ActBuildL2Block(t)
+	}
+}
+
+// Block returns the sequenced block with the given number, on the chain of the
+// sequencer head.
+func (s *Sequencer) Block(number uint64) *types.Block {
+	block := s.Engine.Blocks[s.Head().Hash]
+	for block != nil && block.NumberU64() > number {
+		block = s.Engine.Blocks[block.ParentHash()]
+	}
+	return block
+}
+
+// Batcher posts the blocks of the sequencer to the batch inbox. Its
+// transactions are returned to the test, which decides when and whether they
+// are included in L1.
+type Batcher struct {
+	// Submitted is the last block that was submitted.
+	Submitted rollup.BlockID
+	// MaxFrameSize is the maximum size of a channel frame, and thus of the
+	// data of a batch transaction.
+	MaxFrameSize int
+
+	cfg       *rollup.Config
+	sequencer *Sequencer
+	signer    types.Signer
+	nonce     uint64
+}
+
+// NewBatcher creates a batcher that submits the blocks of the given sequencer.
+func (s *Setup) NewBatcher(sequencer *Sequencer) *Batcher {
+	return &Batcher{
+		Submitted:    s.Cfg.Genesis.L2,
+		MaxFrameSize: 120_000,
+		cfg:          s.Cfg,
+		sequencer:    sequencer,
+		signer:       types.LatestSignerForChainID(s.Cfg.L1ChainID),
+	}
+}
+
+// ActSubmitAll submits all blocks of the sequencer after the last submitted one
+// in a new channel, and returns the transactions that carry its frames.
+func (b *Batcher) ActSubmitAll(t *testing.T) []*types.Transaction {
+	t.Helper()
+	head := b.sequencer.Head()
+	if head.Number <= b.Submitted.Number {
+		return nil
+	}
+	var batches []*derive.BatchData
+	for n := b.Submitted.Number + 1; n <= head.Number; n++ {
+		batch, err := derive.BlockToBatch(b.sequencer.Block(n))
+		if err != nil {
+			t.Fatalf("failed to convert L2 block %d to a batch: %v", n, err)
+		}
+		batches = append(batches, batch)
+	}
+	frames, err := derive.EncodeChannel(derive.Zlib, batches, b.MaxFrameSize-1)
+	if err != nil {
+		t.Fatalf("failed to encode channel: %v", err)
+	}
+	txs := make([]*types.Transaction, len(frames))
+	for i, f := range frames {
+		txs[i], err = types.SignNewTx(BatcherKey, b.signer, &types.DynamicFeeTx{
+			ChainID:   b.cfg.L1ChainID,
+			Nonce:     b.nonce,
+			To:        &b.cfg.BatchInboxAddress,
+			Gas:       1_000_000,
+			GasFeeCap: big.NewInt(10),
+			Data:      derive.EncodeFrames(f),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.nonce++
+	}
+	b.Submitted = rollup.BlockID{Hash: head.Hash, Number: head.Number}
+	return txs
+}
+
+// Verifier derives the L2 chain from L1 on its own engine.
+type Verifier struct {
+	*derive.Pipeline
+	Engine *testutils.Engine
+}
+
+// NewVerifier creates a verifier that derives the chain from the L2 genesis
+// block.
+func (s *Setup) NewVerifier(conf derive.Confirmations) *Verifier {
+	engine := testutils.NewEngine(s.L2Genesis)
+	return &Verifier{
+		Pipeline: derive.NewPipeline(s.Cfg, conf, s.L1, engine, s.Cfg.L2GenesisRef(), log.New("role", "verifier")),
+		Engine:   engine,
+	}
+}
+
+// ActDeriveAll derives L2 blocks until the pipeline runs out of L1 data, and
+// reports the new safe and finalized blocks to the engine.
+func (v *Verifier) ActDeriveAll(t *testing.T) {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; ; i++ {
+		if i == maxSteps {
+			t.Fatal("derivation did not run out of L1 data")
+		}
+		err := v.Step(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("derivation step failed: %v", err)
+		}
+	}
+	changed, err := v.Confirm(ctx)
+	if err != nil {
+		t.Fatalf("failed to confirm derived blocks: %v", err)
+	}
+	if changed {
+		if err := v.ForkchoiceUpdate(ctx); err != nil {
+			t.Fatalf("failed to update forkchoice: %v", err)
+		}
+	}
+}
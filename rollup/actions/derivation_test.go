@@ -0,0 +1,295 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package actions
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// checkSynced fails the test unless the verifier derived the chain of the
+// sequencer up to its head.
+func checkSynced(t *testing.T, seq *Sequencer, ver *Verifier) {
+	t.Helper()
+	if have, want := ver.Head(), seq.Head(); have != want {
+		t.Fatalf("verifier head %s (origin %v), sequencer head %s (origin %v)", have, have.L1Origin, want, want.L1Origin)
+	}
+	if ver.Engine.Forkchoice.HeadBlockHash != seq.Head().Hash {
+		t.Fatalf("verifier engine head %s, want %s", ver.Engine.Forkchoice.HeadBlockHash, seq.Head().Hash)
+	}
+}
+
+func TestDeriveSequencedChain(t *testing.T) {
+	s := NewSetup()
+	seq := s.NewSequencer()
+	batcher := s.NewBatcher(seq)
+	ver := s.NewVerifier(derive.Confirmations{})
+
+	to := common.HexToAddress("0x1234")
+	s.L1.ActDeposit(&types.DepositTx{From: common.HexToAddress("0xabcd"), To: &to, Mint: big.NewInt(7), Value: new(big.Int), Gas: 50_000})
+	s.L1.ActBuildBlocks(2)
+	seq.ActBuildToL1Head(t)
+
+	s.L1.ActIncludeTx(batcher.ActSubmitAll(t)...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	checkSynced(t, seq, ver)
+	if ver.SafeHead() != ver.Head() {
+		t.Fatalf("safe head %s, want %s", ver.SafeHead(), ver.Head())
+	}
+
+	// Only the first block of epoch 1 contains the deposit, after the L1 info.
+	var deposits []*types.Transaction
+	for n := uint64(1); n <= ver.Head().Number; n++ {
+		txs := ver.Engine.Blocks[seq.Block(n).Hash()].Transactions()
+		deposits = append(deposits, txs[1:]...)
+	}
+	if len(deposits) != 1 {
+		t.Fatalf("derived %d user deposits, want 1", len(deposits))
+	}
+	if dep := deposits[0]; dep.Type() != types.DepositTxType || *dep.To() != to || dep.Mint().Int64() != 7 {
+		t.Fatalf("unexpected deposit %+v", dep)
+	}
+}
+
+func TestDeriveMissingFrame(t *testing.T) {
+	s := NewSetup()
+	seq := s.NewSequencer()
+	batcher := s.NewBatcher(seq)
+	batcher.MaxFrameSize = 50
+	ver := s.NewVerifier(derive.Confirmations{})
+
+	s.L1.ActBuildBlocks(3)
+	seq.ActBuildToL1Head(t)
+	txs := batcher.ActSubmitAll(t)
+	if len(txs) < 3 {
+		t.Fatalf("channel has %d frames, want at least 3", len(txs))
+	}
+
+	// Without the last frame, the channel cannot be read.
+	last := len(txs) - 1
+	s.L1.ActIncludeTx(txs[:last]...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	if ver.Head() != s.Cfg.L2GenesisRef() {
+		t.Fatalf("derived %s from an incomplete channel", ver.Head())
+	}
+
+	// The missing frame completes the channel in a later L1 block.
+	s.L1.ActBuildBlock()
+	s.L1.ActIncludeTx(txs[last])
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	checkSynced(t, seq, ver)
+}
+
+func TestDeriveSkipsUnsubmittedBlocks(t *testing.T) {
+	s := NewSetup()
+	seq := s.NewSequencer()
+	batcher := s.NewBatcher(seq)
+	ver := s.NewVerifier(derive.Confirmations{})
+
+	// The batches of the first blocks are lost, the later ones cannot be
+	// derived without them.
+	s.L1.ActBuildBlock()
+	seq.ActBuildToL1Head(t)
+	batcher.ActSubmitAll(t)
+	s.L1.ActBuildBlock()
+	seq.ActBuildToL1Head(t)
+	s.L1.ActIncludeTx(batcher.ActSubmitAll(t)...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	if ver.Head() != s.Cfg.L2GenesisRef() {
+		t.Fatalf("derived %s without its parent batch", ver.Head())
+	}
+
+	// Once the lost batches are posted again, the whole chain is derived.
+	batcher.Submitted = s.Cfg.Genesis.L2
+	s.L1.ActIncludeTx(batcher.ActSubmitAll(t)...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	checkSynced(t, seq, ver)
+}
+
+func TestDeriveL1ReorgOfBatch(t *testing.T) {
+	s := NewSetup()
+	seq := s.NewSequencer()
+	batcher := s.NewBatcher(seq)
+	ver := s.NewVerifier(derive.Confirmations{SafeDepth: 2})
+
+	s.L1.ActBuildBlock()
+	seq.ActBuildToL1Head(t)
+	txs := batcher.ActSubmitAll(t)
+	s.L1.ActIncludeTx(txs...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	checkSynced(t, seq, ver)
+	if ver.SafeHead() != s.Cfg.L2GenesisRef() {
+		t.Fatalf("blocks safe at L1 depth 0: %s", ver.SafeHead())
+	}
+
+	// The L1 block with the batch is reorged out. The L1 origins of the
+	// sequenced blocks are still canonical, but the blocks must be derived
+	// again from L1. The reorg is only noticed once the new chain is longer
+	// than the derived one.
+	s.L1.Reorg(1)
+	s.L1.ActBuildBlocks(2)
+	ver.ActDeriveAll(t)
+	if ver.Head() != s.Cfg.L2GenesisRef() {
+		t.Fatalf("head %s not unwound after the batch was reorged out", ver.Head())
+	}
+
+	// The batches are posted again and included in the new chain.
+	s.L1.ActIncludeTx(txs...)
+	s.L1.ActBuildBlocks(3)
+	ver.ActDeriveAll(t)
+	checkSynced(t, seq, ver)
+	if ver.SafeHead() != seq.Head() {
+		t.Fatalf("safe head %s, want %s", ver.SafeHead(), seq.Head())
+	}
+}
+
+func TestSequencerDrift(t *testing.T) {
+	s := NewSetup()
+	seq := s.NewSequencer()
+
+	// Without new L1 blocks, the sequencer keeps the genesis epoch until the
+	// drift is exhausted.
+	maxBlocks := s.Cfg.MaxSequencerDrift / s.Cfg.BlockTime
+	for i := uint64(0); i < maxBlocks; i++ {
+		seq.ActBuildL2Block(t)
+	}
+	if _, err := seq.BuildBlock(context.Background()); err == nil {
+		t.Fatal("sequenced a block beyond the maximum drift")
+	}
+	if seq.Head().L1Origin != s.Cfg.Genesis.L1 {
+		t.Fatalf("unexpected L1 origin %v", seq.Head().L1Origin)
+	}
+
+	// Sequencing continues on the next L1 block. Its origin is in the past,
+	// so the next block adopts it right away.
+	s.L1.ActBuildBlock()
+	ref := seq.ActBuildL2Block(t)
+	if ref.L1Origin.Number != 1 || ref.SequenceNumber != 0 {
+		t.Fatalf("block %d has L1 origin %v, sequence number %d", ref.Number, ref.L1Origin, ref.SequenceNumber)
+	}
+}
+
+func TestDepositsCarryOver(t *testing.T) {
+	s := NewSetup()
+	s.Cfg.MaxDepositsPerBlock = 2
+	seq := s.NewSequencer()
+	batcher := s.NewBatcher(seq)
+	ver := s.NewVerifier(derive.Confirmations{})
+
+	// A flood of deposits in epoch 1 takes more blocks than the drift allows
+	// in the epoch. The next L1 blocks are available right away.
+	const count = 25
+	to := common.HexToAddress("0x1234")
+	for i := 0; i < count; i++ {
+		s.L1.ActDeposit(&types.DepositTx{From: common.HexToAddress("0xabcd"), To: &to, Mint: big.NewInt(int64(i + 1)), Value: new(big.Int), Gas: 50_000})
+	}
+	s.L1.ActBuildBlocks(5)
+	seq.ActBuildToL1Head(t)
+
+	// The epoch is only left once all deposits are included, in order.
+	var (
+		included int
+		last     *types.Block
+	)
+	for n := uint64(1); n <= seq.Head().Number; n++ {
+		block := seq.Block(n)
+		deposits := block.Transactions()[1:]
+		if len(deposits) > int(s.Cfg.MaxDepositsPerBlock) {
+			t.Fatalf("block %d includes %d deposits", n, len(deposits))
+		}
+		for _, dep := range deposits {
+			included, last = included+1, block
+			if want := int64(included); dep.Mint().Int64() != want {
+				t.Fatalf("block %d includes deposit %d, want %d", n, dep.Mint(), want)
+			}
+		}
+	}
+	if included != count {
+		t.Fatalf("included %d deposits, want %d", included, count)
+	}
+	if last.Time() <= s.L1.Block(1).Time()+s.Cfg.MaxSequencerDrift {
+		t.Fatalf("last deposit at time %d, within the sequencer drift", last.Time())
+	}
+
+	// The verifier spreads the deposits over the same blocks.
+	s.L1.ActIncludeTx(batcher.ActSubmitAll(t)...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	checkSynced(t, seq, ver)
+}
+
+func TestSequencingWindowExpiry(t *testing.T) {
+	s := NewSetup()
+	seq := s.NewSequencer()
+	batcher := s.NewBatcher(seq)
+	ver := s.NewVerifier(derive.Confirmations{})
+
+	// The sequencer includes a transaction and the deposit of epoch 1, but
+	// withholds the batches.
+	to := common.HexToAddress("0x1234")
+	seq.Engine.TxPool = []*types.Transaction{types.NewTx(&types.LegacyTx{To: &to, Gas: 21000, GasPrice: big.NewInt(10)})}
+	s.L1.ActDeposit(&types.DepositTx{From: common.HexToAddress("0xabcd"), To: &to, Mint: big.NewInt(7), Value: new(big.Int), Gas: 50_000})
+	s.L1.ActBuildBlock()
+	seq.ActBuildToL1Head(t)
+
+	// Nothing is derived while the window of the genesis epoch is open.
+	s.L1.ActBuildBlocks(int(s.Cfg.SeqWindowSize) - 2)
+	ver.ActDeriveAll(t)
+	if ver.Head() != s.Cfg.L2GenesisRef() {
+		t.Fatalf("derived %s within the sequencing window", ver.Head())
+	}
+
+	// Once it passed, the rest of the epoch is derived without transactions.
+	// The next block belongs to epoch 1, whose window is still open.
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	head := ver.Head()
+	if head.Number != 1 || head.L1Origin != s.Cfg.Genesis.L1 {
+		t.Fatalf("unexpected head %s with L1 origin %v", head, head.L1Origin)
+	}
+	if n := len(ver.Engine.Blocks[head.Hash].Transactions()); n != 1 {
+		t.Fatalf("empty block has %d transactions, want only the L1 info", n)
+	}
+
+	// Batches posted after the window are ignored. The blocks of epoch 1 only
+	// contain its deposit.
+	s.L1.ActIncludeTx(batcher.ActSubmitAll(t)...)
+	s.L1.ActBuildBlock()
+	ver.ActDeriveAll(t)
+	head = ver.Head()
+	if head.Number != 3 || head.L1Origin.Number != 1 || head.SequenceNumber != 1 {
+		t.Fatalf("unexpected head %s with L1 origin %v, sequence number %d", head, head.L1Origin, head.SequenceNumber)
+	}
+	first := ver.Engine.Blocks[ver.Engine.Blocks[head.Hash].ParentHash()]
+	if txs := first.Transactions(); len(txs) != 2 || txs[1].Type() != types.DepositTxType || txs[1].Mint().Int64() != 7 {
+		t.Fatalf("first block of epoch 1 has transactions %v, want the L1 info and the deposit", txs)
+	}
+	if first.Hash() == seq.Block(2).Hash() {
+		t.Fatal("derived the block of the withheld batch")
+	}
+}
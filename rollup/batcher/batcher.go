@@ -0,0 +1,557 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package batcher implements the batch submitter, which posts the batches of the
+// sequenced L2 blocks to the batch inbox on L1.
+package batcher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/txmgr"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Config contains the settings of the batch submitter.
+type Config struct {
+	L1ChainID         *big.Int
+	BatchInboxAddress common.Address
+
+	// MinSubmitSize is the amount of batch data that triggers a submission.
+	// Smaller amounts are submitted once the oldest block waited for MaxDelay.
+	MinSubmitSize uint64
+	// MaxSubmitSize is the maximum amount of channel data per L1 transaction.
+	MaxSubmitSize uint64
+	// MaxChannelSize is the maximum amount of uncompressed batch data per
+	// channel. The compressed channel is split into as many L1 transactions as
+	// needed.
+	MaxChannelSize uint64
+	// MaxDelay is the maximum time a block waits to be submitted.
+	MaxDelay time.Duration
+	// MaxGasPrice is the highest fee cap the submitter is willing to pay. When
+	// L1 is more expensive, the submission is postponed. Nil means no limit.
+	MaxGasPrice *big.Int
+	// ResubmitTimeout is the time after which a batch transaction that is not
+	// included yet is replaced by one with higher fees.
+	ResubmitTimeout time.Duration
+	// MaxInflight is the number of channels that may be submitted before the
+	// oldest one is confirmed. When enough blocks are queued, several channels
+	// are submitted at once. If a channel fails, the channels submitted after
+	// it are submitted again too.
+	MaxInflight int
+	// PollInterval is the interval at which L2 and pending submissions are polled.
+	PollInterval time.Duration
+
+	// CursorFile is where the last submitted L2 block is persisted.
+	CursorFile string
+}
+
+// DefaultConfig contains reasonable default settings.
+var DefaultConfig = Config{
+	MinSubmitSize:   64 * 1024,
+	MaxSubmitSize:   120 * 1024,
+	MaxChannelSize:  512 * 1024,
+	MaxDelay:        time.Minute,
+	ResubmitTimeout: 3 * time.Minute,
+	MaxInflight:     1,
+	PollInterval:    6 * time.Second,
+}
+
+// L1Client is the L1 API used by the batch submitter. It is implemented by
+// ethclient.Client.
+type L1Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// L2Client is the L2 API used by the batch submitter. It is implemented by
+// ethclient.Client. HeaderByNumber is also called with rpc.SafeBlockNumber, for
+// the safe head the rollup node set.
+type L2Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+}
+
+var errL2Reorg = errors.New("L2 chain reorged")
+
+// pendingBlock is an L2 block whose batch has not been submitted yet.
+type pendingBlock struct {
+	id    rollup.BlockID
+	batch *derive.BatchData
+	size  int
+	added time.Time
+}
+
+// batchTx is a frame transaction that was sent, but is not confirmed yet. It
+// keeps all transactions sent with its nonce, as any of them may be included
+// after a replacement.
+type batchTx struct {
+	txs     []*types.Transaction // oldest first
+	sent    time.Time            // time the last transaction was sent
+	receipt *types.Receipt       // receipt of the included transaction
+	lost    bool                 // the nonce was used by another transaction
+}
+
+func (b *batchTx) nonce() uint64 { return b.txs[0].Nonce() }
+
+// submission is a channel whose frame transactions were sent, but are not all
+// confirmed yet.
+type submission struct {
+	id         derive.ChannelID
+	txs        []*batchTx
+	last       rollup.BlockID // last L2 block included in the channel
+	incomplete bool           // not all frames could be sent
+}
+
+// Submitter collects the blocks of the L2 chain and submits their batches to L1.
+//
+// A block is only removed from the queue, and the cursor only advanced past it,
+// once its batch was confirmed on L1. After a restart, submission resumes from
+// the persisted cursor. A batch whose confirmation was not yet persisted when
+// the submitter stopped is submitted again, which is harmless: derivation
+// ignores batches of blocks it has already derived. Batch transactions of the
+// previous run that are still in the transaction pool are replaced, their nonces
+// are reused starting from the confirmed nonce of the sender.
+type Submitter struct {
+	cfg   Config
+	l1    L1Client
+	l2    L2Client
+	txmgr *txmgr.Manager
+	from  common.Address
+	log   log.Logger
+	now   func() time.Time
+
+	cursor   rollup.BlockID // last L2 block whose batch was confirmed
+	queued   rollup.BlockID // last L2 block added to the queue
+	pending  []*pendingBlock
+	inflight []*submission // oldest first
+	nonce    uint64        // nonce of the next batch transaction
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a batch submitter. The submission cursor is loaded from disk; if
+// there is none, submission starts after the L2 genesis block.
+func New(cfg Config, l1 L1Client, l2 L2Client, key *ecdsa.PrivateKey, logger log.Logger) (*Submitter, error) {
+	if cfg.MaxSubmitSize == 0 {
+		return nil, errors.New("max submit size must be positive")
+	}
+	if cfg.MaxInflight <= 0 {
+		return nil, errors.New("max inflight channels must be positive")
+	}
+	s := &Submitter{
+		cfg:   cfg,
+		l1:    l1,
+		l2:    l2,
+		txmgr: txmgr.New(txmgr.Config{ChainID: cfg.L1ChainID, MaxGasPrice: cfg.MaxGasPrice}, l1, key, logger),
+		from:  crypto.PubkeyToAddress(key.PublicKey),
+		log:   logger,
+		now:   time.Now,
+		quit:  make(chan struct{}),
+	}
+	cursor, err := loadCursor(cfg.CursorFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load submission cursor: %w", err)
+	}
+	if cursor == nil {
+		genesis, err := l2.HeaderByNumber(context.Background(), common.Big0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch L2 genesis: %w", err)
+		}
+		cursor = &rollup.BlockID{Hash: genesis.Hash(), Number: 0}
+	}
+	s.cursor, s.queued = *cursor, *cursor
+
+	// Transactions in the pool beyond the confirmed nonce were sent before a
+	// restart. Their batches are submitted again from the cursor, replacing
+	// them.
+	if s.nonce, err = l1.NonceAt(context.Background(), s.from, nil); err != nil {
+		return nil, fmt.Errorf("failed to fetch nonce: %w", err)
+	}
+	pending, err := l1.PendingNonceAt(context.Background(), s.from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pending nonce: %w", err)
+	}
+	if pending > s.nonce {
+		s.log.Warn("Replacing pending batch transactions of a previous run", "nonce", s.nonce, "count", pending-s.nonce)
+	}
+	return s, nil
+}
+
+// Start starts submitting batches in the background.
+func (s *Submitter) Start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop stops the submitter and waits for it to shut down.
+func (s *Submitter) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func (s *Submitter) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.quit
+		cancel()
+	}()
+	for {
+		if err := s.Step(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error("Batch submission failed", "err", err)
+			failedSubmitMeter.Mark(1)
+		}
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Step checks the pending submissions, queues new L2 blocks and submits new
+// channels as long as the submission policy allows it and fewer than
+// MaxInflight channels are pending.
+func (s *Submitter) Step(ctx context.Context) error {
+	defer func() { inflightChannelGauge.Update(int64(len(s.inflight))) }()
+
+	if len(s.inflight) > 0 {
+		if err := s.checkInflight(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.queueBlocks(ctx); err != nil {
+		return err
+	}
+	for len(s.inflight) < s.cfg.MaxInflight && s.shouldSubmit() {
+		n := len(s.inflight)
+		if err := s.submit(ctx); err != nil {
+			return err
+		}
+		if len(s.inflight) == n {
+			return nil // postponed
+		}
+	}
+	return nil
+}
+
+// Cursor returns the last L2 block whose batch was confirmed on L1.
+func (s *Submitter) Cursor() rollup.BlockID {
+	return s.cursor
+}
+
+// checkInflight checks the pending submissions in order, and advances the
+// cursor past the channels whose transactions were all confirmed. If any of
+// them failed, its blocks and those of all later channels are submitted again
+// in new channels. Transactions that take too long are replaced with higher
+// fees.
+//
+// The frames of a channel may be included in different L1 blocks. Until all of
+// them are, the receipts of the included frames are checked again on every
+// call, so that frames whose L1 block was reorged out are waited for again.
+func (s *Submitter) checkInflight(ctx context.Context) error {
+	// The confirmed nonce is fetched before the receipts, so a nonce below
+	// it without a receipt was used by another transaction.
+	confirmed, err := s.l1.NonceAt(ctx, s.from, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch nonce: %w", err)
+	}
+	canonical := make(map[uint64]common.Hash)
+	for _, sub := range s.inflight {
+		for _, btx := range sub.txs {
+			if err := s.checkBatchTx(ctx, btx, confirmed, canonical); err != nil {
+				return err
+			}
+		}
+	}
+	for len(s.inflight) > 0 {
+		sub := s.inflight[0]
+		var (
+			receipts []*types.Receipt
+			failed   bool
+		)
+		for _, btx := range sub.txs {
+			switch {
+			case btx.lost:
+				s.log.Warn("Batch transaction replaced by another transaction, resubmitting", "nonce", btx.nonce())
+				failed = true
+			case btx.receipt == nil:
+				return nil
+			case btx.receipt.Status != types.ReceiptStatusSuccessful:
+				s.log.Warn("Batch transaction failed, resubmitting", "hash", btx.receipt.TxHash, "l1block", btx.receipt.BlockNumber)
+				failed = true
+			}
+			receipts = append(receipts, btx.receipt)
+		}
+		if sub.incomplete && !failed {
+			s.log.Warn("Incomplete batch channel confirmed, resubmitting", "txs", len(receipts))
+			failed = true
+		}
+		if failed {
+			// Later channels are still included, but derivation needs the
+			// batches in order, so their blocks are submitted again too.
+			s.inflight = nil
+			return nil
+		}
+		if err := saveCursor(s.cfg.CursorFile, sub.last); err != nil {
+			return fmt.Errorf("failed to save submission cursor: %w", err)
+		}
+		s.pending = s.pending[sub.last.Number-s.cursor.Number:]
+		s.cursor = sub.last
+		s.inflight = s.inflight[1:]
+		confirmedBlockGauge.Update(int64(sub.last.Number))
+		pendingBlockGauge.Update(int64(len(s.pending)))
+		s.log.Info("Batch channel confirmed", "channel", sub.id, "txs", len(receipts), "l1block", receipts[len(receipts)-1].BlockNumber, "l2head", sub.last)
+	}
+	return nil
+}
+
+// checkBatchTx looks for the receipt of any transaction sent with the nonce of
+// the batch transaction. If there is none after ResubmitTimeout, the
+// transaction is replaced with higher fees. A receipt whose L1 block is no
+// longer canonical is dropped. The canonical L1 block hashes are cached in the
+// given map.
+func (s *Submitter) checkBatchTx(ctx context.Context, btx *batchTx, confirmed uint64, canonical map[uint64]common.Hash) error {
+	if btx.lost {
+		return nil
+	}
+	if r := btx.receipt; r != nil {
+		hash, ok := canonical[r.BlockNumber.Uint64()]
+		if !ok {
+			header, err := s.l1.HeaderByNumber(ctx, r.BlockNumber)
+			if err != nil && !errors.Is(err, ethereum.NotFound) {
+				return fmt.Errorf("failed to fetch L1 block %d: %w", r.BlockNumber, err)
+			}
+			if header != nil {
+				hash = header.Hash()
+			}
+			canonical[r.BlockNumber.Uint64()] = hash
+		}
+		if hash == r.BlockHash {
+			return nil
+		}
+		s.log.Warn("Batch transaction reorged out of L1", "nonce", btx.nonce(), "hash", r.TxHash, "l1block", r.BlockNumber)
+		reorgedTxMeter.Mark(1)
+		// The transaction is usually back in the pool, give it time to be
+		// included again before replacing it.
+		btx.receipt, btx.sent = nil, s.now()
+	}
+	receipt, err := s.txmgr.Receipt(ctx, btx.txs)
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		btx.receipt = receipt
+		return nil
+	}
+	if btx.nonce() < confirmed {
+		btx.lost = true
+		return nil
+	}
+	if s.now().Sub(btx.sent) < s.cfg.ResubmitTimeout {
+		return nil
+	}
+	last := btx.txs[len(btx.txs)-1]
+	tip, feeCap, err := s.txmgr.Fees(ctx, last)
+	if err != nil || tip == nil {
+		return err
+	}
+	tx, err := s.send(ctx, last.Nonce(), last.Data(), tip, feeCap)
+	if err != nil {
+		return fmt.Errorf("failed to replace batch transaction: %w", err)
+	}
+	btx.txs = append(btx.txs, tx)
+	btx.sent = s.now()
+	replacedTxMeter.Mark(1)
+	s.log.Warn("Batch transaction not included, resubmitted", "nonce", tx.Nonce(), "hash", tx.Hash(), "tip", tx.GasTipCap(), "feecap", tx.GasFeeCap())
+	return nil
+}
+
+// queueBlocks adds the L2 blocks that were sequenced since the last call to the
+// submission queue.
+func (s *Submitter) queueBlocks(ctx context.Context) error {
+	head, err := s.l2.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch L2 head: %w", err)
+	}
+	for n := s.queued.Number + 1; n <= head.Number.Uint64(); n++ {
+		block, err := s.l2.BlockByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			return fmt.Errorf("failed to fetch L2 block %d: %w", n, err)
+		}
+		if block.ParentHash() != s.queued.Hash {
+			if len(s.pending) == 0 {
+				return s.rewindCursor(ctx)
+			}
+			s.log.Warn("L2 chain reorged, dropping queued blocks", "number", n, "queued", len(s.pending))
+			s.pending, s.inflight, s.queued = nil, nil, s.cursor
+			return errL2Reorg
+		}
+		batch, err := derive.BlockToBatch(block)
+		if err != nil {
+			return err
+		}
+		enc, err := derive.EncodeBatch(batch)
+		if err != nil {
+			return err
+		}
+		id := rollup.BlockID{Hash: block.Hash(), Number: n}
+		s.pending = append(s.pending, &pendingBlock{id: id, batch: batch, size: len(enc), added: s.now()})
+		s.queued = id
+	}
+	pendingBlockGauge.Update(int64(len(s.pending)))
+	return nil
+}
+
+// rewindCursor moves the cursor back to the safe L2 head, after the L2 chain
+// reorged below it. The batches of the blocks after the cursor were confirmed,
+// but derivation will not produce them any more, while the safe blocks were
+// derived from L1 already. The blocks after the safe head are submitted again
+// on the next step.
+func (s *Submitter) rewindCursor(ctx context.Context) error {
+	safe, err := s.l2.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+	if err != nil {
+		return fmt.Errorf("failed to fetch safe L2 head: %w", err)
+	}
+	cursor := rollup.BlockID{Hash: safe.Hash(), Number: safe.Number.Uint64()}
+	if err := saveCursor(s.cfg.CursorFile, cursor); err != nil {
+		return fmt.Errorf("failed to save submission cursor: %w", err)
+	}
+	reorged := s.cursor
+	s.cursor, s.queued = cursor, cursor
+	confirmedBlockGauge.Update(int64(cursor.Number))
+	return fmt.Errorf("%w below the submission cursor %s, rewound to the safe head %s", errL2Reorg, reorged, cursor)
+}
+
+// unsubmitted returns the queued blocks that are not part of a pending
+// submission.
+func (s *Submitter) unsubmitted() []*pendingBlock {
+	if len(s.inflight) == 0 {
+		return s.pending
+	}
+	return s.pending[s.inflight[len(s.inflight)-1].last.Number-s.cursor.Number:]
+}
+
+// shouldSubmit reports whether enough batch data was collected, or whether the
+// oldest block waited long enough.
+func (s *Submitter) shouldSubmit() bool {
+	blocks := s.unsubmitted()
+	if len(blocks) == 0 {
+		return false
+	}
+	if s.now().Sub(blocks[0].added) >= s.cfg.MaxDelay {
+		return true
+	}
+	var size uint64
+	for _, b := range blocks {
+		size += uint64(b.size)
+	}
+	return size >= s.cfg.MinSubmitSize
+}
+
+// submit compresses as many queued blocks as fit into the maximum channel size
+// into a channel, and sends its frames in as many transactions as needed.
+func (s *Submitter) submit(ctx context.Context) error {
+	var (
+		batches []*derive.BatchData
+		last    rollup.BlockID
+		size    uint64
+	)
+	for _, b := range s.unsubmitted() {
+		if len(batches) > 0 && size+uint64(b.size) > s.cfg.MaxChannelSize {
+			break
+		}
+		batches = append(batches, b.batch)
+		last = b.id
+		size += uint64(b.size)
+	}
+	tip, feeCap, err := s.txmgr.Fees(ctx, nil)
+	if err != nil || tip == nil {
+		return err
+	}
+	// Every frame is sent in its own transaction, prefixed by the version byte.
+	frames, err := derive.EncodeChannel(derive.Zlib, batches, int(s.cfg.MaxSubmitSize)-1)
+	if err != nil {
+		return err
+	}
+	var (
+		nonce = s.nonce
+		sub   = &submission{id: frames[0].ID, last: last}
+	)
+	for _, f := range frames {
+		data := derive.EncodeFrames(f)
+		tx, err := s.send(ctx, s.nonce, data, tip, feeCap)
+		if err != nil {
+			if strings.Contains(err.Error(), core.ErrNonceTooLow.Error()) {
+				// Transactions of a previous run were included meanwhile.
+				if confirmed, nerr := s.l1.NonceAt(ctx, s.from, nil); nerr == nil {
+					s.nonce = confirmed
+				}
+			}
+			if len(sub.txs) > 0 {
+				// Wait for the frames that were sent. The channel will be
+				// incomplete, and is sent again in full afterwards.
+				sub.incomplete = true
+				s.inflight = append(s.inflight, sub)
+			}
+			return fmt.Errorf("failed to send batch transaction: %w", err)
+		}
+		s.nonce++
+		sub.txs = append(sub.txs, &batchTx{txs: []*types.Transaction{tx}, sent: s.now()})
+		submittedTxMeter.Mark(1)
+		submittedBytesMeter.Mark(int64(len(data)))
+	}
+	submittedBlockMeter.Mark(int64(len(batches)))
+	s.inflight = append(s.inflight, sub)
+	s.log.Info("Submitted batch channel", "channel", frames[0].ID, "txs", len(sub.txs), "nonce", nonce, "blocks", len(batches), "size", size)
+	return nil
+}
+
+// send signs and sends a batch transaction with the given nonce and data.
+func (s *Submitter) send(ctx context.Context, nonce uint64, data []byte, tip, feeCap *big.Int) (*types.Transaction, error) {
+	gas, err := core.IntrinsicGas(data, nil, false, true, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.txmgr.Send(ctx, nonce, s.cfg.BatchInboxAddress, gas, data, tip, feeCap)
+}
@@ -0,0 +1,721 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package batcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/txmgr"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+type testL1 struct {
+	baseFee  *big.Int
+	sent     []*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	reorgs   int // number of L1 reorgs, part of the block hashes
+}
+
+func (l *testL1) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = big.NewInt(101)
+	}
+	return &types.Header{Number: number, BaseFee: l.baseFee, Extra: []byte{byte(l.reorgs)}}, nil
+}
+
+func (l *testL1) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (l *testL1) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 21000, nil
+}
+
+// NonceAt returns the nonce after the included transactions.
+func (l *testL1) NonceAt(ctx context.Context, account common.Address, number *big.Int) (uint64, error) {
+	var nonce uint64
+	for _, tx := range l.sent {
+		if _, ok := l.receipts[tx.Hash()]; ok && tx.Nonce() >= nonce {
+			nonce = tx.Nonce() + 1
+		}
+	}
+	return nonce, nil
+}
+
+func (l *testL1) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	var nonce uint64
+	for _, tx := range l.sent {
+		if tx.Nonce() >= nonce {
+			nonce = tx.Nonce() + 1
+		}
+	}
+	return nonce, nil
+}
+
+// SendTransaction adds the transaction to the pool, which requires a 10% fee
+// bump to replace a pending transaction.
+func (l *testL1) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if confirmed, _ := l.NonceAt(ctx, common.Address{}, nil); tx.Nonce() < confirmed {
+		return core.ErrNonceTooLow
+	}
+	for _, old := range l.sent {
+		if old.Nonce() == tx.Nonce() && (tx.GasTipCap().Cmp(txmgr.BumpFee(old.GasTipCap())) < 0 || tx.GasFeeCap().Cmp(txmgr.BumpFee(old.GasFeeCap())) < 0) {
+			return core.ErrReplaceUnderpriced
+		}
+	}
+	l.sent = append(l.sent, tx)
+	return nil
+}
+
+func (l *testL1) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := l.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// include includes the given transaction with the given status.
+func (l *testL1) include(tx *types.Transaction, status uint64) {
+	head, _ := l.HeaderByNumber(context.Background(), nil)
+	l.receipts[tx.Hash()] = &types.Receipt{TxHash: tx.Hash(), Status: status, BlockHash: head.Hash(), BlockNumber: head.Number}
+}
+
+// reorg replaces the L1 head, which drops the receipts of the transactions
+// included in it.
+func (l *testL1) reorg() {
+	l.reorgs++
+	l.receipts = make(map[common.Hash]*types.Receipt)
+}
+
+// confirm includes the last sent transaction of every nonce without a receipt
+// with the given status.
+func (l *testL1) confirm(status uint64) {
+	included := make(map[uint64]bool)
+	for _, tx := range l.sent {
+		if _, ok := l.receipts[tx.Hash()]; ok {
+			included[tx.Nonce()] = true
+		}
+	}
+	for i := len(l.sent) - 1; i >= 0; i-- {
+		if tx := l.sent[i]; !included[tx.Nonce()] {
+			l.include(tx, status)
+			included[tx.Nonce()] = true
+		}
+	}
+}
+
+type testL2 struct {
+	blocks []*types.Block
+	safe   uint64 // number of the safe block
+}
+
+func newTestL2() *testL2 {
+	genesis := types.NewBlockWithHeader(&types.Header{Number: new(big.Int), Time: 1000})
+	return &testL2{blocks: []*types.Block{genesis}}
+}
+
+// addBlock adds an L2 block with an L1 info deposit and the given number of
+// (fake) user transactions on top of the given parent.
+func (l *testL2) addBlock(t *testing.T, parent *types.Block, ntxs int) *types.Block {
+	t.Helper()
+	l1 := &types.Header{Number: big.NewInt(5), Time: 990, BaseFee: big.NewInt(7)}
+	attrs, err := derive.PayloadAttributes(&rollup.Config{FeeRecipientAddress: derive.SequencerFeeVaultAddr}, rollup.SystemConfig{}, l1, parent.NumberU64(), nil, &derive.BatchData{Timestamp: parent.Time() + 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info types.Transaction
+	if err := info.UnmarshalBinary(attrs.Transactions[0]); err != nil {
+		t.Fatal(err)
+	}
+	txs := []*types.Transaction{&info}
+	for i := 0; i < ntxs; i++ {
+		txs = append(txs, types.NewTx(&types.LegacyTx{Nonce: uint64(i), Data: make([]byte, 100), Gas: 50000, GasPrice: big.NewInt(1), V: big.NewInt(27), R: big.NewInt(1), S: big.NewInt(1)}))
+	}
+	header := &types.Header{ParentHash: parent.Hash(), Number: new(big.Int).Add(parent.Number(), common.Big1), Time: parent.Time() + 2}
+	block := types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+	l.blocks = append(l.blocks[:block.NumberU64()], block)
+	return block
+}
+
+func (l *testL2) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return l.blocks[len(l.blocks)-1].Header(), nil
+	}
+	b, err := l.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return b.Header(), nil
+}
+
+func (l *testL2) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	if number.Int64() == int64(rpc.SafeBlockNumber) {
+		return l.blocks[l.safe], nil
+	}
+	if number.Uint64() >= uint64(len(l.blocks)) {
+		return nil, ethereum.NotFound
+	}
+	return l.blocks[number.Uint64()], nil
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestSubmitter(t *testing.T, cfg Config, l1 *testL1, l2 *testL2, clock *testClock) *Submitter {
+	t.Helper()
+	s, err := New(cfg, l1, l2, testKey, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = clock.Now
+	return s
+}
+
+func testConfig(t *testing.T) Config {
+	cfg := DefaultConfig
+	cfg.L1ChainID = big.NewInt(900)
+	cfg.BatchInboxAddress = common.HexToAddress("0xff00000000000000000000000000000000000901")
+	cfg.MinSubmitSize = 1000
+	cfg.MaxSubmitSize = 800
+	cfg.MaxChannelSize = 800
+	cfg.MaxDelay = time.Minute
+	cfg.CursorFile = filepath.Join(t.TempDir(), "cursor.json")
+	return cfg
+}
+
+// submittedBatches decodes the batches of the channel that is posted by the
+// given batch transactions.
+func submittedBatches(t *testing.T, txs ...*types.Transaction) []*derive.BatchData {
+	t.Helper()
+	var (
+		bank    = derive.NewChannelBank(derive.DefaultChannelTimeout, log.New())
+		batches []*derive.BatchData
+	)
+	for _, tx := range txs {
+		frames, err := derive.DecodeFrames(tx.Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range frames {
+			batches = append(batches, bank.AddFrame(f, 1)...)
+		}
+	}
+	return batches
+}
+
+func TestSubmitterSizeAndDelay(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+		s     = newTestSubmitter(t, cfg, l1, l2, clock)
+	)
+	// A single small block is below the size threshold.
+	b1 := l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 0 {
+		t.Fatal("submitted below the size threshold")
+	}
+	// After the maximum delay it is submitted anyway.
+	clock.now = clock.now.Add(cfg.MaxDelay)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 1 {
+		t.Fatalf("sent %d transactions, want 1", len(l1.sent))
+	}
+	if batches := submittedBatches(t, l1.sent[0]); len(batches) != 1 || batches[0].ParentHash != l2.blocks[0].Hash() {
+		t.Fatalf("unexpected batches %+v", batches)
+	}
+	// Nothing new is sent while the transaction is pending.
+	l2.addBlock(t, b1, 4)
+	l2.addBlock(t, l2.blocks[2], 4)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 1 || s.Cursor().Number != 0 {
+		t.Fatal("submitted while a transaction is pending")
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+
+	// Once confirmed, the two new blocks exceed the size threshold, but only
+	// one fits into a channel.
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("cursor not advanced: %v", s.Cursor())
+	}
+	if len(l1.sent) != 2 {
+		t.Fatalf("sent %d transactions, want 2", len(l1.sent))
+	}
+	if batches := submittedBatches(t, l1.sent[1]); len(batches) != 1 || batches[0].ParentHash != b1.Hash() {
+		t.Fatalf("unexpected batches %+v", batches)
+	}
+	if size := len(l1.sent[1].Data()); uint64(size) > cfg.MaxSubmitSize {
+		t.Fatalf("transaction data of %d bytes exceeds the maximum", size)
+	}
+}
+
+func TestSubmitterGasPriceCeiling(t *testing.T) {
+	var (
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(100), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxGasPrice = big.NewInt(150)
+	cfg.MaxDelay = 0
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 0 {
+		t.Fatal("submitted above the gas price ceiling")
+	}
+	l1.baseFee = big.NewInt(50)
+	if err := s.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 1 {
+		t.Fatal("not submitted below the gas price ceiling")
+	}
+	if feeCap := l1.sent[0].GasFeeCap(); feeCap.Cmp(cfg.MaxGasPrice) > 0 {
+		t.Fatalf("fee cap %v above ceiling", feeCap)
+	}
+}
+
+func TestSubmitterRestart(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0 // submit every block right away
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	b1 := l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("cursor not advanced: %v", s.Cursor())
+	}
+
+	// A new submitter continues after the confirmed block.
+	b2 := l2.addBlock(t, b1, 1)
+	s = newTestSubmitter(t, cfg, l1, l2, clock)
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("cursor not restored: %v", s.Cursor())
+	}
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 2 {
+		t.Fatalf("sent %d transactions, want 2", len(l1.sent))
+	}
+	batches := submittedBatches(t, l1.sent[1])
+	if len(batches) != 1 || batches[0].ParentHash != b1.Hash() || batches[0].Timestamp != b2.Time() {
+		t.Fatalf("restarted submitter sent unexpected batches %+v", batches)
+	}
+}
+
+func TestSubmitterReorgBelowCursor(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0 // submit every block right away
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	b1 := l2.addBlock(t, l2.blocks[0], 1)
+	l2.addBlock(t, b1, 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor().Number != 2 {
+		t.Fatalf("cursor not advanced: %v", s.Cursor())
+	}
+
+	// The L2 chain reorgs below the cursor, with nothing pending. The cursor
+	// is rewound to the safe head, and persisted.
+	b2 := l2.addBlock(t, b1, 2)
+	b3 := l2.addBlock(t, b2, 1)
+	l2.safe = 1
+	if err := s.Step(ctx); !errors.Is(err, errL2Reorg) {
+		t.Fatalf("reorg below the cursor not reported: %v", err)
+	}
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("cursor %v not rewound to the safe head", s.Cursor())
+	}
+	if cursor, err := loadCursor(cfg.CursorFile); err != nil || *cursor != rollupID(b1) {
+		t.Fatalf("persisted cursor %v, %v", cursor, err)
+	}
+
+	// The blocks of the new chain are submitted from there.
+	sent := len(l1.sent)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	batches := submittedBatches(t, l1.sent[sent:]...)
+	if len(batches) != 2 || batches[0].ParentHash != b1.Hash() || batches[1].ParentHash != b2.Hash() || batches[1].Timestamp != b3.Time() {
+		t.Fatalf("unexpected batches after rewinding %+v", batches)
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b3) {
+		t.Fatalf("cursor %v, want %v", s.Cursor(), rollupID(b3))
+	}
+}
+
+func TestSubmitterFailedTransaction(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0 // submit every block right away
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	l1.confirm(types.ReceiptStatusFailed)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor().Number != 0 {
+		t.Fatal("cursor advanced after failed submission")
+	}
+	if len(l1.sent) != 2 || l1.sent[1].Nonce() != 1 {
+		t.Fatal("failed submission not retried")
+	}
+}
+
+func TestSubmitterMultipleFrames(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0 // submit every block right away
+	cfg.MaxSubmitSize = 64
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	b1 := l2.addBlock(t, l2.blocks[0], 4)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) < 2 {
+		t.Fatalf("channel sent in %d transactions, want more", len(l1.sent))
+	}
+	for i, tx := range l1.sent {
+		if tx.Nonce() != uint64(i) {
+			t.Fatalf("transaction %d has nonce %d", i, tx.Nonce())
+		}
+		if size := len(tx.Data()); uint64(size) > cfg.MaxSubmitSize {
+			t.Fatalf("transaction data of %d bytes exceeds the maximum", size)
+		}
+	}
+	if batches := submittedBatches(t, l1.sent...); len(batches) != 1 || batches[0].Timestamp != b1.Time() {
+		t.Fatalf("unexpected batches %+v", batches)
+	}
+	// The cursor only advances once all frames are confirmed.
+	first := l1.sent[0]
+	l1.receipts[first.Hash()] = &types.Receipt{TxHash: first.Hash(), Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(101)}
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor().Number != 0 {
+		t.Fatal("cursor advanced before all frames were confirmed")
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("cursor not advanced: %v", s.Cursor())
+	}
+}
+
+func TestSubmitterResubmit(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0                      // submit every block right away
+	cfg.MaxGasPrice = big.NewInt(1 << 62) // lowered below
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	b1 := l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The transaction is replaced after the timeout, with the same nonce and
+	// enough of a fee bump to replace it in the pool.
+	clock.now = clock.now.Add(cfg.ResubmitTimeout - time.Second)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(time.Second)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 2 {
+		t.Fatalf("sent %d transactions, want 2", len(l1.sent))
+	}
+	old, replacement := l1.sent[0], l1.sent[1]
+	if replacement.Nonce() != old.Nonce() || !bytes.Equal(replacement.Data(), old.Data()) {
+		t.Fatal("replacement differs from the replaced transaction")
+	}
+	// The replacement is capped by the gas price limit.
+	cfg.MaxGasPrice.Set(replacement.GasFeeCap())
+	clock.now = clock.now.Add(cfg.ResubmitTimeout)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 2 {
+		t.Fatal("replaced above the gas price limit")
+	}
+	// The original transaction may still be the one that is included.
+	l1.include(old, types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("inclusion of the replaced transaction not detected: %v", s.Cursor())
+	}
+}
+
+func TestSubmitterReplacesPreviousRun(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0 // submit every block right away
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	b1 := l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// After a restart, the pending transaction of the previous run is
+	// replaced, as its fees are unknown they are bumped until the pool
+	// accepts the replacement.
+	s = newTestSubmitter(t, cfg, l1, l2, clock)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 2 {
+		t.Fatalf("sent %d transactions, want 2", len(l1.sent))
+	}
+	if old, replacement := l1.sent[0], l1.sent[1]; replacement.Nonce() != old.Nonce() || replacement.GasFeeCap().Cmp(old.GasFeeCap()) <= 0 {
+		t.Fatalf("pending transaction not replaced: nonce %d, fee cap %v", replacement.Nonce(), replacement.GasFeeCap())
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b1) {
+		t.Fatalf("cursor not advanced: %v", s.Cursor())
+	}
+}
+
+func TestSubmitterMultipleInflight(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MaxDelay = 0 // submit every block right away
+	cfg.MaxInflight = 2
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	// Two channels are submitted without waiting for confirmations.
+	b1 := l2.addBlock(t, l2.blocks[0], 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b2 := l2.addBlock(t, b1, 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	b3 := l2.addBlock(t, b2, 1)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 2 || l1.sent[1].Nonce() != 1 {
+		t.Fatalf("sent %d transactions, want 2", len(l1.sent))
+	}
+	if batches := submittedBatches(t, l1.sent[1]); len(batches) != 1 || batches[0].ParentHash != b1.Hash() {
+		t.Fatalf("unexpected batches %+v", batches)
+	}
+	// If the first channel fails, both are submitted again in order.
+	l1.include(l1.sent[0], types.ReceiptStatusFailed)
+	l1.include(l1.sent[1], types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor().Number != 0 {
+		t.Fatal("cursor advanced after failed submission")
+	}
+	if len(l1.sent) != 3 || l1.sent[2].Nonce() != 2 {
+		t.Fatalf("sent %d transactions, want 3", len(l1.sent))
+	}
+	if batches := submittedBatches(t, l1.sent[2]); len(batches) != 3 || batches[0].ParentHash != l2.blocks[0].Hash() {
+		t.Fatalf("failed blocks not resubmitted: %+v", batches)
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(b3) {
+		t.Fatalf("cursor not advanced: %v", s.Cursor())
+	}
+}
+
+func rollupID(b *types.Block) rollup.BlockID {
+	return rollup.BlockID{Hash: b.Hash(), Number: b.NumberU64()}
+}
+
+func TestSubmitterPipelining(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		cfg   = testConfig(t)
+		l1    = &testL1{baseFee: big.NewInt(10), receipts: make(map[common.Hash]*types.Receipt)}
+		l2    = newTestL2()
+		clock = &testClock{now: time.Unix(10000, 0)}
+	)
+	cfg.MinSubmitSize = 500
+	cfg.MaxSubmitSize = 80
+	cfg.MaxChannelSize = 600
+	cfg.MaxInflight = 2
+	s := newTestSubmitter(t, cfg, l1, l2, clock)
+
+	// Enough blocks for three channels are queued at once, two of them are
+	// submitted right away.
+	parent := l2.blocks[0]
+	for i := 0; i < 9; i++ {
+		parent = l2.addBlock(t, parent, 1)
+	}
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.inflight) != 2 {
+		t.Fatalf("%d channels in flight, want 2", len(s.inflight))
+	}
+	first, second := s.inflight[0], s.inflight[1]
+	if len(first.txs) < 2 {
+		t.Fatalf("first channel sent in %d transactions, want several", len(first.txs))
+	}
+	if second.txs[0].nonce() != first.txs[len(first.txs)-1].nonce()+1 {
+		t.Fatal("channels not sent with consecutive nonces")
+	}
+	sent := len(l1.sent)
+
+	// Only the first frame is included, then reorged out of L1. The channel
+	// waits for it again instead of being resubmitted.
+	l1.include(first.txs[0].txs[0], types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if first.txs[0].receipt == nil {
+		t.Fatal("receipt of the included frame not tracked")
+	}
+	l1.reorg()
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if first.txs[0].receipt != nil {
+		t.Fatal("receipt of the reorged frame still tracked")
+	}
+	if len(l1.sent) != sent || len(s.inflight) != 2 || s.Cursor().Number != 0 {
+		t.Fatalf("unexpected submission after reorg: sent %d, inflight %d, cursor %v", len(l1.sent), len(s.inflight), s.Cursor())
+	}
+
+	// Once all frames are included, both channels are confirmed and the rest
+	// of the blocks is submitted.
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != second.last {
+		t.Fatalf("cursor %v, want %v", s.Cursor(), second.last)
+	}
+	if len(s.inflight) != 1 || len(l1.sent) == sent {
+		t.Fatal("remaining blocks not submitted")
+	}
+	l1.confirm(types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor() != rollupID(parent) {
+		t.Fatalf("cursor %v, want %v", s.Cursor(), rollupID(parent))
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the batch submitter.
+
+package batcher
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	submittedBytesMeter  = metrics.NewRegisteredMeter("rollup/batcher/submitted/bytes", nil)
+	submittedTxMeter     = metrics.NewRegisteredMeter("rollup/batcher/submitted/txs", nil)
+	submittedBlockMeter  = metrics.NewRegisteredMeter("rollup/batcher/submitted/blocks", nil)
+	replacedTxMeter      = metrics.NewRegisteredMeter("rollup/batcher/replaced/txs", nil)
+	reorgedTxMeter       = metrics.NewRegisteredMeter("rollup/batcher/reorged/txs", nil)
+	confirmedBlockGauge  = metrics.NewRegisteredGauge("rollup/batcher/confirmed", nil)
+	pendingBlockGauge    = metrics.NewRegisteredGauge("rollup/batcher/pending", nil)
+	inflightChannelGauge = metrics.NewRegisteredGauge("rollup/batcher/inflight", nil)
+	failedSubmitMeter    = metrics.NewRegisteredMeter("rollup/batcher/failures", nil)
+)
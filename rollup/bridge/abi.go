@@ -0,0 +1,171 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// L2CrossDomainMessengerAddr is the predeploy that relays the messages of
+	// the L1 messenger and sends messages to L1 through the message passer.
+	L2CrossDomainMessengerAddr = common.HexToAddress("0x4200000000000000000000000000000000000007")
+	// L2StandardBridgeAddr is the predeploy of the L2 side of the standard
+	// bridge.
+	L2StandardBridgeAddr = common.HexToAddress("0x4200000000000000000000000000000000000010")
+)
+
+// portalABI is the part of the portal that deposits and withdrawals use.
+const portalABI = `[
+	{"type":"function","name":"depositTransaction","stateMutability":"payable","inputs":[
+		{"name":"_to","type":"address"},
+		{"name":"_value","type":"uint256"},
+		{"name":"_gasLimit","type":"uint64"},
+		{"name":"_isCreation","type":"bool"},
+		{"name":"_data","type":"bytes"}
+	],"outputs":[]},
+	{"type":"function","name":"finalizedWithdrawals","stateMutability":"view","inputs":[{"name":"","type":"bytes32"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"finalizeWithdrawalTransaction","stateMutability":"nonpayable","inputs":[
+		{"name":"_tx","type":"tuple","components":[
+			{"name":"nonce","type":"uint256"},
+			{"name":"sender","type":"address"},
+			{"name":"target","type":"address"},
+			{"name":"value","type":"uint256"},
+			{"name":"gasLimit","type":"uint256"},
+			{"name":"data","type":"bytes"}
+		]},
+		{"name":"_l2BlockNumber","type":"uint256"},
+		{"name":"_outputRootProof","type":"tuple","components":[
+			{"name":"version","type":"bytes32"},
+			{"name":"stateRoot","type":"bytes32"},
+			{"name":"withdrawerStorageRoot","type":"bytes32"},
+			{"name":"latestBlockhash","type":"bytes32"}
+		]},
+		{"name":"_withdrawalProof","type":"bytes[]"}
+	],"outputs":[]},
+	{"type":"event","name":"WithdrawalFinalized","anonymous":false,"inputs":[
+		{"name":"withdrawalHash","type":"bytes32","indexed":true},
+		{"name":"success","type":"bool","indexed":false}
+	]}
+]`
+
+// messagePasserABI is the part of the L2 message passer that withdrawals use.
+const messagePasserABI = `[
+	{"type":"function","name":"initiateWithdrawal","stateMutability":"payable","inputs":[
+		{"name":"_target","type":"address"},
+		{"name":"_gasLimit","type":"uint256"},
+		{"name":"_data","type":"bytes"}
+	],"outputs":[]},
+	{"type":"event","name":"MessagePassed","anonymous":false,"inputs":[
+		{"name":"nonce","type":"uint256","indexed":true},
+		{"name":"sender","type":"address","indexed":true},
+		{"name":"target","type":"address","indexed":true},
+		{"name":"value","type":"uint256","indexed":false},
+		{"name":"gasLimit","type":"uint256","indexed":false},
+		{"name":"data","type":"bytes","indexed":false},
+		{"name":"withdrawalHash","type":"bytes32","indexed":false}
+	]}
+]`
+
+// transferEventInputs are the inputs of the ERC-20 events of the standard
+// bridge, which all describe a transfer the same way.
+const transferEventInputs = `[
+	{"name":"l1Token","type":"address","indexed":true},
+	{"name":"l2Token","type":"address","indexed":true},
+	{"name":"from","type":"address","indexed":true},
+	{"name":"to","type":"address","indexed":false},
+	{"name":"amount","type":"uint256","indexed":false},
+	{"name":"extraData","type":"bytes","indexed":false}
+]`
+
+// l1StandardBridgeABI is the part of the L1 standard bridge that ERC-20
+// deposits and withdrawals use.
+const l1StandardBridgeABI = `[
+	{"type":"function","name":"depositERC20To","stateMutability":"nonpayable","inputs":[
+		{"name":"_l1Token","type":"address"},
+		{"name":"_l2Token","type":"address"},
+		{"name":"_to","type":"address"},
+		{"name":"_amount","type":"uint256"},
+		{"name":"_minGasLimit","type":"uint32"},
+		{"name":"_extraData","type":"bytes"}
+	],"outputs":[]},
+	{"type":"event","name":"ERC20DepositInitiated","anonymous":false,"inputs":` + transferEventInputs + `},
+	{"type":"event","name":"ERC20WithdrawalFinalized","anonymous":false,"inputs":` + transferEventInputs + `}
+]`
+
+// l2StandardBridgeABI is the part of the L2 standard bridge that ERC-20
+// deposits and withdrawals use.
+const l2StandardBridgeABI = `[
+	{"type":"function","name":"withdrawTo","stateMutability":"payable","inputs":[
+		{"name":"_l2Token","type":"address"},
+		{"name":"_to","type":"address"},
+		{"name":"_amount","type":"uint256"},
+		{"name":"_minGasLimit","type":"uint32"},
+		{"name":"_extraData","type":"bytes"}
+	],"outputs":[]},
+	{"type":"event","name":"DepositFinalized","anonymous":false,"inputs":` + transferEventInputs + `},
+	{"type":"event","name":"WithdrawalInitiated","anonymous":false,"inputs":` + transferEventInputs + `}
+]`
+
+// erc20ABI is the part of an ERC-20 token that the bridge flows use.
+const erc20ABI = `[
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[
+		{"name":"spender","type":"address"},
+		{"name":"amount","type":"uint256"}
+	],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// l2OutputOracleABI is the part of the L2 output oracle that outputs are
+// proposed and read with.
+const l2OutputOracleABI = `[
+	{"type":"function","name":"latestBlockNumber","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"proposeL2Output","stateMutability":"payable","inputs":[
+		{"name":"_l2Output","type":"bytes32"},
+		{"name":"_l2BlockNumber","type":"uint256"},
+		{"name":"_l1BlockHash","type":"bytes32"},
+		{"name":"_l1BlockNumber","type":"uint256"}
+	],"outputs":[]},
+	{"type":"event","name":"OutputProposed","anonymous":false,"inputs":[
+		{"name":"outputRoot","type":"bytes32","indexed":true},
+		{"name":"l2OutputIndex","type":"uint256","indexed":true},
+		{"name":"l2BlockNumber","type":"uint256","indexed":true},
+		{"name":"l1Timestamp","type":"uint256","indexed":false}
+	]}
+]`
+
+// The parsed ABIs of the contracts, for callers that need more than the helpers
+// of this package.
+var (
+	PortalABI           = mustParse(portalABI)
+	MessagePasserABI    = mustParse(messagePasserABI)
+	L1StandardBridgeABI = mustParse(l1StandardBridgeABI)
+	L2StandardBridgeABI = mustParse(l2StandardBridgeABI)
+	ERC20ABI            = mustParse(erc20ABI)
+	L2OutputOracleABI   = mustParse(l2OutputOracleABI)
+)
+
+func mustParse(def string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bridge contains the bindings of the contracts that move assets
+// between L1 and L2, and helpers for the deposit and withdrawal flows.
+//
+// An ERC-20 deposit is made by approving the L1 standard bridge to spend the
+// tokens, and calling it with PackDepositERC20. The rollup node derives the
+// resulting deposit into L2, where the L2 standard bridge mints the tokens.
+//
+// An ERC-20 withdrawal is initiated by calling the L2 standard bridge with
+// PackWithdrawERC20, which sends a message to L1 through the message passer.
+// Once an output at or after the L2 block of the message is final on L1, the
+// message is proven and finalized on the portal in one call, as assembled by
+// ProveWithdrawal and PackFinalizeWithdrawal.
+package bridge
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ERC20Transfer is a transfer of ERC-20 tokens through the standard bridge, as
+// described by the events of both sides of the bridge.
+type ERC20Transfer struct {
+	L1Token   common.Address
+	L2Token   common.Address
+	From      common.Address
+	To        common.Address
+	Amount    *big.Int
+	ExtraData []byte
+}
+
+// PackApprove encodes the ERC-20 call that allows the spender, like the L1
+// standard bridge, to transfer the given amount of tokens of the caller.
+func PackApprove(spender common.Address, amount *big.Int) ([]byte, error) {
+	return ERC20ABI.Pack("approve", spender, amount)
+}
+
+// PackDepositERC20 encodes the call of the L1 standard bridge that deposits the
+// given amount of L1 tokens to the recipient on L2, where they are minted as L2
+// tokens. The deposit is executed on L2 with at least minGasLimit gas.
+func PackDepositERC20(l1Token, l2Token, to common.Address, amount *big.Int, minGasLimit uint32, extraData []byte) ([]byte, error) {
+	return L1StandardBridgeABI.Pack("depositERC20To", l1Token, l2Token, to, amount, minGasLimit, extraData)
+}
+
+// PackWithdrawERC20 encodes the call of the L2 standard bridge that burns the
+// given amount of L2 tokens and withdraws the matching L1 tokens to the
+// recipient on L1.
+func PackWithdrawERC20(l2Token, to common.Address, amount *big.Int, minGasLimit uint32, extraData []byte) ([]byte, error) {
+	return L2StandardBridgeABI.Pack("withdrawTo", l2Token, to, amount, minGasLimit, extraData)
+}
+
+// UnmarshalERC20DepositInitiated decodes an ERC20DepositInitiated event of the L1
+// standard bridge.
+func UnmarshalERC20DepositInitiated(l *types.Log) (*ERC20Transfer, error) {
+	return unmarshalTransfer(L1StandardBridgeABI.Events["ERC20DepositInitiated"], l)
+}
+
+// UnmarshalERC20DepositFinalized decodes a DepositFinalized event of the L2 standard
+// bridge.
+func UnmarshalERC20DepositFinalized(l *types.Log) (*ERC20Transfer, error) {
+	return unmarshalTransfer(L2StandardBridgeABI.Events["DepositFinalized"], l)
+}
+
+// UnmarshalERC20WithdrawalInitiated decodes a WithdrawalInitiated event of the L2
+// standard bridge.
+func UnmarshalERC20WithdrawalInitiated(l *types.Log) (*ERC20Transfer, error) {
+	return unmarshalTransfer(L2StandardBridgeABI.Events["WithdrawalInitiated"], l)
+}
+
+// UnmarshalERC20WithdrawalFinalized decodes an ERC20WithdrawalFinalized event of the
+// L1 standard bridge.
+func UnmarshalERC20WithdrawalFinalized(l *types.Log) (*ERC20Transfer, error) {
+	return unmarshalTransfer(L1StandardBridgeABI.Events["ERC20WithdrawalFinalized"], l)
+}
+
+func unmarshalTransfer(event abi.Event, l *types.Log) (*ERC20Transfer, error) {
+	if len(l.Topics) != 4 || l.Topics[0] != event.ID {
+		return nil, fmt.Errorf("not an %s event", event.Name)
+	}
+	args, err := event.Inputs.NonIndexed().Unpack(l.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &ERC20Transfer{
+		L1Token:   common.BytesToAddress(l.Topics[1][:]),
+		L2Token:   common.BytesToAddress(l.Topics[2][:]),
+		From:      common.BytesToAddress(l.Topics[3][:]),
+		To:        args[0].(common.Address),
+		Amount:    args[1].(*big.Int),
+		ExtraData: args[2].([]byte),
+	}, nil
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+func testWithdrawal() *rollup.Withdrawal {
+	return &rollup.Withdrawal{
+		Nonce:    big.NewInt(7),
+		Sender:   L2CrossDomainMessengerAddr,
+		Target:   common.HexToAddress("0x2222"),
+		Value:    big.NewInt(1e18),
+		GasLimit: big.NewInt(100_000),
+		Data:     []byte{0xca, 0xfe},
+	}
+}
+
+// marshalTransfer encodes an ERC-20 event of the standard bridge.
+func marshalTransfer(t *testing.T, event abi.Event, tr *ERC20Transfer) *types.Log {
+	t.Helper()
+	data, err := event.Inputs.NonIndexed().Pack(tr.To, tr.Amount, tr.ExtraData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &types.Log{
+		Topics: []common.Hash{event.ID, common.BytesToHash(tr.L1Token[:]), common.BytesToHash(tr.L2Token[:]), common.BytesToHash(tr.From[:])},
+		Data:   data,
+	}
+}
+
+func TestERC20Events(t *testing.T) {
+	tr := &ERC20Transfer{
+		L1Token:   common.HexToAddress("0x1111"),
+		L2Token:   common.HexToAddress("0x2222"),
+		From:      common.HexToAddress("0x3333"),
+		To:        common.HexToAddress("0x4444"),
+		Amount:    big.NewInt(1000),
+		ExtraData: []byte{0x01, 0x02},
+	}
+	tests := []struct {
+		event     abi.Event
+		unmarshal func(*types.Log) (*ERC20Transfer, error)
+	}{
+		{L1StandardBridgeABI.Events["ERC20DepositInitiated"], UnmarshalERC20DepositInitiated},
+		{L2StandardBridgeABI.Events["DepositFinalized"], UnmarshalERC20DepositFinalized},
+		{L2StandardBridgeABI.Events["WithdrawalInitiated"], UnmarshalERC20WithdrawalInitiated},
+		{L1StandardBridgeABI.Events["ERC20WithdrawalFinalized"], UnmarshalERC20WithdrawalFinalized},
+	}
+	for i, test := range tests {
+		l := marshalTransfer(t, test.event, tr)
+		dec, err := test.unmarshal(l)
+		if err != nil {
+			t.Fatalf("%s: %v", test.event.Name, err)
+		}
+		if !reflect.DeepEqual(dec, tr) {
+			t.Fatalf("%s: decoded %+v, want %+v", test.event.Name, dec, tr)
+		}
+		// Every event is only decoded as itself.
+		other := tests[(i+1)%len(tests)]
+		if _, err := other.unmarshal(l); err == nil {
+			t.Fatalf("%s decoded as %s", test.event.Name, other.event.Name)
+		}
+	}
+}
+
+func TestPackDepositERC20(t *testing.T) {
+	l1Token, l2Token, to := common.HexToAddress("0x1111"), common.HexToAddress("0x2222"), common.HexToAddress("0x3333")
+	data, err := PackDepositERC20(l1Token, l2Token, to, big.NewInt(1000), 200_000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, err := L1StandardBridgeABI.MethodById(data[:4])
+	if err != nil || method.Name != "depositERC20To" {
+		t.Fatalf("unexpected method %v, %v", method, err)
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args[0] != l1Token || args[1] != l2Token || args[2] != to || args[3].(*big.Int).Int64() != 1000 || args[4] != uint32(200_000) {
+		t.Fatalf("unexpected arguments %v", args)
+	}
+}
+
+func TestWithdrawals(t *testing.T) {
+	w := testWithdrawal()
+	hash, _ := w.Hash()
+	other := &types.Log{Address: L2StandardBridgeAddr, Topics: []common.Hash{L2StandardBridgeABI.Events["WithdrawalInitiated"].ID}}
+	receipt := &types.Receipt{Logs: []*types.Log{other, MarshalMessagePassed(w, hash)}}
+	withdrawals, err := Withdrawals(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withdrawals) != 1 || !reflect.DeepEqual(withdrawals[0], w) {
+		t.Fatalf("unexpected withdrawals %+v", withdrawals)
+	}
+	// Events whose hash does not match the withdrawal are rejected.
+	receipt.Logs[1] = MarshalMessagePassed(w, common.HexToHash("0x01"))
+	if _, err := Withdrawals(receipt); err == nil {
+		t.Fatal("message with wrong hash accepted")
+	}
+}
+
+type testNode struct {
+	value *big.Int // value of the withdrawal slot
+}
+
+func (n *testNode) OutputProofAtBlock(ctx context.Context, number uint64, keys []common.Hash) (*rollup.OutputProof, error) {
+	proof := &rollup.OutputProof{Output: *rollup.NewOutputV0(common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03"))}
+	for _, key := range keys {
+		proof.StorageProof = append(proof.StorageProof, rollup.StorageProof{
+			Key:   key,
+			Value: (*hexutil.Big)(n.value),
+			Proof: []hexutil.Bytes{key[:], {0x01}},
+		})
+	}
+	return proof, nil
+}
+
+func TestProveAndFinalizeWithdrawal(t *testing.T) {
+	ctx, w := context.Background(), testWithdrawal()
+	if _, err := ProveWithdrawal(ctx, &testNode{value: new(big.Int)}, w, 8); err == nil {
+		t.Fatal("withdrawal proven without being sent")
+	}
+	proof, err := ProveWithdrawal(ctx, &testNode{value: common.Big1}, w, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := PackFinalizeWithdrawal(w, 8, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := PortalABI.Methods["finalizeWithdrawalTransaction"].Inputs.Unpack(data[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	slot, _ := w.StorageSlot()
+	if args[1].(*big.Int).Uint64() != 8 {
+		t.Fatalf("L2 block %v, want 8", args[1])
+	}
+	if nodes := args[3].([][]byte); len(nodes) != 2 || common.BytesToHash(nodes[0]) != slot {
+		t.Fatalf("unexpected storage proof %x", nodes)
+	}
+	root := reflect.ValueOf(args[2]).FieldByName("StateRoot").Interface().([32]byte)
+	if common.Hash(root) != proof.StateRoot {
+		t.Fatalf("state root %x, want %s", root, proof.StateRoot)
+	}
+}
+
+func TestFinalizedWithdrawals(t *testing.T) {
+	hash := common.HexToHash("0x77")
+	data, err := PackFinalizedWithdrawals(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := PortalABI.Methods["finalizedWithdrawals"].Inputs.Unpack(data[4:])
+	if err != nil || common.Hash(args[0].([32]byte)) != hash {
+		t.Fatalf("unexpected arguments %v, %v", args, err)
+	}
+	res, _ := PortalABI.Methods["finalizedWithdrawals"].Outputs.Pack(true)
+	if finalized, err := UnpackFinalizedWithdrawals(res); err != nil || !finalized {
+		t.Fatalf("finalized %v, %v", finalized, err)
+	}
+
+	event := PortalABI.Events["WithdrawalFinalized"]
+	eventData, _ := event.Inputs.NonIndexed().Pack(true)
+	got, success, err := UnmarshalWithdrawalFinalized(&types.Log{Topics: []common.Hash{event.ID, hash}, Data: eventData})
+	if err != nil || got != hash || !success {
+		t.Fatalf("decoded %s %v, %v", got, success, err)
+	}
+}
+
+func TestOutputProposed(t *testing.T) {
+	oracle := common.HexToAddress("0xabc")
+	p := &OutputProposal{OutputRoot: common.HexToHash("0x01"), OutputIndex: 7, L2BlockNumber: 1800, L1Timestamp: 1000, L1BlockNumber: 99, TxHash: common.HexToHash("0x02")}
+	dec, err := UnmarshalOutputProposed(MarshalOutputProposed(oracle, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *dec != *p {
+		t.Fatalf("decoded %+v, want %+v", dec, p)
+	}
+	if _, err := UnmarshalOutputProposed(&types.Log{Topics: []common.Hash{{}}}); err == nil {
+		t.Fatal("unrelated event decoded")
+	}
+
+	data, err := PackProposeL2Output(p.OutputRoot, p.L2BlockNumber, common.HexToHash("0x03"), p.L1BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := L2OutputOracleABI.Methods["proposeL2Output"].Inputs.Unpack(data[4:])
+	if err != nil || common.Hash(args[0].([32]byte)) != p.OutputRoot || args[1].(*big.Int).Uint64() != p.L2BlockNumber {
+		t.Fatalf("unexpected arguments %v, %v", args, err)
+	}
+	res, _ := L2OutputOracleABI.Methods["latestBlockNumber"].Outputs.Pack(big.NewInt(1800))
+	if latest, err := UnpackLatestBlockNumber(res); err != nil || latest != 1800 {
+		t.Fatalf("latest block %d, %v", latest, err)
+	}
+}
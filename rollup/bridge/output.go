@@ -0,0 +1,105 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// OutputProposal is an output proposal of the L2 output oracle, as described by
+// its OutputProposed event.
+type OutputProposal struct {
+	OutputRoot    common.Hash
+	OutputIndex   uint64
+	L2BlockNumber uint64
+	L1Timestamp   uint64      // L1 time of the proposal
+	L1BlockNumber uint64      // L1 block the proposal was included in
+	TxHash        common.Hash // transaction of the proposal
+}
+
+// PackProposeL2Output encodes the call of the output oracle that proposes the
+// output root of the given L2 block. The call is only valid in a chain that
+// contains the given L1 block.
+func PackProposeL2Output(outputRoot common.Hash, l2Block uint64, l1Hash common.Hash, l1Block uint64) ([]byte, error) {
+	return L2OutputOracleABI.Pack("proposeL2Output", outputRoot, new(big.Int).SetUint64(l2Block), l1Hash, new(big.Int).SetUint64(l1Block))
+}
+
+// PackLatestBlockNumber encodes the call of the output oracle that returns the
+// L2 block of the latest proposed output.
+func PackLatestBlockNumber() ([]byte, error) {
+	return L2OutputOracleABI.Pack("latestBlockNumber")
+}
+
+// UnpackLatestBlockNumber decodes the result of latestBlockNumber.
+func UnpackLatestBlockNumber(res []byte) (uint64, error) {
+	out, err := L2OutputOracleABI.Unpack("latestBlockNumber", res)
+	if err != nil {
+		return 0, err
+	}
+	number := out[0].(*big.Int)
+	if !number.IsUint64() {
+		return 0, fmt.Errorf("latest L2 block %v out of range", number)
+	}
+	return number.Uint64(), nil
+}
+
+// UnmarshalOutputProposed decodes an OutputProposed event of the output oracle.
+func UnmarshalOutputProposed(l *types.Log) (*OutputProposal, error) {
+	event := L2OutputOracleABI.Events["OutputProposed"]
+	if len(l.Topics) != 4 || l.Topics[0] != event.ID {
+		return nil, fmt.Errorf("not an %s event", event.Name)
+	}
+	args, err := event.Inputs.NonIndexed().Unpack(l.Data)
+	if err != nil {
+		return nil, err
+	}
+	index, number, timestamp := l.Topics[2].Big(), l.Topics[3].Big(), args[0].(*big.Int)
+	if !index.IsUint64() || !number.IsUint64() || !timestamp.IsUint64() {
+		return nil, fmt.Errorf("output index %v, L2 block %v or timestamp %v out of range", index, number, timestamp)
+	}
+	return &OutputProposal{
+		OutputRoot:    l.Topics[1],
+		OutputIndex:   index.Uint64(),
+		L2BlockNumber: number.Uint64(),
+		L1Timestamp:   timestamp.Uint64(),
+		L1BlockNumber: l.BlockNumber,
+		TxHash:        l.TxHash,
+	}, nil
+}
+
+// MarshalOutputProposed encodes a proposal as an OutputProposed event of the
+// output oracle at the given address.
+func MarshalOutputProposed(oracle common.Address, p *OutputProposal) *types.Log {
+	event := L2OutputOracleABI.Events["OutputProposed"]
+	data, _ := event.Inputs.NonIndexed().Pack(new(big.Int).SetUint64(p.L1Timestamp))
+	return &types.Log{
+		Address: oracle,
+		Topics: []common.Hash{
+			event.ID,
+			p.OutputRoot,
+			common.BigToHash(new(big.Int).SetUint64(p.OutputIndex)),
+			common.BigToHash(new(big.Int).SetUint64(p.L2BlockNumber)),
+		},
+		Data:        data,
+		BlockNumber: p.L1BlockNumber,
+		TxHash:      p.TxHash,
+	}
+}
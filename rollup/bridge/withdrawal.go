@@ -0,0 +1,190 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// PackDepositTransaction encodes the call of the portal that deposits a
+// transaction into L2. The call value is minted on L2, and the given value of
+// it is sent to the target.
+func PackDepositTransaction(to common.Address, value *big.Int, gasLimit uint64, isCreation bool, data []byte) ([]byte, error) {
+	return PortalABI.Pack("depositTransaction", to, value, gasLimit, isCreation, data)
+}
+
+// PackInitiateWithdrawal encodes the call of the L2 message passer that sends a
+// message to the target on L1. The call value is withdrawn along with it.
+func PackInitiateWithdrawal(target common.Address, gasLimit *big.Int, data []byte) ([]byte, error) {
+	return MessagePasserABI.Pack("initiateWithdrawal", target, gasLimit, data)
+}
+
+// UnmarshalMessagePassed decodes a MessagePassed event of the message passer.
+// The hash in the event must match the withdrawal.
+func UnmarshalMessagePassed(l *types.Log) (*rollup.Withdrawal, error) {
+	event := MessagePasserABI.Events["MessagePassed"]
+	if len(l.Topics) != 4 || l.Topics[0] != event.ID {
+		return nil, fmt.Errorf("not a %s event", event.Name)
+	}
+	args, err := event.Inputs.NonIndexed().Unpack(l.Data)
+	if err != nil {
+		return nil, err
+	}
+	w := &rollup.Withdrawal{
+		Nonce:    l.Topics[1].Big(),
+		Sender:   common.BytesToAddress(l.Topics[2][:]),
+		Target:   common.BytesToAddress(l.Topics[3][:]),
+		Value:    args[0].(*big.Int),
+		GasLimit: args[1].(*big.Int),
+		Data:     args[2].([]byte),
+	}
+	want, err := w.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if hash := common.Hash(args[3].([32]byte)); hash != want {
+		return nil, fmt.Errorf("message hash %s, want %s", hash, want)
+	}
+	return w, nil
+}
+
+// MarshalMessagePassed encodes a withdrawal as a MessagePassed event of the
+// message passer, with the given withdrawal hash.
+func MarshalMessagePassed(w *rollup.Withdrawal, hash common.Hash) *types.Log {
+	event := MessagePasserABI.Events["MessagePassed"]
+	data, _ := event.Inputs.NonIndexed().Pack(w.Value, w.GasLimit, []byte(w.Data), hash)
+	return &types.Log{
+		Address: rollup.L2ToL1MessagePasserAddr,
+		Topics:  []common.Hash{event.ID, common.BigToHash(w.Nonce), common.BytesToHash(w.Sender[:]), common.BytesToHash(w.Target[:])},
+		Data:    data,
+	}
+}
+
+// Withdrawals returns the withdrawals initiated by the L2 transaction of the
+// given receipt, in the order they were sent.
+func Withdrawals(receipt *types.Receipt) ([]*rollup.Withdrawal, error) {
+	var withdrawals []*rollup.Withdrawal
+	for _, l := range receipt.Logs {
+		if l.Address != rollup.L2ToL1MessagePasserAddr || len(l.Topics) == 0 || l.Topics[0] != MessagePasserABI.Events["MessagePassed"].ID {
+			continue
+		}
+		w, err := UnmarshalMessagePassed(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message in log %d: %w", l.Index, err)
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	return withdrawals, nil
+}
+
+// ProofClient is the rollup node API that withdrawals are proven with. It is
+// implemented by node.Client.
+type ProofClient interface {
+	OutputProofAtBlock(ctx context.Context, number uint64, keys []common.Hash) (*rollup.OutputProof, error)
+}
+
+// ProveWithdrawal fetches the output of the given L2 block along with the proof
+// that the withdrawal was sent at or before it.
+func ProveWithdrawal(ctx context.Context, node ProofClient, w *rollup.Withdrawal, l2Block uint64) (*rollup.OutputProof, error) {
+	slot, err := w.StorageSlot()
+	if err != nil {
+		return nil, err
+	}
+	proof, err := node.OutputProofAtBlock(ctx, l2Block, []common.Hash{slot})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch output proof at L2 block %d: %w", l2Block, err)
+	}
+	if len(proof.StorageProof) != 1 || proof.StorageProof[0].Key != slot {
+		return nil, fmt.Errorf("rollup node returned no proof of slot %s", slot)
+	}
+	if v := proof.StorageProof[0].Value; v == nil || v.ToInt().Sign() == 0 {
+		return nil, fmt.Errorf("withdrawal not sent at L2 block %d", l2Block)
+	}
+	return proof, nil
+}
+
+// withdrawalTx and outputRootProof are the tuple arguments of the portal.
+type withdrawalTx struct {
+	Nonce    *big.Int
+	Sender   common.Address
+	Target   common.Address
+	Value    *big.Int
+	GasLimit *big.Int
+	Data     []byte
+}
+
+type outputRootProof struct {
+	Version               [32]byte
+	StateRoot             [32]byte
+	WithdrawerStorageRoot [32]byte
+	LatestBlockhash       [32]byte
+}
+
+// PackFinalizeWithdrawal encodes the call of the portal that finalizes a
+// withdrawal, proven against the output of the given L2 block as returned by
+// ProveWithdrawal.
+func PackFinalizeWithdrawal(w *rollup.Withdrawal, l2Block uint64, proof *rollup.OutputProof) ([]byte, error) {
+	if len(proof.StorageProof) != 1 {
+		return nil, fmt.Errorf("%d storage proofs, want 1", len(proof.StorageProof))
+	}
+	nodes := make([][]byte, len(proof.StorageProof[0].Proof))
+	for i, node := range proof.StorageProof[0].Proof {
+		nodes[i] = node
+	}
+	return PortalABI.Pack("finalizeWithdrawalTransaction",
+		withdrawalTx{w.Nonce, w.Sender, w.Target, w.Value, w.GasLimit, w.Data},
+		new(big.Int).SetUint64(l2Block),
+		outputRootProof{proof.Version, proof.StateRoot, proof.WithdrawalStorageRoot, proof.BlockHash},
+		nodes,
+	)
+}
+
+// PackFinalizedWithdrawals encodes the call of the portal that checks whether
+// the withdrawal with the given hash was finalized.
+func PackFinalizedWithdrawals(hash common.Hash) ([]byte, error) {
+	return PortalABI.Pack("finalizedWithdrawals", hash)
+}
+
+// UnpackFinalizedWithdrawals decodes the result of finalizedWithdrawals.
+func UnpackFinalizedWithdrawals(res []byte) (bool, error) {
+	out, err := PortalABI.Unpack("finalizedWithdrawals", res)
+	if err != nil {
+		return false, err
+	}
+	return out[0].(bool), nil
+}
+
+// UnmarshalWithdrawalFinalized decodes the WithdrawalFinalized event of the
+// portal, returning the withdrawal hash and whether the call to the target
+// succeeded.
+func UnmarshalWithdrawalFinalized(l *types.Log) (common.Hash, bool, error) {
+	event := PortalABI.Events["WithdrawalFinalized"]
+	if len(l.Topics) != 2 || l.Topics[0] != event.ID {
+		return common.Hash{}, false, fmt.Errorf("not a %s event", event.Name)
+	}
+	args, err := event.Inputs.NonIndexed().Unpack(l.Data)
+	if err != nil {
+		return common.Hash{}, false, err
+	}
+	return l.Topics[1], args[0].(bool), nil
+}
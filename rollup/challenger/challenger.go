@@ -0,0 +1,167 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package challenger implements the challenger, which checks the output roots
+// proposed to the output oracle on L1 against the outputs that a trusted rollup
+// node derives, and reports the invalid ones.
+package challenger
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+	"github.com/ethereum/go-ethereum/rollup/internal/poll"
+)
+
+// Config contains the settings of the challenger.
+type Config struct {
+	OutputOracleAddress common.Address
+
+	// StartBlock is the L1 block from which proposals are checked.
+	StartBlock uint64
+	// Confirmations is the number of L1 blocks on top of a proposal before it
+	// is checked, so that proposals are not reported twice after L1 reorgs.
+	Confirmations uint64
+	// MaxBlockRange is the largest range of L1 blocks whose proposals are
+	// fetched at once.
+	MaxBlockRange uint64
+	// PollInterval is the interval at which L1 and the rollup node are polled.
+	// Failures back off up to MaxRetryInterval.
+	PollInterval     time.Duration
+	MaxRetryInterval time.Duration
+}
+
+// DefaultConfig contains reasonable default settings.
+var DefaultConfig = Config{
+	Confirmations:    5,
+	MaxBlockRange:    1000,
+	PollInterval:     12 * time.Second,
+	MaxRetryInterval: time.Minute,
+}
+
+// L1Client is the L1 API used by the challenger. It is implemented by
+// ethclient.Client.
+type L1Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// RollupClient is the rollup node API used by the challenger. It is implemented
+// by node.Client.
+type RollupClient interface {
+	SyncStatus(ctx context.Context) (*rollup.SyncStatus, error)
+	OutputAtBlock(ctx context.Context, number uint64) (*rollup.Output, error)
+}
+
+// Disputer acts on invalid proposals, for example by sending the transaction
+// that disputes them.
+type Disputer interface {
+	// Dispute is called once for every invalid proposal, with the output
+	// that the rollup node derived for the proposed block.
+	Dispute(ctx context.Context, proposal *bridge.OutputProposal, output *rollup.Output) error
+}
+
+// Challenger checks every proposal of the output oracle once the rollup node has
+// derived the proposed block from L1 as a safe block. Invalid proposals are
+// logged, and passed to the disputer if there is one.
+//
+// The challenger keeps no state of its own: a restarted challenger checks the
+// proposals from the start block again.
+type Challenger struct {
+	cfg      Config
+	disputer Disputer
+	log      log.Logger
+
+	fetcher *Fetcher
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a challenger. The disputer may be nil, in which case invalid
+// proposals are only reported.
+func New(cfg Config, l1 L1Client, node RollupClient, disputer Disputer, logger log.Logger) *Challenger {
+	if cfg.MaxBlockRange == 0 {
+		cfg.MaxBlockRange = DefaultConfig.MaxBlockRange
+	}
+	return &Challenger{
+		cfg:      cfg,
+		disputer: disputer,
+		log:      logger,
+		fetcher:  NewFetcher(l1, node, cfg.OutputOracleAddress, cfg.StartBlock, cfg.Confirmations, cfg.MaxBlockRange, logger),
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start starts checking proposals in the background.
+func (c *Challenger) Start() {
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// Stop stops the challenger and waits for it to shut down.
+func (c *Challenger) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+}
+
+func (c *Challenger) loop() {
+	defer c.wg.Done()
+	poll.Loop(c.quit, c.cfg.PollInterval, c.cfg.MaxRetryInterval, c.Step, c.log, "Checking proposals failed")
+}
+
+// Step fetches the new proposals from L1, and checks the proposals whose L2
+// block is safe.
+func (c *Challenger) Step(ctx context.Context) error {
+	_, err := c.fetcher.Fetch(ctx)
+	pendingGauge.Update(int64(c.fetcher.Pending()))
+	if err != nil {
+		return err
+	}
+	_, err = c.fetcher.CheckSafe(ctx, func(p *bridge.OutputProposal, output *rollup.Output) error {
+		return c.check(ctx, p, output)
+	})
+	pendingGauge.Update(int64(c.fetcher.Pending()))
+	return err
+}
+
+// check compares a proposal with the output of the rollup node, and disputes it
+// if they differ.
+func (c *Challenger) check(ctx context.Context, p *bridge.OutputProposal, output *rollup.Output) error {
+	checkedMeter.Mark(1)
+	if output.OutputRoot == p.OutputRoot {
+		c.log.Info("Output proposal is valid", "l2block", p.L2BlockNumber, "output", p.OutputRoot, "index", p.OutputIndex)
+		return nil
+	}
+	invalidMeter.Mark(1)
+	c.log.Error("Invalid output proposal", "l2block", p.L2BlockNumber, "index", p.OutputIndex, "proposed", p.OutputRoot, "derived", output.OutputRoot, "l1block", p.L1BlockNumber, "tx", p.TxHash)
+	if c.disputer == nil {
+		return nil
+	}
+	if err := c.disputer.Dispute(ctx, p, output); err != nil {
+		return fmt.Errorf("failed to dispute output at L2 block %d: %w", p.L2BlockNumber, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package challenger
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+)
+
+var testOracle = common.HexToAddress("0x0000000000000000000000000000000000000abc")
+
+// testL1 is an L1 chain with an output oracle.
+type testL1 struct {
+	head    uint64
+	logs    []types.Log
+	queries []ethereum.FilterQuery
+}
+
+func (l *testL1) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).SetUint64(l.head)}, nil
+}
+
+func (l *testL1) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	l.queries = append(l.queries, q)
+	var logs []types.Log
+	for _, log := range l.logs {
+		if log.BlockNumber >= q.FromBlock.Uint64() && log.BlockNumber <= q.ToBlock.Uint64() && log.Address == q.Addresses[0] {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// propose includes a proposal in the given L1 block.
+func (l *testL1) propose(l1Block, l2Block uint64, root common.Hash) {
+	p := &bridge.OutputProposal{OutputRoot: root, OutputIndex: uint64(len(l.logs)), L2BlockNumber: l2Block, L1Timestamp: 1000, L1BlockNumber: l1Block}
+	l.logs = append(l.logs, *bridge.MarshalOutputProposed(testOracle, p))
+}
+
+// testNode is a rollup node whose output roots are the block numbers.
+type testNode struct {
+	safe uint64
+}
+
+func (n *testNode) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	return &rollup.SyncStatus{SafeL2: rollup.L2BlockRef{Number: n.safe}}, nil
+}
+
+func (n *testNode) OutputAtBlock(ctx context.Context, number uint64) (*rollup.Output, error) {
+	return &rollup.Output{OutputRoot: outputRoot(number)}, nil
+}
+
+func outputRoot(number uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(number))
+}
+
+type testDisputer struct {
+	disputed []*bridge.OutputProposal
+	err      error
+}
+
+func (d *testDisputer) Dispute(ctx context.Context, p *bridge.OutputProposal, output *rollup.Output) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.disputed = append(d.disputed, p)
+	return nil
+}
+
+func TestChallenger(t *testing.T) {
+	var (
+		l1       = &testL1{head: 20}
+		node     = &testNode{safe: 10}
+		disputer = &testDisputer{}
+		cfg      = DefaultConfig
+	)
+	cfg.OutputOracleAddress = testOracle
+	cfg.StartBlock = 3
+	cfg.Confirmations = 2
+	cfg.MaxBlockRange = 5
+	c := New(cfg, l1, node, disputer, log.New())
+
+	l1.propose(2, 5, common.HexToHash("0xbad")) // before the start block
+	l1.propose(4, 10, outputRoot(10))
+	l1.propose(6, 20, common.HexToHash("0xbad"))
+	l1.propose(19, 30, common.HexToHash("0xbad")) // not confirmed
+	if err := c.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.fetcher.next != 19 || len(l1.queries) != 4 {
+		t.Fatalf("fetched up to L1 block %d in %d queries, want 18 in 4", c.fetcher.next-1, len(l1.queries))
+	}
+	// The proposal of the block that is not safe yet is pending.
+	if len(c.fetcher.pending) != 1 || c.fetcher.pending[0].L2BlockNumber != 20 || len(disputer.disputed) != 0 {
+		t.Fatalf("unexpected pending proposals %v, disputed %v", c.fetcher.pending, disputer.disputed)
+	}
+
+	// Failed disputes are retried.
+	node.safe = 20
+	disputer.err = errors.New("failed")
+	if err := c.Step(context.Background()); err == nil {
+		t.Fatal("failed dispute not reported")
+	}
+	disputer.err = nil
+	if err := c.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.fetcher.pending) != 0 || len(disputer.disputed) != 1 || disputer.disputed[0].L2BlockNumber != 20 {
+		t.Fatalf("unexpected pending proposals %v, disputed %v", c.fetcher.pending, disputer.disputed)
+	}
+
+	l1.head, node.safe = 21, 30
+	if err := c.Step(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(disputer.disputed) != 2 || disputer.disputed[1].L2BlockNumber != 30 || disputer.disputed[1].OutputIndex != 3 {
+		t.Fatalf("unexpected disputed proposals %v", disputer.disputed)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package challenger
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+)
+
+// Fetcher follows the proposals of the output oracle in the confirmed L1
+// blocks, and hands them out along with the local output once the rollup node
+// derived their L2 block as safe. It is shared by the challenger and the fault
+// monitor.
+type Fetcher struct {
+	l1            L1Client
+	node          RollupClient
+	oracle        common.Address
+	confirmations uint64
+	maxBlockRange uint64
+	log           log.Logger
+
+	next    uint64                   // next L1 block whose proposals are fetched
+	pending []*bridge.OutputProposal // proposals whose L2 block is not safe yet, by L2 block
+}
+
+// NewFetcher creates a fetcher of the proposals of the given output oracle,
+// starting at the given L1 block. Proposals are fetched once they have the
+// given number of confirmations, at most maxBlockRange L1 blocks at a time.
+func NewFetcher(l1 L1Client, node RollupClient, oracle common.Address, start, confirmations, maxBlockRange uint64, logger log.Logger) *Fetcher {
+	return &Fetcher{
+		l1:            l1,
+		node:          node,
+		oracle:        oracle,
+		confirmations: confirmations,
+		maxBlockRange: maxBlockRange,
+		log:           logger,
+		next:          start,
+	}
+}
+
+// Pending returns the number of proposals whose L2 block is not safe yet.
+func (f *Fetcher) Pending() int {
+	return len(f.pending)
+}
+
+// Fetch adds the proposals of the confirmed L1 blocks that were not fetched
+// yet to the pending proposals, and returns them in L1 order. If fetching a
+// range of L1 blocks fails, the proposals of the previous ranges are returned
+// along with the error, as they are pending already.
+func (f *Fetcher) Fetch(ctx context.Context) ([]*bridge.OutputProposal, error) {
+	head, err := f.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	if head.Number.Uint64() < f.confirmations {
+		return nil, nil
+	}
+	var (
+		confirmed = head.Number.Uint64() - f.confirmations
+		added     []*bridge.OutputProposal
+	)
+	for f.next <= confirmed {
+		to := f.next + f.maxBlockRange - 1
+		if to > confirmed {
+			to = confirmed
+		}
+		var proposals []*bridge.OutputProposal
+		if proposals, err = FetchProposals(ctx, f.l1, f.oracle, f.next, to, f.log); err != nil {
+			break
+		}
+		added = append(added, proposals...)
+		f.next = to + 1
+	}
+	f.pending = append(f.pending, added...)
+	sort.SliceStable(f.pending, func(i, j int) bool {
+		return f.pending[i].L2BlockNumber < f.pending[j].L2BlockNumber
+	})
+	return added, err
+}
+
+// CheckSafe calls check with every pending proposal whose L2 block is safe, and
+// the output of the rollup node at that block. A proposal stays pending if
+// check fails, and is checked again on the next call. It returns the safe L2
+// block of the rollup node.
+func (f *Fetcher) CheckSafe(ctx context.Context, check func(p *bridge.OutputProposal, output *rollup.Output) error) (uint64, error) {
+	status, err := f.node.SyncStatus(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch sync status: %w", err)
+	}
+	safe := status.SafeL2.Number
+	for len(f.pending) > 0 && f.pending[0].L2BlockNumber <= safe {
+		p := f.pending[0]
+		output, err := f.node.OutputAtBlock(ctx, p.L2BlockNumber)
+		if err != nil {
+			return safe, fmt.Errorf("failed to fetch output at L2 block %d: %w", p.L2BlockNumber, err)
+		}
+		if err := check(p, output); err != nil {
+			return safe, err
+		}
+		f.pending = f.pending[1:]
+	}
+	return safe, nil
+}
+
+// FetchProposals returns the proposals of the output oracle at the given
+// address that were included in the given range of L1 blocks, in L1 order.
+// Malformed proposals are logged and skipped.
+func FetchProposals(ctx context.Context, l1 L1Client, oracle common.Address, from, to uint64, logger log.Logger) ([]*bridge.OutputProposal, error) {
+	logs, err := l1.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{oracle},
+		Topics:    [][]common.Hash{{bridge.L2OutputOracleABI.Events["OutputProposed"].ID}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch proposals of L1 blocks %d-%d: %w", from, to, err)
+	}
+	var proposals []*bridge.OutputProposal
+	for _, l := range logs {
+		p, err := bridge.UnmarshalOutputProposed(&l)
+		if err != nil {
+			logger.Warn("Ignoring malformed proposal", "l1block", l.BlockNumber, "tx", l.TxHash, "err", err)
+			continue
+		}
+		logger.Debug("Found output proposal", "l2block", p.L2BlockNumber, "output", p.OutputRoot, "l1block", p.L1BlockNumber)
+		proposals = append(proposals, p)
+	}
+	return proposals, nil
+}
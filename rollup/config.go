@@ -0,0 +1,277 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Genesis anchors the L2 chain to L1.
+type Genesis struct {
+	// L1 is the L1 block that the L2 genesis block is derived from. Derivation
+	// starts at the block after it.
+	L1 BlockID `json:"l1"`
+	// L2 is the L2 genesis block.
+	L2 BlockID `json:"l2"`
+	// L2Time is the timestamp of the L2 genesis block.
+	L2Time uint64 `json:"l2_time"`
+}
+
+// Config is the rollup configuration, shared by all the nodes of an L2 chain.
+type Config struct {
+	Genesis Genesis `json:"genesis"`
+
+	// BlockTime is the number of seconds between two L2 blocks.
+	BlockTime uint64 `json:"block_time"`
+	// MaxSequencerDrift is the maximum number of seconds the timestamp of an L2
+	// block may be ahead of the timestamp of its L1 origin.
+	MaxSequencerDrift uint64 `json:"max_sequencer_drift"`
+	// SeqWindowSize is the number of L1 blocks, starting at the L1 origin of
+	// an epoch, in which the batches of the epoch must be included. Blocks
+	// without a batch once the window has passed are derived without
+	// transactions other than the deposits of their epoch.
+	SeqWindowSize uint64 `json:"seq_window_size"`
+	// ChannelTimeout is the number of L1 blocks after its first frame within
+	// which a channel must be complete. Zero means the default of the
+	// derivation pipeline.
+	ChannelTimeout uint64 `json:"channel_timeout,omitempty"`
+	// StrictBatchOrdering makes derivation require the frames and batches in
+	// the order they are derived in: out-of-order frames and batches are
+	// dropped instead of buffered, and invalid batches are replaced by blocks
+	// with only the deposits of their epoch. This bounds the state a fault
+	// proof has to replay.
+	StrictBatchOrdering bool `json:"strict_batch_ordering,omitempty"`
+
+	L1ChainID *big.Int `json:"l1_chain_id"`
+	L2ChainID *big.Int `json:"l2_chain_id"`
+
+	// BatchInboxAddress is the L1 address that batches are sent to.
+	BatchInboxAddress common.Address `json:"batch_inbox_address"`
+	// BatchSenderAddress is the only L1 account that is allowed to post batches.
+	BatchSenderAddress common.Address `json:"batch_sender_address"`
+	// DepositContractAddress is the L1 contract whose events are deposits.
+	DepositContractAddress common.Address `json:"deposit_contract_address"`
+	// SystemConfigAddress is the L1 contract whose events update the system
+	// config. If unset, the system config of the genesis applies forever.
+	SystemConfigAddress common.Address `json:"system_config_address,omitempty"`
+	// GenesisGasLimit is the gas limit of the L2 blocks until the system config
+	// changes it. Zero leaves the gas limit to the engine.
+	GenesisGasLimit uint64 `json:"genesis_gas_limit,omitempty"`
+	// MaxDepositsPerBlock is the maximum number of deposits of its epoch that
+	// an L2 block includes. The deposits beyond it are carried over to the next
+	// blocks of the epoch, and the L2 chain does not move on to the next epoch
+	// before all of them are included, even beyond the maximum sequencer
+	// drift. Zero means no limit.
+	MaxDepositsPerBlock uint64 `json:"max_deposits_per_block,omitempty"`
+	// MaxDepositBytesPerBlock limits the encoded size of the deposits that an
+	// L2 block includes, like MaxDepositsPerBlock. A deposit that is larger on
+	// its own is included alone. Zero means no limit.
+	MaxDepositBytesPerBlock uint64 `json:"max_deposit_bytes_per_block,omitempty"`
+
+	// DataAvailability is the name of the source of the batch data, empty for
+	// the calldata of the batch inbox transactions.
+	DataAvailability string `json:"data_availability,omitempty"`
+
+	// FeeRecipientAddress is the L2 account that receives the priority fees
+	// of all L2 blocks.
+	FeeRecipientAddress common.Address `json:"fee_recipient_address"`
+
+	// RegolithTime is the L2 block timestamp at which the Regolith upgrade
+	// activates, nil if it is not scheduled. It must match the chain config of
+	// the L2 chain.
+	RegolithTime *uint64 `json:"regolith_time,omitempty"`
+
+	// InteropTime is the L1 block timestamp from which on the deposits derived
+	// from L1 blocks commit to the L2 chain ID in their source hashes, nil if
+	// it is not scheduled. Then chains that derive from the same L1 blocks
+	// cannot include each other's deposits. Unlike the L2 upgrades, it is
+	// keyed by the L1 origin, since all deposits of an epoch are derived from
+	// the same L1 block.
+	InteropTime *uint64 `json:"interop_time,omitempty"`
+}
+
+// LoadConfig reads a rollup configuration from the given JSON file, and checks
+// that it is valid.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid rollup config %s: %w", path, err)
+	}
+	if err := cfg.Check(); err != nil {
+		return nil, fmt.Errorf("invalid rollup config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save writes the rollup configuration to the given JSON file.
+func (cfg *Config) Save(path string) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Check verifies that the configuration is complete and consistent.
+func (cfg *Config) Check() error {
+	switch {
+	case cfg.Genesis.L1.Hash == (common.Hash{}):
+		return errors.New("missing L1 genesis block hash")
+	case cfg.Genesis.L2.Hash == (common.Hash{}):
+		return errors.New("missing L2 genesis block hash")
+	case cfg.BlockTime == 0:
+		return errors.New("block time must be positive")
+	case cfg.MaxSequencerDrift < cfg.BlockTime:
+		return fmt.Errorf("max sequencer drift %d below block time %d", cfg.MaxSequencerDrift, cfg.BlockTime)
+	case cfg.SeqWindowSize == 0:
+		return errors.New("sequencing window size must be positive")
+	case cfg.L1ChainID == nil || cfg.L1ChainID.Sign() <= 0:
+		return errors.New("L1 chain ID must be positive")
+	case cfg.L2ChainID == nil || cfg.L2ChainID.Sign() <= 0:
+		return errors.New("L2 chain ID must be positive")
+	case cfg.L2ChainID.BitLen() > 256:
+		return errors.New("L2 chain ID exceeds 256 bits")
+	case cfg.L1ChainID.Cmp(cfg.L2ChainID) == 0:
+		return errors.New("L1 and L2 chain IDs are equal")
+	case cfg.BatchInboxAddress == (common.Address{}):
+		return errors.New("missing batch inbox address")
+	case cfg.BatchSenderAddress == (common.Address{}):
+		return errors.New("missing batch sender address")
+	case cfg.DepositContractAddress == (common.Address{}):
+		return errors.New("missing deposit contract address")
+	case cfg.FeeRecipientAddress == (common.Address{}):
+		return errors.New("missing fee recipient address")
+	case cfg.GenesisGasLimit != 0 && cfg.GenesisGasLimit < params.MinGasLimit:
+		return fmt.Errorf("genesis gas limit %d below minimum %d", cfg.GenesisGasLimit, params.MinGasLimit)
+	}
+	return nil
+}
+
+// CheckChainConfig verifies that the configuration agrees with the chain config
+// of the L2 execution engine on the L2 chain ID and on the time of the L2
+// upgrades, which both the rollup node and the engine apply.
+func (cfg *Config) CheckChainConfig(chainCfg *params.ChainConfig) error {
+	switch {
+	case chainCfg.Optimism == nil:
+		return errors.New("chain config is not a rollup chain config")
+	case chainCfg.ChainID == nil || chainCfg.ChainID.Cmp(cfg.L2ChainID) != 0:
+		return fmt.Errorf("L2 chain ID %v, chain config has %v", cfg.L2ChainID, chainCfg.ChainID)
+	case !sameTime(cfg.RegolithTime, chainCfg.Optimism.RegolithTime):
+		return fmt.Errorf("Regolith time %s, chain config has %s", formatTime(cfg.RegolithTime), formatTime(chainCfg.Optimism.RegolithTime))
+	}
+	return nil
+}
+
+func sameTime(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatTime(t *uint64) string {
+	if t == nil {
+		return "unscheduled"
+	}
+	return fmt.Sprint(*t)
+}
+
+// LimitsDeposits returns whether the deposits of an epoch may be spread over
+// several L2 blocks.
+func (cfg *Config) LimitsDeposits() bool {
+	return cfg.MaxDepositsPerBlock != 0 || cfg.MaxDepositBytesPerBlock != 0
+}
+
+// IsRegolith returns whether the Regolith upgrade is active at the given L2
+// block timestamp.
+func (cfg *Config) IsRegolith(timestamp uint64) bool {
+	return cfg.RegolithTime != nil && timestamp >= *cfg.RegolithTime
+}
+
+// IsRegolithActivationBlock returns whether the L2 block with the given
+// timestamp is the first block of the Regolith upgrade. An upgrade that is
+// active at genesis has no activation block.
+func (cfg *Config) IsRegolithActivationBlock(timestamp uint64) bool {
+	return cfg.IsRegolith(timestamp) && timestamp > cfg.Genesis.L2Time && !cfg.IsRegolith(timestamp-cfg.BlockTime)
+}
+
+// IsInterop returns whether the deposits derived from the L1 block with the
+// given timestamp commit to the L2 chain ID.
+func (cfg *Config) IsInterop(l1Time uint64) bool {
+	return cfg.InteropTime != nil && l1Time >= *cfg.InteropTime
+}
+
+// DepositSourceHash returns the source hash of a deposit derived from the L1
+// block with the given timestamp, given its source hash in its own domain.
+// After the Interop upgrade, it is bound to the L2 chain ID.
+func (cfg *Config) DepositSourceHash(l1Time uint64, source common.Hash) common.Hash {
+	if !cfg.IsInterop(l1Time) {
+		return source
+	}
+	return types.ChainDepositSourceHash(cfg.L2ChainID, source)
+}
+
+// CheckChainIDs verifies that the given rollups can share infrastructure, like
+// the L1 chain or a gossip network: no two of them may have the same L2 chain
+// ID, and no L2 chain ID may be the one of an L1 chain. The configurations
+// must be valid on their own.
+func CheckChainIDs(cfgs ...*Config) error {
+	l1s := make(map[string]bool)
+	for _, cfg := range cfgs {
+		l1s[cfg.L1ChainID.String()] = true
+	}
+	l2s := make(map[string]bool)
+	for _, cfg := range cfgs {
+		id := cfg.L2ChainID.String()
+		switch {
+		case l2s[id]:
+			return fmt.Errorf("L2 chain ID %s used by several rollups", id)
+		case l1s[id]:
+			return fmt.Errorf("L2 chain ID %s is the ID of an L1 chain", id)
+		}
+		l2s[id] = true
+	}
+	return nil
+}
+
+// GenesisSystemConfig returns the system config at the L1 genesis block, before
+// any update.
+func (cfg *Config) GenesisSystemConfig() SystemConfig {
+	return SystemConfig{BatcherAddr: cfg.BatchSenderAddress, GasLimit: cfg.GenesisGasLimit}
+}
+
+// L2GenesisRef returns the reference of the L2 genesis block.
+func (cfg *Config) L2GenesisRef() L2BlockRef {
+	return L2BlockRef{
+		Hash:     cfg.Genesis.L2.Hash,
+		Number:   cfg.Genesis.L2.Number,
+		Time:     cfg.Genesis.L2Time,
+		L1Origin: cfg.Genesis.L1,
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Genesis: Genesis{
+			L1:     BlockID{Hash: common.HexToHash("0x01"), Number: 10},
+			L2:     BlockID{Hash: common.HexToHash("0x02")},
+			L2Time: 1000,
+		},
+		BlockTime:              2,
+		MaxSequencerDrift:      600,
+		SeqWindowSize:          120,
+		L1ChainID:              big.NewInt(900),
+		L2ChainID:              big.NewInt(901),
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     common.HexToAddress("0x1234"),
+		DepositContractAddress: common.HexToAddress("0xdeadbeef"),
+		FeeRecipientAddress:    common.HexToAddress("0x4200000000000000000000000000000000000011"),
+	}
+}
+
+func TestConfigSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rollup.json")
+	cfg := testConfig()
+	if err := cfg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, cfg) {
+		t.Fatalf("loaded config differs\nhave %+v\nwant %+v", loaded, cfg)
+	}
+
+	// Invalid configs are rejected on load.
+	cfg.BlockTime = 0
+	if err := cfg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("invalid config loaded")
+	}
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("malformed config loaded")
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	if err := testConfig().Check(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	invalid := map[string]func(*Config){
+		"no L1 genesis":        func(c *Config) { c.Genesis.L1.Hash = common.Hash{} },
+		"no L2 genesis":        func(c *Config) { c.Genesis.L2.Hash = common.Hash{} },
+		"no block time":        func(c *Config) { c.BlockTime = 0 },
+		"drift below block":    func(c *Config) { c.MaxSequencerDrift = 1 },
+		"no sequencing window": func(c *Config) { c.SeqWindowSize = 0 },
+		"no L1 chain ID":       func(c *Config) { c.L1ChainID = nil },
+		"negative chain ID":    func(c *Config) { c.L2ChainID = big.NewInt(-1) },
+		"equal chain IDs":      func(c *Config) { c.L2ChainID = c.L1ChainID },
+		"oversized chain ID":   func(c *Config) { c.L2ChainID = new(big.Int).Lsh(common.Big1, 256) },
+		"no batch inbox":       func(c *Config) { c.BatchInboxAddress = common.Address{} },
+		"no batch sender":      func(c *Config) { c.BatchSenderAddress = common.Address{} },
+		"no deposit contract":  func(c *Config) { c.DepositContractAddress = common.Address{} },
+		"no fee recipient":     func(c *Config) { c.FeeRecipientAddress = common.Address{} },
+		"low gas limit":        func(c *Config) { c.GenesisGasLimit = 1 },
+	}
+	for name, modify := range invalid {
+		cfg := testConfig()
+		modify(cfg)
+		if err := cfg.Check(); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestConfigRegolithActivation(t *testing.T) {
+	cfg := testConfig()
+	if cfg.IsRegolith(2000) || cfg.IsRegolithActivationBlock(2000) {
+		t.Fatal("unscheduled Regolith active")
+	}
+	regolith := uint64(1010)
+	cfg.RegolithTime = &regolith
+	for _, tt := range []struct {
+		time               uint64
+		active, activation bool
+	}{
+		{1008, false, false},
+		{1010, true, true},
+		{1012, true, false},
+	} {
+		if cfg.IsRegolith(tt.time) != tt.active || cfg.IsRegolithActivationBlock(tt.time) != tt.activation {
+			t.Errorf("time %d: wrong activation", tt.time)
+		}
+	}
+	// Upgrades active at genesis have no activation block.
+	regolith = cfg.Genesis.L2Time
+	if cfg.IsRegolithActivationBlock(cfg.Genesis.L2Time) || cfg.IsRegolithActivationBlock(cfg.Genesis.L2Time+cfg.BlockTime) {
+		t.Error("activation block of an upgrade active at genesis")
+	}
+}
+
+func TestConfigCheckChainConfig(t *testing.T) {
+	cfg := testConfig()
+	regolith, other := uint64(1010), uint64(1012)
+	chainCfg := func(chainID int64, regolith *uint64) *params.ChainConfig {
+		return &params.ChainConfig{ChainID: big.NewInt(chainID), Optimism: &params.OptimismConfig{RegolithTime: regolith}}
+	}
+	if err := cfg.CheckChainConfig(chainCfg(cfg.L2ChainID.Int64(), nil)); err != nil {
+		t.Fatalf("matching chain config rejected: %v", err)
+	}
+	cfg.RegolithTime = &regolith
+	for name, chain := range map[string]*params.ChainConfig{
+		"not a rollup":   {ChainID: cfg.L2ChainID},
+		"other chain ID": chainCfg(cfg.L2ChainID.Int64()+1, &regolith),
+		"no Regolith":    chainCfg(cfg.L2ChainID.Int64(), nil),
+		"other Regolith": chainCfg(cfg.L2ChainID.Int64(), &other),
+	} {
+		if err := cfg.CheckChainConfig(chain); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	same := regolith
+	if err := cfg.CheckChainConfig(chainCfg(cfg.L2ChainID.Int64(), &same)); err != nil {
+		t.Fatalf("matching chain config rejected: %v", err)
+	}
+}
+
+func TestConfigInteropSourceHash(t *testing.T) {
+	cfg := testConfig()
+	source := common.HexToHash("0x01")
+	if cfg.DepositSourceHash(2000, source) != source {
+		t.Fatal("source hash bound before Interop is scheduled")
+	}
+	interop := uint64(1012)
+	cfg.InteropTime = &interop
+	if cfg.DepositSourceHash(1000, source) != source {
+		t.Error("source hash bound before Interop")
+	}
+	bound := cfg.DepositSourceHash(1012, source)
+	if bound != types.ChainDepositSourceHash(cfg.L2ChainID, source) {
+		t.Error("source hash not bound to the L2 chain ID after Interop")
+	}
+	other := testConfig()
+	other.L2ChainID = big.NewInt(902)
+	other.InteropTime = &interop
+	if other.DepositSourceHash(1012, source) == bound {
+		t.Error("chains share a bound source hash")
+	}
+}
+
+func TestCheckChainIDs(t *testing.T) {
+	a, b := testConfig(), testConfig()
+	b.L2ChainID = new(big.Int).Add(a.L2ChainID, common.Big1)
+	if err := CheckChainIDs(a, b); err != nil {
+		t.Fatalf("distinct chain IDs rejected: %v", err)
+	}
+	b.L2ChainID = new(big.Int).Set(a.L2ChainID)
+	if err := CheckChainIDs(a, b); err == nil {
+		t.Error("shared L2 chain ID accepted")
+	}
+	b.L1ChainID, b.L2ChainID = new(big.Int).Set(a.L2ChainID), big.NewInt(1000)
+	if err := CheckChainIDs(a, b); err == nil {
+		t.Error("L2 chain ID of an L1 chain accepted")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// L1ToL2AliasOffset is added to the address of an L1 contract that makes a
+// deposit, to get the sender of the deposit on L2. Without it, an L1 contract
+// could act as the L2 account at the same address, which may be controlled by
+// someone else. Accounts that deposit directly are not aliased, as they hold the
+// key of their L2 account too.
+var L1ToL2AliasOffset = common.HexToAddress("0x1111000000000000000000000000000000001111")
+
+var (
+	aliasOffset  = new(big.Int).SetBytes(L1ToL2AliasOffset[:])
+	aliasModulus = new(big.Int).Lsh(common.Big1, 8*common.AddressLength)
+)
+
+// ApplyL1ToL2Alias returns the L2 sender of the deposits made by the L1 contract
+// at the given address. The offset is added modulo 2^160.
+func ApplyL1ToL2Alias(addr common.Address) common.Address {
+	sum := new(big.Int).Add(new(big.Int).SetBytes(addr[:]), aliasOffset)
+	return common.BigToAddress(sum.Mod(sum, aliasModulus))
+}
+
+// UndoL1ToL2Alias returns the L1 contract that made the deposits with the given
+// aliased L2 sender. It is the inverse of ApplyL1ToL2Alias.
+func UndoL1ToL2Alias(addr common.Address) common.Address {
+	diff := new(big.Int).Sub(new(big.Int).SetBytes(addr[:]), aliasOffset)
+	return common.BigToAddress(diff.Mod(diff, aliasModulus))
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestL1ToL2Alias(t *testing.T) {
+	tests := []struct {
+		l1, l2 common.Address
+	}{
+		{common.Address{}, L1ToL2AliasOffset},
+		{
+			common.HexToAddress("0x1234000000000000000000000000000000005678"),
+			common.HexToAddress("0x2345000000000000000000000000000000006789"),
+		},
+		// The offset wraps around at 2^160.
+		{
+			common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"),
+			common.HexToAddress("0x1111000000000000000000000000000000001110"),
+		},
+		{
+			common.HexToAddress("0xeeff000000000000000000000000000000000000"),
+			common.HexToAddress("0x0010000000000000000000000000000000001111"),
+		},
+	}
+	for _, test := range tests {
+		if have := ApplyL1ToL2Alias(test.l1); have != test.l2 {
+			t.Errorf("alias of %s: have %s, want %s", test.l1, have, test.l2)
+		}
+		if have := UndoL1ToL2Alias(test.l2); have != test.l1 {
+			t.Errorf("unalias of %s: have %s, want %s", test.l2, have, test.l1)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// SequencerFeeVaultAddr is the predeploy that receives the priority fees of the
+// L2 blocks, unless the rollup configuration names another fee recipient.
+var SequencerFeeVaultAddr = common.HexToAddress("0x4200000000000000000000000000000000000011")
+
+// EpochDeposits returns the deposits of the given L1 origin that the L2 block
+// with the given sequence number includes: the user deposits, followed by the
+// deposits that apply the fee updates of the system config. Only the first
+// block of an epoch includes the deposits of its L1 origin, unless the rollup
+// limits the deposits per block. Then they are spread over the first blocks of
+// the epoch by SplitDeposits.
+func EpochDeposits(ctx context.Context, cfg *rollup.Config, l1 L1Fetcher, l1Origin *types.Header, seqNumber uint64) ([]*types.DepositTx, error) {
+	if seqNumber > 0 && !cfg.LimitsDeposits() {
+		return nil, nil
+	}
+	deposits, err := allEpochDeposits(ctx, cfg, l1, l1Origin.Hash(), l1Origin.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	blocks := SplitDeposits(cfg, deposits)
+	if seqNumber >= uint64(len(blocks)) {
+		return nil, nil
+	}
+	return blocks[seqNumber], nil
+}
+
+// PendingDeposits returns whether deposits of the epoch of the given L2 block
+// remain to be included in the blocks after it. The next block must not move
+// on to the next epoch until they are included, even if that makes it drift
+// further than the maximum sequencer drift from its L1 origin.
+func PendingDeposits(ctx context.Context, cfg *rollup.Config, l1 L1Fetcher, head rollup.L2BlockRef) (bool, error) {
+	// The genesis block does not include the deposits of its L1 origin.
+	if !cfg.LimitsDeposits() || head.Hash == cfg.Genesis.L2.Hash {
+		return false, nil
+	}
+	deposits, err := allEpochDeposits(ctx, cfg, l1, head.L1Origin.Hash, head.L1Origin.Number)
+	if err != nil {
+		return false, err
+	}
+	return uint64(len(SplitDeposits(cfg, deposits))) > head.SequenceNumber+1, nil
+}
+
+// allEpochDeposits returns all deposits of the given L1 block, in the order
+// they are included in the L2 chain.
+func allEpochDeposits(ctx context.Context, cfg *rollup.Config, l1 L1Fetcher, hash common.Hash, number uint64) ([]*types.DepositTx, error) {
+	block, err := l1.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 block %d: %w", number, err)
+	}
+	receipts, err := l1.Receipts(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipts of L1 block %d: %w", number, err)
+	}
+	signer := types.LatestSignerForChainID(cfg.L1ChainID)
+	deposits, err := UserDeposits(signer, block.Transactions(), receipts, cfg.DepositContractAddress)
+	if err != nil {
+		return nil, err
+	}
+	deposits = append(deposits, SystemConfigDeposits(receipts, cfg.SystemConfigAddress)...)
+	return bindDeposits(cfg, block.Time(), deposits), nil
+}
+
+// bindDeposits returns copies of the deposits derived from the L1 block with
+// the given timestamp, with their source hashes bound to the L2 chain once the
+// rollup does. The given deposits are left unchanged, since some of them, like
+// the upgrade deposits, may be shared.
+func bindDeposits(cfg *rollup.Config, l1Time uint64, deposits []*types.DepositTx) []*types.DepositTx {
+	bound := make([]*types.DepositTx, len(deposits))
+	for i, dep := range deposits {
+		cpy := *dep
+		cpy.SourceHash = cfg.DepositSourceHash(l1Time, dep.SourceHash)
+		bound[i] = &cpy
+	}
+	return bound
+}
+
+// SplitDeposits splits the deposits of an epoch into the deposits of its
+// consecutive L2 blocks, keeping their order. Every block takes as many of the
+// remaining deposits as the limits of the rollup allow, and at least one, so
+// that an oversized deposit is included on its own rather than never. An epoch
+// without deposits has a single empty block of deposits.
+func SplitDeposits(cfg *rollup.Config, deposits []*types.DepositTx) [][]*types.DepositTx {
+	if !cfg.LimitsDeposits() || len(deposits) == 0 {
+		return [][]*types.DepositTx{deposits}
+	}
+	var (
+		blocks [][]*types.DepositTx
+		start  int
+		size   uint64
+	)
+	for i, dep := range deposits {
+		depSize := uint64(types.NewTx(dep).Size())
+		count := uint64(i - start)
+		full := (cfg.MaxDepositsPerBlock != 0 && count >= cfg.MaxDepositsPerBlock) ||
+			(cfg.MaxDepositBytesPerBlock != 0 && size+depSize > cfg.MaxDepositBytesPerBlock)
+		if full && count > 0 {
+			blocks = append(blocks, deposits[start:i])
+			start, size = i, 0
+		}
+		size += depSize
+	}
+	return append(blocks, deposits[start:])
+}
+
+// PreparePayloadAttributes builds the attributes of an L2 block with the given
+// L1 origin and timestamp, containing only the deposits of the block: the L1
+// info deposit, followed by the given user deposits and the deposits of the
+// network upgrades that activate in the block. The attributes allow the engine
+// to fill the rest of the block from its transaction pool. The gas limit of the
+// block is the one of the system config at the L1 origin, if it is set.
+func PreparePayloadAttributes(cfg *rollup.Config, sysCfg rollup.SystemConfig, l1Origin *types.Header, seqNumber uint64, timestamp uint64, deposits []*types.DepositTx) (*beacon.PayloadAttributesV1, error) {
+	if timestamp < l1Origin.Time {
+		return nil, fmt.Errorf("block timestamp %d before L1 origin timestamp %d", timestamp, l1Origin.Time)
+	}
+	deposit, err := types.NewL1InfoDeposit(l1Origin, seqNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create L1 info deposit: %w", err)
+	}
+	bound := bindDeposits(cfg, l1Origin.Time, append([]*types.DepositTx{deposit}, UpgradeDeposits(cfg, timestamp)...))
+	deposit, upgrades := bound[0], bound[1:]
+	l1Info, err := types.NewTx(deposit).MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode L1 info deposit: %w", err)
+	}
+	txs := make([][]byte, 0, 1+len(deposits)+len(upgrades))
+	txs = append(txs, l1Info)
+	for i, dep := range deposits {
+		enc, err := types.NewTx(dep).MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode user deposit %d: %w", i, err)
+		}
+		txs = append(txs, enc)
+	}
+	for i, dep := range upgrades {
+		enc, err := types.NewTx(dep).MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode upgrade deposit %d: %w", i, err)
+		}
+		txs = append(txs, enc)
+	}
+	attrs := &beacon.PayloadAttributesV1{
+		Timestamp:             timestamp,
+		Random:                l1Origin.MixDigest,
+		SuggestedFeeRecipient: cfg.FeeRecipientAddress,
+		Transactions:          txs,
+	}
+	if sysCfg.GasLimit != 0 {
+		gasLimit := sysCfg.GasLimit
+		attrs.GasLimit = &gasLimit
+	}
+	return attrs, nil
+}
+
+// PayloadAttributes builds the attributes of an L2 block from its L1 origin and
+// its batch. The deposits come first, followed by the sequenced transactions.
+// The transaction pool is never used for derived blocks.
+func PayloadAttributes(cfg *rollup.Config, sysCfg rollup.SystemConfig, l1Origin *types.Header, seqNumber uint64, deposits []*types.DepositTx, batch *BatchData) (*beacon.PayloadAttributesV1, error) {
+	attrs, err := PreparePayloadAttributes(cfg, sysCfg, l1Origin, seqNumber, batch.Timestamp, deposits)
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range batch.Transactions {
+		if len(tx) == 0 {
+			return nil, fmt.Errorf("batch transaction %d is empty", i)
+		}
+		if tx[0] == types.DepositTxType {
+			return nil, fmt.Errorf("batch transaction %d is a deposit", i)
+		}
+		attrs.Transactions = append(attrs.Transactions, tx)
+	}
+	attrs.NoTxPool = true
+	return attrs, nil
+}
+
+// sequenceNumber returns the sequence number of the L2 block after the given
+// parent in the given epoch: 0 for the first block of an epoch, counting up
+// within it.
+func sequenceNumber(l2Parent rollup.L2BlockRef, epoch uint64) uint64 {
+	if epoch == l2Parent.L1Origin.Number {
+		return l2Parent.SequenceNumber + 1
+	}
+	return 0
+}
+
+// AttributesForBlock computes the payload attributes of the L2 block after
+// l2Parent in the given epoch, as derived from the given batch. A nil batch
+// stands for a block without sequenced transactions at the next block time.
+// Verifiers recompute the attributes of the unsafe payloads of the sequencer
+// with it, to check them with AttributesMatch.
+func AttributesForBlock(ctx context.Context, cfg *rollup.Config, l1 L1Fetcher, sysCfg rollup.SystemConfig, l2Parent rollup.L2BlockRef, epoch *types.Header, batch *BatchData) (*beacon.PayloadAttributesV1, error) {
+	seqNumber := sequenceNumber(l2Parent, epoch.Number.Uint64())
+	deposits, err := EpochDeposits(ctx, cfg, l1, epoch, seqNumber)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		batch = &BatchData{
+			ParentHash: l2Parent.Hash,
+			EpochNum:   epoch.Number.Uint64(),
+			EpochHash:  epoch.Hash(),
+			Timestamp:  l2Parent.Time + cfg.BlockTime,
+		}
+	}
+	return PayloadAttributes(cfg, sysCfg, epoch, seqNumber, deposits, batch)
+}
+
+// AttributesMatch checks whether the given payload is the block that the
+// engine builds on top of the given parent from the given attributes. The
+// fields that the engine computes while building, like the state root, are
+// not compared: a payload whose attributes match was executed by the engine
+// when it was imported, and would be built the same way again.
+func AttributesMatch(parent common.Hash, attrs *beacon.PayloadAttributesV1, payload *beacon.ExecutableDataV1) error {
+	switch {
+	case payload.ParentHash != parent:
+		return fmt.Errorf("parent %s, want %s", payload.ParentHash, parent)
+	case payload.Timestamp != attrs.Timestamp:
+		return fmt.Errorf("timestamp %d, want %d", payload.Timestamp, attrs.Timestamp)
+	case payload.Random != attrs.Random:
+		return fmt.Errorf("prevRandao %s, want %s", payload.Random, attrs.Random)
+	case payload.FeeRecipient != attrs.SuggestedFeeRecipient:
+		return fmt.Errorf("fee recipient %s, want %s", payload.FeeRecipient, attrs.SuggestedFeeRecipient)
+	case attrs.GasLimit != nil && payload.GasLimit != *attrs.GasLimit:
+		return fmt.Errorf("gas limit %d, want %d", payload.GasLimit, *attrs.GasLimit)
+	case len(payload.Transactions) != len(attrs.Transactions):
+		return fmt.Errorf("%d transactions, want %d", len(payload.Transactions), len(attrs.Transactions))
+	}
+	for i, tx := range payload.Transactions {
+		if !bytes.Equal(tx, attrs.Transactions[i]) {
+			return fmt.Errorf("transaction %d differs", i)
+		}
+	}
+	return nil
+}
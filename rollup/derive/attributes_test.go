@@ -0,0 +1,177 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+func TestSplitDeposits(t *testing.T) {
+	deposit := func(dataLen int) *types.DepositTx {
+		return &types.DepositTx{Gas: 21000, Data: make([]byte, dataLen)}
+	}
+	size := func(dep *types.DepositTx) uint64 {
+		return uint64(types.NewTx(dep).Size())
+	}
+	small, large := deposit(0), deposit(1000)
+	tests := []struct {
+		name     string
+		cfg      rollup.Config
+		deposits []*types.DepositTx
+		want     []int // number of deposits per block
+	}{
+		{"no limit", rollup.Config{}, []*types.DepositTx{small, small, small}, []int{3}},
+		{"no deposits", rollup.Config{MaxDepositsPerBlock: 2}, nil, []int{0}},
+		{"count", rollup.Config{MaxDepositsPerBlock: 2}, []*types.DepositTx{small, small, small, small, small}, []int{2, 2, 1}},
+		{"bytes", rollup.Config{MaxDepositBytesPerBlock: 2*size(small) + 1}, []*types.DepositTx{small, small, small}, []int{2, 1}},
+		{"oversized", rollup.Config{MaxDepositBytesPerBlock: 2 * size(small)}, []*types.DepositTx{small, large, small, small}, []int{1, 1, 2}},
+		{"both", rollup.Config{MaxDepositsPerBlock: 3, MaxDepositBytesPerBlock: size(large) + size(small)}, []*types.DepositTx{large, small, small, small, small, small}, []int{2, 3, 1}},
+	}
+	for _, tt := range tests {
+		blocks := SplitDeposits(&tt.cfg, tt.deposits)
+		if len(blocks) != len(tt.want) {
+			t.Errorf("%s: split into %d blocks, want %d", tt.name, len(blocks), len(tt.want))
+			continue
+		}
+		next := 0
+		for i, block := range blocks {
+			if len(block) != tt.want[i] {
+				t.Errorf("%s: block %d has %d deposits, want %d", tt.name, i, len(block), tt.want[i])
+			}
+			for _, dep := range block {
+				if dep != tt.deposits[next] {
+					t.Errorf("%s: deposit %d out of order", tt.name, next)
+				}
+				next++
+			}
+		}
+	}
+}
+
+func TestAttributesMatch(t *testing.T) {
+	var (
+		parent   = common.HexToHash("0x01")
+		gasLimit = uint64(30_000_000)
+		attrs    = &beacon.PayloadAttributesV1{
+			Timestamp:             1002,
+			Random:                common.HexToHash("0x02"),
+			SuggestedFeeRecipient: SequencerFeeVaultAddr,
+			Transactions:          [][]byte{{0x7e, 0x01}, {0x02, 0x03}},
+			GasLimit:              &gasLimit,
+		}
+	)
+	payload := func(modify func(p *beacon.ExecutableDataV1)) *beacon.ExecutableDataV1 {
+		p := &beacon.ExecutableDataV1{
+			ParentHash:   parent,
+			FeeRecipient: attrs.SuggestedFeeRecipient,
+			StateRoot:    common.HexToHash("0x03"),
+			Random:       attrs.Random,
+			GasLimit:     gasLimit,
+			Timestamp:    attrs.Timestamp,
+			BlockHash:    common.HexToHash("0x04"),
+			Transactions: [][]byte{{0x7e, 0x01}, {0x02, 0x03}},
+		}
+		if modify != nil {
+			modify(p)
+		}
+		return p
+	}
+	if err := AttributesMatch(parent, attrs, payload(nil)); err != nil {
+		t.Fatalf("matching payload rejected: %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(p *beacon.ExecutableDataV1)
+	}{
+		{"parent", func(p *beacon.ExecutableDataV1) { p.ParentHash = common.Hash{} }},
+		{"timestamp", func(p *beacon.ExecutableDataV1) { p.Timestamp++ }},
+		{"random", func(p *beacon.ExecutableDataV1) { p.Random = common.Hash{} }},
+		{"fee recipient", func(p *beacon.ExecutableDataV1) { p.FeeRecipient = common.Address{} }},
+		{"gas limit", func(p *beacon.ExecutableDataV1) { p.GasLimit-- }},
+		{"missing tx", func(p *beacon.ExecutableDataV1) { p.Transactions = p.Transactions[:1] }},
+		{"extra tx", func(p *beacon.ExecutableDataV1) { p.Transactions = append(p.Transactions, []byte{0x04}) }},
+		{"different tx", func(p *beacon.ExecutableDataV1) { p.Transactions[1] = []byte{0x02, 0x04} }},
+	}
+	for _, tt := range tests {
+		if err := AttributesMatch(parent, attrs, payload(tt.modify)); err == nil {
+			t.Errorf("%s: mismatching payload accepted", tt.name)
+		}
+	}
+	// Without a gas limit in the attributes, the engine picks it.
+	noLimit := *attrs
+	noLimit.GasLimit = nil
+	if err := AttributesMatch(parent, &noLimit, payload(func(p *beacon.ExecutableDataV1) { p.GasLimit-- })); err != nil {
+		t.Fatalf("payload rejected for gas limit not in the attributes: %v", err)
+	}
+}
+
+func TestDepositsBoundToChainID(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.DepositContractAddress = testDepositContract
+	to := common.HexToAddress("0x1234")
+	dep := &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Value: new(big.Int), Gas: 50_000, Data: []byte{}}
+	genesis := s.l1.Head()
+	epoch1 := s.l1.AddBlockWithLogs([]*types.Transaction{s.dataTx(t, nil)}, [][]*types.Log{{MarshalDepositLogEvent(testDepositContract, dep)}})
+	interop := epoch1.Time()
+	s.cfg.InteropTime = &interop
+
+	deposits, err := EpochDeposits(context.Background(), s.cfg, s.l1, epoch1.Header(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.ChainDepositSourceHash(s.cfg.L2ChainID, types.UserDepositSourceHash(epoch1.Hash(), 0))
+	if len(deposits) != 1 || deposits[0].SourceHash != want {
+		t.Fatalf("user deposit not bound to the L2 chain ID: %+v", deposits)
+	}
+	// The L1 info deposit is bound from the L1 origin at the Interop time on.
+	for _, tt := range []struct {
+		origin *types.Block
+		want   common.Hash
+	}{
+		{genesis, types.L1InfoDepositSourceHash(genesis.Hash(), 1)},
+		{epoch1, types.ChainDepositSourceHash(s.cfg.L2ChainID, types.L1InfoDepositSourceHash(epoch1.Hash(), 1))},
+	} {
+		attrs, err := PreparePayloadAttributes(s.cfg, rollup.SystemConfig{}, tt.origin.Header(), 1, tt.origin.Time(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var info types.Transaction
+		if err := info.UnmarshalBinary(attrs.Transactions[0]); err != nil {
+			t.Fatal(err)
+		}
+		if info.SourceHash() != tt.want {
+			t.Errorf("L1 origin %d: L1 info source hash %s, want %s", tt.origin.NumberU64(), info.SourceHash(), tt.want)
+		}
+	}
+	// Another chain derives different deposits from the same L1 block.
+	other := *s.cfg
+	other.L2ChainID = big.NewInt(902)
+	otherDeposits, err := EpochDeposits(context.Background(), &other, s.l1, epoch1.Header(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types.NewTx(otherDeposits[0]).Hash() == types.NewTx(deposits[0]).Hash() {
+		t.Fatal("chains share a user deposit")
+	}
+}
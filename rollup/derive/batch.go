@@ -0,0 +1,190 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// DerivationVersion0 is the version byte that prefixes the batch data posted to
+// the batch inbox.
+const DerivationVersion0 = 0
+
+// Batch types, which are the first byte of every encoded batch.
+const (
+	// SingularBatchType is the type of batches that describe a single L2 block.
+	SingularBatchType = 0
+)
+
+var (
+	errEmptyBatchData     = errors.New("empty batch data")
+	errUnknownDataVersion = errors.New("unknown batch data version")
+	errEmptyBatch         = errors.New("empty batch")
+	errUnknownBatchType   = errors.New("unknown batch type")
+)
+
+// Batch is a batch of the batch inbox data. Every batch is encoded as an RLP
+// string that holds the batch type, followed by the type specific encoding of
+// the batch. This leaves room for more compact batch types, such as batches that
+// span many L2 blocks, without breaking the decoding of existing data.
+type Batch interface {
+	// BatchType returns the type of the batch.
+	BatchType() byte
+
+	encodeBatch(w io.Writer) error
+}
+
+// BatchData is the L1 representation of an L2 block: everything that is needed
+// to reproduce the block, except for the deposits, which are read from L1.
+// It is encoded as a singular batch.
+type BatchData struct {
+	ParentHash   common.Hash     // hash of the parent L2 block
+	EpochNum     uint64          // number of the L1 origin
+	EpochHash    common.Hash     // hash of the L1 origin
+	Timestamp    uint64          // timestamp of the L2 block
+	Transactions []hexutil.Bytes // binary encoded sequenced transactions
+}
+
+// BatchType implements Batch.
+func (b *BatchData) BatchType() byte { return SingularBatchType }
+
+func (b *BatchData) encodeBatch(w io.Writer) error {
+	return rlp.Encode(w, b)
+}
+
+// EncodeBatch returns the encoding of a batch, as it appears in batch inbox data
+// and channels.
+func EncodeBatch(b Batch) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(b.BatchType())
+	if err := b.encodeBatch(&buf); err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(buf.Bytes())
+}
+
+// writeBatch writes the encoding of a batch to w.
+func writeBatch(w io.Writer, b Batch) error {
+	enc, err := EncodeBatch(b)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(enc)
+	return err
+}
+
+// EncodeBatches encodes the given batches into batch inbox data.
+func EncodeBatches(batches []*BatchData) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(DerivationVersion0)
+	for i, b := range batches {
+		if err := writeBatch(&buf, b); err != nil {
+			return nil, fmt.Errorf("failed to encode batch %d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeBatches decodes batch inbox data into the batches it contains.
+func DecodeBatches(data []byte) ([]*BatchData, error) {
+	if len(data) == 0 {
+		return nil, errEmptyBatchData
+	}
+	if data[0] != DerivationVersion0 {
+		return nil, fmt.Errorf("%w: %d", errUnknownDataVersion, data[0])
+	}
+	return decodeBatchStream(data[1:])
+}
+
+// decodeBatchStream decodes a sequence of encoded batches into the singular
+// batches of the L2 blocks they describe.
+func decodeBatchStream(data []byte) ([]*BatchData, error) {
+	var (
+		batches []*BatchData
+		stream  = rlp.NewStream(bytes.NewReader(data), uint64(len(data)))
+	)
+	for i := 0; ; i++ {
+		enc, err := stream.Bytes()
+		if err == io.EOF {
+			return batches, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode batch %d: %w", i, err)
+		}
+		decoded, err := decodeBatch(enc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode batch %d: %w", i, err)
+		}
+		batches = append(batches, decoded...)
+	}
+}
+
+// decodeBatch decodes the type and content of a single batch.
+func decodeBatch(enc []byte) ([]*BatchData, error) {
+	if len(enc) == 0 {
+		return nil, errEmptyBatch
+	}
+	switch enc[0] {
+	case SingularBatchType:
+		var b BatchData
+		if err := rlp.DecodeBytes(enc[1:], &b); err != nil {
+			return nil, err
+		}
+		return []*BatchData{&b}, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", errUnknownBatchType, enc[0])
+	}
+}
+
+// BlockToBatch creates the batch of an L2 block, which is what the batch sender
+// posts to L1 so that other nodes can derive the block. The L1 origin of the
+// block is read from its L1 info deposit. Deposits are left out of the batch,
+// since they are derived from L1 directly.
+func BlockToBatch(block *types.Block) (*BatchData, error) {
+	txs := block.Transactions()
+	if len(txs) == 0 || txs[0].Type() != types.DepositTxType {
+		return nil, fmt.Errorf("block %d has no L1 info deposit", block.NumberU64())
+	}
+	var info types.L1BlockInfo
+	if err := info.UnmarshalBinary(txs[0].Data()); err != nil {
+		return nil, fmt.Errorf("block %d: %w", block.NumberU64(), err)
+	}
+	batch := &BatchData{
+		ParentHash: block.ParentHash(),
+		EpochNum:   info.Number,
+		EpochHash:  info.BlockHash,
+		Timestamp:  block.Time(),
+	}
+	for i, tx := range txs {
+		if tx.Type() == types.DepositTxType {
+			continue
+		}
+		enc, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode transaction %d of block %d: %w", i, block.NumberU64(), err)
+		}
+		batch.Transactions = append(batch.Transactions, enc)
+	}
+	return batch, nil
+}
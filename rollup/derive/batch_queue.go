@@ -0,0 +1,283 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// maxQueuedBatches is the maximum number of batches for later blocks that the
+// batch queue holds. Beyond that, the batches for the latest blocks are dropped,
+// they are read again from L1 after a reset if they are needed.
+const maxQueuedBatches = 4096
+
+// batchValidity is the verdict on a queued batch, for the block after the L2
+// head.
+type batchValidity int
+
+const (
+	batchDrop   batchValidity = iota // the batch can never be derived
+	batchAccept                      // the batch derives the next block
+	batchFuture                      // the batch is for a later block
+)
+
+// queuedBatch is a batch that was read, along with the L1 block it was included
+// in.
+type queuedBatch struct {
+	*BatchData
+	l1Block uint64
+}
+
+// BatchQueue holds the batches that were read from L1 until the blocks they
+// describe can be derived. It validates them against the L2 head and drops the
+// ones that cannot be derived, logging why. Batches are only pulled from the
+// previous stage while none of the queued ones derives the next block, and at
+// most maxQueuedBatches are held.
+//
+// When the sequencing window of an epoch passes without a valid batch for the
+// next block, the queue fills the epoch with empty batches instead.
+//
+// With strict batch ordering, batches must be read in the order of their
+// blocks: batches for later blocks are dropped instead of queued.
+type BatchQueue struct {
+	cfg  *rollup.Config
+	l1   L1Fetcher
+	prev batchProvider
+	log  log.Logger
+
+	batches []*queuedBatch // in the order they were read
+}
+
+// NewBatchQueue creates an empty batch queue that pulls the batches of the
+// given stage.
+func NewBatchQueue(cfg *rollup.Config, l1 L1Fetcher, prev batchProvider, logger log.Logger) *BatchQueue {
+	return &BatchQueue{cfg: cfg, l1: l1, prev: prev, log: logger}
+}
+
+// add queues a batch that was read from the given L1 block. If the queue is
+// full, the batch for the latest block is dropped.
+func (q *BatchQueue) add(batch *BatchData, l1Block uint64) {
+	q.batches = append(q.batches, &queuedBatch{BatchData: batch, l1Block: l1Block})
+	if len(q.batches) <= maxQueuedBatches {
+		return
+	}
+	latest := 0
+	for i, b := range q.batches {
+		if b.Timestamp > q.batches[latest].Timestamp {
+			latest = i
+		}
+	}
+	b := q.batches[latest]
+	q.log.Warn("Batch queue full, dropping batch", "timestamp", b.Timestamp, "epoch", b.EpochNum, "l1block", b.l1Block)
+	droppedBatchMeter.Mark(1)
+	q.batches = append(q.batches[:latest], q.batches[latest+1:]...)
+}
+
+// Len returns the number of queued batches.
+func (q *BatchQueue) Len() int {
+	return len(q.batches)
+}
+
+// Reset drops all queued batches.
+func (q *BatchQueue) Reset() {
+	q.batches = nil
+}
+
+// NextBatch returns the batch of the block after the given L2 head, along with
+// the header of its L1 origin. l1Head is the last L1 block that was traversed.
+// It pulls batches from the previous stage until one derives the block, and
+// returns io.EOF if the data of the next L1 block is needed first.
+//
+// The accepted batch stays queued until a block after it is derived, so that it
+// is not lost if deriving it fails.
+func (q *BatchQueue) NextBatch(ctx context.Context, head rollup.L2BlockRef, l1Head uint64) (*BatchData, *types.Header, error) {
+	for {
+		batch, origin, err := q.next(ctx, head)
+		if err != nil || batch != nil {
+			return batch, origin, err
+		}
+		b, l1Block, err := q.prev.NextBatch(ctx)
+		if errors.Is(err, io.EOF) {
+			// All batches of the traversed L1 blocks were read.
+			batch, origin, err := q.emptyBatch(ctx, head, l1Head)
+			if err == nil && batch == nil {
+				err = io.EOF
+			}
+			return batch, origin, err
+		} else if err != nil {
+			return nil, nil, err
+		}
+		q.add(b, l1Block)
+	}
+}
+
+// next returns the queued batch of the block after the given L2 head, along
+// with the header of its L1 origin, or nil if none of the queued batches
+// derives it.
+func (q *BatchQueue) next(ctx context.Context, head rollup.L2BlockRef) (*BatchData, *types.Header, error) {
+	var (
+		found  *BatchData
+		origin *types.Header
+		keep   = q.batches[:0]
+	)
+	for i, b := range q.batches {
+		if found != nil {
+			keep = append(keep, b)
+			continue
+		}
+		verdict, epoch, err := q.check(ctx, head, b)
+		if err != nil {
+			// Keep the unchecked batches for the next attempt.
+			q.batches = append(keep, q.batches[i:]...)
+			return nil, nil, err
+		}
+		switch verdict {
+		case batchAccept:
+			found, origin = b.BatchData, epoch
+			keep = append(keep, b)
+		case batchFuture:
+			keep = append(keep, b)
+		}
+	}
+	q.batches = keep
+	return found, origin, nil
+}
+
+// Drop removes a batch that turned out to be invalid when it was derived.
+func (q *BatchQueue) Drop(batch *BatchData, reason string) {
+	q.log.Warn("Dropping invalid batch", "timestamp", batch.Timestamp, "epoch", batch.EpochNum, "reason", reason)
+	droppedBatchMeter.Mark(1)
+	for i, b := range q.batches {
+		if b.BatchData == batch {
+			q.batches = append(q.batches[:i], q.batches[i+1:]...)
+			return
+		}
+	}
+}
+
+// check validates a batch against the L2 head. Accepted batches are returned
+// with the header of their L1 origin.
+func (q *BatchQueue) check(ctx context.Context, head rollup.L2BlockRef, b *queuedBatch) (batchValidity, *types.Header, error) {
+	var (
+		next   = head.Time + q.cfg.BlockTime
+		origin = head.L1Origin
+	)
+	drop := func(reason string, ctx ...interface{}) (batchValidity, *types.Header, error) {
+		ctx = append([]interface{}{"timestamp", b.Timestamp, "epoch", b.EpochNum, "l1block", b.l1Block, "reason", reason}, ctx...)
+		q.log.Warn("Dropping invalid batch", ctx...)
+		droppedBatchMeter.Mark(1)
+		return batchDrop, nil, nil
+	}
+	switch {
+	case b.Timestamp < next:
+		// Stale batch, the block was derived already.
+		q.log.Debug("Dropping stale batch", "timestamp", b.Timestamp, "next", next)
+		return batchDrop, nil, nil
+	case b.l1Block >= b.EpochNum+q.cfg.SeqWindowSize:
+		return drop("included after the sequencing window")
+	case b.EpochNum > b.l1Block:
+		return drop("epoch after the inclusion block")
+	case b.Timestamp > next && q.cfg.StrictBatchOrdering:
+		return drop("batch for a later block", "next", next)
+	case b.Timestamp > next:
+		return batchFuture, nil, nil
+	case b.ParentHash != head.Hash:
+		return drop("parent mismatch", "parent", b.ParentHash, "head", head.Hash)
+	case b.EpochNum < origin.Number:
+		return drop("epoch before the L1 origin of the parent", "origin", origin.Number)
+	case b.EpochNum > origin.Number+1:
+		return drop("epoch skips L1 blocks", "origin", origin.Number)
+	}
+	epoch, err := q.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(b.EpochNum))
+	if errors.Is(err, ethereum.NotFound) {
+		return drop("unknown epoch")
+	} else if err != nil {
+		return batchDrop, nil, fmt.Errorf("failed to fetch L1 origin %d: %w", b.EpochNum, err)
+	}
+	pending, err := PendingDeposits(ctx, q.cfg, q.l1, head)
+	if err != nil {
+		return batchDrop, nil, err
+	}
+	switch {
+	case epoch.Hash() != b.EpochHash:
+		return drop("epoch hash mismatch", "canonical", epoch.Hash())
+	case b.EpochNum == origin.Number+1 && epoch.ParentHash != origin.Hash:
+		return drop("epoch does not extend the L1 origin of the parent")
+	case b.EpochNum == origin.Number+1 && pending:
+		return drop("epoch leaves deposits of the L1 origin of the parent pending", "origin", origin.Number)
+	case b.Timestamp < epoch.Time:
+		return drop("timestamp before epoch", "epochtime", epoch.Time)
+	case b.Timestamp > epoch.Time+q.cfg.MaxSequencerDrift && !pending && (b.EpochNum == origin.Number || !q.cfg.LimitsDeposits()):
+		// With limited deposits, blocks that move on to the next epoch may
+		// exceed the drift, so that the chain catches up after staying in an
+		// epoch for its deposits.
+		return drop("timestamp exceeds the sequencer drift", "epochtime", epoch.Time)
+	}
+	return batchAccept, epoch, nil
+}
+
+// emptyBatch returns an empty batch for the block after the head, once an L1
+// block after the sequencing window of its epoch was read without a valid batch
+// for it. The derived block only contains the deposits of its epoch. The block
+// moves to the next epoch as soon as its timestamp allows it and no deposits of
+// the current epoch are pending, like the sequencer does. It returns nil while
+// the window is still open.
+func (q *BatchQueue) emptyBatch(ctx context.Context, head rollup.L2BlockRef, l1Head uint64) (*BatchData, *types.Header, error) {
+	var (
+		origin    = head.L1Origin
+		timestamp = head.Time + q.cfg.BlockTime
+	)
+	if l1Head < origin.Number+q.cfg.SeqWindowSize {
+		return nil, nil, nil
+	}
+	epoch, err := q.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(origin.Number))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch L1 origin %d: %w", origin.Number, err)
+	}
+	next, err := q.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(origin.Number+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch L1 block %d: %w", origin.Number+1, err)
+	}
+	pending, err := PendingDeposits(ctx, q.cfg, q.l1, head)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timestamp >= next.Time && !pending {
+		if l1Head < next.Number.Uint64()+q.cfg.SeqWindowSize {
+			return nil, nil, nil
+		}
+		epoch = next
+	}
+	batch := &BatchData{
+		ParentHash: head.Hash,
+		EpochNum:   epoch.Number.Uint64(),
+		EpochHash:  epoch.Hash(),
+		Timestamp:  timestamp,
+	}
+	q.log.Warn("Sequencing window expired, deriving empty block", "timestamp", timestamp, "epoch", batch.EpochNum, "l1head", l1Head)
+	emptyBatchMeter.Mark(1)
+	return batch, epoch, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+func TestBatchQueueCheck(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.MaxSequencerDrift = 8
+	l1a, l1b := s.l1.AddBlock(), s.l1.AddBlock()
+	s.l1.AddBlock()
+	q := NewBatchQueue(s.cfg, s.l1, nil, log.New())
+
+	// The head is in epoch 1, the next block may move to epoch 2.
+	head := rollup.L2BlockRef{
+		Hash:     common.HexToHash("0xaa"),
+		Number:   10,
+		Time:     l1b.Time() - s.cfg.BlockTime,
+		L1Origin: rollup.BlockID{Hash: l1a.Hash(), Number: 1},
+	}
+	next := head.Time + s.cfg.BlockTime
+	tests := []struct {
+		name  string
+		batch BatchData
+		l1    uint64 // inclusion block
+		want  batchValidity
+	}{
+		{"same epoch", BatchData{ParentHash: head.Hash, EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: next}, 2, batchAccept},
+		{"next epoch", BatchData{ParentHash: head.Hash, EpochNum: 2, EpochHash: l1b.Hash(), Timestamp: next}, 2, batchAccept},
+		{"future", BatchData{ParentHash: common.HexToHash("0xbb"), EpochNum: 1, Timestamp: next + s.cfg.BlockTime}, 2, batchFuture},
+		{"stale", BatchData{ParentHash: head.Hash, EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: head.Time}, 2, batchDrop},
+		{"parent mismatch", BatchData{ParentHash: common.HexToHash("0xbb"), EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: next}, 2, batchDrop},
+		{"epoch before origin", BatchData{ParentHash: head.Hash, EpochNum: 0, EpochHash: s.l1.Block(0).Hash(), Timestamp: next}, 2, batchDrop},
+		{"epoch skips blocks", BatchData{ParentHash: head.Hash, EpochNum: 3, EpochHash: s.l1.Block(3).Hash(), Timestamp: next}, 3, batchDrop},
+		{"epoch after inclusion", BatchData{ParentHash: head.Hash, EpochNum: 2, EpochHash: l1b.Hash(), Timestamp: next}, 1, batchDrop},
+		{"after window", BatchData{ParentHash: head.Hash, EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: next}, 1 + s.cfg.SeqWindowSize, batchDrop},
+		{"epoch hash mismatch", BatchData{ParentHash: head.Hash, EpochNum: 2, EpochHash: common.HexToHash("0xcc"), Timestamp: next}, 2, batchDrop},
+	}
+	for _, tt := range tests {
+		verdict, origin, err := q.check(context.Background(), head, &queuedBatch{BatchData: &tt.batch, l1Block: tt.l1})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if verdict != tt.want {
+			t.Errorf("%s: verdict %d, want %d", tt.name, verdict, tt.want)
+		}
+		if verdict == batchAccept && origin.Hash() != tt.batch.EpochHash {
+			t.Errorf("%s: wrong origin %s", tt.name, origin.Hash())
+		}
+	}
+
+	// Blocks may not drift too far ahead of their epoch, nor precede it.
+	drifted := head
+	drifted.Time = l1a.Time() + s.cfg.MaxSequencerDrift
+	batch := &queuedBatch{BatchData: &BatchData{ParentHash: head.Hash, EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: drifted.Time + s.cfg.BlockTime}, l1Block: 2}
+	if verdict, _, _ := q.check(context.Background(), drifted, batch); verdict != batchDrop {
+		t.Errorf("batch beyond the sequencer drift not dropped")
+	}
+	// With strict ordering, batches for later blocks are dropped.
+	s.cfg.StrictBatchOrdering = true
+	batch = &queuedBatch{BatchData: &tests[2].batch, l1Block: tests[2].l1}
+	if verdict, _, _ := q.check(context.Background(), head, batch); verdict != batchDrop {
+		t.Errorf("future batch not dropped with strict ordering")
+	}
+	s.cfg.StrictBatchOrdering = false
+
+	early := head
+	early.Time -= s.cfg.BlockTime
+	batch = &queuedBatch{BatchData: &BatchData{ParentHash: head.Hash, EpochNum: 2, EpochHash: l1b.Hash(), Timestamp: early.Time + s.cfg.BlockTime}, l1Block: 2}
+	if verdict, _, _ := q.check(context.Background(), early, batch); verdict != batchDrop {
+		t.Errorf("batch before its epoch not dropped")
+	}
+}
+
+// testBatchProvider hands out batches, all from the same L1 block.
+type testBatchProvider struct {
+	batches []*BatchData
+	l1Block uint64
+}
+
+func (p *testBatchProvider) NextBatch(ctx context.Context) (*BatchData, uint64, error) {
+	if len(p.batches) == 0 {
+		return nil, 0, io.EOF
+	}
+	b := p.batches[0]
+	p.batches = p.batches[1:]
+	return b, p.l1Block, nil
+}
+
+// Tests that the batch queue stops pulling batches once it has the one of the
+// next block, and holds a bounded number of batches for later blocks.
+func TestBatchQueueBackpressure(t *testing.T) {
+	s := newTestSetup()
+	l1a := s.l1.AddBlock()
+	head := rollup.L2BlockRef{
+		Hash:     common.HexToHash("0xaa"),
+		Number:   10,
+		Time:     l1a.Time(),
+		L1Origin: rollup.BlockID{Hash: l1a.Hash(), Number: 1},
+	}
+	next := head.Time + s.cfg.BlockTime
+	future := func(i int) *BatchData {
+		return &BatchData{ParentHash: common.HexToHash("0xbb"), EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: next + uint64(i+1)*s.cfg.BlockTime}
+	}
+	prev := &testBatchProvider{l1Block: 1}
+	for i := 0; i < maxQueuedBatches+1; i++ {
+		prev.batches = append(prev.batches, future(i))
+	}
+	want := &BatchData{ParentHash: head.Hash, EpochNum: 1, EpochHash: l1a.Hash(), Timestamp: next}
+	prev.batches = append(prev.batches, want, future(maxQueuedBatches+1))
+
+	q := NewBatchQueue(s.cfg, s.l1, prev, log.New())
+	batch, origin, err := q.NextBatch(context.Background(), head, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch != want || origin.Hash() != l1a.Hash() {
+		t.Fatalf("NextBatch = %+v, want the batch of the next block", batch)
+	}
+	if len(prev.batches) != 1 {
+		t.Fatalf("%d batches left to pull, want 1", len(prev.batches))
+	}
+	// The future batch for the latest block was dropped.
+	if n := q.Len(); n != maxQueuedBatches {
+		t.Fatalf("queue holds %d batches, want %d", n, maxQueuedBatches)
+	}
+	for _, b := range q.batches {
+		if b.Timestamp == future(maxQueuedBatches).Timestamp {
+			t.Fatal("batch for the latest block not dropped")
+		}
+	}
+	// Once the previous stage runs out of batches, more L1 data is needed.
+	q.Drop(want, "test")
+	if _, _, err := q.NextBatch(context.Background(), head, 1); err != io.EOF {
+		t.Fatalf("NextBatch without the batch of the next block = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestBatchesRoundTrip(t *testing.T) {
+	batches := []*BatchData{
+		{
+			ParentHash: common.HexToHash("0x01"),
+			EpochNum:   12,
+			EpochHash:  common.HexToHash("0x02"),
+			Timestamp:  1234,
+		},
+		{
+			ParentHash:   common.HexToHash("0x03"),
+			EpochNum:     13,
+			EpochHash:    common.HexToHash("0x04"),
+			Timestamp:    1236,
+			Transactions: []hexutil.Bytes{{0x02, 0xaa}, {0xf8, 0x01}},
+		},
+	}
+	data, err := EncodeBatches(batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != DerivationVersion0 {
+		t.Fatalf("wrong version byte %d", data[0])
+	}
+	// Every batch is an RLP string that starts with the batch type.
+	content, _, err := rlp.SplitString(data[1:])
+	if err != nil {
+		t.Fatalf("batch is not an RLP string: %v", err)
+	}
+	if content[0] != SingularBatchType {
+		t.Fatalf("wrong batch type %d", content[0])
+	}
+	decoded, err := DecodeBatches(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(batches) {
+		t.Fatalf("decoded %d batches, want %d", len(decoded), len(batches))
+	}
+	// The empty transaction list decodes as an empty slice.
+	decoded[0].Transactions = nil
+	if !reflect.DeepEqual(decoded, batches) {
+		t.Fatalf("batch mismatch: have %+v, want %+v", decoded, batches)
+	}
+}
+
+func TestDecodeBatchesInvalid(t *testing.T) {
+	valid, _ := EncodeBatches([]*BatchData{{Timestamp: 1}})
+	batch, _ := EncodeBatch(&BatchData{Timestamp: 1})
+	unknownType, _ := rlp.EncodeToBytes(append([]byte{0x01}, batch[2:]...))
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, errEmptyBatchData},
+		{"version", append([]byte{1}, valid[1:]...), errUnknownDataVersion},
+		{"truncated", valid[:len(valid)-1], nil},
+		{"trailing garbage", append(valid, 0xff), nil},
+		{"empty batch", []byte{DerivationVersion0, 0x80}, errEmptyBatch},
+		{"batch type", append([]byte{DerivationVersion0}, unknownType...), errUnknownBatchType},
+		{"unwrapped batch", append([]byte{DerivationVersion0}, batch[2:]...), nil},
+	}
+	for _, test := range tests {
+		_, err := DecodeBatches(test.data)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s: wrong error: have %v, want %v", test.name, err, test.err)
+		}
+	}
+}
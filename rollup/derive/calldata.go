@@ -0,0 +1,69 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// CalldataSource is the default data availability source, which reads the batch
+// data from the calldata of the batch inbox transactions.
+type CalldataSource struct {
+	cfg *rollup.Config
+	log log.Logger
+}
+
+// NewCalldataSource creates a data availability source that reads calldata.
+func NewCalldataSource(cfg *rollup.Config, logger log.Logger) *CalldataSource {
+	return &CalldataSource{cfg: cfg, log: logger}
+}
+
+// BatchData returns the calldata of the batch inbox transactions of the block.
+func (s *CalldataSource) BatchData(ctx context.Context, block *types.Block, batcher common.Address) ([][]byte, error) {
+	return DataFromL1Txs(s.cfg, block.Transactions(), batcher, s.log), nil
+}
+
+// DataFromL1Txs returns the calldata of the transactions that were sent to the
+// batch inbox by the given batcher. Transactions from any other account are
+// ignored, so that only the batcher can influence the L2 chain.
+func DataFromL1Txs(cfg *rollup.Config, txs types.Transactions, batcher common.Address, logger log.Logger) [][]byte {
+	var (
+		signer = types.LatestSignerForChainID(cfg.L1ChainID)
+		out    [][]byte
+	)
+	for i, tx := range txs {
+		if to := tx.To(); to == nil || *to != cfg.BatchInboxAddress {
+			continue
+		}
+		sender, err := types.Sender(signer, tx)
+		if err != nil {
+			logger.Warn("Ignoring batch inbox tx with invalid signature", "index", i, "hash", tx.Hash(), "err", err)
+			continue
+		}
+		if sender != batcher {
+			logger.Warn("Ignoring batch inbox tx from unauthorized sender", "index", i, "hash", tx.Hash(), "sender", sender)
+			continue
+		}
+		out = append(out, tx.Data())
+	}
+	return out
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// DerivationVersion1 is the version byte that prefixes batch inbox data that
+// consists of channel frames.
+//
+// A channel is a compressed stream of batches. The first byte of the channel
+// data identifies the compression algorithm, the rest is the compressed RLP
+// stream of batches. The channel data is split into frames, which can be posted
+// to L1 in separate transactions and in any order.
+const DerivationVersion1 = 1
+
+// frameOverhead is the encoded size of a frame without its data:
+//
+//	channel_id (16) ++ frame_number (uint16) ++ data_length (uint32) ++ data ++ is_last (1)
+const frameOverhead = 16 + 2 + 4 + 1
+
+// MaxChannelDataSize is the maximum size of the decompressed batch data of a
+// channel, to protect against decompression bombs.
+const MaxChannelDataSize = 10 * 1024 * 1024
+
+var (
+	errInvalidFrame          = errors.New("invalid frame")
+	errUnknownCompression    = errors.New("unknown compression algorithm")
+	errChannelDataTooLarge   = errors.New("channel data too large")
+	errFrameSizeTooSmall     = errors.New("maximum frame size too small")
+	errTooManyChannelFrames  = errors.New("too many channel frames")
+	errEmptyChannel          = errors.New("empty channel")
+	errDuplicateCompressorID = errors.New("duplicate compression algorithm")
+)
+
+// ChannelID identifies a channel. It is chosen randomly by the batch submitter.
+type ChannelID [16]byte
+
+// NewChannelID returns a random channel ID.
+func NewChannelID() (ChannelID, error) {
+	var id ChannelID
+	_, err := rand.Read(id[:])
+	return id, err
+}
+
+// String implements fmt.Stringer.
+func (id ChannelID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Frame is a chunk of the data of a channel.
+type Frame struct {
+	ID     ChannelID
+	Number uint16
+	Data   []byte
+	IsLast bool // last frame of the channel
+
+	legacy bool // whole batch data of derivation version 0, see FrameQueue
+}
+
+// size returns the encoded size of the frame.
+func (f *Frame) size() int {
+	return frameOverhead + len(f.Data)
+}
+
+// appendFrame appends the encoding of the frame to buf.
+func appendFrame(buf []byte, f *Frame) []byte {
+	var header [22]byte
+	copy(header[:16], f.ID[:])
+	binary.BigEndian.PutUint16(header[16:18], f.Number)
+	binary.BigEndian.PutUint32(header[18:22], uint32(len(f.Data)))
+	buf = append(buf, header[:]...)
+	buf = append(buf, f.Data...)
+	if f.IsLast {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+// EncodeFrames encodes the given frames into batch inbox data.
+func EncodeFrames(frames ...*Frame) []byte {
+	size := 1
+	for _, f := range frames {
+		size += f.size()
+	}
+	buf := make([]byte, 1, size)
+	buf[0] = DerivationVersion1
+	for _, f := range frames {
+		buf = appendFrame(buf, f)
+	}
+	return buf
+}
+
+// DecodeFrames decodes batch inbox data into the frames it contains.
+func DecodeFrames(data []byte) ([]*Frame, error) {
+	if len(data) == 0 {
+		return nil, errEmptyBatchData
+	}
+	if data[0] != DerivationVersion1 {
+		return nil, fmt.Errorf("%w: %d", errUnknownDataVersion, data[0])
+	}
+	var frames []*Frame
+	for rest := data[1:]; len(rest) > 0; {
+		if len(rest) < frameOverhead {
+			return nil, fmt.Errorf("%w: %d: truncated header", errInvalidFrame, len(frames))
+		}
+		f := new(Frame)
+		copy(f.ID[:], rest[:16])
+		f.Number = binary.BigEndian.Uint16(rest[16:18])
+		size := binary.BigEndian.Uint32(rest[18:22])
+		if uint64(len(rest)) < frameOverhead+uint64(size) {
+			return nil, fmt.Errorf("%w: %d: truncated data", errInvalidFrame, len(frames))
+		}
+		f.Data = rest[22 : 22+size]
+		switch rest[22+size] {
+		case 0:
+		case 1:
+			f.IsLast = true
+		default:
+			return nil, fmt.Errorf("%w: %d: invalid is_last flag %d", errInvalidFrame, len(frames), rest[22+size])
+		}
+		frames = append(frames, f)
+		rest = rest[frameOverhead+size:]
+	}
+	return frames, nil
+}
+
+// Compressor is a compression algorithm for channel data.
+type Compressor interface {
+	// ID is the byte that identifies the algorithm in the channel data.
+	ID() byte
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// CompressionZlib identifies channels compressed with zlib.
+const CompressionZlib = 0
+
+// Zlib compresses channel data with zlib.
+var Zlib Compressor = zlibCompressor{}
+
+type zlibCompressor struct{}
+
+func (zlibCompressor) ID() byte { return CompressionZlib }
+
+func (zlibCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, zlib.BestCompression)
+}
+
+func (zlibCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// compressors are the algorithms that channels can be decompressed with.
+var compressors = map[byte]Compressor{CompressionZlib: Zlib}
+
+// RegisterCompressor makes the given compression algorithm available to decode
+// channels. It must be called before any channel is decoded.
+func RegisterCompressor(c Compressor) error {
+	if _, ok := compressors[c.ID()]; ok {
+		return fmt.Errorf("%w: %d", errDuplicateCompressorID, c.ID())
+	}
+	compressors[c.ID()] = c
+	return nil
+}
+
+// EncodeChannel compresses the given batches into a new channel, and splits the
+// channel data into frames whose encoding is at most maxFrameSize bytes long.
+func EncodeChannel(c Compressor, batches []*BatchData, maxFrameSize int) ([]*Frame, error) {
+	if maxFrameSize <= frameOverhead {
+		return nil, fmt.Errorf("%w: %d", errFrameSizeTooSmall, maxFrameSize)
+	}
+	id, err := NewChannelID()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(c.ID())
+	w, err := c.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range batches {
+		if err := writeBatch(w, b); err != nil {
+			return nil, fmt.Errorf("failed to encode batch %d: %w", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var (
+		data   = buf.Bytes()
+		chunk  = maxFrameSize - frameOverhead
+		frames []*Frame
+	)
+	for len(frames) == 0 || len(data) > 0 {
+		if len(frames) > math.MaxUint16 {
+			return nil, fmt.Errorf("%w: channel of %d bytes", errTooManyChannelFrames, buf.Len())
+		}
+		n := len(data)
+		if n > chunk {
+			n = chunk
+		}
+		frames = append(frames, &Frame{ID: id, Number: uint16(len(frames)), Data: data[:n]})
+		data = data[n:]
+	}
+	frames[len(frames)-1].IsLast = true
+	return frames, nil
+}
+
+// DecodeChannel decompresses the data of a complete channel, which is the data
+// of its frames in order, and decodes the batches in it.
+func DecodeChannel(data []byte) ([]*BatchData, error) {
+	if len(data) == 0 {
+		return nil, errEmptyChannel
+	}
+	c, ok := compressors[data[0]]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", errUnknownCompression, data[0])
+	}
+	r, err := c.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	raw, err := io.ReadAll(io.LimitReader(r, MaxChannelDataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > MaxChannelDataSize {
+		return nil, errChannelDataTooLarge
+	}
+	return decodeBatchStream(raw)
+}
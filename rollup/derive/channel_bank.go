@@ -0,0 +1,280 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+const (
+	// DefaultChannelTimeout is the number of L1 blocks after its first frame
+	// within which a channel must be complete, unless the rollup configuration
+	// sets another one. Incomplete channels are dropped after that.
+	DefaultChannelTimeout = 50
+
+	// maxChannelBankSize is the maximum amount of frame data that is buffered
+	// for incomplete channels. The oldest channels are dropped beyond that.
+	maxChannelBankSize = 100 * 1024 * 1024
+
+	// bufferedFrameOverhead is the amount of memory accounted for every
+	// buffered frame on top of its data, so that empty frames are bounded too.
+	bufferedFrameOverhead = 200
+)
+
+// ChannelTimeout returns the channel timeout of the rollup configuration.
+func ChannelTimeout(cfg *rollup.Config) uint64 {
+	if cfg.ChannelTimeout == 0 {
+		return DefaultChannelTimeout
+	}
+	return cfg.ChannelTimeout
+}
+
+// pendingChannel is a channel that is not complete yet.
+type pendingChannel struct {
+	id     ChannelID
+	opened uint64 // L1 block of the first frame
+	frames map[uint16][]byte
+	last   int // number of the last frame, -1 if unknown
+	size   int
+}
+
+// ChannelBank reassembles channels from the frames read from L1. Frames may
+// arrive in any order and more than once; the batches of a channel are released
+// as soon as all of its frames were read.
+//
+// A strict channel bank only reassembles one channel at a time, from frames in
+// order. A frame that does not continue the pending channel is dropped, and the
+// first frame of a new channel drops the pending one.
+//
+// ChannelBank is not safe for concurrent use.
+type ChannelBank struct {
+	log     log.Logger
+	timeout uint64 // L1 blocks within which a channel must be complete
+	strict  bool   // whether frames must arrive in order
+
+	channels map[ChannelID]*pendingChannel
+	order    []*pendingChannel    // pending channels, oldest first
+	closed   map[ChannelID]uint64 // completed channels, to ignore late duplicates
+	size     int
+}
+
+// NewChannelBank creates an empty channel bank, which drops the channels that
+// are not complete within the given number of L1 blocks.
+func NewChannelBank(timeout uint64, logger log.Logger) *ChannelBank {
+	return &ChannelBank{
+		log:      logger,
+		timeout:  timeout,
+		channels: make(map[ChannelID]*pendingChannel),
+		closed:   make(map[ChannelID]uint64),
+	}
+}
+
+// NewStrictChannelBank creates an empty channel bank that requires the frames
+// in order, for rollups with strict batch ordering.
+func NewStrictChannelBank(timeout uint64, logger log.Logger) *ChannelBank {
+	cb := NewChannelBank(timeout, logger)
+	cb.strict = true
+	return cb
+}
+
+// newChannelBank creates the channel bank of the given rollup.
+func newChannelBank(cfg *rollup.Config, logger log.Logger) *ChannelBank {
+	if cfg.StrictBatchOrdering {
+		return NewStrictChannelBank(ChannelTimeout(cfg), logger)
+	}
+	return NewChannelBank(ChannelTimeout(cfg), logger)
+}
+
+// AddFrame adds a frame that was read from the given L1 block. It returns the
+// batches of the channel if the frame completes it.
+func (cb *ChannelBank) AddFrame(f *Frame, l1Block uint64) []*BatchData {
+	cb.Prune(l1Block)
+	if _, ok := cb.closed[f.ID]; ok {
+		cb.log.Trace("Ignoring frame of completed channel", "channel", f.ID, "frame", f.Number)
+		return nil
+	}
+	if cb.strict && !cb.inOrder(f) {
+		return nil
+	}
+	ch := cb.channels[f.ID]
+	if ch == nil {
+		ch = &pendingChannel{id: f.ID, opened: l1Block, frames: make(map[uint16][]byte), last: -1}
+		cb.channels[f.ID] = ch
+		cb.order = append(cb.order, ch)
+	}
+	if _, ok := ch.frames[f.Number]; ok {
+		cb.log.Trace("Ignoring duplicate frame", "channel", f.ID, "frame", f.Number)
+		return nil
+	}
+	if f.IsLast {
+		if ch.last >= 0 {
+			cb.log.Debug("Ignoring frame with conflicting channel end", "channel", f.ID, "frame", f.Number, "last", ch.last)
+			return nil
+		}
+		ch.last = int(f.Number)
+	}
+	if ch.last >= 0 && int(f.Number) > ch.last {
+		cb.log.Debug("Ignoring frame after channel end", "channel", f.ID, "frame", f.Number, "last", ch.last)
+		return nil
+	}
+	ch.frames[f.Number] = f.Data
+	ch.size += bufferedFrameOverhead + len(f.Data)
+	cb.size += bufferedFrameOverhead + len(f.Data)
+
+	if ch.last < 0 || len(ch.frames) != ch.last+1 {
+		return nil
+	}
+	// All frames are available, reassemble and decode the channel.
+	cb.remove(ch)
+	cb.closed[ch.id] = l1Block
+
+	data := make([]byte, 0, ch.size)
+	for i := 0; i <= ch.last; i++ {
+		data = append(data, ch.frames[uint16(i)]...)
+	}
+	batches, err := DecodeChannel(data)
+	if err != nil {
+		cb.log.Warn("Dropping invalid channel", "channel", ch.id, "frames", len(ch.frames), "err", err)
+		return nil
+	}
+	cb.log.Debug("Read channel", "channel", ch.id, "frames", len(ch.frames), "size", len(data), "batches", len(batches))
+	return batches
+}
+
+// inOrder reports whether a frame continues the pending channel of a strict
+// bank, or opens a new one. The first frame of a new channel drops the pending
+// channel.
+func (cb *ChannelBank) inOrder(f *Frame) bool {
+	var pending *pendingChannel
+	if len(cb.order) > 0 {
+		pending = cb.order[0]
+	}
+	if pending != nil && pending.id == f.ID {
+		if int(f.Number) != len(pending.frames) {
+			cb.log.Debug("Dropping out-of-order frame", "channel", f.ID, "frame", f.Number, "want", len(pending.frames))
+			droppedFrameMeter.Mark(1)
+			return false
+		}
+		return true
+	}
+	if f.Number != 0 {
+		cb.log.Debug("Dropping frame of unknown channel", "channel", f.ID, "frame", f.Number)
+		droppedFrameMeter.Mark(1)
+		return false
+	}
+	if pending != nil {
+		cb.log.Debug("Dropping incomplete channel replaced by a new one", "channel", pending.id, "frames", len(pending.frames), "next", f.ID)
+		droppedChanMeter.Mark(1)
+		cb.remove(pending)
+	}
+	return true
+}
+
+// Reset drops all pending channels.
+func (cb *ChannelBank) Reset() {
+	cb.channels = make(map[ChannelID]*pendingChannel)
+	cb.closed = make(map[ChannelID]uint64)
+	cb.order = nil
+	cb.size = 0
+}
+
+// remove drops a pending channel.
+func (cb *ChannelBank) remove(ch *pendingChannel) {
+	delete(cb.channels, ch.id)
+	for i, c := range cb.order {
+		if c == ch {
+			cb.order = append(cb.order[:i], cb.order[i+1:]...)
+			break
+		}
+	}
+	cb.size -= ch.size
+}
+
+// Prune drops the channels that timed out at the given L1 block, and the oldest
+// channels while the bank is over its size limit. It is called for every frame,
+// and should be called for every L1 block, so that channels time out even if no
+// more frames arrive.
+func (cb *ChannelBank) Prune(l1Block uint64) {
+	for len(cb.order) > 0 {
+		ch := cb.order[0]
+		if ch.opened+cb.timeout >= l1Block && cb.size <= maxChannelBankSize {
+			break
+		}
+		cb.log.Debug("Dropping incomplete channel", "channel", ch.id, "opened", ch.opened, "frames", len(ch.frames))
+		droppedChanMeter.Mark(1)
+		cb.remove(ch)
+	}
+	for id, completed := range cb.closed {
+		if completed+cb.timeout < l1Block {
+			delete(cb.closed, id)
+		}
+	}
+}
+
+// ChannelReader reassembles the frames of the previous stage into channels in a
+// channel bank, and reads the batches of the channels once they are complete.
+// It buffers the batches of a single channel, whose size is bounded by
+// MaxChannelDataSize.
+type ChannelReader struct {
+	prev frameProvider
+	bank *ChannelBank
+	log  log.Logger
+
+	batches []*BatchData // unread batches of the last completed channel
+	l1Block uint64
+}
+
+// NewChannelReader creates a channel reader that reassembles the frames of the
+// given stage in the given channel bank.
+func NewChannelReader(prev frameProvider, bank *ChannelBank, logger log.Logger) *ChannelReader {
+	return &ChannelReader{prev: prev, bank: bank, log: logger}
+}
+
+// NextBatch returns the next batch, along with the number of the L1 block that
+// completed its channel. It returns io.EOF once all frames of the previous
+// stage were read.
+func (r *ChannelReader) NextBatch(ctx context.Context) (*BatchData, uint64, error) {
+	for len(r.batches) == 0 {
+		f, l1Block, err := r.prev.NextFrame(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		if f.legacy {
+			batches, err := DecodeBatches(f.Data)
+			if err != nil {
+				r.log.Warn("Ignoring invalid batch data", "l1block", l1Block, "err", err)
+				continue
+			}
+			r.batches = batches
+		} else {
+			r.batches = r.bank.AddFrame(f, l1Block)
+		}
+		r.l1Block = l1Block
+	}
+	b := r.batches[0]
+	r.batches = r.batches[1:]
+	return b, r.l1Block, nil
+}
+
+// Reset drops the buffered batches and the pending channels.
+func (r *ChannelReader) Reset() {
+	r.batches = nil
+	r.bank.Reset()
+}
@@ -0,0 +1,361 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// testBatches returns batches with random, incompressible transactions.
+func testBatches(n int, txSize int) []*BatchData {
+	rng := rand.New(rand.NewSource(1))
+	batches := make([]*BatchData, n)
+	for i := range batches {
+		tx := make([]byte, txSize)
+		rng.Read(tx)
+		batches[i] = &BatchData{
+			ParentHash:   common.BigToHash(common.Big1),
+			EpochNum:     uint64(i),
+			Timestamp:    1000 + uint64(i)*2,
+			Transactions: []hexutil.Bytes{tx},
+		}
+	}
+	return batches
+}
+
+func TestFrameEncoding(t *testing.T) {
+	frames, err := EncodeChannel(Zlib, testBatches(4, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) < 2 {
+		t.Fatalf("channel was not split, %d frames", len(frames))
+	}
+	for i, f := range frames {
+		if f.Number != uint16(i) || f.ID != frames[0].ID || f.IsLast != (i == len(frames)-1) {
+			t.Fatalf("frame %d: unexpected header %v/%d/%v", i, f.ID, f.Number, f.IsLast)
+		}
+		if size := len(EncodeFrames(f)); size > 300+1 {
+			t.Fatalf("frame %d: encoded size %d exceeds the maximum", i, size)
+		}
+	}
+	dec, err := DecodeFrames(EncodeFrames(frames...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, frames) {
+		t.Fatal("frames changed after decoding")
+	}
+
+	invalid := map[string][]byte{
+		"empty":            nil,
+		"wrong version":    {DerivationVersion0},
+		"truncated header": append([]byte{DerivationVersion1}, make([]byte, frameOverhead-1)...),
+		"truncated data":   EncodeFrames(frames[0])[:100],
+		"invalid is_last":  append(EncodeFrames(frames[0])[:frameOverhead+len(frames[0].Data)], 2),
+	}
+	for name, data := range invalid {
+		if _, err := DecodeFrames(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestChannelBankReassembly(t *testing.T) {
+	batches := testBatches(4, 500)
+	frames, err := EncodeChannel(Zlib, batches, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Deliver the frames in reverse order, with every frame duplicated.
+	bank := NewChannelBank(DefaultChannelTimeout, log.New())
+	for i := len(frames) - 1; i > 0; i-- {
+		for j := 0; j < 2; j++ {
+			if out := bank.AddFrame(frames[i], 1); out != nil {
+				t.Fatalf("channel released after frame %d", i)
+			}
+		}
+	}
+	out := bank.AddFrame(frames[0], 2)
+	if !reflect.DeepEqual(out, batches) {
+		t.Fatal("reassembled batches differ")
+	}
+	// Late duplicates of a completed channel are ignored.
+	if out := bank.AddFrame(frames[0], 3); out != nil {
+		t.Fatal("completed channel released again")
+	}
+	if len(bank.channels) != 0 || bank.size != 0 {
+		t.Fatalf("channel bank not empty: %d channels, %d bytes", len(bank.channels), bank.size)
+	}
+}
+
+func TestChannelBankInterleaved(t *testing.T) {
+	var (
+		bank   = NewChannelBank(DefaultChannelTimeout, log.New())
+		a, _   = EncodeChannel(Zlib, testBatches(2, 400), 200)
+		b, _   = EncodeChannel(Zlib, testBatches(3, 400), 200)
+		output [][]*BatchData
+	)
+	for i := 0; i < len(a) || i < len(b); i++ {
+		if i < len(b) {
+			if out := bank.AddFrame(b[i], 1); out != nil {
+				output = append(output, out)
+			}
+		}
+		if i < len(a) {
+			if out := bank.AddFrame(a[i], 1); out != nil {
+				output = append(output, out)
+			}
+		}
+	}
+	if len(output) != 2 || len(output[0]) != 2 || len(output[1]) != 3 {
+		t.Fatalf("unexpected channel output %v", output)
+	}
+}
+
+func TestChannelBankTimeout(t *testing.T) {
+	frames, err := EncodeChannel(Zlib, testBatches(2, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank := NewChannelBank(DefaultChannelTimeout, log.New())
+	bank.AddFrame(frames[0], 10)
+	// The remaining frames arrive after the timeout, which reopens the
+	// channel without the first frame.
+	for _, f := range frames[1:] {
+		if out := bank.AddFrame(f, 10+DefaultChannelTimeout+1); out != nil {
+			t.Fatal("timed out channel released")
+		}
+	}
+	if len(bank.channels) != 1 || len(bank.channels[frames[0].ID].frames) != len(frames)-1 {
+		t.Fatal("timed out frames not dropped")
+	}
+}
+
+func TestChannelBankPrune(t *testing.T) {
+	frames, err := EncodeChannel(Zlib, testBatches(2, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank := NewChannelBank(5, log.New())
+	bank.AddFrame(frames[0], 10)
+	// Incomplete channels time out without further frames.
+	bank.Prune(15)
+	if len(bank.channels) != 1 {
+		t.Fatal("channel dropped before the timeout")
+	}
+	bank.Prune(16)
+	if len(bank.channels) != 0 || bank.size != 0 {
+		t.Fatalf("timed out channel not dropped: %d channels, %d bytes", len(bank.channels), bank.size)
+	}
+	// Empty frames count towards the size limit.
+	for i := 0; i < 100; i++ {
+		bank.AddFrame(&Frame{ID: ChannelID{byte(i)}, Number: 1}, 20)
+	}
+	if bank.size != 100*bufferedFrameOverhead {
+		t.Fatalf("empty frames account for %d bytes, want %d", bank.size, 100*bufferedFrameOverhead)
+	}
+}
+
+func TestStrictChannelBank(t *testing.T) {
+	a, err := EncodeChannel(Zlib, testBatches(2, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EncodeChannel(Zlib, testBatches(3, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank := NewStrictChannelBank(DefaultChannelTimeout, log.New())
+
+	// Frames out of order, and frames of unknown channels, are dropped.
+	bank.AddFrame(a[1], 1)
+	if len(bank.channels) != 0 {
+		t.Fatal("frame of unknown channel buffered")
+	}
+	bank.AddFrame(a[0], 1)
+	bank.AddFrame(a[2], 1)
+	if ch := bank.channels[a[0].ID]; ch == nil || len(ch.frames) != 1 {
+		t.Fatal("out-of-order frame buffered")
+	}
+	// The first frame of another channel replaces the pending one.
+	bank.AddFrame(b[0], 2)
+	if len(bank.channels) != 1 || bank.channels[b[0].ID] == nil {
+		t.Fatal("pending channel not replaced")
+	}
+	var out []*BatchData
+	for _, f := range b[1:] {
+		// Duplicates are out of order as well.
+		bank.AddFrame(b[0], 2)
+		out = bank.AddFrame(f, 2)
+	}
+	if len(out) != 3 {
+		t.Fatalf("channel not released, got %d batches", len(out))
+	}
+	for _, f := range a[1:] {
+		if out := bank.AddFrame(f, 3); out != nil {
+			t.Fatal("replaced channel released")
+		}
+	}
+	if len(bank.channels) != 0 || bank.size != 0 {
+		t.Fatalf("channel bank not empty: %d channels, %d bytes", len(bank.channels), bank.size)
+	}
+}
+
+func TestChannelBankConflictingEnd(t *testing.T) {
+	frames, err := EncodeChannel(Zlib, testBatches(2, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := frames[len(frames)-1]
+	bank := NewChannelBank(DefaultChannelTimeout, log.New())
+	// A frame beyond the end and a second end are ignored.
+	bank.AddFrame(last, 1)
+	bank.AddFrame(&Frame{ID: last.ID, Number: last.Number + 1, Data: []byte{1}}, 1)
+	bank.AddFrame(&Frame{ID: last.ID, Number: last.Number + 2, Data: []byte{1}, IsLast: true}, 1)
+	var out []*BatchData
+	for _, f := range frames[:len(frames)-1] {
+		out = bank.AddFrame(f, 1)
+	}
+	if len(out) != 2 {
+		t.Fatalf("channel not released, got %d batches", len(out))
+	}
+}
+
+// reverseCompressor stores data uncompressed, but reversed, to test pluggable
+// compression algorithms.
+type reverseCompressor struct{}
+
+type reverseWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (w *reverseWriter) Write(b []byte) (int, error) { return w.buf.Write(b) }
+
+func (w *reverseWriter) Close() error {
+	_, err := w.w.Write(reverse(w.buf.Bytes()))
+	return err
+}
+
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
+
+func (reverseCompressor) ID() byte { return 0xff }
+
+func (reverseCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return &reverseWriter{w: w}, nil
+}
+
+func (reverseCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(reverse(data))), nil
+}
+
+func TestCustomCompressor(t *testing.T) {
+	batches := testBatches(2, 100)
+	frames, err := EncodeChannel(reverseCompressor{}, batches, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeChannel(frames[0].Data); !errors.Is(err, errUnknownCompression) {
+		t.Fatalf("expected unknown compression error, got %v", err)
+	}
+	if err := RegisterCompressor(reverseCompressor{}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(compressors, reverseCompressor{}.ID())
+	if err := RegisterCompressor(reverseCompressor{}); !errors.Is(err, errDuplicateCompressorID) {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+	out, err := DecodeChannel(frames[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, batches) {
+		t.Fatal("decoded batches differ")
+	}
+}
+
+// testFrameProvider hands out frames, all from the same L1 block.
+type testFrameProvider struct {
+	frames  []*Frame
+	l1Block uint64
+}
+
+func (p *testFrameProvider) NextFrame(ctx context.Context) (*Frame, uint64, error) {
+	if len(p.frames) == 0 {
+		return nil, 0, io.EOF
+	}
+	f := p.frames[0]
+	p.frames = p.frames[1:]
+	return f, p.l1Block, nil
+}
+
+func TestChannelReader(t *testing.T) {
+	batches := testBatches(4, 500)
+	frames, err := EncodeChannel(Zlib, batches, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyBatches := testBatches(2, 10)
+	legacy, err := EncodeBatches(legacyBatches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := &testFrameProvider{
+		frames:  append([]*Frame{{Data: legacy, legacy: true}, {Data: []byte{0xff}, legacy: true}}, frames...),
+		l1Block: 3,
+	}
+	r := NewChannelReader(prev, NewChannelBank(DefaultChannelTimeout, log.New()), log.New())
+
+	// Invalid legacy data is skipped, and the batches of a channel are only
+	// read once all of its frames were pulled.
+	for i, want := range append(legacyBatches, batches...) {
+		b, l1Block, err := r.NextBatch(context.Background())
+		if err != nil {
+			t.Fatalf("batch %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(b, want) || l1Block != 3 {
+			t.Fatalf("batch %d mismatch: %+v from block %d", i, b, l1Block)
+		}
+		if i == len(legacyBatches)-1 && len(prev.frames) != len(frames)+1 {
+			t.Fatalf("frames pulled before the legacy batches were read")
+		}
+	}
+	if _, _, err := r.NextBatch(context.Background()); err != io.EOF {
+		t.Fatalf("NextBatch after the last batch = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// DataAvailabilitySource provides the batch data that was posted for the L1
+// blocks. The default source reads the calldata of the batch inbox
+// transactions, other sources may read blob transactions or an external data
+// availability layer that is referenced from L1.
+type DataAvailabilitySource interface {
+	// BatchData returns the batch data that was posted for the given L1 block,
+	// in the order it was posted. Data that cannot be attributed to the given
+	// batcher, the batch sender of the system config at the block, must not be
+	// returned.
+	BatchData(ctx context.Context, block *types.Block, batcher common.Address) ([][]byte, error)
+}
+
+// DataSourceConstructor creates the data availability source of a rollup,
+// reading from the given L1 chain.
+type DataSourceConstructor func(cfg *rollup.Config, l1 L1Fetcher, logger log.Logger) DataAvailabilitySource
+
+// CalldataSourceName is the name of the default data availability source, the
+// calldata of the batch inbox transactions.
+const CalldataSourceName = "calldata"
+
+var (
+	dataSourcesLock sync.RWMutex
+	dataSources     = map[string]DataSourceConstructor{
+		CalldataSourceName: func(cfg *rollup.Config, _ L1Fetcher, logger log.Logger) DataAvailabilitySource {
+			return NewCalldataSource(cfg, logger)
+		},
+	}
+)
+
+// RegisterDataAvailabilitySource makes a data availability source available
+// under the given name, which rollup configurations select it by. It panics if
+// the name is taken.
+func RegisterDataAvailabilitySource(name string, ctor DataSourceConstructor) {
+	dataSourcesLock.Lock()
+	defer dataSourcesLock.Unlock()
+
+	if _, ok := dataSources[name]; ok {
+		panic(fmt.Sprintf("data availability source %q registered twice", name))
+	}
+	dataSources[name] = ctor
+}
+
+// LookupDataAvailabilitySource returns the constructor of the data availability
+// source with the given name. The empty name selects the calldata source.
+func LookupDataAvailabilitySource(name string) (DataSourceConstructor, error) {
+	if name == "" {
+		name = CalldataSourceName
+	}
+	dataSourcesLock.RLock()
+	defer dataSourcesLock.RUnlock()
+
+	ctor, ok := dataSources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown data availability source %q", name)
+	}
+	return ctor, nil
+}
+
+// unknownDataSource fails to read any data, it stands in for a source that is
+// not registered.
+type unknownDataSource struct{ err error }
+
+func (s unknownDataSource) BatchData(context.Context, *types.Block, common.Address) ([][]byte, error) {
+	return nil, s.err
+}
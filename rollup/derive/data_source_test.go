@@ -0,0 +1,92 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// testDataSource serves batch data that is keyed by L1 block hash, like an
+// external data availability layer.
+type testDataSource struct {
+	data map[common.Hash][][]byte
+}
+
+func (s *testDataSource) BatchData(ctx context.Context, block *types.Block, batcher common.Address) ([][]byte, error) {
+	return s.data[block.Hash()], nil
+}
+
+func TestPipelineDataAvailabilitySource(t *testing.T) {
+	source := &testDataSource{data: make(map[common.Hash][][]byte)}
+	RegisterDataAvailabilitySource("test", func(*rollup.Config, L1Fetcher, log.Logger) DataAvailabilitySource {
+		return source
+	})
+	defer func() {
+		dataSourcesLock.Lock()
+		delete(dataSources, "test")
+		dataSourcesLock.Unlock()
+	}()
+
+	s := newTestSetup()
+	s.cfg.DataAvailability = "test"
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+
+	// The batch is only known to the data source, the inbox transaction of
+	// the L1 block is ignored.
+	batch := &BatchData{
+		ParentHash: s.cfg.Genesis.L2.Hash,
+		EpochHash:  s.cfg.Genesis.L1.Hash,
+		Timestamp:  s.cfg.Genesis.L2Time + s.cfg.BlockTime,
+	}
+	data, err := EncodeBatches([]*BatchData{batch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignored := &BatchData{
+		ParentHash: s.cfg.Genesis.L2.Hash,
+		EpochHash:  s.cfg.Genesis.L1.Hash,
+		Timestamp:  s.cfg.Genesis.L2Time + 2*s.cfg.BlockTime,
+	}
+	block := s.l1.AddBlock(s.batchTx(t, ignored))
+	source.data[block.Hash()] = [][]byte{data}
+	runPipeline(t, p)
+
+	if head := p.Head(); head.Number != 1 || head.Time != batch.Timestamp {
+		t.Fatalf("unexpected head %+v", head)
+	}
+}
+
+func TestLookupDataAvailabilitySource(t *testing.T) {
+	for _, name := range []string{"", CalldataSourceName} {
+		ctor, err := LookupDataAvailabilitySource(name)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if _, ok := ctor(new(rollup.Config), nil, log.New()).(*CalldataSource); !ok {
+			t.Fatalf("%q does not select the calldata source", name)
+		}
+	}
+	if _, err := LookupDataAvailabilitySource("unknown"); err == nil {
+		t.Fatal("unknown source found")
+	}
+}
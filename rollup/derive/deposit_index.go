@@ -0,0 +1,217 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// IndexedDeposit locates a user deposit in the L1 transaction that made it and
+// in the L2 block that included it.
+type IndexedDeposit struct {
+	SourceHash    common.Hash `json:"sourceHash"`
+	L1BlockHash   common.Hash `json:"l1BlockHash"`
+	L1BlockNumber uint64      `json:"l1BlockNumber"`
+	L1TxHash      common.Hash `json:"l1TxHash"`
+	LogIndex      uint64      `json:"logIndex"`
+	L2BlockHash   common.Hash `json:"l2BlockHash"`
+	L2BlockNumber uint64      `json:"l2BlockNumber"`
+	L2TxHash      common.Hash `json:"l2TxHash"`
+}
+
+// Key prefixes of the deposit index.
+var (
+	depositPrefix     = []byte("d") // source hash -> IndexedDeposit
+	depositL1TxPrefix = []byte("t") // L1 tx hash + source hash -> nil
+	depositL2Prefix   = []byte("b") // L2 block number + source hash -> nil
+)
+
+func depositKey(source common.Hash) []byte {
+	return append(append([]byte{}, depositPrefix...), source[:]...)
+}
+
+func depositL1TxKey(l1Tx, source common.Hash) []byte {
+	key := append(append([]byte{}, depositL1TxPrefix...), l1Tx[:]...)
+	return append(key, source[:]...)
+}
+
+func depositL2Key(number uint64, source common.Hash) []byte {
+	key := make([]byte, len(depositL2Prefix)+8, len(depositL2Prefix)+8+common.HashLength)
+	copy(key, depositL2Prefix)
+	binary.BigEndian.PutUint64(key[len(depositL2Prefix):], number)
+	return append(key, source[:]...)
+}
+
+// DepositIndex maps the user deposits of the derived L2 blocks to the L1
+// transactions that made them, so that the L2 transaction of a deposit can be
+// found without scanning L2 blocks. It is persisted in a key-value store and
+// follows the derived chain: the deposits of blocks that are derived again
+// after an L1 reorg are replaced. Only the blocks that the node derived itself
+// are indexed, not the blocks before the head it started from.
+//
+// DepositIndex is safe for concurrent use.
+type DepositIndex struct {
+	cfg *rollup.Config
+	l1  L1Fetcher
+	db  ethdb.KeyValueStore
+}
+
+// NewDepositIndex creates a deposit index stored in the given database.
+func NewDepositIndex(cfg *rollup.Config, l1 L1Fetcher, db ethdb.KeyValueStore) *DepositIndex {
+	return &DepositIndex{cfg: cfg, l1: l1, db: db}
+}
+
+// DepositBySourceHash returns the deposit with the given source hash, or nil if
+// it is not indexed.
+func (idx *DepositIndex) DepositBySourceHash(source common.Hash) (*IndexedDeposit, error) {
+	key := depositKey(source)
+	if ok, err := idx.db.Has(key); err != nil || !ok {
+		return nil, err
+	}
+	enc, err := idx.db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var dep IndexedDeposit
+	if err := rlp.DecodeBytes(enc, &dep); err != nil {
+		return nil, fmt.Errorf("invalid deposit index entry %s: %w", source, err)
+	}
+	return &dep, nil
+}
+
+// DepositsByL1TxHash returns the indexed deposits of the given L1 transaction,
+// in no particular order.
+func (idx *DepositIndex) DepositsByL1TxHash(l1Tx common.Hash) ([]*IndexedDeposit, error) {
+	prefix := append(append([]byte{}, depositL1TxPrefix...), l1Tx[:]...)
+	it := idx.db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var deps []*IndexedDeposit
+	for it.Next() {
+		dep, err := idx.DepositBySourceHash(common.BytesToHash(it.Key()[len(prefix):]))
+		if err != nil {
+			return nil, err
+		}
+		if dep != nil {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, it.Error()
+}
+
+// prepare locates the user deposits among the transactions of an L2 block in
+// the L1 origin of the block. The L2 block is filled in by put, once the block
+// is inserted.
+func (idx *DepositIndex) prepare(ctx context.Context, origin *types.Header, txs [][]byte) ([]*IndexedDeposit, error) {
+	l2Txs := make(map[common.Hash]common.Hash)
+	for _, enc := range txs {
+		if len(enc) == 0 || enc[0] != types.DepositTxType {
+			continue
+		}
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			return nil, err
+		}
+		l2Txs[tx.SourceHash()] = tx.Hash()
+	}
+	if len(l2Txs) == 0 {
+		return nil, nil
+	}
+	receipts, err := idx.l1.Receipts(ctx, origin.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch receipts of L1 block %d: %w", origin.Number, err)
+	}
+	var deps []*IndexedDeposit
+	for _, receipt := range receipts {
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		for _, ev := range receipt.Logs {
+			if ev.Address != idx.cfg.DepositContractAddress || len(ev.Topics) == 0 || ev.Topics[0] != DepositEventABIHash {
+				continue
+			}
+			source := idx.cfg.DepositSourceHash(origin.Time, types.UserDepositSourceHash(origin.Hash(), uint64(ev.Index)))
+			l2Tx, ok := l2Txs[source]
+			if !ok {
+				continue
+			}
+			deps = append(deps, &IndexedDeposit{
+				SourceHash:    source,
+				L1BlockHash:   origin.Hash(),
+				L1BlockNumber: origin.Number.Uint64(),
+				L1TxHash:      ev.TxHash,
+				LogIndex:      uint64(ev.Index),
+				L2TxHash:      l2Tx,
+			})
+		}
+	}
+	return deps, nil
+}
+
+// put indexes the prepared deposits of an inserted L2 block.
+func (idx *DepositIndex) put(ref rollup.L2BlockRef, deps []*IndexedDeposit) error {
+	if len(deps) == 0 {
+		return nil
+	}
+	batch := idx.db.NewBatch()
+	for _, dep := range deps {
+		dep.L2BlockHash, dep.L2BlockNumber = ref.Hash, ref.Number
+		enc, err := rlp.EncodeToBytes(dep)
+		if err != nil {
+			return err
+		}
+		batch.Put(depositKey(dep.SourceHash), enc)
+		batch.Put(depositL1TxKey(dep.L1TxHash, dep.SourceHash), nil)
+		batch.Put(depositL2Key(ref.Number, dep.SourceHash), nil)
+	}
+	return batch.Write()
+}
+
+// unwind removes the deposits of the L2 blocks after the given one, which are
+// derived again.
+func (idx *DepositIndex) unwind(number uint64) error {
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, number+1)
+	it := idx.db.NewIterator(depositL2Prefix, start)
+	defer it.Release()
+
+	batch := idx.db.NewBatch()
+	for it.Next() {
+		source := common.BytesToHash(it.Key()[len(depositL2Prefix)+8:])
+		dep, err := idx.DepositBySourceHash(source)
+		if err != nil {
+			return err
+		}
+		if dep != nil {
+			batch.Delete(depositKey(source))
+			batch.Delete(depositL1TxKey(dep.L1TxHash, source))
+		}
+		batch.Delete(common.CopyBytes(it.Key()))
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func TestDepositIndex(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.DepositContractAddress = testDepositContract
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	idx := NewDepositIndex(s.cfg, s.l1, memorydb.New())
+	if err := p.SetDepositIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The deposit of the first L1 block is included in the first L2 block of
+	// its epoch.
+	to := common.HexToAddress("0x1234")
+	dep := &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Mint: big.NewInt(1000), Value: new(big.Int), Gas: 50_000, Data: []byte{}}
+	depositTx := s.dataTx(t, nil)
+	epoch1 := s.l1.AddBlockWithLogs([]*types.Transaction{depositTx}, [][]*types.Log{{MarshalDepositLogEvent(testDepositContract, dep)}})
+	s.l1.AddBlock(s.batchTx(t, &BatchData{
+		ParentHash: s.cfg.Genesis.L2.Hash,
+		EpochNum:   0,
+		EpochHash:  s.cfg.Genesis.L1.Hash,
+		Timestamp:  s.cfg.Genesis.L2Time + s.cfg.BlockTime,
+	}))
+	runPipeline(t, p)
+	s.l1.AddBlock(s.batchTx(t, &BatchData{
+		ParentHash: p.Head().Hash,
+		EpochNum:   1,
+		EpochHash:  epoch1.Hash(),
+		Timestamp:  epoch1.Time(),
+	}))
+	runPipeline(t, p)
+
+	head := p.Head()
+	l2Tx := s.engine.Blocks[head.Hash].Transactions()[1]
+	deps, err := idx.DepositsByL1TxHash(depositTx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IndexedDeposit{
+		SourceHash:    types.UserDepositSourceHash(epoch1.Hash(), 0),
+		L1BlockHash:   epoch1.Hash(),
+		L1BlockNumber: 1,
+		L1TxHash:      depositTx.Hash(),
+		L2BlockHash:   head.Hash,
+		L2BlockNumber: 2,
+		L2TxHash:      l2Tx.Hash(),
+	}
+	if len(deps) != 1 || *deps[0] != want {
+		t.Fatalf("indexed deposits %+v, want %+v", deps, want)
+	}
+	if d, err := idx.DepositBySourceHash(want.SourceHash); err != nil || d == nil || *d != want {
+		t.Fatalf("deposit by source hash %+v, want %+v (err %v)", d, want, err)
+	}
+
+	// The deposits of blocks that are derived again are removed.
+	if err := idx.unwind(1); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := idx.DepositBySourceHash(want.SourceHash); err != nil || d != nil {
+		t.Fatalf("unwound deposit still indexed: %+v (err %v)", d, err)
+	}
+	if deps, err := idx.DepositsByL1TxHash(depositTx.Hash()); err != nil || len(deps) != 0 {
+		t.Fatalf("unwound deposit still indexed by L1 tx: %+v (err %v)", deps, err)
+	}
+}
+
+// failingDB is a database whose batch writes fail while fail is set.
+type failingDB struct {
+	ethdb.KeyValueStore
+	fail bool
+}
+
+func (db *failingDB) NewBatch() ethdb.Batch {
+	return &failingBatch{Batch: db.KeyValueStore.NewBatch(), db: db}
+}
+
+type failingBatch struct {
+	ethdb.Batch
+	db *failingDB
+}
+
+func (b *failingBatch) Write() error {
+	if b.db.fail {
+		return errors.New("disk full")
+	}
+	return b.Batch.Write()
+}
+
+// Tests that a block whose deposits cannot be indexed is derived again, rather
+// than leave a gap in the index.
+func TestDepositIndexWriteFailure(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.DepositContractAddress = testDepositContract
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	db := &failingDB{KeyValueStore: memorydb.New()}
+	idx := NewDepositIndex(s.cfg, s.l1, db)
+	if err := p.SetDepositIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	to := common.HexToAddress("0x1234")
+	dep := &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Mint: big.NewInt(1000), Value: new(big.Int), Gas: 50_000, Data: []byte{}}
+	epoch1 := s.l1.AddBlockWithLogs([]*types.Transaction{s.dataTx(t, nil)}, [][]*types.Log{{MarshalDepositLogEvent(testDepositContract, dep)}})
+	s.l1.AddBlock(s.batchTx(t, &BatchData{
+		ParentHash: s.cfg.Genesis.L2.Hash,
+		EpochNum:   0,
+		EpochHash:  s.cfg.Genesis.L1.Hash,
+		Timestamp:  s.cfg.Genesis.L2Time + s.cfg.BlockTime,
+	}))
+	runPipeline(t, p)
+	parent := p.Head()
+	s.l1.AddBlock(s.batchTx(t, &BatchData{
+		ParentHash: parent.Hash,
+		EpochNum:   1,
+		EpochHash:  epoch1.Hash(),
+		Timestamp:  epoch1.Time(),
+	}))
+
+	// The block with the deposit is not derived while the index fails.
+	db.fail = true
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = p.Step(context.Background())
+	}
+	if !errors.Is(err, ErrTemporary) {
+		t.Fatalf("failed index write not reported as temporary: %v", err)
+	}
+	if head := p.Head(); head != parent {
+		t.Fatalf("head advanced to %d despite the failed index write", head.Number)
+	}
+
+	db.fail = false
+	runPipeline(t, p)
+	if p.Head().Number != 2 {
+		t.Fatalf("head %d, want 2", p.Head().Number)
+	}
+	source := types.UserDepositSourceHash(epoch1.Hash(), 0)
+	if d, err := idx.DepositBySourceHash(source); err != nil || d == nil || d.L2BlockHash != p.Head().Hash {
+		t.Fatalf("deposit not indexed with the derived block: %+v (err %v)", d, err)
+	}
+}
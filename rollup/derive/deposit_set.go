@@ -0,0 +1,117 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrDuplicateDeposit is returned when a derived block would include a deposit
+// whose source hash was included before. It indicates a derivation bug, and
+// the block is not inserted.
+var ErrDuplicateDeposit = errors.New("duplicate deposit source hash")
+
+// depositSet remembers the source hashes of the deposits in the recently
+// derived blocks, so that no deposit is included twice.
+//
+// It is a sanity check of the pipeline, not a consensus rule: the engine does
+// not reject blocks that repeat a deposit, and blocks that can no longer be
+// unwound are forgotten. The source hash of a deposit commits to the L1 block
+// it was read from, and the L1 origins of L2 blocks never go back, so deposits
+// of old epochs cannot reappear unless the L1 origin is also checked wrongly.
+// The set catches replays within the window that derivation bugs are most
+// likely to affect: the blocks that are re-derived after reorgs.
+type depositSet struct {
+	blocks  map[common.Hash]uint64 // source hash -> number of the including L2 block
+	numbers []depositBlock         // blocks with deposits, in increasing order
+}
+
+// depositBlock holds the source hashes of the deposits of an L2 block.
+type depositBlock struct {
+	number uint64
+	hashes []common.Hash
+}
+
+func newDepositSet() *depositSet {
+	return &depositSet{blocks: make(map[common.Hash]uint64)}
+}
+
+// check returns the source hashes of the deposits among the given encoded
+// transactions, or ErrDuplicateDeposit if any of them was seen before.
+func (s *depositSet) check(txs [][]byte) ([]common.Hash, error) {
+	var hashes []common.Hash
+	seen := make(map[common.Hash]bool)
+	for i, enc := range txs {
+		if len(enc) == 0 || enc[0] != types.DepositTxType {
+			continue
+		}
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			return nil, fmt.Errorf("invalid deposit %d: %w", i, err)
+		}
+		hash := tx.SourceHash()
+		if number, ok := s.blocks[hash]; ok {
+			return nil, fmt.Errorf("%w: %s, included in L2 block %d", ErrDuplicateDeposit, hash, number)
+		}
+		if seen[hash] {
+			return nil, fmt.Errorf("%w: %s, included twice in the block", ErrDuplicateDeposit, hash)
+		}
+		seen[hash] = true
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// add records the deposits of a derived block, which follows the blocks that
+// were added before.
+func (s *depositSet) add(number uint64, hashes []common.Hash) {
+	if len(hashes) == 0 {
+		return
+	}
+	for _, hash := range hashes {
+		s.blocks[hash] = number
+	}
+	s.numbers = append(s.numbers, depositBlock{number: number, hashes: hashes})
+}
+
+// unwind forgets the deposits of the blocks after the given one, which are
+// derived again.
+func (s *depositSet) unwind(number uint64) {
+	for len(s.numbers) > 0 && s.numbers[len(s.numbers)-1].number > number {
+		s.forget(s.numbers[len(s.numbers)-1])
+		s.numbers = s.numbers[:len(s.numbers)-1]
+	}
+}
+
+// prune forgets the deposits of the blocks up to the given one, which can no
+// longer be unwound.
+func (s *depositSet) prune(number uint64) {
+	for len(s.numbers) > 0 && s.numbers[0].number <= number {
+		s.forget(s.numbers[0])
+		s.numbers = s.numbers[1:]
+	}
+}
+
+func (s *depositSet) forget(block depositBlock) {
+	for _, hash := range block.hashes {
+		delete(s.blocks, hash)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDepositSet(t *testing.T) {
+	deposit := func(logIndex uint64) []byte {
+		enc, err := types.NewTx(&types.DepositTx{
+			SourceHash: types.UserDepositSourceHash(common.HexToHash("0x01"), logIndex),
+			Value:      new(big.Int),
+			Gas:        21000,
+		}).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return enc
+	}
+	s := newDepositSet()
+	block1 := [][]byte{deposit(0), userTx(t, 0)}
+	hashes, err := s.check(block1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 1 {
+		t.Fatalf("got %d source hashes, want 1", len(hashes))
+	}
+	s.add(1, hashes)
+
+	if _, err := s.check([][]byte{deposit(1), deposit(1)}); !errors.Is(err, ErrDuplicateDeposit) {
+		t.Fatalf("deposit repeated within a block: %v", err)
+	}
+	if _, err := s.check([][]byte{deposit(1), deposit(0)}); !errors.Is(err, ErrDuplicateDeposit) {
+		t.Fatalf("deposit of an earlier block replayed: %v", err)
+	}
+
+	// Once its block is unwound, the deposit can be included again.
+	s.unwind(0)
+	if _, err := s.check(block1); err != nil {
+		t.Fatalf("deposit of an unwound block rejected: %v", err)
+	}
+	s.add(1, hashes)
+
+	// Unwinding and pruning only touch the blocks beyond their bound.
+	for number := uint64(2); number <= 4; number++ {
+		hashes, err := s.check([][]byte{deposit(number)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.add(number, hashes)
+	}
+	s.unwind(3)
+	s.prune(1)
+	if len(s.blocks) != 2 || len(s.numbers) != 2 || s.numbers[0].number != 2 || s.numbers[1].number != 3 {
+		t.Fatalf("wrong deposits left after unwinding and pruning: %v", s.numbers)
+	}
+	if _, err := s.check([][]byte{deposit(4)}); err != nil {
+		t.Fatalf("deposit of an unwound block rejected: %v", err)
+	}
+	if _, err := s.check([][]byte{deposit(2)}); !errors.Is(err, ErrDuplicateDeposit) {
+		t.Fatalf("deposit of a remaining block replayed: %v", err)
+	}
+	s.prune(3)
+	if len(s.blocks) != 0 || len(s.numbers) != 0 {
+		t.Fatalf("%d deposits left after pruning", len(s.blocks))
+	}
+}
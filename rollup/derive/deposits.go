@@ -0,0 +1,244 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// DepositEventABIHash is the topic of the event that the deposit contract
+	// emits for every deposit:
+	//
+	//	event TransactionDeposited(address indexed from, address indexed to, uint256 indexed version, bytes opaqueData);
+	DepositEventABIHash = crypto.Keccak256Hash([]byte("TransactionDeposited(address,address,uint256,bytes)"))
+
+	// DepositEventVersion0 is the version of the opaque data layout:
+	//
+	//	abi.encodePacked(uint256 mint, uint256 value, uint64 gasLimit, bool isCreation, bytes data)
+	DepositEventVersion0 = common.Hash{}
+)
+
+// depositOpaqueHeaderLen is the length of the fixed fields at the start of the
+// version 0 opaque data.
+const depositOpaqueHeaderLen = 32 + 32 + 8 + 1
+
+var errNotDepositEvent = errors.New("log is not a deposit event")
+
+// UnmarshalDepositLogEvent decodes a deposit event log into the deposit
+// transaction it describes. The source hash of the deposit is derived from the
+// block hash and index of the log.
+func UnmarshalDepositLogEvent(ev *types.Log) (*types.DepositTx, error) {
+	if len(ev.Topics) != 4 {
+		return nil, fmt.Errorf("expected 4 event topics, got %d", len(ev.Topics))
+	}
+	if ev.Topics[0] != DepositEventABIHash {
+		return nil, errNotDepositEvent
+	}
+	if version := ev.Topics[3]; version != DepositEventVersion0 {
+		return nil, fmt.Errorf("unsupported deposit event version %x", version)
+	}
+	// The topics hold the padded addresses, which must not have dirty upper
+	// bytes.
+	from, err := topicAddress(ev.Topics[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid from topic: %w", err)
+	}
+	to, err := topicAddress(ev.Topics[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid to topic: %w", err)
+	}
+	opaque, err := unpackEventBytes(ev.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid opaque data: %w", err)
+	}
+	if len(opaque) < depositOpaqueHeaderLen {
+		return nil, fmt.Errorf("opaque data too short: %d bytes", len(opaque))
+	}
+	dep := &types.DepositTx{
+		SourceHash: types.UserDepositSourceHash(ev.BlockHash, uint64(ev.Index)),
+		From:       from,
+		Value:      new(big.Int).SetBytes(opaque[32:64]),
+		Gas:        binary.BigEndian.Uint64(opaque[64:72]),
+		Data:       common.CopyBytes(opaque[depositOpaqueHeaderLen:]),
+	}
+	if mint := new(big.Int).SetBytes(opaque[0:32]); mint.Sign() != 0 {
+		dep.Mint = mint
+	}
+	switch opaque[72] {
+	case 0:
+		dep.To = &to
+	case 1:
+		if to != (common.Address{}) {
+			return nil, fmt.Errorf("contract creation with target %s", to)
+		}
+	default:
+		return nil, fmt.Errorf("invalid contract creation flag %d", opaque[72])
+	}
+	return dep, nil
+}
+
+// MarshalDepositLogEvent encodes the deposit as the event log that the given
+// deposit contract emits for it. It is the inverse of UnmarshalDepositLogEvent,
+// except for the source hash, which is determined by the position of the log.
+func MarshalDepositLogEvent(depositContract common.Address, dep *types.DepositTx) *types.Log {
+	var to common.Address
+	if dep.To != nil {
+		to = *dep.To
+	}
+	return &types.Log{
+		Address: depositContract,
+		Topics: []common.Hash{
+			DepositEventABIHash,
+			common.BytesToHash(dep.From[:]),
+			common.BytesToHash(to[:]),
+			DepositEventVersion0,
+		},
+		Data: packEventBytes(MarshalDepositOpaqueData(dep)),
+	}
+}
+
+// MarshalDepositOpaqueData encodes the fields of the deposit that the deposit
+// event carries in its opaque data, in the version 0 layout.
+func MarshalDepositOpaqueData(dep *types.DepositTx) []byte {
+	opaque := make([]byte, depositOpaqueHeaderLen, depositOpaqueHeaderLen+len(dep.Data))
+	if dep.Mint != nil {
+		dep.Mint.FillBytes(opaque[0:32])
+	}
+	if dep.Value != nil {
+		dep.Value.FillBytes(opaque[32:64])
+	}
+	binary.BigEndian.PutUint64(opaque[64:72], dep.Gas)
+	if dep.To == nil {
+		opaque[72] = 1
+	}
+	return append(opaque, dep.Data...)
+}
+
+// UserDeposits collects the deposits emitted by the deposit contract in the
+// given receipts of the transactions of an L1 block. Reverted transactions
+// cannot emit deposits, so their receipts are skipped.
+//
+// A deposit whose sender is not the sender of its L1 transaction was made by an
+// L1 contract, and its sender is aliased with ApplyL1ToL2Alias. The senders are
+// only recovered for the transactions that emitted deposits.
+//
+// The deposits are put in canonical order by types.CanonicalizeDeposits, so
+// that logs that an L1 node returns twice or out of order do not change the
+// deposits of the block, and conflicting logs are rejected.
+func UserDeposits(signer types.Signer, txs types.Transactions, receipts []*types.Receipt, depositContract common.Address) ([]*types.DepositTx, error) {
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("have %d receipts for %d transactions", len(receipts), len(txs))
+	}
+	var (
+		deposits []*types.Transaction
+		bySource = make(map[common.Hash]*types.DepositTx)
+		origins  = make(map[common.Hash]types.OriginInfo)
+	)
+	for i, receipt := range receipts {
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		var (
+			origin    common.Address
+			recovered bool
+		)
+		for _, ev := range receipt.Logs {
+			if ev.Address != depositContract || len(ev.Topics) == 0 || ev.Topics[0] != DepositEventABIHash {
+				continue
+			}
+			dep, err := UnmarshalDepositLogEvent(ev)
+			if err != nil {
+				return nil, fmt.Errorf("malformed deposit log %d of transaction %s: %w", ev.Index, receipt.TxHash, err)
+			}
+			if !recovered {
+				if origin, err = types.Sender(signer, txs[i]); err != nil {
+					return nil, fmt.Errorf("failed to recover sender of deposit transaction %s: %w", txs[i].Hash(), err)
+				}
+				recovered = true
+			}
+			if dep.From != origin {
+				dep.From = ApplyL1ToL2Alias(dep.From)
+			}
+			deposits = append(deposits, types.NewTx(dep))
+			bySource[dep.SourceHash] = dep
+			origins[dep.SourceHash] = types.OriginInfo{L1BlockHash: ev.BlockHash, L1BlockNumber: ev.BlockNumber, LogIndex: uint64(ev.Index)}
+		}
+	}
+	canonical, err := types.CanonicalizeDeposits(deposits, origins)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.DepositTx, 0, len(canonical))
+	for _, tx := range canonical {
+		result = append(result, bySource[tx.SourceHash()])
+	}
+	return result, nil
+}
+
+// topicAddress decodes an address from an indexed event topic.
+func topicAddress(topic common.Hash) (common.Address, error) {
+	for _, b := range topic[:common.HashLength-common.AddressLength] {
+		if b != 0 {
+			return common.Address{}, fmt.Errorf("address topic %x has dirty upper bytes", topic)
+		}
+	}
+	return common.BytesToAddress(topic[:]), nil
+}
+
+// unpackEventBytes decodes the ABI encoding of a single bytes value, which is
+// an offset, a length and the zero-padded content. The encoding must be exact.
+func unpackEventBytes(data []byte) ([]byte, error) {
+	if len(data) < 64 {
+		return nil, fmt.Errorf("too short: %d bytes", len(data))
+	}
+	var offset, length big.Int
+	if offset.SetBytes(data[0:32]); !offset.IsUint64() || offset.Uint64() != 32 {
+		return nil, fmt.Errorf("invalid offset %v", &offset)
+	}
+	length.SetBytes(data[32:64])
+	if !length.IsUint64() || length.Uint64() > uint64(len(data)-64) {
+		return nil, fmt.Errorf("length %v exceeds data", &length)
+	}
+	n := length.Uint64()
+	if padded := (n + 31) / 32 * 32; uint64(len(data)-64) != padded {
+		return nil, fmt.Errorf("have %d bytes, want %d for length %d", len(data)-64, padded, n)
+	}
+	for _, b := range data[64+n:] {
+		if b != 0 {
+			return nil, errors.New("non-zero padding")
+		}
+	}
+	return data[64 : 64+n], nil
+}
+
+// packEventBytes returns the ABI encoding of a single bytes value.
+func packEventBytes(content []byte) []byte {
+	padded := (len(content) + 31) / 32 * 32
+	data := make([]byte, 64+padded)
+	data[31] = 32
+	binary.BigEndian.PutUint64(data[56:64], uint64(len(content)))
+	copy(data[64:], content)
+	return data
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var testDepositContract = common.HexToAddress("0xdeadbeef")
+
+func TestDepositLogEventRoundTrip(t *testing.T) {
+	to := common.HexToAddress("0x1234")
+	deposits := map[string]*types.DepositTx{
+		"call": {
+			From:  common.HexToAddress("0xf00d"),
+			To:    &to,
+			Mint:  big.NewInt(100),
+			Value: big.NewInt(50),
+			Gas:   100_000,
+			Data:  []byte{0xca, 0xfe},
+		},
+		"creation": {
+			From:  common.HexToAddress("0xf00d"),
+			Value: new(big.Int),
+			Gas:   1_000_000,
+			Data:  make([]byte, 100), // spans multiple words
+		},
+		"mint only": {
+			From:  common.HexToAddress("0xf00d"),
+			To:    &to,
+			Mint:  big.NewInt(1e18),
+			Value: new(big.Int),
+			Gas:   21000,
+			Data:  []byte{},
+		},
+	}
+	blockHash := common.HexToHash("0xb10c")
+	for name, dep := range deposits {
+		ev := MarshalDepositLogEvent(testDepositContract, dep)
+		ev.BlockHash, ev.Index = blockHash, 3
+		if len(ev.Data)%32 != 0 {
+			t.Errorf("%s: data not word aligned: %d bytes", name, len(ev.Data))
+		}
+		decoded, err := UnmarshalDepositLogEvent(ev)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		want := *dep
+		want.SourceHash = types.UserDepositSourceHash(blockHash, 3)
+		// Compare the encodings, as the decoded big integers differ in their
+		// internal representation.
+		if types.NewTx(decoded).Hash() != types.NewTx(&want).Hash() {
+			t.Errorf("%s: deposit mismatch:\nhave %+v\nwant %+v", name, decoded, &want)
+		}
+	}
+}
+
+func TestUnmarshalDepositLogEventInvalid(t *testing.T) {
+	to := common.HexToAddress("0x1234")
+	valid := func() *types.Log {
+		return MarshalDepositLogEvent(testDepositContract, &types.DepositTx{To: &to, Value: new(big.Int), Gas: 1, Data: []byte{1}})
+	}
+	tests := map[string]func(ev *types.Log){
+		"missing topic":   func(ev *types.Log) { ev.Topics = ev.Topics[:3] },
+		"other event":     func(ev *types.Log) { ev.Topics[0] = common.HexToHash("0x01") },
+		"version":         func(ev *types.Log) { ev.Topics[3] = common.HexToHash("0x01") },
+		"dirty from":      func(ev *types.Log) { ev.Topics[1][0] = 1 },
+		"dirty to":        func(ev *types.Log) { ev.Topics[2][0] = 1 },
+		"empty data":      func(ev *types.Log) { ev.Data = nil },
+		"offset":          func(ev *types.Log) { ev.Data[31] = 64 },
+		"length overflow": func(ev *types.Log) { ev.Data[32] = 1 },
+		"truncated":       func(ev *types.Log) { ev.Data = ev.Data[:len(ev.Data)-1] },
+		"trailing word":   func(ev *types.Log) { ev.Data = append(ev.Data, make([]byte, 32)...) },
+		"dirty padding":   func(ev *types.Log) { ev.Data[len(ev.Data)-1] = 1 },
+		"short opaque":    func(ev *types.Log) { ev.Data = packEventBytes(make([]byte, depositOpaqueHeaderLen-1)) },
+		"creation flag":   func(ev *types.Log) { ev.Data[64+72] = 2 },
+		"creation target": func(ev *types.Log) { ev.Data[64+72] = 1 },
+	}
+	for name, modify := range tests {
+		ev := valid()
+		modify(ev)
+		if dep, err := UnmarshalDepositLogEvent(ev); err == nil {
+			t.Errorf("%s: expected error, got deposit %+v", name, dep)
+		}
+	}
+}
+
+func TestUserDeposits(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		signer = types.LatestSignerForChainID(big.NewInt(900))
+		to     = common.HexToAddress("0x1234")
+		dep    = &types.DepositTx{From: crypto.PubkeyToAddress(key.PublicKey), To: &to, Value: big.NewInt(1), Gas: 50_000, Data: []byte{}}
+		ev     = MarshalDepositLogEvent(testDepositContract, dep)
+		fake   = MarshalDepositLogEvent(common.HexToAddress("0xbad"), dep)
+	)
+	ev.Index, fake.Index = 1, 0
+	txs := make(types.Transactions, 3)
+	for i := range txs {
+		txs[i] = types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: big.NewInt(900), Nonce: uint64(i), To: &testDepositContract, Gas: 100_000})
+	}
+	receipts := []*types.Receipt{
+		{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{fake, ev}},
+		{Status: types.ReceiptStatusFailed, Logs: []*types.Log{ev}},
+		{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{{Address: testDepositContract, Topics: []common.Hash{common.HexToHash("0x01")}}}},
+	}
+	deposits, err := UserDeposits(signer, txs, receipts, testDepositContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 1 || deposits[0].SourceHash != types.UserDepositSourceHash(common.Hash{}, 1) {
+		t.Fatalf("unexpected deposits %+v", deposits)
+	}
+	if deposits[0].From != dep.From {
+		t.Errorf("deposit of the transaction sender aliased to %s", deposits[0].From)
+	}
+	// A deposit of another account was made by a contract, and is aliased.
+	contract := common.HexToAddress("0xc0de")
+	byContract := *dep
+	byContract.From = contract
+	receipts[0].Logs = []*types.Log{MarshalDepositLogEvent(testDepositContract, &byContract)}
+	if deposits, err = UserDeposits(signer, txs, receipts, testDepositContract); err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 1 || deposits[0].From != ApplyL1ToL2Alias(contract) {
+		t.Fatalf("deposit of a contract not aliased: %+v", deposits)
+	}
+	// A malformed deposit event of the deposit contract is an error.
+	bad := MarshalDepositLogEvent(testDepositContract, dep)
+	bad.Data = bad.Data[:40]
+	txs = append(txs, types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: big.NewInt(900), Nonce: 3, To: &testDepositContract, Gas: 100_000}))
+	receipts = append(receipts, &types.Receipt{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{bad}})
+	if _, err := UserDeposits(signer, txs, receipts, testDepositContract); err == nil {
+		t.Fatal("malformed deposit event accepted")
+	}
+	// So is a deposit transaction without a valid signature.
+	unsigned := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(900), To: &testDepositContract, Gas: 100_000})
+	if _, err := UserDeposits(signer, types.Transactions{unsigned}, receipts[:1], testDepositContract); err == nil {
+		t.Fatal("deposit of an unsigned transaction accepted")
+	}
+}
+
+// Tests that the user deposits of a block are in canonical order, even if the
+// L1 node returns their logs twice or out of order.
+func TestUserDepositsCanonical(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		signer  = types.LatestSignerForChainID(big.NewInt(900))
+		to      = common.HexToAddress("0x1234")
+		l1Hash  = common.HexToHash("0xdeadbeef")
+		deposit = func(index uint, value int64) *types.Log {
+			ev := MarshalDepositLogEvent(testDepositContract, &types.DepositTx{From: crypto.PubkeyToAddress(key.PublicKey), To: &to, Value: big.NewInt(value), Gas: 50_000, Data: []byte{}})
+			ev.BlockHash, ev.BlockNumber, ev.Index = l1Hash, 7, index
+			return ev
+		}
+	)
+	txs := make(types.Transactions, 2)
+	for i := range txs {
+		txs[i] = types.MustSignNewTx(key, signer, &types.DynamicFeeTx{ChainID: big.NewInt(900), Nonce: uint64(i), To: &testDepositContract, Gas: 100_000})
+	}
+	receipts := []*types.Receipt{
+		{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{deposit(5, 1), deposit(5, 1)}},
+		{Status: types.ReceiptStatusSuccessful, Logs: []*types.Log{deposit(2, 2)}},
+	}
+	deposits, err := UserDeposits(signer, txs, receipts, testDepositContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deposits) != 2 {
+		t.Fatalf("have %d deposits, want 2", len(deposits))
+	}
+	for i, index := range []uint64{2, 5} {
+		if want := types.UserDepositSourceHash(l1Hash, index); deposits[i].SourceHash != want {
+			t.Errorf("deposit %d: source hash %s, want the one of log %d", i, deposits[i].SourceHash, index)
+		}
+	}
+	// Two different deposits of the same log are a conflict.
+	receipts[0].Logs = []*types.Log{deposit(5, 1), deposit(5, 3)}
+	if _, err := UserDeposits(signer, txs, receipts, testDepositContract); !errors.Is(err, types.ErrDepositSourceConflict) {
+		t.Fatalf("conflicting deposit logs: have %v, want %v", err, types.ErrDepositSourceConflict)
+	}
+}
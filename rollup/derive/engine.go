@@ -0,0 +1,191 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+)
+
+// Engine is the subset of the Engine API that is used to insert derived blocks
+// into the execution engine.
+type Engine interface {
+	ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error)
+	GetPayload(ctx context.Context, id beacon.PayloadID) (*beacon.ExecutableDataV1, error)
+	NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error)
+}
+
+// Engine errors are either fatal, when the engine found the input invalid, or
+// transient, when the engine could not process it yet. Retrying a fatal error
+// with the same input fails again, so the derivation is reset instead.
+var (
+	// ErrEngineInvalid is returned when the engine rejects a payload, a
+	// forkchoice state or payload attributes as invalid. It is fatal.
+	ErrEngineInvalid = NewResetError(errors.New("engine rejected invalid input"))
+	// ErrEngineSyncing is returned when the engine cannot validate a payload
+	// or forkchoice state while it syncs. It is transient.
+	ErrEngineSyncing = NewTemporaryError(errors.New("engine is syncing"))
+	// ErrEngineUnavailable is returned when an Engine API call fails for other
+	// reasons, like a lost connection or a timeout. It is transient.
+	ErrEngineUnavailable = NewTemporaryError(errors.New("engine unavailable"))
+)
+
+var (
+	errMissingPayloadID = errors.New("engine did not start building a payload")
+	errGasLimitMismatch = errors.New("engine did not apply the gas limit")
+)
+
+// InsertHeadBlock makes the engine build a block on top of the head of the given
+// forkchoice state, imports the block, and makes it the new head. The safe and
+// finalized blocks are taken from the given state.
+func InsertHeadBlock(ctx context.Context, engine Engine, fc beacon.ForkchoiceStateV1, attrs *beacon.PayloadAttributesV1) (*beacon.ExecutableDataV1, error) {
+	id, err := StartPayload(ctx, engine, fc, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return SealPayload(ctx, engine, fc, id, attrs)
+}
+
+// StartPayload makes the engine start building a block with the given attributes
+// on top of the head of the given forkchoice state. The engine keeps adding
+// transactions of its pool to the block until SealPayload fetches it. Starting
+// another block on the same head abandons the block.
+func StartPayload(ctx context.Context, engine Engine, fc beacon.ForkchoiceStateV1, attrs *beacon.PayloadAttributesV1) (beacon.PayloadID, error) {
+	res, err := engine.ForkchoiceUpdate(ctx, &fc, attrs)
+	if err != nil {
+		return beacon.PayloadID{}, fmt.Errorf("failed to start building payload: %w", callError(err))
+	}
+	if err := statusError(&res.PayloadStatus); err != nil {
+		return beacon.PayloadID{}, fmt.Errorf("forkchoice state not applied: %w", err)
+	}
+	if res.PayloadID == nil {
+		return beacon.PayloadID{}, errMissingPayloadID
+	}
+	return *res.PayloadID, nil
+}
+
+// SealPayload fetches the block that the engine builds with the given ID and
+// attributes, imports it, and makes it the new head, like InsertHeadBlock.
+func SealPayload(ctx context.Context, engine Engine, fc beacon.ForkchoiceStateV1, id beacon.PayloadID, attrs *beacon.PayloadAttributesV1) (*beacon.ExecutableDataV1, error) {
+	payload, err := engine.GetPayload(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payload %s: %w", id, callError(err))
+	}
+	if len(payload.Transactions) < len(attrs.Transactions) {
+		return nil, NewCriticalError(fmt.Errorf("engine dropped forced transactions: %d of %d included", len(payload.Transactions), len(attrs.Transactions)))
+	}
+	if attrs.GasLimit != nil && payload.GasLimit != *attrs.GasLimit {
+		return nil, NewCriticalError(fmt.Errorf("%w: payload has %d, system config %d", errGasLimitMismatch, payload.GasLimit, *attrs.GasLimit))
+	}
+	if err := ImportPayload(ctx, engine, fc, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// ImportPayload imports a payload that was built elsewhere, such as an unsafe
+// payload gossiped by the sequencer, and makes it the new head. The safe and
+// finalized blocks are taken from the given state.
+func ImportPayload(ctx context.Context, engine Engine, fc beacon.ForkchoiceStateV1, payload *beacon.ExecutableDataV1) error {
+	status, err := engine.NewPayload(ctx, payload)
+	if err != nil {
+		return fmt.Errorf("failed to import payload %s: %w", payload.BlockHash, callError(err))
+	}
+	if err := statusError(status); err != nil {
+		return fmt.Errorf("payload %s not imported: %w", payload.BlockHash, err)
+	}
+	fc.HeadBlockHash = payload.BlockHash
+	if err := UpdateForkchoice(ctx, engine, fc); err != nil {
+		return fmt.Errorf("failed to make payload %s the head: %w", payload.BlockHash, err)
+	}
+	return nil
+}
+
+// SyncToPayload imports a payload into an engine that syncs the chain from its
+// execution-layer peers, and makes it the head. Unlike ImportPayload, it accepts
+// that the engine cannot validate the payload yet, in which case the engine
+// fetches the missing blocks from its peers. It reports whether the engine has
+// the full chain up to the payload.
+func SyncToPayload(ctx context.Context, engine Engine, fc beacon.ForkchoiceStateV1, payload *beacon.ExecutableDataV1) (bool, error) {
+	status, err := engine.NewPayload(ctx, payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to import payload %s: %w", payload.BlockHash, callError(err))
+	}
+	if err := statusError(status); err != nil && !errors.Is(err, ErrEngineSyncing) {
+		return false, fmt.Errorf("payload %s not imported: %w", payload.BlockHash, err)
+	}
+	fc.HeadBlockHash = payload.BlockHash
+	err = UpdateForkchoice(ctx, engine, fc)
+	switch {
+	case errors.Is(err, ErrEngineSyncing):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to make payload %s the head: %w", payload.BlockHash, err)
+	}
+	return true, nil
+}
+
+// UpdateForkchoice reports the given forkchoice state to the engine, without
+// building a payload.
+func UpdateForkchoice(ctx context.Context, engine Engine, fc beacon.ForkchoiceStateV1) error {
+	res, err := engine.ForkchoiceUpdate(ctx, &fc, nil)
+	if err != nil {
+		return callError(err)
+	}
+	return statusError(&res.PayloadStatus)
+}
+
+// statusError converts a payload status that is not VALID into an engine error.
+func statusError(status *beacon.PayloadStatusV1) error {
+	switch status.Status {
+	case beacon.VALID:
+		return nil
+	case beacon.INVALID, beacon.INVALIDBLOCKHASH:
+		if status.ValidationError != nil {
+			return fmt.Errorf("%w: %s (%s)", ErrEngineInvalid, status.Status, *status.ValidationError)
+		}
+		return fmt.Errorf("%w: %s", ErrEngineInvalid, status.Status)
+	case beacon.SYNCING, beacon.ACCEPTED:
+		return fmt.Errorf("%w: %s", ErrEngineSyncing, status.Status)
+	default:
+		return fmt.Errorf("%w: unknown payload status %q", ErrEngineUnavailable, status.Status)
+	}
+}
+
+// callError classifies the error of an Engine API call. Unless the engine
+// reported the input as invalid, the call is assumed to have failed for
+// transient reasons.
+func callError(err error) error {
+	if errors.Is(err, ErrEngineInvalid) || errors.Is(err, ErrEngineSyncing) || errors.Is(err, ErrEngineUnavailable) {
+		return err
+	}
+	return &unavailableError{err}
+}
+
+// unavailableError is an Engine API call error that is matched by
+// ErrEngineUnavailable and ErrTemporary, while keeping the original error in
+// the chain.
+type unavailableError struct{ err error }
+
+func (e *unavailableError) Error() string { return e.err.Error() }
+func (e *unavailableError) Unwrap() error { return e.err }
+func (e *unavailableError) Is(target error) bool {
+	return target == ErrEngineUnavailable || target == ErrTemporary
+}
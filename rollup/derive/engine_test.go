@@ -0,0 +1,121 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+// fixedGasLimitEngine is an engine that ignores the gas limit of the payload
+// attributes.
+type fixedGasLimitEngine struct {
+	*testutils.Engine
+}
+
+func (e fixedGasLimitEngine) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	if attr != nil {
+		cpy := *attr
+		cpy.GasLimit = nil
+		attr = &cpy
+	}
+	return e.Engine.ForkchoiceUpdate(ctx, state, attr)
+}
+
+func TestInsertHeadBlockGasLimit(t *testing.T) {
+	s := newTestSetup()
+	genesisL1 := s.l1.Head().Header()
+	fc := beacon.ForkchoiceStateV1{HeadBlockHash: s.cfg.Genesis.L2.Hash}
+	attrs, err := PreparePayloadAttributes(s.cfg, rollup.SystemConfig{GasLimit: 15_000_000}, genesisL1, 1, s.cfg.Genesis.L2Time+s.cfg.BlockTime, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := InsertHeadBlock(context.Background(), s.engine, fc, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.GasLimit != 15_000_000 {
+		t.Fatalf("payload gas limit %d, want the system config gas limit", payload.GasLimit)
+	}
+	// A block with another gas limit than the configured one is not inserted.
+	_, err = InsertHeadBlock(context.Background(), fixedGasLimitEngine{s.engine}, fc, attrs)
+	if !errors.Is(err, errGasLimitMismatch) || !errors.Is(err, ErrCritical) {
+		t.Fatalf("expected critical gas limit mismatch, got %v", err)
+	}
+}
+
+// failingEngine is an engine whose payload imports fail with the given status
+// or error.
+type failingEngine struct {
+	*testutils.Engine
+	status *beacon.PayloadStatusV1
+	err    error
+}
+
+func (e failingEngine) NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.status, nil
+}
+
+func TestInsertHeadBlockErrors(t *testing.T) {
+	s := newTestSetup()
+	genesisL1 := s.l1.Head().Header()
+	fc := beacon.ForkchoiceStateV1{HeadBlockHash: s.cfg.Genesis.L2.Hash}
+	attrs, err := PreparePayloadAttributes(s.cfg, rollup.SystemConfig{}, genesisL1, 1, s.cfg.Genesis.L2Time+s.cfg.BlockTime, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reason := "bad state root"
+	tests := []struct {
+		engine failingEngine
+		want   error
+		class  error
+	}{
+		{failingEngine{Engine: s.engine, status: &beacon.PayloadStatusV1{Status: beacon.INVALID, ValidationError: &reason}}, ErrEngineInvalid, ErrReset},
+		{failingEngine{Engine: s.engine, status: &beacon.PayloadStatusV1{Status: beacon.INVALIDBLOCKHASH}}, ErrEngineInvalid, ErrReset},
+		{failingEngine{Engine: s.engine, status: &beacon.PayloadStatusV1{Status: beacon.SYNCING}}, ErrEngineSyncing, ErrTemporary},
+		{failingEngine{Engine: s.engine, status: &beacon.PayloadStatusV1{Status: beacon.ACCEPTED}}, ErrEngineSyncing, ErrTemporary},
+		{failingEngine{Engine: s.engine, err: context.DeadlineExceeded}, ErrEngineUnavailable, ErrTemporary},
+	}
+	for i, tt := range tests {
+		_, err := InsertHeadBlock(context.Background(), tt.engine, fc, attrs)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("test %d: got error %v, want %v", i, err, tt.want)
+		}
+		if !errors.Is(err, tt.class) {
+			t.Errorf("test %d: error %v not classified as %v", i, err, tt.class)
+		}
+	}
+	// The original error of a failed call is kept.
+	_, err = InsertHeadBlock(context.Background(), failingEngine{Engine: s.engine, err: context.DeadlineExceeded}, fc, attrs)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call error not kept: %v", err)
+	}
+	// An unknown forkchoice head means that the engine syncs.
+	fc.HeadBlockHash = common.HexToHash("0x01")
+	if _, err := InsertHeadBlock(context.Background(), s.engine, fc, attrs); !errors.Is(err, ErrEngineSyncing) {
+		t.Errorf("got error %v for unknown head, want %v", err, ErrEngineSyncing)
+	}
+}
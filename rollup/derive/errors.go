@@ -0,0 +1,71 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"errors"
+	"io"
+)
+
+// The errors of a derivation step are classified by how the driver recovers
+// from them. Check the class with errors.Is; the classified error still
+// matches the errors it wraps.
+var (
+	// ErrTemporary marks failures that may succeed when the step is retried,
+	// like L1 or Engine API calls that failed.
+	ErrTemporary = errors.New("temporary derivation error")
+	// ErrReset marks failures after which the pipeline must start over from
+	// the safe head, like a derived block that the engine rejected.
+	ErrReset = errors.New("derivation reset required")
+	// ErrCritical marks failures that derivation cannot recover from without
+	// an operator, like derived blocks that would break consensus rules.
+	ErrCritical = errors.New("critical derivation error")
+)
+
+// classifiedError is an error along with its class.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string        { return e.err.Error() }
+func (e *classifiedError) Unwrap() error        { return e.err }
+func (e *classifiedError) Is(target error) bool { return target == e.class }
+
+// NewTemporaryError marks err as temporary.
+func NewTemporaryError(err error) error {
+	return &classifiedError{class: ErrTemporary, err: err}
+}
+
+// NewResetError marks err as requiring a reset of the pipeline.
+func NewResetError(err error) error {
+	return &classifiedError{class: ErrReset, err: err}
+}
+
+// NewCriticalError marks err as critical.
+func NewCriticalError(err error) error {
+	return &classifiedError{class: ErrCritical, err: err}
+}
+
+// classify marks the errors of a step that were not classified where they
+// occurred as temporary, since most of them are failed L1 or engine calls.
+func classify(err error) error {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, ErrTemporary) || errors.Is(err, ErrReset) || errors.Is(err, ErrCritical) {
+		return err
+	}
+	return NewTemporaryError(err)
+}
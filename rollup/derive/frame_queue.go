@@ -0,0 +1,72 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// FrameQueue decodes the batch inbox data of the previous stage into channel
+// frames. It buffers the frames of a single batch inbox transaction. Data that
+// cannot be decoded is skipped.
+//
+// Batch data of derivation version 0 is not split into frames. It is passed on
+// whole, as a single legacy frame.
+type FrameQueue struct {
+	prev dataProvider
+	log  log.Logger
+
+	frames  []*Frame // unread frames of the current data
+	l1Block uint64
+}
+
+// NewFrameQueue creates a frame queue that decodes the data of the given stage.
+func NewFrameQueue(prev dataProvider, logger log.Logger) *FrameQueue {
+	return &FrameQueue{prev: prev, log: logger}
+}
+
+// NextFrame returns the next frame, along with the number of the L1 block it
+// was included in. It returns io.EOF once all data of the previous stage was
+// read.
+func (q *FrameQueue) NextFrame(ctx context.Context) (*Frame, uint64, error) {
+	for len(q.frames) == 0 {
+		data, l1Block, err := q.prev.NextData(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) > 0 && data[0] == DerivationVersion0 {
+			q.frames, q.l1Block = []*Frame{{Data: data, legacy: true}}, l1Block
+			break
+		}
+		frames, err := DecodeFrames(data)
+		if err != nil {
+			q.log.Warn("Ignoring invalid batch data", "l1block", l1Block, "err", err)
+			continue
+		}
+		q.frames, q.l1Block = frames, l1Block
+	}
+	f := q.frames[0]
+	q.frames = q.frames[1:]
+	return f, q.l1Block, nil
+}
+
+// Reset drops the buffered frames.
+func (q *FrameQueue) Reset() {
+	q.frames = nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// testDataProvider hands out batch inbox data, all from the same L1 block, and
+// counts how much was pulled.
+type testDataProvider struct {
+	data    [][]byte
+	l1Block uint64
+	pulled  int
+}
+
+func (p *testDataProvider) NextData(ctx context.Context) ([]byte, uint64, error) {
+	if len(p.data) == 0 {
+		return nil, 0, io.EOF
+	}
+	data := p.data[0]
+	p.data = p.data[1:]
+	p.pulled++
+	return data, p.l1Block, nil
+}
+
+func TestFrameQueue(t *testing.T) {
+	frames, err := EncodeChannel(Zlib, testBatches(4, 500), 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := EncodeBatches(testBatches(2, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := &testDataProvider{
+		data:    [][]byte{EncodeFrames(frames[:2]...), {0xff}, legacy, EncodeFrames(frames[2:]...)},
+		l1Block: 7,
+	}
+	q := NewFrameQueue(prev, log.New())
+
+	// The frames of a transaction are handed out before the next one is read.
+	for i := 0; i < 2; i++ {
+		f, l1Block, err := q.NextFrame(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(f, frames[i]) || l1Block != 7 {
+			t.Fatalf("frame %d mismatch: %+v from block %d", i, f, l1Block)
+		}
+		if prev.pulled != 1 {
+			t.Fatalf("pulled %d transactions for the frames of the first one", prev.pulled)
+		}
+	}
+	// Invalid data is skipped, legacy data is passed on whole.
+	f, _, err := q.NextFrame(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.legacy || !reflect.DeepEqual(f.Data, legacy) {
+		t.Fatalf("legacy data not passed on: %+v", f)
+	}
+	for i := 2; i < len(frames); i++ {
+		f, _, err := q.NextFrame(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(f, frames[i]) {
+			t.Fatalf("frame %d mismatch: %+v", i, f)
+		}
+	}
+	if _, _, err := q.NextFrame(context.Background()); err != io.EOF {
+		t.Fatalf("NextFrame after the last frame = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// L2BlockRefFromPayload derives the reference of an L2 block from its payload.
+// The L1 origin of the block is read from its L1 info deposit.
+func L2BlockRefFromPayload(cfg *rollup.Config, payload *beacon.ExecutableDataV1) (rollup.L2BlockRef, error) {
+	if payload.Number == cfg.Genesis.L2.Number {
+		if payload.BlockHash != cfg.Genesis.L2.Hash {
+			return rollup.L2BlockRef{}, fmt.Errorf("expected L2 genesis %s, got %s", cfg.Genesis.L2.Hash, payload.BlockHash)
+		}
+		return cfg.L2GenesisRef(), nil
+	}
+	if len(payload.Transactions) == 0 {
+		return rollup.L2BlockRef{}, errors.New("missing L1 info deposit")
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(payload.Transactions[0]); err != nil {
+		return rollup.L2BlockRef{}, fmt.Errorf("failed to decode first transaction: %w", err)
+	}
+	if tx.Type() != types.DepositTxType {
+		return rollup.L2BlockRef{}, fmt.Errorf("first transaction has type %d, expected L1 info deposit", tx.Type())
+	}
+	var info types.L1BlockInfo
+	if err := info.UnmarshalBinary(tx.Data()); err != nil {
+		return rollup.L2BlockRef{}, err
+	}
+	return rollup.L2BlockRef{
+		Hash:           payload.BlockHash,
+		Number:         payload.Number,
+		ParentHash:     payload.ParentHash,
+		Time:           payload.Timestamp,
+		L1Origin:       rollup.BlockID{Hash: info.BlockHash, Number: info.Number},
+		SequenceNumber: info.SequenceNumber,
+	}, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// L1Traversal is the first stage of the pipeline. It hands out the L1 blocks
+// traversed by an L1 tracker, one at a time: the pipeline only advances it to
+// the next L1 block once the later stages consumed all data of the current one.
+type L1Traversal struct {
+	tracker *L1Tracker
+	block   *types.Header // current block, nil before the first advance
+	done    bool          // whether the current block was handed out
+}
+
+// NewL1Traversal creates a traversal of the L1 blocks after the head of the
+// given tracker.
+func NewL1Traversal(tracker *L1Tracker) *L1Traversal {
+	return &L1Traversal{tracker: tracker}
+}
+
+// NextL1Block returns the current L1 block, once. It returns io.EOF until the
+// traversal is advanced.
+func (t *L1Traversal) NextL1Block(ctx context.Context) (*types.Header, error) {
+	if t.block == nil || t.done {
+		return nil, io.EOF
+	}
+	t.done = true
+	return t.block, nil
+}
+
+// AdvanceL1Block moves the traversal to the next L1 block and returns it. It
+// returns io.EOF if the block does not exist yet, and ErrReorg if it does not
+// build on the current one.
+func (t *L1Traversal) AdvanceL1Block(ctx context.Context) (*types.Header, error) {
+	header, err := t.tracker.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.block, t.done = header, false
+	return header, nil
+}
+
+// Reset restarts the traversal after the head of the given tracker.
+func (t *L1Traversal) Reset(tracker *L1Tracker) {
+	t.tracker, t.block, t.done = tracker, nil, false
+}
+
+// L1Retrieval reads the batch inbox data of the L1 blocks of the traversal from
+// the data availability source. It buffers the data of a single L1 block.
+type L1Retrieval struct {
+	l1     L1Fetcher
+	da     DataAvailabilitySource
+	sysCfg *SystemConfigTracker
+	prev   l1BlockProvider
+	log    log.Logger
+
+	pending *types.Header // block whose data is not retrieved yet
+	data    [][]byte      // unread batch data of the current block
+	l1Block uint64
+}
+
+// NewL1Retrieval creates a retrieval stage that reads the data of the L1 blocks
+// of the given stage.
+func NewL1Retrieval(l1 L1Fetcher, da DataAvailabilitySource, sysCfg *SystemConfigTracker, prev l1BlockProvider, logger log.Logger) *L1Retrieval {
+	return &L1Retrieval{l1: l1, da: da, sysCfg: sysCfg, prev: prev, log: logger}
+}
+
+// NextData returns the next batch inbox data, along with the number of the L1
+// block it was included in. It returns io.EOF once all data of the L1 blocks of
+// the previous stage was read. If the data of a block cannot be retrieved, the
+// next call retries it.
+func (r *L1Retrieval) NextData(ctx context.Context) ([]byte, uint64, error) {
+	for len(r.data) == 0 {
+		if r.pending == nil {
+			header, err := r.prev.NextL1Block(ctx)
+			if err != nil {
+				return nil, 0, err
+			}
+			r.pending = header
+		}
+		if err := r.retrieve(ctx, r.pending); err != nil {
+			return nil, 0, err
+		}
+		r.pending = nil
+	}
+	data := r.data[0]
+	r.data = r.data[1:]
+	return data, r.l1Block, nil
+}
+
+// retrieve reads the batch data of an L1 block into the buffer.
+func (r *L1Retrieval) retrieve(ctx context.Context, header *types.Header) error {
+	number := header.Number.Uint64()
+	block, err := r.l1.BlockByHash(ctx, header.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 block %d: %w", number, err)
+	}
+	// The receipts are needed once the L1 block becomes the origin of an
+	// epoch, fetch them meanwhile.
+	if prefetcher, ok := r.l1.(receiptsPrefetcher); ok {
+		prefetcher.PrefetchReceipts(header.Hash())
+	}
+	sysCfg, err := r.sysCfg.At(ctx, header)
+	if err != nil {
+		return fmt.Errorf("failed to read system config at L1 block %d: %w", number, err)
+	}
+	data, err := r.da.BatchData(ctx, block, sysCfg.BatcherAddr)
+	if err != nil {
+		return fmt.Errorf("failed to read batch data of L1 block %d: %w", number, err)
+	}
+	r.data, r.l1Block = data, number
+	r.log.Debug("Read batch data from L1", "number", number, "hash", header.Hash(), "txs", len(data))
+	return nil
+}
+
+// Reset drops the buffered data.
+func (r *L1Retrieval) Reset() {
+	r.pending, r.data = nil, nil
+}
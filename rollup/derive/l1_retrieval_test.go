@@ -0,0 +1,106 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+// failingL1 fails to fetch blocks while fail is set.
+type failingL1 struct {
+	*testutils.L1Chain
+	fail bool
+}
+
+func (l *failingL1) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if l.fail {
+		return nil, errors.New("unavailable")
+	}
+	return l.L1Chain.BlockByHash(ctx, hash)
+}
+
+func TestL1Traversal(t *testing.T) {
+	s := newTestSetup()
+	ctx := context.Background()
+	tr := NewL1Traversal(NewL1Tracker(s.l1, rollup.L1BlockRefFromHeader(s.l1.Head().Header())))
+	if _, err := tr.NextL1Block(ctx); err != io.EOF {
+		t.Fatalf("block handed out before the first advance: %v", err)
+	}
+	if _, err := tr.AdvanceL1Block(ctx); err != io.EOF {
+		t.Fatalf("advanced past the L1 head: %v", err)
+	}
+	b1 := s.l1.AddBlock()
+	if _, err := tr.AdvanceL1Block(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The current block is handed out once.
+	if header, err := tr.NextL1Block(ctx); err != nil || header.Hash() != b1.Hash() {
+		t.Fatalf("NextL1Block = %v, %v, want block 1", header, err)
+	}
+	if _, err := tr.NextL1Block(ctx); err != io.EOF {
+		t.Fatalf("block handed out twice: %v", err)
+	}
+}
+
+func TestL1Retrieval(t *testing.T) {
+	s := newTestSetup()
+	ctx := context.Background()
+	l1 := &failingL1{L1Chain: s.l1}
+	tr := NewL1Traversal(NewL1Tracker(l1, rollup.L1BlockRefFromHeader(s.l1.Head().Header())))
+	r := NewL1Retrieval(l1, NewCalldataSource(s.cfg, log.New()), NewSystemConfigTracker(s.cfg, l1, log.New()), tr, log.New())
+
+	b1 := s.l1.AddBlock(s.dataTx(t, []byte{1}), s.dataTx(t, []byte{2}))
+	s.l1.AddBlock(s.dataTx(t, []byte{3}))
+	if _, err := tr.AdvanceL1Block(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A failure to retrieve the data is retried by the next call.
+	l1.fail = true
+	if _, _, err := r.NextData(ctx); err == nil || err == io.EOF {
+		t.Fatalf("retrieval did not fail: %v", err)
+	}
+	l1.fail = false
+	for _, want := range [][]byte{{1}, {2}} {
+		data, l1Block, err := r.NextData(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, want) || l1Block != b1.NumberU64() {
+			t.Errorf("NextData = %x from block %d, want %x from block %d", data, l1Block, want, b1.NumberU64())
+		}
+	}
+	// The data of the next block is only read once the traversal advances.
+	if _, _, err := r.NextData(ctx); err != io.EOF {
+		t.Fatalf("read past the current L1 block: %v", err)
+	}
+	if _, err := tr.AdvanceL1Block(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if data, l1Block, err := r.NextData(ctx); err != nil || !bytes.Equal(data, []byte{3}) || l1Block != 2 {
+		t.Errorf("NextData = %x, %d, %v, want data of block 2", data, l1Block, err)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/sync/singleflight"
+)
+
+// Sizes of the L1 source caches. Blocks and receipts are cached for the depth of
+// reorgs that the L1 tracker can unwind, as derivation reads them again after a
+// reset.
+const (
+	l1HeaderCacheSize   = 4 * maxReorgDepth
+	l1BlockCacheSize    = maxReorgDepth
+	l1ReceiptsCacheSize = maxReorgDepth
+)
+
+const (
+	// maxReceiptRequests is the maximum number of concurrent receipt requests
+	// to the L1 node.
+	maxReceiptRequests = 16
+
+	// maxReceiptPrefetches is the maximum number of L1 blocks whose receipts
+	// are prefetched at the same time.
+	maxReceiptPrefetches = 8
+
+	// l1RequestTimeout is the time after which a request to the L1 node is
+	// abandoned. Requests are shared by all callers that wait for the same
+	// data, so they do not run under the context of any of them.
+	l1RequestTimeout = time.Minute
+)
+
+// L1Client is the L1 API that the L1 source reads from. It is implemented by
+// ethclient.Client.
+type L1Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// BlockReceiptsClient is implemented by L1 clients that can fetch all receipts
+// of a block in a single request, like ethclient.Client with nodes that serve
+// eth_getBlockReceipts.
+type BlockReceiptsClient interface {
+	BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error)
+}
+
+// L1Source is an L1Fetcher that caches the headers, blocks and receipts of L1
+// blocks by hash, so that derivation over the same L1 blocks, such as after a
+// reset of the pipeline, does not request them from the L1 node again.
+// Concurrent requests for the same data are coalesced into a single request.
+//
+// Lookups by number are always forwarded to the L1 client, since the canonical
+// block of a number changes with reorgs.
+//
+// The receipts of a block are fetched in a single request if the client
+// implements BlockReceiptsClient and the L1 node supports it, and with
+// concurrent requests per transaction otherwise.
+type L1Source struct {
+	client L1Client
+
+	headers  *lru.Cache // hash -> *types.Header
+	blocks   *lru.Cache // hash -> *types.Block
+	receipts *lru.Cache // hash -> []*types.Receipt
+
+	requests   singleflight.Group
+	slots      chan struct{} // bounds the concurrent receipt requests
+	prefetches int32         // number of running receipt prefetches
+	noBlockRPC int32         // set if the L1 node lacks eth_getBlockReceipts
+}
+
+// NewL1Source creates a caching L1 source on top of the given client.
+func NewL1Source(client L1Client) *L1Source {
+	headers, _ := lru.New(l1HeaderCacheSize)
+	blocks, _ := lru.New(l1BlockCacheSize)
+	receipts, _ := lru.New(l1ReceiptsCacheSize)
+	return &L1Source{
+		client:   client,
+		headers:  headers,
+		blocks:   blocks,
+		receipts: receipts,
+		slots:    make(chan struct{}, maxReceiptRequests),
+	}
+}
+
+// HeaderByNumber fetches the header of the canonical L1 block with the given
+// number, or the latest block if number is nil.
+func (s *L1Source) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header, err := s.client.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	s.headers.Add(header.Hash(), header)
+	return header, nil
+}
+
+// HeaderByHash returns the header of the L1 block with the given hash.
+func (s *L1Source) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if header, ok := s.headers.Get(hash); ok {
+		return header.(*types.Header), nil
+	}
+	header, err := s.do(ctx, "header:"+hash.Hex(), func(ctx context.Context) (interface{}, error) {
+		header, err := s.client.HeaderByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		s.headers.Add(hash, header)
+		return header, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return header.(*types.Header), nil
+}
+
+// BlockByHash returns the L1 block with the given hash.
+func (s *L1Source) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if block, ok := s.blocks.Get(hash); ok {
+		return block.(*types.Block), nil
+	}
+	block, err := s.do(ctx, "block:"+hash.Hex(), func(ctx context.Context) (interface{}, error) {
+		block, err := s.client.BlockByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		s.blocks.Add(hash, block)
+		s.headers.Add(hash, block.Header())
+		return block, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block.(*types.Block), nil
+}
+
+// Receipts returns the receipts of the transactions of the L1 block with the
+// given hash.
+func (s *L1Source) Receipts(ctx context.Context, hash common.Hash) ([]*types.Receipt, error) {
+	if receipts, ok := s.receipts.Get(hash); ok {
+		return receipts.([]*types.Receipt), nil
+	}
+	receipts, err := s.do(ctx, "receipts:"+hash.Hex(), func(ctx context.Context) (interface{}, error) {
+		block, err := s.BlockByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		receipts, err := s.fetchReceipts(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		s.receipts.Add(hash, receipts)
+		return receipts, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return receipts.([]*types.Receipt), nil
+}
+
+// do runs the request with the given key, unless the same request is running
+// already, and waits for its result until the context is done. The request
+// runs detached from the context, with its own timeout, so that the caller
+// that started it cannot cancel it for the others.
+func (s *L1Source) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	ch := s.requests.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), l1RequestTimeout)
+		defer cancel()
+		return fn(ctx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PrefetchReceipts starts fetching the receipts of the L1 block with the given
+// hash in the background, so that a later call to Receipts finds them cached or
+// waits for the running request. Prefetches beyond the limit of concurrent
+// prefetches are skipped.
+func (s *L1Source) PrefetchReceipts(hash common.Hash) {
+	if _, ok := s.receipts.Get(hash); ok {
+		return
+	}
+	if atomic.AddInt32(&s.prefetches, 1) > maxReceiptPrefetches {
+		atomic.AddInt32(&s.prefetches, -1)
+		return
+	}
+	go func() {
+		defer atomic.AddInt32(&s.prefetches, -1)
+		s.Receipts(context.Background(), hash)
+	}()
+}
+
+// fetchReceipts fetches the receipts of the transactions of the block, and
+// checks that they belong to it.
+func (s *L1Source) fetchReceipts(ctx context.Context, block *types.Block) ([]*types.Receipt, error) {
+	var (
+		hash = block.Hash()
+		txs  = block.Transactions()
+	)
+	if receipts, err := s.fetchBlockReceipts(ctx, hash); err != errNoBlockReceipts {
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch block receipts: %w", err)
+		}
+		if len(receipts) != len(txs) {
+			return nil, fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(txs))
+		}
+		for i, receipt := range receipts {
+			if receipt.TxHash != txs[i].Hash() || receipt.BlockHash != hash {
+				return nil, fmt.Errorf("receipt %d of transaction %s in block %s does not match the block", i, receipt.TxHash, receipt.BlockHash)
+			}
+		}
+		return receipts, nil
+	}
+	// Fall back to a request per transaction.
+	var (
+		receipts = make([]*types.Receipt, len(txs))
+		errs     = make([]error, len(txs))
+		wg       sync.WaitGroup
+	)
+	for i, tx := range txs {
+		if err := s.acquireSlot(ctx); err != nil {
+			errs[i] = err
+			break
+		}
+		wg.Add(1)
+		go func(i int, tx *types.Transaction) {
+			defer func() { <-s.slots; wg.Done() }()
+
+			receipt, err := s.client.TransactionReceipt(ctx, tx.Hash())
+			switch {
+			case err != nil:
+				errs[i] = fmt.Errorf("failed to fetch receipt of transaction %s: %w", tx.Hash(), err)
+			case receipt.BlockHash != hash:
+				// The receipt of a transaction that was reorged out of the
+				// block belongs to another block.
+				errs[i] = fmt.Errorf("receipt of transaction %s is from block %s, not %s", tx.Hash(), receipt.BlockHash, hash)
+			default:
+				receipts[i] = receipt
+			}
+		}(i, tx)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return receipts, nil
+}
+
+// errNoBlockReceipts is returned if the receipts of a block cannot be fetched
+// in a single request.
+var errNoBlockReceipts = errors.New("block receipts not supported")
+
+// methodNotFoundCode is the JSON-RPC error code of unknown methods.
+const methodNotFoundCode = -32601
+
+// fetchBlockReceipts fetches the receipts of the block with the given hash in a
+// single request. Once the L1 node turned out not to support it, it is not
+// tried again.
+func (s *L1Source) fetchBlockReceipts(ctx context.Context, hash common.Hash) ([]*types.Receipt, error) {
+	client, ok := s.client.(BlockReceiptsClient)
+	if !ok || atomic.LoadInt32(&s.noBlockRPC) != 0 {
+		return nil, errNoBlockReceipts
+	}
+	if err := s.acquireSlot(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { <-s.slots }()
+
+	receipts, err := client.BlockReceipts(ctx, rpc.BlockNumberOrHashWithHash(hash, false))
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == methodNotFoundCode {
+		atomic.StoreInt32(&s.noBlockRPC, 1)
+		return nil, errNoBlockReceipts
+	}
+	return receipts, err
+}
+
+// acquireSlot waits for a free slot for a receipt request, until the context is
+// done. The slot is released by receiving from s.slots.
+func (s *L1Source) acquireSlot(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
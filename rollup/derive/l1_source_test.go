@@ -0,0 +1,260 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// countingL1Client serves an L1 chain and counts the requests by method.
+type countingL1Client struct {
+	*testutils.L1Chain
+	headers, blocks, receipts int32
+	unblock                   chan struct{} // if set, block fetches wait for it
+}
+
+func (c *countingL1Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	atomic.AddInt32(&c.headers, 1)
+	block, err := c.L1Chain.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (c *countingL1Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	atomic.AddInt32(&c.blocks, 1)
+	if c.unblock != nil {
+		select {
+		case <-c.unblock:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return c.L1Chain.BlockByHash(ctx, hash)
+}
+
+func (c *countingL1Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	atomic.AddInt32(&c.receipts, 1)
+	for n := uint64(0); n <= c.Head().NumberU64(); n++ {
+		block := c.Block(n)
+		for i, tx := range block.Transactions() {
+			if tx.Hash() == txHash {
+				return &types.Receipt{TxHash: txHash, BlockHash: block.Hash(), BlockNumber: block.Number(), TransactionIndex: uint(i)}, nil
+			}
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func TestL1SourceCaches(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		client = &countingL1Client{L1Chain: testutils.NewL1Chain()}
+		source = NewL1Source(client)
+	)
+	block := client.AddBlock(types.NewTx(&types.LegacyTx{Nonce: 0}), types.NewTx(&types.LegacyTx{Nonce: 1}))
+
+	for i := 0; i < 2; i++ {
+		receipts, err := source.Receipts(ctx, block.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(receipts) != 2 || receipts[1].TxHash != block.Transactions()[1].Hash() {
+			t.Fatalf("wrong receipts %+v", receipts)
+		}
+		if _, err := source.BlockByHash(ctx, block.Hash()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := source.HeaderByHash(ctx, block.Hash()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.blocks != 1 || client.receipts != 2 || client.headers != 0 {
+		t.Fatalf("unexpected requests: %d blocks, %d receipts, %d headers", client.blocks, client.receipts, client.headers)
+	}
+	// Headers fetched by number are cached by hash.
+	header, err := source.HeaderByNumber(ctx, big.NewInt(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.HeaderByHash(ctx, header.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if client.headers != 0 {
+		t.Fatalf("header fetched by hash after fetching it by number")
+	}
+}
+
+func TestL1SourceCoalescesRequests(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		client = &countingL1Client{L1Chain: testutils.NewL1Chain(), unblock: make(chan struct{})}
+		source = NewL1Source(client)
+		hash   = client.AddBlock().Hash()
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if block, err := source.BlockByHash(ctx, hash); err != nil || block.Hash() != hash {
+				t.Errorf("wrong block: %v", err)
+			}
+		}()
+	}
+	// Give all requests time to queue up behind the first one.
+	time.Sleep(50 * time.Millisecond)
+	close(client.unblock)
+	wg.Wait()
+	if n := atomic.LoadInt32(&client.blocks); n != 1 {
+		t.Fatalf("%d block requests, want 1", n)
+	}
+}
+
+// Tests that a caller that gives up on a shared request does not cancel it for
+// the other callers that wait for it.
+func TestL1SourceSharedRequestOutlivesCaller(t *testing.T) {
+	var (
+		client = &countingL1Client{L1Chain: testutils.NewL1Chain(), unblock: make(chan struct{})}
+		source = NewL1Source(client)
+		hash   = client.AddBlock().Hash()
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := source.BlockByHash(ctx, hash)
+		first <- err
+	}()
+	second := make(chan error, 1)
+	go func() {
+		block, err := source.BlockByHash(context.Background(), hash)
+		if err == nil && block.Hash() != hash {
+			err = errors.New("wrong block")
+		}
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled caller: have %v, want %v", err, context.Canceled)
+	}
+	close(client.unblock)
+	if err := <-second; err != nil {
+		t.Fatalf("waiting caller failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&client.blocks); n != 1 {
+		t.Fatalf("%d block requests, want 1", n)
+	}
+}
+
+// blockReceiptsClient additionally serves the receipts of whole blocks, or
+// fails as an L1 node without eth_getBlockReceipts if unsupported is set.
+type blockReceiptsClient struct {
+	*countingL1Client
+	blockReceipts int32
+	unsupported   bool
+}
+
+type methodNotFoundError struct{}
+
+func (methodNotFoundError) Error() string {
+	return "the method eth_getBlockReceipts does not exist/is not available"
+}
+func (methodNotFoundError) ErrorCode() int { return -32601 }
+
+func (c *blockReceiptsClient) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	atomic.AddInt32(&c.blockReceipts, 1)
+	if c.unsupported {
+		return nil, methodNotFoundError{}
+	}
+	hash, _ := blockNrOrHash.Hash()
+	block, err := c.L1Chain.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	var receipts []*types.Receipt
+	for i, tx := range block.Transactions() {
+		receipts = append(receipts, &types.Receipt{TxHash: tx.Hash(), BlockHash: hash, BlockNumber: block.Number(), TransactionIndex: uint(i)})
+	}
+	return receipts, nil
+}
+
+func TestL1SourceBlockReceipts(t *testing.T) {
+	for _, unsupported := range []bool{false, true} {
+		var (
+			ctx    = context.Background()
+			client = &blockReceiptsClient{countingL1Client: &countingL1Client{L1Chain: testutils.NewL1Chain()}, unsupported: unsupported}
+			source = NewL1Source(client)
+		)
+		var blocks []*types.Block
+		for i := 0; i < 2; i++ {
+			blocks = append(blocks, client.AddBlock(types.NewTx(&types.LegacyTx{Nonce: uint64(2 * i)}), types.NewTx(&types.LegacyTx{Nonce: uint64(2*i + 1)})))
+		}
+		for _, block := range blocks {
+			receipts, err := source.Receipts(ctx, block.Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(receipts) != 2 || receipts[0].TxHash != block.Transactions()[0].Hash() || receipts[1].TxHash != block.Transactions()[1].Hash() {
+				t.Fatalf("wrong receipts %+v", receipts)
+			}
+		}
+		// Without block receipts, the source falls back to a request per
+		// transaction and does not try block receipts again.
+		if unsupported && (client.blockReceipts != 1 || client.receipts != 4) {
+			t.Errorf("unsupported block receipts: %d block requests, %d receipt requests", client.blockReceipts, client.receipts)
+		}
+		if !unsupported && (client.blockReceipts != 2 || client.receipts != 0) {
+			t.Errorf("supported block receipts: %d block requests, %d receipt requests", client.blockReceipts, client.receipts)
+		}
+	}
+}
+
+func TestL1SourcePrefetchReceipts(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		client = &countingL1Client{L1Chain: testutils.NewL1Chain(), unblock: make(chan struct{})}
+		source = NewL1Source(client)
+		block  = client.AddBlock(types.NewTx(&types.LegacyTx{Nonce: 0}), types.NewTx(&types.LegacyTx{Nonce: 1}), types.NewTx(&types.LegacyTx{Nonce: 2}))
+	)
+	source.PrefetchReceipts(block.Hash())
+	// The request waits for the running prefetch.
+	time.Sleep(50 * time.Millisecond)
+	close(client.unblock)
+	receipts, err := source.Receipts(ctx, block.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 3 || receipts[2].TxHash != block.Transactions()[2].Hash() {
+		t.Fatalf("wrong receipts %+v", receipts)
+	}
+	if n := atomic.LoadInt32(&client.receipts); n != 3 {
+		t.Fatalf("%d receipt requests, want 3", n)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// maxReorgDepth is the number of traversed L1 blocks that the tracker remembers.
+// Reorgs that are deeper than this cannot be unwound block by block.
+const maxReorgDepth = 256
+
+var (
+	// ErrReorg is returned by the L1 tracker when the next L1 block does not
+	// build on the last traversed block.
+	ErrReorg = errors.New("L1 reorg detected")
+	// ErrDeepReorg is returned when none of the remembered L1 blocks is still
+	// canonical.
+	ErrDeepReorg = errors.New("L1 reorg deeper than the tracked window")
+)
+
+// L1Tracker traverses the L1 chain block by block and detects reorgs of the
+// blocks it has traversed already.
+type L1Tracker struct {
+	l1   L1Fetcher
+	refs []rollup.L1BlockRef // traversed blocks, oldest first
+}
+
+// NewL1Tracker creates a tracker that continues the traversal after the given
+// L1 block. Only the hash and number of the start block are used.
+func NewL1Tracker(l1 L1Fetcher, start rollup.L1BlockRef) *L1Tracker {
+	return &L1Tracker{l1: l1, refs: []rollup.L1BlockRef{start}}
+}
+
+// Head returns the last traversed L1 block.
+func (t *L1Tracker) Head() rollup.L1BlockRef {
+	return t.refs[len(t.refs)-1]
+}
+
+// Ref returns the traversed block with the given number, if it is still
+// remembered.
+func (t *L1Tracker) Ref(number uint64) (rollup.L1BlockRef, bool) {
+	first := t.refs[0].Number
+	if number < first || number-first >= uint64(len(t.refs)) {
+		return rollup.L1BlockRef{}, false
+	}
+	return t.refs[number-first], true
+}
+
+// Reset restarts the traversal after the given L1 block.
+func (t *L1Tracker) Reset(start rollup.L1BlockRef) {
+	t.refs = append(t.refs[:0], start)
+}
+
+// Next fetches the L1 block after the head and makes it the new head. It
+// returns io.EOF if the block does not exist yet, and ErrReorg if it does not
+// build on the head.
+func (t *L1Tracker) Next(ctx context.Context) (*types.Header, error) {
+	head := t.Head()
+	header, err := t.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(head.Number+1))
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 header %d: %w", head.Number+1, err)
+	}
+	if header.ParentHash != head.Hash {
+		return nil, fmt.Errorf("%w: L1 block %d has parent %s, expected %s", ErrReorg, head.Number+1, header.ParentHash, head.Hash)
+	}
+	t.refs = append(t.refs, rollup.L1BlockRefFromHeader(header))
+	if len(t.refs) > maxReorgDepth {
+		t.refs = t.refs[len(t.refs)-maxReorgDepth:]
+	}
+	return header, nil
+}
+
+// undo makes the parent of the head the head again, for a traversal that failed
+// to process the head.
+func (t *L1Tracker) undo() {
+	if len(t.refs) > 1 {
+		t.refs = t.refs[:len(t.refs)-1]
+	} else {
+		head := t.refs[0]
+		t.refs[0] = rollup.L1BlockRef{Hash: head.ParentHash, Number: head.Number - 1}
+	}
+}
+
+// FindCommonAncestor walks back the traversed blocks until it finds one that is
+// still canonical, and makes it the new head. It returns ErrDeepReorg if none
+// of the remembered blocks is canonical anymore.
+func (t *L1Tracker) FindCommonAncestor(ctx context.Context) (rollup.L1BlockRef, error) {
+	for i := len(t.refs) - 1; i >= 0; i-- {
+		ref := t.refs[i]
+		header, err := t.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(ref.Number))
+		if errors.Is(err, ethereum.NotFound) {
+			continue // the new chain is shorter
+		} else if err != nil {
+			return rollup.L1BlockRef{}, fmt.Errorf("failed to fetch L1 header %d: %w", ref.Number, err)
+		}
+		if header.Hash() == ref.Hash {
+			t.refs = t.refs[:i+1]
+			return ref, nil
+		}
+	}
+	return rollup.L1BlockRef{}, ErrDeepReorg
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+// traverse advances the tracker to the head of the L1 chain.
+func traverse(t *testing.T, tracker *L1Tracker) error {
+	t.Helper()
+	for {
+		if _, err := tracker.Next(context.Background()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestL1TrackerShallowReorg(t *testing.T) {
+	l1 := testutils.NewL1Chain()
+	for i := 0; i < 5; i++ {
+		l1.AddBlock()
+	}
+	tracker := NewL1Tracker(l1, rollup.L1BlockRefFromHeader(l1.Block(0).Header()))
+	if err := traverse(t, tracker); err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Head().Hash != l1.Head().Hash() {
+		t.Fatalf("tracker head %v, want %d", tracker.Head(), l1.Head().NumberU64())
+	}
+
+	// Replace the last two blocks with a longer fork.
+	ancestor := l1.Block(3)
+	l1.Reorg(2)
+	for i := 0; i < 3; i++ {
+		l1.AddBlock()
+	}
+	if err := traverse(t, tracker); !errors.Is(err, ErrReorg) {
+		t.Fatalf("expected reorg error, got %v", err)
+	}
+	ref, err := tracker.FindCommonAncestor(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash != ancestor.Hash() || tracker.Head() != ref {
+		t.Fatalf("common ancestor %v, want %d", ref, ancestor.NumberU64())
+	}
+	// The traversal continues on the new fork.
+	if err := traverse(t, tracker); err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Head().Hash != l1.Head().Hash() {
+		t.Fatalf("tracker head %v not on the new fork", tracker.Head())
+	}
+}
+
+func TestL1TrackerReorgToShorterChain(t *testing.T) {
+	l1 := testutils.NewL1Chain()
+	for i := 0; i < 5; i++ {
+		l1.AddBlock()
+	}
+	tracker := NewL1Tracker(l1, rollup.L1BlockRefFromHeader(l1.Block(0).Header()))
+	if err := traverse(t, tracker); err != nil {
+		t.Fatal(err)
+	}
+	l1.Reorg(3)
+	l1.AddBlock()
+
+	ref, err := tracker.FindCommonAncestor(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Number != 2 || ref.Hash != l1.Block(2).Hash() {
+		t.Fatalf("common ancestor %v, want 2", ref)
+	}
+}
+
+func TestL1TrackerDeepReorg(t *testing.T) {
+	l1 := testutils.NewL1Chain()
+	for i := 0; i < 5; i++ {
+		l1.AddBlock()
+	}
+	// Start tracking after block 2, so that nothing before it is remembered.
+	tracker := NewL1Tracker(l1, rollup.L1BlockRefFromHeader(l1.Block(2).Header()))
+	if err := traverse(t, tracker); err != nil {
+		t.Fatal(err)
+	}
+	l1.Reorg(4)
+	for i := 0; i < 5; i++ {
+		l1.AddBlock()
+	}
+	if _, err := tracker.FindCommonAncestor(context.Background()); !errors.Is(err, ErrDeepReorg) {
+		t.Fatalf("expected deep reorg error, got %v", err)
+	}
+}
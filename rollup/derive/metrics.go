@@ -0,0 +1,37 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the derivation pipeline.
+
+package derive
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	derivedBlockMeter  = metrics.NewRegisteredMeter("rollup/derive/blocks", nil)
+	droppedBatchMeter  = metrics.NewRegisteredMeter("rollup/derive/batches/dropped", nil)
+	droppedChanMeter   = metrics.NewRegisteredMeter("rollup/derive/channels/dropped", nil)
+	droppedFrameMeter  = metrics.NewRegisteredMeter("rollup/derive/frames/dropped", nil)
+	emptyBatchMeter    = metrics.NewRegisteredMeter("rollup/derive/batches/empty", nil)
+	consolidatedMeter  = metrics.NewRegisteredMeter("rollup/derive/blocks/consolidated", nil)
+	l1ReorgMeter       = metrics.NewRegisteredMeter("rollup/derive/l1/reorgs", nil)
+	l1OriginLagGauge   = metrics.NewRegisteredGauge("rollup/derive/l1/originlag", nil)
+	safeHeadGauge      = metrics.NewRegisteredGauge("rollup/derive/head/safe", nil)
+	finalizedHeadGauge = metrics.NewRegisteredGauge("rollup/derive/head/finalized", nil)
+	derivedHeadGauge   = metrics.NewRegisteredGauge("rollup/derive/head/derived", nil)
+)
@@ -0,0 +1,588 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package derive implements the derivation of the L2 chain from L1: batches are
+// read from the batch inbox, turned into payload attributes and inserted into
+// the execution engine through the Engine API.
+package derive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// L1Fetcher provides access to the L1 chain. It is implemented by L1Source. The
+// methods return ethereum.NotFound if the requested block does not exist.
+type L1Fetcher interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	Receipts(ctx context.Context, hash common.Hash) ([]*types.Receipt, error)
+}
+
+// The stages of the pipeline pull their input from the previous stage, one item
+// at a time, and buffer only what they decoded from a single input item. They
+// return io.EOF once the previous stage ran out of data, in which case the
+// pipeline advances the L1 traversal to the next block.
+type (
+	l1BlockProvider interface {
+		NextL1Block(ctx context.Context) (*types.Header, error)
+	}
+	dataProvider interface {
+		NextData(ctx context.Context) ([]byte, uint64, error)
+	}
+	frameProvider interface {
+		NextFrame(ctx context.Context) (*Frame, uint64, error)
+	}
+	batchProvider interface {
+		NextBatch(ctx context.Context) (*BatchData, uint64, error)
+	}
+)
+
+// UnsafeBlocks provides the unsafe L2 blocks that the engine imported ahead of
+// the derived head. Derivation consolidates with them: an unsafe block whose
+// attributes match the derived ones becomes the derived block as it is, without
+// building it again.
+type UnsafeBlocks interface {
+	// UnsafePayload returns the imported unsafe payload with the given
+	// number, nil if there is none.
+	UnsafePayload(number uint64) *beacon.ExecutableDataV1
+}
+
+// receiptsPrefetcher is implemented by L1 fetchers that can fetch receipts in
+// the background, like L1Source.
+type receiptsPrefetcher interface {
+	PrefetchReceipts(hash common.Hash)
+}
+
+// Confirmations control when derived L2 blocks are labelled safe and finalized.
+type Confirmations struct {
+	// SafeDepth is the number of L1 blocks on top of the L1 block that a
+	// derived block's batch was read from, before the block is safe.
+	SafeDepth uint64
+	// FinalityDepth is the number of L1 blocks on top of the L1 block that a
+	// derived block's batch was read from, before the block is finalized. If
+	// zero, blocks are finalized along with their L1 blocks, as reported by
+	// the finality of the L1 beacon chain.
+	FinalityDepth uint64
+}
+
+// Pipeline derives L2 blocks from the L1 chain, one step at a time. It keeps
+// track of the derived L2 head: the last L2 block that was derived from L1.
+//
+// The data flows through a chain of stages: the L1 traversal hands out the L1
+// blocks, the L1 retrieval reads their batch inbox data, the frame queue
+// decodes the data into frames, the channel reader reassembles the frames into
+// channels and reads their batches, and the batch queue holds the batches until
+// they can be turned into the payload attributes of the next block. Each stage
+// only pulls from the previous one when it needs more input, so the amount of
+// buffered data stays bounded no matter how far behind L1 the pipeline is.
+// Derived blocks become safe, and later finalized, once the L1 blocks they
+// were derived from are deep enough in the L1 chain.
+//
+// When the L1 chain reorgs, the pipeline unwinds the head to the last block
+// that was derived from L1 blocks that are still canonical, and derives the
+// chain again from there. If the reorg is too deep for that, it starts over from
+// the finalized L2 block.
+//
+// Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	cfg     *rollup.Config
+	conf    Confirmations
+	l1      L1Fetcher
+	tracker *L1Tracker // L1 blocks that batches were read from
+	sysCfg  *SystemConfigTracker
+	engine  Engine
+	log     log.Logger
+
+	head      rollup.L2BlockRef
+	safe      rollup.L2BlockRef
+	finalized rollup.L2BlockRef
+
+	traversal *L1Traversal
+	retrieval *L1Retrieval
+	frames    *FrameQueue
+	channels  *ChannelBank   // channels whose frames were partially read
+	reader    *ChannelReader // batches of the completed channels
+	queue     *BatchQueue    // batches that were read but not derived yet
+
+	history  []derivedBlock // recently derived blocks, oldest first
+	deposits *depositSet    // deposits of the derived blocks in the history
+
+	tracer *Tracer    // records the steps if set
+	step   *StepTrace // trace of the step in progress
+
+	index *DepositIndex // indexes the deposits of the derived blocks if set
+
+	unsafe UnsafeBlocks // unsafe blocks to consolidate with if set
+}
+
+// derivedBlock is a derived L2 block, along with the last L1 block that had
+// been read when it was derived.
+type derivedBlock struct {
+	ref     rollup.L2BlockRef
+	l1Block uint64
+}
+
+// derivedFrom returns the L1 block that the block counts as derived from when
+// it is labeled: the block cannot be safe or finalized before its L1 origin
+// is, even if its batch was read earlier.
+func (b derivedBlock) derivedFrom() uint64 {
+	if b.ref.L1Origin.Number > b.l1Block {
+		return b.ref.L1Origin.Number
+	}
+	return b.l1Block
+}
+
+// NewPipeline creates a pipeline that derives the blocks after the given safe
+// L2 head. The batch data is read from the data availability source of the
+// rollup configuration. If that source is unknown, derivation fails.
+func NewPipeline(cfg *rollup.Config, conf Confirmations, l1 L1Fetcher, engine Engine, safeHead rollup.L2BlockRef, logger log.Logger) *Pipeline {
+	var da DataAvailabilitySource
+	if ctor, err := LookupDataAvailabilitySource(cfg.DataAvailability); err != nil {
+		da = unknownDataSource{err}
+	} else {
+		da = ctor(cfg, l1, logger)
+	}
+	p := &Pipeline{
+		cfg:       cfg,
+		conf:      conf,
+		l1:        l1,
+		sysCfg:    NewSystemConfigTracker(cfg, l1, logger),
+		engine:    engine,
+		log:       logger,
+		finalized: cfg.L2GenesisRef(),
+		channels:  newChannelBank(cfg, logger),
+		deposits:  newDepositSet(),
+	}
+	p.traversal = NewL1Traversal(nil)
+	p.retrieval = NewL1Retrieval(l1, da, p.sysCfg, p.traversal, logger)
+	p.frames = NewFrameQueue(p.retrieval, logger)
+	p.reader = NewChannelReader(p.frames, p.channels, logger)
+	p.queue = NewBatchQueue(cfg, l1, p.reader, logger)
+	p.resetTo(safeHead)
+	return p
+}
+
+// Head returns the last L2 block that was derived.
+func (p *Pipeline) Head() rollup.L2BlockRef {
+	return p.head
+}
+
+// SafeHead returns the last derived L2 block whose L1 data is confirmed deep
+// enough to be unlikely to be reorged.
+func (p *Pipeline) SafeHead() rollup.L2BlockRef {
+	return p.safe
+}
+
+// Finalized returns the last L2 block that can no longer be reorged.
+func (p *Pipeline) Finalized() rollup.L2BlockRef {
+	return p.finalized
+}
+
+// SetFinalized sets the finalized block, which the pipeline starts over from
+// after a deep L1 reorg. It is used to resume the derivation of a previous run.
+func (p *Pipeline) SetFinalized(finalized rollup.L2BlockRef) {
+	p.finalized = finalized
+}
+
+// SafeL1 returns the L1 block that the safe head counts as derived from. The
+// safe head is finalized once that block is. It is persisted along with the
+// safe head, to resume with SetSafeL1.
+func (p *Pipeline) SafeL1() uint64 {
+	for i := len(p.history) - 1; i >= 0; i-- {
+		if p.history[i].ref == p.safe {
+			return p.history[i].derivedFrom()
+		}
+	}
+	return p.safe.L1Origin.Number
+}
+
+// SetSafeL1 sets the L1 block that the safe head was derived from in a
+// previous run. Otherwise, a resumed safe head counts as derived from its L1
+// origin, and may be finalized before the L1 block that its batch was read
+// from.
+func (p *Pipeline) SetSafeL1(l1Block uint64) {
+	for i := range p.history {
+		if p.history[i].ref == p.safe && p.history[i].l1Block < l1Block {
+			p.history[i].l1Block = l1Block
+		}
+	}
+}
+
+// Reset restarts the derivation after the given block, which becomes the safe
+// and finalized block. It is used when the engine synced the chain by itself,
+// without derivation.
+func (p *Pipeline) Reset(head rollup.L2BlockRef) {
+	p.resetTo(head)
+	p.finalized = head
+}
+
+// ResetToSafeHead discards the buffered frames, channels and batches and the
+// blocks derived after the safe head, and restarts the derivation after the
+// safe head, at its L1 origin. The finalized block is kept.
+func (p *Pipeline) ResetToSafeHead() {
+	safeL1 := p.SafeL1()
+	p.resetTo(p.safe)
+	p.SetSafeL1(safeL1)
+}
+
+// CurrentL1 returns the last L1 block that batches were read from.
+func (p *Pipeline) CurrentL1() rollup.L1BlockRef {
+	return p.tracker.Head()
+}
+
+// SetTracer makes the pipeline record its steps in the given tracer. A nil
+// tracer disables tracing.
+func (p *Pipeline) SetTracer(t *Tracer) {
+	p.tracer = t
+}
+
+// SetSystemConfigTracker makes the pipeline look up the system config with the
+// given tracker, which may be shared with other users of the config.
+func (p *Pipeline) SetSystemConfigTracker(t *SystemConfigTracker) {
+	p.sysCfg = t
+	p.retrieval.sysCfg = t
+}
+
+// SetUnsafeBlocks makes the pipeline consolidate the derived blocks with the
+// given unsafe blocks. A nil source makes it build every derived block.
+func (p *Pipeline) SetUnsafeBlocks(unsafe UnsafeBlocks) {
+	p.unsafe = unsafe
+}
+
+// SetDepositIndex makes the pipeline index the deposits of the blocks it
+// derives. The deposits of the blocks after the current head are removed from
+// the index.
+func (p *Pipeline) SetDepositIndex(idx *DepositIndex) error {
+	p.index = idx
+	if idx == nil {
+		return nil
+	}
+	return idx.unwind(p.head.Number)
+}
+
+// Step performs a single derivation step: it either derives the next L2 block,
+// or advances the traversal to the next L1 block once the batches of the
+// traversed ones are exhausted. It returns io.EOF when there is no L1 data to
+// derive from yet. Other errors are classified as ErrTemporary, ErrReset or
+// ErrCritical.
+func (p *Pipeline) Step(ctx context.Context) error {
+	if p.tracer == nil {
+		return classify(p.doStep(ctx))
+	}
+	p.step = new(StepTrace)
+	defer func() { p.step = nil }()
+
+	err := classify(p.doStep(ctx))
+	p.step.Head = p.head
+	if err != nil && !errors.Is(err, io.EOF) {
+		if p.step.Kind == "" {
+			p.step.Kind = StepFailed
+		}
+		p.step.Error = err.Error()
+	}
+	p.tracer.record(*p.step)
+	return err
+}
+
+func (p *Pipeline) doStep(ctx context.Context) error {
+	batch, origin, err := p.queue.NextBatch(ctx, p.head, p.tracker.Head().Number)
+	if err == nil {
+		p.trace(func(s *StepTrace) { s.Kind, s.Batch = StepDerive, newBatchTrace(batch) })
+		return p.deriveBlock(ctx, batch, origin)
+	}
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+	header, err := p.traversal.AdvanceL1Block(ctx)
+	switch {
+	case errors.Is(err, ErrReorg):
+		p.trace(func(s *StepTrace) { s.Kind = StepReset })
+		p.log.Warn("Resetting derivation after L1 reorg", "err", err)
+		l1ReorgMeter.Mark(1)
+		return p.reset(ctx)
+	case errors.Is(err, io.EOF):
+		p.trace(func(s *StepTrace) { s.Kind = StepIdle })
+		return err
+	case err != nil:
+		return err
+	}
+	p.trace(func(s *StepTrace) {
+		ref := rollup.L1BlockRefFromHeader(header)
+		s.Kind, s.L1Block = StepAdvance, &ref
+	})
+	p.channels.Prune(header.Number.Uint64())
+	return nil
+}
+
+// trace updates the trace of the step in progress, if tracing is enabled.
+func (p *Pipeline) trace(update func(s *StepTrace)) {
+	if p.step != nil {
+		update(p.step)
+	}
+}
+
+// reset unwinds the head after an L1 reorg. The new head is the last derived
+// block whose batch was read from an L1 block that is still canonical.
+func (p *Pipeline) reset(ctx context.Context) error {
+	var (
+		target  = p.finalized
+		history = []derivedBlock{{ref: p.finalized, l1Block: p.finalized.L1Origin.Number}}
+	)
+	ancestor, err := p.tracker.FindCommonAncestor(ctx)
+	switch {
+	case err == nil:
+		for i := len(p.history) - 1; i >= 0; i-- {
+			if p.history[i].l1Block <= ancestor.Number {
+				target, history = p.history[i].ref, p.history[:i+1]
+				break
+			}
+		}
+	case errors.Is(err, ErrDeepReorg):
+		p.log.Warn("Deep L1 reorg, restarting from the finalized L2 block", "finalized", p.finalized)
+	default:
+		return err
+	}
+	// The safe block was unwound too if the reorg was deeper than the safe
+	// depth.
+	safe := p.safe
+	if safe.Number > target.Number {
+		safe = target
+	}
+	if err := p.forkchoiceUpdate(ctx, target, safe); err != nil {
+		return fmt.Errorf("failed to reset L2 head to %s: %w", target, err)
+	}
+	p.log.Info("Reset derived head", "old", p.head, "new", target, "l1origin", target.L1Origin)
+	p.resetTo(target)
+	p.safe = safe
+	p.history = history
+	derivedHeadGauge.Update(int64(target.Number))
+	safeHeadGauge.Update(int64(safe.Number))
+	return nil
+}
+
+// Confirm labels the derived blocks as safe and finalized, according to the
+// confirmations of the L1 blocks their batches were read from. It reports
+// whether any of the labels changed.
+func (p *Pipeline) Confirm(ctx context.Context) (bool, error) {
+	head, err := p.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return false, NewTemporaryError(fmt.Errorf("failed to fetch L1 head: %w", err))
+	}
+	var (
+		l1Head      = head.Number.Uint64()
+		l1Finalized uint64
+		hasFinality bool
+	)
+	if p.conf.FinalityDepth > 0 {
+		if l1Head >= p.conf.FinalityDepth {
+			l1Finalized, hasFinality = l1Head-p.conf.FinalityDepth, true
+		}
+	} else {
+		final, err := p.l1.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+		switch {
+		case err == nil:
+			l1Finalized, hasFinality = final.Number.Uint64(), true
+		case errors.Is(err, ethereum.NotFound):
+			// No L1 block is finalized yet.
+		default:
+			return false, NewTemporaryError(fmt.Errorf("failed to fetch finalized L1 block: %w", err))
+		}
+	}
+	if origin := p.head.L1Origin.Number; l1Head > origin {
+		l1OriginLagGauge.Update(int64(l1Head - origin))
+	} else {
+		l1OriginLagGauge.Update(0)
+	}
+	safe, finalized := p.safe, p.finalized
+	for _, b := range p.history {
+		l1Block := b.derivedFrom()
+		if b.ref.Number > safe.Number && l1Block+p.conf.SafeDepth <= l1Head {
+			safe = b.ref
+		}
+		if hasFinality && b.ref.Number > finalized.Number && l1Block <= l1Finalized {
+			finalized = b.ref
+		}
+	}
+	if safe == p.safe && finalized == p.finalized {
+		return false, nil
+	}
+	p.log.Debug("Updated L2 block labels", "safe", safe, "finalized", finalized, "l1head", l1Head)
+	p.safe, p.finalized = safe, finalized
+	safeHeadGauge.Update(int64(safe.Number))
+	finalizedHeadGauge.Update(int64(finalized.Number))
+	return true, nil
+}
+
+// ForkchoiceUpdate reports the derived head and the safe and finalized blocks
+// to the engine.
+func (p *Pipeline) ForkchoiceUpdate(ctx context.Context) error {
+	return p.forkchoiceUpdate(ctx, p.head, p.safe)
+}
+
+func (p *Pipeline) forkchoiceUpdate(ctx context.Context, head, safe rollup.L2BlockRef) error {
+	return UpdateForkchoice(ctx, p.engine, beacon.ForkchoiceStateV1{
+		HeadBlockHash:      head.Hash,
+		SafeBlockHash:      safe.Hash,
+		FinalizedBlockHash: p.finalized.Hash,
+	})
+}
+
+// resetTo restarts the derivation after the given safe head, with no history
+// of derived blocks before it.
+func (p *Pipeline) resetTo(safeHead rollup.L2BlockRef) {
+	p.head, p.safe = safeHead, safeHead
+	p.deposits.unwind(safeHead.Number)
+	if p.index != nil {
+		if err := p.index.unwind(safeHead.Number); err != nil {
+			p.log.Error("Failed to unwind deposit index", "number", safeHead.Number, "err", err)
+		}
+	}
+	// The batch of the next block cannot be included in L1 before the L1
+	// origin of the safe head, since it builds on top of it.
+	p.tracker = NewL1Tracker(p.l1, rollup.L1BlockRef{Hash: safeHead.L1Origin.Hash, Number: safeHead.L1Origin.Number})
+	p.traversal.Reset(p.tracker)
+	p.retrieval.Reset()
+	p.frames.Reset()
+	p.reader.Reset()
+	p.queue.Reset()
+	p.history = []derivedBlock{{ref: safeHead, l1Block: safeHead.L1Origin.Number}}
+}
+
+// deriveBlock derives the block after the safe head from the given batch, which
+// was validated against its L1 origin.
+func (p *Pipeline) deriveBlock(ctx context.Context, batch *BatchData, origin *types.Header) error {
+	seqNumber := sequenceNumber(p.head, batch.EpochNum)
+	deposits, err := EpochDeposits(ctx, p.cfg, p.l1, origin, seqNumber)
+	if err != nil {
+		return err
+	}
+	sysCfg, err := p.sysCfg.At(ctx, origin)
+	if err != nil {
+		return fmt.Errorf("failed to read system config at L1 origin %d: %w", origin.Number, err)
+	}
+	attrs, err := PayloadAttributes(p.cfg, sysCfg, origin, seqNumber, deposits, batch)
+	if err != nil {
+		p.queue.Drop(batch, err.Error())
+		p.trace(func(s *StepTrace) { s.Dropped = err.Error() })
+		if !p.cfg.StrictBatchOrdering {
+			return nil
+		}
+		// With strict ordering, no other batch can follow for this block.
+		// It is derived with only the deposits of its epoch instead.
+		batch = &BatchData{
+			ParentHash: batch.ParentHash,
+			EpochNum:   batch.EpochNum,
+			EpochHash:  batch.EpochHash,
+			Timestamp:  batch.Timestamp,
+		}
+		if attrs, err = PayloadAttributes(p.cfg, sysCfg, origin, seqNumber, deposits, batch); err != nil {
+			return NewCriticalError(fmt.Errorf("failed to derive deposit-only L2 block %d: %w", p.head.Number+1, err))
+		}
+		emptyBatchMeter.Mark(1)
+	}
+	sourceHashes, err := p.deposits.check(attrs.Transactions)
+	if err != nil {
+		return NewCriticalError(fmt.Errorf("refusing to derive L2 block %d: %w", p.head.Number+1, err))
+	}
+	fc := beacon.ForkchoiceStateV1{
+		HeadBlockHash:      p.head.Hash,
+		SafeBlockHash:      p.safe.Hash,
+		FinalizedBlockHash: p.finalized.Hash,
+	}
+	// The deposits are located before the block is inserted, so that a
+	// failure to read L1 fails the step rather than leave a gap in the index.
+	var indexed []*IndexedDeposit
+	if p.index != nil {
+		if indexed, err = p.index.prepare(ctx, origin, attrs.Transactions); err != nil {
+			return fmt.Errorf("failed to index deposits of L2 block %d: %w", p.head.Number+1, err)
+		}
+	}
+	payload, consolidated := p.consolidate(attrs)
+	if !consolidated {
+		if payload, err = InsertHeadBlock(ctx, p.engine, fc, attrs); err != nil {
+			return fmt.Errorf("failed to insert L2 block %d: %w", p.head.Number+1, err)
+		}
+	}
+	ref, err := L2BlockRefFromPayload(p.cfg, payload)
+	if err != nil {
+		return fmt.Errorf("failed to derive reference of L2 block %s: %w", payload.BlockHash, err)
+	}
+	// The index is written before the head advances, so that the block is
+	// derived again if the write fails, instead of missing in the index.
+	if p.index != nil {
+		if err := p.index.put(ref, indexed); err != nil {
+			return fmt.Errorf("failed to index deposits of L2 block %d: %w", ref.Number, err)
+		}
+	}
+	p.head = ref
+	p.deposits.add(ref.Number, sourceHashes)
+	p.recordDerived(ref)
+	derivedBlockMeter.Mark(1)
+	derivedHeadGauge.Update(int64(ref.Number))
+	if consolidated {
+		consolidatedMeter.Mark(1)
+		p.trace(func(s *StepTrace) { s.Consolidated = true })
+		p.log.Info("Consolidated unsafe L2 block", "number", ref.Number, "hash", ref.Hash, "l1origin", ref.L1Origin, "txs", len(payload.Transactions))
+	} else {
+		p.log.Info("Derived L2 block", "number", ref.Number, "hash", ref.Hash, "l1origin", ref.L1Origin, "txs", len(payload.Transactions))
+	}
+	return nil
+}
+
+// consolidate returns the unsafe payload after the derived head if it matches
+// the derived attributes. The engine already imported it, so it becomes the
+// derived block without being built again. An unsafe payload that does not
+// match is replaced by the derived block.
+func (p *Pipeline) consolidate(attrs *beacon.PayloadAttributesV1) (*beacon.ExecutableDataV1, bool) {
+	if p.unsafe == nil {
+		return nil, false
+	}
+	payload := p.unsafe.UnsafePayload(p.head.Number + 1)
+	if payload == nil {
+		return nil, false
+	}
+	if err := AttributesMatch(p.head.Hash, attrs, payload); err != nil {
+		p.log.Warn("Unsafe L2 block differs from derived block, replacing it", "number", payload.Number, "hash", payload.BlockHash, "err", err)
+		return nil, false
+	}
+	return payload, true
+}
+
+// recordDerived adds a derived block to the history, and forgets the blocks
+// that were derived from L1 blocks too old to be reorged.
+func (p *Pipeline) recordDerived(ref rollup.L2BlockRef) {
+	l1Head := p.tracker.Head().Number
+	p.history = append(p.history, derivedBlock{ref: ref, l1Block: l1Head})
+	pruned := false
+	for len(p.history) > 1 && p.history[0].l1Block+maxReorgDepth < l1Head {
+		p.history, pruned = p.history[1:], true
+	}
+	if pruned {
+		p.deposits.prune(p.history[0].ref.Number)
+	}
+}
@@ -0,0 +1,641 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+var (
+	testBatcherKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testOtherKey, _   = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	testUserKey, _    = crypto.HexToECDSA("49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee")
+)
+
+// testSetup is a derivation test environment: an L1 chain, an engine and a
+// rollup configuration that ties them together.
+type testSetup struct {
+	cfg    *rollup.Config
+	l1     *testutils.L1Chain
+	engine *testutils.Engine
+	signer types.Signer
+	nonce  uint64
+}
+
+func newTestSetup() *testSetup {
+	l1 := testutils.NewL1Chain()
+	l2Genesis := types.NewBlockWithHeader(&types.Header{
+		Number:     new(big.Int),
+		Time:       l1.Head().Time(),
+		BaseFee:    big.NewInt(1),
+		Difficulty: new(big.Int),
+	})
+	cfg := &rollup.Config{
+		Genesis: rollup.Genesis{
+			L1:     rollup.BlockID{Hash: l1.Head().Hash(), Number: 0},
+			L2:     rollup.BlockID{Hash: l2Genesis.Hash(), Number: 0},
+			L2Time: l2Genesis.Time(),
+		},
+		BlockTime:           2,
+		MaxSequencerDrift:   600,
+		SeqWindowSize:       10,
+		L1ChainID:           big.NewInt(900),
+		L2ChainID:           big.NewInt(901),
+		BatchInboxAddress:   common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:  crypto.PubkeyToAddress(testBatcherKey.PublicKey),
+		FeeRecipientAddress: SequencerFeeVaultAddr,
+	}
+	return &testSetup{
+		cfg:    cfg,
+		l1:     l1,
+		engine: testutils.NewEngine(l2Genesis),
+		signer: types.LatestSignerForChainID(cfg.L1ChainID),
+	}
+}
+
+// batchTx creates an L1 transaction that posts the given batches to the inbox.
+func (s *testSetup) batchTx(t *testing.T, batches ...*BatchData) *types.Transaction {
+	t.Helper()
+	data, err := EncodeBatches(batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.dataTx(t, data)
+}
+
+// dataTx creates an L1 transaction that posts the given data to the inbox.
+func (s *testSetup) dataTx(t *testing.T, data []byte) *types.Transaction {
+	t.Helper()
+	tx, err := types.SignNewTx(testBatcherKey, s.signer, &types.DynamicFeeTx{
+		ChainID:   s.cfg.L1ChainID,
+		Nonce:     s.nonce,
+		To:        &s.cfg.BatchInboxAddress,
+		Gas:       1_000_000,
+		GasFeeCap: big.NewInt(10),
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nonce++
+	return tx
+}
+
+// userTx creates a signed L2 transaction for inclusion in a batch.
+func userTx(t *testing.T, nonce uint64) hexutil.Bytes {
+	t.Helper()
+	to := common.HexToAddress("0x1234")
+	tx, err := types.SignNewTx(testUserKey, types.LatestSignerForChainID(big.NewInt(901)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(901),
+		Nonce:     nonce,
+		To:        &to,
+		Gas:       21000,
+		GasFeeCap: big.NewInt(10),
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := tx.MarshalBinary()
+	return enc
+}
+
+// runPipeline steps the pipeline until it runs out of L1 data.
+func runPipeline(t *testing.T, p *Pipeline) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		if err := p.Step(context.Background()); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("step %d failed: %v", i, err)
+		}
+	}
+	t.Fatal("pipeline did not run out of L1 data")
+}
+
+func TestPipelineDerivesBatches(t *testing.T) {
+	s := newTestSetup()
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+
+	// Two blocks in the genesis epoch, posted in the first L1 block.
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{
+		ParentHash:   s.cfg.Genesis.L2.Hash,
+		EpochNum:     0,
+		EpochHash:    genesisL1.Hash(),
+		Timestamp:    s.cfg.Genesis.L2Time + 2,
+		Transactions: []hexutil.Bytes{userTx(t, 0)},
+	}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+
+	head := p.Head()
+	if head.Number != 1 || head.Time != b1.Timestamp {
+		t.Fatalf("unexpected head %+v", head)
+	}
+	if head.L1Origin != s.cfg.Genesis.L1 || head.SequenceNumber != 1 {
+		t.Fatalf("unexpected L1 origin %v, sequence number %d", head.L1Origin, head.SequenceNumber)
+	}
+	block := s.engine.Blocks[head.Hash]
+	if block == nil {
+		t.Fatal("head not known to the engine")
+	}
+	if len(block.Transactions()) != 2 {
+		t.Fatalf("block has %d transactions, want 2", len(block.Transactions()))
+	}
+	if block.Transactions()[0].Type() != types.DepositTxType {
+		t.Fatal("first transaction is not the L1 info deposit")
+	}
+	if s.engine.Forkchoice.HeadBlockHash != head.Hash || s.engine.Forkchoice.SafeBlockHash != s.cfg.Genesis.L2.Hash {
+		t.Fatalf("engine forkchoice not updated: %+v", s.engine.Forkchoice)
+	}
+
+	// The next block moves to the next epoch. An unauthorized batch for the
+	// same block must be ignored.
+	epoch1 := s.l1.Block(1)
+	b2 := &BatchData{
+		ParentHash: head.Hash,
+		EpochNum:   1,
+		EpochHash:  epoch1.Hash(),
+		Timestamp:  epoch1.Time(),
+	}
+	forged, _ := EncodeBatches([]*BatchData{{ParentHash: head.Hash, EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time(), Transactions: []hexutil.Bytes{userTx(t, 1)}}})
+	forgedTx, _ := types.SignNewTx(testOtherKey, s.signer, &types.DynamicFeeTx{
+		ChainID:   s.cfg.L1ChainID,
+		To:        &s.cfg.BatchInboxAddress,
+		Gas:       1_000_000,
+		GasFeeCap: big.NewInt(10),
+		Data:      forged,
+	})
+	s.l1.AddBlock(forgedTx, s.batchTx(t, b2))
+	runPipeline(t, p)
+
+	head = p.Head()
+	if head.Number != 2 || head.L1Origin != (rollup.BlockID{Hash: epoch1.Hash(), Number: 1}) || head.SequenceNumber != 0 {
+		t.Fatalf("unexpected head %+v", head)
+	}
+	if n := len(s.engine.Blocks[head.Hash].Transactions()); n != 1 {
+		t.Fatalf("block has %d transactions, want only the L1 info deposit", n)
+	}
+}
+
+func TestPipelineDerivesDeposits(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.DepositContractAddress = testDepositContract
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+
+	// The first L1 block contains a deposit, followed by the batches of an L2
+	// block of the genesis epoch and of the first L2 block of its own epoch.
+	to := common.HexToAddress("0x1234")
+	dep := &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Mint: big.NewInt(1000), Value: new(big.Int), Gas: 50_000, Data: []byte{}}
+	depositTx := s.dataTx(t, nil)
+	epoch1 := s.l1.AddBlockWithLogs([]*types.Transaction{depositTx}, [][]*types.Log{{MarshalDepositLogEvent(testDepositContract, dep)}})
+	b1 := &BatchData{
+		ParentHash: s.cfg.Genesis.L2.Hash,
+		EpochNum:   0,
+		EpochHash:  s.cfg.Genesis.L1.Hash,
+		Timestamp:  s.cfg.Genesis.L2Time + s.cfg.BlockTime,
+	}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	b2 := &BatchData{
+		ParentHash: p.Head().Hash,
+		EpochNum:   1,
+		EpochHash:  epoch1.Hash(),
+		Timestamp:  epoch1.Time(),
+	}
+	s.l1.AddBlock(s.batchTx(t, b2))
+	runPipeline(t, p)
+
+	head := p.Head()
+	block := s.engine.Blocks[head.Hash]
+	if head.Number != 2 || block == nil {
+		t.Fatalf("unexpected head %+v", head)
+	}
+	txs := block.Transactions()
+	if len(txs) != 2 || txs[1].Type() != types.DepositTxType {
+		t.Fatalf("block has %d transactions, want the L1 info deposit and the user deposit", len(txs))
+	}
+	// The deposit was not made by the batcher that sent the L1 transaction, so
+	// it was made by a contract and its sender is aliased.
+	want := *dep
+	want.SourceHash = types.UserDepositSourceHash(epoch1.Hash(), 0)
+	want.From = ApplyL1ToL2Alias(dep.From)
+	if txs[1].Hash() != types.NewTx(&want).Hash() {
+		t.Fatalf("wrong user deposit %+v", txs[1])
+	}
+
+	// The next block of the epoch does not repeat the deposits.
+	b3 := &BatchData{
+		ParentHash: head.Hash,
+		EpochNum:   1,
+		EpochHash:  epoch1.Hash(),
+		Timestamp:  b2.Timestamp + s.cfg.BlockTime,
+	}
+	s.l1.AddBlock(s.batchTx(t, b3))
+	runPipeline(t, p)
+
+	head = p.Head()
+	if head.Number != 3 || head.SequenceNumber != 1 {
+		t.Fatalf("unexpected head %+v", head)
+	}
+	if n := len(s.engine.Blocks[head.Hash].Transactions()); n != 1 {
+		t.Fatalf("block has %d transactions, want only the L1 info deposit", n)
+	}
+}
+
+func TestPipelineDropsInvalidBatches(t *testing.T) {
+	s := newTestSetup()
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	genesisL1 := s.l1.Head()
+	next := s.cfg.Genesis.L2Time + s.cfg.BlockTime
+
+	deposit := types.NewTx(&types.DepositTx{Gas: 21000, Value: new(big.Int)})
+	depositEnc, _ := deposit.MarshalBinary()
+	invalid := []*BatchData{
+		// wrong parent
+		{ParentHash: common.HexToHash("0xdead"), EpochHash: genesisL1.Hash(), Timestamp: next},
+		// wrong epoch hash
+		{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: common.HexToHash("0xbeef"), Timestamp: next},
+		// epoch too far ahead
+		{ParentHash: s.cfg.Genesis.L2.Hash, EpochNum: 2, Timestamp: next},
+		// deposits cannot be sequenced
+		{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: next, Transactions: []hexutil.Bytes{depositEnc}},
+	}
+	s.l1.AddBlock(s.batchTx(t, invalid...))
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 0 {
+		t.Fatalf("derived block from invalid batch: %+v", head)
+	}
+	if p.queue.Len() != 0 {
+		t.Fatalf("%d invalid batches left in the queue", p.queue.Len())
+	}
+
+	// A valid batch for the same block is still accepted afterwards.
+	valid := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: next}
+	s.l1.AddBlock(s.batchTx(t, valid))
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 1 {
+		t.Fatalf("valid batch not derived, safe head %+v", head)
+	}
+}
+
+func TestPipelineShallowL1Reorg(t *testing.T) {
+	s := newTestSetup()
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	genesisL1 := s.l1.Head()
+
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	block1 := p.Head()
+
+	b2 := &BatchData{ParentHash: block1.Hash, EpochHash: genesisL1.Hash(), Timestamp: b1.Timestamp + 2}
+	s.l1.AddBlock(s.batchTx(t, b2))
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 2 {
+		t.Fatalf("unexpected head %+v", head)
+	}
+	oldBlock2 := p.Head()
+
+	// Reorg out the L1 block with the second batch, and replace it with a
+	// different batch for the same L2 block.
+	s.l1.Reorg(1)
+	b2.Transactions = []hexutil.Bytes{userTx(t, 0)}
+	s.l1.AddBlock(s.batchTx(t, b2))
+	s.l1.AddBlock()
+	runPipeline(t, p)
+
+	head := p.Head()
+	if head.Number != 2 || head.Hash == oldBlock2.Hash {
+		t.Fatalf("block 2 not re-derived: %+v", head)
+	}
+	if head.ParentHash != block1.Hash {
+		t.Fatal("block derived from canonical L1 data was unwound")
+	}
+	if n := len(s.engine.Blocks[head.Hash].Transactions()); n != 2 {
+		t.Fatalf("re-derived block has %d transactions, want 2", n)
+	}
+	if s.engine.Forkchoice.HeadBlockHash != head.Hash {
+		t.Fatalf("engine forkchoice not updated: %+v", s.engine.Forkchoice)
+	}
+}
+
+func TestPipelineDeepL1Reorg(t *testing.T) {
+	s := newTestSetup()
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	genesisL1 := s.l1.Head()
+
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	epoch1 := s.l1.Block(1)
+	b2 := &BatchData{ParentHash: p.Head().Hash, EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time()}
+	s.l1.AddBlock(s.batchTx(t, b2))
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 2 || head.L1Origin.Number != 1 {
+		t.Fatalf("unexpected head %+v", head)
+	}
+
+	// Reorg out all blocks after the L1 genesis. Everything has to be
+	// derived again from the finalized L2 genesis.
+	s.l1.Reorg(2)
+	for i := 0; i < 3; i++ {
+		s.l1.AddBlock()
+	}
+	runPipeline(t, p)
+	if head := p.Head(); head != s.cfg.L2GenesisRef() {
+		t.Fatalf("head not reset to genesis: %+v", head)
+	}
+	if s.engine.Forkchoice.HeadBlockHash != s.cfg.Genesis.L2.Hash {
+		t.Fatal("engine head not reset to genesis")
+	}
+
+	// Batches posted on the new chain are derived from genesis.
+	b1.Transactions = []hexutil.Bytes{userTx(t, 0)}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 1 || head.ParentHash != s.cfg.Genesis.L2.Hash {
+		t.Fatalf("unexpected head after reorg %+v", head)
+	}
+}
+
+func TestPipelineStrictOrdering(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.StrictBatchOrdering = true
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	genesisL1 := s.l1.Head()
+	next := s.cfg.Genesis.L2Time + s.cfg.BlockTime
+
+	// The batch for the second block precedes the one for the first block,
+	// it is dropped instead of queued.
+	b2 := &BatchData{EpochHash: genesisL1.Hash(), Timestamp: next + s.cfg.BlockTime}
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: next, Transactions: []hexutil.Bytes{userTx(t, 0)}}
+	s.l1.AddBlock(s.batchTx(t, b2, b1))
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 1 {
+		t.Fatalf("first block not derived, head %+v", head)
+	}
+	if p.queue.Len() != 0 {
+		t.Fatalf("%d batches left in the queue", p.queue.Len())
+	}
+
+	// A batch that cannot be derived is replaced by a deposit-only block.
+	deposit := types.NewTx(&types.DepositTx{Gas: 21000, Value: new(big.Int)})
+	depositEnc, _ := deposit.MarshalBinary()
+	invalid := &BatchData{ParentHash: p.Head().Hash, EpochHash: genesisL1.Hash(), Timestamp: next + s.cfg.BlockTime, Transactions: []hexutil.Bytes{depositEnc, userTx(t, 1)}}
+	s.l1.AddBlock(s.batchTx(t, invalid))
+	runPipeline(t, p)
+	head := p.Head()
+	if head.Number != 2 || head.Time != invalid.Timestamp {
+		t.Fatalf("deposit-only block not derived, head %+v", head)
+	}
+	if n := len(s.engine.Blocks[head.Hash].Transactions()); n != 1 {
+		t.Fatalf("deposit-only block has %d transactions, want only the L1 info deposit", n)
+	}
+}
+
+func TestPipelineDerivesChannels(t *testing.T) {
+	s := newTestSetup()
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	genesisL1 := s.l1.Head()
+
+	b1 := &BatchData{
+		ParentHash:   s.cfg.Genesis.L2.Hash,
+		EpochHash:    genesisL1.Hash(),
+		Timestamp:    s.cfg.Genesis.L2Time + 2,
+		Transactions: []hexutil.Bytes{userTx(t, 0), userTx(t, 1)},
+	}
+	frames, err := EncodeChannel(Zlib, []*BatchData{b1}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) < 2 {
+		t.Fatalf("channel not split into frames")
+	}
+	// Post the frames in reverse order, spread over two L1 blocks.
+	post := func(frames ...*Frame) {
+		var txs []*types.Transaction
+		for i := len(frames) - 1; i >= 0; i-- {
+			txs = append(txs, s.dataTx(t, EncodeFrames(frames[i])))
+		}
+		s.l1.AddBlock(txs...)
+	}
+	post(frames[1:]...)
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 0 {
+		t.Fatalf("derived block from incomplete channel: %+v", head)
+	}
+	post(frames[0])
+	runPipeline(t, p)
+	head := p.Head()
+	if head.Number != 1 {
+		t.Fatalf("channel not derived, head %+v", head)
+	}
+	if n := len(s.engine.Blocks[head.Hash].Transactions()); n != 3 {
+		t.Fatalf("block has %d transactions, want 3", n)
+	}
+}
+
+func TestPipelineConfirmDepth(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		s    = newTestSetup()
+		conf = Confirmations{SafeDepth: 2, FinalityDepth: 4}
+		p    = NewPipeline(s.cfg, conf, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	)
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	block1 := p.Head()
+
+	// Block 1 was read from L1 block 1, which needs two more confirmations
+	// before it is safe.
+	confirm := func(wantSafe, wantFinalized rollup.L2BlockRef) {
+		t.Helper()
+		if _, err := p.Confirm(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.ForkchoiceUpdate(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if p.SafeHead() != wantSafe || s.engine.Forkchoice.SafeBlockHash != wantSafe.Hash {
+			t.Fatalf("safe block mismatch: have %d, want %d", p.SafeHead().Number, wantSafe.Number)
+		}
+		if p.Finalized() != wantFinalized || s.engine.Forkchoice.FinalizedBlockHash != wantFinalized.Hash {
+			t.Fatalf("finalized block mismatch: have %d, want %d", p.Finalized().Number, wantFinalized.Number)
+		}
+		if s.engine.Forkchoice.HeadBlockHash != p.Head().Hash {
+			t.Fatal("engine head is not the derived head")
+		}
+	}
+	genesis := s.cfg.L2GenesisRef()
+	confirm(genesis, genesis)
+	s.l1.AddBlock()
+	confirm(genesis, genesis)
+	s.l1.AddBlock()
+	confirm(block1, genesis)
+	s.l1.AddBlock()
+	s.l1.AddBlock()
+	confirm(block1, block1)
+}
+
+func TestPipelineConfirmL1Finality(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = newTestSetup()
+		p   = NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	)
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	block1 := p.Head()
+
+	// Without a safe depth, derived blocks are safe right away, but they are
+	// only finalized along with their L1 blocks.
+	if _, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.SafeHead() != block1 || p.Finalized() != s.cfg.L2GenesisRef() {
+		t.Fatalf("unexpected labels: safe %d, finalized %d", p.SafeHead().Number, p.Finalized().Number)
+	}
+	s.l1.Finalize(1)
+	if changed, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	} else if !changed || p.Finalized() != block1 {
+		t.Fatalf("block not finalized along with L1, finalized %d", p.Finalized().Number)
+	}
+}
+
+func TestPipelineResumedSafeL1(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = newTestSetup()
+		p   = NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	)
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	if _, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	block1 := p.SafeHead()
+	if block1.Number != 1 || p.SafeL1() != 1 {
+		t.Fatalf("wrong safe head %d derived from L1 block %d", block1.Number, p.SafeL1())
+	}
+
+	// The resumed safe head has the L1 genesis as origin, but its batch was
+	// read from L1 block 1, which must be finalized first.
+	p = NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, block1, log.New())
+	p.SetSafeL1(1)
+	s.l1.Finalize(0)
+	if _, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.Finalized() != s.cfg.L2GenesisRef() {
+		t.Fatalf("resumed safe head finalized before its L1 block, finalized %d", p.Finalized().Number)
+	}
+	s.l1.Finalize(1)
+	if _, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.Finalized() != block1 {
+		t.Fatalf("resumed safe head not finalized, finalized %d", p.Finalized().Number)
+	}
+	if err := p.ForkchoiceUpdate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.engine.Forkchoice.FinalizedBlockHash != block1.Hash {
+		t.Fatal("finalized block not sent to the engine")
+	}
+}
+
+func TestPipelineResetToSafeHead(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = newTestSetup()
+		p   = NewPipeline(s.cfg, Confirmations{SafeDepth: 1}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	)
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	block1 := p.Head()
+	b2 := &BatchData{ParentHash: block1.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 4}
+	s.l1.AddBlock(s.batchTx(t, b2))
+	runPipeline(t, p)
+	block2 := p.Head()
+	if _, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.SafeHead() != block1 {
+		t.Fatalf("safe head %d, want 1", p.SafeHead().Number)
+	}
+
+	// The block after the safe head is derived again from L1.
+	p.ResetToSafeHead()
+	if p.Head() != block1 || p.SafeHead() != block1 || p.SafeL1() != 1 {
+		t.Fatalf("not reset to the safe head: head %d, safe %d, safe L1 %d", p.Head().Number, p.SafeHead().Number, p.SafeL1())
+	}
+	runPipeline(t, p)
+	if p.Head() != block2 {
+		t.Fatalf("head %d after reset, want block 2 again", p.Head().Number)
+	}
+}
+
+func TestPipelineReorgUnwindsSafe(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = newTestSetup()
+		p   = NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	)
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	s.l1.AddBlock(s.batchTx(t, b1))
+	runPipeline(t, p)
+	if _, err := p.Confirm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.SafeHead().Number != 1 {
+		t.Fatal("block not safe")
+	}
+	// A reorg deeper than the safe depth unwinds the safe block too.
+	s.l1.Reorg(1)
+	s.l1.AddBlock()
+	s.l1.AddBlock()
+	runPipeline(t, p)
+	if p.Head() != s.cfg.L2GenesisRef() || p.SafeHead() != s.cfg.L2GenesisRef() {
+		t.Fatalf("safe block not unwound: head %d, safe %d", p.Head().Number, p.SafeHead().Number)
+	}
+	if s.engine.Forkchoice.SafeBlockHash != s.cfg.Genesis.L2.Hash {
+		t.Fatal("engine safe block not unwound")
+	}
+}
@@ -0,0 +1,449 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+var (
+	// ConfigUpdateEventABIHash is the topic of the event that the system config
+	// contract emits for every update:
+	//
+	//	event ConfigUpdate(uint256 indexed version, uint8 indexed updateType, bytes data);
+	ConfigUpdateEventABIHash = crypto.Keccak256Hash([]byte("ConfigUpdate(uint256,uint8,bytes)"))
+
+	// ConfigUpdateEventVersion0 is the version of the update data layout, a
+	// single ABI-encoded word with the new value.
+	ConfigUpdateEventVersion0 = common.Hash{}
+)
+
+// Types of system config updates.
+const (
+	SystemConfigUpdateBatcher      = 0 // data is the address of the batcher
+	SystemConfigUpdateOverhead     = 1 // data is the L1 fee overhead
+	SystemConfigUpdateScalar       = 2 // data is the L1 fee scalar
+	SystemConfigUpdateGasLimit     = 3 // data is the L2 block gas limit
+	SystemConfigUpdateAddSigner    = 4 // data is an unsafe block signer to authorize
+	SystemConfigUpdateRemoveSigner = 5 // data is an unsafe block signer to revoke
+)
+
+// systemConfigDepositGas is the gas of the deposits that apply fee updates to
+// the gas price oracle.
+const systemConfigDepositGas = 50_000
+
+var (
+	setOverheadSelector = crypto.Keccak256([]byte("setOverhead(uint256)"))[:4]
+	setScalarSelector   = crypto.Keccak256([]byte("setScalar(uint256)"))[:4]
+)
+
+var errNotConfigUpdateEvent = errors.New("log is not a config update event")
+
+// UnmarshalConfigUpdateLogEvent decodes a config update event log into the type
+// of the update and its value.
+func UnmarshalConfigUpdateLogEvent(ev *types.Log) (uint8, common.Hash, error) {
+	if len(ev.Topics) != 3 {
+		return 0, common.Hash{}, fmt.Errorf("expected 3 event topics, got %d", len(ev.Topics))
+	}
+	if ev.Topics[0] != ConfigUpdateEventABIHash {
+		return 0, common.Hash{}, errNotConfigUpdateEvent
+	}
+	if version := ev.Topics[1]; version != ConfigUpdateEventVersion0 {
+		return 0, common.Hash{}, fmt.Errorf("unsupported config update version %x", version)
+	}
+	updateType := ev.Topics[2]
+	for _, b := range updateType[:common.HashLength-1] {
+		if b != 0 {
+			return 0, common.Hash{}, fmt.Errorf("invalid update type topic %x", updateType)
+		}
+	}
+	data, err := unpackEventBytes(ev.Data)
+	if err != nil {
+		return 0, common.Hash{}, fmt.Errorf("invalid update data: %w", err)
+	}
+	if len(data) != common.HashLength {
+		return 0, common.Hash{}, fmt.Errorf("update data has %d bytes, want %d", len(data), common.HashLength)
+	}
+	return updateType[common.HashLength-1], common.BytesToHash(data), nil
+}
+
+// MarshalConfigUpdateLogEvent encodes an update as the event log that the given
+// system config contract emits for it.
+func MarshalConfigUpdateLogEvent(systemConfig common.Address, updateType uint8, value common.Hash) *types.Log {
+	return &types.Log{
+		Address: systemConfig,
+		Topics: []common.Hash{
+			ConfigUpdateEventABIHash,
+			ConfigUpdateEventVersion0,
+			common.BigToHash(new(big.Int).SetUint64(uint64(updateType))),
+		},
+		Data: packEventBytes(value[:]),
+	}
+}
+
+// ApplyConfigUpdate applies a config update event to the system config. Updates
+// with invalid values leave the system config unchanged.
+func ApplyConfigUpdate(sysCfg *rollup.SystemConfig, ev *types.Log) error {
+	updateType, value, err := UnmarshalConfigUpdateLogEvent(ev)
+	if err != nil {
+		return err
+	}
+	switch updateType {
+	case SystemConfigUpdateBatcher:
+		addr, err := topicAddress(value)
+		if err != nil {
+			return fmt.Errorf("invalid batcher: %w", err)
+		}
+		sysCfg.BatcherAddr = addr
+	case SystemConfigUpdateOverhead, SystemConfigUpdateScalar:
+		v := value.Big()
+		if !v.IsUint64() {
+			return fmt.Errorf("fee parameter %v out of range", v)
+		}
+		if updateType == SystemConfigUpdateOverhead {
+			sysCfg.Overhead = v.Uint64()
+		} else {
+			sysCfg.Scalar = v.Uint64()
+		}
+	case SystemConfigUpdateGasLimit:
+		v := value.Big()
+		if !v.IsUint64() || v.Uint64() < params.MinGasLimit || v.Uint64() > params.MaxGasLimit {
+			return fmt.Errorf("gas limit %v out of range", v)
+		}
+		sysCfg.GasLimit = v.Uint64()
+	case SystemConfigUpdateAddSigner, SystemConfigUpdateRemoveSigner:
+		addr, err := topicAddress(value)
+		if err != nil {
+			return fmt.Errorf("invalid unsafe block signer: %w", err)
+		}
+		if addr == (common.Address{}) {
+			return errors.New("zero unsafe block signer")
+		}
+		// The signers are copied rather than modified in place, since the
+		// system config tracker keeps the configs before the update.
+		signers := make([]common.Address, 0, len(sysCfg.UnsafeBlockSigners)+1)
+		for _, signer := range sysCfg.UnsafeBlockSigners {
+			if signer != addr {
+				signers = append(signers, signer)
+			}
+		}
+		if updateType == SystemConfigUpdateAddSigner {
+			signers = append(signers, addr)
+		}
+		sysCfg.UnsafeBlockSigners = signers
+	default:
+		return fmt.Errorf("unknown update type %d", updateType)
+	}
+	return nil
+}
+
+// configUpdateLogs returns the config update events that the system config
+// contract emitted in the given receipts of an L1 block. Reverted transactions
+// cannot emit events, so their receipts are skipped.
+func configUpdateLogs(receipts []*types.Receipt, systemConfig common.Address) []*types.Log {
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		for _, ev := range receipt.Logs {
+			if ev.Address == systemConfig && len(ev.Topics) > 0 && ev.Topics[0] == ConfigUpdateEventABIHash {
+				logs = append(logs, ev)
+			}
+		}
+	}
+	return logs
+}
+
+// SystemConfigDeposits returns the deposits that apply the fee updates in the
+// given receipts of an L1 block to the gas price oracle. They are sent from the
+// zero address, which owns the oracle since no account can sign for it. Invalid
+// updates are skipped, the system config tracker reports them.
+func SystemConfigDeposits(receipts []*types.Receipt, systemConfig common.Address) []*types.DepositTx {
+	if systemConfig == (common.Address{}) {
+		return nil
+	}
+	var (
+		oracle   = core.OVM_GasPriceOracleAddr
+		deposits []*types.DepositTx
+	)
+	for _, ev := range configUpdateLogs(receipts, systemConfig) {
+		var sysCfg rollup.SystemConfig
+		if err := ApplyConfigUpdate(&sysCfg, ev); err != nil {
+			continue
+		}
+		updateType, value, _ := UnmarshalConfigUpdateLogEvent(ev)
+		var selector []byte
+		switch updateType {
+		case SystemConfigUpdateOverhead:
+			selector = setOverheadSelector
+		case SystemConfigUpdateScalar:
+			selector = setScalarSelector
+		default:
+			continue
+		}
+		data := make([]byte, 0, 4+common.HashLength)
+		data = append(data, selector...)
+		data = append(data, value[:]...)
+		deposits = append(deposits, &types.DepositTx{
+			SourceHash: types.SystemConfigDepositSourceHash(ev.BlockHash, uint64(ev.Index)),
+			From:       common.Address{},
+			To:         &oracle,
+			Value:      new(big.Int),
+			Gas:        systemConfigDepositGas,
+			Data:       data,
+		})
+	}
+	return deposits
+}
+
+// SystemConfigUpdate is the system config after the updates of an L1 block.
+type SystemConfigUpdate struct {
+	L1Block uint64              `json:"l1Block"`
+	Config  rollup.SystemConfig `json:"config"`
+}
+
+// SystemConfigCheckpoint is the state of a system config tracker at a traversed
+// L1 block. It is persisted, so that a restarted tracker resumes the traversal
+// after the block instead of from the genesis.
+type SystemConfigCheckpoint struct {
+	L1      rollup.L1BlockRef    `json:"l1"`
+	Updates []SystemConfigUpdate `json:"updates"` // up to L1, oldest first, starting with the genesis config
+}
+
+// systemConfigTraverseBatch is the number of L1 blocks that the tracker
+// traverses at once, before it lets other callers look up their config.
+const systemConfigTraverseBatch = 100
+
+// SystemConfigTracker follows the updates of the system config on L1. It
+// traverses the L1 chain from the genesis of the rollup on, fetching the
+// receipts of the blocks whose logs bloom matches a config update, and unwinds
+// the updates of L1 blocks that were reorged out.
+//
+// A tracker is shared by the users of the config of a node, which look it up at
+// different L1 blocks, so that L1 is only traversed once. It is safe for
+// concurrent use.
+type SystemConfigTracker struct {
+	cfg *rollup.Config
+	l1  L1Fetcher
+	log log.Logger
+
+	mu      sync.Mutex
+	blocks  *L1Tracker           // L1 blocks whose updates were applied
+	updates []SystemConfigUpdate // oldest first, starting with the genesis config
+}
+
+// NewSystemConfigTracker creates a tracker of the system config of the rollup.
+func NewSystemConfigTracker(cfg *rollup.Config, l1 L1Fetcher, logger log.Logger) *SystemConfigTracker {
+	t := &SystemConfigTracker{cfg: cfg, l1: l1, log: logger}
+	t.blocks = NewL1Tracker(l1, rollup.L1BlockRef{})
+	t.restart()
+	return t
+}
+
+// restart forgets all updates and traverses L1 from the genesis again.
+func (t *SystemConfigTracker) restart() {
+	genesis := t.cfg.Genesis.L1
+	t.blocks.Reset(rollup.L1BlockRef{Hash: genesis.Hash, Number: genesis.Number})
+	t.updates = []SystemConfigUpdate{{L1Block: genesis.Number, Config: t.cfg.GenesisSystemConfig()}}
+}
+
+// Checkpoint returns the state of the tracker at the oldest L1 block that it
+// still remembers. Resuming from there, the tracker can unwind reorgs of the
+// blocks it traversed after the checkpoint. It returns nil if the rollup has no
+// system config contract.
+func (t *SystemConfigTracker) Checkpoint() *SystemConfigCheckpoint {
+	if t.cfg.SystemConfigAddress == (common.Address{}) {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ref := t.blocks.refs[0]
+	cp := &SystemConfigCheckpoint{L1: ref}
+	for _, update := range t.updates {
+		if update.L1Block > ref.Number {
+			break
+		}
+		cp.Updates = append(cp.Updates, update)
+	}
+	return cp
+}
+
+// Restore makes the tracker continue the traversal after the L1 block of the
+// given checkpoint. If that block was reorged out in the meantime, the tracker
+// starts over from the genesis.
+func (t *SystemConfigTracker) Restore(cp *SystemConfigCheckpoint) error {
+	genesis := t.cfg.Genesis.L1
+	switch {
+	case len(cp.Updates) == 0 || cp.Updates[0].L1Block != genesis.Number:
+		return errors.New("system config checkpoint does not start at the L1 genesis")
+	case cp.L1.Number < genesis.Number:
+		return fmt.Errorf("system config checkpoint at L1 block %d before the genesis %d", cp.L1.Number, genesis.Number)
+	}
+	for i := 1; i < len(cp.Updates); i++ {
+		if n := cp.Updates[i].L1Block; n <= cp.Updates[i-1].L1Block || n > cp.L1.Number {
+			return fmt.Errorf("invalid system config update at L1 block %d", n)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.blocks.Reset(cp.L1)
+	t.updates = append([]SystemConfigUpdate(nil), cp.Updates...)
+	return nil
+}
+
+// At returns the system config after the updates of the given L1 block, which
+// apply to the batches posted in the block and to the L2 blocks of its epoch.
+func (t *SystemConfigTracker) At(ctx context.Context, header *types.Header) (rollup.SystemConfig, error) {
+	number := header.Number.Uint64()
+	if t.cfg.SystemConfigAddress == (common.Address{}) || number <= t.cfg.Genesis.L1.Number {
+		return t.cfg.GenesisSystemConfig(), nil
+	}
+	// A long traversal is done in batches, so that the users of the tracker
+	// that look up blocks it traversed already are not held up by it.
+	for {
+		t.mu.Lock()
+		head := t.blocks.Head().Number
+		if head >= number {
+			break
+		}
+		to := number
+		if to-head > systemConfigTraverseBatch {
+			to = head + systemConfigTraverseBatch
+		}
+		err := t.traverse(ctx, to)
+		t.mu.Unlock()
+		if err != nil {
+			return rollup.SystemConfig{}, err
+		}
+	}
+	defer t.mu.Unlock()
+
+	// The block may have been reorged out of the traversed chain, or the
+	// traversal may have followed a chain that the block is not part of. The
+	// traversal is unwound and retried once, so that a header that is no
+	// longer canonical cannot make it go back and forth.
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := t.traverse(ctx, number); err != nil {
+			return rollup.SystemConfig{}, err
+		}
+		ref, ok := t.blocks.Ref(number)
+		if !ok || ref.Hash == header.Hash() {
+			// Blocks beyond the tracked window are deep enough to be final.
+			return t.configAt(number), nil
+		}
+		if err := t.unwind(ctx); err != nil {
+			return rollup.SystemConfig{}, err
+		}
+	}
+	return rollup.SystemConfig{}, fmt.Errorf("L1 block %d %s is not canonical", number, header.Hash())
+}
+
+// configAt returns the config after the updates of the traversed L1 block with
+// the given number.
+func (t *SystemConfigTracker) configAt(number uint64) rollup.SystemConfig {
+	for i := len(t.updates) - 1; i > 0; i-- {
+		if t.updates[i].L1Block <= number {
+			return t.updates[i].Config
+		}
+	}
+	return t.updates[0].Config
+}
+
+// traverse applies the updates of the L1 blocks up to the given number.
+func (t *SystemConfigTracker) traverse(ctx context.Context, number uint64) error {
+	for t.blocks.Head().Number < number {
+		header, err := t.blocks.Next(ctx)
+		switch {
+		case errors.Is(err, ErrReorg):
+			if err := t.unwind(ctx); err != nil {
+				return err
+			}
+			continue
+		case err == io.EOF:
+			return fmt.Errorf("L1 block %d not available", t.blocks.Head().Number+1)
+		case err != nil:
+			return err
+		}
+		if err := t.apply(ctx, header); err != nil {
+			// Without the updates of the block, the traversal must stop
+			// before it.
+			t.blocks.undo()
+			return err
+		}
+	}
+	return nil
+}
+
+// apply applies the config updates of an L1 block.
+func (t *SystemConfigTracker) apply(ctx context.Context, header *types.Header) error {
+	addr := t.cfg.SystemConfigAddress
+	if !types.BloomLookup(header.Bloom, addr) || !types.BloomLookup(header.Bloom, ConfigUpdateEventABIHash) {
+		return nil
+	}
+	number := header.Number.Uint64()
+	receipts, err := t.l1.Receipts(ctx, header.Hash())
+	if err != nil {
+		return fmt.Errorf("failed to fetch receipts of L1 block %d: %w", number, err)
+	}
+	logs := configUpdateLogs(receipts, addr)
+	if len(logs) == 0 {
+		return nil
+	}
+	sysCfg := t.configAt(number)
+	for _, ev := range logs {
+		if err := ApplyConfigUpdate(&sysCfg, ev); err != nil {
+			t.log.Warn("Ignoring invalid system config update", "l1block", number, "index", ev.Index, "err", err)
+		}
+	}
+	t.updates = append(t.updates, SystemConfigUpdate{L1Block: number, Config: sysCfg})
+	t.log.Info("Updated system config", "l1block", number, "batcher", sysCfg.BatcherAddr, "overhead", sysCfg.Overhead, "scalar", sysCfg.Scalar, "gaslimit", sysCfg.GasLimit, "signers", len(sysCfg.UnsafeBlockSigners))
+	return nil
+}
+
+// unwind drops the updates of the L1 blocks that were reorged out. After a
+// reorg deeper than the tracked window, the traversal starts over from the
+// genesis.
+func (t *SystemConfigTracker) unwind(ctx context.Context) error {
+	ancestor, err := t.blocks.FindCommonAncestor(ctx)
+	if errors.Is(err, ErrDeepReorg) {
+		t.log.Warn("Deep L1 reorg, restarting system config traversal from genesis")
+		t.restart()
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for len(t.updates) > 1 && t.updates[len(t.updates)-1].L1Block > ancestor.Number {
+		t.updates = t.updates[:len(t.updates)-1]
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+var (
+	testSystemConfig = common.HexToAddress("0x5555555555555555555555555555555555555555")
+	testMaxWord      = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+)
+
+// addConfigUpdates adds an L1 block whose only transaction emits the given
+// config updates.
+func (s *testSetup) addConfigUpdates(t *testing.T, updates ...*types.Log) *types.Block {
+	t.Helper()
+	return s.l1.AddBlockWithLogs([]*types.Transaction{s.dataTx(t, nil)}, [][]*types.Log{updates})
+}
+
+func TestApplyConfigUpdate(t *testing.T) {
+	batcher := common.HexToAddress("0xb0b")
+	tests := []struct {
+		name    string
+		ev      *types.Log
+		want    rollup.SystemConfig
+		wantErr bool
+	}{
+		{name: "batcher", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateBatcher, common.BytesToHash(batcher[:])), want: rollup.SystemConfig{BatcherAddr: batcher}},
+		{name: "overhead", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateOverhead, common.BigToHash(big.NewInt(2100))), want: rollup.SystemConfig{Overhead: 2100}},
+		{name: "scalar", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateScalar, common.BigToHash(big.NewInt(1_000_000))), want: rollup.SystemConfig{Scalar: 1_000_000}},
+		{name: "gas limit", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateGasLimit, common.BigToHash(big.NewInt(15_000_000))), want: rollup.SystemConfig{GasLimit: 15_000_000}},
+		{name: "gas limit too low", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateGasLimit, common.BigToHash(big.NewInt(1))), wantErr: true},
+		{name: "dirty batcher", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateBatcher, common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")), wantErr: true},
+		{name: "overhead out of range", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateOverhead, common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")), wantErr: true},
+		{name: "add signer", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateAddSigner, common.BytesToHash(batcher[:])), want: rollup.SystemConfig{UnsafeBlockSigners: []common.Address{batcher}}},
+		{name: "remove unknown signer", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateRemoveSigner, common.BytesToHash(batcher[:])), want: rollup.SystemConfig{UnsafeBlockSigners: []common.Address{}}},
+		{name: "zero signer", ev: MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateAddSigner, common.Hash{}), wantErr: true},
+		{name: "unknown type", ev: MarshalConfigUpdateLogEvent(testSystemConfig, 6, common.Hash{}), wantErr: true},
+		{name: "unknown version", ev: &types.Log{Topics: []common.Hash{ConfigUpdateEventABIHash, {31: 1}, {}}, Data: packEventBytes(make([]byte, 32))}, wantErr: true},
+		{name: "short data", ev: &types.Log{Topics: []common.Hash{ConfigUpdateEventABIHash, {}, {}}, Data: packEventBytes(make([]byte, 20))}, wantErr: true},
+	}
+	for _, test := range tests {
+		var sysCfg rollup.SystemConfig
+		err := ApplyConfigUpdate(&sysCfg, test.ev)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			if !reflect.DeepEqual(sysCfg, rollup.SystemConfig{}) {
+				t.Errorf("%s: invalid update changed the config to %+v", test.name, sysCfg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(sysCfg, test.want) {
+			t.Errorf("%s: config %+v, want %+v", test.name, sysCfg, test.want)
+		}
+	}
+}
+
+func TestSystemConfigDeposits(t *testing.T) {
+	blockHash := common.HexToHash("0xb10c")
+	logs := []*types.Log{
+		MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateOverhead, common.BigToHash(big.NewInt(2100))),
+		MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateGasLimit, common.BigToHash(big.NewInt(15_000_000))),
+		MarshalConfigUpdateLogEvent(common.HexToAddress("0xbad"), SystemConfigUpdateScalar, common.BigToHash(big.NewInt(7))),
+		MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateScalar, common.BigToHash(big.NewInt(1_000_000))),
+	}
+	for i, l := range logs {
+		l.BlockHash, l.Index = blockHash, uint(i)
+	}
+	receipts := []*types.Receipt{{Status: types.ReceiptStatusSuccessful, Logs: logs}}
+
+	deposits := SystemConfigDeposits(receipts, testSystemConfig)
+	if len(deposits) != 2 {
+		t.Fatalf("got %d deposits, want the overhead and scalar updates", len(deposits))
+	}
+	for i, want := range []struct {
+		selector string
+		value    int64
+		logIndex uint64
+	}{{"setOverhead(uint256)", 2100, 0}, {"setScalar(uint256)", 1_000_000, 3}} {
+		dep := deposits[i]
+		data := append(crypto.Keccak256([]byte(want.selector))[:4], common.BigToHash(big.NewInt(want.value)).Bytes()...)
+		if dep.From != (common.Address{}) || dep.To == nil || *dep.To != core.OVM_GasPriceOracleAddr {
+			t.Errorf("deposit %d: from %s to %v, want from zero to the gas price oracle", i, dep.From, dep.To)
+		}
+		if !bytes.Equal(dep.Data, data) {
+			t.Errorf("deposit %d: data %x, want %x", i, dep.Data, data)
+		}
+		if dep.SourceHash != types.SystemConfigDepositSourceHash(blockHash, want.logIndex) {
+			t.Errorf("deposit %d: wrong source hash", i)
+		}
+	}
+	if deposits := SystemConfigDeposits(receipts, common.Address{}); deposits != nil {
+		t.Fatalf("got %d deposits without a system config contract", len(deposits))
+	}
+	receipts[0].Status = types.ReceiptStatusFailed
+	if deposits := SystemConfigDeposits(receipts, testSystemConfig); len(deposits) != 0 {
+		t.Fatalf("got %d deposits from a reverted transaction", len(deposits))
+	}
+}
+
+func TestSystemConfigTracker(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.SystemConfigAddress = testSystemConfig
+	tracker := NewSystemConfigTracker(s.cfg, s.l1, log.New())
+
+	batcher := common.HexToAddress("0xb0b")
+	s.l1.AddBlock()
+	updated := s.addConfigUpdates(t,
+		MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateBatcher, common.BytesToHash(batcher[:])),
+		MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateGasLimit, common.BigToHash(big.NewInt(15_000_000))),
+		MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateGasLimit, common.BigToHash(big.NewInt(1))), // ignored
+	)
+	s.l1.AddBlock()
+
+	genesis := s.cfg.GenesisSystemConfig()
+	want := rollup.SystemConfig{BatcherAddr: batcher, GasLimit: 15_000_000}
+	for _, check := range []struct {
+		number uint64
+		want   rollup.SystemConfig
+	}{{3, want}, {1, genesis}, {2, want}, {0, genesis}} {
+		sysCfg, err := tracker.At(context.Background(), s.l1.Block(check.number).Header())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sysCfg, check.want) {
+			t.Fatalf("config at L1 block %d is %+v, want %+v", check.number, sysCfg, check.want)
+		}
+	}
+
+	// A reorg of the updating block unwinds its updates.
+	s.l1.Reorg(2)
+	s.l1.AddBlock()
+	s.l1.AddBlock()
+	sysCfg, err := tracker.At(context.Background(), s.l1.Block(3).Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sysCfg, genesis) {
+		t.Fatalf("config after reorg of the update in %s is %+v, want the genesis config", updated.Hash(), sysCfg)
+	}
+}
+
+func TestSystemConfigTrackerSigners(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.SystemConfigAddress = testSystemConfig
+	tracker := NewSystemConfigTracker(s.cfg, s.l1, log.New())
+
+	// The signer key is rotated: the new key is authorized next to the old
+	// one, which is revoked in a later block.
+	oldKey, newKey := common.HexToAddress("0x01d"), common.HexToAddress("0x0e1")
+	s.addConfigUpdates(t, MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateAddSigner, common.BytesToHash(oldKey[:])))
+	s.addConfigUpdates(t, MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateAddSigner, common.BytesToHash(newKey[:])))
+	s.addConfigUpdates(t, MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateRemoveSigner, common.BytesToHash(oldKey[:])))
+
+	for _, check := range []struct {
+		number uint64
+		want   []common.Address
+	}{{3, []common.Address{newKey}}, {0, nil}, {1, []common.Address{oldKey}}, {2, []common.Address{oldKey, newKey}}} {
+		sysCfg, err := tracker.At(context.Background(), s.l1.Block(check.number).Header())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sysCfg.UnsafeBlockSigners, check.want) {
+			t.Fatalf("signers at L1 block %d are %v, want %v", check.number, sysCfg.UnsafeBlockSigners, check.want)
+		}
+	}
+}
+
+// headerCounter is an L1 fetcher that counts the headers it fetches.
+type headerCounter struct {
+	L1Fetcher
+	headers int
+}
+
+func (c *headerCounter) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.headers++
+	return c.L1Fetcher.HeaderByNumber(ctx, number)
+}
+
+func TestSystemConfigTrackerCheckpoint(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.SystemConfigAddress = testSystemConfig
+	tracker := NewSystemConfigTracker(s.cfg, s.l1, log.New())
+
+	batcher := common.HexToAddress("0xb0b")
+	s.addConfigUpdates(t, MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateBatcher, common.BytesToHash(batcher[:])))
+	for i := 0; i < maxReorgDepth+10; i++ {
+		s.l1.AddBlock()
+	}
+	want := rollup.SystemConfig{BatcherAddr: batcher}
+	if _, err := tracker.At(context.Background(), s.l1.Head().Header()); err != nil {
+		t.Fatal(err)
+	}
+	// The checkpoint is at the oldest remembered block, and survives being
+	// persisted.
+	cp := tracker.Checkpoint()
+	if cp.L1.Number != s.l1.Head().NumberU64()-maxReorgDepth+1 || len(cp.Updates) != 2 {
+		t.Fatalf("checkpoint at L1 block %d with %d updates", cp.L1.Number, len(cp.Updates))
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved SystemConfigCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restored tracker only traverses the blocks after the checkpoint.
+	s.l1.AddBlock()
+	l1 := &headerCounter{L1Fetcher: s.l1}
+	restored := NewSystemConfigTracker(s.cfg, l1, log.New())
+	if err := restored.Restore(&saved); err != nil {
+		t.Fatal(err)
+	}
+	for _, number := range []uint64{s.l1.Head().NumberU64(), 1} {
+		sysCfg, err := restored.At(context.Background(), s.l1.Block(number).Header())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sysCfg, want) {
+			t.Fatalf("restored config at L1 block %d is %+v, want %+v", number, sysCfg, want)
+		}
+	}
+	if max := int(s.l1.Head().NumberU64() - cp.L1.Number); l1.headers > max {
+		t.Fatalf("restored tracker fetched %d headers, want at most %d", l1.headers, max)
+	}
+
+	// A checkpoint that was reorged out makes the tracker start over.
+	s.l1.Reorg(maxReorgDepth + 1)
+	s.l1.AddBlock()
+	restored = NewSystemConfigTracker(s.cfg, s.l1, log.New())
+	if err := restored.Restore(&saved); err != nil {
+		t.Fatal(err)
+	}
+	sysCfg, err := restored.At(context.Background(), s.l1.Head().Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sysCfg, want) {
+		t.Fatalf("config after reorg of the checkpoint is %+v, want %+v", sysCfg, want)
+	}
+
+	// Checkpoints that do not start at the genesis are refused.
+	if err := restored.Restore(&SystemConfigCheckpoint{L1: cp.L1, Updates: cp.Updates[1:]}); err == nil {
+		t.Fatal("checkpoint without the genesis config restored")
+	}
+}
+
+func TestPipelineSystemConfigUpdates(t *testing.T) {
+	s := newTestSetup()
+	s.cfg.SystemConfigAddress = testSystemConfig
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+
+	// The first L1 block hands the batcher role over to another account and
+	// sets the gas limit, the batcher's own batch in the same block is ignored.
+	other := crypto.PubkeyToAddress(testOtherKey.PublicKey)
+	epoch1 := s.l1.AddBlockWithLogs(
+		[]*types.Transaction{s.dataTx(t, nil), s.batchTx(t, &BatchData{
+			ParentHash: s.cfg.Genesis.L2.Hash,
+			EpochNum:   0,
+			EpochHash:  s.cfg.Genesis.L1.Hash,
+			Timestamp:  s.cfg.Genesis.L2Time + s.cfg.BlockTime,
+		})},
+		[][]*types.Log{{
+			MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateBatcher, common.BytesToHash(other[:])),
+			MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateGasLimit, common.BigToHash(big.NewInt(15_000_000))),
+			MarshalConfigUpdateLogEvent(testSystemConfig, SystemConfigUpdateScalar, common.BigToHash(big.NewInt(1_000_000))),
+		}},
+	)
+	runPipeline(t, p)
+	if head := p.Head(); head.Number != 0 {
+		t.Fatalf("derived block %d from a batch of the previous batcher", head.Number)
+	}
+
+	data, err := EncodeBatches([]*BatchData{
+		{ParentHash: s.cfg.Genesis.L2.Hash, EpochNum: 0, EpochHash: s.cfg.Genesis.L1.Hash, Timestamp: s.cfg.Genesis.L2Time + s.cfg.BlockTime},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, _ := types.SignNewTx(testOtherKey, s.signer, &types.DynamicFeeTx{
+		ChainID:   s.cfg.L1ChainID,
+		To:        &s.cfg.BatchInboxAddress,
+		Gas:       1_000_000,
+		GasFeeCap: big.NewInt(10),
+		Data:      data,
+	})
+	s.l1.AddBlock(tx)
+	runPipeline(t, p)
+	head := p.Head()
+	if head.Number != 1 {
+		t.Fatalf("batch of the new batcher not derived, head %+v", head)
+	}
+	// The genesis epoch keeps the genesis gas limit.
+	if gasLimit := s.engine.Blocks[head.Hash].GasLimit(); gasLimit != 30_000_000 {
+		t.Fatalf("gas limit %d in the genesis epoch", gasLimit)
+	}
+
+	data, err = EncodeBatches([]*BatchData{
+		{ParentHash: head.Hash, EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, _ = types.SignNewTx(testOtherKey, s.signer, &types.DynamicFeeTx{
+		ChainID:   s.cfg.L1ChainID,
+		Nonce:     1,
+		To:        &s.cfg.BatchInboxAddress,
+		Gas:       1_000_000,
+		GasFeeCap: big.NewInt(10),
+		Data:      data,
+	})
+	s.l1.AddBlock(tx)
+	runPipeline(t, p)
+
+	// The first block of the updating epoch has the new gas limit and the
+	// deposit that sets the scalar.
+	head = p.Head()
+	block := s.engine.Blocks[head.Hash]
+	if head.Number != 2 || block == nil {
+		t.Fatalf("unexpected head %+v", head)
+	}
+	if block.GasLimit() != 15_000_000 {
+		t.Fatalf("gas limit %d, want the updated one", block.GasLimit())
+	}
+	txs := block.Transactions()
+	if len(txs) != 2 || txs[1].To() == nil || *txs[1].To() != core.OVM_GasPriceOracleAddr {
+		t.Fatalf("block has %d transactions, want the L1 info deposit and the scalar update", len(txs))
+	}
+}
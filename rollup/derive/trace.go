@@ -0,0 +1,110 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// Kinds of pipeline steps.
+const (
+	StepDerive  = "derive"  // a batch was consumed to derive the next L2 block
+	StepAdvance = "advance" // the L1 traversal advanced to the next L1 block
+	StepReset   = "reset"   // the head was unwound after an L1 reorg
+	StepIdle    = "idle"    // there was no L1 data to derive from yet
+	StepFailed  = "failed"  // the step failed before consuming any input
+)
+
+// StepTrace records a single pipeline step: the input it consumed, the derived
+// head it left behind and how it failed, if it did. Traces only depend on the
+// inputs of the pipeline, so two nodes deriving the same chain record the same
+// steps.
+type StepTrace struct {
+	Seq  uint64 `json:"seq"` // number of the step since tracing was enabled
+	Kind string `json:"kind"`
+
+	L1Block *rollup.L1BlockRef `json:"l1Block,omitempty"` // L1 block advanced to
+	Batch   *BatchTrace        `json:"batch,omitempty"`   // batch consumed
+
+	Head         rollup.L2BlockRef `json:"head"`                   // derived head after the step
+	Consolidated bool              `json:"consolidated,omitempty"` // the derived block was an unsafe block
+	Dropped      string            `json:"dropped,omitempty"`      // why the batch was dropped
+	Error        string            `json:"error,omitempty"`
+}
+
+// BatchTrace identifies the batch consumed by a step.
+type BatchTrace struct {
+	ParentHash   common.Hash `json:"parentHash"`
+	EpochNum     uint64      `json:"epochNum"`
+	EpochHash    common.Hash `json:"epochHash"`
+	Timestamp    uint64      `json:"timestamp"`
+	Transactions int         `json:"transactions"`
+}
+
+func newBatchTrace(b *BatchData) *BatchTrace {
+	return &BatchTrace{
+		ParentHash:   b.ParentHash,
+		EpochNum:     b.EpochNum,
+		EpochHash:    b.EpochHash,
+		Timestamp:    b.Timestamp,
+		Transactions: len(b.Transactions),
+	}
+}
+
+// Tracer keeps the most recent steps of a pipeline in a ring buffer. It is safe
+// for concurrent use, so the steps can be read while the pipeline runs.
+type Tracer struct {
+	mu    sync.Mutex
+	steps []StepTrace
+	seq   uint64 // number of recorded steps
+}
+
+// NewTracer creates a tracer that keeps the given number of steps.
+func NewTracer(size int) *Tracer {
+	if size < 1 {
+		size = 1
+	}
+	return &Tracer{steps: make([]StepTrace, size)}
+}
+
+// record adds a step, overwriting the oldest one if the buffer is full.
+func (t *Tracer) record(step StepTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	step.Seq = t.seq
+	t.steps[t.seq%uint64(len(t.steps))] = step
+	t.seq++
+}
+
+// Steps returns the recorded steps, oldest first.
+func (t *Tracer) Steps() []StepTrace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	size := uint64(len(t.steps))
+	if t.seq <= size {
+		return append([]StepTrace(nil), t.steps[:t.seq]...)
+	}
+	start := t.seq % size
+	steps := make([]StepTrace, 0, size)
+	steps = append(steps, t.steps[start:]...)
+	return append(steps, t.steps[:start]...)
+}
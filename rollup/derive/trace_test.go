@@ -0,0 +1,84 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func TestTracerRing(t *testing.T) {
+	tr := NewTracer(3)
+	if steps := tr.Steps(); len(steps) != 0 {
+		t.Fatalf("empty tracer returned %d steps", len(steps))
+	}
+	for i := 0; i < 5; i++ {
+		tr.record(StepTrace{Kind: StepIdle})
+	}
+	var seqs []uint64
+	for _, s := range tr.Steps() {
+		seqs = append(seqs, s.Seq)
+	}
+	if want := []uint64{2, 3, 4}; !reflect.DeepEqual(seqs, want) {
+		t.Fatalf("recorded steps %v, want %v", seqs, want)
+	}
+}
+
+func TestPipelineTrace(t *testing.T) {
+	s := newTestSetup()
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+	tracer := NewTracer(100)
+	p.SetTracer(tracer)
+
+	genesisL1 := s.l1.Head()
+	b1 := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: s.cfg.Genesis.L2Time + 2}
+	deposit, _ := types.NewTx(&types.DepositTx{Gas: 21000, Value: new(big.Int)}).MarshalBinary()
+	invalid := &BatchData{ParentHash: s.cfg.Genesis.L2.Hash, EpochHash: genesisL1.Hash(), Timestamp: b1.Timestamp, Transactions: []hexutil.Bytes{deposit}}
+	s.l1.AddBlock(s.batchTx(t, invalid, b1))
+	runPipeline(t, p)
+	s.l1.Reorg(1)
+	s.l1.AddBlock()
+	s.l1.AddBlock()
+	runPipeline(t, p)
+
+	var kinds []string
+	for _, step := range tracer.Steps() {
+		kinds = append(kinds, step.Kind)
+	}
+	want := []string{StepAdvance, StepDerive, StepDerive, StepIdle, StepReset, StepAdvance, StepAdvance, StepIdle}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("traced steps %v, want %v", kinds, want)
+	}
+	steps := tracer.Steps()
+	if l1 := steps[0].L1Block; l1 == nil || l1.Number != 1 {
+		t.Fatalf("advance step traced L1 block %v, want 1", l1)
+	}
+	if b := steps[1].Batch; b == nil || b.Timestamp != invalid.Timestamp || steps[1].Dropped == "" {
+		t.Fatalf("invalid batch not traced as dropped: %+v", steps[1])
+	}
+	if steps[2].Batch.Timestamp != b1.Timestamp || steps[2].Head.Number != 1 {
+		t.Fatalf("derived block not traced: %+v", steps[2])
+	}
+	if steps[4].Head.Number != 0 {
+		t.Fatalf("reset step traced head %v, want genesis", steps[4].Head)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// networkUpgrade is a rollup upgrade that changes the predeploys with deposits
+// in the first L2 block of the upgrade. The deposits are part of the node
+// software, so every node derives the same upgrade without any L1 transaction.
+type networkUpgrade struct {
+	name       string
+	activation func(cfg *rollup.Config, timestamp uint64) bool
+	deposits   func(cfg *rollup.Config) []*types.DepositTx
+}
+
+// networkUpgrades are the upgrades that change the predeploys, in the order of
+// their deposits when several upgrades activate in the same block.
+//
+// None of the scheduled upgrades does yet: Regolith only changes the gas
+// accounting of deposits. An upgrade that deploys new predeploy code registers
+// here along with the contract artifacts it ships.
+var networkUpgrades []networkUpgrade
+
+// UpgradeDeposits returns the network upgrade deposits of the L2 block with the
+// given timestamp, which follow the user deposits of the block.
+func UpgradeDeposits(cfg *rollup.Config, timestamp uint64) []*types.DepositTx {
+	var deposits []*types.DepositTx
+	for _, upgrade := range networkUpgrades {
+		if upgrade.deposits != nil && upgrade.activation(cfg, timestamp) {
+			deposits = append(deposits, upgrade.deposits(cfg)...)
+		}
+	}
+	return deposits
+}
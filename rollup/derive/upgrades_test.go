@@ -0,0 +1,169 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package derive
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+// Tests that the upgrade deposits are bound to the L2 chain ID after Interop
+// without changing the deposits of the upgrade, which every block that
+// activates it shares.
+func TestUpgradeDepositsBoundToChainID(t *testing.T) {
+	upgrade := upgradeProxyDeposit("Test: L1 Block Proxy Update", types.L1BlockAddr, common.HexToAddress("0x1b"))
+	source := upgrade.SourceHash
+	defer func(upgrades []networkUpgrade) { networkUpgrades = upgrades }(networkUpgrades)
+	networkUpgrades = []networkUpgrade{{
+		name:       "Test",
+		activation: (*rollup.Config).IsRegolithActivationBlock,
+		deposits:   func(*rollup.Config) []*types.DepositTx { return []*types.DepositTx{upgrade} },
+	}}
+
+	s := newTestSetup()
+	origin := s.l1.Head().Header()
+	regolith, interop := origin.Time+s.cfg.BlockTime, origin.Time
+	s.cfg.RegolithTime, s.cfg.InteropTime = &regolith, &interop
+
+	want := types.ChainDepositSourceHash(s.cfg.L2ChainID, source)
+	for i := 0; i < 2; i++ {
+		attrs, err := PreparePayloadAttributes(s.cfg, rollup.SystemConfig{}, origin, 1, regolith, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(attrs.Transactions[1]); err != nil {
+			t.Fatal(err)
+		}
+		if tx.SourceHash() != want {
+			t.Fatalf("attempt %d: upgrade deposit source hash %s, want %s", i, tx.SourceHash(), want)
+		}
+	}
+	if upgrade.SourceHash != source {
+		t.Fatal("upgrade deposit changed by binding it")
+	}
+}
+
+// deployDeposit returns an upgrade deposit that deploys a contract from the
+// given deployer, along with the address of the contract. Every deployer may
+// only deploy once, its nonce must be zero.
+func deployDeposit(intent string, deployer common.Address, gas uint64, initCode []byte) (*types.DepositTx, common.Address) {
+	return &types.DepositTx{
+		SourceHash: types.UpgradeDepositSourceHash(intent),
+		From:       deployer,
+		Value:      new(big.Int),
+		Gas:        gas,
+		Data:       initCode,
+	}, crypto.CreateAddress(deployer, 0)
+}
+
+// upgradeToSelector is the selector of upgradeTo(address) of the predeploy
+// proxies.
+var upgradeToSelector = crypto.Keccak256([]byte("upgradeTo(address)"))[:4]
+
+// upgradeProxyGas is the gas of the deposits that upgrade a predeploy proxy.
+const upgradeProxyGas = 50_000
+
+// upgradeProxyDeposit returns an upgrade deposit that points the proxy of a
+// predeploy to a new implementation. The deposit is sent from the zero address,
+// which the proxies accept as their admin, since no account can sign for it.
+func upgradeProxyDeposit(intent string, proxy, impl common.Address) *types.DepositTx {
+	data := make([]byte, 0, 4+common.HashLength)
+	data = append(data, upgradeToSelector...)
+	data = append(data, common.BytesToHash(impl[:]).Bytes()...)
+	return &types.DepositTx{
+		SourceHash: types.UpgradeDepositSourceHash(intent),
+		From:       common.Address{},
+		To:         &proxy,
+		Value:      new(big.Int),
+		Gas:        upgradeProxyGas,
+		Data:       data,
+	}
+}
+
+func TestPipelineDerivesUpgradeDeposits(t *testing.T) {
+	// An upgrade that deploys a new L1 block implementation and points the
+	// proxy to it.
+	deploy, impl := deployDeposit("Test: L1 Block Deployment", common.HexToAddress("0xde01"), 300_000, []byte{0x60, 0x00})
+	upgrade := upgradeProxyDeposit("Test: L1 Block Proxy Update", types.L1BlockAddr, impl)
+	defer func(upgrades []networkUpgrade) { networkUpgrades = upgrades }(networkUpgrades)
+	networkUpgrades = []networkUpgrade{{
+		name:       "Test",
+		activation: (*rollup.Config).IsRegolithActivationBlock,
+		deposits:   func(*rollup.Config) []*types.DepositTx { return []*types.DepositTx{deploy, upgrade} },
+	}}
+
+	s := newTestSetup()
+	regolith := s.cfg.Genesis.L2Time + 2*s.cfg.BlockTime
+	s.cfg.RegolithTime = &regolith
+	p := NewPipeline(s.cfg, Confirmations{}, s.l1, s.engine, s.cfg.L2GenesisRef(), log.New())
+
+	// Three blocks of the genesis epoch, the second one activates the upgrade.
+	var batches []*BatchData
+	parent := s.cfg.Genesis.L2.Hash
+	for i := uint64(1); i <= 3; i++ {
+		batch := &BatchData{
+			ParentHash: parent,
+			EpochHash:  s.cfg.Genesis.L1.Hash,
+			Timestamp:  s.cfg.Genesis.L2Time + i*s.cfg.BlockTime,
+		}
+		s.l1.AddBlock(s.batchTx(t, batch))
+		runPipeline(t, p)
+		if p.Head().Number != i {
+			t.Fatalf("block %d not derived, head %+v", i, p.Head())
+		}
+		batches = append(batches, batch)
+		parent = p.Head().Hash
+	}
+	for i, batch := range batches {
+		var block *types.Block
+		for _, b := range s.engine.Blocks {
+			if b.Time() == batch.Timestamp {
+				block = b
+			}
+		}
+		txs := block.Transactions()
+		if batch.Timestamp != regolith {
+			if len(txs) != 1 {
+				t.Errorf("block %d has %d transactions, want only the L1 info deposit", i+1, len(txs))
+			}
+			continue
+		}
+		if len(txs) != 3 {
+			t.Fatalf("activation block has %d transactions, want the L1 info and the upgrade deposits", len(txs))
+		}
+		if txs[1].Hash() != types.NewTx(deploy).Hash() || txs[2].Hash() != types.NewTx(upgrade).Hash() {
+			t.Fatal("wrong upgrade deposits")
+		}
+	}
+
+	// The upgrade is sent by the zero address and calls upgradeTo with the
+	// implementation.
+	if upgrade.From != (common.Address{}) || !bytes.Equal(upgrade.Data[:4], upgradeToSelector) || common.BytesToAddress(upgrade.Data[4:]) != impl {
+		t.Fatalf("wrong proxy upgrade %+v", upgrade)
+	}
+	if deploy.SourceHash == upgrade.SourceHash {
+		t.Fatal("upgrade deposits share a source hash")
+	}
+}
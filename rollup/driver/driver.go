@@ -0,0 +1,867 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+const (
+	// derivePollInterval is the interval at which L1 is polled for new
+	// batches, in case no new L1 heads are reported to the driver.
+	derivePollInterval = 4 * time.Second
+
+	// headsSaveInterval is the interval at which changed heads are persisted.
+	headsSaveInterval = 5 * time.Second
+)
+
+var (
+	// ErrSequencerActive is returned when starting a sequencer that is running.
+	ErrSequencerActive = errors.New("sequencer already running")
+	// ErrSequencerInactive is returned when stopping a sequencer that is not
+	// running.
+	ErrSequencerInactive = errors.New("sequencer not running")
+	// ErrUnknownSequencerHead is returned when the sequencer is started on top
+	// of a block that is not the unsafe head of the node.
+	ErrUnknownSequencerHead = errors.New("block is not the unsafe head")
+	// ErrDriverStopped is returned when reporting events to a stopped driver.
+	ErrDriverStopped = errors.New("driver stopped")
+	// ErrELSyncing is returned when starting the sequencer while the engine
+	// syncs the chain from its peers.
+	ErrELSyncing = errors.New("execution-layer sync in progress")
+	// ErrTracingDisabled is returned when reading the derivation trace of a
+	// driver that does not record it.
+	ErrTracingDisabled = errors.New("derivation tracing disabled")
+	// ErrDepositIndexDisabled is returned when looking up deposits on a
+	// driver that does not index them.
+	ErrDepositIndexDisabled = errors.New("deposit index disabled")
+)
+
+// Config contains the settings of the driver.
+type Config struct {
+	// Confirmations control when derived blocks are labelled safe and
+	// finalized.
+	Confirmations derive.Confirmations
+	// Sequencing enables the sequencer from the start.
+	Sequencing bool
+	// HeadsFile is where the heads of the node are persisted. If empty, the
+	// heads are not persisted and the node always starts from the L2 genesis.
+	HeadsFile string
+	// Backfill are the sources that fill gaps in the unsafe payloads, tried
+	// in order. Without them, gaps are only closed by derivation.
+	Backfill []PayloadSource
+	// ELSync makes a new node let the engine sync the chain from its
+	// execution-layer peers, up to the unsafe payloads of the sequencer,
+	// before derivation starts. Derivation then continues from the synced
+	// block, which is trusted as finalized. It has no effect on a node that
+	// sequences or that resumes from persisted heads.
+	ELSync bool
+	// EngineTimeout is the time the engine has to answer an Engine API call.
+	// Zero means DefaultEngineTimeout.
+	EngineTimeout time.Duration
+	// TraceSize is the number of recent derivation steps that are recorded
+	// for debugging. Zero disables tracing.
+	TraceSize int
+	// DepositIndex is the database that the deposits of the derived blocks
+	// are indexed in. If nil, deposits are not indexed.
+	DepositIndex ethdb.KeyValueStore
+	// InterruptWindow is how long the sequencer keeps a block open after it
+	// starts building it. If the next L1 origin arrives with deposits within
+	// the window, the block is restarted on it, so that the deposits are not
+	// delayed to the next block. Zero seals blocks right away. The window must
+	// be shorter than the block time.
+	InterruptWindow time.Duration
+}
+
+// Driver derives the L2 chain from L1 and, on the sequencer, sequences new
+// blocks on top of it.
+//
+// The driver is an event loop. It reacts to new L1 heads, to unsafe payloads
+// gossiped by the sequencer and to the block time of the sequencer, and runs the
+// derivation pipeline one step at a time in between, so that all events are
+// handled promptly. Stopping the driver cancels the step in progress. Every step
+// leaves the engine at a consistent head, from which a new driver resumes.
+type Driver struct {
+	cfg    *rollup.Config
+	l1     derive.L1Fetcher
+	engine derive.Engine
+	log    log.Logger
+
+	mu         sync.Mutex // protects the state below, which the event loop modifies
+	pipeline   *derive.Pipeline
+	sysCfg     *derive.SystemConfigTracker // shared by the pipeline, the sequencer and the signers
+	tracer     *derive.Tracer              // records the derivation steps, nil if disabled
+	deposits   *derive.DepositIndex        // indexes the derived deposits, nil if disabled
+	sequencer  *Sequencer
+	sequencing bool              // whether the sequencer is running
+	elSyncing  bool              // whether the engine syncs the chain, see elsync.go
+	elSyncHead rollup.L2BlockRef // last unsafe payload relayed to the syncing engine
+	halted     error             // critical derivation error that stopped derivation
+	draining   bool              // whether sequenced blocks are kept from the gossip
+	interrupt  time.Duration     // how long sequenced blocks stay open, see Config.InterruptWindow
+	lastBuilt  time.Time         // when the sequencer last built a block
+	unsafe     rollup.L2BlockRef // last imported unsafe payload, ahead of the derived head
+	imported   importedPayloads  // imported unsafe payloads, consolidated by derivation
+	queue      unsafeQueue       // unsafe payloads ahead of the unsafe head
+	saved      Heads             // last persisted heads
+	headsFile  string
+
+	l1Heads  chan rollup.L1BlockRef
+	payloads chan *beacon.ExecutableDataV1
+	stepReq  chan struct{} // requests a derivation step
+
+	backfillSources []PayloadSource
+	backfillReq     chan struct{} // requests filling the gap before the queued payloads
+
+	signersLock sync.RWMutex
+	signers     []common.Address // unsafe block signers at the L1 head, see signers.go
+
+	statusLock sync.RWMutex
+	status     sequencerStatus // published by the event loop, see SequencerStatus
+
+	sequenced event.Feed // payloads built by the sequencer, to be gossiped
+	events    event.Feed // node events, see events.go
+	published Heads      // heads of the last published events, owned by the loop
+	scope     event.SubscriptionScope
+
+	ctx    context.Context // canceled to stop the driver
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDriver creates a driver that continues the L2 chain from the heads that
+// were persisted by the previous run, or from the L2 genesis block.
+//
+// Derivation resumes after the safe head, at its L1 origin. This may read L1
+// blocks again that were read before the restart, since the channels that were
+// only partially read are lost. The sequencer resumes at the unsafe head.
+func NewDriver(cfg *rollup.Config, dcfg Config, l1 derive.L1Fetcher, engine derive.Engine, logger log.Logger) (*Driver, error) {
+	if _, err := derive.LookupDataAvailabilitySource(cfg.DataAvailability); err != nil {
+		return nil, err
+	}
+	if dcfg.InterruptWindow < 0 || dcfg.InterruptWindow >= time.Duration(cfg.BlockTime)*time.Second {
+		return nil, fmt.Errorf("interrupt window %v not within the block time of %ds", dcfg.InterruptWindow, cfg.BlockTime)
+	}
+	genesis := cfg.L2GenesisRef()
+	heads := &Heads{Unsafe: genesis, Safe: genesis, Finalized: genesis}
+	if dcfg.HeadsFile != "" {
+		saved, err := LoadHeads(dcfg.HeadsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load heads: %w", err)
+		}
+		if saved != nil {
+			heads = saved
+			logger.Info("Resuming from persisted heads", "unsafe", heads.Unsafe, "safe", heads.Safe, "finalized", heads.Finalized, "l1", heads.CurrentL1)
+		}
+	}
+	timeout := dcfg.EngineTimeout
+	if timeout == 0 {
+		timeout = DefaultEngineTimeout
+	}
+	engine = &timeoutEngine{engine: engine, timeout: timeout}
+
+	sysCfg := derive.NewSystemConfigTracker(cfg, l1, rollup.ComponentLogger(logger, rollup.LogDerivation))
+	if heads.SystemConfig != nil {
+		if err := sysCfg.Restore(heads.SystemConfig); err != nil {
+			return nil, fmt.Errorf("failed to restore system config: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Driver{
+		cfg:        cfg,
+		l1:         l1,
+		engine:     engine,
+		log:        rollup.ComponentLogger(logger, rollup.LogDriver),
+		pipeline:   derive.NewPipeline(cfg, dcfg.Confirmations, l1, engine, heads.Safe, rollup.ComponentLogger(logger, rollup.LogDerivation)),
+		sysCfg:     sysCfg,
+		sequencer:  NewSequencer(cfg, l1, engine, heads.Unsafe, rollup.ComponentLogger(logger, rollup.LogEngine)),
+		sequencing: dcfg.Sequencing,
+		interrupt:  dcfg.InterruptWindow,
+		unsafe:     heads.Unsafe,
+		saved:      *heads,
+		headsFile:  dcfg.HeadsFile,
+		l1Heads:    make(chan rollup.L1BlockRef, 10),
+		payloads:   make(chan *beacon.ExecutableDataV1, 10),
+		stepReq:    make(chan struct{}, 1),
+		ctx:        ctx,
+		cancel:     cancel,
+
+		backfillSources: dcfg.Backfill,
+		backfillReq:     make(chan struct{}, 1),
+	}
+	d.pipeline.SetSystemConfigTracker(sysCfg)
+	d.sequencer.SetSystemConfigTracker(sysCfg)
+	d.pipeline.SetFinalized(heads.Finalized)
+	d.pipeline.SetUnsafeBlocks(&d.imported)
+	// Heads persisted without the L1 block of the safe head only bound it by
+	// the L1 block that derivation had read up to.
+	if heads.SafeL1 != 0 {
+		d.pipeline.SetSafeL1(heads.SafeL1)
+	} else {
+		d.pipeline.SetSafeL1(heads.CurrentL1.Number)
+	}
+	if dcfg.ELSync && !dcfg.Sequencing && heads.Unsafe == genesis {
+		d.log.Info("Syncing the chain through the execution engine")
+		d.elSyncing = true
+	}
+	if dcfg.TraceSize > 0 {
+		d.tracer = derive.NewTracer(dcfg.TraceSize)
+		d.pipeline.SetTracer(d.tracer)
+	}
+	if dcfg.DepositIndex != nil {
+		d.deposits = derive.NewDepositIndex(cfg, l1, dcfg.DepositIndex)
+		if err := d.pipeline.SetDepositIndex(d.deposits); err != nil {
+			return nil, fmt.Errorf("failed to unwind deposit index: %w", err)
+		}
+	}
+	d.sequencer.SetSafeHead(heads.Safe.Hash, heads.Finalized.Hash)
+	d.publishStatus()
+	return d, nil
+}
+
+// Start starts the event loop of the driver.
+func (d *Driver) Start() {
+	d.wg.Add(1)
+	go d.loop()
+	if len(d.backfillSources) > 0 {
+		d.wg.Add(1)
+		go d.backfillLoop()
+	}
+	if d.cfg.SystemConfigAddress != (common.Address{}) {
+		d.wg.Add(1)
+		go d.signersLoop()
+	}
+}
+
+// Stop stops the driver, aborting the derivation step or block in progress, and
+// waits for the event loop to exit. The heads are persisted one last time.
+func (d *Driver) Stop() {
+	d.cancel()
+	d.wg.Wait()
+	d.scope.Close()
+
+	if err := d.saveHeads(); err != nil {
+		d.log.Error("Failed to persist heads", "err", err)
+	}
+}
+
+// OnL1Head reports a new L1 head, which makes the driver look for new batches
+// right away.
+func (d *Driver) OnL1Head(ctx context.Context, head rollup.L1BlockRef) error {
+	select {
+	case d.l1Heads <- head:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.ctx.Done():
+		return ErrDriverStopped
+	}
+}
+
+// OnUnsafePayload reports a payload of the sequencer that is not confirmed on L1
+// yet. The driver imports it once it extends the unsafe head, payloads further
+// ahead are queued until the payloads in between arrive.
+func (d *Driver) OnUnsafePayload(ctx context.Context, payload *beacon.ExecutableDataV1) error {
+	select {
+	case d.payloads <- payload:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.ctx.Done():
+		return ErrDriverStopped
+	}
+}
+
+// StartSequencer starts sequencing blocks on top of the block with the given
+// hash, which must be the unsafe head of the node: either the last block that
+// this node sequenced, or the last block that it derived or imported. This
+// allows handing the sequencer over from another node that stopped at that
+// block.
+func (d *Driver) StartSequencer(hash common.Hash) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sequencing {
+		return ErrSequencerActive
+	}
+	if d.elSyncing {
+		return ErrELSyncing
+	}
+	switch unsafe := d.unsafeHead(); hash {
+	case d.sequencer.Head().Hash:
+	case unsafe.Hash:
+		d.sequencer.SetHead(unsafe)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownSequencerHead, hash)
+	}
+	d.sequencer.SetSafeHead(d.pipeline.SafeHead().Hash, d.pipeline.Finalized().Hash)
+	d.sequencing = true
+	d.publishStatus()
+	d.log.Info("Sequencer started", "head", d.sequencer.Head())
+	return nil
+}
+
+// StopSequencer stops sequencing blocks and returns the hash of the last
+// sequenced block, which the next sequencer continues from.
+func (d *Driver) StopSequencer() (common.Hash, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.sequencing {
+		return common.Hash{}, ErrSequencerInactive
+	}
+	d.sequencing = false
+	d.sequencer.cancelBlock()
+	d.publishStatus()
+	head := d.sequencer.Head()
+	d.log.Info("Sequencer stopped", "head", head)
+	return head.Hash, nil
+}
+
+// SetSequencerDrain switches the drain mode of the sequencer. A draining
+// sequencer keeps building blocks, but does not gossip them, so that a
+// coordinator can let a demoted sequencer wind down while another one takes
+// over without the network seeing competing blocks.
+func (d *Driver) SetSequencerDrain(drain bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining != drain {
+		d.log.Info("Sequencer drain mode changed", "drain", drain)
+	}
+	d.draining = drain
+	d.publishStatus()
+}
+
+// ResetDerivationPipeline discards the data that the derivation pipeline
+// buffered and restarts the derivation after the safe head, without restarting
+// the node. The blocks after the safe head are derived again. It lets
+// operators recover from corrupted pipeline state, or read L1 again after
+// fixing the L1 endpoint. Derivation that halted on a critical error resumes.
+func (d *Driver) ResetDerivationPipeline() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.elSyncing {
+		return ErrELSyncing
+	}
+	if d.halted != nil {
+		d.log.Warn("Resuming halted derivation", "err", d.halted)
+		d.halted = nil
+		derivationHaltedGauge.Update(0)
+	}
+	d.resetPipeline()
+	d.requestStep()
+	return nil
+}
+
+// resetPipeline restarts the derivation after the safe head. It is called with
+// the lock held.
+func (d *Driver) resetPipeline() {
+	// The engine keeps the blocks after the safe head until they are derived
+	// again, so they remain the unsafe chain meanwhile.
+	d.unsafe = d.unsafeHead()
+	d.pipeline.ResetToSafeHead()
+	d.log.Warn("Derivation pipeline reset", "safe", d.pipeline.SafeHead(), "unsafe", d.unsafe)
+}
+
+// sequencerStatus is the state of the sequencer that SequencerStatus reports.
+type sequencerStatus struct {
+	active    bool
+	draining  bool
+	head      rollup.L2BlockRef
+	lastBuilt time.Time
+}
+
+// SequencerStatus reports whether the node sequences and how recently it built
+// a block. It does not wait for the event loop, which holds the driver lock
+// across engine and L1 requests, so that health checks answer while the node
+// is busy.
+func (d *Driver) SequencerStatus() rollup.SequencerStatus {
+	d.statusLock.RLock()
+	s := d.status
+	d.statusLock.RUnlock()
+
+	status := rollup.SequencerStatus{
+		Active:   s.active,
+		Draining: s.draining,
+		Head:     s.head,
+	}
+	if !s.lastBuilt.IsZero() {
+		status.LastBuiltTime = uint64(s.lastBuilt.Unix())
+		status.LastBuiltAge = uint64(time.Since(s.lastBuilt) / time.Second)
+	}
+	return status
+}
+
+// publishStatus updates the status reported by SequencerStatus. It is called
+// with the lock held, whenever the sequencer changed.
+func (d *Driver) publishStatus() {
+	status := sequencerStatus{
+		active:    d.sequencing,
+		draining:  d.draining,
+		head:      d.sequencer.Head(),
+		lastBuilt: d.lastBuilt,
+	}
+	d.statusLock.Lock()
+	d.status = status
+	d.statusLock.Unlock()
+}
+
+// DerivationTrace returns the recorded derivation steps, oldest first.
+func (d *Driver) DerivationTrace() ([]derive.StepTrace, error) {
+	if d.tracer == nil {
+		return nil, ErrTracingDisabled
+	}
+	return d.tracer.Steps(), nil
+}
+
+// DepositsByL1TxHash returns the indexed deposits of the given L1 transaction.
+// The index is safe for concurrent use, so the lock is not taken.
+func (d *Driver) DepositsByL1TxHash(hash common.Hash) ([]*derive.IndexedDeposit, error) {
+	if d.deposits == nil {
+		return nil, ErrDepositIndexDisabled
+	}
+	return d.deposits.DepositsByL1TxHash(hash)
+}
+
+// DepositBySourceHash returns the indexed deposit with the given source hash,
+// or nil if it is not indexed.
+func (d *Driver) DepositBySourceHash(hash common.Hash) (*derive.IndexedDeposit, error) {
+	if d.deposits == nil {
+		return nil, ErrDepositIndexDisabled
+	}
+	return d.deposits.DepositBySourceHash(hash)
+}
+
+// SubscribeSequencedPayloads subscribes to the payloads built by the sequencer,
+// which are to be gossiped. Payloads built in drain mode are not sent.
+func (d *Driver) SubscribeSequencedPayloads(ch chan<- *beacon.ExecutableDataV1) event.Subscription {
+	return d.scope.Track(d.sequenced.Subscribe(ch))
+}
+
+func (d *Driver) loop() {
+	defer d.wg.Done()
+
+	poll := time.NewTicker(derivePollInterval)
+	defer poll.Stop()
+	blockTime := time.NewTicker(time.Duration(d.cfg.BlockTime) * time.Second)
+	defer blockTime.Stop()
+	save := time.NewTicker(headsSaveInterval)
+	defer save.Stop()
+
+	// Derivation steps that fail temporarily, like on L1 or engine RPC
+	// errors, are retried with backoff. After a reset, like when the engine
+	// rejected a derived block as invalid, derivation continues once a new L1
+	// head arrives, which may change the derived block. Critical errors halt
+	// derivation until it is reset through the admin API.
+	var (
+		retryDelay time.Duration
+		retry      <-chan time.Time // fires when the failed step is retried
+		stalled    bool             // waiting for an L1 head after an invalid block
+		seal       <-chan time.Time // fires when the sequenced block in progress is sealed
+	)
+	d.mu.Lock()
+	d.published = d.heads()
+	d.mu.Unlock()
+	d.requestStep()
+	for {
+		d.publishHeads()
+		select {
+		case head := <-d.l1Heads:
+			d.log.Debug("New L1 head", "head", head)
+			stalled = false
+			if retry == nil {
+				d.requestStep()
+			}
+			if seal != nil {
+				if err := d.restartBlock(d.ctx); err != nil && d.ctx.Err() == nil {
+					d.log.Warn("Failed to restart L2 block on new L1 origin", "err", err)
+				}
+			}
+
+		case payload := <-d.payloads:
+			if err := d.importUnsafePayload(d.ctx, payload); err != nil && d.ctx.Err() == nil {
+				d.log.Warn("Failed to import unsafe payload", "hash", payload.BlockHash, "number", payload.Number, "err", err)
+			}
+
+		case <-d.stepReq:
+			err := d.deriveStep(d.ctx)
+			if err == nil || errors.Is(err, io.EOF) {
+				retryDelay = 0
+			}
+			if err != nil && !errors.Is(err, io.EOF) && d.ctx.Err() == nil {
+				d.publishError(err)
+			}
+			switch {
+			case err == nil:
+				// There may be more to derive from the current L1 data.
+				d.requestStep()
+			case errors.Is(err, io.EOF):
+				// Derivation caught up with L1, wait for the next head.
+			case d.ctx.Err() != nil:
+			case errors.Is(err, derive.ErrCritical):
+				d.log.Error("Derivation halted on critical error, reset the derivation pipeline to resume", "err", err)
+			case errors.Is(err, derive.ErrReset):
+				d.log.Error("Derivation reset, waiting for the next L1 head", "err", err)
+				if errors.Is(err, derive.ErrEngineInvalid) {
+					engineInvalidMeter.Mark(1)
+				}
+				stalled = true
+			default:
+				retryDelay = nextRetryInterval(retryDelay)
+				d.log.Warn("Derivation step failed, retrying", "err", err, "retry", retryDelay)
+				if errors.Is(err, derive.ErrEngineSyncing) || errors.Is(err, derive.ErrEngineUnavailable) {
+					engineRetryMeter.Mark(1)
+				}
+				retry = time.After(retryDelay)
+			}
+
+		case <-retry:
+			retry = nil
+			d.requestStep()
+
+		case <-poll.C:
+			if retry == nil && !stalled {
+				d.requestStep()
+			}
+
+		case <-blockTime.C:
+			ctx, cancel := context.WithTimeout(d.ctx, time.Duration(d.cfg.BlockTime)*time.Second)
+			if d.interrupt == 0 {
+				d.sequenceFailed(d.sequence(ctx))
+				cancel()
+				break
+			}
+			// A block that is still open, because the loop was busy when it
+			// was due, is sealed before the next one starts.
+			if seal != nil {
+				seal = nil
+				d.sequenceFailed(d.sealBlock(ctx))
+			}
+			started, err := d.startBlock(ctx)
+			d.sequenceFailed(err)
+			if started {
+				seal = time.After(d.interrupt)
+			}
+			cancel()
+
+		case <-seal:
+			seal = nil
+			ctx, cancel := context.WithTimeout(d.ctx, time.Duration(d.cfg.BlockTime)*time.Second)
+			d.sequenceFailed(d.sealBlock(ctx))
+			cancel()
+
+		case <-save.C:
+			if err := d.saveHeads(); err != nil {
+				d.log.Error("Failed to persist heads", "err", err)
+			}
+
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// requestStep schedules a derivation step, unless one is scheduled already.
+func (d *Driver) requestStep() {
+	select {
+	case d.stepReq <- struct{}{}:
+	default:
+	}
+}
+
+// sequenceFailed records an error of the sequencer.
+func (d *Driver) sequenceFailed(err error) {
+	if err != nil && d.ctx.Err() == nil {
+		d.log.Error("Failed to sequence L2 block", "err", err)
+		sequencerFailMeter.Mark(1)
+	}
+}
+
+// sequence builds the next block, if the sequencer is running, and hands it to
+// the gossip unless the sequencer drains.
+func (d *Driver) sequence(ctx context.Context) error {
+	return d.finishBlock(ctx, (*Sequencer).buildPayload)
+}
+
+// startBlock starts building the next block, if the sequencer is running, and
+// reports whether it did. The block is sealed by sealBlock.
+func (d *Driver) startBlock(ctx context.Context) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.sequencing {
+		return false, nil
+	}
+	if err := d.sequencer.startBlock(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// restartBlock restarts the block in progress on the next L1 origin, if it is
+// available now and has deposits.
+func (d *Driver) restartBlock(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.sequencing {
+		return nil
+	}
+	_, err := d.sequencer.restartBlock(ctx)
+	return err
+}
+
+// sealBlock seals the block in progress, if the sequencer is running, and hands
+// it to the gossip unless the sequencer drains.
+func (d *Driver) sealBlock(ctx context.Context) error {
+	return d.finishBlock(ctx, (*Sequencer).sealBlock)
+}
+
+// finishBlock obtains a block from the sequencer with the given method, if the
+// sequencer is running, and hands it to the gossip unless the sequencer drains.
+func (d *Driver) finishBlock(ctx context.Context, build func(*Sequencer, context.Context) (*beacon.ExecutableDataV1, rollup.L2BlockRef, error)) error {
+	d.mu.Lock()
+	if !d.sequencing {
+		d.mu.Unlock()
+		return nil
+	}
+	payload, _, err := build(d.sequencer, ctx)
+	if err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	d.lastBuilt = time.Now()
+	d.publishStatus()
+	draining := d.draining
+	d.mu.Unlock()
+
+	// The subscribers are not waited for while holding the lock, which the
+	// RPC handlers need as well.
+	if !draining {
+		d.sequenced.Send(payload)
+	}
+	return nil
+}
+
+// heads returns the current heads of the node.
+func (d *Driver) heads() Heads {
+	heads := Heads{
+		Unsafe:    d.unsafeHead(),
+		Safe:      d.pipeline.SafeHead(),
+		Finalized: d.pipeline.Finalized(),
+		CurrentL1: d.pipeline.CurrentL1(),
+		SafeL1:    d.pipeline.SafeL1(),
+	}
+	if d.sequencing {
+		heads.Unsafe = d.sequencer.Head()
+	}
+	return heads
+}
+
+// saveHeads persists the heads, if they changed since they were last saved.
+func (d *Driver) saveHeads() error {
+	if d.headsFile == "" {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	heads := d.heads()
+	heads.SystemConfig = d.sysCfg.Checkpoint()
+	if heads.equal(&d.saved) {
+		return nil
+	}
+	if err := saveHeads(d.headsFile, &heads); err != nil {
+		return err
+	}
+	d.saved = heads
+	return nil
+}
+
+// unsafeHead returns the head of the L2 chain, if the node is not sequencing.
+func (d *Driver) unsafeHead() rollup.L2BlockRef {
+	if head := d.pipeline.Head(); d.unsafe.Number <= head.Number {
+		return head
+	}
+	return d.unsafe
+}
+
+// importUnsafePayload queues a payload of the sequencer and imports the queued
+// payloads that extend the unsafe head. The sequencer itself ignores payloads,
+// and payloads at or below the head are dropped. If a gap remains in front of
+// the queued payloads, it is backfilled.
+func (d *Driver) importUnsafePayload(ctx context.Context, payload *beacon.ExecutableDataV1) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.sequencing {
+		return nil
+	}
+	if d.elSyncing {
+		return d.relayPayload(ctx, payload)
+	}
+	if head := d.unsafeHead(); uint64(payload.Number) <= head.Number {
+		d.log.Debug("Dropping stale unsafe payload", "hash", payload.BlockHash, "number", payload.Number, "head", head.Number)
+		return nil
+	}
+	d.queue.push(payload)
+	if err := d.importQueued(ctx); err != nil {
+		return err
+	}
+	if first := d.queue.first(); first != nil && uint64(first.Number) > d.unsafeHead().Number+1 && len(d.backfillSources) > 0 {
+		d.requestBackfill()
+	}
+	return nil
+}
+
+// importQueued imports the queued payloads that extend the unsafe head.
+func (d *Driver) importQueued(ctx context.Context) error {
+	for {
+		head := d.unsafeHead()
+		d.queue.prune(head.Number)
+		payload := d.queue.pop(head.Hash)
+		if payload == nil {
+			return nil
+		}
+		ref, err := derive.L2BlockRefFromPayload(d.cfg, payload)
+		if err != nil {
+			return err
+		}
+		if err := derive.ImportPayload(ctx, d.engine, d.forkchoice(), payload); err != nil {
+			return err
+		}
+		d.unsafe = ref
+		d.imported.add(payload)
+		unsafeHeadGauge.Update(int64(ref.Number))
+		d.log.Debug("Imported unsafe payload", "number", ref.Number, "hash", ref.Hash)
+	}
+}
+
+// deriveStep runs a single step of the derivation pipeline. Once the pipeline
+// runs out of L1 data, it updates the safe and finalized blocks and returns
+// io.EOF. Nothing is derived while the engine syncs the chain by itself, or
+// after a critical error until the pipeline is reset. Errors that require a
+// reset restart the derivation after the safe head.
+func (d *Driver) deriveStep(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.elSyncing || d.halted != nil {
+		return io.EOF
+	}
+
+	prev := d.pipeline.Head()
+	err := d.pipeline.Step(ctx)
+	if head := d.pipeline.Head(); head != prev {
+		if d.imported.contains(head) {
+			// The derived block was consolidated with an unsafe block, the
+			// unsafe blocks after it remain.
+			d.imported.prune(head.Number)
+		} else {
+			// Derived blocks replace the unsafe blocks that were imported
+			// on top of the previous head.
+			d.unsafe = rollup.L2BlockRef{}
+			d.imported.reset()
+		}
+	}
+	switch {
+	case errors.Is(err, derive.ErrCritical):
+		d.halted = err
+		derivationHaltedGauge.Update(1)
+		return err
+	case errors.Is(err, derive.ErrReset):
+		d.resetPipeline()
+		return err
+	case !errors.Is(err, io.EOF):
+		return err
+	}
+	// Queued payloads may extend the derived head, once derivation caught up.
+	if !d.sequencing {
+		if err := d.importQueued(ctx); err != nil {
+			d.log.Warn("Failed to import queued unsafe payload", "err", err)
+		}
+	}
+	if err := d.confirm(ctx); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// confirm updates the safe and finalized blocks.
+func (d *Driver) confirm(ctx context.Context) error {
+	changed, err := d.pipeline.Confirm(ctx)
+	if err != nil {
+		return err
+	}
+	heads := d.heads()
+	unsafeHeadGauge.Update(int64(heads.Unsafe.Number))
+	unsafeGapGauge.Update(int64(heads.Unsafe.Number) - int64(heads.Safe.Number))
+
+	// The sequencer owns the head of the chain, so it reports the new labels
+	// to the engine with its next block.
+	if d.sequencing {
+		d.sequencer.SetSafeHead(d.pipeline.SafeHead().Hash, d.pipeline.Finalized().Hash)
+		return nil
+	}
+	if !changed {
+		return nil
+	}
+	return derive.UpdateForkchoice(ctx, d.engine, d.forkchoice())
+}
+
+// forkchoice returns the forkchoice state of a node that is not sequencing.
+func (d *Driver) forkchoice() beacon.ForkchoiceStateV1 {
+	return beacon.ForkchoiceStateV1{
+		HeadBlockHash:      d.unsafeHead().Hash,
+		SafeBlockHash:      d.pipeline.SafeHead().Hash,
+		FinalizedBlockHash: d.pipeline.Finalized().Hash,
+	}
+}
+
+// SyncStatus reports the current L1 head and the progress of the L2 chain.
+func (d *Driver) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	head, err := d.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	d.mu.Lock()
+	heads := d.heads()
+	d.mu.Unlock()
+	return &rollup.SyncStatus{
+		HeadL1:      rollup.L1BlockRefFromHeader(head),
+		CurrentL1:   heads.CurrentL1,
+		UnsafeL2:    heads.Unsafe,
+		SafeL2:      heads.Safe,
+		FinalizedL2: heads.Finalized,
+	}, nil
+}
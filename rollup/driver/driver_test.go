@@ -0,0 +1,651 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+func newTestDriver(t *testing.T, cfg *rollup.Config, l1 *testutils.L1Chain, engine *testutils.Engine, dcfg Config) *Driver {
+	t.Helper()
+	d, err := NewDriver(cfg, dcfg, l1, engine, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// deriveAll runs derivation steps until the driver caught up with L1.
+func deriveAll(t *testing.T, d *Driver) {
+	t.Helper()
+	for {
+		err := d.deriveStep(context.Background())
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDriverSyncStatus(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		d            = newTestDriver(t, cfg, l1, engine, Config{Sequencing: true})
+	)
+	l1.AddBlock()
+	l1.AddBlock()
+	deriveAll(t, d)
+	ref, err := d.sequencer.BuildBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := d.SyncStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := rollup.SyncStatus{
+		HeadL1:      rollup.L1BlockRefFromHeader(l1.Head().Header()),
+		CurrentL1:   rollup.L1BlockRef{Hash: l1.Head().Hash(), Number: 2, ParentHash: l1.Block(1).Hash(), Time: l1.Head().Time()},
+		UnsafeL2:    ref,
+		SafeL2:      cfg.L2GenesisRef(),
+		FinalizedL2: cfg.L2GenesisRef(),
+	}
+	if *status != want {
+		t.Fatalf("unexpected sync status\nhave %+v\nwant %+v", status, want)
+	}
+}
+
+func TestDriverSequencerHandoff(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		d            = newTestDriver(t, cfg, l1, engine, Config{})
+	)
+	if _, err := d.StopSequencer(); !errors.Is(err, ErrSequencerInactive) {
+		t.Fatalf("stopped inactive sequencer: %v", err)
+	}
+	if err := d.StartSequencer(common.HexToHash("0x01")); !errors.Is(err, ErrUnknownSequencerHead) {
+		t.Fatalf("started sequencer on unknown block: %v", err)
+	}
+	// A verifier takes over at its derived head.
+	if err := d.StartSequencer(genesis.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StartSequencer(genesis.Hash()); !errors.Is(err, ErrSequencerActive) {
+		t.Fatalf("started sequencer twice: %v", err)
+	}
+	ref, err := d.sequencer.BuildBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := d.StopSequencer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != ref.Hash {
+		t.Fatalf("stopped at %s, want last sequenced block %s", hash, ref.Hash)
+	}
+	if status, _ := d.SyncStatus(ctx); status.UnsafeL2 != cfg.L2GenesisRef() {
+		t.Fatalf("stopped sequencer still reports unsafe head %d", status.UnsafeL2.Number)
+	}
+	// The sequencer resumes from the block it stopped at.
+	if err := d.StartSequencer(hash); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := d.SyncStatus(ctx); status.UnsafeL2 != ref {
+		t.Fatalf("resumed sequencer reports unsafe head %d", status.UnsafeL2.Number)
+	}
+	if _, err := d.StopSequencer(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriverSequencerDrain(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		d            = newTestDriver(t, cfg, l1, engine, Config{Sequencing: true})
+	)
+	payloads := make(chan *beacon.ExecutableDataV1, 10)
+	sub := d.SubscribeSequencedPayloads(payloads)
+	defer sub.Unsubscribe()
+
+	if status := d.SequencerStatus(); !status.Active || status.LastBuiltTime != 0 {
+		t.Fatalf("unexpected status before the first block %+v", status)
+	}
+	if err := d.sequence(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-payloads:
+		if payload.BlockHash != d.sequencer.Head().Hash {
+			t.Fatalf("sent payload %s, want sequenced block %s", payload.BlockHash, d.sequencer.Head().Hash)
+		}
+	default:
+		t.Fatal("sequenced payload not sent")
+	}
+	if status := d.SequencerStatus(); status.LastBuiltTime == 0 || status.Head != d.sequencer.Head() {
+		t.Fatalf("unexpected status after building a block %+v", status)
+	}
+	// A draining sequencer keeps building blocks, but does not send them.
+	d.SetSequencerDrain(true)
+	if err := d.sequence(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if head := d.sequencer.Head(); head.Number != 2 {
+		t.Fatalf("draining sequencer at block %d, want 2", head.Number)
+	}
+	select {
+	case payload := <-payloads:
+		t.Fatalf("draining sequencer sent payload %d", payload.Number)
+	default:
+	}
+	if status := d.SequencerStatus(); !status.Draining {
+		t.Fatal("drain mode not reported")
+	}
+
+	// The status is reported while the event loop holds the driver lock.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	done := make(chan rollup.SequencerStatus, 1)
+	go func() { done <- d.SequencerStatus() }()
+	select {
+	case status := <-done:
+		if status.Head.Number != 2 {
+			t.Fatalf("status reports head %d, want 2", status.Head.Number)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sequencer status waits for the driver lock")
+	}
+}
+
+func TestDriverInterruptWindow(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+	)
+	if _, err := NewDriver(cfg, Config{InterruptWindow: 2 * time.Second}, l1, engine, log.New()); err == nil {
+		t.Fatal("interrupt window of a whole block time accepted")
+	}
+	d := newTestDriver(t, cfg, l1, engine, Config{Sequencing: true, InterruptWindow: time.Second})
+	payloads := make(chan *beacon.ExecutableDataV1, 10)
+	sub := d.SubscribeSequencedPayloads(payloads)
+	defer sub.Unsubscribe()
+
+	// A started block is gossiped once it is sealed.
+	if started, err := d.startBlock(ctx); err != nil || !started {
+		t.Fatalf("block not started: %v", err)
+	}
+	if err := d.restartBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if head := d.sequencer.Head(); head.Number != 0 {
+		t.Fatalf("sequencer head %d before the block is sealed", head.Number)
+	}
+	if err := d.sealBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-payloads:
+		if payload.BlockHash != d.sequencer.Head().Hash || payload.Number != 1 {
+			t.Fatalf("sent payload %d %s, want sequenced block %v", payload.Number, payload.BlockHash, d.sequencer.Head())
+		}
+	default:
+		t.Fatal("sealed payload not sent")
+	}
+	// Stopping the sequencer abandons the block in progress.
+	if _, err := d.startBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.StopSequencer(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StartSequencer(d.sequencer.Head().Hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.sealBlock(ctx); !errors.Is(err, errNoBlockBuilding) {
+		t.Fatalf("expected errNoBlockBuilding, got %v", err)
+	}
+}
+
+func TestDriverImportsUnsafePayloads(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		seqEngine    = testutils.NewEngine(genesis)
+		sequencer    = NewSequencer(cfg, l1, seqEngine, cfg.L2GenesisRef(), log.New())
+		engine       = testutils.NewEngine(genesis)
+		d            = newTestDriver(t, cfg, l1, engine, Config{})
+	)
+	var payloads []*beacon.ExecutableDataV1
+	for i := 0; i < 3; i++ {
+		if _, err := sequencer.BuildBlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, beacon.BlockToExecutableData(seqEngine.Head()))
+	}
+	// Payloads that do not extend the unsafe head are dropped.
+	if err := d.importUnsafePayload(ctx, payloads[1]); err != nil {
+		t.Fatal(err)
+	}
+	if head := engine.Head(); head.Hash() != genesis.Hash() {
+		t.Fatalf("gapped payload imported, head %d", head.NumberU64())
+	}
+	d.Start()
+	defer d.Stop()
+	for _, payload := range payloads {
+		if err := d.OnUnsafePayload(ctx, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := sequencer.Head()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		status, err := d.SyncStatus(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.UnsafeL2 == want {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("unsafe head %d not imported, have %d", want.Number, status.UnsafeL2.Number)
+		}
+	}
+	d.mu.Lock()
+	head := engine.Head()
+	d.mu.Unlock()
+	if head.Hash() != want.Hash {
+		t.Fatalf("engine head %d, want %d", head.NumberU64(), want.Number)
+	}
+	// The imported head can be taken over by the sequencer.
+	if err := d.StartSequencer(want.Hash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriverStop(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		d            = newTestDriver(t, cfg, l1, testutils.NewEngine(genesis), Config{})
+	)
+	d.Start()
+	if err := d.OnL1Head(ctx, rollup.L1BlockRefFromHeader(l1.Head().Header())); err != nil {
+		t.Fatal(err)
+	}
+	d.Stop()
+
+	// Fill the queue, events are refused once it is full.
+	var err error
+	for i := 0; i <= cap(d.l1Heads) && err == nil; i++ {
+		err = d.OnL1Head(ctx, rollup.L1BlockRef{})
+	}
+	if !errors.Is(err, ErrDriverStopped) {
+		t.Fatalf("stopped driver accepted events: %v", err)
+	}
+}
+
+func TestDriverResumesFromHeads(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		dcfg         = Config{Sequencing: true, HeadsFile: filepath.Join(t.TempDir(), "heads.json")}
+		d            = newTestDriver(t, cfg, l1, engine, dcfg)
+	)
+	l1.AddBlock()
+	deriveAll(t, d)
+	for i := 0; i < 2; i++ {
+		if _, err := d.sequencer.BuildBlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Stop()
+
+	heads, err := LoadHeads(dcfg.HeadsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Heads{
+		Unsafe:    d.sequencer.Head(),
+		Safe:      cfg.L2GenesisRef(),
+		Finalized: cfg.L2GenesisRef(),
+		CurrentL1: rollup.L1BlockRefFromHeader(l1.Head().Header()),
+	}
+	if heads == nil || *heads != want {
+		t.Fatalf("wrong persisted heads\nhave %+v\nwant %+v", heads, want)
+	}
+	// The restarted sequencer continues its chain.
+	d = newTestDriver(t, cfg, l1, engine, dcfg)
+	ref, err := d.sequencer.BuildBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Number != want.Unsafe.Number+1 {
+		t.Fatalf("restarted sequencer built block %d, want %d", ref.Number, want.Unsafe.Number+1)
+	}
+}
+
+// hangingEngine is an engine that does not answer the given number of calls to
+// build a payload.
+type hangingEngine struct {
+	*testutils.Engine
+	hangs int32
+}
+
+func (e *hangingEngine) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	if attr != nil && atomic.AddInt32(&e.hangs, -1) >= 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return e.Engine.ForkchoiceUpdate(ctx, state, attr)
+}
+
+func TestDriverRetriesEngine(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = &hangingEngine{Engine: testutils.NewEngine(genesis), hangs: 3}
+	)
+	d, err := NewDriver(cfg, Config{EngineTimeout: 20 * time.Millisecond}, l1, engine, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Once the sequencing window passed, blocks are derived without batches.
+	for i := uint64(0); i <= cfg.SeqWindowSize; i++ {
+		l1.AddBlock()
+	}
+	for {
+		err := d.deriveStep(ctx)
+		if errors.Is(err, derive.ErrEngineUnavailable) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected engine timeout, got %v", err)
+		}
+	}
+	// The driver retries the step without waiting for the next L1 head.
+	d.Start()
+	defer d.Stop()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		status, err := d.SyncStatus(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.UnsafeL2.Number > 0 {
+			break
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("derivation not retried after engine timeouts")
+		}
+	}
+}
+
+// syncingEngine is an engine that cannot validate payloads while it syncs the
+// chain from its peers.
+type syncingEngine struct {
+	*testutils.Engine
+	syncing bool
+	head    common.Hash // last requested head
+}
+
+func (e *syncingEngine) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	e.head = state.HeadBlockHash
+	return e.Engine.ForkchoiceUpdate(ctx, state, attr)
+}
+
+func (e *syncingEngine) NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error) {
+	if e.syncing {
+		return &beacon.PayloadStatusV1{Status: beacon.SYNCING}, nil
+	}
+	return e.Engine.NewPayload(ctx, payload)
+}
+
+func TestDriverELSync(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		seqEngine    = testutils.NewEngine(genesis)
+		sequencer    = NewSequencer(cfg, l1, seqEngine, cfg.L2GenesisRef(), log.New())
+		engine       = &syncingEngine{Engine: testutils.NewEngine(genesis), syncing: true}
+	)
+	d, err := NewDriver(cfg, Config{ELSync: true}, l1, engine, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads []*beacon.ExecutableDataV1
+	for i := 0; i < 3; i++ {
+		if _, err := sequencer.BuildBlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, beacon.BlockToExecutableData(seqEngine.Head()))
+	}
+	// The engine is made to sync to the latest payload, without derivation.
+	if err := d.importUnsafePayload(ctx, payloads[1]); err != nil {
+		t.Fatal(err)
+	}
+	if engine.head != payloads[1].BlockHash {
+		t.Fatal("payload not relayed to the syncing engine")
+	}
+	if err := d.deriveStep(ctx); !errors.Is(err, io.EOF) {
+		t.Fatalf("derived during execution-layer sync: %v", err)
+	}
+	if err := d.StartSequencer(genesis.Hash()); !errors.Is(err, ErrELSyncing) {
+		t.Fatalf("started sequencer during execution-layer sync: %v", err)
+	}
+
+	// Once the engine has the chain, derivation continues from the synced
+	// block.
+	for hash, block := range seqEngine.Blocks {
+		engine.Blocks[hash] = block
+	}
+	engine.syncing = false
+	if err := d.importUnsafePayload(ctx, payloads[2]); err != nil {
+		t.Fatal(err)
+	}
+	want := sequencer.Head()
+	if d.elSyncing {
+		t.Fatal("execution-layer sync not finished")
+	}
+	if d.pipeline.SafeHead() != want || d.pipeline.Finalized() != want {
+		t.Fatalf("derivation not restarted at synced block %d: safe %d, finalized %d", want.Number, d.pipeline.SafeHead().Number, d.pipeline.Finalized().Number)
+	}
+	if engine.Forkchoice.FinalizedBlockHash != want.Hash {
+		t.Fatal("synced block not finalized in the engine")
+	}
+	if status, _ := d.SyncStatus(ctx); status.UnsafeL2 != want {
+		t.Fatalf("unsafe head %d, want synced block %d", status.UnsafeL2.Number, want.Number)
+	}
+	// A node that resumes or sequences does not sync through the engine.
+	d, err = NewDriver(cfg, Config{ELSync: true, Sequencing: true}, l1, engine, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.elSyncing {
+		t.Fatal("sequencer syncs through the engine")
+	}
+}
+
+func TestDriverUnsafeBlockSigners(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		d            = newTestDriver(t, cfg, l1, engine, Config{})
+	)
+	cfg.SystemConfigAddress = common.HexToAddress("0x5555555555555555555555555555555555555555")
+	update := func(updateType uint8, signer common.Address) {
+		ev := derive.MarshalConfigUpdateLogEvent(cfg.SystemConfigAddress, updateType, common.BytesToHash(signer[:]))
+		l1.AddBlockWithLogs([]*types.Transaction{types.NewTx(&types.LegacyTx{})}, [][]*types.Log{{ev}})
+		if err := d.updateSigners(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if signers := d.UnsafeBlockSigners(); signers != nil {
+		t.Fatalf("signers %v before the system config is read", signers)
+	}
+	oldKey, newKey := common.HexToAddress("0x01d"), common.HexToAddress("0x0e1")
+	update(derive.SystemConfigUpdateAddSigner, oldKey)
+	update(derive.SystemConfigUpdateAddSigner, newKey)
+	update(derive.SystemConfigUpdateRemoveSigner, oldKey)
+	if signers := d.UnsafeBlockSigners(); !sameSigners(signers, []common.Address{newKey}) {
+		t.Fatalf("signers %v after rotation, want %s", signers, newKey)
+	}
+	// Removing the last signer authorizes none, rather than the sequencer address.
+	update(derive.SystemConfigUpdateRemoveSigner, newKey)
+	if signers := d.UnsafeBlockSigners(); signers == nil || len(signers) != 0 {
+		t.Fatalf("signers %v after removing all, want none", signers)
+	}
+}
+
+func TestDriverResumesSystemConfig(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		dcfg         = Config{HeadsFile: filepath.Join(t.TempDir(), "heads.json")}
+	)
+	cfg.SystemConfigAddress = common.HexToAddress("0x5555555555555555555555555555555555555555")
+	d := newTestDriver(t, cfg, l1, engine, dcfg)
+	if d.sequencer.sysCfg != d.sysCfg {
+		t.Fatal("system config tracker not shared")
+	}
+	signer := common.HexToAddress("0x01d")
+	ev := derive.MarshalConfigUpdateLogEvent(cfg.SystemConfigAddress, derive.SystemConfigUpdateAddSigner, common.BytesToHash(signer[:]))
+	l1.AddBlockWithLogs([]*types.Transaction{types.NewTx(&types.LegacyTx{})}, [][]*types.Log{{ev}})
+	for i := 0; i < 300; i++ {
+		l1.AddBlock()
+	}
+	if err := d.updateSigners(ctx); err != nil {
+		t.Fatal(err)
+	}
+	d.Stop()
+
+	heads, err := LoadHeads(dcfg.HeadsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := d.sysCfg.Checkpoint()
+	if heads == nil || heads.SystemConfig == nil || heads.SystemConfig.L1 != want.L1 {
+		t.Fatalf("system config not persisted at L1 block %d", want.L1.Number)
+	}
+	// The restarted driver continues the traversal after the checkpoint, which
+	// is past the signer update.
+	d = newTestDriver(t, cfg, l1, engine, dcfg)
+	if cp := d.sysCfg.Checkpoint(); cp.L1 != want.L1 {
+		t.Fatalf("system config resumed at L1 block %d, want %d", cp.L1.Number, want.L1.Number)
+	}
+	if err := d.updateSigners(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if signers := d.UnsafeBlockSigners(); !sameSigners(signers, []common.Address{signer}) {
+		t.Fatalf("signers %v after restart, want %s", signers, signer)
+	}
+}
+
+// buildingEngine is an engine that counts the payloads it is asked to build.
+type buildingEngine struct {
+	*testutils.Engine
+	builds int
+}
+
+func (e *buildingEngine) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	if attr != nil {
+		e.builds++
+	}
+	return e.Engine.ForkchoiceUpdate(ctx, state, attr)
+}
+
+func TestDriverConsolidatesUnsafeBlocks(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		seqEngine    = testutils.NewEngine(genesis)
+		sequencer    = NewSequencer(cfg, l1, seqEngine, cfg.L2GenesisRef(), log.New())
+		engine       = &buildingEngine{Engine: testutils.NewEngine(genesis)}
+	)
+	d, err := NewDriver(cfg, Config{}, l1, engine, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Once the sequencing window passed, blocks are derived without batches,
+	// like the sequencer built them without transactions.
+	for i := uint64(0); i <= cfg.SeqWindowSize+1; i++ {
+		l1.AddBlock()
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := sequencer.BuildBlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.importUnsafePayload(ctx, beacon.BlockToExecutableData(seqEngine.Head())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unsafe := sequencer.Head()
+	for d.pipeline.Head().Number < unsafe.Number {
+		if err := d.deriveStep(ctx); err != nil {
+			t.Fatalf("derived head %d: %v", d.pipeline.Head().Number, err)
+		}
+		if d.unsafeHead() != unsafe {
+			t.Fatalf("unsafe head %d dropped after deriving block %d", unsafe.Number, d.pipeline.Head().Number)
+		}
+	}
+	if d.pipeline.Head() != unsafe {
+		t.Fatalf("derived head %v, want unsafe head %v", d.pipeline.Head(), unsafe)
+	}
+	if engine.builds != 0 {
+		t.Fatalf("engine built %d payloads for blocks it imported already", engine.builds)
+	}
+	// Derivation builds the blocks after the imported ones.
+	deriveAll(t, d)
+	if head := d.pipeline.Head(); head.Number <= unsafe.Number || engine.builds != int(head.Number-unsafe.Number) {
+		t.Fatalf("engine built %d payloads up to derived head %d", engine.builds, head.Number)
+	}
+}
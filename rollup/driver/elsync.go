@@ -0,0 +1,63 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// In execution-layer sync, a new node does not derive the chain from L1, which
+// takes long for a chain with a long history. Instead, it hands the unsafe
+// payloads of the sequencer to the engine as new heads, and the engine syncs
+// the chain up to them from its execution-layer peers. Once the engine has the
+// full chain up to a payload, derivation starts from that block, which is
+// trusted as safe and finalized.
+
+// relayPayload makes an unsafe payload the head of a syncing engine. It is
+// called with the lock held.
+func (d *Driver) relayPayload(ctx context.Context, payload *beacon.ExecutableDataV1) error {
+	if uint64(payload.Number) <= d.elSyncHead.Number {
+		return nil
+	}
+	ref, err := derive.L2BlockRefFromPayload(d.cfg, payload)
+	if err != nil {
+		return err
+	}
+	synced, err := derive.SyncToPayload(ctx, d.engine, d.forkchoice(), payload)
+	if err != nil {
+		return err
+	}
+	d.elSyncHead = ref
+	elSyncHeadGauge.Update(int64(ref.Number))
+	if !synced {
+		d.log.Debug("Engine syncing to unsafe payload", "number", ref.Number, "hash", ref.Hash)
+		return nil
+	}
+	d.log.Info("Execution-layer sync finished, deriving from the synced block", "number", ref.Number, "hash", ref.Hash, "l1origin", ref.L1Origin)
+	d.elSyncing = false
+	d.pipeline.Reset(ref)
+	d.unsafe = ref
+	d.imported.reset()
+	if err := d.pipeline.ForkchoiceUpdate(ctx); err != nil {
+		return err
+	}
+	d.requestStep()
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+const (
+	// DefaultEngineTimeout is the time the engine has to answer an Engine API
+	// call, unless configured otherwise.
+	DefaultEngineTimeout = 10 * time.Second
+
+	// engineRetryInterval is the delay before a derivation step that failed
+	// on a transient engine error is retried. It doubles with every failure
+	// in a row, up to maxEngineRetryInterval.
+	engineRetryInterval    = 250 * time.Millisecond
+	maxEngineRetryInterval = 30 * time.Second
+)
+
+// timeoutEngine bounds the duration of the Engine API calls, so that an engine
+// that hangs does not block the driver. Calls that time out fail with
+// derive.ErrEngineUnavailable.
+type timeoutEngine struct {
+	engine  derive.Engine
+	timeout time.Duration
+}
+
+func (e *timeoutEngine) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.engine.ForkchoiceUpdate(ctx, state, attr)
+}
+
+func (e *timeoutEngine) GetPayload(ctx context.Context, id beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.engine.GetPayload(ctx, id)
+}
+
+func (e *timeoutEngine) NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	return e.engine.NewPayload(ctx, payload)
+}
+
+// nextRetryInterval returns the delay before the next retry of a failed
+// derivation step, given the previous delay.
+func nextRetryInterval(prev time.Duration) time.Duration {
+	if prev == 0 {
+		return engineRetryInterval
+	}
+	if next := 2 * prev; next < maxEngineRetryInterval {
+		return next
+	}
+	return maxEngineRetryInterval
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// The event loop publishes the changes of the heads after every event it
+// handled, so that dashboards and failover tooling need not poll the sync
+// status. The events are sent without holding the lock, like the sequenced
+// payloads.
+
+// SubscribeEvents subscribes to the new heads of the node and to its failed
+// derivation steps.
+func (d *Driver) SubscribeEvents(ch chan<- rollup.NodeEvent) event.Subscription {
+	return d.scope.Track(d.events.Subscribe(ch))
+}
+
+// publishHeads sends an event for every head that changed since the previous
+// call, and updates the sequencer status after the step. It must only be
+// called by the event loop.
+func (d *Driver) publishHeads() {
+	d.mu.Lock()
+	heads := d.heads()
+	d.publishStatus()
+	d.mu.Unlock()
+
+	prev := d.published
+	d.published = heads
+	for _, h := range []struct {
+		kind      string
+		head, old rollup.L2BlockRef
+	}{
+		{rollup.EventUnsafeHead, heads.Unsafe, prev.Unsafe},
+		{rollup.EventSafeHead, heads.Safe, prev.Safe},
+		{rollup.EventFinalizedHead, heads.Finalized, prev.Finalized},
+	} {
+		if h.head != h.old {
+			head := h.head
+			d.events.Send(rollup.NodeEvent{Kind: h.kind, Head: &head})
+		}
+	}
+}
+
+// publishError sends the event of a failed derivation step.
+func (d *Driver) publishError(err error) {
+	class := "temporary"
+	switch {
+	case errors.Is(err, derive.ErrCritical):
+		class = "critical"
+	case errors.Is(err, derive.ErrReset):
+		class = "reset"
+	}
+	d.events.Send(rollup.NodeEvent{Kind: rollup.EventDerivationError, Error: err.Error(), Class: class})
+}
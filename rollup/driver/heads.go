@@ -0,0 +1,100 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// Heads are the L2 heads of the node and the L1 block that derivation read up
+// to. SafeL1 is the L1 block that the safe head was derived from, which must
+// be finalized before the safe head is. They are persisted, so that a restarted node resumes where it stopped
+// instead of deriving the chain again from genesis. Along with them, the system
+// config tracker is persisted, so that it does not traverse L1 from genesis
+// again either.
+type Heads struct {
+	Unsafe    rollup.L2BlockRef `json:"unsafeL2"`
+	Safe      rollup.L2BlockRef `json:"safeL2"`
+	Finalized rollup.L2BlockRef `json:"finalizedL2"`
+	CurrentL1 rollup.L1BlockRef `json:"currentL1"`
+	SafeL1    uint64            `json:"safeL1,omitempty"`
+
+	SystemConfig *derive.SystemConfigCheckpoint `json:"systemConfig,omitempty"`
+}
+
+// equal reports whether the heads are the same. System config checkpoints at
+// the same L1 block hold the same updates.
+func (h *Heads) equal(o *Heads) bool {
+	a, b := *h, *o
+	a.SystemConfig, b.SystemConfig = nil, nil
+	if a != b {
+		return false
+	}
+	if h.SystemConfig == nil || o.SystemConfig == nil {
+		return h.SystemConfig == o.SystemConfig
+	}
+	return h.SystemConfig.L1 == o.SystemConfig.L1
+}
+
+// LoadHeads reads the heads from the given file. It returns nil if the file
+// does not exist.
+func LoadHeads(path string) (*Heads, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var heads Heads
+	if err := json.Unmarshal(data, &heads); err != nil {
+		return nil, err
+	}
+	return &heads, nil
+}
+
+// saveHeads atomically replaces the heads in the given file.
+func saveHeads(path string, heads *Heads) error {
+	data, err := json.Marshal(heads)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the driver and the sequencer.
+
+package driver
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var (
+	sequencedBlockMeter = metrics.NewRegisteredMeter("rollup/sequencer/blocks", nil)
+	sequencerBuildTimer = metrics.NewRegisteredTimer("rollup/sequencer/build", nil)
+	sequencerFailMeter  = metrics.NewRegisteredMeter("rollup/sequencer/failures", nil)
+	restartedBlockMeter = metrics.NewRegisteredMeter("rollup/sequencer/restarts", nil)
+
+	unsafeHeadGauge = metrics.NewRegisteredGauge("rollup/driver/head/unsafe", nil)
+	unsafeGapGauge  = metrics.NewRegisteredGauge("rollup/driver/head/unsafegap", nil)
+
+	engineRetryMeter   = metrics.NewRegisteredMeter("rollup/driver/engine/retries", nil)
+	engineInvalidMeter = metrics.NewRegisteredMeter("rollup/driver/engine/invalid", nil)
+
+	elSyncHeadGauge = metrics.NewRegisteredGauge("rollup/driver/elsync/head", nil)
+
+	derivationHaltedGauge = metrics.NewRegisteredGauge("rollup/driver/derivation/halted", nil)
+
+	unsafeQueueGauge   = metrics.NewRegisteredGauge("rollup/driver/unsafe/queued", nil)
+	droppedUnsafeMeter = metrics.NewRegisteredMeter("rollup/driver/unsafe/dropped", nil)
+	backfilledMeter    = metrics.NewRegisteredMeter("rollup/driver/unsafe/backfilled", nil)
+)
@@ -0,0 +1,305 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package driver drives the L2 chain of the rollup node: it sequences new
+// blocks and derives the chain from L1.
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+var (
+	// errOriginReorged is returned when the L1 origin of the L2 head is no
+	// longer part of the canonical L1 chain.
+	errOriginReorged = errors.New("L1 origin of the L2 head was reorged out")
+	// errOriginBehind is returned when the next block would exceed the maximum
+	// sequencer drift, but the next L1 origin is not known yet.
+	errOriginBehind = errors.New("next L1 origin not available, sequencer drift exceeded")
+	// errNoBlockBuilding is returned when sealing a block while no block is in
+	// progress.
+	errNoBlockBuilding = errors.New("no block in progress")
+)
+
+// Sequencer builds new L2 blocks on top of the unsafe L2 head. The driver asks
+// for a block once per block time of the rollup. The blocks contain the deposits
+// of their L1 origin, followed by transactions from the transaction pool of the
+// engine.
+//
+// A block is either built at once, or started and sealed later, which gives the
+// engine time to fill it from its pool. A block in progress can be restarted on
+// a new L1 origin, to include the deposits of the origin without waiting for the
+// next block.
+type Sequencer struct {
+	cfg    *rollup.Config
+	l1     derive.L1Fetcher
+	sysCfg *derive.SystemConfigTracker
+	engine derive.Engine
+	log    log.Logger
+
+	mu        sync.Mutex
+	head      rollup.L2BlockRef // unsafe head
+	safe      common.Hash
+	finalized common.Hash
+	building  *blockBuild // block in progress, nil if none
+}
+
+// blockBuild is a block that the engine builds until the sequencer seals it.
+type blockBuild struct {
+	id     beacon.PayloadID
+	fc     beacon.ForkchoiceStateV1
+	attrs  *beacon.PayloadAttributesV1
+	origin *types.Header
+	start  time.Time
+}
+
+// NewSequencer creates a sequencer that builds on top of the given L2 head.
+func NewSequencer(cfg *rollup.Config, l1 derive.L1Fetcher, engine derive.Engine, head rollup.L2BlockRef, logger log.Logger) *Sequencer {
+	return &Sequencer{
+		cfg:       cfg,
+		l1:        l1,
+		sysCfg:    derive.NewSystemConfigTracker(cfg, l1, logger),
+		engine:    engine,
+		log:       logger,
+		head:      head,
+		safe:      cfg.Genesis.L2.Hash,
+		finalized: cfg.Genesis.L2.Hash,
+	}
+}
+
+// Head returns the last block built by the sequencer.
+func (s *Sequencer) Head() rollup.L2BlockRef {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.head
+}
+
+// SetHead sets the block that the next block is built on. A block in progress
+// is abandoned.
+func (s *Sequencer) SetHead(head rollup.L2BlockRef) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.head = head
+	s.building = nil
+}
+
+// SetSafeHead sets the safe and finalized blocks that are reported to the engine
+// along with new blocks.
+func (s *Sequencer) SetSafeHead(safe, finalized common.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.safe, s.finalized = safe, finalized
+}
+
+// SetSystemConfigTracker makes the sequencer look up the system config with the
+// given tracker, which may be shared with other users of the config.
+func (s *Sequencer) SetSystemConfigTracker(t *derive.SystemConfigTracker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sysCfg = t
+}
+
+// BuildBlock builds the next L2 block on top of the head and makes it the new
+// head.
+func (s *Sequencer) BuildBlock(ctx context.Context) (rollup.L2BlockRef, error) {
+	_, ref, err := s.buildPayload(ctx)
+	return ref, err
+}
+
+// buildPayload builds the next L2 block like BuildBlock, and also returns its
+// payload, for the driver to gossip.
+func (s *Sequencer) buildPayload(ctx context.Context) (*beacon.ExecutableDataV1, rollup.L2BlockRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.start(ctx); err != nil {
+		return nil, rollup.L2BlockRef{}, err
+	}
+	return s.seal(ctx)
+}
+
+// startBlock starts building the next L2 block on top of the head, abandoning
+// the block in progress, if any. The block is sealed by sealBlock.
+func (s *Sequencer) startBlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.start(ctx)
+}
+
+// sealBlock seals the block in progress and makes it the new head. It returns
+// the payload of the block, for the driver to gossip.
+func (s *Sequencer) sealBlock(ctx context.Context) (*beacon.ExecutableDataV1, rollup.L2BlockRef, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seal(ctx)
+}
+
+// cancelBlock abandons the block in progress, if any.
+func (s *Sequencer) cancelBlock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.building = nil
+}
+
+// restartBlock restarts the block in progress on the next L1 origin, if the
+// origin became available since the block was started and has deposits. The
+// block then includes the deposits, instead of the block after it. It reports
+// whether the block was restarted.
+func (s *Sequencer) restartBlock(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only a block that continues the epoch of the head can move on to the
+	// next epoch.
+	b := s.building
+	if b == nil || b.origin.Number.Uint64() != s.head.L1Origin.Number {
+		return false, nil
+	}
+	origin, err := s.nextOrigin(ctx, b.attrs.Timestamp)
+	if err != nil {
+		return false, err
+	}
+	if origin.Number.Uint64() == s.head.L1Origin.Number {
+		return false, nil
+	}
+	deposits, err := derive.EpochDeposits(ctx, s.cfg, s.l1, origin, 0)
+	if err != nil {
+		return false, err
+	}
+	if len(deposits) == 0 {
+		return false, nil
+	}
+	if err := s.startOn(ctx, origin, 0, deposits); err != nil {
+		// The engine may keep building the abandoned block, but it cannot be
+		// sealed anymore.
+		s.building = nil
+		return false, err
+	}
+	restartedBlockMeter.Mark(1)
+	s.log.Info("Restarted L2 block on new L1 origin", "number", s.head.Number+1, "l1origin", origin.Hash(), "previous", b.origin.Hash(), "deposits", len(deposits), "elapsed", time.Since(b.start))
+	return true, nil
+}
+
+// start starts building the next L2 block. The caller must hold the lock.
+func (s *Sequencer) start(ctx context.Context) error {
+	s.building = nil
+	origin, err := s.nextOrigin(ctx, s.head.Time+s.cfg.BlockTime)
+	if err != nil {
+		return err
+	}
+	var seqNumber uint64
+	if origin.Number.Uint64() == s.head.L1Origin.Number {
+		seqNumber = s.head.SequenceNumber + 1
+	}
+	deposits, err := derive.EpochDeposits(ctx, s.cfg, s.l1, origin, seqNumber)
+	if err != nil {
+		return err
+	}
+	return s.startOn(ctx, origin, seqNumber, deposits)
+}
+
+// startOn starts building the next L2 block on the given L1 origin, with the
+// given deposits of the origin. The caller must hold the lock.
+func (s *Sequencer) startOn(ctx context.Context, origin *types.Header, seqNumber uint64, deposits []*types.DepositTx) error {
+	start := time.Now()
+	sysCfg, err := s.sysCfg.At(ctx, origin)
+	if err != nil {
+		return err
+	}
+	attrs, err := derive.PreparePayloadAttributes(s.cfg, sysCfg, origin, seqNumber, s.head.Time+s.cfg.BlockTime, deposits)
+	if err != nil {
+		return err
+	}
+	fc := beacon.ForkchoiceStateV1{
+		HeadBlockHash:      s.head.Hash,
+		SafeBlockHash:      s.safe,
+		FinalizedBlockHash: s.finalized,
+	}
+	id, err := derive.StartPayload(ctx, s.engine, fc, attrs)
+	if err != nil {
+		return err
+	}
+	s.building = &blockBuild{id: id, fc: fc, attrs: attrs, origin: origin, start: start}
+	return nil
+}
+
+// seal seals the block in progress. The caller must hold the lock.
+func (s *Sequencer) seal(ctx context.Context) (*beacon.ExecutableDataV1, rollup.L2BlockRef, error) {
+	b := s.building
+	if b == nil {
+		return nil, rollup.L2BlockRef{}, errNoBlockBuilding
+	}
+	s.building = nil
+	payload, err := derive.SealPayload(ctx, s.engine, b.fc, b.id, b.attrs)
+	if err != nil {
+		return nil, rollup.L2BlockRef{}, err
+	}
+	ref, err := derive.L2BlockRefFromPayload(s.cfg, payload)
+	if err != nil {
+		return nil, rollup.L2BlockRef{}, err
+	}
+	s.head = ref
+	sequencedBlockMeter.Mark(1)
+	sequencerBuildTimer.UpdateSince(b.start)
+	unsafeHeadGauge.Update(int64(ref.Number))
+	s.log.Info("Sequenced L2 block", "number", ref.Number, "hash", ref.Hash, "l1origin", ref.L1Origin, "txs", len(payload.Transactions))
+	return payload, ref, nil
+}
+
+// nextOrigin selects the L1 origin of the block with the given timestamp. The
+// sequencer moves on to the next L1 block as soon as the timestamp allows it,
+// and must move on once the block would drift too far ahead of its L1 origin.
+// It stays on the L1 origin while deposits of the epoch are pending though.
+func (s *Sequencer) nextOrigin(ctx context.Context, timestamp uint64) (*types.Header, error) {
+	current, err := s.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(s.head.L1Origin.Number))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L1 origin %d: %w", s.head.L1Origin.Number, err)
+	}
+	if current.Hash() != s.head.L1Origin.Hash {
+		return nil, fmt.Errorf("%w: have %s, canonical %s", errOriginReorged, s.head.L1Origin, current.Hash())
+	}
+	pending, err := derive.PendingDeposits(ctx, s.cfg, s.l1, s.head)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return current, nil
+	}
+	next, err := s.l1.HeaderByNumber(ctx, new(big.Int).SetUint64(s.head.L1Origin.Number+1))
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return nil, fmt.Errorf("failed to fetch next L1 origin: %w", err)
+	}
+	if next != nil && next.ParentHash == current.Hash() && next.Time <= timestamp {
+		return next, nil
+	}
+	if timestamp > current.Time+s.cfg.MaxSequencerDrift {
+		return nil, errOriginBehind
+	}
+	return current, nil
+}
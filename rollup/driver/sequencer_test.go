@@ -0,0 +1,215 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+func newTestConfig(l1 *testutils.L1Chain) (*rollup.Config, *types.Block) {
+	l2Genesis := types.NewBlockWithHeader(&types.Header{
+		Number:     new(big.Int),
+		Time:       l1.Head().Time(),
+		BaseFee:    big.NewInt(1),
+		Difficulty: new(big.Int),
+	})
+	cfg := &rollup.Config{
+		Genesis: rollup.Genesis{
+			L1:     rollup.BlockID{Hash: l1.Head().Hash(), Number: l1.Head().NumberU64()},
+			L2:     rollup.BlockID{Hash: l2Genesis.Hash(), Number: 0},
+			L2Time: l2Genesis.Time(),
+		},
+		BlockTime:           2,
+		MaxSequencerDrift:   10,
+		SeqWindowSize:       10,
+		L1ChainID:           big.NewInt(900),
+		L2ChainID:           big.NewInt(901),
+		FeeRecipientAddress: derive.SequencerFeeVaultAddr,
+	}
+	return cfg, l2Genesis
+}
+
+func TestSequencerOriginSelection(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		seq          = NewSequencer(cfg, l1, engine, cfg.L2GenesisRef(), log.New())
+	)
+	// Without new L1 blocks, the sequencer keeps the genesis origin up to the
+	// maximum drift.
+	for i := uint64(1); i <= cfg.MaxSequencerDrift/cfg.BlockTime; i++ {
+		ref, err := seq.BuildBlock(ctx)
+		if err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		if ref.Number != i || ref.Time != cfg.Genesis.L2Time+i*cfg.BlockTime {
+			t.Fatalf("unexpected block %+v", ref)
+		}
+		if ref.L1Origin != cfg.Genesis.L1 || ref.SequenceNumber != i {
+			t.Fatalf("block %d: unexpected origin %v, sequence number %d", i, ref.L1Origin, ref.SequenceNumber)
+		}
+	}
+	if _, err := seq.BuildBlock(ctx); !errors.Is(err, errOriginBehind) {
+		t.Fatalf("expected drift error, got %v", err)
+	}
+	// Once the next L1 block is known, the sequencer moves on to it.
+	next := l1.AddBlock()
+	ref, err := seq.BuildBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.L1Origin != (rollup.BlockID{Hash: next.Hash(), Number: 1}) || ref.SequenceNumber != 0 {
+		t.Fatalf("unexpected origin %v, sequence number %d", ref.L1Origin, ref.SequenceNumber)
+	}
+	if engine.Forkchoice.HeadBlockHash != ref.Hash {
+		t.Fatal("engine head not updated")
+	}
+	if engine.Forkchoice.SafeBlockHash != cfg.Genesis.L2.Hash {
+		t.Fatal("sequenced block marked as safe")
+	}
+}
+
+func TestSequencerAdvancesOriginEarly(t *testing.T) {
+	var (
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		seq          = NewSequencer(cfg, l1, engine, cfg.L2GenesisRef(), log.New())
+	)
+	l1.AddBlock() // timestamp genesis + 4
+	l1.AddBlock()
+
+	// The next origin is only adopted once the L2 timestamp reaches it, one L1
+	// block at a time.
+	want := []uint64{0, 1, 1, 2}
+	for i, num := range want {
+		ref, err := seq.BuildBlock(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.L1Origin.Number != num {
+			t.Fatalf("block %d: origin %d, want %d", i+1, ref.L1Origin.Number, num)
+		}
+	}
+}
+
+func TestSequencerIncludesTxPool(t *testing.T) {
+	var (
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		seq          = NewSequencer(cfg, l1, engine, cfg.L2GenesisRef(), log.New())
+	)
+	to := common.HexToAddress("0x1234")
+	tx := types.NewTx(&types.LegacyTx{To: &to, Gas: 21000, GasPrice: big.NewInt(1), V: big.NewInt(27), R: big.NewInt(1), S: big.NewInt(1)})
+	engine.TxPool = []*types.Transaction{tx}
+
+	ref, err := seq.BuildBlock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := engine.Blocks[ref.Hash].Transactions()
+	if len(txs) != 2 {
+		t.Fatalf("block has %d transactions, want 2", len(txs))
+	}
+	if txs[0].Type() != types.DepositTxType || txs[1].Hash() != tx.Hash() {
+		t.Fatal("block does not start with the L1 info deposit followed by the pool transaction")
+	}
+}
+
+func TestSequencerRestartsBlock(t *testing.T) {
+	var (
+		ctx             = context.Background()
+		l1              = testutils.NewL1Chain()
+		cfg, genesis    = newTestConfig(l1)
+		engine          = testutils.NewEngine(genesis)
+		seq             = NewSequencer(cfg, l1, engine, cfg.L2GenesisRef(), log.New())
+		depositContract = common.HexToAddress("0xde90517")
+	)
+	cfg.DepositContractAddress = depositContract
+	if _, _, err := seq.sealBlock(ctx); !errors.Is(err, errNoBlockBuilding) {
+		t.Fatalf("expected errNoBlockBuilding, got %v", err)
+	}
+	if _, err := seq.BuildBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Block 2 starts on the genesis origin. The next L1 block arrives while it
+	// is built, but it has no deposits, so the block stays on its origin.
+	if err := seq.startBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if restarted, err := seq.restartBlock(ctx); err != nil || restarted {
+		t.Fatalf("block restarted without new L1 origin: %v", err)
+	}
+	l1.AddBlock()
+	if restarted, err := seq.restartBlock(ctx); err != nil || restarted {
+		t.Fatalf("block restarted on L1 origin without deposits: %v", err)
+	}
+	if _, ref, err := seq.sealBlock(ctx); err != nil {
+		t.Fatal(err)
+	} else if ref.Number != 2 || ref.L1Origin.Number != 0 {
+		t.Fatalf("unexpected block %+v", ref)
+	}
+
+	// Block 3 starts epoch 1, which block 4 continues, until epoch 2 arrives
+	// with a deposit while it is built.
+	if _, err := seq.BuildBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := seq.startBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x1234")
+	dep := &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Value: big.NewInt(1), Gas: 50_000, Data: []byte{}}
+	key, _ := crypto.GenerateKey()
+	depLogTx := types.MustSignNewTx(key, types.LatestSignerForChainID(cfg.L1ChainID), &types.LegacyTx{To: &depositContract, Gas: 100_000, GasPrice: big.NewInt(1)})
+	epoch2 := l1.AddBlockWithLogs([]*types.Transaction{depLogTx}, [][]*types.Log{{derive.MarshalDepositLogEvent(depositContract, dep)}})
+	if restarted, err := seq.restartBlock(ctx); err != nil || !restarted {
+		t.Fatalf("block not restarted on L1 origin with deposits: %v", err)
+	}
+	// The restarted block is on the next origin already.
+	if restarted, err := seq.restartBlock(ctx); err != nil || restarted {
+		t.Fatalf("block restarted twice: %v", err)
+	}
+	_, ref, err := seq.sealBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Number != 4 || ref.L1Origin != (rollup.BlockID{Hash: epoch2.Hash(), Number: 2}) || ref.SequenceNumber != 0 {
+		t.Fatalf("unexpected block %+v", ref)
+	}
+	txs := engine.Blocks[ref.Hash].Transactions()
+	if len(txs) != 2 || txs[1].Type() != types.DepositTxType || txs[1].To() == nil || *txs[1].To() != to {
+		t.Fatal("restarted block does not include the deposit of the new origin")
+	}
+	if engine.Forkchoice.HeadBlockHash != ref.Hash {
+		t.Fatal("engine head not updated")
+	}
+}
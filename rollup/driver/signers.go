@@ -0,0 +1,95 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// signersPollInterval is the interval at which the unsafe block signers are
+// read from the system config at the L1 head.
+const signersPollInterval = 12 * time.Second
+
+// UnsafeBlockSigners returns the keys that the system config at the L1 head
+// authorizes to sign unsafe payloads. They are part of the system config on L1,
+// so that the sequencer key can be rotated without restarting the other nodes,
+// and are followed at the L1 head rather than at the L1 origin of the derived
+// head, so that a rotation takes effect once it is included in L1, not once the
+// batches after it are derived.
+//
+// The signers are nil before the system config is known or updates them, and
+// empty if it authorizes none.
+func (d *Driver) UnsafeBlockSigners() []common.Address {
+	d.signersLock.RLock()
+	defer d.signersLock.RUnlock()
+	return d.signers
+}
+
+// signersLoop follows the unsafe block signers of the system config.
+func (d *Driver) signersLoop() {
+	defer d.wg.Done()
+
+	poll := time.NewTicker(signersPollInterval)
+	defer poll.Stop()
+	for {
+		if err := d.updateSigners(d.ctx); err != nil && d.ctx.Err() == nil {
+			d.log.Warn("Failed to update unsafe block signers", "err", err)
+		}
+		select {
+		case <-poll.C:
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// updateSigners reads the unsafe block signers from the system config at the
+// L1 head.
+func (d *Driver) updateSigners(ctx context.Context) error {
+	head, err := d.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return err
+	}
+	sysCfg, err := d.sysCfg.At(ctx, head)
+	if err != nil {
+		return err
+	}
+	d.signersLock.Lock()
+	changed := !sameSigners(d.signers, sysCfg.UnsafeBlockSigners)
+	d.signers = sysCfg.UnsafeBlockSigners
+	d.signersLock.Unlock()
+
+	if changed {
+		d.log.Info("Updated unsafe block signers", "l1block", head.Number, "signers", sysCfg.UnsafeBlockSigners)
+	}
+	return nil
+}
+
+func sameSigners(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
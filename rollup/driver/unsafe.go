@@ -0,0 +1,270 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+const (
+	// maxUnsafeQueueSize is the maximum amount of payload data that is queued
+	// ahead of the unsafe head. The payloads furthest ahead are dropped
+	// beyond that.
+	maxUnsafeQueueSize = 64 * 1024 * 1024
+
+	// unsafePayloadOverhead is the amount of memory accounted for every queued
+	// payload on top of its transactions.
+	unsafePayloadOverhead = 1024
+
+	// maxBackfillPayloads is the maximum number of payloads requested at once
+	// to fill a gap in front of the queued payloads.
+	maxBackfillPayloads = 64
+
+	// backfillTimeout is the time a payload source has to serve a request.
+	backfillTimeout = 10 * time.Second
+)
+
+// PayloadSource provides unsafe payloads by number, to fill the gaps between
+// the unsafe head and the payloads received from the gossip.
+type PayloadSource interface {
+	// PayloadsByNumber returns consecutive payloads starting at the given
+	// number. It may return fewer payloads than requested.
+	PayloadsByNumber(ctx context.Context, from, count uint64) ([]*beacon.ExecutableDataV1, error)
+}
+
+// unsafeQueue holds the unsafe payloads that do not extend the unsafe head yet,
+// until the payloads in between arrive.
+type unsafeQueue struct {
+	payloads []*beacon.ExecutableDataV1 // by number, then arrival
+	size     int
+}
+
+func payloadSize(payload *beacon.ExecutableDataV1) int {
+	size := unsafePayloadOverhead + len(payload.ExtraData) + len(payload.LogsBloom)
+	for _, tx := range payload.Transactions {
+		size += len(tx)
+	}
+	return size
+}
+
+// push queues a payload, unless it is queued already. The payloads furthest
+// ahead are dropped while the queue is over its size limit.
+func (q *unsafeQueue) push(payload *beacon.ExecutableDataV1) {
+	for _, p := range q.payloads {
+		if p.BlockHash == payload.BlockHash {
+			return
+		}
+	}
+	i := sort.Search(len(q.payloads), func(i int) bool { return q.payloads[i].Number > payload.Number })
+	q.payloads = append(q.payloads, nil)
+	copy(q.payloads[i+1:], q.payloads[i:])
+	q.payloads[i] = payload
+	q.size += payloadSize(payload)
+
+	for q.size > maxUnsafeQueueSize {
+		last := q.payloads[len(q.payloads)-1]
+		q.payloads = q.payloads[:len(q.payloads)-1]
+		q.size -= payloadSize(last)
+		droppedUnsafeMeter.Mark(1)
+	}
+	unsafeQueueGauge.Update(int64(len(q.payloads)))
+}
+
+// pop removes and returns a queued payload that extends the given block, nil if
+// there is none.
+func (q *unsafeQueue) pop(parent common.Hash) *beacon.ExecutableDataV1 {
+	for i, p := range q.payloads {
+		if p.ParentHash == parent {
+			q.payloads = append(q.payloads[:i], q.payloads[i+1:]...)
+			q.size -= payloadSize(p)
+			unsafeQueueGauge.Update(int64(len(q.payloads)))
+			return p
+		}
+	}
+	return nil
+}
+
+// prune drops the payloads up to the given block number.
+func (q *unsafeQueue) prune(number uint64) {
+	i := sort.Search(len(q.payloads), func(i int) bool { return uint64(q.payloads[i].Number) > number })
+	for _, p := range q.payloads[:i] {
+		q.size -= payloadSize(p)
+	}
+	q.payloads = append(q.payloads[:0], q.payloads[i:]...)
+	unsafeQueueGauge.Update(int64(len(q.payloads)))
+}
+
+// first returns the queued payload with the lowest number, nil if the queue is
+// empty.
+func (q *unsafeQueue) first() *beacon.ExecutableDataV1 {
+	if len(q.payloads) == 0 {
+		return nil
+	}
+	return q.payloads[0]
+}
+
+// importedPayloads are the unsafe payloads that were imported ahead of the
+// derived head, oldest first. The derivation pipeline consolidates with them.
+// Beyond maxUnsafeQueueSize, further payloads are imported without being kept,
+// and are built again when they are derived.
+type importedPayloads struct {
+	payloads []*beacon.ExecutableDataV1
+	size     int
+}
+
+// add keeps an imported payload, which extends the last kept one.
+func (p *importedPayloads) add(payload *beacon.ExecutableDataV1) {
+	if n := len(p.payloads); n > 0 && p.payloads[n-1].BlockHash != payload.ParentHash {
+		return
+	}
+	if size := payloadSize(payload); p.size+size <= maxUnsafeQueueSize {
+		p.payloads = append(p.payloads, payload)
+		p.size += size
+	}
+}
+
+// contains reports whether the given block is one of the kept payloads.
+func (p *importedPayloads) contains(ref rollup.L2BlockRef) bool {
+	payload := p.UnsafePayload(ref.Number)
+	return payload != nil && payload.BlockHash == ref.Hash
+}
+
+// prune drops the payloads up to the given block number.
+func (p *importedPayloads) prune(number uint64) {
+	for len(p.payloads) > 0 && p.payloads[0].Number <= number {
+		p.size -= payloadSize(p.payloads[0])
+		p.payloads = p.payloads[1:]
+	}
+}
+
+// reset drops all payloads.
+func (p *importedPayloads) reset() {
+	p.payloads, p.size = nil, 0
+}
+
+// UnsafePayload implements derive.UnsafeBlocks.
+func (p *importedPayloads) UnsafePayload(number uint64) *beacon.ExecutableDataV1 {
+	if len(p.payloads) == 0 || number < p.payloads[0].Number {
+		return nil
+	}
+	if i := number - p.payloads[0].Number; i < uint64(len(p.payloads)) {
+		return p.payloads[i]
+	}
+	return nil
+}
+
+// requestBackfill schedules filling the gap in front of the queued payloads,
+// unless it is scheduled already.
+func (d *Driver) requestBackfill() {
+	select {
+	case d.backfillReq <- struct{}{}:
+	default:
+	}
+}
+
+// backfillLoop fills the gaps in front of the queued payloads, one at a time.
+func (d *Driver) backfillLoop() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.backfillReq:
+			d.backfill(d.ctx)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// backfill requests the payloads between the unsafe head and the first queued
+// payload from the payload sources, in order, and hands them to the driver as
+// if they were gossiped. They are imported once they extend the head.
+func (d *Driver) backfill(ctx context.Context) {
+	d.mu.Lock()
+	head, first := d.unsafeHead(), d.queue.first()
+	d.mu.Unlock()
+	if first == nil || uint64(first.Number) <= head.Number+1 {
+		return
+	}
+	from := head.Number + 1
+	count := uint64(first.Number) - from
+	if count > maxBackfillPayloads {
+		count = maxBackfillPayloads
+	}
+	for _, source := range d.backfillSources {
+		fetchCtx, cancel := context.WithTimeout(ctx, backfillTimeout)
+		payloads, err := source.PayloadsByNumber(fetchCtx, from, count)
+		cancel()
+		if err != nil || len(payloads) == 0 {
+			d.log.Debug("Failed to backfill unsafe payloads", "from", from, "count", count, "err", err)
+			continue
+		}
+		d.log.Debug("Backfilled unsafe payloads", "from", from, "count", len(payloads))
+		backfilledMeter.Mark(int64(len(payloads)))
+		for _, payload := range payloads {
+			if err := d.OnUnsafePayload(ctx, payload); err != nil {
+				return
+			}
+		}
+		return
+	}
+	d.log.Warn("Unsafe payloads missing, waiting for L1", "from", from, "count", count)
+}
+
+// BlockByNumberClient is the part of an L2 RPC client that payloads are read
+// from.
+type BlockByNumberClient interface {
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+}
+
+// RPCPayloadSource reads unsafe payloads from the RPC of an L2 node that has
+// them, such as the execution engine of the sequencer. The node is trusted,
+// its payloads are not authenticated.
+type RPCPayloadSource struct {
+	client BlockByNumberClient
+}
+
+// NewRPCPayloadSource creates a payload source that reads blocks from the given
+// L2 RPC client.
+func NewRPCPayloadSource(client BlockByNumberClient) *RPCPayloadSource {
+	return &RPCPayloadSource{client: client}
+}
+
+// PayloadsByNumber implements PayloadSource.
+func (s *RPCPayloadSource) PayloadsByNumber(ctx context.Context, from, count uint64) ([]*beacon.ExecutableDataV1, error) {
+	var payloads []*beacon.ExecutableDataV1
+	for n := from; n < from+count; n++ {
+		block, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(n))
+		if err != nil {
+			if len(payloads) > 0 {
+				break
+			}
+			return nil, fmt.Errorf("failed to fetch L2 block %d: %w", n, err)
+		}
+		payloads = append(payloads, beacon.BlockToExecutableData(block))
+	}
+	return payloads, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+func TestUnsafeQueue(t *testing.T) {
+	var q unsafeQueue
+	payload := func(number uint64, size int) *beacon.ExecutableDataV1 {
+		return &beacon.ExecutableDataV1{
+			ParentHash:   common.BigToHash(new(big.Int).SetUint64(number - 1)),
+			BlockHash:    common.BigToHash(new(big.Int).SetUint64(number)),
+			Number:       number,
+			Transactions: [][]byte{make([]byte, size)},
+		}
+	}
+	q.push(payload(3, 10))
+	q.push(payload(5, 10))
+	q.push(payload(4, 10))
+	q.push(payload(4, 10))
+	if len(q.payloads) != 3 || q.first().Number != 3 {
+		t.Fatalf("unexpected queue: %d payloads, first %d", len(q.payloads), q.first().Number)
+	}
+	if p := q.pop(payload(4, 0).BlockHash); p == nil || p.Number != 5 {
+		t.Fatal("payload extending block 4 not found")
+	}
+	q.prune(3)
+	if len(q.payloads) != 1 || q.first().Number != 4 {
+		t.Fatal("payloads at or below the head not pruned")
+	}
+	// The payloads furthest ahead are dropped beyond the size limit.
+	q.push(payload(10, maxUnsafeQueueSize/2))
+	q.push(payload(6, maxUnsafeQueueSize/2))
+	if len(q.payloads) != 2 || q.payloads[1].Number != 6 {
+		t.Fatalf("wrong payloads dropped over the size limit, have %d", len(q.payloads))
+	}
+	q.prune(100)
+	if q.size != 0 || q.first() != nil {
+		t.Fatalf("queue not empty: %d bytes", q.size)
+	}
+}
+
+// testPayloadSource serves payloads from a list, starting at block 1.
+type testPayloadSource struct {
+	payloads []*beacon.ExecutableDataV1
+	requests chan [2]uint64
+}
+
+func (s *testPayloadSource) PayloadsByNumber(ctx context.Context, from, count uint64) ([]*beacon.ExecutableDataV1, error) {
+	s.requests <- [2]uint64{from, count}
+	if from == 0 || from > uint64(len(s.payloads)) {
+		return nil, errors.New("unknown payloads")
+	}
+	end := from - 1 + count
+	if end > uint64(len(s.payloads)) {
+		end = uint64(len(s.payloads))
+	}
+	return s.payloads[from-1 : end], nil
+}
+
+func TestDriverBackfillsUnsafePayloads(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		seqEngine    = testutils.NewEngine(genesis)
+		sequencer    = NewSequencer(cfg, l1, seqEngine, cfg.L2GenesisRef(), log.New())
+		engine       = testutils.NewEngine(genesis)
+		source       = &testPayloadSource{requests: make(chan [2]uint64, 10)}
+		d            = newTestDriver(t, cfg, l1, engine, Config{Backfill: []PayloadSource{source}})
+	)
+	for i := 0; i < 5; i++ {
+		if _, err := sequencer.BuildBlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+		source.payloads = append(source.payloads, beacon.BlockToExecutableData(seqEngine.Head()))
+	}
+	d.Start()
+	defer d.Stop()
+
+	// Only the last payload is gossiped, the ones before it are requested.
+	if err := d.OnUnsafePayload(ctx, source.payloads[4]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case req := <-source.requests:
+		if req != [2]uint64{1, 4} {
+			t.Fatalf("requested %d payloads from %d, want 4 from 1", req[1], req[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing payloads not requested")
+	}
+	want := sequencer.Head()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		status, err := d.SyncStatus(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.UnsafeL2 == want {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("unsafe head %d not imported, have %d", want.Number, status.UnsafeL2.Number)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if head := engine.Head(); head.Hash() != want.Hash {
+		t.Fatalf("engine head %d, want %d", head.NumberU64(), want.Number)
+	}
+	if d.queue.first() != nil {
+		t.Fatal("imported payloads left in the queue")
+	}
+}
+
+// testBlockClient serves the blocks of a test engine by number.
+type testBlockClient struct {
+	engine *testutils.Engine
+}
+
+func (c *testBlockClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	for _, block := range c.engine.Blocks {
+		if block.Number().Cmp(number) == 0 {
+			return block, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func TestRPCPayloadSource(t *testing.T) {
+	var (
+		ctx          = context.Background()
+		l1           = testutils.NewL1Chain()
+		cfg, genesis = newTestConfig(l1)
+		engine       = testutils.NewEngine(genesis)
+		sequencer    = NewSequencer(cfg, l1, engine, cfg.L2GenesisRef(), log.New())
+	)
+	for i := 0; i < 3; i++ {
+		if _, err := sequencer.BuildBlock(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := NewRPCPayloadSource(&testBlockClient{engine})
+	payloads, err := source.PayloadsByNumber(ctx, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 2 || payloads[1].BlockHash != engine.Head().Hash() {
+		t.Fatalf("unexpected payloads: %d", len(payloads))
+	}
+	if _, err := source.PayloadsByNumber(ctx, 4, 1); err == nil {
+		t.Fatal("unknown payloads returned")
+	}
+}
@@ -0,0 +1,442 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package e2e runs a complete rollup in-process for end-to-end tests: an L1
+// chain, a sequencer and a verifier, each with its own L2 execution engine, and
+// a batch submitter that posts the blocks of the sequencer to L1.
+package e2e
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/batcher"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/driver"
+	"github.com/ethereum/go-ethereum/rollup/engine"
+	"github.com/ethereum/go-ethereum/rollup/genesis"
+)
+
+// Accounts of the test system. The batcher and the users are funded on L1, the
+// users also on L2.
+var (
+	BatcherKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	AliceKey, _   = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	BobKey, _     = crypto.HexToECDSA("49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee")
+)
+
+// DepositContractAddr is the L1 address of the deposit contract.
+var DepositContractAddr = common.HexToAddress("0xde90517000000000000000000000000000000001")
+
+// depositContractCode is a minimal stand-in for the L1 deposit contract. It
+// emits the deposit event of the caller, with the padded target address and the
+// ABI encoded opaque data taken from the calldata:
+//
+//	calldatacopy(0, 32, calldatasize-32)
+//	log4(0, calldatasize-32, DepositEventABIHash, caller, calldataload(0), 0)
+//
+// Unlike the real contract, it does not check that the value matches the mint.
+var depositContractCode = append(append(
+	common.FromHex("0x602036038060206000376000600035337f"),
+	derive.DepositEventABIHash[:]...),
+	common.FromHex("0x846000a400")...,
+)
+
+// SystemConfig contains the settings of the test system.
+type SystemConfig struct {
+	// L1BlockTime is the interval at which L1 blocks are produced.
+	L1BlockTime time.Duration
+	// L2BlockTime is the number of seconds between two L2 blocks. The
+	// sequencer builds blocks at this pace.
+	L2BlockTime uint64
+	// Confirmations control when derived blocks are safe and finalized.
+	Confirmations derive.Confirmations
+	// BatcherMaxDelay is the maximum time a block waits to be submitted.
+	BatcherMaxDelay time.Duration
+}
+
+// DefaultSystemConfig returns the settings of a fast system, which submits
+// every L2 block to L1 within seconds.
+func DefaultSystemConfig() SystemConfig {
+	return SystemConfig{
+		L1BlockTime:     time.Second,
+		L2BlockTime:     1,
+		Confirmations:   derive.Confirmations{FinalityDepth: 4},
+		BatcherMaxDelay: 2 * time.Second,
+	}
+}
+
+// EthNode is an in-process execution client with the engine API enabled.
+type EthNode struct {
+	Node   *node.Node
+	Eth    *eth.Ethereum
+	Client *ethclient.Client
+	Engine *engine.Client
+}
+
+// RollupNode is an L2 execution engine together with the driver of the rollup
+// node that drives it.
+type RollupNode struct {
+	*EthNode
+	Driver *driver.Driver
+}
+
+// System is a running rollup.
+type System struct {
+	Cfg       *rollup.Config
+	L1        *EthNode
+	Sequencer *RollupNode
+	Verifier  *RollupNode
+	Batcher   *batcher.Submitter
+
+	l1Engine *engine.Client
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSystem starts a rollup, which is shut down when the test finishes.
+func NewSystem(t *testing.T, cfg SystemConfig) *System {
+	t.Helper()
+
+	funds := new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+	l1Genesis := &core.Genesis{
+		Config:     l1ChainConfig(),
+		Timestamp:  uint64(time.Now().Unix()),
+		GasLimit:   30_000_000,
+		Difficulty: new(big.Int),
+		BaseFee:    big.NewInt(params.InitialBaseFee),
+		Alloc: core.GenesisAlloc{
+			crypto.PubkeyToAddress(BatcherKey.PublicKey): {Balance: funds},
+			crypto.PubkeyToAddress(AliceKey.PublicKey):   {Balance: funds},
+			crypto.PubkeyToAddress(BobKey.PublicKey):     {Balance: funds},
+			DepositContractAddr:                          {Balance: new(big.Int), Code: depositContractCode},
+		},
+	}
+	l1Anchor := l1Genesis.ToBlock(nil).Header()
+	deployCfg := &genesis.DeployConfig{
+		L1ChainID:              l1Genesis.Config.ChainID.Uint64(),
+		L2ChainID:              901,
+		L2BlockTime:            cfg.L2BlockTime,
+		MaxSequencerDrift:      600,
+		SequencerWindowSize:    100,
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     crypto.PubkeyToAddress(BatcherKey.PublicKey),
+		DepositContractAddress: DepositContractAddr,
+		L2GenesisGasLimit:      30_000_000,
+		FundedAccounts: core.GenesisAlloc{
+			crypto.PubkeyToAddress(AliceKey.PublicKey): {Balance: funds},
+		},
+	}
+	l2Genesis, err := genesis.BuildL2Genesis(deployCfg, l1Anchor)
+	if err != nil {
+		t.Fatalf("failed to build L2 genesis: %v", err)
+	}
+	sys := &System{
+		Cfg:  genesis.BuildRollupConfig(deployCfg, l1Anchor, l2Genesis.ToBlock(nil)),
+		stop: make(chan struct{}),
+	}
+	t.Cleanup(sys.Close)
+
+	sys.L1 = startEthNode(t, "l1", l1Genesis)
+	sys.l1Engine = sys.L1.Engine
+	sys.Sequencer = sys.startRollupNode(t, "sequencer", l2Genesis, cfg.Confirmations, true)
+	sys.Verifier = sys.startRollupNode(t, "verifier", l2Genesis, cfg.Confirmations, false)
+
+	bcfg := batcher.DefaultConfig
+	bcfg.L1ChainID = sys.Cfg.L1ChainID
+	bcfg.BatchInboxAddress = sys.Cfg.BatchInboxAddress
+	bcfg.MaxDelay = cfg.BatcherMaxDelay
+	bcfg.PollInterval = 100 * time.Millisecond
+	bcfg.CursorFile = t.TempDir() + "/cursor.json"
+	sys.Batcher, err = batcher.New(bcfg, sys.L1.Client, sys.Sequencer.Client, BatcherKey, log.New("role", "batcher"))
+	if err != nil {
+		t.Fatalf("failed to create batch submitter: %v", err)
+	}
+	sys.Batcher.Start()
+
+	sys.wg.Add(1)
+	go sys.produceL1Blocks(cfg.L1BlockTime)
+	return sys
+}
+
+// Close stops all components of the system.
+func (s *System) Close() {
+	select {
+	case <-s.stop:
+		return
+	default:
+		close(s.stop)
+	}
+	s.wg.Wait()
+	if s.Batcher != nil {
+		s.Batcher.Stop()
+	}
+	for _, n := range []*RollupNode{s.Sequencer, s.Verifier} {
+		if n != nil {
+			n.Driver.Stop()
+			n.close()
+		}
+	}
+	if s.L1 != nil {
+		s.L1.close()
+	}
+}
+
+func l1ChainConfig() *params.ChainConfig {
+	config := *params.AllEthashProtocolChanges
+	config.ChainID = big.NewInt(900)
+	config.TerminalTotalDifficulty = new(big.Int)
+	config.TerminalTotalDifficultyPassed = true
+	return &config
+}
+
+// startEthNode starts an execution client with the given genesis, which only
+// progresses through the engine API.
+func startEthNode(t *testing.T, name string, genesis *core.Genesis) *EthNode {
+	t.Helper()
+	stack, err := node.New(&node.Config{
+		Name: name,
+		P2P:  p2p.Config{NoDiscovery: true, NoDial: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to create %s node: %v", name, err)
+	}
+	ethcfg := ethconfig.Defaults
+	ethcfg.Genesis = genesis
+	ethcfg.SyncMode = downloader.FullSync
+	ethcfg.Ethash.PowMode = ethash.ModeFake
+	backend, err := eth.New(stack, &ethcfg)
+	if err != nil {
+		stack.Close()
+		t.Fatalf("failed to create %s eth service: %v", name, err)
+	}
+	if err := catalyst.Register(stack, backend); err != nil {
+		stack.Close()
+		t.Fatalf("failed to register engine API of %s: %v", name, err)
+	}
+	if err := stack.Start(); err != nil {
+		stack.Close()
+		t.Fatalf("failed to start %s node: %v", name, err)
+	}
+	rpcClient, _ := stack.Attach()
+	return &EthNode{
+		Node:   stack,
+		Eth:    backend,
+		Client: ethclient.NewClient(rpcClient),
+		Engine: engine.NewClient(rpcClient),
+	}
+}
+
+func (n *EthNode) close() {
+	n.Client.Close()
+	n.Node.Close()
+}
+
+func (s *System) startRollupNode(t *testing.T, name string, genesis *core.Genesis, conf derive.Confirmations, sequencing bool) *RollupNode {
+	t.Helper()
+	l2 := startEthNode(t, name, genesis)
+	if hash := l2.Eth.BlockChain().Genesis().Hash(); hash != s.Cfg.Genesis.L2.Hash {
+		l2.close()
+		t.Fatalf("%s genesis mismatch: have %s, want %s", name, hash, s.Cfg.Genesis.L2.Hash)
+	}
+	d, err := driver.NewDriver(s.Cfg, driver.Config{Confirmations: conf, Sequencing: sequencing}, derive.NewL1Source(s.L1.Client), l2.Engine, log.New("role", name))
+	if err != nil {
+		l2.close()
+		t.Fatalf("failed to create %s driver: %v", name, err)
+	}
+	d.Start()
+	return &RollupNode{EthNode: l2, Driver: d}
+}
+
+// produceL1Blocks builds an L1 block out of the transaction pool of the L1 node
+// at every interval, and reports it to the rollup nodes.
+func (s *System) produceL1Blocks(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			head, err := s.buildL1Block(ctx)
+			if err == nil {
+				ref := rollup.L1BlockRefFromHeader(head)
+				s.Sequencer.Driver.OnL1Head(ctx, ref)
+				s.Verifier.Driver.OnL1Head(ctx, ref)
+			} else {
+				log.Error("Failed to build L1 block", "err", err)
+			}
+			cancel()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *System) buildL1Block(ctx context.Context) (*types.Header, error) {
+	parent := s.L1.Eth.BlockChain().CurrentHeader()
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= parent.Time {
+		timestamp = parent.Time + 1
+	}
+	hash := parent.Hash()
+	attrs := &beacon.PayloadAttributesV1{
+		Timestamp:             timestamp,
+		Random:                crypto.Keccak256Hash(hash[:]),
+		SuggestedFeeRecipient: common.HexToAddress("0xc0ffee"),
+	}
+	fc := beacon.ForkchoiceStateV1{HeadBlockHash: hash, SafeBlockHash: hash, FinalizedBlockHash: hash}
+	payload, err := derive.InsertHeadBlock(ctx, s.l1Engine, fc, attrs)
+	if err != nil {
+		return nil, err
+	}
+	return s.L1.Client.HeaderByHash(ctx, payload.BlockHash)
+}
+
+// Deposit sends an L1 transaction from the given account to the deposit
+// contract, which deposits into L2 as described by dep. The sender of the
+// deposit is the L1 account. It waits for the L1 transaction to be included and
+// returns its receipt.
+func (s *System) Deposit(t *testing.T, key *ecdsa.PrivateKey, dep *types.DepositTx) *types.Receipt {
+	t.Helper()
+	ev := derive.MarshalDepositLogEvent(DepositContractAddr, dep)
+	data := append(ev.Topics[2].Bytes(), ev.Data...)
+	value := new(big.Int)
+	if dep.Mint != nil {
+		value.Set(dep.Mint)
+	}
+	tx := SendTx(t, s.L1.Client, key, &DepositContractAddr, value, data)
+	receipt := WaitForReceipt(t, s.L1.Client, tx.Hash())
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatalf("deposit transaction %s failed", tx.Hash())
+	}
+	return receipt
+}
+
+// SendTx signs a transaction from the given account and sends it to the given
+// node.
+func SendTx(t *testing.T, client *ethclient.Client, key *ecdsa.PrivateKey, to *common.Address, value *big.Int, data []byte) *types.Transaction {
+	t.Helper()
+	ctx := context.Background()
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := client.PendingNonceAt(ctx, crypto.PubkeyToAddress(key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tip := big.NewInt(params.GWei)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, common.Big2)),
+		Gas:       1_000_000,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	return tx
+}
+
+// WaitForReceipt waits for the transaction to be included and returns its
+// receipt.
+func WaitForReceipt(t *testing.T, client *ethclient.Client, hash common.Hash) *types.Receipt {
+	t.Helper()
+	var receipt *types.Receipt
+	WaitFor(t, "receipt of "+hash.Hex(), func() (bool, error) {
+		var err error
+		receipt, err = client.TransactionReceipt(context.Background(), hash)
+		if errors.Is(err, ethereum.NotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	return receipt
+}
+
+// WaitForSafe waits for the safe head of the rollup node to reach the given L2
+// block number, and returns it.
+func (n *RollupNode) WaitForSafe(t *testing.T, number uint64) rollup.L2BlockRef {
+	t.Helper()
+	var safe rollup.L2BlockRef
+	WaitFor(t, "safe head", func() (bool, error) {
+		status, err := n.Driver.SyncStatus(context.Background())
+		if err != nil {
+			return false, err
+		}
+		safe = status.SafeL2
+		return safe.Number >= number, nil
+	})
+	return safe
+}
+
+// waitTimeout is the time that WaitFor waits for a condition.
+const waitTimeout = 60 * time.Second
+
+// WaitFor polls the condition until it holds, and fails the test if it does
+// not hold in time or returns an error.
+func WaitFor(t *testing.T, what string, cond func() (bool, error)) {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		ok, err := cond()
+		if err != nil {
+			t.Fatalf("failed to wait for %s: %v", what, err)
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
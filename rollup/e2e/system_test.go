@@ -0,0 +1,78 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package e2e
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// TestSystemDepositAndDerive deposits into L2, spends the deposit in a
+// sequenced L2 transaction, and checks that the verifier derives the same
+// chain from the batches on L1.
+func TestSystemDepositAndDerive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	sys := NewSystem(t, DefaultSystemConfig())
+	ctx := context.Background()
+
+	// Bob is only funded on L1, and deposits into L2.
+	bob := crypto.PubkeyToAddress(BobKey.PublicKey)
+	mint := big.NewInt(params.Ether)
+	sys.Deposit(t, BobKey, &types.DepositTx{To: &bob, Mint: mint, Value: new(big.Int), Gas: 100_000, Data: []byte{}})
+	WaitFor(t, "deposit on L2", func() (bool, error) {
+		balance, err := sys.Sequencer.Client.BalanceAt(ctx, bob, nil)
+		return err == nil && balance.Cmp(mint) == 0, err
+	})
+
+	// The deposit pays for a transfer on L2.
+	carol := common.HexToAddress("0xca401")
+	value := big.NewInt(params.Ether / 10)
+	tx := SendTx(t, sys.Sequencer.Client, BobKey, &carol, value, nil)
+	receipt := WaitForReceipt(t, sys.Sequencer.Client, tx.Hash())
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Fatal("L2 transfer failed")
+	}
+
+	// The verifier derives the same blocks and state from L1.
+	number := receipt.BlockNumber.Uint64()
+	safe := sys.Verifier.WaitForSafe(t, number)
+	header, err := sys.Verifier.Client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Hash() != receipt.BlockHash {
+		t.Fatalf("verifier derived block %d %s, sequencer built %s", number, header.Hash(), receipt.BlockHash)
+	}
+	balance, err := sys.Verifier.Client.BalanceAt(ctx, carol, receipt.BlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(value) != 0 {
+		t.Fatalf("verifier has balance %v for the recipient, want %v", balance, value)
+	}
+	if safe.L1Origin.Number == 0 {
+		t.Fatal("safe head still in the genesis epoch")
+	}
+}
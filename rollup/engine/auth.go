@@ -0,0 +1,106 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwtRefreshInterval is the age at which a token is replaced. The engine
+// rejects tokens whose issuance time is more than 60 seconds away from its own
+// clock, so refreshing at half that tolerates up to 30 seconds of clock skew
+// between the node and the engine.
+const jwtRefreshInterval = 30 * time.Second
+
+// LoadJWTSecret reads the hex encoded 32 byte secret that authenticates the
+// node to the engine API from the given file.
+func LoadJWTSecret(path string) ([32]byte, error) {
+	var secret [32]byte
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return secret, err
+	}
+	enc := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(enc, "0x") {
+		enc = "0x" + enc
+	}
+	dec, err := hexutil.Decode(enc)
+	if err != nil {
+		return secret, fmt.Errorf("invalid JWT secret in %s: %v", path, err)
+	}
+	if len(dec) != len(secret) {
+		return secret, fmt.Errorf("JWT secret in %s has %d bytes, want %d", path, len(dec), len(secret))
+	}
+	copy(secret[:], dec)
+	return secret, nil
+}
+
+// jwtAuth authenticates requests to the engine API with HS256 signed tokens
+// that claim their issuance time. A token is reused until it is refreshed.
+type jwtAuth struct {
+	secret [32]byte
+	now    func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+}
+
+func newJWTAuth(secret [32]byte) *jwtAuth {
+	return &jwtAuth{secret: secret, now: time.Now}
+}
+
+// AddAuthHeader implements rpc.HeaderAuthProvider.
+func (a *jwtAuth) AddAuthHeader(header *http.Header) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if a.token == "" || now.Sub(a.issued) >= jwtRefreshInterval || now.Before(a.issued) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(now),
+		})
+		signed, err := token.SignedString(a.secret[:])
+		if err != nil {
+			return fmt.Errorf("failed to create JWT token: %w", err)
+		}
+		a.token, a.issued = signed, now
+	}
+	header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+// DialWithJWT connects a client to the authenticated engine API at the given
+// URL. Over HTTP, every request carries a recent token. Over WebSocket, the
+// token authenticates the connection, including every reconnection.
+func DialWithJWT(ctx context.Context, rawurl string, secret [32]byte) (*Client, error) {
+	c, err := rpc.DialWithAuth(ctx, rawurl, newJWTAuth(secret))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwtCheck accepts requests that carry a token signed with the secret and
+// issued within a minute of the server clock, like the authenticated port of
+// the engine.
+func jwtCheck(secret [32]byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var claims jwt.RegisteredClaims
+		enc := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		_, err := jwt.ParseWithClaims(enc, &claims, func(*jwt.Token) (interface{}, error) { return secret[:], nil },
+			jwt.WithValidMethods([]string{"HS256"}), jwt.WithoutClaimsValidation())
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case claims.IssuedAt == nil:
+			http.Error(w, "missing issued-at", http.StatusForbidden)
+		case time.Since(claims.IssuedAt.Time) > time.Minute || time.Until(claims.IssuedAt.Time) > time.Minute:
+			http.Error(w, "stale token", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func TestDialWithJWT(t *testing.T) {
+	_, api := newTestClient(t)
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("engine", api); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	secret := [32]byte{1, 2, 3}
+	httpsrv := httptest.NewServer(jwtCheck(secret, srv))
+	defer httpsrv.Close()
+
+	ctx := context.Background()
+	client, err := DialWithJWT(ctx, httpsrv.URL, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.GetPayload(ctx, beacon.PayloadID{1}); err != nil {
+		t.Fatalf("authenticated request failed: %v", err)
+	}
+
+	other, err := DialWithJWT(ctx, httpsrv.URL, [32]byte{4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if _, err := other.GetPayload(ctx, beacon.PayloadID{1}); err == nil {
+		t.Fatal("request with wrong secret accepted")
+	}
+}
+
+func TestJWTAuthRefresh(t *testing.T) {
+	var (
+		now  = time.Unix(1_000_000, 0)
+		auth = newJWTAuth([32]byte{1})
+	)
+	auth.now = func() time.Time { return now }
+	token := func() string {
+		header := make(http.Header)
+		if err := auth.AddAuthHeader(&header); err != nil {
+			t.Fatal(err)
+		}
+		return header.Get("Authorization")
+	}
+	first := token()
+	now = now.Add(jwtRefreshInterval - time.Second)
+	if token() != first {
+		t.Fatal("token refreshed early")
+	}
+	now = now.Add(time.Second)
+	second := token()
+	if second == first {
+		t.Fatal("token not refreshed")
+	}
+	// A clock that jumps back does not keep a token from the future.
+	now = now.Add(-time.Minute)
+	if token() == second {
+		t.Fatal("token not refreshed after the clock went back")
+	}
+}
+
+func TestLoadJWTSecret(t *testing.T) {
+	secret := common.HexToHash("0x0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20")
+	tests := map[string]bool{
+		secret.Hex():                    true,
+		secret.Hex()[2:] + "\n":         true,
+		secret.Hex()[:64]:               false,
+		"0x" + strings.Repeat("zz", 32): false,
+	}
+	for content, valid := range tests {
+		path := filepath.Join(t.TempDir(), "jwt.hex")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		have, err := LoadJWTSecret(path)
+		if (err == nil) != valid {
+			t.Errorf("%q: unexpected error %v", content, err)
+		}
+		if err == nil && have != secret {
+			t.Errorf("%q: wrong secret %x", content, have)
+		}
+	}
+}
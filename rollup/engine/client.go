@@ -0,0 +1,163 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package engine provides a client for the Engine API of the L2 execution
+// engine, which the rollup node uses to build and insert L2 blocks.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Errors returned by the Engine API, as defined by the specification. Invalid
+// forkchoice states and payload attributes are fatal to the caller, so they
+// match derive.ErrEngineInvalid.
+var (
+	ErrUnknownPayload           = errors.New("unknown payload")
+	ErrInvalidForkchoiceState   = fmt.Errorf("%w: invalid forkchoice state", derive.ErrEngineInvalid)
+	ErrInvalidPayloadAttributes = fmt.Errorf("%w: invalid payload attributes", derive.ErrEngineInvalid)
+)
+
+// Engine API error codes.
+const (
+	unknownPayloadCode           = -38001
+	invalidForkchoiceStateCode   = -38002
+	invalidPayloadAttributesCode = -38003
+)
+
+// Client is a client for the Engine API of an L2 execution engine. Besides the
+// engine methods, it gives access to the L2 chain through the eth namespace.
+type Client struct {
+	rpc  *rpc.Client
+	eth  *ethclient.Client
+	geth *gethclient.Client
+}
+
+var _ derive.Engine = (*Client)(nil)
+
+// Dial connects a client to the given URL.
+func Dial(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{rpc: c, eth: ethclient.NewClient(c), geth: gethclient.New(c)}
+}
+
+// Close closes the underlying RPC connection.
+func (c *Client) Close() {
+	c.rpc.Close()
+}
+
+// ForkchoiceUpdate updates the forkchoice state of the engine and, if attributes
+// are given, starts building a payload on top of the new head. The attributes
+// may force transactions into the payload, such as deposits, and may exclude
+// the transaction pool.
+func (c *Client) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	var res beacon.ForkChoiceResponse
+	defer forkchoiceUpdateTimer.UpdateSince(time.Now())
+	if err := c.rpc.CallContext(ctx, &res, "engine_forkchoiceUpdatedV1", state, attr); err != nil {
+		return nil, engineError(err)
+	}
+	return &res, nil
+}
+
+// GetPayload retrieves the payload that is being built with the given ID.
+func (c *Client) GetPayload(ctx context.Context, id beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	var res beacon.ExecutableDataV1
+	defer getPayloadTimer.UpdateSince(time.Now())
+	if err := c.rpc.CallContext(ctx, &res, "engine_getPayloadV1", id); err != nil {
+		return nil, engineError(err)
+	}
+	return &res, nil
+}
+
+// NewPayload executes the given payload and reports its validity.
+func (c *Client) NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error) {
+	var res beacon.PayloadStatusV1
+	defer newPayloadTimer.UpdateSince(time.Now())
+	if err := c.rpc.CallContext(ctx, &res, "engine_newPayloadV1", payload); err != nil {
+		return nil, engineError(err)
+	}
+	return &res, nil
+}
+
+// PayloadByNumber returns the canonical L2 block with the given number as an
+// execution payload. A nil number returns the head block.
+func (c *Client) PayloadByNumber(ctx context.Context, number *big.Int) (*beacon.ExecutableDataV1, error) {
+	block, err := c.eth.BlockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return beacon.BlockToExecutableData(block), nil
+}
+
+// PayloadByHash returns the L2 block with the given hash as an execution payload.
+func (c *Client) PayloadByHash(ctx context.Context, hash common.Hash) (*beacon.ExecutableDataV1, error) {
+	block, err := c.eth.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return beacon.BlockToExecutableData(block), nil
+}
+
+// HeaderByNumber returns the canonical L2 header with the given number. A nil
+// number returns the head header.
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return c.eth.HeaderByNumber(ctx, number)
+}
+
+// GetProof returns the account and storage proofs of the given account at the
+// canonical L2 block with the given number. A nil number uses the head block.
+func (c *Client) GetProof(ctx context.Context, account common.Address, keys []string, number *big.Int) (*gethclient.AccountResult, error) {
+	return c.geth.GetProof(ctx, account, keys, number)
+}
+
+// engineError converts the standard Engine API errors into the corresponding
+// error values, keeping the server message.
+func engineError(err error) error {
+	engineErrorMeter.Mark(1)
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	switch rpcErr.ErrorCode() {
+	case unknownPayloadCode:
+		return fmt.Errorf("%w: %v", ErrUnknownPayload, err)
+	case invalidForkchoiceStateCode:
+		return fmt.Errorf("%w: %v", ErrInvalidForkchoiceState, err)
+	case invalidPayloadAttributesCode:
+		return fmt.Errorf("%w: %v", ErrInvalidPayloadAttributes, err)
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// testEngineAPI records the calls it receives and serves a single block.
+type testEngineAPI struct {
+	attrs *beacon.PayloadAttributesV1
+	block *types.Block
+}
+
+func (api *testEngineAPI) ForkchoiceUpdatedV1(state beacon.ForkchoiceStateV1, attrs *beacon.PayloadAttributesV1) (beacon.ForkChoiceResponse, error) {
+	if state.HeadBlockHash != api.block.ParentHash() {
+		return beacon.STATUS_INVALID, beacon.InvalidForkChoiceState.With(errors.New("unknown head"))
+	}
+	api.attrs = attrs
+	id := beacon.PayloadID{1}
+	return beacon.ForkChoiceResponse{PayloadStatus: beacon.PayloadStatusV1{Status: beacon.VALID}, PayloadID: &id}, nil
+}
+
+func (api *testEngineAPI) GetPayloadV1(id beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	if id != (beacon.PayloadID{1}) {
+		return nil, beacon.UnknownPayload
+	}
+	return beacon.BlockToExecutableData(api.block), nil
+}
+
+func (api *testEngineAPI) NewPayloadV1(payload beacon.ExecutableDataV1) (beacon.PayloadStatusV1, error) {
+	block, err := beacon.ExecutableDataToBlock(payload)
+	if err != nil {
+		return beacon.PayloadStatusV1{Status: beacon.INVALIDBLOCKHASH}, nil
+	}
+	hash := block.Hash()
+	return beacon.PayloadStatusV1{Status: beacon.VALID, LatestValidHash: &hash}, nil
+}
+
+// testEthAPI serves the block of the engine through the eth namespace.
+type testEthAPI struct {
+	block *types.Block
+}
+
+func (api *testEthAPI) GetBlockByNumber(number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	if number >= 0 && uint64(number) != api.block.NumberU64() {
+		return nil, nil
+	}
+	return ethapi.RPCMarshalBlock(api.block, true, fullTx, params.AllEthashProtocolChanges)
+}
+
+func newTestClient(t *testing.T) (*Client, *testEngineAPI) {
+	t.Helper()
+	to := common.HexToAddress("0x4200000000000000000000000000000000000015")
+	deposit := types.NewTx(&types.DepositTx{
+		SourceHash: common.HexToHash("0x01"),
+		From:       common.HexToAddress("0xdeaddeaddeaddeaddeaddeaddeaddeaddead0001"),
+		To:         &to,
+		Value:      new(big.Int),
+		Gas:        150_000_000,
+		Data:       []byte{1, 2, 3},
+	})
+	header := &types.Header{
+		ParentHash: common.HexToHash("0xaa"),
+		Number:     big.NewInt(5),
+		GasLimit:   30_000_000,
+		Time:       1000,
+		BaseFee:    big.NewInt(7),
+		Difficulty: new(big.Int),
+	}
+	block := types.NewBlock(header, []*types.Transaction{deposit}, nil, nil, trie.NewStackTrie(nil))
+
+	engineAPI := &testEngineAPI{block: block}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("engine", engineAPI); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RegisterName("eth", &testEthAPI{block: block}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	client := NewClient(rpc.DialInProc(srv))
+	t.Cleanup(client.Close)
+	return client, engineAPI
+}
+
+func TestClientBuildBlock(t *testing.T) {
+	ctx := context.Background()
+	client, api := newTestClient(t)
+
+	depositEnc, _ := api.block.Transactions()[0].MarshalBinary()
+	attrs := &beacon.PayloadAttributesV1{
+		Timestamp:    1000,
+		Transactions: [][]byte{depositEnc},
+		NoTxPool:     true,
+	}
+	fc := &beacon.ForkchoiceStateV1{HeadBlockHash: api.block.ParentHash()}
+	res, err := client.ForkchoiceUpdate(ctx, fc, attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.PayloadStatus.Status != beacon.VALID || res.PayloadID == nil {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	// The rollup extensions of the attributes reach the engine.
+	if api.attrs == nil || !api.attrs.NoTxPool || len(api.attrs.Transactions) != 1 {
+		t.Fatalf("engine received wrong attributes %+v", api.attrs)
+	}
+	if common.Bytes2Hex(api.attrs.Transactions[0]) != common.Bytes2Hex(depositEnc) {
+		t.Fatal("forced deposit mismatch")
+	}
+
+	payload, err := client.GetPayload(ctx, *res.PayloadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.BlockHash != api.block.Hash() {
+		t.Fatalf("wrong payload %s", payload.BlockHash)
+	}
+	status, err := client.NewPayload(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != beacon.VALID || *status.LatestValidHash != payload.BlockHash {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t)
+
+	_, err := client.GetPayload(ctx, beacon.PayloadID{2})
+	if !errors.Is(err, ErrUnknownPayload) {
+		t.Errorf("wrong error for unknown payload: %v", err)
+	}
+	_, err = client.ForkchoiceUpdate(ctx, &beacon.ForkchoiceStateV1{HeadBlockHash: common.HexToHash("0xbb")}, nil)
+	if !errors.Is(err, ErrInvalidForkchoiceState) {
+		t.Errorf("wrong error for invalid forkchoice state: %v", err)
+	}
+	if !errors.Is(err, derive.ErrEngineInvalid) {
+		t.Errorf("invalid forkchoice state not fatal: %v", err)
+	}
+}
+
+func TestClientPayloadByNumber(t *testing.T) {
+	client, api := newTestClient(t)
+
+	payload, err := client.PayloadByNumber(context.Background(), big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.BlockHash != api.block.Hash() || len(payload.Transactions) != 1 {
+		t.Fatalf("wrong payload %+v", payload)
+	}
+	if _, err := beacon.ExecutableDataToBlock(*payload); err != nil {
+		t.Fatalf("payload does not convert back to the block: %v", err)
+	}
+}
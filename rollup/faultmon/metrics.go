@@ -0,0 +1,51 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the fault monitor.
+
+package faultmon
+
+import (
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// monitorMetrics are the metrics of a monitor. Unlike the metrics of the other
+// rollup services, they are registered when the monitor is created rather than
+// when the package is loaded, since the monitor binary enables metrics
+// unconditionally instead of through a command line flag.
+type monitorMetrics struct {
+	checked    metrics.Meter // proposals compared with the local output
+	diverged   metrics.Meter // proposals that differ from the local output
+	divergence metrics.Gauge // 1 if the last checked proposal diverged
+	skipped    metrics.Meter // submission intervals left out between proposals
+	latest     metrics.Gauge // L2 block of the latest proposal
+	lag        metrics.Gauge // L2 blocks the safe head is ahead of the latest proposal
+	missed     metrics.Gauge // submission intervals overdue since the latest proposal
+	pending    metrics.Gauge // proposals whose L2 block is not safe yet
+}
+
+func newMonitorMetrics() *monitorMetrics {
+	return &monitorMetrics{
+		checked:    metrics.GetOrRegisterMeter("rollup/faultmon/checked", nil),
+		diverged:   metrics.GetOrRegisterMeter("rollup/faultmon/diverged", nil),
+		divergence: metrics.GetOrRegisterGauge("rollup/faultmon/divergence", nil),
+		skipped:    metrics.GetOrRegisterMeter("rollup/faultmon/proposal/skipped", nil),
+		latest:     metrics.GetOrRegisterGauge("rollup/faultmon/proposal/latest", nil),
+		lag:        metrics.GetOrRegisterGauge("rollup/faultmon/proposal/lag", nil),
+		missed:     metrics.GetOrRegisterGauge("rollup/faultmon/proposal/missed", nil),
+		pending:    metrics.GetOrRegisterGauge("rollup/faultmon/pending", nil),
+	}
+}
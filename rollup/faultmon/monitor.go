@@ -0,0 +1,192 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package faultmon implements the fault monitor, which watches the output
+// proposals on L1 for operators. It compares the proposed output roots with the
+// outputs of a local rollup node, and tracks how far the proposals lag behind
+// the safe chain, exporting both as metrics to alert on.
+package faultmon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+	"github.com/ethereum/go-ethereum/rollup/challenger"
+	"github.com/ethereum/go-ethereum/rollup/internal/poll"
+)
+
+// Config contains the settings of the fault monitor.
+type Config struct {
+	OutputOracleAddress common.Address
+
+	// StartBlock is the L1 block from which proposals are monitored.
+	StartBlock uint64
+	// Confirmations is the number of L1 blocks on top of a proposal before it
+	// is monitored.
+	Confirmations uint64
+	// MaxBlockRange is the largest range of L1 blocks whose proposals are
+	// fetched at once.
+	MaxBlockRange uint64
+	// SubmissionInterval is the number of L2 blocks between two proposals, as
+	// configured in the output oracle.
+	SubmissionInterval uint64
+	// PollInterval is the interval at which L1 and the rollup node are polled.
+	// Failures back off up to MaxRetryInterval.
+	PollInterval     time.Duration
+	MaxRetryInterval time.Duration
+}
+
+// DefaultConfig contains reasonable default settings.
+var DefaultConfig = Config{
+	Confirmations:      5,
+	MaxBlockRange:      1000,
+	SubmissionInterval: 1800,
+	PollInterval:       12 * time.Second,
+	MaxRetryInterval:   time.Minute,
+}
+
+// Monitor follows the proposals of the output oracle. Once the local rollup
+// node has derived the proposed block as a safe block, the proposal is compared
+// with the local output, and a divergence is reported. The safe head of the
+// node is also compared with the latest proposal: a proposal is missed once
+// the safe head passed the block after it by a whole submission interval, which
+// leaves the proposer one interval to propose a block after it became safe.
+//
+// Unlike the challenger, the monitor does not act on invalid proposals.
+type Monitor struct {
+	cfg     Config
+	log     log.Logger
+	metrics *monitorMetrics
+
+	fetcher  *challenger.Fetcher
+	latest   *bridge.OutputProposal // proposal of the highest L2 block, nil if none was seen
+	diverged bool                   // whether the last checked proposal diverged
+	missed   uint64                 // submission intervals overdue since the latest proposal
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a fault monitor.
+func New(cfg Config, l1 challenger.L1Client, node challenger.RollupClient, logger log.Logger) *Monitor {
+	if cfg.MaxBlockRange == 0 {
+		cfg.MaxBlockRange = DefaultConfig.MaxBlockRange
+	}
+	if cfg.SubmissionInterval == 0 {
+		cfg.SubmissionInterval = DefaultConfig.SubmissionInterval
+	}
+	return &Monitor{
+		cfg:     cfg,
+		log:     logger,
+		metrics: newMonitorMetrics(),
+		fetcher: challenger.NewFetcher(l1, node, cfg.OutputOracleAddress, cfg.StartBlock, cfg.Confirmations, cfg.MaxBlockRange, logger),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start starts monitoring proposals in the background.
+func (m *Monitor) Start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// Stop stops the monitor and waits for it to shut down.
+func (m *Monitor) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+	poll.Loop(m.quit, m.cfg.PollInterval, m.cfg.MaxRetryInterval, m.Step, m.log, "Monitoring proposals failed")
+}
+
+// Step fetches the new proposals from L1, checks the proposals whose L2 block
+// is safe, and updates the lag of the proposals behind the safe head.
+func (m *Monitor) Step(ctx context.Context) error {
+	proposals, err := m.fetcher.Fetch(ctx)
+	for _, p := range proposals {
+		m.addProposal(p)
+	}
+	m.metrics.pending.Update(int64(m.fetcher.Pending()))
+	if err != nil {
+		return err
+	}
+	safe, err := m.fetcher.CheckSafe(ctx, m.check)
+	m.metrics.pending.Update(int64(m.fetcher.Pending()))
+	if err != nil {
+		return err
+	}
+	m.updateLag(safe)
+	return nil
+}
+
+// addProposal records a new proposal, and reports the submission intervals
+// that were left out since the latest proposal.
+func (m *Monitor) addProposal(p *bridge.OutputProposal) {
+	if m.latest != nil && p.L2BlockNumber <= m.latest.L2BlockNumber {
+		return
+	}
+	if m.latest != nil {
+		if skipped := (p.L2BlockNumber-m.latest.L2BlockNumber)/m.cfg.SubmissionInterval - 1; skipped > 0 {
+			m.metrics.skipped.Mark(int64(skipped))
+			m.log.Warn("Proposals skipped submission intervals", "previous", m.latest.L2BlockNumber, "l2block", p.L2BlockNumber, "skipped", skipped)
+		}
+	}
+	m.latest = p
+	m.metrics.latest.Update(int64(p.L2BlockNumber))
+}
+
+// check compares a proposal with the output of the rollup node.
+func (m *Monitor) check(p *bridge.OutputProposal, output *rollup.Output) error {
+	m.metrics.checked.Mark(1)
+	m.diverged = output.OutputRoot != p.OutputRoot
+	if !m.diverged {
+		m.metrics.divergence.Update(0)
+		m.log.Info("Output proposal matches", "l2block", p.L2BlockNumber, "output", p.OutputRoot, "index", p.OutputIndex)
+		return nil
+	}
+	m.metrics.diverged.Mark(1)
+	m.metrics.divergence.Update(1)
+	m.log.Error("Output proposal diverges from local output", "l2block", p.L2BlockNumber, "index", p.OutputIndex, "proposed", p.OutputRoot, "local", output.OutputRoot, "l1block", p.L1BlockNumber, "tx", p.TxHash)
+	return nil
+}
+
+// updateLag compares the latest proposal with the safe head. Nothing is
+// reported before the first proposal is seen.
+func (m *Monitor) updateLag(safe uint64) {
+	if m.latest == nil {
+		return
+	}
+	var lag, missed uint64
+	if safe > m.latest.L2BlockNumber {
+		lag = safe - m.latest.L2BlockNumber
+	}
+	if intervals := lag / m.cfg.SubmissionInterval; intervals > 1 {
+		missed = intervals - 1
+	}
+	if missed > m.missed {
+		m.log.Warn("Output proposals overdue", "latest", m.latest.L2BlockNumber, "safe", safe, "missed", missed)
+	}
+	m.missed = missed
+	m.metrics.lag.Update(int64(lag))
+	m.metrics.missed.Update(int64(missed))
+}
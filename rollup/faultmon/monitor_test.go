@@ -0,0 +1,134 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package faultmon
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+)
+
+var testOracle = common.HexToAddress("0x0000000000000000000000000000000000000abc")
+
+// testL1 is an L1 chain with an output oracle.
+type testL1 struct {
+	head uint64
+	logs []types.Log
+}
+
+func (l *testL1) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: new(big.Int).SetUint64(l.head)}, nil
+}
+
+func (l *testL1) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	for _, log := range l.logs {
+		if log.BlockNumber >= q.FromBlock.Uint64() && log.BlockNumber <= q.ToBlock.Uint64() && log.Address == q.Addresses[0] {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// propose includes a proposal in the given L1 block.
+func (l *testL1) propose(l1Block, l2Block uint64, root common.Hash) {
+	p := &bridge.OutputProposal{OutputRoot: root, OutputIndex: uint64(len(l.logs)), L2BlockNumber: l2Block, L1Timestamp: 1000, L1BlockNumber: l1Block}
+	l.logs = append(l.logs, *bridge.MarshalOutputProposed(testOracle, p))
+}
+
+// testNode is a rollup node whose output roots are the block numbers.
+type testNode struct {
+	safe uint64
+}
+
+func (n *testNode) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	return &rollup.SyncStatus{SafeL2: rollup.L2BlockRef{Number: n.safe}}, nil
+}
+
+func (n *testNode) OutputAtBlock(ctx context.Context, number uint64) (*rollup.Output, error) {
+	return &rollup.Output{OutputRoot: outputRoot(number)}, nil
+}
+
+func outputRoot(number uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(number))
+}
+
+func TestMonitor(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		l1   = &testL1{head: 4}
+		node = &testNode{safe: 15}
+		cfg  = DefaultConfig
+	)
+	cfg.OutputOracleAddress = testOracle
+	cfg.Confirmations = 2
+	cfg.SubmissionInterval = 10
+	m := New(cfg, l1, node, log.New())
+
+	// Nothing is reported before the first proposal.
+	if err := m.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if m.latest != nil || m.missed != 0 {
+		t.Fatalf("unexpected latest proposal %v, missed %d", m.latest, m.missed)
+	}
+
+	l1.head = 20
+	l1.propose(5, 10, outputRoot(10))
+	l1.propose(6, 20, common.HexToHash("0xbad"))
+	if err := m.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The proposal of the block that is not safe yet is pending.
+	if m.diverged || m.fetcher.Pending() != 1 || m.latest.L2BlockNumber != 20 {
+		t.Fatalf("unexpected state: diverged %v, pending %d, latest %d", m.diverged, m.fetcher.Pending(), m.latest.L2BlockNumber)
+	}
+	node.safe = 35
+	if err := m.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !m.diverged || m.fetcher.Pending() != 0 {
+		t.Fatalf("divergence not detected: diverged %v, pending %d", m.diverged, m.fetcher.Pending())
+	}
+	// The proposer has an interval to propose block 30.
+	if m.missed != 0 {
+		t.Fatalf("missed %d proposals, want 0", m.missed)
+	}
+	node.safe = 40
+	if err := m.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if m.missed != 1 {
+		t.Fatalf("missed %d proposals, want 1", m.missed)
+	}
+
+	l1.head = 21
+	l1.propose(19, 40, outputRoot(40))
+	if err := m.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if m.diverged || m.missed != 0 || m.latest.L2BlockNumber != 40 {
+		t.Fatalf("unexpected state: diverged %v, missed %d, latest %d", m.diverged, m.missed, m.latest.L2BlockNumber)
+	}
+}
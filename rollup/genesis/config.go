@@ -0,0 +1,143 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package genesis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// DeployConfig describes a new L2 chain.
+type DeployConfig struct {
+	L1ChainID uint64 `json:"l1ChainID"`
+	L2ChainID uint64 `json:"l2ChainID"`
+
+	// L2BlockTime is the number of seconds between two L2 blocks.
+	L2BlockTime uint64 `json:"l2BlockTime"`
+	// MaxSequencerDrift is the maximum number of seconds an L2 block may be
+	// ahead of its L1 origin.
+	MaxSequencerDrift uint64 `json:"maxSequencerDrift"`
+	// SequencerWindowSize is the number of L1 blocks in which the batches of
+	// an epoch must be included.
+	SequencerWindowSize uint64 `json:"sequencerWindowSize"`
+	// ChannelTimeout is the number of L1 blocks in which the frames of a
+	// channel must be included, zero for the default.
+	ChannelTimeout uint64 `json:"channelTimeout,omitempty"`
+	// StrictBatchOrdering makes derivation drop out-of-order frames and
+	// batches instead of buffering them.
+	StrictBatchOrdering bool `json:"strictBatchOrdering,omitempty"`
+	// DataAvailability is the name of the source of the batch data, empty for
+	// the calldata of the batch inbox transactions.
+	DataAvailability string `json:"dataAvailability,omitempty"`
+	// MaxDepositsPerBlock and MaxDepositBytesPerBlock limit the deposits of an
+	// epoch that an L2 block includes, zero for no limit.
+	MaxDepositsPerBlock     uint64 `json:"maxDepositsPerBlock,omitempty"`
+	MaxDepositBytesPerBlock uint64 `json:"maxDepositBytesPerBlock,omitempty"`
+
+	BatchInboxAddress  common.Address `json:"batchInboxAddress"`
+	BatchSenderAddress common.Address `json:"batchSenderAddress"`
+	// DepositContractAddress is the L1 deposit contract, which is deployed
+	// before the L2 chain is created.
+	DepositContractAddress common.Address `json:"depositContractAddress"`
+	// SystemConfigAddress is the L1 contract that updates the system config at
+	// runtime, unset if the system config is fixed.
+	SystemConfigAddress common.Address `json:"systemConfigAddress,omitempty"`
+	// L1CrossDomainMessengerAddress is the L1 messenger, which relays the
+	// withdrawals of a migrated legacy chain.
+	L1CrossDomainMessengerAddress common.Address `json:"l1CrossDomainMessengerAddress,omitempty"`
+
+	L2GenesisGasLimit hexutil.Uint64 `json:"l2GenesisGasLimit"`
+	L2GenesisBaseFee  *hexutil.Big   `json:"l2GenesisBaseFee,omitempty"`
+	// L2GenesisRegolithTime is the timestamp of the Regolith upgrade, nil if
+	// it is not scheduled.
+	L2GenesisRegolithTime *hexutil.Uint64 `json:"l2GenesisRegolithTime,omitempty"`
+	// L2GenesisDepositGasExemptTime is the timestamp from which deposits do
+	// not count toward the block gas limit, nil if they always do.
+	L2GenesisDepositGasExemptTime *hexutil.Uint64 `json:"l2GenesisDepositGasExemptTime,omitempty"`
+	// L2GenesisL1InfoCheckTime is the timestamp from which the L1 info deposits
+	// of blocks are validated, nil if they never are.
+	L2GenesisL1InfoCheckTime *hexutil.Uint64 `json:"l2GenesisL1InfoCheckTime,omitempty"`
+
+	// ProxyAdminOwner owns the proxy admin, which can upgrade the predeploys.
+	ProxyAdminOwner common.Address `json:"proxyAdminOwner"`
+
+	// Parameters of the L1 data fee charged by the gas price oracle.
+	GasPriceOracleOverhead uint64 `json:"gasPriceOracleOverhead"`
+	GasPriceOracleScalar   uint64 `json:"gasPriceOracleScalar"`
+	GasPriceOracleDecimals uint64 `json:"gasPriceOracleDecimals"`
+
+	// ProxyCode is the runtime code of the proxy that is placed in front of
+	// every predeploy. Without it, the predeploys are not upgradable and their
+	// code is placed at the predeploy address directly.
+	ProxyCode hexutil.Bytes `json:"proxyCode,omitempty"`
+	// PredeployCode is the runtime code of the predeploys, by name. Predeploys
+	// without code only hold their storage.
+	PredeployCode map[string]hexutil.Bytes `json:"predeployCode,omitempty"`
+
+	// FundedAccounts are additional accounts of the genesis state, such as
+	// prefunded developer accounts.
+	FundedAccounts core.GenesisAlloc `json:"fundedAccounts,omitempty"`
+}
+
+// LoadDeployConfig reads a deploy config from the given JSON file.
+func LoadDeployConfig(path string) (*DeployConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg DeployConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Check verifies that the deploy config describes a valid chain.
+func (cfg *DeployConfig) Check() error {
+	switch {
+	case cfg.L1ChainID == 0:
+		return errors.New("missing L1 chain ID")
+	case cfg.L2ChainID == 0:
+		return errors.New("missing L2 chain ID")
+	case cfg.L1ChainID == cfg.L2ChainID:
+		return errors.New("L1 and L2 chain IDs are equal")
+	case cfg.L2BlockTime == 0:
+		return errors.New("L2 block time must be positive")
+	case cfg.SequencerWindowSize == 0:
+		return errors.New("sequencer window size must be positive")
+	case cfg.L2GenesisGasLimit == 0:
+		return errors.New("missing L2 genesis gas limit")
+	case cfg.BatchInboxAddress == (common.Address{}):
+		return errors.New("missing batch inbox address")
+	case cfg.BatchSenderAddress == (common.Address{}):
+		return errors.New("missing batch sender address")
+	case cfg.DepositContractAddress == (common.Address{}):
+		return errors.New("missing deposit contract address")
+	}
+	for name := range cfg.PredeployCode {
+		if _, ok := Predeploys[name]; !ok {
+			return fmt.Errorf("code for unknown predeploy %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package genesis generates the genesis state of new L2 chains, together with
+// the matching rollup configuration.
+package genesis
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// Predeploys are the contracts that exist at fixed addresses from genesis.
+var Predeploys = map[string]common.Address{
+	"GasPriceOracle":      core.OVM_GasPriceOracleAddr,
+	"SequencerFeeVault":   derive.SequencerFeeVaultAddr,
+	"L1Block":             types.L1BlockAddr,
+	"L2ToL1MessagePasser": rollup.L2ToL1MessagePasserAddr,
+	"ProxyAdmin":          ProxyAdminAddr,
+	"BaseFeeVault":        BaseFeeVaultAddr,
+	"L1FeeVault":          L1FeeVaultAddr,
+}
+
+var (
+	// ProxyAdminAddr is the predeploy that administers the predeploy proxies.
+	ProxyAdminAddr = common.HexToAddress("0x4200000000000000000000000000000000000018")
+	// BaseFeeVaultAddr is the predeploy that receives the L2 base fees.
+	BaseFeeVaultAddr = common.HexToAddress("0x4200000000000000000000000000000000000019")
+	// L1FeeVaultAddr is the predeploy that receives the L1 data fees.
+	L1FeeVaultAddr = common.HexToAddress("0x420000000000000000000000000000000000001a")
+)
+
+// EIP-1967 proxy storage slots.
+var (
+	ProxyImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	ProxyAdminSlot          = common.HexToHash("0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103")
+)
+
+// implementationAddr returns the address of the implementation behind the proxy
+// of a predeploy: the last two bytes of the predeploy address in the code
+// namespace 0xc0d3c0d3...
+func implementationAddr(predeploy common.Address) common.Address {
+	addr := common.HexToAddress("0xc0d3c0d3c0d3c0d3c0d3c0d3c0d3c0d3c0d30000")
+	copy(addr[18:], predeploy[18:])
+	return addr
+}
+
+// BuildL2Genesis creates the genesis of the L2 chain described by the config,
+// anchored to the given L1 block.
+func BuildL2Genesis(cfg *DeployConfig, l1Anchor *types.Header) (*core.Genesis, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	alloc := make(core.GenesisAlloc)
+	for addr, account := range cfg.FundedAccounts {
+		if account.Balance == nil {
+			account.Balance = new(big.Int)
+		}
+		alloc[addr] = account
+	}
+	for name, addr := range Predeploys {
+		if _, ok := alloc[addr]; ok {
+			return nil, fmt.Errorf("funded account %s collides with predeploy %s", addr, name)
+		}
+		storage := make(map[common.Hash]common.Hash)
+		switch addr {
+		case core.OVM_GasPriceOracleAddr:
+			gpo := &core.GasPriceOracle{
+				Overhead: new(big.Int).SetUint64(cfg.GasPriceOracleOverhead),
+				Scalar:   new(big.Int).SetUint64(cfg.GasPriceOracleScalar),
+				Decimals: new(big.Int).SetUint64(cfg.GasPriceOracleDecimals),
+			}
+			storage = gpo.OracleStorage()
+		case ProxyAdminAddr:
+			storage[common.Hash{}] = common.BytesToHash(cfg.ProxyAdminOwner[:])
+		}
+		code := cfg.PredeployCode[name]
+		if len(cfg.ProxyCode) == 0 {
+			alloc[addr] = core.GenesisAccount{Code: code, Storage: storage, Balance: new(big.Int)}
+			continue
+		}
+		// The state lives in the proxy, the code in the implementation. The
+		// proxy admin administers all proxies, including its own.
+		impl := implementationAddr(addr)
+		storage[ProxyAdminSlot] = common.BytesToHash(ProxyAdminAddr[:])
+		storage[ProxyImplementationSlot] = common.BytesToHash(impl[:])
+		alloc[addr] = core.GenesisAccount{Code: cfg.ProxyCode, Storage: storage, Balance: new(big.Int)}
+		alloc[impl] = core.GenesisAccount{Code: code, Balance: new(big.Int)}
+	}
+
+	baseFee := new(big.Int).SetUint64(params.InitialBaseFee)
+	if cfg.L2GenesisBaseFee != nil {
+		baseFee = cfg.L2GenesisBaseFee.ToInt()
+	}
+	return &core.Genesis{
+		Config:     chainConfig(cfg),
+		Timestamp:  l1Anchor.Time,
+		GasLimit:   uint64(cfg.L2GenesisGasLimit),
+		Difficulty: new(big.Int),
+		BaseFee:    baseFee,
+		Alloc:      alloc,
+	}, nil
+}
+
+// chainConfig returns the L2 chain config, which has all forks up to the merge
+// active from genesis.
+func chainConfig(cfg *DeployConfig) *params.ChainConfig {
+	var regolithTime *uint64
+	if cfg.L2GenesisRegolithTime != nil {
+		regolithTime = (*uint64)(cfg.L2GenesisRegolithTime)
+	}
+	return &params.ChainConfig{
+		ChainID:                       new(big.Int).SetUint64(cfg.L2ChainID),
+		HomesteadBlock:                new(big.Int),
+		EIP150Block:                   new(big.Int),
+		EIP155Block:                   new(big.Int),
+		EIP158Block:                   new(big.Int),
+		ByzantiumBlock:                new(big.Int),
+		ConstantinopleBlock:           new(big.Int),
+		PetersburgBlock:               new(big.Int),
+		IstanbulBlock:                 new(big.Int),
+		MuirGlacierBlock:              new(big.Int),
+		BerlinBlock:                   new(big.Int),
+		LondonBlock:                   new(big.Int),
+		ArrowGlacierBlock:             new(big.Int),
+		GrayGlacierBlock:              new(big.Int),
+		MergeNetsplitBlock:            new(big.Int),
+		TerminalTotalDifficulty:       new(big.Int),
+		TerminalTotalDifficultyPassed: true,
+		Optimism: &params.OptimismConfig{
+			BaseFeeRecipient: BaseFeeVaultAddr,
+			L1FeeRecipient:   L1FeeVaultAddr,
+			RegolithTime:     regolithTime,
+
+			DepositGasExemptTime: (*uint64)(cfg.L2GenesisDepositGasExemptTime),
+			L1InfoCheckTime:      (*uint64)(cfg.L2GenesisL1InfoCheckTime),
+		},
+	}
+}
+
+// BuildRollupConfig creates the rollup configuration of the L2 chain with the
+// given genesis block, anchored to the given L1 block.
+func BuildRollupConfig(cfg *DeployConfig, l1Anchor *types.Header, l2Genesis *types.Block) *rollup.Config {
+	return &rollup.Config{
+		Genesis: rollup.Genesis{
+			L1:     rollup.BlockID{Hash: l1Anchor.Hash(), Number: l1Anchor.Number.Uint64()},
+			L2:     rollup.BlockID{Hash: l2Genesis.Hash(), Number: l2Genesis.NumberU64()},
+			L2Time: l2Genesis.Time(),
+		},
+		BlockTime:               cfg.L2BlockTime,
+		MaxSequencerDrift:       cfg.MaxSequencerDrift,
+		SeqWindowSize:           cfg.SequencerWindowSize,
+		ChannelTimeout:          cfg.ChannelTimeout,
+		StrictBatchOrdering:     cfg.StrictBatchOrdering,
+		DataAvailability:        cfg.DataAvailability,
+		L1ChainID:               new(big.Int).SetUint64(cfg.L1ChainID),
+		L2ChainID:               new(big.Int).SetUint64(cfg.L2ChainID),
+		BatchInboxAddress:       cfg.BatchInboxAddress,
+		BatchSenderAddress:      cfg.BatchSenderAddress,
+		DepositContractAddress:  cfg.DepositContractAddress,
+		SystemConfigAddress:     cfg.SystemConfigAddress,
+		GenesisGasLimit:         uint64(cfg.L2GenesisGasLimit),
+		MaxDepositsPerBlock:     cfg.MaxDepositsPerBlock,
+		MaxDepositBytesPerBlock: cfg.MaxDepositBytesPerBlock,
+		FeeRecipientAddress:     derive.SequencerFeeVaultAddr,
+		RegolithTime:            (*uint64)(cfg.L2GenesisRegolithTime),
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package genesis
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func testDeployConfig() *DeployConfig {
+	return &DeployConfig{
+		L1ChainID:              900,
+		L2ChainID:              901,
+		L2BlockTime:            2,
+		MaxSequencerDrift:      600,
+		SequencerWindowSize:    120,
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     common.HexToAddress("0x1234"),
+		DepositContractAddress: common.HexToAddress("0xdeadbeef"),
+		L2GenesisGasLimit:      30_000_000,
+		ProxyAdminOwner:        common.HexToAddress("0xadadad"),
+		GasPriceOracleOverhead: 2100,
+		GasPriceOracleScalar:   1_000_000,
+		GasPriceOracleDecimals: 6,
+		ProxyCode:              hexutil.Bytes{0x60, 0x01},
+		PredeployCode:          map[string]hexutil.Bytes{"L1Block": {0x60, 0x02}},
+		FundedAccounts: core.GenesisAlloc{
+			common.HexToAddress("0xf00d"): {Balance: big.NewInt(params.Ether)},
+		},
+	}
+}
+
+func TestBuildGenesis(t *testing.T) {
+	var (
+		cfg = testDeployConfig()
+		l1  = &types.Header{Number: big.NewInt(10), Time: 1000}
+	)
+	genesis, err := BuildL2Genesis(cfg, l1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The genesis survives a round trip through JSON, as written by the tool.
+	enc, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec core.Genesis
+	if err := json.Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	block := dec.MustCommit(rawdb.NewMemoryDatabase())
+	if block.Hash() != genesis.ToBlock(nil).Hash() {
+		t.Fatal("genesis block changed after encoding")
+	}
+	if block.Difficulty().Sign() != 0 || block.Time() != l1.Time || block.GasLimit() != 30_000_000 {
+		t.Fatalf("unexpected genesis header %+v", block.Header())
+	}
+	rollupCfg := BuildRollupConfig(cfg, l1, block)
+	if rollupCfg.Genesis.L2.Hash != block.Hash() || rollupCfg.Genesis.L1.Hash != l1.Hash() || rollupCfg.Genesis.L2Time != l1.Time {
+		t.Fatalf("rollup config does not match genesis: %+v", rollupCfg.Genesis)
+	}
+	if gasLimit := rollupCfg.GenesisSystemConfig().GasLimit; gasLimit != block.GasLimit() {
+		t.Fatalf("genesis system config has gas limit %d, want %d", gasLimit, block.GasLimit())
+	}
+	if err := rollupCfg.Check(); err != nil {
+		t.Fatalf("invalid rollup config: %v", err)
+	}
+
+	// Every predeploy is a proxy that points to its implementation.
+	for name, addr := range Predeploys {
+		proxy := genesis.Alloc[addr]
+		if !bytes.Equal(proxy.Code, cfg.ProxyCode) {
+			t.Errorf("%s: missing proxy code", name)
+		}
+		if proxy.Storage[ProxyAdminSlot] != common.BytesToHash(ProxyAdminAddr[:]) {
+			t.Errorf("%s: wrong proxy admin %x", name, proxy.Storage[ProxyAdminSlot])
+		}
+		impl := common.BytesToAddress(proxy.Storage[ProxyImplementationSlot].Bytes())
+		if _, ok := genesis.Alloc[impl]; !ok || impl[0] != 0xc0 || impl[19] != addr[19] {
+			t.Errorf("%s: wrong implementation %s", name, impl)
+		}
+	}
+	impl := common.BytesToAddress(genesis.Alloc[types.L1BlockAddr].Storage[ProxyImplementationSlot].Bytes())
+	if !bytes.Equal(genesis.Alloc[impl].Code, []byte{0x60, 0x02}) {
+		t.Error("missing predeploy code")
+	}
+	if owner := genesis.Alloc[ProxyAdminAddr].Storage[common.Hash{}]; owner != common.BytesToHash(cfg.ProxyAdminOwner[:]) {
+		t.Errorf("wrong proxy admin owner %x", owner)
+	}
+	if scalar := genesis.Alloc[core.OVM_GasPriceOracleAddr].Storage[core.ScalarSlot]; scalar != common.BigToHash(big.NewInt(1_000_000)) {
+		t.Errorf("wrong gas price oracle scalar %x", scalar)
+	}
+	if genesis.Alloc[common.HexToAddress("0xf00d")].Balance.Cmp(big.NewInt(params.Ether)) != 0 {
+		t.Error("funded account missing")
+	}
+}
+
+func TestBuildGenesisInvalid(t *testing.T) {
+	l1 := &types.Header{Number: big.NewInt(10), Time: 1000}
+
+	cfg := testDeployConfig()
+	cfg.L2ChainID = cfg.L1ChainID
+	if _, err := BuildL2Genesis(cfg, l1); err == nil {
+		t.Error("equal chain IDs accepted")
+	}
+	cfg = testDeployConfig()
+	cfg.PredeployCode["Unknown"] = hexutil.Bytes{1}
+	if _, err := BuildL2Genesis(cfg, l1); err == nil {
+		t.Error("code for unknown predeploy accepted")
+	}
+	cfg = testDeployConfig()
+	cfg.FundedAccounts[ProxyAdminAddr] = core.GenesisAccount{Balance: common.Big1}
+	if _, err := BuildL2Genesis(cfg, l1); err == nil {
+		t.Error("funded predeploy accepted")
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package genesis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rollup"
+)
+
+var (
+	// LegacyETHAddr is the ERC20 contract that holds the ETH balances of the
+	// legacy chain.
+	LegacyETHAddr = common.HexToAddress("0xDeadDeAddeAddEAddeadDEaDDEAdDeaDDeAD0000")
+	// LegacyMessagePasserAddr is the contract that recorded the withdrawals of
+	// the legacy chain.
+	LegacyMessagePasserAddr = common.HexToAddress("0x4200000000000000000000000000000000000000")
+	// L2CrossDomainMessengerAddr is the contract that sends the withdrawals of
+	// the legacy chain to L1.
+	L2CrossDomainMessengerAddr = common.HexToAddress("0x4200000000000000000000000000000000000007")
+)
+
+// Storage layout of the legacy ETH token, an OpenZeppelin ERC20.
+var (
+	legacyBalancesSlot    = common.Hash{}
+	legacyTotalSupplySlot = common.BigToHash(big.NewInt(2))
+)
+
+// Withdrawals of the legacy chain are replayed on L1 by the messenger, with
+// enough gas for the relayed call and its calldata.
+const (
+	withdrawalBaseGas     = 200_000
+	withdrawalCalldataGas = 16
+)
+
+var (
+	legacyRelayMessage abi.Method // relayMessage(address,address,bytes,uint256)
+	relayMessage       abi.Method // relayMessage(uint256,address,address,uint256,uint256,bytes)
+)
+
+func init() {
+	uint256, _ := abi.NewType("uint256", "", nil)
+	address, _ := abi.NewType("address", "", nil)
+	bytes, _ := abi.NewType("bytes", "", nil)
+	legacyRelayMessage = abi.NewMethod("relayMessage", "relayMessage", abi.Function, "", false, false,
+		abi.Arguments{{Name: "target", Type: address}, {Name: "sender", Type: address}, {Name: "message", Type: bytes}, {Name: "nonce", Type: uint256}}, nil)
+	relayMessage = abi.NewMethod("relayMessage", "relayMessage", abi.Function, "", false, true,
+		abi.Arguments{{Type: uint256}, {Type: address}, {Type: address}, {Type: uint256}, {Type: uint256}, {Type: bytes}}, nil)
+}
+
+// LegacyWithdrawal is a withdrawal that was sent on the legacy chain: the
+// calldata of the L1 messenger, sent by the L2 messenger.
+type LegacyWithdrawal struct {
+	Sender  common.Address `json:"sender"`
+	Message hexutil.Bytes  `json:"message"`
+}
+
+// storageSlot returns the slot of the legacy message passer that records the
+// withdrawal.
+func (w *LegacyWithdrawal) storageSlot() common.Hash {
+	hash := crypto.Keccak256(w.Message, w.Sender[:])
+	return crypto.Keccak256Hash(hash, common.Hash{}.Bytes())
+}
+
+// convert turns the legacy withdrawal into a withdrawal of the message passer,
+// which relays the legacy message through the L1 messenger.
+func (w *LegacyWithdrawal) convert(l1Messenger common.Address) (*rollup.Withdrawal, error) {
+	if w.Sender != L2CrossDomainMessengerAddr {
+		return nil, fmt.Errorf("unexpected sender %s", w.Sender)
+	}
+	if len(w.Message) < 4 || !bytes.Equal(w.Message[:4], legacyRelayMessage.ID) {
+		return nil, errors.New("not a relayMessage call")
+	}
+	args, err := legacyRelayMessage.Inputs.Unpack(w.Message[4:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid relayMessage call: %v", err)
+	}
+	var (
+		target  = args[0].(common.Address)
+		sender  = args[1].(common.Address)
+		message = args[2].([]byte)
+		nonce   = args[3].(*big.Int)
+	)
+	// The nonce carries the version of the message encoding in its top two
+	// bytes, the relayed legacy messages are version 1.
+	versioned := new(big.Int).Or(nonce, new(big.Int).Lsh(big.NewInt(1), 240))
+	data, err := relayMessage.Inputs.Pack(versioned, sender, target, new(big.Int), new(big.Int), message)
+	if err != nil {
+		return nil, err
+	}
+	data = append(common.CopyBytes(relayMessage.ID), data...)
+	return &rollup.Withdrawal{
+		Nonce:    versioned,
+		Sender:   L2CrossDomainMessengerAddr,
+		Target:   l1Messenger,
+		Value:    new(big.Int),
+		GasLimit: new(big.Int).SetUint64(withdrawalBaseGas + withdrawalCalldataGas*uint64(len(data))),
+		Data:     data,
+	}, nil
+}
+
+// MigrationReport summarizes the changes of a migration.
+type MigrationReport struct {
+	Accounts    int           `json:"accounts"`    // accounts of the legacy state
+	Minted      *hexutil.Big  `json:"minted"`      // ETH moved from the legacy token to the balances
+	TotalSupply *hexutil.Big  `json:"totalSupply"` // total supply of the legacy token
+	Withdrawals int           `json:"withdrawals"` // converted withdrawals
+	Diffs       []AccountDiff `json:"diffs"`       // changed accounts, sorted by address
+}
+
+// AccountDiff is the change of a single account in a migration.
+type AccountDiff struct {
+	Address       common.Address              `json:"address"`
+	BalanceBefore *hexutil.Big                `json:"balanceBefore,omitempty"`
+	BalanceAfter  *hexutil.Big                `json:"balanceAfter,omitempty"`
+	CodeChanged   bool                        `json:"codeChanged,omitempty"`
+	Storage       map[common.Hash]StorageDiff `json:"storage,omitempty"`
+}
+
+// StorageDiff is the change of a storage slot in a migration.
+type StorageDiff struct {
+	Before common.Hash `json:"before"`
+	After  common.Hash `json:"after"`
+}
+
+// MigrateLegacyState creates the genesis of an L2 chain that continues the
+// legacy chain with the given head block, whose state was dumped. The L2 chain
+// is described by the deploy config and anchored to the given L1 block.
+//
+// The migration
+//   - moves the balances of the legacy ETH token into the account balances,
+//   - replaces the code and storage of the predeploys,
+//   - converts the given legacy withdrawals into withdrawals of the message
+//     passer, after checking that they were sent on the legacy chain.
+//
+// The genesis block follows the legacy head, whose history is served by the
+// legacy node.
+func MigrateLegacyState(cfg *DeployConfig, dump *state.Dump, withdrawals []*LegacyWithdrawal, legacyHead, l1Anchor *types.Header) (*core.Genesis, *MigrationReport, error) {
+	if cfg.L1CrossDomainMessengerAddress == (common.Address{}) {
+		return nil, nil, errors.New("missing L1 cross domain messenger address")
+	}
+	if root := common.HexToHash(dump.Root); root != legacyHead.Root {
+		return nil, nil, fmt.Errorf("state dump of root %s, legacy head has root %s", root, legacyHead.Root)
+	}
+	if l1Anchor.Time <= legacyHead.Time {
+		return nil, nil, fmt.Errorf("L1 anchor time %d not after the legacy head time %d", l1Anchor.Time, legacyHead.Time)
+	}
+	before, err := dumpAlloc(dump)
+	if err != nil {
+		return nil, nil, err
+	}
+	after := make(core.GenesisAlloc, len(before))
+	for addr, account := range before {
+		account.Balance = new(big.Int).Set(account.Balance)
+		account.Storage = copyStorage(account.Storage)
+		after[addr] = account
+	}
+
+	// Mint the balances of the legacy token and check that they add up to its
+	// total supply. The token keeps its code, but loses its state.
+	report := &MigrationReport{Accounts: len(before)}
+	token, ok := after[LegacyETHAddr]
+	if !ok {
+		return nil, nil, errors.New("legacy ETH token missing from the state dump")
+	}
+	minted := new(big.Int)
+	for addr, account := range after {
+		slot := crypto.Keccak256Hash(common.BytesToHash(addr[:]).Bytes(), legacyBalancesSlot[:])
+		balance := token.Storage[slot].Big()
+		if balance.Sign() == 0 {
+			continue
+		}
+		account.Balance.Add(account.Balance, balance)
+		after[addr] = account
+		minted.Add(minted, balance)
+	}
+	totalSupply := token.Storage[legacyTotalSupplySlot].Big()
+	if minted.Cmp(totalSupply) != 0 {
+		return nil, nil, fmt.Errorf("minted %v from the legacy token, total supply is %v", minted, totalSupply)
+	}
+	report.Minted, report.TotalSupply = (*hexutil.Big)(minted), (*hexutil.Big)(totalSupply)
+	token.Storage = nil
+	after[LegacyETHAddr] = token
+
+	// Convert the withdrawals that the legacy message passer recorded.
+	passer, ok := after[LegacyMessagePasserAddr]
+	if !ok && len(withdrawals) > 0 {
+		return nil, nil, errors.New("legacy message passer missing from the state dump")
+	}
+	withdrawalSlots := make(map[common.Hash]common.Hash)
+	for i, w := range withdrawals {
+		if passer.Storage[w.storageSlot()] == (common.Hash{}) {
+			return nil, nil, fmt.Errorf("withdrawal %d not sent on the legacy chain", i)
+		}
+		converted, err := w.convert(cfg.L1CrossDomainMessengerAddress)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid withdrawal %d: %v", i, err)
+		}
+		slot, err := converted.StorageSlot()
+		if err != nil {
+			return nil, nil, err
+		}
+		withdrawalSlots[slot] = common.BigToHash(common.Big1)
+	}
+	report.Withdrawals = len(withdrawals)
+	if ok {
+		passer.Storage = nil
+		after[LegacyMessagePasserAddr] = passer
+	}
+
+	// Replace the predeploys with the ones of the deploy config. They keep
+	// their balances, the fee vaults in particular.
+	genesis, err := BuildL2Genesis(cfg, l1Anchor)
+	if err != nil {
+		return nil, nil, err
+	}
+	for addr, account := range genesis.Alloc {
+		if _, ok := cfg.FundedAccounts[addr]; ok {
+			continue
+		}
+		if prev, ok := after[addr]; ok {
+			account.Balance = prev.Balance
+			account.Nonce = prev.Nonce
+		}
+		if addr == rollup.L2ToL1MessagePasserAddr {
+			for slot, value := range withdrawalSlots {
+				account.Storage[slot] = value
+			}
+		}
+		after[addr] = account
+	}
+	report.Diffs = diffAlloc(before, after)
+
+	genesis.Alloc = after
+	genesis.Number = legacyHead.Number.Uint64() + 1
+	genesis.ParentHash = legacyHead.Hash()
+	genesis.Config.Optimism.MigrationBlock = new(big.Int).SetUint64(genesis.Number)
+	return genesis, report, nil
+}
+
+// dumpAlloc converts a state dump into genesis accounts.
+func dumpAlloc(dump *state.Dump) (core.GenesisAlloc, error) {
+	alloc := make(core.GenesisAlloc, len(dump.Accounts))
+	for addr, account := range dump.Accounts {
+		if len(account.SecureKey) > 0 && crypto.Keccak256Hash(addr[:]) != common.BytesToHash(account.SecureKey) {
+			return nil, fmt.Errorf("missing preimage of account %x", account.SecureKey)
+		}
+		balance, ok := new(big.Int).SetString(account.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance %q of account %s", account.Balance, addr)
+		}
+		storage := make(map[common.Hash]common.Hash, len(account.Storage))
+		for slot, value := range account.Storage {
+			storage[slot] = common.HexToHash(value)
+		}
+		alloc[addr] = core.GenesisAccount{
+			Code:    account.Code,
+			Storage: storage,
+			Balance: balance,
+			Nonce:   account.Nonce,
+		}
+	}
+	return alloc, nil
+}
+
+func copyStorage(storage map[common.Hash]common.Hash) map[common.Hash]common.Hash {
+	cpy := make(map[common.Hash]common.Hash, len(storage))
+	for slot, value := range storage {
+		cpy[slot] = value
+	}
+	return cpy
+}
+
+// diffAlloc returns the changes between two sets of accounts. Empty storage
+// slots are treated like missing ones.
+func diffAlloc(before, after core.GenesisAlloc) []AccountDiff {
+	addrs := make(map[common.Address]struct{})
+	for addr := range before {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range after {
+		addrs[addr] = struct{}{}
+	}
+	var diffs []AccountDiff
+	for addr := range addrs {
+		var (
+			prev, prevOk = before[addr]
+			next, nextOk = after[addr]
+			diff         = AccountDiff{Address: addr, Storage: make(map[common.Hash]StorageDiff)}
+		)
+		if prevOk {
+			diff.BalanceBefore = (*hexutil.Big)(prev.Balance)
+		}
+		if nextOk {
+			diff.BalanceAfter = (*hexutil.Big)(next.Balance)
+		}
+		balanceChanged := prevOk != nextOk || prev.Balance.Cmp(next.Balance) != 0
+		if !balanceChanged {
+			diff.BalanceBefore, diff.BalanceAfter = nil, nil
+		}
+		diff.CodeChanged = !bytes.Equal(prev.Code, next.Code)
+		for slot, value := range prev.Storage {
+			if next.Storage[slot] != value {
+				diff.Storage[slot] = StorageDiff{Before: value, After: next.Storage[slot]}
+			}
+		}
+		for slot, value := range next.Storage {
+			if _, ok := prev.Storage[slot]; !ok && value != (common.Hash{}) {
+				diff.Storage[slot] = StorageDiff{After: value}
+			}
+		}
+		if len(diff.Storage) == 0 {
+			diff.Storage = nil
+		}
+		if balanceChanged || diff.CodeChanged || diff.Storage != nil {
+			diffs = append(diffs, diff)
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Address[:], diffs[j].Address[:]) < 0
+	})
+	return diffs
+}
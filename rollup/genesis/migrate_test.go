@@ -0,0 +1,169 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package genesis
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	legacyAlice = common.HexToAddress("0xa11ce")
+	legacyBob   = common.HexToAddress("0xb0b")
+)
+
+func legacyBalanceSlot(addr common.Address) common.Hash {
+	return crypto.Keccak256Hash(common.BytesToHash(addr[:]).Bytes(), legacyBalancesSlot[:])
+}
+
+func legacyWithdrawal(t *testing.T, nonce int64) *LegacyWithdrawal {
+	enc, err := legacyRelayMessage.Inputs.Pack(common.HexToAddress("0x7a46e7"), legacyAlice, []byte{1, 2, 3}, big.NewInt(nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LegacyWithdrawal{Sender: L2CrossDomainMessengerAddr, Message: append(common.CopyBytes(legacyRelayMessage.ID), enc...)}
+}
+
+// newLegacyState creates the state of a legacy chain, in which alice and bob
+// hold ETH and the first of the given withdrawals was sent.
+func newLegacyState(t *testing.T, sent *LegacyWithdrawal, totalSupply int64) (*state.Dump, *types.Header) {
+	db := state.NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &trie.Config{Preimages: true})
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	statedb.SetCode(LegacyETHAddr, []byte{0x60, 0x00})
+	statedb.SetState(LegacyETHAddr, legacyBalanceSlot(legacyAlice), common.BigToHash(big.NewInt(5)))
+	statedb.SetState(LegacyETHAddr, legacyBalanceSlot(legacyBob), common.BigToHash(big.NewInt(7)))
+	statedb.SetState(LegacyETHAddr, legacyTotalSupplySlot, common.BigToHash(big.NewInt(totalSupply)))
+	statedb.SetNonce(legacyAlice, 3)
+	statedb.SetNonce(legacyBob, 1)
+	statedb.SetCode(LegacyMessagePasserAddr, []byte{0x60, 0x01})
+	statedb.SetState(LegacyMessagePasserAddr, sent.storageSlot(), common.BigToHash(common.Big1))
+	statedb.AddBalance(derive.SequencerFeeVaultAddr, big.NewInt(100))
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	statedb, _ = state.New(root, db, nil)
+	dump := statedb.RawDump(nil)
+	return &dump, &types.Header{Number: big.NewInt(100), Time: 900, Root: root}
+}
+
+func TestMigrateLegacyState(t *testing.T) {
+	var (
+		cfg        = testDeployConfig()
+		l1         = &types.Header{Number: big.NewInt(10), Time: 1000}
+		withdrawal = legacyWithdrawal(t, 4)
+	)
+	cfg.L1CrossDomainMessengerAddress = common.HexToAddress("0x1c0de")
+	dump, head := newLegacyState(t, withdrawal, 12)
+
+	genesis, report, err := MigrateLegacyState(cfg, dump, []*LegacyWithdrawal{withdrawal}, head, l1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := genesis.ToBlock(nil)
+	if block.NumberU64() != 101 || block.ParentHash() != head.Hash() || block.Time() != l1.Time {
+		t.Fatalf("unexpected genesis header %+v", block.Header())
+	}
+	if genesis.Config.Optimism.MigrationBlock.Uint64() != 101 {
+		t.Fatalf("wrong migration block %v", genesis.Config.Optimism.MigrationBlock)
+	}
+
+	// The ETH balances are minted, accounts keep their nonces.
+	alloc := genesis.Alloc
+	if alice := alloc[legacyAlice]; alice.Balance.Int64() != 5 || alice.Nonce != 3 {
+		t.Errorf("alice has balance %v, nonce %d", alice.Balance, alice.Nonce)
+	}
+	if bob := alloc[legacyBob]; bob.Balance.Int64() != 7 {
+		t.Errorf("bob has balance %v", bob.Balance)
+	}
+	if report.Minted.ToInt().Int64() != 12 || report.TotalSupply.ToInt().Int64() != 12 {
+		t.Errorf("report minted %v of total supply %v", report.Minted, report.TotalSupply)
+	}
+	if len(alloc[LegacyETHAddr].Storage) != 0 || len(alloc[LegacyMessagePasserAddr].Storage) != 0 {
+		t.Error("legacy contract storage not wiped")
+	}
+
+	// The predeploys are replaced, but keep their balances.
+	if vault := alloc[derive.SequencerFeeVaultAddr]; vault.Balance.Int64() != 100 || vault.Storage[ProxyAdminSlot] != common.BytesToHash(ProxyAdminAddr[:]) {
+		t.Errorf("sequencer fee vault not migrated: %+v", vault)
+	}
+	if _, ok := alloc[common.HexToAddress("0xf00d")]; ok {
+		t.Error("funded account of the deploy config created")
+	}
+
+	// The withdrawal is recorded by the message passer.
+	converted, err := withdrawal.convert(cfg.L1CrossDomainMessengerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.Target != cfg.L1CrossDomainMessengerAddress || converted.Nonce.Cmp(new(big.Int).Lsh(big.NewInt(1), 240)) <= 0 {
+		t.Errorf("unexpected withdrawal %+v", converted)
+	}
+	slot, _ := converted.StorageSlot()
+	if alloc[rollup.L2ToL1MessagePasserAddr].Storage[slot] != common.BigToHash(common.Big1) {
+		t.Error("withdrawal missing from the message passer")
+	}
+
+	// The diff holds the changed accounts only.
+	diffs := make(map[common.Address]AccountDiff)
+	for _, diff := range report.Diffs {
+		diffs[diff.Address] = diff
+	}
+	if diff, ok := diffs[legacyAlice]; !ok || diff.BalanceBefore.ToInt().Sign() != 0 || diff.BalanceAfter.ToInt().Int64() != 5 {
+		t.Errorf("wrong diff of alice: %+v", diff)
+	}
+	if diff := diffs[LegacyETHAddr]; diff.CodeChanged || len(diff.Storage) != 3 {
+		t.Errorf("wrong diff of the legacy token: %+v", diff)
+	}
+	if _, ok := diffs[rollup.L2ToL1MessagePasserAddr]; !ok {
+		t.Error("message passer missing from the diff")
+	}
+}
+
+func TestMigrateLegacyStateInvalid(t *testing.T) {
+	var (
+		cfg        = testDeployConfig()
+		l1         = &types.Header{Number: big.NewInt(10), Time: 1000}
+		withdrawal = legacyWithdrawal(t, 4)
+	)
+	cfg.L1CrossDomainMessengerAddress = common.HexToAddress("0x1c0de")
+
+	dump, head := newLegacyState(t, withdrawal, 13)
+	if _, _, err := MigrateLegacyState(cfg, dump, nil, head, l1); err == nil {
+		t.Error("balances not matching the total supply accepted")
+	}
+	dump, head = newLegacyState(t, withdrawal, 12)
+	if _, _, err := MigrateLegacyState(cfg, dump, []*LegacyWithdrawal{legacyWithdrawal(t, 5)}, head, l1); err == nil {
+		t.Error("unsent withdrawal accepted")
+	}
+	head.Root = common.Hash{1}
+	if _, _, err := MigrateLegacyState(cfg, dump, nil, head, l1); err == nil {
+		t.Error("state dump of another block accepted")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// defaultBlockDuration is how long peers and addresses are blocked through the
+// API if no duration is given.
+const defaultBlockDuration = 24 * time.Hour
+
+var errNotBlocked = errors.New("not blocked")
+
+// API is the opp2p_ RPC namespace, which lets operators inspect the gossip
+// peers and block misbehaving ones.
+type API struct {
+	gossip *Gossip
+}
+
+// APIs returns the RPC APIs of the payload gossip.
+func (g *Gossip) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "opp2p",
+			Service:   &API{gossip: g},
+		},
+	}
+}
+
+// Peers returns the connected peers with their scores.
+func (api *API) Peers() []PeerStatus {
+	return api.gossip.Peers()
+}
+
+// BlockPeer disconnects a peer and bans it for the given number of seconds, or
+// a day if zero.
+func (api *API) BlockPeer(id enode.ID, seconds uint64) {
+	api.gossip.BlockPeer(id, blockDuration(seconds))
+}
+
+// UnblockPeer lifts the ban of a peer.
+func (api *API) UnblockPeer(id enode.ID) error {
+	if !api.gossip.UnblockPeer(id) {
+		return errNotBlocked
+	}
+	return nil
+}
+
+// BlockAddr disconnects the peers at an IP address and bans the address for the
+// given number of seconds, or a day if zero.
+func (api *API) BlockAddr(ip net.IP, seconds uint64) {
+	api.gossip.BlockIP(ip, blockDuration(seconds))
+}
+
+// UnblockAddr lifts the ban of an IP address.
+func (api *API) UnblockAddr(ip net.IP) error {
+	if !api.gossip.UnblockIP(ip) {
+		return errNotBlocked
+	}
+	return nil
+}
+
+// ListBlocked returns the peers and addresses that are banned, along with the
+// expiry of their bans. Peers that were banned for their score are included.
+func (api *API) ListBlocked() []Ban {
+	return api.gossip.Bans()
+}
+
+func blockDuration(seconds uint64) time.Duration {
+	if seconds == 0 {
+		return defaultBlockDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestAPI(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	g, other := New(cfg, log.New()), New(cfg, log.New())
+	errc := connect(other, g)
+
+	srv := rpc.NewServer()
+	for _, api := range g.APIs() {
+		if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := rpc.DialInProc(srv)
+	defer client.Close()
+
+	var peers []PeerStatus
+	if err := client.Call(&peers, "opp2p_peers"); err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0].Version != ROLLUP2 {
+		t.Fatalf("unexpected peers %+v", peers)
+	}
+	// Blocking a peer disconnects it.
+	if err := client.Call(nil, "opp2p_blockPeer", peers[0].ID, 60); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errc:
+	case <-time.After(time.Second):
+		t.Fatal("blocked peer not disconnected")
+	}
+	if err := client.Call(nil, "opp2p_blockAddr", "10.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	var bans []Ban
+	if err := client.Call(&bans, "opp2p_listBlocked"); err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 2 || bans[0].Peer == nil || *bans[0].Peer != peers[0].ID || !bans[1].IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("unexpected bans %+v", bans)
+	}
+	if err := client.Call(nil, "opp2p_unblockPeer", peers[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "opp2p_unblockPeer", peers[0].ID); err == nil {
+		t.Fatal("unblocked peer that is not blocked")
+	}
+	if err := client.Call(nil, "opp2p_unblockAddr", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if bans := g.Bans(); len(bans) != 0 {
+		t.Fatalf("bans left after unblocking: %+v", bans)
+	}
+}
+
+func TestBanListExpiry(t *testing.T) {
+	var (
+		bans = newBanList()
+		now  = time.Unix(1000, 0)
+		id   = enode.ID{1}
+		ip   = net.ParseIP("10.0.0.1")
+	)
+	bans.now = func() time.Time { return now }
+	bans.banPeer(id, time.Minute)
+	bans.banIP(ip, time.Hour)
+	if !bans.banned(id, nil) || !bans.banned(enode.ID{2}, ip) || bans.banned(enode.ID{2}, net.ParseIP("10.0.0.2")) {
+		t.Fatal("wrong ban status")
+	}
+	now = now.Add(time.Minute)
+	if bans.banned(id, nil) || !bans.banned(id, ip) {
+		t.Fatal("peer ban not expired")
+	}
+	now = now.Add(time.Hour)
+	if len(bans.list()) != 0 {
+		t.Fatal("bans not expired")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Ban is a peer or an IP address that may not connect to the gossip until the
+// ban expires.
+type Ban struct {
+	Peer    *enode.ID `json:"peer,omitempty"`
+	IP      net.IP    `json:"ip,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// banList holds the banned peers and IP addresses. Expired bans are removed
+// when they are looked up.
+type banList struct {
+	now func() time.Time
+
+	lock  sync.Mutex
+	peers map[enode.ID]time.Time
+	ips   map[string]time.Time
+}
+
+func newBanList() *banList {
+	return &banList{
+		now:   time.Now,
+		peers: make(map[enode.ID]time.Time),
+		ips:   make(map[string]time.Time),
+	}
+}
+
+// banPeer bans a peer for the given duration, replacing an earlier ban.
+func (b *banList) banPeer(id enode.ID, d time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.peers[id] = b.now().Add(d)
+}
+
+// banIP bans an IP address for the given duration, replacing an earlier ban.
+func (b *banList) banIP(ip net.IP, d time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.ips[ip.String()] = b.now().Add(d)
+}
+
+// unbanPeer lifts the ban of a peer. It returns whether the peer was banned.
+func (b *banList) unbanPeer(id enode.ID) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	_, ok := b.peers[id]
+	delete(b.peers, id)
+	return ok
+}
+
+// unbanIP lifts the ban of an IP address. It returns whether it was banned.
+func (b *banList) unbanIP(ip net.IP) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	_, ok := b.ips[ip.String()]
+	delete(b.ips, ip.String())
+	return ok
+}
+
+// banned reports whether the peer or its IP address, which may be nil, is
+// banned.
+func (b *banList) banned(id enode.ID, ip net.IP) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.expire()
+
+	if _, ok := b.peers[id]; ok {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	_, ok := b.ips[ip.String()]
+	return ok
+}
+
+// list returns the bans in effect, the peers first.
+func (b *banList) list() []Ban {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.expire()
+
+	bans := make([]Ban, 0, len(b.peers)+len(b.ips))
+	for id, expires := range b.peers {
+		id := id
+		bans = append(bans, Ban{Peer: &id, Expires: expires})
+	}
+	for ip, expires := range b.ips {
+		bans = append(bans, Ban{IP: net.ParseIP(ip), Expires: expires})
+	}
+	sort.SliceStable(bans, func(i, j int) bool {
+		if (bans[i].Peer == nil) != (bans[j].Peer == nil) {
+			return bans[i].Peer != nil
+		}
+		return bans[i].Expires.Before(bans[j].Expires)
+	})
+	return bans
+}
+
+// expire removes the expired bans. The lock must be held.
+func (b *banList) expire() {
+	now := b.now()
+	for id, expires := range b.peers {
+		if !now.Before(expires) {
+			delete(b.peers, id)
+		}
+	}
+	for ip, expires := range b.ips {
+		if !now.Before(expires) {
+			delete(b.ips, ip)
+		}
+	}
+}
+
+// remoteIP returns the IP address of a network address, nil if it has none.
+func remoteIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// enrEntry is the ENR entry which advertises the `rollup` protocol on the
+// discovery, along with the L2 chain of the node.
+type enrEntry struct {
+	ChainID *big.Int
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e enrEntry) ENRKey() string {
+	return ProtocolName
+}
+
+// enrEntry returns the ENR entry of the local node.
+func (g *Gossip) enrEntry() *enrEntry {
+	return &enrEntry{ChainID: g.cfg.ChainID}
+}
+
+// nodeFilter reports whether a discovered node gossips the payloads of the
+// same L2 chain.
+func (g *Gossip) nodeFilter(n *enode.Node) bool {
+	var entry enrEntry
+	if err := n.Load(&entry); err != nil || entry.ChainID == nil {
+		return false
+	}
+	return entry.ChainID.Cmp(g.cfg.ChainID) == 0
+}
+
+// SetDiscovery sets the source of the dial candidates of the gossip, usually
+// the random nodes of the discovery, which only exists once the p2p server is
+// started. Only the nodes of the same L2 chain are dialed.
+func (g *Gossip) SetDiscovery(it enode.Iterator) {
+	g.candidates.setSource(enode.Filter(it, g.nodeFilter))
+}
+
+// dialCandidates is the enode.Iterator of the dial candidates, handed to the
+// p2p server before its source is known. Next blocks until the source is set.
+type dialCandidates struct {
+	ready  chan struct{} // closed when the source is set
+	closed chan struct{}
+
+	lock sync.Mutex
+	it   enode.Iterator
+}
+
+func newDialCandidates() *dialCandidates {
+	return &dialCandidates{
+		ready:  make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+func (d *dialCandidates) setSource(it enode.Iterator) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// The source is set only once, later sources are discarded.
+	select {
+	case <-d.closed:
+		it.Close()
+		return
+	case <-d.ready:
+		it.Close()
+		return
+	default:
+	}
+	d.it = it
+	close(d.ready)
+}
+
+// Next implements enode.Iterator.
+func (d *dialCandidates) Next() bool {
+	select {
+	case <-d.ready:
+		return d.it.Next()
+	case <-d.closed:
+		return false
+	}
+}
+
+// Node implements enode.Iterator.
+func (d *dialCandidates) Node() *enode.Node {
+	select {
+	case <-d.ready:
+		return d.it.Node()
+	default:
+		return nil
+	}
+}
+
+// Close implements enode.Iterator.
+func (d *dialCandidates) Close() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	select {
+	case <-d.closed:
+		return
+	default:
+	}
+	close(d.closed)
+	if d.it != nil {
+		d.it.Close()
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// testNode returns a node record with the given ENR entries.
+func testNode(t *testing.T, entries ...enr.Entry) *enode.Node {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	for _, e := range entries {
+		r.Set(e)
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDiscoveryCandidates(t *testing.T) {
+	g := New(Config{ChainID: testChainID}, log.New())
+	defer g.Close()
+
+	var (
+		same  = testNode(t, g.enrEntry())
+		other = testNode(t, &enrEntry{ChainID: new(big.Int).Add(testChainID, big.NewInt(1))})
+		eth   = testNode(t)
+	)
+	// The candidates wait for the discovery to start.
+	next := make(chan bool, 1)
+	go func() { next <- g.candidates.Next() }()
+	select {
+	case <-next:
+		t.Fatal("candidate returned before the discovery is set")
+	case <-time.After(50 * time.Millisecond):
+	}
+	g.SetDiscovery(enode.IterNodes([]*enode.Node{eth, other, same}))
+	select {
+	case ok := <-next:
+		if !ok {
+			t.Fatal("no candidate")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("candidate not returned")
+	}
+	// Only the node of the same chain is dialed.
+	if n := g.candidates.Node(); n.ID() != same.ID() {
+		t.Fatalf("candidate %v, want the node of the same chain", n.ID())
+	}
+	if g.candidates.Next() {
+		t.Fatalf("unexpected candidate %v", g.candidates.Node().ID())
+	}
+}
+
+func TestDiscoveryCandidatesClose(t *testing.T) {
+	g := New(Config{ChainID: testChainID}, log.New())
+	next := make(chan bool, 1)
+	go func() { next <- g.candidates.Next() }()
+	g.Close()
+	select {
+	case ok := <-next:
+		if ok {
+			t.Fatal("candidate returned after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("candidates not closed")
+	}
+}
@@ -0,0 +1,631 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gossip implements the devp2p protocol that rollup nodes use to gossip
+// unsafe L2 payloads: the sequencer broadcasts every block it builds, and other
+// nodes can import it before it is confirmed on L1. Nodes that missed payloads
+// can request them from their peers.
+package gossip
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// seenCacheSize is the number of payload hashes remembered to avoid
+	// processing and relaying the same payload twice.
+	seenCacheSize = 1024
+
+	// peerQueueSize is the number of payloads queued for sending to a peer.
+	// Payloads are dropped for peers that do not keep up.
+	peerQueueSize = 16
+
+	// recentPayloads is the number of the latest payloads kept to serve the
+	// requests of peers that missed them.
+	recentPayloads = 256
+
+	// maxPayloadsRequest is the maximum number of payloads requested or
+	// served at once.
+	maxPayloadsRequest = 64
+
+	// maxResponseSize is the size of the served payloads beyond which a
+	// response is cut short.
+	maxResponseSize = 2 * 1024 * 1024
+
+	// peerMsgRate and peerMsgBurst limit the rate of messages of a peer.
+	// Messages beyond it are dropped as spam.
+	peerMsgRate  = 20
+	peerMsgBurst = 100
+
+	// maxPayloadAge and maxPayloadFuture bound the timestamps of gossiped
+	// payloads, relative to the local clock. Older payloads are not relayed,
+	// nodes that miss them request them.
+	maxPayloadAge    = time.Minute
+	maxPayloadFuture = 5 * time.Second
+
+	// banDuration is how long peers are banned once their score drops below
+	// the disconnect threshold.
+	banDuration = time.Hour
+)
+
+// Peer scores. A peer gains score for every new valid payload it relays and
+// loses score for invalid, stale and excess messages. Peers whose score drops
+// below the disconnect threshold are dropped and banned.
+const (
+	scoreValidPayload     = 1
+	scoreMax              = 100
+	penaltyStalePayload   = 2
+	penaltySpam           = 5
+	penaltyInvalidPayload = 10
+	penaltyUndecodable    = 20
+	disconnectThreshold   = -50
+)
+
+var (
+	errPeerScore     = errors.New("peer score too low")
+	errNoPeers       = errors.New("no peer serves payloads")
+	errPeerGone      = errors.New("peer disconnected")
+	errBanned        = errors.New("peer banned")
+	errSpam          = errors.New("message rate exceeded")
+	errStalePayload  = errors.New("stale payload")
+	errFuturePayload = errors.New("payload from the future")
+)
+
+// Config contains the settings of the payload gossip.
+type Config struct {
+	// ChainID is the L2 chain ID, which is part of the signed data.
+	ChainID *big.Int
+	// SequencerAddress is the address of the key that signs valid payloads,
+	// unless the signer set authorizes other keys.
+	SequencerAddress common.Address
+	// SequencerKey signs published payloads. Only set on the sequencer.
+	SequencerKey *ecdsa.PrivateKey
+}
+
+// SignerSet provides the keys that are authorized to sign payloads. The system
+// config on L1 can change them, so that the sequencer key can be rotated
+// without reconfiguring every node.
+type SignerSet interface {
+	// UnsafeBlockSigners returns the authorized keys, or nil if the
+	// configured sequencer address applies. An empty, non-nil list
+	// authorizes no key.
+	UnsafeBlockSigners() []common.Address
+}
+
+// Gossip broadcasts and receives unsafe L2 payloads.
+type Gossip struct {
+	cfg    Config
+	log    log.Logger
+	seen   *lru.Cache // hashes of payloads that were already processed
+	recent *lru.Cache // latest signed payloads by number, served to peers
+	bans   *banList
+	now    func() time.Time
+
+	candidates *dialCandidates // discovered nodes of the same chain
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+
+	lock    sync.RWMutex
+	signers SignerSet // authorized signers, nil for the sequencer address
+	peers   map[enode.ID]*peer
+	pending map[uint64]*payloadsRequest // requests awaiting a response, by ID
+	reqID   uint64                      // ID of the last request, accessed atomically
+}
+
+// payloadsRequest is a request for payloads sent to a peer.
+type payloadsRequest struct {
+	peer        enode.ID
+	from, count uint64
+	res         chan []*beacon.ExecutableDataV1
+}
+
+// New creates a payload gossip instance.
+func New(cfg Config, logger log.Logger) *Gossip {
+	seen, _ := lru.New(seenCacheSize)
+	recent, _ := lru.New(recentPayloads)
+	return &Gossip{
+		cfg:        cfg,
+		log:        logger,
+		seen:       seen,
+		recent:     recent,
+		bans:       newBanList(),
+		now:        time.Now,
+		candidates: newDialCandidates(),
+		peers:      make(map[enode.ID]*peer),
+		pending:    make(map[uint64]*payloadsRequest),
+	}
+}
+
+// Protocols returns the devp2p protocols of the payload gossip, to be run by a
+// p2p server.
+func (g *Gossip) Protocols() []p2p.Protocol {
+	protocols := make([]p2p.Protocol, len(ProtocolVersions))
+	for i, version := range ProtocolVersions {
+		version := version // Closure
+		protocols[i] = p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  protocolLengths[version],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return g.runPeer(version, p, rw)
+			},
+			PeerInfo: func(id enode.ID) interface{} {
+				return g.peerInfo(id)
+			},
+			Attributes:     []enr.Entry{g.enrEntry()},
+			DialCandidates: g.candidates,
+		}
+	}
+	return protocols
+}
+
+// SubscribePayloads subscribes to valid payloads received from the network.
+func (g *Gossip) SubscribePayloads(ch chan<- *beacon.ExecutableDataV1) event.Subscription {
+	return g.scope.Track(g.feed.Subscribe(ch))
+}
+
+// Publish signs the given payload with the sequencer key and broadcasts it to
+// all peers.
+func (g *Gossip) Publish(payload *beacon.ExecutableDataV1) error {
+	if g.cfg.SequencerKey == nil {
+		return errors.New("no sequencer key to sign payloads with")
+	}
+	signed, err := SignPayload(g.cfg.SequencerKey, g.cfg.ChainID, payload)
+	if err != nil {
+		return err
+	}
+	g.seen.Add(payload.BlockHash, struct{}{})
+	g.recent.Add(payload.Number, signed)
+	g.broadcast(signed, enode.ID{})
+	return nil
+}
+
+// PayloadsByNumber requests the payloads with consecutive numbers from the
+// peer with the best score. The response may contain fewer payloads than
+// requested, and is verified to be signed by the sequencer.
+func (g *Gossip) PayloadsByNumber(ctx context.Context, from, count uint64) ([]*beacon.ExecutableDataV1, error) {
+	if count > maxPayloadsRequest {
+		count = maxPayloadsRequest
+	}
+	req := &payloadsRequest{from: from, count: count, res: make(chan []*beacon.ExecutableDataV1, 1)}
+	id := atomic.AddUint64(&g.reqID, 1)
+
+	g.lock.Lock()
+	var best *peer
+	for _, p := range g.peers {
+		if p.version >= ROLLUP2 && (best == nil || p.Score() > best.Score()) {
+			best = p
+		}
+	}
+	if best == nil {
+		g.lock.Unlock()
+		return nil, errNoPeers
+	}
+	req.peer = best.id
+	g.pending[id] = req
+	g.lock.Unlock()
+
+	defer func() {
+		g.lock.Lock()
+		delete(g.pending, id)
+		g.lock.Unlock()
+	}()
+	if err := p2p.Send(best.rw, GetPayloadsMsg, &GetPayloadsPacket{RequestID: id, From: from, Count: count}); err != nil {
+		return nil, err
+	}
+	select {
+	case payloads := <-req.res:
+		return payloads, nil
+	case <-best.term:
+		return nil, errPeerGone
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close unsubscribes all payload subscribers.
+func (g *Gossip) Close() {
+	g.candidates.Close()
+	g.scope.Close()
+}
+
+// PeerCount returns the number of connected gossip peers.
+func (g *Gossip) PeerCount() int {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return len(g.peers)
+}
+
+// PeerStatus describes a connected gossip peer.
+type PeerStatus struct {
+	ID      enode.ID `json:"id"`
+	Addr    string   `json:"addr"`
+	Version uint     `json:"version"`
+	Score   int      `json:"score"`
+}
+
+// Peers returns the connected gossip peers, the best scored first.
+func (g *Gossip) Peers() []PeerStatus {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+
+	peers := make([]PeerStatus, 0, len(g.peers))
+	for _, p := range g.peers {
+		peers = append(peers, PeerStatus{ID: p.id, Addr: p.p.RemoteAddr().String(), Version: p.version, Score: p.Score()})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Score > peers[j].Score })
+	return peers
+}
+
+// BlockPeer bans a peer for the given duration and disconnects it.
+func (g *Gossip) BlockPeer(id enode.ID, d time.Duration) {
+	g.bans.banPeer(id, d)
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if p, ok := g.peers[id]; ok {
+		p.p.Disconnect(p2p.DiscUselessPeer)
+	}
+}
+
+// BlockIP bans an IP address for the given duration and disconnects its peers.
+func (g *Gossip) BlockIP(ip net.IP, d time.Duration) {
+	g.bans.banIP(ip, d)
+
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	for _, p := range g.peers {
+		if remoteIP(p.p.RemoteAddr()).Equal(ip) {
+			p.p.Disconnect(p2p.DiscUselessPeer)
+		}
+	}
+}
+
+// UnblockPeer lifts the ban of a peer. It returns whether the peer was banned.
+func (g *Gossip) UnblockPeer(id enode.ID) bool {
+	return g.bans.unbanPeer(id)
+}
+
+// UnblockIP lifts the ban of an IP address. It returns whether it was banned.
+func (g *Gossip) UnblockIP(ip net.IP) bool {
+	return g.bans.unbanIP(ip)
+}
+
+// Bans returns the bans in effect.
+func (g *Gossip) Bans() []Ban {
+	return g.bans.list()
+}
+
+// broadcast queues the payload for sending to all peers except the origin.
+func (g *Gossip) broadcast(payload *SignedPayload, origin enode.ID) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	for id, p := range g.peers {
+		if id == origin {
+			continue
+		}
+		select {
+		case p.queue <- payload:
+		default:
+			p.log.Debug("Dropping payload broadcast, peer queue full")
+		}
+	}
+}
+
+func (g *Gossip) runPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	if g.bans.banned(p.ID(), remoteIP(p.RemoteAddr())) {
+		return errBanned
+	}
+	peer := newPeer(version, p, rw, g.log)
+	g.lock.Lock()
+	if _, ok := g.peers[peer.id]; ok {
+		g.lock.Unlock()
+		return p2p.DiscAlreadyConnected
+	}
+	g.peers[peer.id] = peer
+	g.lock.Unlock()
+
+	peer.log.Debug("Payload gossip peer connected")
+	go peer.sendLoop()
+	defer func() {
+		g.lock.Lock()
+		delete(g.peers, peer.id)
+		g.lock.Unlock()
+		peer.close()
+		peer.log.Debug("Payload gossip peer disconnected")
+	}()
+	for {
+		if err := g.handleMsg(peer); err != nil {
+			return err
+		}
+	}
+}
+
+// handleMsg reads and processes a single message from the peer.
+func (g *Gossip) handleMsg(peer *peer) error {
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	if !peer.limiter.Allow() {
+		return g.penalize(peer, penaltySpam, errSpam)
+	}
+	switch {
+	case msg.Code == PayloadMsg:
+	case msg.Code == GetPayloadsMsg && peer.version >= ROLLUP2:
+		return g.handleGetPayloads(peer, msg)
+	case msg.Code == PayloadsMsg && peer.version >= ROLLUP2:
+		return g.handlePayloads(peer, msg)
+	default:
+		return fmt.Errorf("%w: %v", errInvalidMsgCode, msg.Code)
+	}
+	var signed SignedPayload
+	if err := msg.Decode(&signed); err != nil {
+		return g.penalize(peer, penaltyUndecodable, fmt.Errorf("%w: %v", errDecode, err))
+	}
+	payload, err := signed.Decode()
+	if err != nil {
+		return g.penalize(peer, penaltyUndecodable, err)
+	}
+	if g.seen.Contains(payload.BlockHash) {
+		return nil
+	}
+	now, timestamp := g.now(), time.Unix(int64(payload.Timestamp), 0)
+	if timestamp.After(now.Add(maxPayloadFuture)) {
+		return g.penalize(peer, penaltyInvalidPayload, fmt.Errorf("%w: timestamp %d", errFuturePayload, payload.Timestamp))
+	}
+	if timestamp.Before(now.Add(-maxPayloadAge)) {
+		return g.penalize(peer, penaltyStalePayload, fmt.Errorf("%w: timestamp %d", errStalePayload, payload.Timestamp))
+	}
+	if err := g.checkSigner(&signed); err != nil {
+		return g.penalize(peer, penaltyInvalidPayload, err)
+	}
+	g.seen.Add(payload.BlockHash, struct{}{})
+	g.recent.Add(payload.Number, &signed)
+	peer.reward(scoreValidPayload)
+	peer.log.Trace("Received unsafe payload", "number", payload.Number, "hash", payload.BlockHash)
+
+	g.broadcast(&signed, peer.id)
+	g.feed.Send(payload)
+	return nil
+}
+
+// handleGetPayloads serves a request for payloads from the recent payloads.
+func (g *Gossip) handleGetPayloads(peer *peer, msg p2p.Msg) error {
+	var req GetPayloadsPacket
+	if err := msg.Decode(&req); err != nil {
+		return g.penalize(peer, penaltyUndecodable, fmt.Errorf("%w: %v", errDecode, err))
+	}
+	if req.Count > maxPayloadsRequest {
+		req.Count = maxPayloadsRequest
+	}
+	res := &PayloadsPacket{RequestID: req.RequestID}
+	size := 0
+	for n := req.From; n < req.From+req.Count && size < maxResponseSize; n++ {
+		signed, ok := g.recent.Get(n)
+		if !ok {
+			break
+		}
+		res.Payloads = append(res.Payloads, signed.(*SignedPayload))
+		size += len(signed.(*SignedPayload).Payload)
+	}
+	peer.log.Trace("Serving payloads", "from", req.From, "count", req.Count, "served", len(res.Payloads))
+	return p2p.Send(peer.rw, PayloadsMsg, res)
+}
+
+// handlePayloads delivers the response to a request for payloads, after
+// checking that it is signed by the sequencer and matches the request.
+func (g *Gossip) handlePayloads(peer *peer, msg p2p.Msg) error {
+	var res PayloadsPacket
+	if err := msg.Decode(&res); err != nil {
+		return g.penalize(peer, penaltyUndecodable, fmt.Errorf("%w: %v", errDecode, err))
+	}
+	g.lock.RLock()
+	req := g.pending[res.RequestID]
+	g.lock.RUnlock()
+	if req == nil || req.peer != peer.id {
+		return g.penalize(peer, penaltyInvalidPayload, fmt.Errorf("%w: unsolicited response %d", errInvalidResponse, res.RequestID))
+	}
+	if uint64(len(res.Payloads)) > req.count {
+		return g.penalize(peer, penaltyInvalidPayload, fmt.Errorf("%w: %d payloads, requested %d", errInvalidResponse, len(res.Payloads), req.count))
+	}
+	payloads := make([]*beacon.ExecutableDataV1, len(res.Payloads))
+	for i, signed := range res.Payloads {
+		payload, err := signed.Decode()
+		if err != nil {
+			return g.penalize(peer, penaltyUndecodable, err)
+		}
+		if payload.Number != req.from+uint64(i) {
+			return g.penalize(peer, penaltyInvalidPayload, fmt.Errorf("%w: payload %d at position %d, requested from %d", errInvalidResponse, payload.Number, i, req.from))
+		}
+		if err := g.checkSigner(signed); err != nil {
+			return g.penalize(peer, penaltyInvalidPayload, err)
+		}
+		payloads[i] = payload
+	}
+	for i, payload := range payloads {
+		g.seen.Add(payload.BlockHash, struct{}{})
+		g.recent.Add(payload.Number, res.Payloads[i])
+	}
+	select {
+	case req.res <- payloads:
+	default:
+		// Duplicate response, the request was answered already.
+	}
+	return nil
+}
+
+// SetSigners sets the source of the keys that are authorized to sign payloads,
+// which take precedence over the configured sequencer address.
+func (g *Gossip) SetSigners(signers SignerSet) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.signers = signers
+}
+
+// checkSigner checks that a payload is signed by an authorized key: one of the
+// keys of the signer set, or the sequencer address if the set has not been
+// updated yet.
+func (g *Gossip) checkSigner(signed *SignedPayload) error {
+	signer, err := signed.Signer(g.cfg.ChainID)
+	if err != nil {
+		return err
+	}
+	g.lock.RLock()
+	signers := g.signers
+	g.lock.RUnlock()
+
+	var authorized []common.Address
+	if signers != nil {
+		authorized = signers.UnsafeBlockSigners()
+	}
+	if authorized == nil {
+		authorized = []common.Address{g.cfg.SequencerAddress}
+	}
+	for _, addr := range authorized {
+		if signer == addr {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signed by %s", errInvalidSignature, signer)
+}
+
+// penalize lowers the score of a peer for sending an invalid message. Peers whose
+// score drops below the disconnect threshold are banned, the returned error
+// disconnects them.
+func (g *Gossip) penalize(peer *peer, amount int, reason error) error {
+	err := peer.penalize(amount, reason)
+	if err != nil {
+		peer.log.Debug("Banning peer", "duration", banDuration, "err", err)
+		g.bans.banPeer(peer.id, banDuration)
+	}
+	return err
+}
+
+// PeerInfo is the gossip related metadata of a peer.
+type PeerInfo struct {
+	Score int `json:"score"`
+}
+
+func (g *Gossip) peerInfo(id enode.ID) interface{} {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if p, ok := g.peers[id]; ok {
+		return &PeerInfo{Score: p.Score()}
+	}
+	return nil
+}
+
+// peer is a connected gossip peer.
+type peer struct {
+	id      enode.ID
+	version uint
+	p       *p2p.Peer
+	rw      p2p.MsgReadWriter
+	log     log.Logger
+	limiter *rate.Limiter
+
+	lock  sync.Mutex
+	score int
+
+	queue chan *SignedPayload
+	term  chan struct{}
+}
+
+func newPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, logger log.Logger) *peer {
+	return &peer{
+		id:      p.ID(),
+		version: version,
+		p:       p,
+		rw:      rw,
+		log:     logger.New("peer", p.ID()),
+		limiter: rate.NewLimiter(peerMsgRate, peerMsgBurst),
+		queue:   make(chan *SignedPayload, peerQueueSize),
+		term:    make(chan struct{}),
+	}
+}
+
+// sendLoop sends the queued payloads to the peer until it disconnects.
+func (p *peer) sendLoop() {
+	for {
+		select {
+		case payload := <-p.queue:
+			if err := p2p.Send(p.rw, PayloadMsg, payload); err != nil {
+				p.log.Debug("Failed to send payload", "err", err)
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+func (p *peer) close() {
+	close(p.term)
+}
+
+// Score returns the current score of the peer.
+func (p *peer) Score() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.score
+}
+
+func (p *peer) reward(amount int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.score += amount; p.score > scoreMax {
+		p.score = scoreMax
+	}
+}
+
+// penalize lowers the score of the peer for sending an invalid message. It
+// returns an error if the peer should be disconnected.
+func (p *peer) penalize(amount int, reason error) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.score -= amount
+	p.log.Debug("Penalizing peer", "score", p.score, "reason", reason)
+	if p.score < disconnectThreshold {
+		return fmt.Errorf("%w: %v", errPeerScore, reason)
+	}
+	return nil
+}
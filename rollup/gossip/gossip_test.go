@@ -0,0 +1,359 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var (
+	testChainID         = big.NewInt(901)
+	testSequencerKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testOtherKey, _     = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+)
+
+func testPayload(number uint64) *beacon.ExecutableDataV1 {
+	return &beacon.ExecutableDataV1{
+		ParentHash:    common.HexToHash("0x01"),
+		LogsBloom:     make([]byte, 256),
+		Number:        number,
+		GasLimit:      30_000_000,
+		Timestamp:     uint64(time.Now().Unix()),
+		BaseFeePerGas: big.NewInt(7),
+		BlockHash:     common.BigToHash(new(big.Int).SetUint64(number)),
+		Transactions:  [][]byte{{0x7e, 0x01}},
+	}
+}
+
+// connect runs the gossip protocol between two instances over a message pipe.
+// It returns a channel that receives the exit error of b's handler for a.
+func connect(a, b *Gossip) chan error {
+	return connectVersion(a, b, ROLLUP2)
+}
+
+// connectVersion connects two instances with the given protocol version.
+func connectVersion(a, b *Gossip, version uint) chan error {
+	rwA, rwB := p2p.MsgPipe()
+	var idA, idB enode.ID
+	idA[0], idB[0] = 1, 2
+	peerA := p2p.NewPeerPipe(idA, "a", nil, rwB)
+	peerB := p2p.NewPeerPipe(idB, "b", nil, rwA)
+
+	errc := make(chan error, 1)
+	go a.runPeer(version, peerB, rwA)
+	go func() { errc <- b.runPeer(version, peerA, rwB) }()
+	for a.PeerCount() == 0 || b.PeerCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return errc
+}
+
+func TestSigningHash(t *testing.T) {
+	signed, err := SignPayload(testSequencerKey, testChainID, testPayload(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signed.Signer(testChainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer != crypto.PubkeyToAddress(testSequencerKey.PublicKey) {
+		t.Fatalf("wrong signer %s", signer)
+	}
+	// A signature for another chain recovers a different signer.
+	if other, _ := signed.Signer(big.NewInt(902)); other == signer {
+		t.Fatal("signature valid on another chain")
+	}
+	payload, err := signed.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.BlockHash != testPayload(1).BlockHash {
+		t.Fatal("payload mismatch after decoding")
+	}
+}
+
+func TestGossipPayload(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	verifierCfg := cfg
+	cfg.SequencerKey = testSequencerKey
+
+	sequencer := New(cfg, log.New())
+	verifier := New(verifierCfg, log.New())
+	connect(sequencer, verifier)
+
+	ch := make(chan *beacon.ExecutableDataV1, 1)
+	sub := verifier.SubscribePayloads(ch)
+	defer sub.Unsubscribe()
+
+	if err := verifier.Publish(testPayload(1)); err == nil {
+		t.Fatal("published without sequencer key")
+	}
+	if err := sequencer.Publish(testPayload(1)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case payload := <-ch:
+		if payload.BlockHash != testPayload(1).BlockHash {
+			t.Fatalf("received wrong payload %s", payload.BlockHash)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("payload not received")
+	}
+}
+
+func TestGossipRejectsInvalidSigner(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	attackerCfg := cfg
+	attackerCfg.SequencerKey = testOtherKey
+
+	attacker := New(attackerCfg, log.New())
+	verifier := New(cfg, log.New())
+	errc := connect(attacker, verifier)
+
+	ch := make(chan *beacon.ExecutableDataV1, 16)
+	sub := verifier.SubscribePayloads(ch)
+	defer sub.Unsubscribe()
+
+	// Every invalid payload lowers the score of the attacker until it is
+	// disconnected.
+	for i := uint64(1); i <= 10; i++ {
+		attacker.Publish(testPayload(i))
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errPeerScore) {
+			t.Fatalf("peer dropped with wrong error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("attacker not disconnected")
+	}
+	if len(ch) != 0 {
+		t.Fatal("payload with invalid signature delivered")
+	}
+	// The attacker is banned from reconnecting.
+	var id enode.ID
+	id[0] = 1
+	_, rw := p2p.MsgPipe()
+	if err := verifier.runPeer(ROLLUP2, p2p.NewPeer(id, "a", nil), rw); !errors.Is(err, errBanned) {
+		t.Fatalf("banned peer connected: %v", err)
+	}
+}
+
+// staticSigners is a signer set that does not change.
+type staticSigners []common.Address
+
+func (s staticSigners) UnsafeBlockSigners() []common.Address { return s }
+
+func TestGossipSignerSet(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	verifierCfg := cfg
+	cfg.SequencerKey = testSequencerKey
+
+	sequencer := New(cfg, log.New())
+	verifier := New(verifierCfg, log.New())
+	connect(sequencer, verifier)
+	publish := func(key *ecdsa.PrivateKey, number uint64) {
+		sequencer.cfg.SequencerKey = key
+		if err := sequencer.Publish(testPayload(number)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch := make(chan *beacon.ExecutableDataV1, 16)
+	sub := verifier.SubscribePayloads(ch)
+	defer sub.Unsubscribe()
+
+	expect := func(number uint64, delivered bool) {
+		t.Helper()
+		select {
+		case payload := <-ch:
+			if !delivered || uint64(payload.Number) != number {
+				t.Fatalf("payload %d delivered, want %d delivered %t", payload.Number, number, delivered)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if delivered {
+				t.Fatalf("payload %d not delivered", number)
+			}
+		}
+	}
+	// Before the signers are updated, the sequencer address applies.
+	verifier.SetSigners(staticSigners(nil))
+	publish(testSequencerKey, 1)
+	expect(1, true)
+	publish(testOtherKey, 2)
+	expect(2, false)
+
+	// The new key is authorized, the sequencer address no longer applies.
+	verifier.SetSigners(staticSigners{crypto.PubkeyToAddress(testOtherKey.PublicKey)})
+	publish(testOtherKey, 3)
+	expect(3, true)
+	publish(testSequencerKey, 4)
+	expect(4, false)
+
+	// Once all signers are removed, no key is authorized.
+	verifier.SetSigners(staticSigners{})
+	publish(testSequencerKey, 5)
+	expect(5, false)
+	publish(testOtherKey, 6)
+	expect(6, false)
+}
+
+func TestGossipRejectsPayloadTimestamps(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	verifierCfg := cfg
+	cfg.SequencerKey = testSequencerKey
+
+	sequencer := New(cfg, log.New())
+	verifier := New(verifierCfg, log.New())
+	connect(sequencer, verifier)
+
+	ch := make(chan *beacon.ExecutableDataV1, 16)
+	sub := verifier.SubscribePayloads(ch)
+	defer sub.Unsubscribe()
+
+	stale, future := testPayload(1), testPayload(2)
+	stale.Timestamp -= uint64((maxPayloadAge + time.Minute) / time.Second)
+	future.Timestamp += uint64((maxPayloadFuture + time.Minute) / time.Second)
+	sequencer.Publish(stale)
+	sequencer.Publish(future)
+	sequencer.Publish(testPayload(3))
+	select {
+	case payload := <-ch:
+		if payload.Number != 3 {
+			t.Fatalf("payload %d with invalid timestamp delivered", payload.Number)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("payload not received")
+	}
+	peers := verifier.Peers()
+	if want := -penaltyStalePayload - penaltyInvalidPayload + scoreValidPayload; len(peers) != 1 || peers[0].Score != want {
+		t.Fatalf("peer scores %+v, want a single peer with score %d", peers, want)
+	}
+}
+
+func TestGossipRateLimit(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	verifier := New(cfg, log.New())
+
+	rw, rwVerifier := p2p.MsgPipe()
+	var id enode.ID
+	id[0] = 1
+	errc := make(chan error, 1)
+	go func() {
+		errc <- verifier.runPeer(ROLLUP2, p2p.NewPeer(id, "spammer", nil), rwVerifier)
+		rwVerifier.Close()
+	}()
+	// Drain the responses of the verifier.
+	go func() {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return
+			}
+			msg.Discard()
+		}
+	}()
+	// Requests beyond the burst are penalized until the spammer is dropped.
+	for i := 0; ; i++ {
+		if err := p2p.Send(rw, GetPayloadsMsg, &GetPayloadsPacket{RequestID: uint64(i), From: 1, Count: 1}); err != nil {
+			break
+		}
+	}
+	if err := <-errc; !errors.Is(err, errPeerScore) {
+		t.Fatalf("spammer dropped with wrong error: %v", err)
+	}
+	if bans := verifier.Bans(); len(bans) != 1 || bans[0].Peer == nil || *bans[0].Peer != id {
+		t.Fatalf("spammer not banned: %+v", bans)
+	}
+}
+
+func TestGossipRequestPayloads(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	verifierCfg := cfg
+	cfg.SequencerKey = testSequencerKey
+
+	sequencer := New(cfg, log.New())
+	for i := uint64(1); i <= 5; i++ {
+		if err := sequencer.Publish(testPayload(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verifier := New(verifierCfg, log.New())
+	connect(sequencer, verifier)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// The response ends at the last payload the peer has.
+	payloads, err := verifier.PayloadsByNumber(ctx, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 3 {
+		t.Fatalf("received %d payloads, want 3", len(payloads))
+	}
+	for i, payload := range payloads {
+		if payload.BlockHash != testPayload(uint64(3+i)).BlockHash {
+			t.Fatalf("payload %d: wrong hash %s", i, payload.BlockHash)
+		}
+	}
+	// The verifier serves the received payloads in turn.
+	other := New(verifierCfg, log.New())
+	connect(verifier, other)
+	if payloads, err := other.PayloadsByNumber(ctx, 3, 1); err != nil || len(payloads) != 1 {
+		t.Fatalf("received payloads not served: %d payloads, %v", len(payloads), err)
+	}
+}
+
+func TestGossipRequestPayloadsInvalidSigner(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	attackerCfg := cfg
+	attackerCfg.SequencerKey = testOtherKey
+
+	attacker := New(attackerCfg, log.New())
+	attacker.Publish(testPayload(1))
+	verifier := New(cfg, log.New())
+	connect(attacker, verifier)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if payloads, err := verifier.PayloadsByNumber(ctx, 1, 1); err == nil {
+		t.Fatalf("accepted %d payloads with invalid signature", len(payloads))
+	}
+}
+
+func TestGossipRequestPayloadsOldPeer(t *testing.T) {
+	cfg := Config{ChainID: testChainID, SequencerAddress: crypto.PubkeyToAddress(testSequencerKey.PublicKey)}
+	verifier := New(cfg, log.New())
+	connectVersion(New(cfg, log.New()), verifier, ROLLUP1)
+
+	if _, err := verifier.PayloadsByNumber(context.Background(), 1, 1); !errors.Is(err, errNoPeers) {
+		t.Fatalf("requested payloads from rollup/1 peer: %v", err)
+	}
+}
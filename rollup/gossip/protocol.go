@@ -0,0 +1,136 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gossip
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	ROLLUP1 = 1
+	ROLLUP2 = 2
+)
+
+// ProtocolName is the short name of the payload gossip protocol used during
+// devp2p capability negotiation.
+const ProtocolName = "rollup"
+
+// ProtocolVersions are the supported versions of the gossip protocol (first is
+// primary).
+var ProtocolVersions = []uint{ROLLUP2, ROLLUP1}
+
+// protocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var protocolLengths = map[uint]uint64{ROLLUP1: 1, ROLLUP2: 3}
+
+// maxMessageSize is the maximum cap on the size of a protocol message.
+const maxMessageSize = 10 * 1024 * 1024
+
+const (
+	PayloadMsg = 0x00
+
+	// Protocol messages introduced in rollup/2
+	GetPayloadsMsg = 0x01
+	PayloadsMsg    = 0x02
+)
+
+var (
+	errMsgTooLarge      = errors.New("message too long")
+	errDecode           = errors.New("invalid message")
+	errInvalidMsgCode   = errors.New("invalid message code")
+	errInvalidSignature = errors.New("invalid payload signature")
+	errInvalidResponse  = errors.New("invalid payloads response")
+)
+
+// GetPayloadsPacket requests the payloads with consecutive numbers, which peers
+// serve from the payloads they received recently.
+type GetPayloadsPacket struct {
+	RequestID uint64
+	From      uint64
+	Count     uint64
+}
+
+// PayloadsPacket is the response to GetPayloadsPacket. It contains the signed
+// payloads from the requested number on, up to the first one the peer does not
+// have.
+type PayloadsPacket struct {
+	RequestID uint64
+	Payloads  []*SignedPayload
+}
+
+// SignedPayload is an execution payload, signed by the sequencer that built it.
+type SignedPayload struct {
+	Signature []byte // 65 byte [R || S || V] signature over the signing hash
+	Payload   []byte // RLP encoded execution payload
+}
+
+// SigningHash returns the hash that the sequencer signs to authenticate an
+// encoded payload. It commits to the L2 chain ID, so that signatures cannot be
+// replayed on other chains:
+//
+//	keccak256(bytes32(0) ++ bytes32(chainID) ++ keccak256(payload))
+func SigningHash(chainID *big.Int, payload []byte) common.Hash {
+	var input [96]byte
+	// The first word is the signature domain, reserved for other kinds of
+	// signed messages.
+	chainID.FillBytes(input[32:64])
+	copy(input[64:], crypto.Keccak256(payload))
+	return crypto.Keccak256Hash(input[:])
+}
+
+// SignPayload encodes and signs the given payload.
+func SignPayload(key *ecdsa.PrivateKey, chainID *big.Int, payload *beacon.ExecutableDataV1) (*SignedPayload, error) {
+	enc, err := rlp.EncodeToBytes(payload)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(SigningHash(chainID, enc).Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedPayload{Signature: sig, Payload: enc}, nil
+}
+
+// Signer recovers the address that signed the payload.
+func (p *SignedPayload) Signer(chainID *big.Int) (common.Address, error) {
+	if len(p.Signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: length %d", errInvalidSignature, len(p.Signature))
+	}
+	pub, err := crypto.SigToPub(SigningHash(chainID, p.Payload).Bytes(), p.Signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", errInvalidSignature, err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Decode decodes the signed execution payload.
+func (p *SignedPayload) Decode() (*beacon.ExecutableDataV1, error) {
+	var payload beacon.ExecutableDataV1
+	if err := rlp.DecodeBytes(p.Payload, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecode, err)
+	}
+	return &payload, nil
+}
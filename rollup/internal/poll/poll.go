@@ -0,0 +1,58 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package poll implements the polling loop of the rollup services that act on
+// L1 and the rollup node.
+package poll
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Loop runs step every interval until quit is closed. While step fails, the
+// delay doubles up to maxRetry, and the failures are logged with the given
+// message. The context of step is canceled when quit is closed.
+func Loop(quit <-chan struct{}, interval, maxRetry time.Duration, step func(context.Context) error, logger log.Logger, msg string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-quit
+		cancel()
+	}()
+	delay := interval
+	for {
+		if err := step(ctx); err != nil && ctx.Err() == nil {
+			// Back off while L1 or the rollup node are having trouble.
+			delay *= 2
+			if delay > maxRetry {
+				delay = maxRetry
+			}
+			logger.Error(msg, "err", err, "retry", delay)
+		} else {
+			delay = interval
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-quit:
+			timer.Stop()
+			return
+		}
+	}
+}
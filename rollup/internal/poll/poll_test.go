@@ -0,0 +1,60 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package poll
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func TestLoop(t *testing.T) {
+	var (
+		quit  = make(chan struct{})
+		done  = make(chan struct{})
+		steps = make(chan struct{}, 10)
+		calls int
+	)
+	step := func(ctx context.Context) error {
+		calls++
+		if calls == 4 {
+			close(quit)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		steps <- struct{}{}
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}
+	go func() {
+		Loop(quit, time.Millisecond, 2*time.Millisecond, step, log.New(), "Step failed")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("loop did not stop")
+	}
+	if len(steps) != 3 {
+		t.Fatalf("%d steps before quitting, want 3", len(steps))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package testutils
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Engine is a minimal execution engine that builds blocks out of the forced
+// transactions of the payload attributes and the transactions of its pool,
+// without executing them.
+type Engine struct {
+	Blocks     map[common.Hash]*types.Block
+	Forkchoice beacon.ForkchoiceStateV1
+	TxPool     []*types.Transaction // included when the attributes allow it
+
+	payloads map[beacon.PayloadID]*types.Block
+	nextID   uint64
+}
+
+// NewEngine creates an engine whose chain starts at the given genesis block.
+func NewEngine(genesis *types.Block) *Engine {
+	return &Engine{
+		Blocks:     map[common.Hash]*types.Block{genesis.Hash(): genesis},
+		Forkchoice: beacon.ForkchoiceStateV1{HeadBlockHash: genesis.Hash()},
+		payloads:   make(map[beacon.PayloadID]*types.Block),
+	}
+}
+
+// Head returns the current head block.
+func (e *Engine) Head() *types.Block {
+	return e.Blocks[e.Forkchoice.HeadBlockHash]
+}
+
+func (e *Engine) ForkchoiceUpdate(ctx context.Context, state *beacon.ForkchoiceStateV1, attr *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	parent, ok := e.Blocks[state.HeadBlockHash]
+	if !ok {
+		return &beacon.STATUS_SYNCING, nil
+	}
+	e.Forkchoice = *state
+	res := &beacon.ForkChoiceResponse{PayloadStatus: beacon.PayloadStatusV1{Status: beacon.VALID}}
+	if attr == nil {
+		return res, nil
+	}
+	txs := make([]*types.Transaction, len(attr.Transactions))
+	for i, enc := range attr.Transactions {
+		txs[i] = new(types.Transaction)
+		if err := txs[i].UnmarshalBinary(enc); err != nil {
+			return nil, err
+		}
+	}
+	if !attr.NoTxPool {
+		txs = append(txs, e.TxPool...)
+		e.TxPool = nil
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		Time:       attr.Timestamp,
+		MixDigest:  attr.Random,
+		Coinbase:   attr.SuggestedFeeRecipient,
+		GasLimit:   30_000_000,
+		BaseFee:    big.NewInt(1),
+		Difficulty: new(big.Int),
+	}
+	if attr.GasLimit != nil {
+		header.GasLimit = *attr.GasLimit
+	}
+	var id beacon.PayloadID
+	binary.BigEndian.PutUint64(id[:], e.nextID)
+	e.nextID++
+	e.payloads[id] = types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+	res.PayloadID = &id
+	return res, nil
+}
+
+func (e *Engine) GetPayload(ctx context.Context, id beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	block, ok := e.payloads[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload %s", id)
+	}
+	return beacon.BlockToExecutableData(block), nil
+}
+
+func (e *Engine) NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error) {
+	block, err := beacon.ExecutableDataToBlock(*payload)
+	if err != nil {
+		msg := err.Error()
+		return &beacon.PayloadStatusV1{Status: beacon.INVALID, ValidationError: &msg}, nil
+	}
+	hash := block.Hash()
+	e.Blocks[hash] = block
+	return &beacon.PayloadStatusV1{Status: beacon.VALID, LatestValidHash: &hash}, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package testutils contains in-memory L1 and L2 fakes for testing the rollup
+// node components.
+package testutils
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// L1BlockTime is the time between two blocks of the fake L1 chain.
+const L1BlockTime = 4
+
+// L1Chain is an in-memory L1 chain.
+type L1Chain struct {
+	blocks []*types.Block
+	byHash map[common.Hash]*types.Block   // includes blocks that were reorged out
+	logs   map[common.Hash][][]*types.Log // logs of the transactions, by block hash
+	forks  uint64
+
+	finalized *types.Block // nil until a block is finalized
+}
+
+// NewL1Chain creates an L1 chain that only contains a genesis block.
+func NewL1Chain() *L1Chain {
+	genesis := types.NewBlockWithHeader(&types.Header{
+		Number:  new(big.Int),
+		Time:    1000,
+		BaseFee: big.NewInt(7),
+	})
+	return &L1Chain{
+		blocks: []*types.Block{genesis},
+		byHash: map[common.Hash]*types.Block{genesis.Hash(): genesis},
+		logs:   make(map[common.Hash][][]*types.Log),
+	}
+}
+
+// Head returns the head block of the chain.
+func (c *L1Chain) Head() *types.Block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+// Block returns the canonical block with the given number.
+func (c *L1Chain) Block(number uint64) *types.Block {
+	return c.blocks[number]
+}
+
+// AddBlock adds a block with the given transactions on top of the chain.
+func (c *L1Chain) AddBlock(txs ...*types.Transaction) *types.Block {
+	return c.AddBlockWithLogs(txs, nil)
+}
+
+// AddBlockWithLogs adds a block with the given transactions on top of the chain.
+// The transactions emit the given logs, which are indexed by transaction.
+func (c *L1Chain) AddBlockWithLogs(txs []*types.Transaction, logs [][]*types.Log) *types.Block {
+	parent := c.Head()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		Time:       parent.Time() + L1BlockTime,
+		BaseFee:    big.NewInt(7),
+		MixDigest:  common.BigToHash(parent.Number()),
+	}
+	if c.forks > 0 {
+		// Make sure the blocks of a new fork differ from the reorged ones.
+		header.Extra = new(big.Int).SetUint64(c.forks).Bytes()
+	}
+	receipts := make(types.Receipts, len(txs))
+	for i := range txs {
+		receipts[i] = &types.Receipt{Type: txs[i].Type(), Status: types.ReceiptStatusSuccessful}
+		if i < len(logs) {
+			receipts[i].Logs = logs[i]
+		}
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+	}
+	header.Bloom = types.CreateBloom(receipts)
+	block := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+	c.blocks = append(c.blocks, block)
+	c.byHash[block.Hash()] = block
+
+	var index uint
+	for i := range logs {
+		for _, l := range logs[i] {
+			l.BlockHash, l.BlockNumber, l.TxHash, l.TxIndex, l.Index = block.Hash(), block.NumberU64(), txs[i].Hash(), uint(i), index
+			index++
+		}
+	}
+	c.logs[block.Hash()] = logs
+	return block
+}
+
+// Reorg removes the given number of blocks from the head of the chain. Blocks
+// added afterwards form a new fork.
+func (c *L1Chain) Reorg(depth int) {
+	c.blocks = c.blocks[:len(c.blocks)-depth]
+	c.forks++
+}
+
+// Finalize marks the canonical block with the given number as finalized.
+func (c *L1Chain) Finalize(number uint64) {
+	c.finalized = c.blocks[number]
+}
+
+func (c *L1Chain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return c.Head().Header(), nil
+	}
+	if number.Int64() == int64(rpc.FinalizedBlockNumber) {
+		if c.finalized == nil {
+			return nil, ethereum.NotFound
+		}
+		return c.finalized.Header(), nil
+	}
+	if !number.IsUint64() || number.Uint64() >= uint64(len(c.blocks)) {
+		return nil, ethereum.NotFound
+	}
+	return c.blocks[number.Uint64()].Header(), nil
+}
+
+// Receipts returns the receipts of the block with the given hash. All
+// transactions succeed.
+func (c *L1Chain) Receipts(ctx context.Context, hash common.Hash) ([]*types.Receipt, error) {
+	b, ok := c.byHash[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	receipts := make([]*types.Receipt, len(b.Transactions()))
+	for i, tx := range b.Transactions() {
+		receipts[i] = &types.Receipt{
+			Type:        tx.Type(),
+			Status:      types.ReceiptStatusSuccessful,
+			TxHash:      tx.Hash(),
+			BlockHash:   hash,
+			BlockNumber: b.Number(),
+		}
+		if i < len(c.logs[hash]) {
+			receipts[i].Logs = c.logs[hash][i]
+		}
+		receipts[i].Bloom = types.CreateBloom(types.Receipts{receipts[i]})
+	}
+	return receipts, nil
+}
+
+func (c *L1Chain) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if b, ok := c.byHash[hash]; ok {
+		return b, nil
+	}
+	return nil, ethereum.NotFound
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// LogComponentKey is the context key that names the component of a logger.
+const LogComponentKey = "component"
+
+// Components of the rollup node and the batcher, whose log levels can be set
+// separately.
+const (
+	LogDriver     = "driver"  // driver loop and unsafe payloads
+	LogDerivation = "derive"  // derivation pipeline
+	LogEngine     = "engine"  // block building on the execution engine
+	LogP2P        = "p2p"     // payload gossip and its p2p server
+	LogBatcher    = "batcher" // batch submission
+)
+
+// LogComponents are the known log components.
+var LogComponents = []string{LogDriver, LogDerivation, LogEngine, LogP2P, LogBatcher}
+
+// ComponentLogger returns a logger of the given component.
+func ComponentLogger(logger log.Logger, component string) log.Logger {
+	return logger.New(LogComponentKey, component)
+}
+
+// ComponentLogHandler filters log records by the level of their component,
+// which can be changed at runtime. Records without a component, or of a
+// component without a level of its own, are filtered by the default level.
+type ComponentLogHandler struct {
+	next log.Handler
+
+	lock   sync.RWMutex
+	lvl    log.Lvl
+	levels map[string]log.Lvl
+}
+
+// NewComponentLogHandler creates a handler that passes the records within the
+// levels to the given handler.
+func NewComponentLogHandler(next log.Handler, lvl log.Lvl) *ComponentLogHandler {
+	return &ComponentLogHandler{next: next, lvl: lvl, levels: make(map[string]log.Lvl)}
+}
+
+// Log implements log.Handler.
+func (h *ComponentLogHandler) Log(r *log.Record) error {
+	if r.Lvl > h.level(recordComponent(r)) {
+		return nil
+	}
+	return h.next.Log(r)
+}
+
+func (h *ComponentLogHandler) level(component string) log.Lvl {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if lvl, ok := h.levels[component]; ok {
+		return lvl
+	}
+	return h.lvl
+}
+
+// recordComponent returns the component of a log record. The last one wins if
+// loggers of several components are nested.
+func recordComponent(r *log.Record) string {
+	var component string
+	for i := 0; i+1 < len(r.Ctx); i += 2 {
+		if key, ok := r.Ctx[i].(string); ok && key == LogComponentKey {
+			if c, ok := r.Ctx[i+1].(string); ok {
+				component = c
+			}
+		}
+	}
+	return component
+}
+
+// SetLevel sets the level of a component, or the default level if the
+// component is empty.
+func (h *ComponentLogHandler) SetLevel(component string, lvl log.Lvl) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if component == "" {
+		h.lvl = lvl
+	} else {
+		h.levels[component] = lvl
+	}
+}
+
+// ResetLevel makes a component use the default level again.
+func (h *ComponentLogHandler) ResetLevel(component string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.levels, component)
+}
+
+// Levels returns the default level, under the empty component, and the levels
+// of the components that have their own.
+func (h *ComponentLogHandler) Levels() map[string]log.Lvl {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	levels := map[string]log.Lvl{"": h.lvl}
+	for component, lvl := range h.levels {
+		levels[component] = lvl
+	}
+	return levels
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+func TestComponentLogHandler(t *testing.T) {
+	var logged []string
+	handler := NewComponentLogHandler(log.FuncHandler(func(r *log.Record) error {
+		logged = append(logged, r.Msg)
+		return nil
+	}), log.LvlInfo)
+	root := log.New()
+	root.SetHandler(handler)
+	var (
+		derive = ComponentLogger(root, LogDerivation)
+		p2p    = ComponentLogger(root, LogP2P)
+	)
+	logAll := func() {
+		logged = nil
+		root.Debug("root debug")
+		root.Info("root info")
+		derive.Trace("derive trace")
+		derive.Info("derive info")
+		p2p.Debug("p2p debug")
+		p2p.Warn("p2p warn")
+	}
+
+	logAll()
+	if want := []string{"root info", "derive info", "p2p warn"}; !reflect.DeepEqual(logged, want) {
+		t.Fatalf("logged %v at the default level, want %v", logged, want)
+	}
+	handler.SetLevel(LogDerivation, log.LvlTrace)
+	handler.SetLevel("", log.LvlWarn)
+	logAll()
+	if want := []string{"derive trace", "derive info", "p2p warn"}; !reflect.DeepEqual(logged, want) {
+		t.Fatalf("logged %v with a component level, want %v", logged, want)
+	}
+	handler.ResetLevel(LogDerivation)
+	logAll()
+	if want := []string{"p2p warn"}; !reflect.DeepEqual(logged, want) {
+		t.Fatalf("logged %v after the reset, want %v", logged, want)
+	}
+}
@@ -0,0 +1,470 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package node contains the services of the rollup node that are exposed to
+// operators, such as its RPC API.
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Driver is the part of the rollup driver that the API reports on and
+// controls.
+type Driver interface {
+	SyncStatus(ctx context.Context) (*rollup.SyncStatus, error)
+	StartSequencer(hash common.Hash) error
+	StopSequencer() (common.Hash, error)
+	SetSequencerDrain(drain bool)
+	ResetDerivationPipeline() error
+	SequencerStatus() rollup.SequencerStatus
+	DerivationTrace() ([]derive.StepTrace, error)
+	DepositsByL1TxHash(hash common.Hash) ([]*derive.IndexedDeposit, error)
+	DepositBySourceHash(hash common.Hash) (*derive.IndexedDeposit, error)
+	SubscribeEvents(ch chan<- rollup.NodeEvent) event.Subscription
+}
+
+// L2Client is the part of the L2 execution engine that blocks and outputs are
+// read from.
+type L2Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	PayloadByNumber(ctx context.Context, number *big.Int) (*beacon.ExecutableDataV1, error)
+	GetProof(ctx context.Context, account common.Address, keys []string, number *big.Int) (*gethclient.AccountResult, error)
+}
+
+// API is the optimism_ RPC namespace of the rollup node.
+type API struct {
+	cfg    *rollup.Config
+	driver Driver
+	l2     L2Client
+}
+
+// NewAPI creates the optimism_ API.
+func NewAPI(cfg *rollup.Config, driver Driver, l2 L2Client) *API {
+	return &API{cfg: cfg, driver: driver, l2: l2}
+}
+
+// APIs returns the RPC APIs of the rollup node.
+func APIs(cfg *rollup.Config, driver Driver, l2 L2Client) []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "optimism",
+			Service:   NewAPI(cfg, driver, l2),
+		},
+		{
+			Namespace: "admin",
+			Service:   NewAdminAPI(driver),
+		},
+	}
+}
+
+// SyncStatus reports the L1 head and the unsafe, safe and finalized L2 heads,
+// which tell how far the node is behind.
+func (api *API) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	return api.driver.SyncStatus(ctx)
+}
+
+// SequencerActive reports whether the node sequences blocks.
+func (api *API) SequencerActive() bool {
+	return api.driver.SequencerStatus().Active
+}
+
+// SequencerStatus reports whether the node sequences blocks and how long ago it
+// built the last one, for a coordinator to tell whether the sequencer is live.
+func (api *API) SequencerStatus() rollup.SequencerStatus {
+	return api.driver.SequencerStatus()
+}
+
+// DerivationTrace returns the most recent steps of the derivation pipeline,
+// oldest first, to diagnose a node whose derivation stalls. It fails unless the
+// node was started with derivation tracing enabled.
+func (api *API) DerivationTrace() ([]derive.StepTrace, error) {
+	return api.driver.DerivationTrace()
+}
+
+// DepositByL1Hash returns the deposits made by the L1 transaction with the given
+// hash, along with the L2 blocks and transactions that include them. Deposits
+// of blocks that the node did not derive yet are not returned. It fails unless
+// the node was started with the deposit index enabled.
+func (api *API) DepositByL1Hash(hash common.Hash) ([]*derive.IndexedDeposit, error) {
+	deps, err := api.driver.DepositsByL1TxHash(hash)
+	if deps == nil && err == nil {
+		deps = []*derive.IndexedDeposit{}
+	}
+	return deps, err
+}
+
+// DepositBySourceHash returns the deposit with the given source hash, which is
+// the hash that identifies it in its L2 transaction, or nil if it is not
+// indexed.
+func (api *API) DepositBySourceHash(hash common.Hash) (*derive.IndexedDeposit, error) {
+	return api.driver.DepositBySourceHash(hash)
+}
+
+// UnsafeHead streams the new unsafe heads of the node to the subscribers of
+// optimism_subscribe("unsafeHead"), as node events.
+func (api *API) UnsafeHead(ctx context.Context) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, rollup.EventUnsafeHead)
+}
+
+// SafeHead streams the new safe heads of the node, like UnsafeHead.
+func (api *API) SafeHead(ctx context.Context) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, rollup.EventSafeHead)
+}
+
+// FinalizedHead streams the new finalized heads of the node, like UnsafeHead.
+func (api *API) FinalizedHead(ctx context.Context) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, rollup.EventFinalizedHead)
+}
+
+// DerivationError streams the failed derivation steps of the node, along with
+// how the node recovers from them, like UnsafeHead.
+func (api *API) DerivationError(ctx context.Context) (*rpc.Subscription, error) {
+	return api.subscribe(ctx, rollup.EventDerivationError)
+}
+
+// subscribe creates a subscription to the node events of the given kind.
+func (api *API) subscribe(ctx context.Context, kind string) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	var (
+		rpcSub = notifier.CreateSubscription()
+		events = make(chan rollup.NodeEvent, 128)
+		sub    = api.driver.SubscribeEvents(events)
+	)
+	go func() {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case ev := <-events:
+				if ev.Kind == kind {
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-sub.Err():
+				// The driver stopped.
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
+// RollupConfig returns the rollup configuration of the node.
+func (api *API) RollupConfig() *rollup.Config {
+	return api.cfg
+}
+
+// BlockByNumber returns the L2 block with the given number, or the unsafe, safe
+// or finalized head for the latest, safe and finalized tags. The deposits of the
+// block are separated from the user transactions, and the L1 info deposit is
+// decoded, so that the block can be indexed without inspecting transactions.
+func (api *API) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*rollup.L2Block, error) {
+	var num *big.Int
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber, rpc.SafeBlockNumber, rpc.FinalizedBlockNumber:
+		status, err := api.driver.SyncStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		head := status.UnsafeL2
+		if number == rpc.SafeBlockNumber {
+			head = status.SafeL2
+		} else if number == rpc.FinalizedBlockNumber {
+			head = status.FinalizedL2
+		}
+		num = new(big.Int).SetUint64(head.Number)
+	default:
+		if number < 0 {
+			return nil, fmt.Errorf("invalid block number %d", number)
+		}
+		num = big.NewInt(number.Int64())
+	}
+	payload, err := api.l2.PayloadByNumber(ctx, num)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 block %d: %w", num, err)
+	}
+	ref, err := derive.L2BlockRefFromPayload(api.cfg, payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid L2 block %d: %w", num, err)
+	}
+	block := &rollup.L2Block{
+		L2BlockRef:   ref,
+		Deposits:     []*types.Transaction{},
+		Transactions: []*types.Transaction{},
+	}
+	for i, enc := range payload.Transactions {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(enc); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d of L2 block %d: %w", i, num, err)
+		}
+		if tx.Type() != types.DepositTxType {
+			block.Transactions = append(block.Transactions, tx)
+			continue
+		}
+		if len(block.Transactions) > 0 {
+			return nil, fmt.Errorf("deposit %d of L2 block %d after user transactions", i, num)
+		}
+		block.Deposits = append(block.Deposits, tx)
+	}
+	// Blocks other than the genesis block start with the L1 info deposit,
+	// which was decoded for the reference already.
+	if len(block.Deposits) > 0 {
+		var info types.L1BlockInfo
+		if err := info.UnmarshalBinary(block.Deposits[0].Data()); err != nil {
+			return nil, fmt.Errorf("invalid L1 info deposit of L2 block %d: %w", num, err)
+		}
+		block.L1OriginTime = info.Time
+		block.L1BaseFee = (*hexutil.Big)(info.BaseFee)
+	}
+	return block, nil
+}
+
+// OutputAtBlock returns the output of the L2 block with the given number, which
+// is proposed on L1 to prove withdrawals against.
+func (api *API) OutputAtBlock(ctx context.Context, number hexutil.Uint64) (*rollup.Output, error) {
+	res, err := api.outputProof(ctx, uint64(number), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &res.Output, nil
+}
+
+// OutputProofAtBlock returns the output of the L2 block with the given number,
+// along with the proof of the message passer account and the proofs of the
+// given storage slots of the message passer. Withdrawals are proven on L1 with
+// these proofs.
+func (api *API) OutputProofAtBlock(ctx context.Context, number hexutil.Uint64, keys []common.Hash) (*rollup.OutputProof, error) {
+	return api.outputProof(ctx, uint64(number), keys)
+}
+
+func (api *API) outputProof(ctx context.Context, number uint64, keys []common.Hash) (*rollup.OutputProof, error) {
+	num := new(big.Int).SetUint64(number)
+	header, err := api.l2.HeaderByNumber(ctx, num)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch L2 block %d: %w", number, err)
+	}
+	hexKeys := make([]string, len(keys))
+	for i, key := range keys {
+		hexKeys[i] = key.Hex()
+	}
+	res, err := api.l2.GetProof(ctx, rollup.L2ToL1MessagePasserAddr, hexKeys, num)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch withdrawal storage proof at L2 block %d: %w", number, err)
+	}
+	// The engine is trusted, but an output is expensive to get wrong, as it
+	// is committed to L1.
+	proof, err := verifyProof(header.Root, rollup.L2ToL1MessagePasserAddr, keys, res)
+	if err != nil {
+		return nil, fmt.Errorf("invalid withdrawal storage proof at L2 block %d: %w", number, err)
+	}
+	proof.Output = *rollup.NewOutputV0(header.Root, res.StorageHash, header.Hash())
+	return proof, nil
+}
+
+// verifyProof checks that the account proof of the result matches the given
+// state root and the storage root of the result, and that the storage proofs
+// of the given keys match the storage root. It returns the decoded proofs.
+func verifyProof(stateRoot common.Hash, addr common.Address, keys []common.Hash, res *gethclient.AccountResult) (*rollup.OutputProof, error) {
+	proof := new(rollup.OutputProof)
+	accountProof, err := decodeProof(res.AccountProof)
+	if err != nil {
+		return nil, err
+	}
+	val, err := trie.VerifyProof(stateRoot, crypto.Keccak256(addr[:]), proofDB(accountProof))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, errors.New("account does not exist")
+	}
+	var account types.StateAccount
+	if err := rlp.DecodeBytes(val, &account); err != nil {
+		return nil, err
+	}
+	if account.Root != res.StorageHash {
+		return nil, fmt.Errorf("storage root mismatch: proof has %s, result has %s", account.Root, res.StorageHash)
+	}
+	proof.AccountProof = accountProof
+
+	if len(res.StorageProof) != len(keys) {
+		return nil, fmt.Errorf("got %d storage proofs for %d keys", len(res.StorageProof), len(keys))
+	}
+	for i, sp := range res.StorageProof {
+		if common.HexToHash(sp.Key) != keys[i] {
+			return nil, fmt.Errorf("storage proof %d is for key %s, want %s", i, sp.Key, keys[i])
+		}
+		storageProof, err := decodeProof(sp.Proof)
+		if err != nil {
+			return nil, err
+		}
+		val, err := trie.VerifyProof(res.StorageHash, crypto.Keccak256(keys[i][:]), proofDB(storageProof))
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage proof for key %s: %w", keys[i], err)
+		}
+		value := new(big.Int)
+		if val != nil {
+			var enc []byte
+			if err := rlp.DecodeBytes(val, &enc); err != nil {
+				return nil, err
+			}
+			value.SetBytes(enc)
+		}
+		if sp.Value == nil || value.Cmp(sp.Value) != 0 {
+			return nil, fmt.Errorf("storage value mismatch for key %s: proof has %d, result has %v", keys[i], value, sp.Value)
+		}
+		proof.StorageProof = append(proof.StorageProof, rollup.StorageProof{
+			Key:   keys[i],
+			Value: (*hexutil.Big)(value),
+			Proof: storageProof,
+		})
+	}
+	return proof, nil
+}
+
+func decodeProof(nodes []string) ([]hexutil.Bytes, error) {
+	proof := make([]hexutil.Bytes, len(nodes))
+	for i, node := range nodes {
+		blob, err := hexutil.Decode(node)
+		if err != nil {
+			return nil, err
+		}
+		proof[i] = blob
+	}
+	return proof, nil
+}
+
+// proofDB returns a database of the proof nodes by hash, to verify the proof
+// with.
+func proofDB(proof []hexutil.Bytes) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// AdminAPI is the admin_ RPC namespace of the rollup node, which lets operators
+// hand the sequencer over between nodes and reset the derivation.
+type AdminAPI struct {
+	driver Driver
+}
+
+// NewAdminAPI creates the admin_ API.
+func NewAdminAPI(driver Driver) *AdminAPI {
+	return &AdminAPI{driver: driver}
+}
+
+// StartSequencer starts sequencing on top of the block with the given hash,
+// which must be the unsafe head of the node.
+func (api *AdminAPI) StartSequencer(hash common.Hash) error {
+	return api.driver.StartSequencer(hash)
+}
+
+// StopSequencer stops sequencing and returns the hash of the last sequenced
+// block, for the next sequencer to start from.
+func (api *AdminAPI) StopSequencer() (common.Hash, error) {
+	return api.driver.StopSequencer()
+}
+
+// SetSequencerDrain switches the drain mode of the sequencer, in which it
+// builds blocks without gossiping them.
+func (api *AdminAPI) SetSequencerDrain(drain bool) {
+	api.driver.SetSequencerDrain(drain)
+}
+
+// ResetDerivationPipeline discards the buffered derivation data and restarts
+// the derivation after the safe head.
+func (api *AdminAPI) ResetDerivationPipeline() error {
+	return api.driver.ResetDerivationPipeline()
+}
+
+// LogAPI is the part of the admin_ RPC namespace that changes the log levels of
+// the node components at runtime, to debug a node without restarting it.
+type LogAPI struct {
+	handler *rollup.ComponentLogHandler
+}
+
+// NewLogAPI creates the log level part of the admin_ API.
+func NewLogAPI(handler *rollup.ComponentLogHandler) *LogAPI {
+	return &LogAPI{handler: handler}
+}
+
+// SetLogLevel sets the log level of a component, one of trace, debug, info,
+// warn, error and crit. An empty component sets the default level.
+func (api *LogAPI) SetLogLevel(component string, level string) error {
+	if err := checkLogComponent(component); err != nil {
+		return err
+	}
+	lvl, err := log.LvlFromString(level)
+	if err != nil {
+		return err
+	}
+	api.handler.SetLevel(component, lvl)
+	return nil
+}
+
+// ResetLogLevel makes a component log at the default level again.
+func (api *LogAPI) ResetLogLevel(component string) error {
+	if err := checkLogComponent(component); err != nil {
+		return err
+	}
+	api.handler.ResetLevel(component)
+	return nil
+}
+
+// LogLevels returns the default log level, under the empty component, and the
+// levels of the components that have their own.
+func (api *LogAPI) LogLevels() map[string]string {
+	levels := make(map[string]string)
+	for component, lvl := range api.handler.Levels() {
+		levels[component] = lvl.String()
+	}
+	return levels
+}
+
+func checkLogComponent(component string) error {
+	if component == "" {
+		return nil
+	}
+	for _, c := range rollup.LogComponents {
+		if c == component {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown log component %q", component)
+}
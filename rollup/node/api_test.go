@@ -0,0 +1,542 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testDriver struct {
+	status     rollup.SyncStatus
+	sequencing bool
+	draining   bool
+	resets     int
+	trace      []derive.StepTrace
+	deposits   []*derive.IndexedDeposit // nil if the index is disabled
+	events     event.Feed
+}
+
+func (d *testDriver) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	return &d.status, nil
+}
+
+func (d *testDriver) StartSequencer(hash common.Hash) error {
+	if d.sequencing {
+		return errors.New("sequencer already running")
+	}
+	if hash != d.status.UnsafeL2.Hash {
+		return errors.New("block is not the unsafe head")
+	}
+	d.sequencing = true
+	return nil
+}
+
+func (d *testDriver) StopSequencer() (common.Hash, error) {
+	if !d.sequencing {
+		return common.Hash{}, errors.New("sequencer not running")
+	}
+	d.sequencing = false
+	return d.status.UnsafeL2.Hash, nil
+}
+
+func (d *testDriver) SetSequencerDrain(drain bool) {
+	d.draining = drain
+}
+
+func (d *testDriver) ResetDerivationPipeline() error {
+	d.resets++
+	return nil
+}
+
+func (d *testDriver) SequencerStatus() rollup.SequencerStatus {
+	return rollup.SequencerStatus{Active: d.sequencing, Draining: d.draining, Head: d.status.UnsafeL2}
+}
+
+func (d *testDriver) DerivationTrace() ([]derive.StepTrace, error) {
+	if d.trace == nil {
+		return nil, errors.New("derivation tracing disabled")
+	}
+	return d.trace, nil
+}
+
+func (d *testDriver) DepositsByL1TxHash(hash common.Hash) ([]*derive.IndexedDeposit, error) {
+	if d.deposits == nil {
+		return nil, errors.New("deposit index disabled")
+	}
+	var deps []*derive.IndexedDeposit
+	for _, dep := range d.deposits {
+		if dep.L1TxHash == hash {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+func (d *testDriver) DepositBySourceHash(hash common.Hash) (*derive.IndexedDeposit, error) {
+	if d.deposits == nil {
+		return nil, errors.New("deposit index disabled")
+	}
+	for _, dep := range d.deposits {
+		if dep.SourceHash == hash {
+			return dep, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *testDriver) SubscribeEvents(ch chan<- rollup.NodeEvent) event.Subscription {
+	return d.events.Subscribe(ch)
+}
+
+// testL2 serves a single L2 block, with a state that holds withdrawals.
+type testL2 struct {
+	header  *types.Header
+	block   *types.Block
+	number  *big.Int // number of the last requested payload
+	proof   *gethclient.AccountResult
+	statedb *state.StateDB
+}
+
+func newTestL2(t *testing.T) *testL2 {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetNonce(rollup.L2ToL1MessagePasserAddr, 1)
+	statedb.SetState(rollup.L2ToL1MessagePasserAddr, common.HexToHash("0x01"), common.HexToHash("0x01"))
+	statedb.SetNonce(common.HexToAddress("0x1234"), 1)
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := statedb.GetProof(rollup.L2ToL1MessagePasserAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof := &gethclient.AccountResult{
+		Address:     rollup.L2ToL1MessagePasserAddr,
+		StorageHash: statedb.StorageTrie(rollup.L2ToL1MessagePasserAddr).Hash(),
+	}
+	for _, node := range nodes {
+		proof.AccountProof = append(proof.AccountProof, hexutil.Encode(node))
+	}
+	return &testL2{header: &types.Header{Number: big.NewInt(5), Root: root}, proof: proof, statedb: statedb}
+}
+
+func (l2 *testL2) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return l2.header, nil
+}
+
+func (l2 *testL2) PayloadByNumber(ctx context.Context, number *big.Int) (*beacon.ExecutableDataV1, error) {
+	l2.number = number
+	if l2.block == nil {
+		return nil, errors.New("not found")
+	}
+	return beacon.BlockToExecutableData(l2.block), nil
+}
+
+func (l2 *testL2) GetProof(ctx context.Context, account common.Address, keys []string, number *big.Int) (*gethclient.AccountResult, error) {
+	res := *l2.proof
+	res.StorageProof = nil
+	for _, key := range keys {
+		nodes, err := l2.statedb.GetStorageProof(account, common.HexToHash(key))
+		if err != nil {
+			return nil, err
+		}
+		sp := gethclient.StorageResult{Key: key, Value: l2.statedb.GetState(account, common.HexToHash(key)).Big()}
+		for _, node := range nodes {
+			sp.Proof = append(sp.Proof, hexutil.Encode(node))
+		}
+		res.StorageProof = append(res.StorageProof, sp)
+	}
+	return &res, nil
+}
+
+func TestAPI(t *testing.T) {
+	cfg := &rollup.Config{
+		Genesis: rollup.Genesis{
+			L1:     rollup.BlockID{Hash: common.HexToHash("0x01"), Number: 10},
+			L2:     rollup.BlockID{Hash: common.HexToHash("0x02")},
+			L2Time: 1000,
+		},
+		BlockTime:          2,
+		MaxSequencerDrift:  600,
+		SeqWindowSize:      120,
+		L1ChainID:          big.NewInt(900),
+		L2ChainID:          big.NewInt(901),
+		BatchInboxAddress:  common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress: common.HexToAddress("0x1234"),
+	}
+	driver := &testDriver{status: rollup.SyncStatus{
+		HeadL1:    rollup.L1BlockRef{Hash: common.HexToHash("0x11"), Number: 20, ParentHash: common.HexToHash("0x10"), Time: 1200},
+		CurrentL1: rollup.L1BlockRef{Hash: common.HexToHash("0x0f"), Number: 15},
+		UnsafeL2: rollup.L2BlockRef{
+			Hash:           common.HexToHash("0x21"),
+			Number:         100,
+			L1Origin:       rollup.BlockID{Hash: common.HexToHash("0x11"), Number: 20},
+			SequenceNumber: 3,
+		},
+		SafeL2:      rollup.L2BlockRef{Hash: common.HexToHash("0x20"), Number: 80},
+		FinalizedL2: cfg.L2GenesisRef(),
+	}}
+
+	srv := rpc.NewServer()
+	l2 := newTestL2(t)
+	for _, api := range APIs(cfg, driver, l2) {
+		if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := NewClient(rpc.DialInProc(srv))
+	defer client.Close()
+
+	ctx := context.Background()
+	status, err := client.SyncStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *status != driver.status {
+		t.Fatalf("unexpected sync status\nhave %+v\nwant %+v", status, driver.status)
+	}
+	config, err := client.RollupConfig(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, cfg) {
+		t.Fatalf("unexpected rollup config\nhave %+v\nwant %+v", config, cfg)
+	}
+	if _, err := client.DerivationTrace(ctx); err == nil {
+		t.Fatal("derivation trace returned with tracing disabled")
+	}
+	driver.trace = []derive.StepTrace{
+		{Seq: 7, Kind: derive.StepAdvance, L1Block: &driver.status.CurrentL1, Head: driver.status.SafeL2},
+		{Seq: 8, Kind: derive.StepFailed, Head: driver.status.SafeL2, Error: "failed to fetch L1 block 16"},
+	}
+	trace, err := client.DerivationTrace(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(trace, driver.trace) {
+		t.Fatalf("unexpected derivation trace\nhave %+v\nwant %+v", trace, driver.trace)
+	}
+
+	l1Tx := common.HexToHash("0x1d")
+	if _, err := client.DepositByL1Hash(ctx, l1Tx); err == nil {
+		t.Fatal("deposits returned with the deposit index disabled")
+	}
+	dep := &derive.IndexedDeposit{SourceHash: common.HexToHash("0x50"), L1TxHash: l1Tx, L1BlockNumber: 12, L2BlockNumber: 6, L2TxHash: common.HexToHash("0x2d")}
+	driver.deposits = []*derive.IndexedDeposit{dep}
+	deps, err := client.DepositByL1Hash(ctx, l1Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deps) != 1 || *deps[0] != *dep {
+		t.Fatalf("unexpected deposits %+v, want %+v", deps, dep)
+	}
+	if deps, err := client.DepositByL1Hash(ctx, common.HexToHash("0x1e")); err != nil || deps == nil || len(deps) != 0 {
+		t.Fatalf("unexpected deposits of unknown L1 tx: %v (err %v)", deps, err)
+	}
+	if d, err := client.DepositBySourceHash(ctx, dep.SourceHash); err != nil || d == nil || *d != *dep {
+		t.Fatalf("unexpected deposit by source hash %+v (err %v)", d, err)
+	}
+	if d, err := client.DepositBySourceHash(ctx, common.HexToHash("0x51")); err != nil || d != nil {
+		t.Fatalf("unexpected deposit of unknown source hash %+v (err %v)", d, err)
+	}
+
+	output, err := client.OutputAtBlock(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, hash := l2.header.Root, l2.header.Hash()
+	want := rollup.Output{
+		Version:               rollup.OutputVersionV0,
+		OutputRoot:            crypto.Keccak256Hash(make([]byte, 32), root[:], l2.proof.StorageHash[:], hash[:]),
+		BlockHash:             hash,
+		StateRoot:             root,
+		WithdrawalStorageRoot: l2.proof.StorageHash,
+	}
+	if *output != want {
+		t.Fatalf("unexpected output\nhave %+v\nwant %+v", output, want)
+	}
+
+	// The proof of a withdrawal slot and of an empty slot.
+	keys := []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}
+	proof, err := client.OutputProofAtBlock(ctx, 5, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Output != want {
+		t.Fatalf("unexpected output\nhave %+v\nwant %+v", proof.Output, want)
+	}
+	if len(proof.AccountProof) != len(l2.proof.AccountProof) {
+		t.Fatalf("account proof has %d nodes, want %d", len(proof.AccountProof), len(l2.proof.AccountProof))
+	}
+	if len(proof.StorageProof) != 2 {
+		t.Fatalf("got %d storage proofs, want 2", len(proof.StorageProof))
+	}
+	for i, value := range []int64{1, 0} {
+		sp := proof.StorageProof[i]
+		if sp.Key != keys[i] || sp.Value.ToInt().Int64() != value || len(sp.Proof) == 0 {
+			t.Errorf("unexpected storage proof %d: %+v", i, sp)
+		}
+	}
+	// A storage value that does not match its proof is rejected.
+	l2.statedb.SetState(rollup.L2ToL1MessagePasserAddr, common.HexToHash("0x02"), common.HexToHash("0x05"))
+	if _, err := client.OutputProofAtBlock(ctx, 5, keys); err == nil {
+		t.Fatal("output with invalid storage proof returned")
+	}
+	// A proof that does not match the claimed storage root is rejected.
+	l2.proof.StorageHash = common.HexToHash("0x01")
+	if _, err := client.OutputAtBlock(ctx, 5); err == nil {
+		t.Fatal("output with invalid withdrawal storage proof returned")
+	}
+}
+
+func TestBlockByNumber(t *testing.T) {
+	cfg := &rollup.Config{
+		Genesis: rollup.Genesis{
+			L1: rollup.BlockID{Hash: common.HexToHash("0x01"), Number: 10},
+			L2: rollup.BlockID{Hash: common.HexToHash("0x02")},
+		},
+		L2ChainID: big.NewInt(901),
+	}
+	driver := &testDriver{status: rollup.SyncStatus{
+		UnsafeL2:    rollup.L2BlockRef{Number: 9},
+		SafeL2:      rollup.L2BlockRef{Number: 8},
+		FinalizedL2: rollup.L2BlockRef{Number: 7},
+	}}
+	l1Origin := &types.Header{Number: big.NewInt(12), Time: 1200, BaseFee: big.NewInt(7)}
+	infoTx, err := types.NewL1InfoDepositTx(l1Origin, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x1234")
+	depositTx := types.NewTx(&types.DepositTx{From: to, To: &to, Mint: big.NewInt(1), Value: new(big.Int), Gas: 21000})
+	key, _ := crypto.GenerateKey()
+	userTx, err := types.SignNewTx(key, types.LatestSignerForChainID(cfg.L2ChainID), &types.LegacyTx{To: &to, Gas: 21000, GasPrice: big.NewInt(1), Value: new(big.Int)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: big.NewInt(9), Time: 1210, GasLimit: 30_000_000, BaseFee: big.NewInt(1), Difficulty: new(big.Int)}
+	l2 := newTestL2(t)
+	l2.block = types.NewBlockWithHeader(header).WithBody(types.Transactions{infoTx, depositTx, userTx}, nil)
+
+	srv := rpc.NewServer()
+	for _, api := range APIs(cfg, driver, l2) {
+		if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := NewClient(rpc.DialInProc(srv))
+	defer client.Close()
+
+	ctx := context.Background()
+	block, err := client.BlockByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRef := rollup.L2BlockRef{
+		Hash:           l2.block.Hash(),
+		Number:         9,
+		Time:           1210,
+		L1Origin:       rollup.BlockID{Hash: l1Origin.Hash(), Number: 12},
+		SequenceNumber: 2,
+	}
+	if block.L2BlockRef != wantRef {
+		t.Fatalf("unexpected block reference\nhave %+v\nwant %+v", block.L2BlockRef, wantRef)
+	}
+	if block.L1OriginTime != 1200 || block.L1BaseFee.ToInt().Int64() != 7 {
+		t.Fatalf("unexpected L1 info: time %d, base fee %v", block.L1OriginTime, block.L1BaseFee)
+	}
+	if len(block.Deposits) != 2 || block.Deposits[0].Hash() != infoTx.Hash() || block.Deposits[1].Hash() != depositTx.Hash() {
+		t.Fatalf("unexpected deposits %v", block.Deposits)
+	}
+	if len(block.Transactions) != 1 || block.Transactions[0].Hash() != userTx.Hash() {
+		t.Fatalf("unexpected transactions %v", block.Transactions)
+	}
+
+	// Tags resolve to the heads of the node.
+	for tag, want := range map[rpc.BlockNumber]int64{rpc.SafeBlockNumber: 8, rpc.FinalizedBlockNumber: 7, 5: 5} {
+		if _, err := client.BlockByNumber(ctx, tag); err != nil {
+			t.Fatal(err)
+		}
+		if l2.number.Int64() != want {
+			t.Errorf("tag %d requested block %v, want %d", tag, l2.number, want)
+		}
+	}
+	// Deposits after user transactions are rejected.
+	l2.block = types.NewBlockWithHeader(header).WithBody(types.Transactions{infoTx, userTx, depositTx}, nil)
+	if _, err := client.BlockByNumber(ctx, 9); err == nil {
+		t.Fatal("block with deposit after user transaction accepted")
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	driver := &testDriver{status: rollup.SyncStatus{UnsafeL2: rollup.L2BlockRef{Hash: common.HexToHash("0x21"), Number: 100}}}
+	srv := rpc.NewServer()
+	for _, api := range APIs(&rollup.Config{}, driver, newTestL2(t)) {
+		if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := NewClient(rpc.DialInProc(srv))
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := client.StopSequencer(ctx); err == nil {
+		t.Fatal("stopped inactive sequencer")
+	}
+	if err := client.StartSequencer(ctx, common.HexToHash("0x20")); err == nil {
+		t.Fatal("started sequencer below the unsafe head")
+	}
+	if err := client.StartSequencer(ctx, common.HexToHash("0x21")); err != nil {
+		t.Fatal(err)
+	}
+	if active, err := client.SequencerActive(ctx); err != nil || !active {
+		t.Fatalf("started sequencer not active: %v", err)
+	}
+	if err := client.SetSequencerDrain(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	status, err := client.SequencerStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Active || !status.Draining || status.Head != driver.status.UnsafeL2 {
+		t.Fatalf("unexpected sequencer status %+v", status)
+	}
+	hash, err := client.StopSequencer(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != driver.status.UnsafeL2.Hash {
+		t.Fatalf("stopped at %s, want %s", hash, driver.status.UnsafeL2.Hash)
+	}
+	if err := client.ResetDerivationPipeline(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if driver.resets != 1 {
+		t.Fatalf("derivation pipeline reset %d times, want 1", driver.resets)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	driver := new(testDriver)
+	srv := rpc.NewServer()
+	for _, api := range APIs(&rollup.Config{}, driver, newTestL2(t)) {
+		if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := NewClient(rpc.DialInProc(srv))
+	defer client.Close()
+
+	ctx := context.Background()
+	safe, errs := make(chan rollup.NodeEvent), make(chan rollup.NodeEvent)
+	safeSub, err := client.SubscribeEvents(ctx, rollup.EventSafeHead, safe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeSub.Unsubscribe()
+	errSub, err := client.SubscribeEvents(ctx, rollup.EventDerivationError, errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errSub.Unsubscribe()
+
+	// Subscribers only receive the events of their topic.
+	head := rollup.L2BlockRef{Hash: common.HexToHash("0x21"), Number: 100}
+	events := []rollup.NodeEvent{
+		{Kind: rollup.EventUnsafeHead, Head: &head},
+		{Kind: rollup.EventSafeHead, Head: &head},
+		{Kind: rollup.EventDerivationError, Error: "engine unavailable", Class: "temporary"},
+	}
+	for _, ev := range events {
+		driver.events.Send(ev)
+	}
+	for _, want := range []struct {
+		ch chan rollup.NodeEvent
+		ev rollup.NodeEvent
+	}{{safe, events[1]}, {errs, events[2]}} {
+		select {
+		case ev := <-want.ch:
+			if ev.Kind != want.ev.Kind || ev.Error != want.ev.Error || ev.Class != want.ev.Class || (ev.Head == nil) != (want.ev.Head == nil) || (ev.Head != nil && *ev.Head != *want.ev.Head) {
+				t.Errorf("received event %+v, want %+v", ev, want.ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s event received", want.ev.Kind)
+		}
+	}
+	select {
+	case ev := <-safe:
+		t.Fatalf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLogAPI(t *testing.T) {
+	handler := rollup.NewComponentLogHandler(log.DiscardHandler(), log.LvlInfo)
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("admin", NewLogAPI(handler)); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(rpc.DialInProc(srv))
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.SetLogLevel(ctx, rollup.LogDerivation, "trace"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetLogLevel(ctx, "", "warn"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetLogLevel(ctx, "unknown", "debug"); err == nil {
+		t.Fatal("set the level of an unknown component")
+	}
+	if err := client.SetLogLevel(ctx, rollup.LogP2P, "verbose"); err == nil {
+		t.Fatal("set an unknown level")
+	}
+	levels, err := client.LogLevels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"": "warn", rollup.LogDerivation: "trce"}; !reflect.DeepEqual(levels, want) {
+		t.Fatalf("levels %v, want %v", levels, want)
+	}
+	if err := client.ResetLogLevel(ctx, rollup.LogDerivation); err != nil {
+		t.Fatal(err)
+	}
+	if levels, _ := client.LogLevels(ctx); len(levels) != 1 {
+		t.Fatalf("component level not reset: %v", levels)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Client is a client for the optimism_ and admin_ RPC APIs of a rollup node.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{rpc: c}
+}
+
+// Close closes the underlying RPC connection.
+func (c *Client) Close() {
+	c.rpc.Close()
+}
+
+// SyncStatus returns the L1 head and the unsafe, safe and finalized L2 heads of
+// the node.
+func (c *Client) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	var status rollup.SyncStatus
+	if err := c.rpc.CallContext(ctx, &status, "optimism_syncStatus"); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SequencerActive reports whether the node sequences blocks.
+func (c *Client) SequencerActive(ctx context.Context) (bool, error) {
+	var active bool
+	err := c.rpc.CallContext(ctx, &active, "optimism_sequencerActive")
+	return active, err
+}
+
+// SequencerStatus returns the state of the sequencer of the node and the age of
+// its last block.
+func (c *Client) SequencerStatus(ctx context.Context) (*rollup.SequencerStatus, error) {
+	var status rollup.SequencerStatus
+	if err := c.rpc.CallContext(ctx, &status, "optimism_sequencerStatus"); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// DerivationTrace returns the most recent steps of the derivation pipeline of
+// the node, oldest first.
+func (c *Client) DerivationTrace(ctx context.Context) ([]derive.StepTrace, error) {
+	var steps []derive.StepTrace
+	err := c.rpc.CallContext(ctx, &steps, "optimism_derivationTrace")
+	return steps, err
+}
+
+// DepositByL1Hash returns the deposits made by the L1 transaction with the given
+// hash and the L2 transactions that include them.
+func (c *Client) DepositByL1Hash(ctx context.Context, hash common.Hash) ([]*derive.IndexedDeposit, error) {
+	var deps []*derive.IndexedDeposit
+	err := c.rpc.CallContext(ctx, &deps, "optimism_depositByL1Hash", hash)
+	return deps, err
+}
+
+// DepositBySourceHash returns the deposit with the given source hash, or nil if
+// the node has not indexed it.
+func (c *Client) DepositBySourceHash(ctx context.Context, hash common.Hash) (*derive.IndexedDeposit, error) {
+	var dep *derive.IndexedDeposit
+	err := c.rpc.CallContext(ctx, &dep, "optimism_depositBySourceHash", hash)
+	return dep, err
+}
+
+// SubscribeEvents subscribes to the node events of the given kind, one of the
+// rollup.Event constants. It requires a WebSocket or IPC connection.
+func (c *Client) SubscribeEvents(ctx context.Context, kind string, ch chan<- rollup.NodeEvent) (ethereum.Subscription, error) {
+	return c.rpc.Subscribe(ctx, "optimism", ch, kind)
+}
+
+// RollupConfig returns the rollup configuration of the node.
+func (c *Client) RollupConfig(ctx context.Context) (*rollup.Config, error) {
+	var cfg rollup.Config
+	if err := c.rpc.CallContext(ctx, &cfg, "optimism_rollupConfig"); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// BlockByNumber returns the L2 block with the given number, or one of the heads
+// of the node for the latest, safe and finalized tags, with its deposits
+// separated from the user transactions.
+func (c *Client) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*rollup.L2Block, error) {
+	var block rollup.L2Block
+	if err := c.rpc.CallContext(ctx, &block, "optimism_blockByNumber", number); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// OutputAtBlock returns the output of the L2 block with the given number.
+func (c *Client) OutputAtBlock(ctx context.Context, number uint64) (*rollup.Output, error) {
+	var output rollup.Output
+	if err := c.rpc.CallContext(ctx, &output, "optimism_outputAtBlock", hexutil.Uint64(number)); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// OutputProofAtBlock returns the output of the L2 block with the given number,
+// along with the proofs of the message passer account and the given storage
+// slots.
+func (c *Client) OutputProofAtBlock(ctx context.Context, number uint64, keys []common.Hash) (*rollup.OutputProof, error) {
+	var proof rollup.OutputProof
+	if err := c.rpc.CallContext(ctx, &proof, "optimism_outputProofAtBlock", hexutil.Uint64(number), keys); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// StartSequencer starts sequencing on top of the block with the given hash.
+func (c *Client) StartSequencer(ctx context.Context, hash common.Hash) error {
+	return c.rpc.CallContext(ctx, nil, "admin_startSequencer", hash)
+}
+
+// StopSequencer stops sequencing and returns the hash of the last sequenced
+// block.
+func (c *Client) StopSequencer(ctx context.Context) (common.Hash, error) {
+	var hash common.Hash
+	err := c.rpc.CallContext(ctx, &hash, "admin_stopSequencer")
+	return hash, err
+}
+
+// SetSequencerDrain switches the drain mode of the sequencer, in which it
+// builds blocks without gossiping them.
+func (c *Client) SetSequencerDrain(ctx context.Context, drain bool) error {
+	return c.rpc.CallContext(ctx, nil, "admin_setSequencerDrain", drain)
+}
+
+// ResetDerivationPipeline restarts the derivation after the safe head.
+func (c *Client) ResetDerivationPipeline(ctx context.Context) error {
+	return c.rpc.CallContext(ctx, nil, "admin_resetDerivationPipeline")
+}
+
+// SetLogLevel sets the log level of a node component, or the default level if
+// the component is empty.
+func (c *Client) SetLogLevel(ctx context.Context, component string, level string) error {
+	return c.rpc.CallContext(ctx, nil, "admin_setLogLevel", component, level)
+}
+
+// ResetLogLevel makes a node component log at the default level again.
+func (c *Client) ResetLogLevel(ctx context.Context, component string) error {
+	return c.rpc.CallContext(ctx, nil, "admin_resetLogLevel", component)
+}
+
+// LogLevels returns the default log level, under the empty component, and the
+// levels of the components that have their own.
+func (c *Client) LogLevels(ctx context.Context) (map[string]string, error) {
+	var levels map[string]string
+	err := c.rpc.CallContext(ctx, &levels, "admin_logLevels")
+	return levels, err
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// L2ToL1MessagePasserAddr is the address of the predeploy that withdrawals are
+// initiated with. Every withdrawal is recorded in its storage, so the storage
+// root of the contract commits to all withdrawals up to an L2 block.
+var L2ToL1MessagePasserAddr = common.HexToAddress("0x4200000000000000000000000000000000000016")
+
+// OutputVersionV0 is the version of the output root format below.
+var OutputVersionV0 = common.Hash{}
+
+// Output is the commitment to an L2 block that is proposed on L1. Withdrawals
+// are proven on L1 with a storage proof against the withdrawal storage root,
+// which in turn is proven to be part of the output root.
+type Output struct {
+	Version               common.Hash `json:"version"`
+	OutputRoot            common.Hash `json:"outputRoot"`
+	BlockHash             common.Hash `json:"blockHash"`
+	StateRoot             common.Hash `json:"stateRoot"`
+	WithdrawalStorageRoot common.Hash `json:"withdrawalStorageRoot"`
+}
+
+// NewOutputV0 creates the version 0 output of an L2 block, whose root is
+//
+//	keccak256(version ++ stateRoot ++ withdrawalStorageRoot ++ blockHash)
+func NewOutputV0(stateRoot, withdrawalStorageRoot, blockHash common.Hash) *Output {
+	return &Output{
+		Version:               OutputVersionV0,
+		OutputRoot:            crypto.Keccak256Hash(OutputVersionV0[:], stateRoot[:], withdrawalStorageRoot[:], blockHash[:]),
+		BlockHash:             blockHash,
+		StateRoot:             stateRoot,
+		WithdrawalStorageRoot: withdrawalStorageRoot,
+	}
+}
+
+// OutputProof is an output along with the Merkle proofs that withdrawals are
+// proven with: the proof of the message passer account against the state root,
+// and the proofs of storage slots against the withdrawal storage root.
+type OutputProof struct {
+	Output
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	StorageProof []StorageProof  `json:"storageProof"`
+}
+
+// StorageProof proves the value of a storage slot of the message passer.
+type StorageProof struct {
+	Key   common.Hash     `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
@@ -0,0 +1,291 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rollup/program/preimage"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// The local keys of the boot information.
+const (
+	L1HeadKey             = preimage.LocalKey(1)
+	L2HeadKey             = preimage.LocalKey(2)
+	L2ClaimKey            = preimage.LocalKey(3)
+	L2ClaimBlockNumberKey = preimage.LocalKey(4)
+	RollupConfigKey       = preimage.LocalKey(5)
+	L2ChainConfigKey      = preimage.LocalKey(6)
+)
+
+// The hints that the program sends before reading preimages. Every hint is
+// followed by a space and the hash of the block, trie node or code it is about.
+const (
+	HintL1BlockHeader  = "l1-block-header"
+	HintL1Transactions = "l1-transactions"
+	HintL1Receipts     = "l1-receipts"
+	HintL2BlockHeader  = "l2-block-header"
+	HintL2Transactions = "l2-transactions"
+	HintL2StateNode    = "l2-state-node"
+	HintL2Code         = "l2-code"
+)
+
+// PreimageOracle implements L1Oracle and L2Oracle on top of the preimage
+// protocol. Block headers, state trie nodes and code are read as the preimages
+// of their hashes, and the transactions and receipts of a block are read node by
+// node from their tries.
+type PreimageOracle struct {
+	oracle preimage.Oracle
+	hinter preimage.Hinter
+}
+
+// NewPreimageOracle creates an oracle that reads from the host.
+func NewPreimageOracle(oracle preimage.Oracle, hinter preimage.Hinter) *PreimageOracle {
+	return &PreimageOracle{oracle: oracle, hinter: hinter}
+}
+
+func (o *PreimageOracle) hint(kind string, hash common.Hash) error {
+	return o.hinter.Hint(kind + " " + hash.Hex())
+}
+
+// preimage reads the preimage of the given hash, and checks it.
+func (o *PreimageOracle) preimage(hash common.Hash) ([]byte, error) {
+	key := preimage.Keccak256Key(hash)
+	data, err := o.oracle.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !key.Check(data) {
+		return nil, fmt.Errorf("host returned wrong preimage of %s", hash)
+	}
+	return data, nil
+}
+
+func (o *PreimageOracle) header(kind string, hash common.Hash) (*types.Header, error) {
+	if err := o.hint(kind, hash); err != nil {
+		return nil, err
+	}
+	data, err := o.preimage(hash)
+	if err != nil {
+		return nil, err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(data, header); err != nil {
+		return nil, fmt.Errorf("invalid header %s: %w", hash, err)
+	}
+	return header, nil
+}
+
+// list reads the encoded items of the trie with the given root, as built by
+// types.DeriveSha.
+func (o *PreimageOracle) list(root common.Hash) ([][]byte, error) {
+	if root == types.EmptyRootHash {
+		return nil, nil
+	}
+	tr, err := trie.New(common.Hash{}, root, trie.NewDatabase(&preimageDB{KeyValueStore: memorydb.New(), oracle: o}))
+	if err != nil {
+		return nil, err
+	}
+	var items [][]byte
+	for i := uint64(0); ; i++ {
+		item, err := tr.TryGet(rlp.AppendUint64(nil, i))
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			return items, nil
+		}
+		items = append(items, item)
+	}
+}
+
+func (o *PreimageOracle) transactions(kind string, block common.Hash, root common.Hash) (types.Transactions, error) {
+	if err := o.hint(kind, block); err != nil {
+		return nil, err
+	}
+	items, err := o.list(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions of %s: %w", block, err)
+	}
+	txs := make(types.Transactions, len(items))
+	for i, item := range items {
+		txs[i] = new(types.Transaction)
+		if err := txs[i].UnmarshalBinary(item); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d of %s: %w", i, block, err)
+		}
+	}
+	return txs, nil
+}
+
+// HeaderByHash implements L1Oracle.
+func (o *PreimageOracle) HeaderByHash(hash common.Hash) (*types.Header, error) {
+	return o.header(HintL1BlockHeader, hash)
+}
+
+// TransactionsByHash implements L1Oracle.
+func (o *PreimageOracle) TransactionsByHash(hash common.Hash) (types.Transactions, error) {
+	header, err := o.HeaderByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return o.transactions(HintL1Transactions, hash, header.TxHash)
+}
+
+// ReceiptsByHash implements L1Oracle.
+func (o *PreimageOracle) ReceiptsByHash(hash common.Hash) (types.Receipts, error) {
+	header, err := o.HeaderByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.hint(HintL1Receipts, hash); err != nil {
+		return nil, err
+	}
+	items, err := o.list(header.ReceiptHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipts of %s: %w", hash, err)
+	}
+	receipts := make(types.Receipts, len(items))
+	for i, item := range items {
+		receipts[i] = new(types.Receipt)
+		if err := receipts[i].UnmarshalBinary(item); err != nil {
+			return nil, fmt.Errorf("invalid receipt %d of %s: %w", i, hash, err)
+		}
+	}
+	return receipts, nil
+}
+
+// BlockByHash implements L2Oracle.
+func (o *PreimageOracle) BlockByHash(hash common.Hash) (*types.Block, error) {
+	header, err := o.header(HintL2BlockHeader, hash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := o.transactions(HintL2Transactions, hash, header.TxHash)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(header).WithBody(txs, nil), nil
+}
+
+// NodeByHash implements L2Oracle.
+func (o *PreimageOracle) NodeByHash(hash common.Hash) ([]byte, error) {
+	if err := o.hint(HintL2StateNode, hash); err != nil {
+		return nil, err
+	}
+	return o.preimage(hash)
+}
+
+// CodeByHash implements L2Oracle.
+func (o *PreimageOracle) CodeByHash(hash common.Hash) ([]byte, error) {
+	if err := o.hint(HintL2Code, hash); err != nil {
+		return nil, err
+	}
+	return o.preimage(hash)
+}
+
+// preimageDB is a key-value store that reads the trie nodes it does not have
+// as preimages, without hints. The hint for the whole trie is sent before the
+// trie is read.
+type preimageDB struct {
+	ethdb.KeyValueStore
+	oracle *PreimageOracle
+}
+
+func (db *preimageDB) Get(key []byte) ([]byte, error) {
+	if data, err := db.KeyValueStore.Get(key); err == nil {
+		return data, nil
+	}
+	if len(key) != common.HashLength {
+		return nil, fmt.Errorf("unexpected trie key %x", key)
+	}
+	data, err := db.oracle.preimage(common.BytesToHash(key))
+	if err != nil {
+		return nil, err
+	}
+	db.KeyValueStore.Put(key, data)
+	return data, nil
+}
+
+// ReadBootInfo reads the boot information from the local keys.
+func ReadBootInfo(oracle preimage.Oracle) (*BootInfo, error) {
+	var boot BootInfo
+	for _, field := range []struct {
+		key  preimage.LocalKey
+		name string
+		hash *common.Hash
+	}{
+		{L1HeadKey, "L1 head", &boot.L1Head},
+		{L2HeadKey, "L2 head", &boot.L2Head},
+		{L2ClaimKey, "L2 claim", &boot.L2Claim},
+	} {
+		data, err := oracle.Get(field.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", field.name, err)
+		}
+		if len(data) != common.HashLength {
+			return nil, fmt.Errorf("invalid %s %x", field.name, data)
+		}
+		*field.hash = common.BytesToHash(data)
+	}
+	number, err := oracle.Get(L2ClaimBlockNumberKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read L2 claim block number: %w", err)
+	}
+	if len(number) != 8 {
+		return nil, fmt.Errorf("invalid L2 claim block number %x", number)
+	}
+	boot.L2ClaimBlockNumber = binary.BigEndian.Uint64(number)
+
+	data, err := oracle.Get(RollupConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rollup config: %w", err)
+	}
+	if err := json.Unmarshal(data, &boot.Rollup); err != nil {
+		return nil, fmt.Errorf("invalid rollup config: %w", err)
+	}
+	if data, err = oracle.Get(L2ChainConfigKey); err != nil {
+		return nil, fmt.Errorf("failed to read L2 chain config: %w", err)
+	}
+	if err := json.Unmarshal(data, &boot.L2Chain); err != nil {
+		return nil, fmt.Errorf("invalid L2 chain config: %w", err)
+	}
+	return &boot, nil
+}
+
+// RunClient runs the program against the host on the other end of the given
+// channels: it reads the boot information and checks the claim.
+func RunClient(ctx context.Context, preimages, hints io.ReadWriter, logger log.Logger) error {
+	oracle := preimage.NewOracleClient(preimages)
+	boot, err := ReadBootInfo(oracle)
+	if err != nil {
+		return err
+	}
+	po := NewPreimageOracle(oracle, preimage.NewHintWriter(hints))
+	_, err = Run(ctx, boot, po, po, logger)
+	return err
+}
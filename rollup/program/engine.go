@@ -0,0 +1,279 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Engine is a stateless L2 execution engine, which implements derive.Engine.
+// It reads the blocks and the state it builds on from an oracle, and keeps the
+// blocks and the state it builds or imports in memory.
+//
+// Blocks are built like the miner builds them for the engine API: the
+// transactions of the payload attributes are all included in order, and the
+// transaction pool is never used. Without a gas limit in the attributes, the
+// block keeps the gas limit of its parent.
+type Engine struct {
+	config *params.ChainConfig
+	oracle L2Oracle
+	db     state.Database
+
+	blocks   map[common.Hash]*types.Block
+	payloads map[beacon.PayloadID]*types.Block
+	nextID   uint64
+	head     common.Hash
+}
+
+// NewEngine creates a stateless engine of the L2 chain with the given config.
+func NewEngine(config *params.ChainConfig, oracle L2Oracle) *Engine {
+	kv := &oracleDB{KeyValueStore: memorydb.New(), oracle: oracle}
+	return &Engine{
+		config:   config,
+		oracle:   oracle,
+		db:       state.NewDatabase(rawdb.NewDatabase(kv)),
+		blocks:   make(map[common.Hash]*types.Block),
+		payloads: make(map[beacon.PayloadID]*types.Block),
+	}
+}
+
+// Head returns the hash of the head block of the last forkchoice update.
+func (e *Engine) Head() common.Hash {
+	return e.head
+}
+
+// Block returns the L2 block with the given hash.
+func (e *Engine) Block(hash common.Hash) (*types.Block, error) {
+	if block, ok := e.blocks[hash]; ok {
+		return block, nil
+	}
+	block, err := e.oracle.BlockByHash(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read L2 block %s: %w", hash, err)
+	}
+	if block.Hash() != hash {
+		return nil, fmt.Errorf("oracle returned block %s for %s", block.Hash(), hash)
+	}
+	if root := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); root != block.TxHash() {
+		return nil, fmt.Errorf("oracle returned block %s with transaction root %s", hash, root)
+	}
+	e.blocks[hash] = block
+	return block, nil
+}
+
+// State returns the state after the given block.
+func (e *Engine) State(block *types.Block) (*state.StateDB, error) {
+	return state.New(block.Root(), e.db, nil)
+}
+
+// ForkchoiceUpdate implements derive.Engine. It sets the head, and builds a block
+// on top of it if attributes are given.
+func (e *Engine) ForkchoiceUpdate(ctx context.Context, fc *beacon.ForkchoiceStateV1, attrs *beacon.PayloadAttributesV1) (*beacon.ForkChoiceResponse, error) {
+	head, err := e.Block(fc.HeadBlockHash)
+	if err != nil {
+		return nil, err
+	}
+	e.head = head.Hash()
+	res := &beacon.ForkChoiceResponse{PayloadStatus: beacon.PayloadStatusV1{Status: beacon.VALID, LatestValidHash: &e.head}}
+	if attrs == nil {
+		return res, nil
+	}
+	block, err := e.buildBlock(head, attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build block on %s: %w", head.Hash(), err)
+	}
+	var id beacon.PayloadID
+	binary.BigEndian.PutUint64(id[:], e.nextID)
+	e.nextID++
+	e.payloads[id] = block
+	res.PayloadID = &id
+	return res, nil
+}
+
+// GetPayload implements derive.Engine.
+func (e *Engine) GetPayload(ctx context.Context, id beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	block, ok := e.payloads[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown payload %s", id)
+	}
+	return beacon.BlockToExecutableData(block), nil
+}
+
+// NewPayload implements derive.Engine. It executes the payload on top of its
+// parent and checks the result against the payload.
+func (e *Engine) NewPayload(ctx context.Context, payload *beacon.ExecutableDataV1) (*beacon.PayloadStatusV1, error) {
+	block, err := beacon.ExecutableDataToBlock(*payload)
+	if err != nil {
+		return invalidStatus(err), nil
+	}
+	if _, ok := e.blocks[block.Hash()]; ok {
+		return &beacon.PayloadStatusV1{Status: beacon.VALID, LatestValidHash: &payload.BlockHash}, nil
+	}
+	parent, err := e.Block(block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	executed, err := e.execute(parent, types.CopyHeader(block.Header()), block.Transactions())
+	if err != nil {
+		return invalidStatus(err), nil
+	}
+	if executed.Hash() != block.Hash() {
+		return invalidStatus(fmt.Errorf("executed block %s differs from payload %s", executed.Hash(), block.Hash())), nil
+	}
+	e.blocks[executed.Hash()] = executed
+	return &beacon.PayloadStatusV1{Status: beacon.VALID, LatestValidHash: &payload.BlockHash}, nil
+}
+
+func invalidStatus(err error) *beacon.PayloadStatusV1 {
+	msg := err.Error()
+	return &beacon.PayloadStatusV1{Status: beacon.INVALID, ValidationError: &msg}
+}
+
+// buildBlock builds a block with the given attributes on top of the parent.
+func (e *Engine) buildBlock(parent *types.Block, attrs *beacon.PayloadAttributesV1) (*types.Block, error) {
+	if attrs.Timestamp <= parent.Time() {
+		return nil, fmt.Errorf("timestamp %d not after parent timestamp %d", attrs.Timestamp, parent.Time())
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Time:       attrs.Timestamp,
+		Coinbase:   attrs.SuggestedFeeRecipient,
+		MixDigest:  attrs.Random,
+		Difficulty: new(big.Int),
+	}
+	if attrs.GasLimit != nil {
+		header.GasLimit = *attrs.GasLimit
+	}
+	if e.config.IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(e.config, parent.Header())
+	}
+	txs := make(types.Transactions, len(attrs.Transactions))
+	for i, enc := range attrs.Transactions {
+		txs[i] = new(types.Transaction)
+		if err := txs[i].UnmarshalBinary(enc); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %w", i, err)
+		}
+	}
+	block, err := e.execute(parent, header, txs)
+	if err != nil {
+		return nil, err
+	}
+	e.blocks[block.Hash()] = block
+	return block, nil
+}
+
+// execute applies the transactions on top of the state of the parent, and
+// assembles the block with the given header and the resulting state.
+func (e *Engine) execute(parent *types.Block, header *types.Header, txs types.Transactions) (*types.Block, error) {
+	statedb, err := e.State(parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state of %s: %w", parent.Hash(), err)
+	}
+	var (
+		gp       = new(core.GasPool).AddGas(header.GasLimit)
+		receipts = make(types.Receipts, 0, len(txs))
+	)
+	header.GasUsed = 0
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), i)
+		receipt, err := core.ApplyTransaction(e.config, e, &header.Coinbase, gp, statedb, header, tx, &header.GasUsed, vm.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply transaction %d %s: %w", i, tx.Hash(), err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	deleteEmpty := e.config.IsEIP158(header.Number)
+	header.Root = statedb.IntermediateRoot(deleteEmpty)
+	block := types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
+	if _, err := statedb.Commit(deleteEmpty); err != nil {
+		return nil, fmt.Errorf("failed to commit state: %w", err)
+	}
+	return block, nil
+}
+
+// Engine implements core.ChainContext. The author of every block is passed to
+// the execution, so no consensus engine is needed.
+func (e *Engine) Engine() consensus.Engine {
+	return nil
+}
+
+// GetHeader implements core.ChainContext, for the BLOCKHASH opcode.
+func (e *Engine) GetHeader(hash common.Hash, number uint64) *types.Header {
+	block, err := e.Block(hash)
+	if err != nil || block.NumberU64() != number {
+		return nil
+	}
+	return block.Header()
+}
+
+// oracleDB is a key-value store that reads the state trie nodes and contract
+// code it does not have from an oracle, and keeps whatever is written to it in
+// memory.
+type oracleDB struct {
+	ethdb.KeyValueStore
+	oracle L2Oracle
+}
+
+func (db *oracleDB) Has(key []byte) (bool, error) {
+	_, err := db.Get(key)
+	return err == nil, nil
+}
+
+func (db *oracleDB) Get(key []byte) ([]byte, error) {
+	data, err := db.KeyValueStore.Get(key)
+	if err == nil {
+		return data, nil
+	}
+	var hash common.Hash
+	if isCode, codeHash := rawdb.IsCodeKey(key); isCode {
+		hash = common.BytesToHash(codeHash)
+		data, err = db.oracle.CodeByHash(hash)
+	} else if len(key) == common.HashLength {
+		hash = common.BytesToHash(key)
+		data, err = db.oracle.NodeByHash(hash)
+	} else {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if crypto.Keccak256Hash(data) != hash {
+		return nil, fmt.Errorf("oracle returned wrong preimage of %s", hash)
+	}
+	db.KeyValueStore.Put(key, data)
+	return data, nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rollup/program/preimage"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// L1Source provides the L1 chain to the host. It is implemented by
+// derive.L1Source.
+type L1Source interface {
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	Receipts(ctx context.Context, hash common.Hash) ([]*types.Receipt, error)
+}
+
+// L2Source provides the L2 chain and its state to the host.
+type L2Source interface {
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+	NodeByHash(ctx context.Context, hash common.Hash) ([]byte, error)
+	CodeByHash(ctx context.Context, hash common.Hash) ([]byte, error)
+}
+
+// errUnknownPreimage is returned for preimages that were not fetched, because
+// the program did not hint them.
+var errUnknownPreimage = errors.New("unknown preimage")
+
+// Host runs on the other end of the channels of the program. It serves the boot
+// information as local keys, and fetches the other preimages from its sources
+// as the program hints them.
+type Host struct {
+	boot *BootInfo
+	l1   L1Source
+	l2   L2Source
+	kv   ethdb.KeyValueStore // preimages, by preimage key
+	log  log.Logger
+}
+
+// NewHost creates a host of a program run with the given boot information.
+func NewHost(boot *BootInfo, l1 L1Source, l2 L2Source, logger log.Logger) *Host {
+	return &Host{boot: boot, l1: l1, l2: l2, kv: memorydb.New(), log: logger}
+}
+
+// Serve serves hints and preimages until the program closes the channels.
+func (h *Host) Serve(ctx context.Context, preimages, hints io.ReadWriter) error {
+	hintErr := make(chan error, 1)
+	go func() {
+		reader := preimage.NewHintReader(hints)
+		route := func(hint string) error {
+			// A failed hint only means that the preimage requests after it
+			// fail, which the program reports.
+			if err := h.Hint(ctx, hint); err != nil {
+				h.log.Warn("Failed to fetch hinted data", "hint", hint, "err", err)
+			}
+			return nil
+		}
+		for {
+			if err := reader.NextHint(route); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				hintErr <- err
+				return
+			}
+		}
+	}()
+	server := preimage.NewOracleServer(preimages)
+	for {
+		if err := server.NextPreimageRequest(h.Preimage); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return <-hintErr
+}
+
+// Preimage returns the preimage of the given key.
+func (h *Host) Preimage(key [32]byte) ([]byte, error) {
+	switch preimage.KeyType(key[0]) {
+	case preimage.LocalKeyType:
+		return h.local(preimage.LocalKey(binary.BigEndian.Uint64(key[24:])))
+	case preimage.Keccak256KeyType:
+		data, err := h.kv.Get(key[:])
+		if err != nil {
+			return nil, fmt.Errorf("%w %x", errUnknownPreimage, key)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown key type %d", key[0])
+	}
+}
+
+func (h *Host) local(key preimage.LocalKey) ([]byte, error) {
+	switch key {
+	case L1HeadKey:
+		return h.boot.L1Head.Bytes(), nil
+	case L2HeadKey:
+		return h.boot.L2Head.Bytes(), nil
+	case L2ClaimKey:
+		return h.boot.L2Claim.Bytes(), nil
+	case L2ClaimBlockNumberKey:
+		number := make([]byte, 8)
+		binary.BigEndian.PutUint64(number, h.boot.L2ClaimBlockNumber)
+		return number, nil
+	case RollupConfigKey:
+		return json.Marshal(h.boot.Rollup)
+	case L2ChainConfigKey:
+		return json.Marshal(h.boot.L2Chain)
+	default:
+		return nil, fmt.Errorf("unknown local key %d", key)
+	}
+}
+
+// Hint fetches the data of the given hint from the sources.
+func (h *Host) Hint(ctx context.Context, hint string) error {
+	parts := strings.SplitN(hint, " ", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("malformed hint %q", hint)
+	}
+	kind := parts[0]
+	var hash common.Hash
+	if err := hash.UnmarshalText([]byte(parts[1])); err != nil {
+		return fmt.Errorf("malformed hint %q: %w", hint, err)
+	}
+	h.log.Trace("Fetching hinted data", "hint", hint)
+	switch kind {
+	case HintL1BlockHeader, HintL1Transactions:
+		block, err := h.l1.BlockByHash(ctx, hash)
+		if err != nil {
+			return err
+		}
+		return h.storeBlock(block)
+	case HintL1Receipts:
+		receipts, err := h.l1.Receipts(ctx, hash)
+		if err != nil {
+			return err
+		}
+		return h.storeList(types.Receipts(receipts))
+	case HintL2BlockHeader, HintL2Transactions:
+		block, err := h.l2.BlockByHash(ctx, hash)
+		if err != nil {
+			return err
+		}
+		return h.storeBlock(block)
+	case HintL2StateNode:
+		node, err := h.l2.NodeByHash(ctx, hash)
+		if err != nil {
+			return err
+		}
+		return h.store(node)
+	case HintL2Code:
+		code, err := h.l2.CodeByHash(ctx, hash)
+		if err != nil {
+			return err
+		}
+		return h.store(code)
+	default:
+		return fmt.Errorf("unknown hint %q", hint)
+	}
+}
+
+// store stores data as the preimage of its hash.
+func (h *Host) store(data []byte) error {
+	return h.kv.Put(keccakKey(crypto.Keccak256Hash(data)), data)
+}
+
+// storeBlock stores the header and the transaction trie of a block.
+func (h *Host) storeBlock(block *types.Block) error {
+	header, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return err
+	}
+	if err := h.store(header); err != nil {
+		return err
+	}
+	return h.storeList(block.Transactions())
+}
+
+// storeList stores the nodes of the trie that types.DeriveSha builds from the
+// list.
+func (h *Host) storeList(list types.DerivableList) error {
+	st := &committingStackTrie{db: &preimageWriter{h.kv}}
+	types.DeriveSha(list, st)
+	return st.err
+}
+
+// committingStackTrie is a stack trie that writes its nodes when it is hashed.
+type committingStackTrie struct {
+	*trie.StackTrie
+	db  ethdb.KeyValueWriter
+	err error
+}
+
+// Reset implements types.TrieHasher. Unlike StackTrie.Reset, it keeps the
+// database.
+func (t *committingStackTrie) Reset() {
+	t.StackTrie = trie.NewStackTrie(t.db)
+}
+
+func (t *committingStackTrie) Hash() common.Hash {
+	root, err := t.Commit()
+	if err != nil {
+		t.err = err
+	}
+	return root
+}
+
+// preimageWriter writes trie nodes under the preimage keys of their hashes.
+type preimageWriter struct {
+	kv ethdb.KeyValueWriter
+}
+
+func (w *preimageWriter) Put(key []byte, value []byte) error {
+	return w.kv.Put(keccakKey(common.BytesToHash(key)), value)
+}
+
+func (w *preimageWriter) Delete(key []byte) error {
+	return w.kv.Delete(keccakKey(common.BytesToHash(key)))
+}
+
+func keccakKey(hash common.Hash) []byte {
+	key := preimage.Keccak256Key(hash).PreimageKey()
+	return key[:]
+}
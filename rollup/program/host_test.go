@@ -0,0 +1,153 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/program/preimage"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// l2Source serves the L2 genesis block and its state to the host.
+type l2Source struct {
+	*l2Oracle
+}
+
+func (s l2Source) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return s.l2Oracle.BlockByHash(hash)
+}
+
+func (s l2Source) NodeByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	return s.l2Oracle.NodeByHash(hash)
+}
+
+func (s l2Source) CodeByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	return s.l2Oracle.CodeByHash(hash)
+}
+
+// runClient runs the program against a host over file descriptor channels.
+func runClient(t *testing.T, s *testSetup, boot *BootInfo) error {
+	t.Helper()
+	preimageClient, preimageHost, err := preimage.CreateBidirectionalChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hintClient, hintHost, err := preimage.CreateBidirectionalChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := NewHost(boot, s.l1, l2Source{s.l2}, log.New())
+	served := make(chan error, 1)
+	go func() {
+		served <- host.Serve(context.Background(), preimageHost, hintHost)
+	}()
+	err = RunClient(context.Background(), preimageClient, hintClient, log.New())
+	preimageClient.Close()
+	hintClient.Close()
+	if serveErr := <-served; serveErr != nil {
+		t.Fatalf("host failed: %v", serveErr)
+	}
+	return err
+}
+
+func TestRunClient(t *testing.T) {
+	s := newTestSetup(t)
+	blocks := s.buildChain(t)
+	head := blocks[len(blocks)-1]
+	output := rollup.NewOutputV0(head.Root(), types.EmptyRootHash, head.Hash())
+
+	boot := &BootInfo{
+		Rollup:             s.cfg,
+		L2Chain:            s.gspec.Config,
+		L1Head:             s.l1.Head().Hash(),
+		L2Head:             s.genesis.Hash(),
+		L2ClaimBlockNumber: head.NumberU64(),
+		L2Claim:            output.OutputRoot,
+	}
+	if err := runClient(t, s, boot); err != nil {
+		t.Fatalf("valid claim rejected: %v", err)
+	}
+	boot.L2Claim = common.HexToHash("0x01")
+	if err := runClient(t, s, boot); !errors.Is(err, ErrInvalidClaim) {
+		t.Fatalf("invalid claim not rejected: %v", err)
+	}
+}
+
+func TestHostPreimages(t *testing.T) {
+	s := newTestSetup(t)
+	s.buildChain(t)
+	host := NewHost(&BootInfo{}, s.l1, l2Source{s.l2}, log.New())
+
+	// Preimages are only known after they were hinted.
+	hash := s.l1.Head().Hash()
+	key := preimage.Keccak256Key(hash).PreimageKey()
+	if _, err := host.Preimage(key); !errors.Is(err, errUnknownPreimage) {
+		t.Fatalf("unhinted preimage served: %v", err)
+	}
+	if err := host.Hint(context.Background(), HintL1BlockHeader+" "+hash.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := host.Preimage(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, hint := range []string{"l1-block-header", "l1-block-header 0x12", "l1-unknown " + hash.Hex()} {
+		if err := host.Hint(context.Background(), hint); err == nil {
+			t.Fatalf("invalid hint %q accepted", hint)
+		}
+	}
+
+	// The oracle reads the transactions and receipts of a block from their
+	// tries, node by node.
+	empty := s.l1.AddBlock()
+	oracle := NewPreimageOracle(hostOracle{host}, hostOracle{host})
+	for _, block := range []*types.Block{s.l1.Block(1), s.l1.Block(2), empty} {
+		txs, err := oracle.TransactionsByHash(block.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if types.DeriveSha(txs, trie.NewStackTrie(nil)) != block.TxHash() {
+			t.Fatalf("wrong transactions of block %d", block.NumberU64())
+		}
+		receipts, err := oracle.ReceiptsByHash(block.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if types.DeriveSha(receipts, trie.NewStackTrie(nil)) != block.ReceiptHash() {
+			t.Fatalf("wrong receipts of block %d", block.NumberU64())
+		}
+	}
+}
+
+// hostOracle reads preimages from a host directly.
+type hostOracle struct {
+	host *Host
+}
+
+func (o hostOracle) Get(key preimage.Key) ([]byte, error) {
+	return o.host.Preimage(key.PreimageKey())
+}
+
+func (o hostOracle) Hint(hint string) error {
+	return o.host.Hint(context.Background(), hint)
+}
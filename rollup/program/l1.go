@@ -0,0 +1,152 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// l1Fetcher is the L1 chain up to a given head block, read from an oracle. It
+// implements derive.L1Fetcher. Blocks are looked up by number by walking back
+// the parent hashes from the head, since the oracle only serves data by hash.
+type l1Fetcher struct {
+	oracle   L1Oracle
+	head     *types.Header
+	headers  map[common.Hash]*types.Header
+	byNumber map[uint64]*types.Header
+	lowest   *types.Header // lowest ancestor of the head that was walked to
+}
+
+func newL1Fetcher(oracle L1Oracle, head common.Hash) (*l1Fetcher, error) {
+	f := &l1Fetcher{
+		oracle:   oracle,
+		headers:  make(map[common.Hash]*types.Header),
+		byNumber: make(map[uint64]*types.Header),
+	}
+	header, err := f.HeaderByHash(context.Background(), head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read L1 head %s: %w", head, err)
+	}
+	f.head, f.lowest = header, header
+	f.byNumber[header.Number.Uint64()] = header
+	return f, nil
+}
+
+// HeaderByHash returns the header of the L1 block with the given hash.
+func (f *l1Fetcher) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if header, ok := f.headers[hash]; ok {
+		return header, nil
+	}
+	header, err := f.oracle.HeaderByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if header.Hash() != hash {
+		return nil, fmt.Errorf("oracle returned header %s for %s", header.Hash(), hash)
+	}
+	f.headers[hash] = header
+	return header, nil
+}
+
+// HeaderByNumber returns the header of the ancestor of the head with the given
+// number, or the head if number is nil. Blocks after the head do not exist.
+func (f *l1Fetcher) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return f.head, nil
+	}
+	if number.Sign() < 0 || !number.IsUint64() || number.Uint64() > f.head.Number.Uint64() {
+		// Block tags such as finalized have no meaning to the program.
+		return nil, ethereum.NotFound
+	}
+	n := number.Uint64()
+	for f.lowest.Number.Uint64() > n {
+		parent, err := f.HeaderByHash(ctx, f.lowest.ParentHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read L1 header %d: %w", f.lowest.Number.Uint64()-1, err)
+		}
+		f.byNumber[parent.Number.Uint64()] = parent
+		f.lowest = parent
+	}
+	return f.byNumber[n], nil
+}
+
+// BlockByHash returns the L1 block with the given hash.
+func (f *l1Fetcher) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	header, err := f.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := f.transactions(header)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(header).WithBody(txs, nil), nil
+}
+
+// Receipts returns the receipts of the L1 block with the given hash, with the
+// fields that locate the receipts and their logs in the block filled in.
+func (f *l1Fetcher) Receipts(ctx context.Context, hash common.Hash) ([]*types.Receipt, error) {
+	header, err := f.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := f.transactions(header)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := f.oracle.ReceiptsByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return nil, fmt.Errorf("oracle returned receipts with root %s for block %s, want %s", root, hash, header.ReceiptHash)
+	}
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("block %s has %d receipts for %d transactions", hash, len(receipts), len(txs))
+	}
+	var logIndex uint
+	for i, receipt := range receipts {
+		receipt.TxHash = txs[i].Hash()
+		receipt.BlockHash = hash
+		receipt.BlockNumber = header.Number
+		receipt.TransactionIndex = uint(i)
+		for _, l := range receipt.Logs {
+			l.TxHash, l.TxIndex, l.BlockHash, l.BlockNumber, l.Index = receipt.TxHash, uint(i), hash, header.Number.Uint64(), logIndex
+			logIndex++
+		}
+	}
+	return receipts, nil
+}
+
+func (f *l1Fetcher) transactions(header *types.Header) (types.Transactions, error) {
+	hash := header.Hash()
+	txs, err := f.oracle.TransactionsByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if root := types.DeriveSha(txs, trie.NewStackTrie(nil)); root != header.TxHash {
+		return nil, fmt.Errorf("oracle returned transactions with root %s for block %s, want %s", root, hash, header.TxHash)
+	}
+	return txs, nil
+}
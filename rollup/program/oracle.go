@@ -0,0 +1,48 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// L1Oracle provides the L1 data that the derivation reads, by block hash. The
+// program checks the data against the block hash, so the oracle need not be
+// trusted.
+type L1Oracle interface {
+	// HeaderByHash returns the header of the L1 block with the given hash.
+	HeaderByHash(hash common.Hash) (*types.Header, error)
+	// TransactionsByHash returns the transactions of the L1 block with the
+	// given hash.
+	TransactionsByHash(hash common.Hash) (types.Transactions, error)
+	// ReceiptsByHash returns the receipts of the L1 block with the given hash.
+	// Only the consensus fields of the receipts are used.
+	ReceiptsByHash(hash common.Hash) (types.Receipts, error)
+}
+
+// L2Oracle provides the L2 blocks and state that the execution reads, by hash.
+// The program checks the data against the hash, so the oracle need not be
+// trusted.
+type L2Oracle interface {
+	// BlockByHash returns the L2 block with the given hash.
+	BlockByHash(hash common.Hash) (*types.Block, error)
+	// NodeByHash returns the state trie node with the given hash.
+	NodeByHash(hash common.Hash) ([]byte, error)
+	// CodeByHash returns the contract code with the given hash.
+	CodeByHash(hash common.Hash) ([]byte, error)
+}
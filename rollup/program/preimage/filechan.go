@@ -0,0 +1,95 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preimage
+
+import (
+	"os"
+)
+
+// The file descriptors that the host passes to the program, after stdin, stdout
+// and stderr.
+const (
+	HintClientReadFd      = 3
+	HintClientWriteFd     = 4
+	PreimageClientReadFd  = 5
+	PreimageClientWriteFd = 6
+)
+
+// FileChannel is one end of a bidirectional channel made of two files.
+type FileChannel struct {
+	r *os.File
+	w *os.File
+}
+
+// NewFileChannel creates a channel that reads from r and writes to w.
+func NewFileChannel(r, w *os.File) *FileChannel {
+	return &FileChannel{r: r, w: w}
+}
+
+func (c *FileChannel) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *FileChannel) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// Reader returns the file that the channel reads from.
+func (c *FileChannel) Reader() *os.File {
+	return c.r
+}
+
+// Writer returns the file that the channel writes to.
+func (c *FileChannel) Writer() *os.File {
+	return c.w
+}
+
+// Close closes both files of the channel.
+func (c *FileChannel) Close() error {
+	rerr, werr := c.r.Close(), c.w.Close()
+	if rerr != nil {
+		return rerr
+	}
+	return werr
+}
+
+// CreateBidirectionalChannel creates the two ends of a channel out of two pipes.
+func CreateBidirectionalChannel() (*FileChannel, *FileChannel, error) {
+	ar, bw, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	br, aw, err := os.Pipe()
+	if err != nil {
+		ar.Close()
+		bw.Close()
+		return nil, nil, err
+	}
+	return NewFileChannel(ar, aw), NewFileChannel(br, bw), nil
+}
+
+// ClientHinterChannel returns the program end of the hint channel, on the file
+// descriptors that the host passed to the program.
+func ClientHinterChannel() *FileChannel {
+	return NewFileChannel(os.NewFile(HintClientReadFd, "hint-read"), os.NewFile(HintClientWriteFd, "hint-write"))
+}
+
+// ClientPreimageChannel returns the program end of the preimage channel, on the
+// file descriptors that the host passed to the program.
+func ClientPreimageChannel() *FileChannel {
+	return NewFileChannel(os.NewFile(PreimageClientReadFd, "preimage-read"), os.NewFile(PreimageClientWriteFd, "preimage-write"))
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preimage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxHintSize bounds the hints that are read from the program.
+const maxHintSize = 1 << 16
+
+// HintWriter is the program side of the hint channel.
+type HintWriter struct {
+	rw io.ReadWriter
+}
+
+// NewHintWriter creates a hinter that sends hints over the given channel.
+func NewHintWriter(rw io.ReadWriter) *HintWriter {
+	return &HintWriter{rw: rw}
+}
+
+// Hint implements Hinter. It returns once the host acknowledged the hint.
+func (h *HintWriter) Hint(hint string) error {
+	if len(hint) > maxHintSize {
+		return fmt.Errorf("hint too large: %d bytes", len(hint))
+	}
+	msg := make([]byte, 4+len(hint))
+	binary.BigEndian.PutUint32(msg, uint32(len(hint)))
+	copy(msg[4:], hint)
+	if _, err := h.rw.Write(msg); err != nil {
+		return fmt.Errorf("failed to write hint %q: %w", hint, err)
+	}
+	var ack [1]byte
+	if _, err := io.ReadFull(h.rw, ack[:]); err != nil {
+		return fmt.Errorf("failed to read acknowledgement of hint %q: %w", hint, err)
+	}
+	return nil
+}
+
+// HintReader is the host side of the hint channel.
+type HintReader struct {
+	rw io.ReadWriter
+}
+
+// NewHintReader creates a reader of the hints sent over the given channel.
+func NewHintReader(rw io.ReadWriter) *HintReader {
+	return &HintReader{rw: rw}
+}
+
+// NextHint waits for the next hint, passes it to the router, and acknowledges
+// it. The hint is acknowledged even if the router fails, since hints are only
+// advisory. It returns io.EOF once the program closed the channel.
+func (h *HintReader) NextHint(router func(hint string) error) error {
+	var length [4]byte
+	if _, err := io.ReadFull(h.rw, length[:]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return fmt.Errorf("failed to read hint length: %w", err)
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > maxHintSize {
+		return fmt.Errorf("hint too large: %d bytes", size)
+	}
+	hint := make([]byte, size)
+	if _, err := io.ReadFull(h.rw, hint); err != nil {
+		return fmt.Errorf("failed to read hint: %w", err)
+	}
+	routeErr := router(string(hint))
+	if _, err := h.rw.Write([]byte{0}); err != nil {
+		return fmt.Errorf("failed to acknowledge hint %q: %w", hint, err)
+	}
+	if routeErr != nil {
+		return fmt.Errorf("failed to handle hint %q: %w", hint, routeErr)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preimage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxPreimageSize bounds the preimages that are read from the host, so that a
+// corrupt length cannot make the program allocate all of its memory.
+const maxPreimageSize = 1 << 30
+
+// OracleClient is the program side of the preimage channel.
+type OracleClient struct {
+	rw io.ReadWriter
+}
+
+// NewOracleClient creates a client that reads preimages over the given channel.
+func NewOracleClient(rw io.ReadWriter) *OracleClient {
+	return &OracleClient{rw: rw}
+}
+
+// Get implements Oracle.
+func (o *OracleClient) Get(key Key) ([]byte, error) {
+	k := key.PreimageKey()
+	if _, err := o.rw.Write(k[:]); err != nil {
+		return nil, fmt.Errorf("failed to write key %x: %w", k, err)
+	}
+	var length [8]byte
+	if _, err := io.ReadFull(o.rw, length[:]); err != nil {
+		return nil, fmt.Errorf("failed to read length of preimage %x: %w", k, err)
+	}
+	size := binary.BigEndian.Uint64(length[:])
+	if size > maxPreimageSize {
+		return nil, fmt.Errorf("preimage %x too large: %d bytes", k, size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(o.rw, data); err != nil {
+		return nil, fmt.Errorf("failed to read preimage %x: %w", k, err)
+	}
+	return data, nil
+}
+
+// OracleServer is the host side of the preimage channel.
+type OracleServer struct {
+	rw io.ReadWriter
+}
+
+// NewOracleServer creates a server that serves preimages over the given channel.
+func NewOracleServer(rw io.ReadWriter) *OracleServer {
+	return &OracleServer{rw: rw}
+}
+
+// NextPreimageRequest waits for the next request, and responds to it with the
+// preimage that the getter returns. It returns io.EOF once the program closed
+// the channel.
+func (o *OracleServer) NextPreimageRequest(getter func(key [32]byte) ([]byte, error)) error {
+	var key [32]byte
+	if _, err := io.ReadFull(o.rw, key[:]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	data, err := getter(key)
+	if err != nil {
+		return fmt.Errorf("failed to get preimage %x: %w", key, err)
+	}
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(data)))
+	if _, err := o.rw.Write(length[:]); err != nil {
+		return fmt.Errorf("failed to write length of preimage %x: %w", key, err)
+	}
+	if _, err := o.rw.Write(data); err != nil {
+		return fmt.Errorf("failed to write preimage %x: %w", key, err)
+	}
+	return nil
+}
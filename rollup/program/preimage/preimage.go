@@ -0,0 +1,97 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package preimage implements the protocol between the fault proof program and
+// its host, which runs the program and provides the data it reads.
+//
+// The program reads all of its data as preimages: it sends the 32-byte key of
+// the preimage, and the host responds with the preimage, prefixed with its
+// length as a big-endian uint64. The first byte of a key is its type:
+//
+//   - a local key is the index of a value that is specific to the run of the
+//     program, such as the claim it checks.
+//   - a keccak256 key is the hash of the preimage, with the first byte
+//     replaced by the key type. The program checks the preimage against it,
+//     so these need not be trusted.
+//
+// Before reading preimages, the program may send a hint, which tells the host
+// what data it is about to read, so that the host can fetch it. A hint is a
+// string prefixed with its length as a big-endian uint32, and the host
+// acknowledges it with a single byte. Hints are not part of the proof: a host
+// that already has all preimages may ignore them.
+//
+// The program talks to its host over two pairs of file descriptors, one for
+// the hints and one for the preimages.
+package preimage
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// KeyType is the first byte of a preimage key.
+type KeyType byte
+
+const (
+	// LocalKeyType is the type of keys that are local to the program run.
+	LocalKeyType KeyType = 1
+	// Keccak256KeyType is the type of keys that are the keccak256 hash of the
+	// preimage.
+	Keccak256KeyType KeyType = 2
+)
+
+// Key is the key of a preimage.
+type Key interface {
+	// PreimageKey returns the 32-byte key that is sent to the host.
+	PreimageKey() [32]byte
+}
+
+// LocalKey is the index of a value that is local to the program run.
+type LocalKey uint64
+
+func (k LocalKey) PreimageKey() (out [32]byte) {
+	out[0] = byte(LocalKeyType)
+	binary.BigEndian.PutUint64(out[24:], uint64(k))
+	return out
+}
+
+// Keccak256Key is the keccak256 hash of a preimage.
+type Keccak256Key common.Hash
+
+func (k Keccak256Key) PreimageKey() (out [32]byte) {
+	out = k
+	out[0] = byte(Keccak256KeyType)
+	return out
+}
+
+// Check reports whether the data is the preimage of the key.
+func (k Keccak256Key) Check(data []byte) bool {
+	return Keccak256Key(crypto.Keccak256Hash(data)).PreimageKey() == k.PreimageKey()
+}
+
+// Oracle reads preimages from the host.
+type Oracle interface {
+	// Get returns the preimage of the key.
+	Get(key Key) ([]byte, error)
+}
+
+// Hinter sends hints to the host.
+type Hinter interface {
+	// Hint tells the host what data the program is about to read.
+	Hint(hint string) error
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package preimage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestKeys(t *testing.T) {
+	local := LocalKey(0x0102).PreimageKey()
+	if want := common.HexToHash("0x0100000000000000000000000000000000000000000000000000000000000102"); local != want {
+		t.Fatalf("local key %x, want %x", local, want)
+	}
+	data := []byte("hello")
+	hash := crypto.Keccak256Hash(data)
+	key := Keccak256Key(hash)
+	if k := key.PreimageKey(); k[0] != byte(Keccak256KeyType) || !bytes.Equal(k[1:], hash[1:]) {
+		t.Fatalf("keccak256 key %x of hash %x", k, hash)
+	}
+	if !key.Check(data) {
+		t.Fatal("preimage rejected")
+	}
+	if key.Check([]byte("hellO")) {
+		t.Fatal("wrong preimage accepted")
+	}
+}
+
+func TestOracle(t *testing.T) {
+	a, b, err := CreateBidirectionalChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	preimages := map[[32]byte][]byte{
+		LocalKey(1).PreimageKey():                             {0x01},
+		Keccak256Key(crypto.Keccak256Hash(nil)).PreimageKey(): {},
+		LocalKey(2).PreimageKey():                             bytes.Repeat([]byte{0xff}, 100_000),
+	}
+	done := make(chan error, 1)
+	go func() {
+		server := NewOracleServer(b)
+		for {
+			err := server.NextPreimageRequest(func(key [32]byte) ([]byte, error) {
+				if data, ok := preimages[key]; ok {
+					return data, nil
+				}
+				return nil, errors.New("not found")
+			})
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	client := NewOracleClient(a)
+	for _, key := range []Key{LocalKey(1), Keccak256Key(crypto.Keccak256Hash(nil)), LocalKey(2)} {
+		data, err := client.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := preimages[key.PreimageKey()]; !bytes.Equal(data, want) {
+			t.Fatalf("preimage of %x: got %d bytes, want %d", key.PreimageKey(), len(data), len(want))
+		}
+	}
+	a.Close()
+	if err := <-done; err != io.EOF {
+		t.Fatalf("server did not stop after the client closed the channel: %v", err)
+	}
+}
+
+func TestHints(t *testing.T) {
+	a, b, err := CreateBidirectionalChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		received []string
+		done     = make(chan error, 1)
+	)
+	go func() {
+		reader := NewHintReader(b)
+		for {
+			err := reader.NextHint(func(hint string) error {
+				received = append(received, hint)
+				if hint == "fail" {
+					return errors.New("failed")
+				}
+				return nil
+			})
+			if err == io.EOF {
+				done <- nil
+				return
+			} else if err != nil && received[len(received)-1] != "fail" {
+				done <- err
+				return
+			}
+		}
+	}()
+	writer := NewHintWriter(a)
+	hints := []string{"l1-block-header " + hexutil.Encode(make([]byte, 32)), "", "fail", "last"}
+	for _, hint := range hints {
+		if err := writer.Hint(hint); err != nil {
+			t.Fatalf("hint %q: %v", hint, err)
+		}
+	}
+	a.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != len(hints) {
+		t.Fatalf("received hints %q, want %q", received, hints)
+	}
+	for i := range hints {
+		if received[i] != hints[i] {
+			t.Fatalf("received hints %q, want %q", received, hints)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package program implements the fault proof program: it derives and executes
+// the L2 chain from L1 without a node or a database, reading every block and
+// every piece of state from a preimage oracle, and checks a claimed output root
+// against the result.
+//
+// The program is deterministic and only trusts its boot information, so running
+// it in a verifiable VM proves whether the claim is correct.
+package program
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// ErrInvalidClaim is returned if the claimed output root is not the output root
+// of the claimed L2 block, or if that block cannot be derived from L1.
+var ErrInvalidClaim = errors.New("invalid claim")
+
+// BootInfo is the trusted input of the program.
+type BootInfo struct {
+	Rollup  *rollup.Config      `json:"rollup"`
+	L2Chain *params.ChainConfig `json:"l2_chain"`
+
+	// L1Head is the L1 block that the L2 chain is derived up to.
+	L1Head common.Hash `json:"l1_head"`
+	// L2Head is an agreed upon L2 block that was derived before L1Head, which
+	// the derivation starts from.
+	L2Head common.Hash `json:"l2_head"`
+
+	// L2ClaimBlockNumber is the number of the L2 block that the claim is about.
+	L2ClaimBlockNumber uint64 `json:"l2_claim_block_number"`
+	// L2Claim is the claimed output root of that block.
+	L2Claim common.Hash `json:"l2_claim"`
+}
+
+// Run derives the L2 chain from the L2 head up to the claimed block, with the
+// batches included in L1 up to the L1 head, and returns the output of the
+// claimed block. It returns ErrInvalidClaim if the output does not match the
+// claim.
+func Run(ctx context.Context, boot *BootInfo, l1 L1Oracle, l2 L2Oracle, logger log.Logger) (*rollup.Output, error) {
+	fetcher, err := newL1Fetcher(l1, boot.L1Head)
+	if err != nil {
+		return nil, err
+	}
+	engine := NewEngine(boot.L2Chain, l2)
+	head, err := engine.Block(boot.L2Head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read L2 head: %w", err)
+	}
+	safeHead, err := derive.L2BlockRefFromPayload(boot.Rollup, beacon.BlockToExecutableData(head))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read L2 head: %w", err)
+	}
+	if safeHead.Number > boot.L2ClaimBlockNumber {
+		return nil, fmt.Errorf("L2 head %d is after the claimed block %d", safeHead.Number, boot.L2ClaimBlockNumber)
+	}
+	pipeline := derive.NewPipeline(boot.Rollup, derive.Confirmations{}, fetcher, engine, safeHead, logger)
+	if err := pipeline.ForkchoiceUpdate(ctx); err != nil {
+		return nil, fmt.Errorf("failed to set L2 head: %w", err)
+	}
+	for pipeline.Head().Number < boot.L2ClaimBlockNumber {
+		if err := pipeline.Step(ctx); errors.Is(err, io.EOF) {
+			logger.Info("Claimed L2 block was not derived", "head", pipeline.Head(), "claimed", boot.L2ClaimBlockNumber)
+			return nil, fmt.Errorf("%w: block %d not derived by L1 block %s", ErrInvalidClaim, boot.L2ClaimBlockNumber, boot.L1Head)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	output, err := engine.output(pipeline.Head().Hash)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Derived claimed L2 block", "block", pipeline.Head(), "output", output.OutputRoot)
+	if output.OutputRoot != boot.L2Claim {
+		return output, fmt.Errorf("%w: output root %s, claimed %s", ErrInvalidClaim, output.OutputRoot, boot.L2Claim)
+	}
+	return output, nil
+}
+
+// output computes the output of the block with the given hash.
+func (e *Engine) output(hash common.Hash) (*rollup.Output, error) {
+	block, err := e.Block(hash)
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := e.State(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state of %s: %w", hash, err)
+	}
+	storageRoot := types.EmptyRootHash
+	if tr := statedb.StorageTrie(rollup.L2ToL1MessagePasserAddr); tr != nil {
+		storageRoot = tr.Hash()
+	}
+	return rollup.NewOutputV0(block.Root(), storageRoot, hash), nil
+}
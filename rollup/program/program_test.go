@@ -0,0 +1,322 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package program
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/genesis"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+)
+
+var (
+	testBatcherKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testUserKey, _    = crypto.HexToECDSA("49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee")
+	testDeposit       = common.HexToAddress("0xde90517000000000000000000000000000000001")
+)
+
+// l1Oracle serves the blocks of an in-memory L1 chain.
+type l1Oracle struct {
+	chain *testutils.L1Chain
+}
+
+func (o *l1Oracle) HeaderByHash(hash common.Hash) (*types.Header, error) {
+	block, err := o.chain.BlockByHash(context.Background(), hash)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (o *l1Oracle) TransactionsByHash(hash common.Hash) (types.Transactions, error) {
+	block, err := o.chain.BlockByHash(context.Background(), hash)
+	if err != nil {
+		return nil, err
+	}
+	return block.Transactions(), nil
+}
+
+func (o *l1Oracle) ReceiptsByHash(hash common.Hash) (types.Receipts, error) {
+	return o.chain.Receipts(context.Background(), hash)
+}
+
+// l2Oracle serves the L2 genesis block and its state.
+type l2Oracle struct {
+	genesis *types.Block
+	db      ethdb.Database
+}
+
+func (o *l2Oracle) BlockByHash(hash common.Hash) (*types.Block, error) {
+	if hash != o.genesis.Hash() {
+		return nil, ethereum.NotFound
+	}
+	return o.genesis, nil
+}
+
+func (o *l2Oracle) NodeByHash(hash common.Hash) ([]byte, error) {
+	return o.db.Get(hash[:])
+}
+
+func (o *l2Oracle) CodeByHash(hash common.Hash) ([]byte, error) {
+	if code := rawdb.ReadCode(o.db, hash); len(code) > 0 {
+		return code, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// testSetup is an L1 chain with an L2 chain on top, whose genesis state holds
+// the predeploys and a funded user account.
+type testSetup struct {
+	l1      *testutils.L1Chain
+	gspec   *core.Genesis
+	genesis *types.Block
+	cfg     *rollup.Config
+	l2      *l2Oracle
+	nonce   uint64
+}
+
+func newTestSetup(t *testing.T) *testSetup {
+	l1 := testutils.NewL1Chain()
+	deployCfg := &genesis.DeployConfig{
+		L1ChainID:              900,
+		L2ChainID:              901,
+		L2BlockTime:            2,
+		MaxSequencerDrift:      600,
+		SequencerWindowSize:    10,
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     crypto.PubkeyToAddress(testBatcherKey.PublicKey),
+		DepositContractAddress: testDeposit,
+		L2GenesisGasLimit:      30_000_000,
+		GasPriceOracleOverhead: 2100,
+		GasPriceOracleScalar:   1_000_000,
+		GasPriceOracleDecimals: 6,
+		PredeployCode:          map[string]hexutil.Bytes{"L1Block": {0x60, 0x02}},
+		FundedAccounts: core.GenesisAlloc{
+			crypto.PubkeyToAddress(testUserKey.PublicKey): {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	gspec, err := genesis.BuildL2Genesis(deployCfg, l1.Head().Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	l2Genesis := gspec.MustCommit(db)
+	return &testSetup{
+		l1:      l1,
+		gspec:   gspec,
+		genesis: l2Genesis,
+		cfg:     genesis.BuildRollupConfig(deployCfg, l1.Head().Header(), l2Genesis),
+		l2:      &l2Oracle{genesis: l2Genesis, db: db},
+	}
+}
+
+// addBatch adds an L1 block that posts the batch of the next L2 block.
+func (s *testSetup) addBatch(t *testing.T, batch *derive.BatchData) {
+	t.Helper()
+	data, err := derive.EncodeBatches([]*derive.BatchData{batch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignNewTx(testBatcherKey, types.LatestSignerForChainID(s.cfg.L1ChainID), &types.DynamicFeeTx{
+		ChainID:   s.cfg.L1ChainID,
+		Nonce:     s.nonce,
+		To:        &s.cfg.BatchInboxAddress,
+		Gas:       1_000_000,
+		GasFeeCap: big.NewInt(10),
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nonce++
+	s.l1.AddBlock(tx)
+}
+
+// userTx creates a signed L2 value transfer.
+func userTx(t *testing.T, nonce uint64) hexutil.Bytes {
+	t.Helper()
+	to := common.HexToAddress("0x1234")
+	tx, err := types.SignNewTx(testUserKey, types.LatestSignerForChainID(big.NewInt(901)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(901),
+		Nonce:     nonce,
+		To:        &to,
+		Gas:       21000,
+		GasFeeCap: big.NewInt(2 * params.GWei),
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := tx.MarshalBinary()
+	return enc
+}
+
+// buildChain derives L2 blocks with user transactions and a deposit from a new
+// L1 chain, like a rollup node would, and returns them.
+func (s *testSetup) buildChain(t *testing.T) []*types.Block {
+	engine := NewEngine(s.gspec.Config, s.l2)
+	p := derive.NewPipeline(s.cfg, derive.Confirmations{}, s.l1, engine, s.cfg.L2GenesisRef(), log.New())
+	step := func() {
+		t.Helper()
+		for {
+			if err := p.Step(context.Background()); err == io.EOF {
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var (
+		to       = common.HexToAddress("0x5678")
+		dep      = &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Mint: big.NewInt(1000), Value: big.NewInt(10), Gas: 50_000, Data: []byte{}}
+		depLogTx = types.MustSignNewTx(testUserKey, types.LatestSignerForChainID(s.cfg.L1ChainID), &types.LegacyTx{To: &testDeposit, Gas: 100_000, GasPrice: big.NewInt(10)})
+		epoch1   = s.l1.AddBlockWithLogs([]*types.Transaction{depLogTx}, [][]*types.Log{{derive.MarshalDepositLogEvent(testDeposit, dep)}})
+	)
+	batches := []*derive.BatchData{
+		{EpochNum: 0, EpochHash: s.cfg.Genesis.L1.Hash, Timestamp: s.cfg.Genesis.L2Time + 2, Transactions: []hexutil.Bytes{userTx(t, 0)}},
+		{EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time(), Transactions: []hexutil.Bytes{userTx(t, 1)}},
+		{EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time() + 2, Transactions: []hexutil.Bytes{userTx(t, 2)}},
+	}
+	blocks := make([]*types.Block, len(batches))
+	for i, batch := range batches {
+		batch.ParentHash = p.Head().Hash
+		s.addBatch(t, batch)
+		step()
+		if p.Head().Number != uint64(i+1) {
+			t.Fatalf("derived head %d, want %d", p.Head().Number, i+1)
+		}
+		block, err := engine.Block(p.Head().Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks[i] = block
+	}
+	return blocks
+}
+
+func TestRun(t *testing.T) {
+	s := newTestSetup(t)
+	blocks := s.buildChain(t)
+
+	// The stateless engine must build the same blocks as geth.
+	db := rawdb.NewMemoryDatabase()
+	s.gspec.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, s.gspec.Config, beacon.New(ethash.NewFaker()), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("geth rejected the derived blocks: %v", err)
+	}
+	head := blocks[len(blocks)-1]
+	statedb, err := chain.StateAt(head.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance := statedb.GetBalance(common.HexToAddress("0x5678")); balance.Cmp(big.NewInt(10)) != 0 {
+		t.Fatalf("deposit not executed, balance %v", balance)
+	}
+	want := rollup.NewOutputV0(head.Root(), types.EmptyRootHash, head.Hash())
+
+	boot := &BootInfo{
+		Rollup:             s.cfg,
+		L2Chain:            s.gspec.Config,
+		L1Head:             s.l1.Head().Hash(),
+		L2Head:             s.genesis.Hash(),
+		L2ClaimBlockNumber: head.NumberU64(),
+		L2Claim:            want.OutputRoot,
+	}
+	output, err := Run(context.Background(), boot, &l1Oracle{s.l1}, s.l2, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *output != *want {
+		t.Fatalf("output mismatch: got %+v, want %+v", output, want)
+	}
+
+	// A wrong output root is rejected.
+	boot.L2Claim = common.HexToHash("0x01")
+	if _, err := Run(context.Background(), boot, &l1Oracle{s.l1}, s.l2, log.New()); !errors.Is(err, ErrInvalidClaim) {
+		t.Fatalf("wrong output root accepted: %v", err)
+	}
+
+	// A block that is not derived by the L1 head is rejected.
+	boot.L2Claim = want.OutputRoot
+	boot.L1Head = s.l1.Block(2).Hash()
+	if _, err := Run(context.Background(), boot, &l1Oracle{s.l1}, s.l2, log.New()); !errors.Is(err, ErrInvalidClaim) {
+		t.Fatalf("claim after the L1 head accepted: %v", err)
+	}
+}
+
+// lyingL1Oracle serves a forged header in place of the L1 head.
+type lyingL1Oracle struct {
+	l1Oracle
+	head common.Hash
+}
+
+func (o *lyingL1Oracle) HeaderByHash(hash common.Hash) (*types.Header, error) {
+	header, err := o.l1Oracle.HeaderByHash(hash)
+	if err == nil && hash == o.head {
+		header = types.CopyHeader(header)
+		header.Extra = []byte("forged")
+	}
+	return header, err
+}
+
+func TestRunChecksPreimages(t *testing.T) {
+	s := newTestSetup(t)
+	s.l1.AddBlock()
+	boot := &BootInfo{
+		Rollup:  s.cfg,
+		L2Chain: s.gspec.Config,
+		L1Head:  s.l1.Head().Hash(),
+		L2Head:  s.genesis.Hash(),
+	}
+	oracle := &lyingL1Oracle{l1Oracle{s.l1}, s.l1.Head().Hash()}
+	if _, err := Run(context.Background(), boot, oracle, s.l2, log.New()); err == nil {
+		t.Fatal("forged L1 header accepted")
+	}
+
+	// State that does not match its hash is rejected.
+	key := s.genesis.Root()
+	s.l2.db.Put(key[:], []byte("forged"))
+	db := &oracleDB{KeyValueStore: memorydb.New(), oracle: s.l2}
+	if _, err := db.Get(key[:]); err == nil {
+		t.Fatal("forged state trie node accepted")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package proposer implements the output submitter, which proposes the output
+// roots of safe L2 blocks to the output oracle on L1.
+package proposer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+	"github.com/ethereum/go-ethereum/rollup/internal/poll"
+	"github.com/ethereum/go-ethereum/rollup/txmgr"
+)
+
+// Config contains the settings of the output submitter.
+type Config struct {
+	L1ChainID           *big.Int
+	OutputOracleAddress common.Address
+
+	// SubmissionInterval is the number of L2 blocks between two outputs.
+	SubmissionInterval uint64
+	// ResubmitTimeout is the time after which a proposal that is not included
+	// yet is replaced by one with higher fees.
+	ResubmitTimeout time.Duration
+	// MaxGasPrice is the highest fee cap the submitter is willing to pay. When
+	// L1 is more expensive, the proposal is postponed. Nil means no limit.
+	MaxGasPrice *big.Int
+	// PollInterval is the interval at which the rollup node and pending
+	// proposals are polled. Failures back off up to MaxRetryInterval.
+	PollInterval     time.Duration
+	MaxRetryInterval time.Duration
+}
+
+// DefaultConfig contains reasonable default settings.
+var DefaultConfig = Config{
+	SubmissionInterval: 1800,
+	ResubmitTimeout:    3 * time.Minute,
+	PollInterval:       6 * time.Second,
+	MaxRetryInterval:   time.Minute,
+}
+
+// L1Client is the L1 API used by the output submitter. It is implemented by
+// ethclient.Client.
+type L1Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	CallContract(ctx context.Context, call ethereum.CallMsg, number *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// RollupClient is the rollup node API used by the output submitter. It is
+// implemented by node.Client.
+type RollupClient interface {
+	SyncStatus(ctx context.Context) (*rollup.SyncStatus, error)
+	OutputAtBlock(ctx context.Context, number uint64) (*rollup.Output, error)
+}
+
+// proposal is an output proposal that was sent, but is not included yet. It
+// keeps all transactions sent for the proposal, as any of them may be included
+// after a resubmission.
+type proposal struct {
+	txs    []*types.Transaction // oldest first
+	number uint64               // L2 block of the output
+	sent   time.Time            // time the last transaction was sent
+}
+
+// Submitter proposes the output of every SubmissionInterval-th L2 block to the
+// output oracle, once the block is safe.
+//
+// The submitter keeps no state of its own: the next output to propose follows
+// from the latest output of the oracle, so a restarted submitter continues
+// where it stopped.
+type Submitter struct {
+	cfg   Config
+	l1    L1Client
+	node  RollupClient
+	txmgr *txmgr.Manager
+	log   log.Logger
+	now   func() time.Time
+
+	inflight *proposal
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates an output submitter.
+func New(cfg Config, l1 L1Client, node RollupClient, key *ecdsa.PrivateKey, logger log.Logger) (*Submitter, error) {
+	if cfg.SubmissionInterval == 0 {
+		return nil, errors.New("submission interval must be positive")
+	}
+	return &Submitter{
+		cfg:   cfg,
+		l1:    l1,
+		node:  node,
+		txmgr: txmgr.New(txmgr.Config{ChainID: cfg.L1ChainID, MaxGasPrice: cfg.MaxGasPrice}, l1, key, logger),
+		log:   logger,
+		now:   time.Now,
+		quit:  make(chan struct{}),
+	}, nil
+}
+
+// Start starts submitting outputs in the background.
+func (s *Submitter) Start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop stops the submitter and waits for it to shut down.
+func (s *Submitter) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func (s *Submitter) loop() {
+	defer s.wg.Done()
+	poll.Loop(s.quit, s.cfg.PollInterval, s.cfg.MaxRetryInterval, s.Step, s.log, "Output submission failed")
+}
+
+// Step checks the pending proposal and proposes the next output once its L2
+// block is safe.
+func (s *Submitter) Step(ctx context.Context) error {
+	if s.inflight != nil {
+		return s.checkInflight(ctx)
+	}
+	latest, err := s.latestBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	next := latest + s.cfg.SubmissionInterval
+	status, err := s.node.SyncStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch sync status: %w", err)
+	}
+	if status.SafeL2.Number < next {
+		return nil
+	}
+	output, err := s.node.OutputAtBlock(ctx, next)
+	if err != nil {
+		return fmt.Errorf("failed to fetch output at L2 block %d: %w", next, err)
+	}
+	// The oracle rejects the proposal if the L1 block it was derived from was
+	// reorged out in the meantime.
+	data, err := bridge.PackProposeL2Output(output.OutputRoot, next, status.CurrentL1.Hash, status.CurrentL1.Number)
+	if err != nil {
+		return err
+	}
+	nonce, err := s.l1.PendingNonceAt(ctx, s.txmgr.From())
+	if err != nil {
+		return fmt.Errorf("failed to fetch nonce: %w", err)
+	}
+	tx, err := s.send(ctx, nonce, data, nil)
+	if err != nil || tx == nil {
+		return err
+	}
+	s.inflight = &proposal{txs: []*types.Transaction{tx}, number: next, sent: s.now()}
+	s.log.Info("Proposed output", "l2block", next, "output", output.OutputRoot, "l1origin", status.CurrentL1, "hash", tx.Hash(), "nonce", nonce)
+	return nil
+}
+
+// checkInflight checks whether the pending proposal was included. Proposals
+// that take too long are replaced with higher fees, reusing their nonce.
+func (s *Submitter) checkInflight(ctx context.Context) error {
+	p := s.inflight
+	receipt, err := s.txmgr.Receipt(ctx, p.txs)
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		s.inflight = nil
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			// The next step proposes the output again, if the oracle still
+			// expects it.
+			s.log.Warn("Output proposal failed", "l2block", p.number, "hash", receipt.TxHash, "l1block", receipt.BlockNumber)
+			return nil
+		}
+		s.log.Info("Output proposal included", "l2block", p.number, "hash", receipt.TxHash, "l1block", receipt.BlockNumber)
+		return nil
+	}
+	if s.now().Sub(p.sent) < s.cfg.ResubmitTimeout {
+		return nil
+	}
+	last := p.txs[len(p.txs)-1]
+	tx, err := s.send(ctx, last.Nonce(), last.Data(), last)
+	if err != nil || tx == nil {
+		return err
+	}
+	p.txs = append(p.txs, tx)
+	p.sent = s.now()
+	s.log.Warn("Output proposal not included, resubmitted", "l2block", p.number, "hash", tx.Hash(), "feecap", tx.GasFeeCap())
+	return nil
+}
+
+// latestBlockNumber returns the L2 block of the latest output in the oracle.
+func (s *Submitter) latestBlockNumber(ctx context.Context) (uint64, error) {
+	data, err := bridge.PackLatestBlockNumber()
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.l1.CallContract(ctx, ethereum.CallMsg{To: &s.cfg.OutputOracleAddress, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch latest output: %w", err)
+	}
+	return bridge.UnpackLatestBlockNumber(res)
+}
+
+// send signs and sends a proposal with the given nonce and call data. If it
+// replaces a previous transaction, the fees are raised enough for the pool to
+// accept the replacement. It returns nil if L1 is too expensive.
+func (s *Submitter) send(ctx context.Context, nonce uint64, data []byte, replaced *types.Transaction) (*types.Transaction, error) {
+	tip, feeCap, err := s.txmgr.Fees(ctx, replaced)
+	if err != nil || tip == nil {
+		return nil, err
+	}
+	gas, err := s.txmgr.EstimateGas(ctx, s.cfg.OutputOracleAddress, data, tip, feeCap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate proposal gas: %w", err)
+	}
+	tx, err := s.txmgr.Send(ctx, nonce, s.cfg.OutputOracleAddress, gas, data, tip, feeCap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send proposal: %w", err)
+	}
+	return tx, nil
+}
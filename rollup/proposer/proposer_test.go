@@ -0,0 +1,230 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package proposer
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// testL1 is an L1 chain with an output oracle.
+type testL1 struct {
+	latest   uint64 // L2 block of the latest output in the oracle
+	tip      *big.Int
+	sent     []*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+}
+
+func newTestL1() *testL1 {
+	return &testL1{tip: big.NewInt(1), receipts: make(map[common.Hash]*types.Receipt)}
+}
+
+func (l *testL1) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(10)}, nil
+}
+
+func (l *testL1) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return l.tip, nil
+}
+
+func (l *testL1) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return uint64(len(l.receipts)), nil
+}
+
+func (l *testL1) CallContract(ctx context.Context, call ethereum.CallMsg, number *big.Int) ([]byte, error) {
+	return bridge.L2OutputOracleABI.Methods["latestBlockNumber"].Outputs.Pack(new(big.Int).SetUint64(l.latest))
+}
+
+func (l *testL1) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 100_000, nil
+}
+
+func (l *testL1) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	l.sent = append(l.sent, tx)
+	return nil
+}
+
+func (l *testL1) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := l.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// include includes the given proposal, which updates the oracle if it succeeds.
+func (l *testL1) include(t *testing.T, tx *types.Transaction, status uint64) {
+	t.Helper()
+	l.receipts[tx.Hash()] = &types.Receipt{TxHash: tx.Hash(), Status: status, BlockNumber: big.NewInt(101)}
+	if status == types.ReceiptStatusSuccessful {
+		args, err := bridge.L2OutputOracleABI.Methods["proposeL2Output"].Inputs.Unpack(tx.Data()[4:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.latest = args[1].(*big.Int).Uint64()
+	}
+}
+
+type testNode struct {
+	status rollup.SyncStatus
+}
+
+func (n *testNode) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	return &n.status, nil
+}
+
+func (n *testNode) OutputAtBlock(ctx context.Context, number uint64) (*rollup.Output, error) {
+	return rollup.NewOutputV0(common.BigToHash(new(big.Int).SetUint64(number)), common.Hash{}, common.Hash{}), nil
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestSubmitter(t *testing.T, l1 *testL1, node *testNode, clock *testClock) *Submitter {
+	t.Helper()
+	cfg := DefaultConfig
+	cfg.L1ChainID = big.NewInt(900)
+	cfg.OutputOracleAddress = common.HexToAddress("0x0000000000000000000000000000000000000abc")
+	cfg.SubmissionInterval = 10
+	s, err := New(cfg, l1, node, testKey, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = clock.Now
+	return s
+}
+
+func TestSubmitterProposesSafeOutputs(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		l1    = newTestL1()
+		node  = &testNode{}
+		clock = &testClock{now: time.Unix(10000, 0)}
+		s     = newTestSubmitter(t, l1, node, clock)
+	)
+	// The next output is not safe yet.
+	node.status.SafeL2.Number = 9
+	node.status.UnsafeL2.Number = 20
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 0 {
+		t.Fatal("proposed output of unsafe block")
+	}
+	node.status.SafeL2.Number = 12
+	node.status.CurrentL1 = rollup.L1BlockRef{Hash: common.HexToHash("0x11"), Number: 99}
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 1 {
+		t.Fatalf("sent %d proposals, want 1", len(l1.sent))
+	}
+	args, err := bridge.L2OutputOracleABI.Methods["proposeL2Output"].Inputs.Unpack(l1.sent[0].Data()[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, _ := node.OutputAtBlock(ctx, 10)
+	if args[0].([32]byte) != output.OutputRoot || args[1].(*big.Int).Uint64() != 10 {
+		t.Fatalf("unexpected proposal of output %x at block %v", args[0], args[1])
+	}
+	if args[2].([32]byte) != node.status.CurrentL1.Hash || args[3].(*big.Int).Uint64() != 99 {
+		t.Fatalf("unexpected L1 reference %x at block %v", args[2], args[3])
+	}
+	// Nothing else is sent while the proposal is pending.
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 1 {
+		t.Fatal("proposed again while pending")
+	}
+	// A failed proposal is retried.
+	l1.include(t, l1.sent[0], types.ReceiptStatusFailed)
+	for i := 0; i < 2; i++ {
+		if err := s.Step(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(l1.sent) != 2 || l1.sent[1].Nonce() != 1 {
+		t.Fatalf("failed proposal not retried, sent %d", len(l1.sent))
+	}
+	// Once included, the next output is due after the interval.
+	l1.include(t, l1.sent[1], types.ReceiptStatusSuccessful)
+	for i := 0; i < 2; i++ {
+		if err := s.Step(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.inflight != nil || len(l1.sent) != 2 {
+		t.Fatal("proposed output before the next interval")
+	}
+}
+
+func TestSubmitterResubmit(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		l1    = newTestL1()
+		node  = &testNode{}
+		clock = &testClock{now: time.Unix(10000, 0)}
+		s     = newTestSubmitter(t, l1, node, clock)
+	)
+	node.status.SafeL2.Number = 10
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The proposal is replaced after the timeout, with the same nonce and
+	// enough of a fee bump to replace it in the pool.
+	clock.now = clock.now.Add(s.cfg.ResubmitTimeout - time.Second)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(time.Second)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(l1.sent) != 2 {
+		t.Fatalf("sent %d proposals, want 2", len(l1.sent))
+	}
+	old, replacement := l1.sent[0], l1.sent[1]
+	if replacement.Nonce() != old.Nonce() {
+		t.Fatalf("replacement nonce mismatch: have %d, want %d", replacement.Nonce(), old.Nonce())
+	}
+	minTip := new(big.Int).Div(new(big.Int).Mul(old.GasTipCap(), big.NewInt(110)), big.NewInt(100))
+	minFeeCap := new(big.Int).Div(new(big.Int).Mul(old.GasFeeCap(), big.NewInt(110)), big.NewInt(100))
+	if replacement.GasTipCap().Cmp(minTip) < 0 || replacement.GasFeeCap().Cmp(minFeeCap) < 0 {
+		t.Fatalf("fees not bumped: tip %v -> %v, fee cap %v -> %v", old.GasTipCap(), replacement.GasTipCap(), old.GasFeeCap(), replacement.GasFeeCap())
+	}
+	// The original transaction may still be the one that is included.
+	l1.include(t, old, types.ReceiptStatusSuccessful)
+	if err := s.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.inflight != nil || l1.latest != 10 {
+		t.Fatal("inclusion of the replaced proposal not detected")
+	}
+}
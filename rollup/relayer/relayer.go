@@ -0,0 +1,495 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package relayer implements the message relayer, which finalizes the messages
+// sent from L2 to L1 on L1 once they can be proven against a finalized output.
+//
+// Messages from L1 to L2 need no relayer: they are deposits, which the rollup
+// node derives into L2 blocks by itself.
+package relayer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+	"github.com/ethereum/go-ethereum/rollup/internal/poll"
+	"github.com/ethereum/go-ethereum/rollup/txmgr"
+)
+
+// Config contains the settings of the relayer.
+type Config struct {
+	L1ChainID           *big.Int
+	PortalAddress       common.Address
+	OutputOracleAddress common.Address
+
+	// StateFile is where the relayed messages are persisted.
+	StateFile string
+	// L1StartBlock is the L1 block from which output proposals are fetched, and
+	// L2StartBlock the L2 block from which messages are relayed. L2StartBlock
+	// is only used if there is no state file yet.
+	L1StartBlock uint64
+	L2StartBlock uint64
+	// FinalizationPeriod is the time after its proposal that an output is
+	// final, and messages can be proven against it.
+	FinalizationPeriod time.Duration
+	// MaxBlockRange is the largest range of blocks whose logs are fetched at
+	// once.
+	MaxBlockRange uint64
+	// ResubmitTimeout is the time after which a relay transaction that is not
+	// included yet is replaced by one with higher fees.
+	ResubmitTimeout time.Duration
+	// MaxGasPrice is the highest fee cap the relayer is willing to pay. When
+	// L1 is more expensive, relaying is postponed. Nil means no limit.
+	MaxGasPrice *big.Int
+	// PollInterval is the interval at which L1, L2 and the rollup node are
+	// polled. Failures back off up to MaxRetryInterval.
+	PollInterval     time.Duration
+	MaxRetryInterval time.Duration
+}
+
+// DefaultConfig contains reasonable default settings.
+var DefaultConfig = Config{
+	FinalizationPeriod: 7 * 24 * time.Hour,
+	MaxBlockRange:      1000,
+	ResubmitTimeout:    3 * time.Minute,
+	PollInterval:       12 * time.Second,
+	MaxRetryInterval:   time.Minute,
+}
+
+// L1Client is the L1 API used by the relayer. It is implemented by
+// ethclient.Client.
+type L1Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	CallContract(ctx context.Context, call ethereum.CallMsg, number *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// L2Client is the L2 API used by the relayer. It is implemented by
+// ethclient.Client.
+type L2Client interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// RollupClient is the rollup node API used by the relayer. It is implemented by
+// node.Client.
+type RollupClient interface {
+	SyncStatus(ctx context.Context) (*rollup.SyncStatus, error)
+	OutputProofAtBlock(ctx context.Context, number uint64, keys []common.Hash) (*rollup.OutputProof, error)
+}
+
+// Message is a message sent from L2 to L1 through the message passer.
+type Message struct {
+	Withdrawal rollup.Withdrawal
+	Hash       common.Hash // hash of the withdrawal
+	L2Block    uint64      // L2 block the message was sent in
+	TxHash     common.Hash // transaction that sent the message
+}
+
+// relay is a relay transaction that was sent, but is not included yet. It keeps
+// all transactions sent for the message, as any of them may be included after a
+// resubmission.
+type relay struct {
+	txs  []*types.Transaction // oldest first
+	msg  *Message
+	sent time.Time // time the last transaction was sent
+}
+
+// Relayer finalizes the messages sent from L2 to L1. Messages are picked up
+// from safe L2 blocks, and relayed one at a time, oldest first, once an output
+// at or after their L2 block is final.
+//
+// The relayed messages are persisted, along with the first L2 block that has
+// messages which are not relayed yet, so a restarted relayer continues where it
+// stopped. Messages that were relayed by someone else are skipped.
+type Relayer struct {
+	cfg   Config
+	l1    L1Client
+	l2    L2Client
+	node  RollupClient
+	txmgr *txmgr.Manager
+	log   log.Logger
+	now   func() time.Time
+
+	state     *relayState
+	l2Next    uint64                   // next L2 block whose messages are fetched
+	l1Next    uint64                   // next L1 block whose proposals are fetched
+	pending   []*Message               // messages that are not relayed yet, by L2 block
+	proposals []*bridge.OutputProposal // proposals at or after the first pending message, by L2 block
+	inflight  *relay
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a relayer. The relayed messages are loaded from the state file.
+func New(cfg Config, l1 L1Client, l2 L2Client, node RollupClient, key *ecdsa.PrivateKey, logger log.Logger) (*Relayer, error) {
+	if cfg.StateFile == "" {
+		return nil, errors.New("state file must be set")
+	}
+	if cfg.MaxBlockRange == 0 {
+		cfg.MaxBlockRange = DefaultConfig.MaxBlockRange
+	}
+	state, err := loadState(cfg.StateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load relayer state: %w", err)
+	}
+	if state == nil {
+		state = &relayState{Next: cfg.L2StartBlock, Relayed: make(map[common.Hash]uint64)}
+	}
+	return &Relayer{
+		cfg:    cfg,
+		l1:     l1,
+		l2:     l2,
+		node:   node,
+		txmgr:  txmgr.New(txmgr.Config{ChainID: cfg.L1ChainID, MaxGasPrice: cfg.MaxGasPrice}, l1, key, logger),
+		log:    logger,
+		now:    time.Now,
+		state:  state,
+		l2Next: state.Next,
+		l1Next: cfg.L1StartBlock,
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Start starts relaying messages in the background.
+func (r *Relayer) Start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// Stop stops the relayer and waits for it to shut down.
+func (r *Relayer) Stop() {
+	close(r.quit)
+	r.wg.Wait()
+}
+
+func (r *Relayer) loop() {
+	defer r.wg.Done()
+	poll.Loop(r.quit, r.cfg.PollInterval, r.cfg.MaxRetryInterval, r.Step, r.log, "Relaying messages failed")
+}
+
+// Step checks the pending relay transaction, fetches the new messages and
+// proposals, and relays the oldest message whose output is final.
+func (r *Relayer) Step(ctx context.Context) error {
+	if r.inflight != nil {
+		return r.checkInflight(ctx)
+	}
+	if err := r.fetchMessages(ctx); err != nil {
+		return err
+	}
+	if len(r.pending) == 0 {
+		return nil
+	}
+	head, err := r.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	if err := r.fetchProposals(ctx, head.Number.Uint64()); err != nil {
+		return err
+	}
+	for _, msg := range append([]*Message(nil), r.pending...) {
+		p := r.proposalOf(msg.L2Block)
+		if p == nil || head.Time < p.L1Timestamp+uint64(r.cfg.FinalizationPeriod/time.Second) {
+			// The outputs of later messages are not final either.
+			return nil
+		}
+		sent, err := r.relay(ctx, msg, p)
+		if err != nil || sent {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchMessages adds the messages of the safe L2 blocks that were not fetched
+// yet to the pending messages.
+func (r *Relayer) fetchMessages(ctx context.Context) error {
+	status, err := r.node.SyncStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch sync status: %w", err)
+	}
+	safe := status.SafeL2.Number
+	if r.l2Next > safe {
+		return nil
+	}
+	for r.l2Next <= safe {
+		to := r.l2Next + r.cfg.MaxBlockRange - 1
+		if to > safe {
+			to = safe
+		}
+		logs, err := r.l2.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(r.l2Next),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{rollup.L2ToL1MessagePasserAddr},
+			Topics:    [][]common.Hash{{bridge.MessagePasserABI.Events["MessagePassed"].ID}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch messages of L2 blocks %d-%d: %w", r.l2Next, to, err)
+		}
+		for _, l := range logs {
+			msg, err := UnmarshalMessage(&l)
+			if err != nil {
+				r.log.Warn("Ignoring malformed message", "l2block", l.BlockNumber, "tx", l.TxHash, "err", err)
+				continue
+			}
+			if _, ok := r.state.Relayed[msg.Hash]; ok {
+				continue
+			}
+			r.log.Debug("Found message", "hash", msg.Hash, "l2block", msg.L2Block, "target", msg.Withdrawal.Target)
+			r.pending = append(r.pending, msg)
+		}
+		r.l2Next = to + 1
+	}
+	pendingGauge.Update(int64(len(r.pending)))
+	return r.saveState()
+}
+
+// fetchProposals adds the proposals of the L1 blocks up to the given one that
+// were not fetched yet, and drops the proposals before the pending messages.
+func (r *Relayer) fetchProposals(ctx context.Context, head uint64) error {
+	for r.l1Next <= head {
+		to := r.l1Next + r.cfg.MaxBlockRange - 1
+		if to > head {
+			to = head
+		}
+		logs, err := r.l1.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(r.l1Next),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{r.cfg.OutputOracleAddress},
+			Topics:    [][]common.Hash{{bridge.L2OutputOracleABI.Events["OutputProposed"].ID}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch proposals of L1 blocks %d-%d: %w", r.l1Next, to, err)
+		}
+		for _, l := range logs {
+			p, err := bridge.UnmarshalOutputProposed(&l)
+			if err != nil {
+				r.log.Warn("Ignoring malformed proposal", "l1block", l.BlockNumber, "tx", l.TxHash, "err", err)
+				continue
+			}
+			r.proposals = append(r.proposals, p)
+		}
+		r.l1Next = to + 1
+	}
+	sort.SliceStable(r.proposals, func(i, j int) bool {
+		return r.proposals[i].L2BlockNumber < r.proposals[j].L2BlockNumber
+	})
+	first := r.l2Next
+	if len(r.pending) > 0 {
+		first = r.pending[0].L2Block
+	}
+	for len(r.proposals) > 0 && r.proposals[0].L2BlockNumber < first {
+		r.proposals = r.proposals[1:]
+	}
+	return nil
+}
+
+// proposalOf returns the first proposal at or after the given L2 block, nil if
+// there is none yet.
+func (r *Relayer) proposalOf(l2Block uint64) *bridge.OutputProposal {
+	i := sort.Search(len(r.proposals), func(i int) bool {
+		return r.proposals[i].L2BlockNumber >= l2Block
+	})
+	if i == len(r.proposals) {
+		return nil
+	}
+	return r.proposals[i]
+}
+
+// relay sends the transaction that finalizes a message, proven against the
+// output of the given proposal. It reports whether a transaction was sent.
+// Messages that cannot be relayed are skipped until the next step.
+func (r *Relayer) relay(ctx context.Context, msg *Message, p *bridge.OutputProposal) (bool, error) {
+	finalized, err := r.finalized(ctx, msg.Hash)
+	if err != nil {
+		return false, err
+	}
+	if finalized {
+		r.log.Info("Message was relayed by someone else", "hash", msg.Hash, "l2block", msg.L2Block)
+		return false, r.markRelayed(msg)
+	}
+	proof, err := bridge.ProveWithdrawal(ctx, r.node, &msg.Withdrawal, p.L2BlockNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to prove message %s: %w", msg.Hash, err)
+	}
+	if proof.OutputRoot != p.OutputRoot {
+		r.log.Error("Proposed output differs from rollup node", "l2block", p.L2BlockNumber, "proposed", p.OutputRoot, "node", proof.OutputRoot)
+		return false, nil
+	}
+	data, err := bridge.PackFinalizeWithdrawal(&msg.Withdrawal, p.L2BlockNumber, proof)
+	if err != nil {
+		return false, err
+	}
+	nonce, err := r.l1.PendingNonceAt(ctx, r.txmgr.From())
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch nonce: %w", err)
+	}
+	tx, err := r.send(ctx, nonce, data, nil)
+	if errors.Is(err, errRevert) {
+		r.log.Warn("Message cannot be relayed", "hash", msg.Hash, "l2block", msg.L2Block, "output", p.L2BlockNumber, "err", err)
+		return false, nil
+	}
+	if err != nil || tx == nil {
+		return false, err
+	}
+	r.inflight = &relay{txs: []*types.Transaction{tx}, msg: msg, sent: r.now()}
+	r.log.Info("Relayed message", "hash", msg.Hash, "l2block", msg.L2Block, "output", p.L2BlockNumber, "tx", tx.Hash(), "nonce", nonce)
+	return true, nil
+}
+
+// checkInflight checks whether the pending relay transaction was included.
+// Transactions that take too long are replaced with higher fees, reusing their
+// nonce.
+func (r *Relayer) checkInflight(ctx context.Context) error {
+	rl := r.inflight
+	receipt, err := r.txmgr.Receipt(ctx, rl.txs)
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		r.inflight = nil
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			// The message stays pending, and is relayed again if the portal
+			// still accepts it.
+			failedMeter.Mark(1)
+			r.log.Warn("Relay transaction failed", "hash", rl.msg.Hash, "tx", receipt.TxHash, "l1block", receipt.BlockNumber)
+			return nil
+		}
+		relayedMeter.Mark(1)
+		r.log.Info("Relay transaction included", "hash", rl.msg.Hash, "tx", receipt.TxHash, "l1block", receipt.BlockNumber)
+		return r.markRelayed(rl.msg)
+	}
+	if r.now().Sub(rl.sent) < r.cfg.ResubmitTimeout {
+		return nil
+	}
+	last := rl.txs[len(rl.txs)-1]
+	tx, err := r.send(ctx, last.Nonce(), last.Data(), last)
+	if err != nil || tx == nil {
+		return err
+	}
+	rl.txs = append(rl.txs, tx)
+	rl.sent = r.now()
+	r.log.Warn("Relay transaction not included, resubmitted", "hash", rl.msg.Hash, "tx", tx.Hash(), "feecap", tx.GasFeeCap())
+	return nil
+}
+
+// markRelayed removes a message from the pending messages, and persists that it
+// was relayed.
+func (r *Relayer) markRelayed(msg *Message) error {
+	for i, m := range r.pending {
+		if m == msg {
+			r.pending = append(r.pending[:i:i], r.pending[i+1:]...)
+			break
+		}
+	}
+	pendingGauge.Update(int64(len(r.pending)))
+	r.state.Relayed[msg.Hash] = msg.L2Block
+	return r.saveState()
+}
+
+// saveState persists the relayed messages at or after the first pending message.
+// All messages before it were relayed.
+func (r *Relayer) saveState() error {
+	next := r.l2Next
+	if len(r.pending) > 0 {
+		next = r.pending[0].L2Block
+	}
+	r.state.Next = next
+	for hash, number := range r.state.Relayed {
+		if number < next {
+			delete(r.state.Relayed, hash)
+		}
+	}
+	if err := saveState(r.cfg.StateFile, r.state); err != nil {
+		return fmt.Errorf("failed to save relayer state: %w", err)
+	}
+	return nil
+}
+
+// finalized reports whether the portal has finalized the message with the given
+// hash.
+func (r *Relayer) finalized(ctx context.Context, hash common.Hash) (bool, error) {
+	data, err := bridge.PackFinalizedWithdrawals(hash)
+	if err != nil {
+		return false, err
+	}
+	res, err := r.l1.CallContract(ctx, ethereum.CallMsg{To: &r.cfg.PortalAddress, Data: data}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to check message %s: %w", hash, err)
+	}
+	return bridge.UnpackFinalizedWithdrawals(res)
+}
+
+// errRevert is returned by send if the relay transaction would fail.
+var errRevert = errors.New("relay transaction reverts")
+
+// send signs and sends a relay transaction with the given nonce and call data.
+// If it replaces a previous transaction, the fees are raised enough for the
+// pool to accept the replacement. It returns nil if L1 is too expensive.
+func (r *Relayer) send(ctx context.Context, nonce uint64, data []byte, replaced *types.Transaction) (*types.Transaction, error) {
+	tip, feeCap, err := r.txmgr.Fees(ctx, replaced)
+	if err != nil || tip == nil {
+		return nil, err
+	}
+	gas, err := r.txmgr.EstimateGas(ctx, r.cfg.PortalAddress, data, tip, feeCap)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRevert, err)
+	}
+	tx, err := r.txmgr.Send(ctx, nonce, r.cfg.PortalAddress, gas, data, tip, feeCap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send relay transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// UnmarshalMessage decodes a MessagePassed event of the message passer. The
+// hash in the event must match the withdrawal.
+func UnmarshalMessage(l *types.Log) (*Message, error) {
+	w, err := bridge.UnmarshalMessagePassed(l)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Withdrawal: *w, L2Block: l.BlockNumber, TxHash: l.TxHash}
+	if msg.Hash, err = w.Hash(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// MarshalMessage encodes a message as a MessagePassed event of the message
+// passer.
+func MarshalMessage(msg *Message) *types.Log {
+	l := bridge.MarshalMessagePassed(&msg.Withdrawal, msg.Hash)
+	l.BlockNumber, l.TxHash = msg.L2Block, msg.TxHash
+	return l
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package relayer
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/bridge"
+)
+
+var (
+	testKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testOracle   = common.HexToAddress("0x0000000000000000000000000000000000000abc")
+	testPortal   = common.HexToAddress("0x0000000000000000000000000000000000000def")
+	testProposal = uint64(1000) // L1 time of the test proposals
+)
+
+// testL1 is an L1 chain with an output oracle and a portal.
+type testL1 struct {
+	head      types.Header
+	proposals []types.Log
+	finalized map[common.Hash]bool
+	sent      []*types.Transaction
+	receipts  map[common.Hash]*types.Receipt
+}
+
+func newTestL1() *testL1 {
+	return &testL1{
+		head:      types.Header{Number: big.NewInt(100), Time: testProposal, BaseFee: big.NewInt(10)},
+		finalized: make(map[common.Hash]bool),
+		receipts:  make(map[common.Hash]*types.Receipt),
+	}
+}
+
+func (l *testL1) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return types.CopyHeader(&l.head), nil
+}
+
+func (l *testL1) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return filterLogs(l.proposals, q), nil
+}
+
+func (l *testL1) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (l *testL1) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return uint64(len(l.receipts)), nil
+}
+
+func (l *testL1) CallContract(ctx context.Context, call ethereum.CallMsg, number *big.Int) ([]byte, error) {
+	args, err := bridge.PortalABI.Methods["finalizedWithdrawals"].Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	return bridge.PortalABI.Methods["finalizedWithdrawals"].Outputs.Pack(l.finalized[common.Hash(args[0].([32]byte))])
+}
+
+func (l *testL1) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 200_000, nil
+}
+
+func (l *testL1) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	l.sent = append(l.sent, tx)
+	return nil
+}
+
+func (l *testL1) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := l.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// propose adds a proposal of the output of the given L2 block in a new L1 block.
+func (l *testL1) propose(l2Block uint64) {
+	l.head.Number = new(big.Int).Add(l.head.Number, common.Big1)
+	p := &bridge.OutputProposal{OutputRoot: testOutput(l2Block).OutputRoot, L2BlockNumber: l2Block, L1Timestamp: testProposal, L1BlockNumber: l.head.Number.Uint64()}
+	l.proposals = append(l.proposals, *bridge.MarshalOutputProposed(testOracle, p))
+}
+
+// include includes the given relay transaction, which finalizes its message if
+// it succeeds.
+func (l *testL1) include(t *testing.T, tx *types.Transaction, msg *Message, status uint64) {
+	t.Helper()
+	l.receipts[tx.Hash()] = &types.Receipt{TxHash: tx.Hash(), Status: status, BlockNumber: big.NewInt(101)}
+	if status == types.ReceiptStatusSuccessful {
+		l.finalized[msg.Hash] = true
+	}
+}
+
+// testL2 is an L2 chain whose message passer sent the given messages.
+type testL2 struct {
+	logs []types.Log
+}
+
+func (l *testL2) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return filterLogs(l.logs, q), nil
+}
+
+func (l *testL2) send(msg *Message) {
+	l.logs = append(l.logs, *MarshalMessage(msg))
+}
+
+func filterLogs(logs []types.Log, q ethereum.FilterQuery) []types.Log {
+	var res []types.Log
+	for _, l := range logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() && l.Address == q.Addresses[0] && l.Topics[0] == q.Topics[0][0] {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+type testNode struct {
+	status rollup.SyncStatus
+}
+
+func (n *testNode) SyncStatus(ctx context.Context) (*rollup.SyncStatus, error) {
+	return &n.status, nil
+}
+
+func (n *testNode) OutputProofAtBlock(ctx context.Context, number uint64, keys []common.Hash) (*rollup.OutputProof, error) {
+	proof := &rollup.OutputProof{Output: *testOutput(number)}
+	for _, key := range keys {
+		proof.StorageProof = append(proof.StorageProof, rollup.StorageProof{
+			Key:   key,
+			Value: (*hexutil.Big)(common.Big1),
+			Proof: []hexutil.Bytes{key[:], {0x01}},
+		})
+	}
+	return proof, nil
+}
+
+// withdrawalTx and outputRootProof are the tuple arguments of the portal.
+type withdrawalTx struct {
+	Nonce    *big.Int
+	Sender   common.Address
+	Target   common.Address
+	Value    *big.Int
+	GasLimit *big.Int
+	Data     []byte
+}
+
+type outputRootProof struct {
+	Version               [32]byte
+	StateRoot             [32]byte
+	WithdrawerStorageRoot [32]byte
+	LatestBlockhash       [32]byte
+}
+
+func testOutput(l2Block uint64) *rollup.Output {
+	return rollup.NewOutputV0(common.BigToHash(new(big.Int).SetUint64(l2Block)), common.HexToHash("0x01"), common.HexToHash("0x02"))
+}
+
+func testMessage(nonce int64, l2Block uint64) *Message {
+	msg := &Message{
+		Withdrawal: rollup.Withdrawal{
+			Nonce:    big.NewInt(nonce),
+			Sender:   common.HexToAddress("0x1111"),
+			Target:   common.HexToAddress("0x2222"),
+			Value:    big.NewInt(1e18),
+			GasLimit: big.NewInt(100_000),
+			Data:     []byte{0xca, 0xfe},
+		},
+		L2Block: l2Block,
+	}
+	msg.Hash, _ = msg.Withdrawal.Hash()
+	return msg
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestRelayer(t *testing.T, stateFile string, l1 *testL1, l2 *testL2, node *testNode, clock *testClock) *Relayer {
+	t.Helper()
+	cfg := DefaultConfig
+	cfg.L1ChainID = big.NewInt(900)
+	cfg.PortalAddress = testPortal
+	cfg.OutputOracleAddress = testOracle
+	cfg.StateFile = stateFile
+	cfg.FinalizationPeriod = time.Hour
+	cfg.MaxBlockRange = 4
+	r, err := New(cfg, l1, l2, node, testKey, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = clock.Now
+	return r
+}
+
+func steps(t *testing.T, r *Relayer, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := r.Step(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRelayerFinalizesMessages(t *testing.T) {
+	var (
+		l1    = newTestL1()
+		l2    = &testL2{}
+		node  = &testNode{}
+		clock = &testClock{now: time.Unix(10000, 0)}
+		file  = filepath.Join(t.TempDir(), "relayer.json")
+		r     = newTestRelayer(t, file, l1, l2, node, clock)
+		msg   = testMessage(0, 5)
+	)
+	l2.send(msg)
+	node.status.SafeL2.Number = 12
+	steps(t, r, 1)
+	if len(r.pending) != 1 || r.pending[0].Hash != msg.Hash {
+		t.Fatalf("message not picked up, pending %d", len(r.pending))
+	}
+	// The message waits for an output at or after its block to be final.
+	l1.propose(4)
+	l1.propose(8)
+	steps(t, r, 1)
+	l1.head.Time = testProposal + 3600
+	if len(l1.sent) != 0 {
+		t.Fatal("relayed message before its output was final")
+	}
+	steps(t, r, 2)
+	if len(l1.sent) != 1 {
+		t.Fatalf("sent %d relay transactions, want 1", len(l1.sent))
+	}
+	tx := l1.sent[0]
+	if *tx.To() != testPortal {
+		t.Fatalf("relay transaction sent to %s", tx.To())
+	}
+	// The message is proven against the output of block 8.
+	w, output := msg.Withdrawal, testOutput(8)
+	slot, _ := w.StorageSlot()
+	want, err := bridge.PortalABI.Pack("finalizeWithdrawalTransaction",
+		withdrawalTx{w.Nonce, w.Sender, w.Target, w.Value, w.GasLimit, w.Data},
+		big.NewInt(8),
+		outputRootProof{output.Version, output.StateRoot, output.WithdrawalStorageRoot, output.BlockHash},
+		[][]byte{slot[:], {0x01}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx.Data(), want) {
+		t.Fatalf("unexpected relay call data %x, want %x", tx.Data(), want)
+	}
+	// Nothing else is sent while the relay is pending, and a failed relay is
+	// retried.
+	steps(t, r, 1)
+	l1.include(t, tx, msg, types.ReceiptStatusFailed)
+	steps(t, r, 2)
+	if len(l1.sent) != 2 {
+		t.Fatalf("failed relay not retried, sent %d", len(l1.sent))
+	}
+	l1.include(t, l1.sent[1], msg, types.ReceiptStatusSuccessful)
+	steps(t, r, 2)
+	if len(r.pending) != 0 || r.inflight != nil || len(l1.sent) != 2 {
+		t.Fatalf("message not relayed once, pending %d, sent %d", len(r.pending), len(l1.sent))
+	}
+	state, err := loadState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Next != 13 || len(state.Relayed) != 0 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+}
+
+func TestRelayerRestart(t *testing.T) {
+	var (
+		l1    = newTestL1()
+		l2    = &testL2{}
+		node  = &testNode{}
+		clock = &testClock{now: time.Unix(10000, 0)}
+		file  = filepath.Join(t.TempDir(), "relayer.json")
+		r     = newTestRelayer(t, file, l1, l2, node, clock)
+		msgs  = []*Message{testMessage(0, 3), testMessage(1, 3), testMessage(2, 6)}
+	)
+	for _, msg := range msgs {
+		l2.send(msg)
+	}
+	node.status.SafeL2.Number = 10
+	l1.propose(4)
+	l1.head.Time = testProposal + 3600
+
+	// The first message is relayed, the second one is left pending.
+	steps(t, r, 1)
+	if len(l1.sent) != 1 {
+		t.Fatalf("sent %d relay transactions, want 1", len(l1.sent))
+	}
+	l1.include(t, l1.sent[0], msgs[0], types.ReceiptStatusSuccessful)
+	steps(t, r, 1)
+	state, err := loadState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Next != 3 || len(state.Relayed) != 1 || state.Relayed[msgs[0].Hash] != 3 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	// After a restart, the relayed message is skipped. The second one was
+	// relayed by someone else in the meantime, and the third one is not
+	// proven by a final output yet.
+	l1.finalized[msgs[1].Hash] = true
+	r = newTestRelayer(t, file, l1, l2, node, clock)
+	steps(t, r, 1)
+	if len(l1.sent) != 1 {
+		t.Fatalf("relayed again after restart, sent %d", len(l1.sent))
+	}
+	if len(r.pending) != 1 || r.pending[0].Hash != msgs[2].Hash {
+		t.Fatalf("unexpected pending messages %d", len(r.pending))
+	}
+	if state, _ = loadState(file); state.Next != 6 || len(state.Relayed) != 0 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := testMessage(7, 42)
+	msg.TxHash = common.HexToHash("0x77")
+	dec, err := UnmarshalMessage(MarshalMessage(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec, msg) {
+		t.Fatalf("decoded message %+v, want %+v", dec, msg)
+	}
+	// Events whose hash does not match the withdrawal are rejected.
+	msg.Hash = common.HexToHash("0x01")
+	if _, err := UnmarshalMessage(MarshalMessage(msg)); err == nil {
+		t.Fatal("message with wrong hash accepted")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package replay derives a range of L2 blocks again from L1 and compares them
+// with a reference chain, to find the first block where a node diverged from
+// the derivation rules, and the batch or deposit that it diverged on.
+//
+// The blocks are executed by the stateless engine of the fault proof program,
+// on top of the state of the reference chain before the range.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/program"
+)
+
+// ErrNotDerived is returned if the L1 chain does not derive the whole range.
+var ErrNotDerived = errors.New("block range not derived from L1")
+
+// traceSize is the number of pipeline steps kept to explain a divergence.
+const traceSize = 64
+
+// Config is the configuration of a replay.
+type Config struct {
+	Rollup  *rollup.Config
+	L2Chain *params.ChainConfig
+
+	// Start and End are the first and the last L2 block to derive. The block
+	// before Start is taken from the reference chain.
+	Start, End uint64
+}
+
+// Reference is the chain that the derived blocks are compared with. It is
+// implemented by ethclient.Client.
+type Reference interface {
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+}
+
+// Result is the outcome of a replay.
+type Result struct {
+	// Head is the last derived block. All blocks before it matched the
+	// reference chain.
+	Head rollup.L2BlockRef `json:"head"`
+	// Divergence describes how Head differs from the reference chain, nil if
+	// it matches too.
+	Divergence *Divergence `json:"divergence,omitempty"`
+}
+
+// Divergence describes a derived block that differs from the block with the
+// same number in the reference chain.
+type Divergence struct {
+	Number        uint64      `json:"number"`
+	DerivedHash   common.Hash `json:"derivedHash"`
+	ReferenceHash common.Hash `json:"referenceHash"`
+	DerivedRoot   common.Hash `json:"derivedStateRoot"`
+	ReferenceRoot common.Hash `json:"referenceStateRoot"`
+	// Fields are the header fields that differ.
+	Fields []string `json:"fields"`
+
+	// TxIndex is the first transaction that differs, -1 if the blocks have the
+	// same transactions and only differ in their execution.
+	TxIndex     int          `json:"txIndex"`
+	DerivedTx   *common.Hash `json:"derivedTx,omitempty"`
+	ReferenceTx *common.Hash `json:"referenceTx,omitempty"`
+	// Deposit is the source hash of the derived transaction at TxIndex, if it
+	// is a deposit of the L1 origin of the block.
+	Deposit *common.Hash `json:"deposit,omitempty"`
+
+	// L1Origin is the epoch of the derived block.
+	L1Origin rollup.BlockID `json:"l1Origin"`
+	// Step is the pipeline step that derived the block, with the batch it
+	// consumed, and L1Block is the L1 block that the pipeline read it from.
+	Step    *derive.StepTrace  `json:"step,omitempty"`
+	L1Block *rollup.L1BlockRef `json:"l1Block,omitempty"`
+}
+
+// Run derives the blocks of the configured range from L1, executing them with
+// the state read from the L2 oracle, and compares every block with the reference
+// chain. It stops at the first block that differs.
+func Run(ctx context.Context, cfg Config, l1 derive.L1Fetcher, l2 program.L2Oracle, ref Reference, logger log.Logger) (*Result, error) {
+	if cfg.Start <= cfg.Rollup.Genesis.L2.Number {
+		return nil, fmt.Errorf("start block %d not after the L2 genesis block %d", cfg.Start, cfg.Rollup.Genesis.L2.Number)
+	}
+	if cfg.End < cfg.Start {
+		return nil, fmt.Errorf("end block %d before start block %d", cfg.End, cfg.Start)
+	}
+	parent, err := ref.BlockByNumber(ctx, new(big.Int).SetUint64(cfg.Start-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reference block %d: %w", cfg.Start-1, err)
+	}
+	engine := program.NewEngine(cfg.L2Chain, l2)
+	head, err := engine.Block(parent.Hash())
+	if err != nil {
+		return nil, err
+	}
+	safeHead, err := derive.L2BlockRefFromPayload(cfg.Rollup, beacon.BlockToExecutableData(head))
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference block %d: %w", head.NumberU64(), err)
+	}
+	tracer := derive.NewTracer(traceSize)
+	pipeline := derive.NewPipeline(cfg.Rollup, derive.Confirmations{}, l1, engine, safeHead, logger)
+	pipeline.SetTracer(tracer)
+	if err := pipeline.ForkchoiceUpdate(ctx); err != nil {
+		return nil, fmt.Errorf("failed to set L2 head: %w", err)
+	}
+	res := &Result{Head: safeHead}
+	for res.Head.Number < cfg.End {
+		err := pipeline.Step(ctx)
+		if errors.Is(err, io.EOF) {
+			return res, fmt.Errorf("%w: derived up to block %d", ErrNotDerived, res.Head.Number)
+		}
+		if err != nil {
+			return res, err
+		}
+		next := pipeline.Head()
+		if next == res.Head {
+			continue
+		}
+		res.Head = next
+		derived, err := engine.Block(next.Hash)
+		if err != nil {
+			return res, err
+		}
+		want, err := ref.BlockByNumber(ctx, new(big.Int).SetUint64(next.Number))
+		if err != nil {
+			return res, fmt.Errorf("failed to fetch reference block %d: %w", next.Number, err)
+		}
+		if derived.Hash() == want.Hash() {
+			logger.Debug("Derived block matches", "number", next.Number, "hash", next.Hash)
+			continue
+		}
+		res.Divergence = diff(derived, want)
+		res.Divergence.L1Origin = next.L1Origin
+		res.Divergence.Step, res.Divergence.L1Block = lastDerivation(tracer.Steps())
+		logger.Warn("Derived block differs", "number", next.Number, "derived", derived.Hash(), "reference", want.Hash(), "fields", res.Divergence.Fields)
+		return res, nil
+	}
+	return res, nil
+}
+
+// diff compares a derived block with the reference block.
+func diff(derived, ref *types.Block) *Divergence {
+	d := &Divergence{
+		Number:        derived.NumberU64(),
+		DerivedHash:   derived.Hash(),
+		ReferenceHash: ref.Hash(),
+		DerivedRoot:   derived.Root(),
+		ReferenceRoot: ref.Root(),
+		Fields:        diffHeaders(derived.Header(), ref.Header()),
+		TxIndex:       -1,
+	}
+	dtxs, rtxs := derived.Transactions(), ref.Transactions()
+	for i := 0; i < len(dtxs) || i < len(rtxs); i++ {
+		var dtx, rtx *types.Transaction
+		if i < len(dtxs) {
+			dtx = dtxs[i]
+		}
+		if i < len(rtxs) {
+			rtx = rtxs[i]
+		}
+		if dtx != nil && rtx != nil && dtx.Hash() == rtx.Hash() {
+			continue
+		}
+		d.TxIndex = i
+		if dtx != nil {
+			hash := dtx.Hash()
+			d.DerivedTx = &hash
+			if dtx.Type() == types.DepositTxType {
+				source := dtx.SourceHash()
+				d.Deposit = &source
+			}
+		}
+		if rtx != nil {
+			hash := rtx.Hash()
+			d.ReferenceTx = &hash
+		}
+		break
+	}
+	return d
+}
+
+// diffHeaders returns the names of the fields that differ between two headers.
+func diffHeaders(a, b *types.Header) []string {
+	var fields []string
+	check := func(name string, equal bool) {
+		if !equal {
+			fields = append(fields, name)
+		}
+	}
+	check("parentHash", a.ParentHash == b.ParentHash)
+	check("miner", a.Coinbase == b.Coinbase)
+	check("stateRoot", a.Root == b.Root)
+	check("transactionsRoot", a.TxHash == b.TxHash)
+	check("receiptsRoot", a.ReceiptHash == b.ReceiptHash)
+	check("logsBloom", a.Bloom == b.Bloom)
+	check("gasLimit", a.GasLimit == b.GasLimit)
+	check("gasUsed", a.GasUsed == b.GasUsed)
+	check("timestamp", a.Time == b.Time)
+	check("extraData", string(a.Extra) == string(b.Extra))
+	check("mixHash", a.MixDigest == b.MixDigest)
+	check("baseFeePerGas", a.BaseFee == nil && b.BaseFee == nil || a.BaseFee != nil && b.BaseFee != nil && a.BaseFee.Cmp(b.BaseFee) == 0)
+	return fields
+}
+
+// lastDerivation returns the last step of the traced steps that derived a
+// block, along with the last L1 block that the pipeline advanced to before it.
+func lastDerivation(steps []derive.StepTrace) (*derive.StepTrace, *rollup.L1BlockRef) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].Kind != derive.StepDerive {
+			continue
+		}
+		step := steps[i]
+		for j := i - 1; j >= 0; j-- {
+			if steps[j].L1Block != nil {
+				return &step, steps[j].L1Block
+			}
+		}
+		return &step, nil
+	}
+	return nil, nil
+}
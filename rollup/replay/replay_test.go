@@ -0,0 +1,261 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rollup"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+	"github.com/ethereum/go-ethereum/rollup/genesis"
+	"github.com/ethereum/go-ethereum/rollup/internal/testutils"
+	"github.com/ethereum/go-ethereum/rollup/program"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	testBatcherKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testUserKey, _    = crypto.HexToECDSA("49a7b37aa6f6645917e7b807e9d1c00d4fa71f18343b0d4122a4d2df64dd6fee")
+	testDeposit       = common.HexToAddress("0xde90517000000000000000000000000000000001")
+)
+
+// l2Oracle serves the L2 genesis block and its state.
+type l2Oracle struct {
+	genesis *types.Block
+	db      ethdb.Database
+}
+
+func (o *l2Oracle) BlockByHash(hash common.Hash) (*types.Block, error) {
+	if hash != o.genesis.Hash() {
+		return nil, ethereum.NotFound
+	}
+	return o.genesis, nil
+}
+
+func (o *l2Oracle) NodeByHash(hash common.Hash) ([]byte, error) {
+	return o.db.Get(hash[:])
+}
+
+func (o *l2Oracle) CodeByHash(hash common.Hash) ([]byte, error) {
+	if code := rawdb.ReadCode(o.db, hash); len(code) > 0 {
+		return code, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// referenceChain is a reference chain of blocks by number.
+type referenceChain []*types.Block
+
+func (c referenceChain) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	if !number.IsUint64() || number.Uint64() >= uint64(len(c)) {
+		return nil, ethereum.NotFound
+	}
+	return c[number.Uint64()], nil
+}
+
+// testSetup is an L1 chain with an L2 chain on top, whose genesis state funds
+// a user account.
+type testSetup struct {
+	l1    *testutils.L1Chain
+	gspec *core.Genesis
+	cfg   *rollup.Config
+	l2    *l2Oracle
+	nonce uint64
+}
+
+func newTestSetup(t *testing.T) *testSetup {
+	l1 := testutils.NewL1Chain()
+	deployCfg := &genesis.DeployConfig{
+		L1ChainID:              900,
+		L2ChainID:              901,
+		L2BlockTime:            2,
+		MaxSequencerDrift:      600,
+		SequencerWindowSize:    10,
+		BatchInboxAddress:      common.HexToAddress("0xff00000000000000000000000000000000000901"),
+		BatchSenderAddress:     crypto.PubkeyToAddress(testBatcherKey.PublicKey),
+		DepositContractAddress: testDeposit,
+		L2GenesisGasLimit:      30_000_000,
+		GasPriceOracleOverhead: 2100,
+		GasPriceOracleScalar:   1_000_000,
+		GasPriceOracleDecimals: 6,
+		PredeployCode:          map[string]hexutil.Bytes{"L1Block": {0x60, 0x02}},
+		FundedAccounts: core.GenesisAlloc{
+			crypto.PubkeyToAddress(testUserKey.PublicKey): {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	gspec, err := genesis.BuildL2Genesis(deployCfg, l1.Head().Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	l2Genesis := gspec.MustCommit(db)
+	return &testSetup{
+		l1:    l1,
+		gspec: gspec,
+		cfg:   genesis.BuildRollupConfig(deployCfg, l1.Head().Header(), l2Genesis),
+		l2:    &l2Oracle{genesis: l2Genesis, db: db},
+	}
+}
+
+// addBatch adds an L1 block that posts the batch of the next L2 block.
+func (s *testSetup) addBatch(t *testing.T, batch *derive.BatchData) {
+	t.Helper()
+	data, err := derive.EncodeBatches([]*derive.BatchData{batch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignNewTx(testBatcherKey, types.LatestSignerForChainID(s.cfg.L1ChainID), &types.DynamicFeeTx{
+		ChainID:   s.cfg.L1ChainID,
+		Nonce:     s.nonce,
+		To:        &s.cfg.BatchInboxAddress,
+		Gas:       1_000_000,
+		GasFeeCap: big.NewInt(10),
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nonce++
+	s.l1.AddBlock(tx)
+}
+
+// userTx creates a signed L2 value transfer.
+func userTx(t *testing.T, nonce uint64) hexutil.Bytes {
+	t.Helper()
+	to := common.HexToAddress("0x1234")
+	tx, err := types.SignNewTx(testUserKey, types.LatestSignerForChainID(big.NewInt(901)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(901),
+		Nonce:     nonce,
+		To:        &to,
+		Gas:       21000,
+		GasFeeCap: big.NewInt(2 * params.GWei),
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := tx.MarshalBinary()
+	return enc
+}
+
+// buildChain derives three L2 blocks with user transactions and a deposit from
+// a new L1 chain, like a rollup node would, and returns the L2 chain.
+func (s *testSetup) buildChain(t *testing.T) referenceChain {
+	engine := program.NewEngine(s.gspec.Config, s.l2)
+	p := derive.NewPipeline(s.cfg, derive.Confirmations{}, s.l1, engine, s.cfg.L2GenesisRef(), log.New())
+	var (
+		to       = common.HexToAddress("0x5678")
+		dep      = &types.DepositTx{From: common.HexToAddress("0xf00d"), To: &to, Mint: big.NewInt(1000), Value: big.NewInt(10), Gas: 50_000, Data: []byte{}}
+		depLogTx = types.MustSignNewTx(testUserKey, types.LatestSignerForChainID(s.cfg.L1ChainID), &types.LegacyTx{To: &testDeposit, Gas: 100_000, GasPrice: big.NewInt(10)})
+		epoch1   = s.l1.AddBlockWithLogs([]*types.Transaction{depLogTx}, [][]*types.Log{{derive.MarshalDepositLogEvent(testDeposit, dep)}})
+	)
+	batches := []*derive.BatchData{
+		{EpochNum: 0, EpochHash: s.cfg.Genesis.L1.Hash, Timestamp: s.cfg.Genesis.L2Time + 2, Transactions: []hexutil.Bytes{userTx(t, 0)}},
+		{EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time(), Transactions: []hexutil.Bytes{userTx(t, 1)}},
+		{EpochNum: 1, EpochHash: epoch1.Hash(), Timestamp: epoch1.Time() + 2, Transactions: []hexutil.Bytes{userTx(t, 2)}},
+	}
+	chain := referenceChain{s.l2.genesis}
+	for _, batch := range batches {
+		batch.ParentHash = p.Head().Hash
+		s.addBatch(t, batch)
+		for {
+			if err := p.Step(context.Background()); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		}
+		block, err := engine.Block(p.Head().Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, block)
+	}
+	return chain
+}
+
+func TestRun(t *testing.T) {
+	s := newTestSetup(t)
+	chain := s.buildChain(t)
+	cfg := Config{Rollup: s.cfg, L2Chain: s.gspec.Config, Start: 1, End: 3}
+	res, err := Run(context.Background(), cfg, s.l1, s.l2, chain, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Divergence != nil {
+		t.Fatalf("unexpected divergence %+v", res.Divergence)
+	}
+	if res.Head.Hash != chain[3].Hash() {
+		t.Fatalf("replayed head %v, want block 3 %s", res.Head, chain[3].Hash())
+	}
+
+	// The range must be derived completely.
+	cfg.End = 5
+	if _, err := Run(context.Background(), cfg, s.l1, s.l2, chain, log.New()); !errors.Is(err, ErrNotDerived) {
+		t.Fatalf("expected ErrNotDerived, got %v", err)
+	}
+}
+
+func TestRunDivergence(t *testing.T) {
+	s := newTestSetup(t)
+	chain := s.buildChain(t)
+
+	// The reference chain misses the deposit in the first block of epoch 1.
+	derived := chain[2]
+	txs := append(types.Transactions{derived.Transactions()[0]}, derived.Transactions()[2:]...)
+	header := derived.Header()
+	header.TxHash = types.DeriveSha(txs, trie.NewStackTrie(nil))
+	header.Root = common.HexToHash("0x01")
+	chain[2] = types.NewBlockWithHeader(header).WithBody(txs, nil)
+
+	cfg := Config{Rollup: s.cfg, L2Chain: s.gspec.Config, Start: 1, End: 3}
+	res, err := Run(context.Background(), cfg, s.l1, s.l2, chain, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := res.Divergence
+	if d == nil || d.Number != 2 || res.Head.Hash != derived.Hash() {
+		t.Fatalf("divergence at block 2 not found: head %v, divergence %+v", res.Head, d)
+	}
+	if d.DerivedHash != derived.Hash() || d.ReferenceHash != chain[2].Hash() || d.ReferenceRoot != header.Root {
+		t.Errorf("wrong blocks reported: %+v", d)
+	}
+	if len(d.Fields) != 2 || d.Fields[0] != "stateRoot" || d.Fields[1] != "transactionsRoot" {
+		t.Errorf("differing fields %v, want stateRoot and transactionsRoot", d.Fields)
+	}
+	deposit := derived.Transactions()[1]
+	if d.TxIndex != 1 || d.Deposit == nil || *d.Deposit != deposit.SourceHash() || *d.DerivedTx != deposit.Hash() || *d.ReferenceTx != txs[1].Hash() {
+		t.Errorf("offending deposit not reported: %+v", d)
+	}
+	if d.L1Origin.Number != 1 || d.Step == nil || d.Step.Batch == nil || d.Step.Batch.EpochNum != 1 || d.L1Block == nil {
+		t.Errorf("offending batch not reported: origin %v, step %+v", d.L1Origin, d.Step)
+	}
+}
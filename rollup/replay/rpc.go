@@ -0,0 +1,72 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RPCOracle implements program.L2Oracle with the RPC API of an L2 node. The
+// state trie nodes and code are read with debug_dbGet, so the node must serve
+// the debug namespace and keep the state before the replayed range, and its
+// database must store trie nodes by hash.
+type RPCOracle struct {
+	ctx    context.Context
+	client *rpc.Client
+	eth    *ethclient.Client
+}
+
+// NewRPCOracle creates an oracle that reads from the given L2 node. Its calls
+// are made with the given context.
+func NewRPCOracle(ctx context.Context, client *rpc.Client) *RPCOracle {
+	return &RPCOracle{ctx: ctx, client: client, eth: ethclient.NewClient(client)}
+}
+
+// BlockByHash implements program.L2Oracle.
+func (o *RPCOracle) BlockByHash(hash common.Hash) (*types.Block, error) {
+	return o.eth.BlockByHash(o.ctx, hash)
+}
+
+// NodeByHash implements program.L2Oracle.
+func (o *RPCOracle) NodeByHash(hash common.Hash) ([]byte, error) {
+	return o.dbGet(hash[:])
+}
+
+// CodeByHash implements program.L2Oracle. Code is looked up by its prefixed key
+// first, and by its hash for databases written by older versions.
+func (o *RPCOracle) CodeByHash(hash common.Hash) ([]byte, error) {
+	code, err := o.dbGet(append(rawdb.CodePrefix, hash[:]...))
+	if err == nil && len(code) > 0 {
+		return code, nil
+	}
+	return o.dbGet(hash[:])
+}
+
+func (o *RPCOracle) dbGet(key []byte) ([]byte, error) {
+	var data hexutil.Bytes
+	if err := o.client.CallContext(o.ctx, &data, "debug_dbGet", hexutil.Encode(key)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
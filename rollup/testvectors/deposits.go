@@ -0,0 +1,147 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package testvectors contains the canonical encodings of the rollup data that
+// the L1 contracts produce or verify, and generates the test vectors that the
+// contract tests check against, so that both implementations stay in lock-step.
+//
+// The vectors are in testdata/ and are regenerated with
+//
+//	go test ./rollup/testvectors -write-test-vectors
+package testvectors
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// EncodeDeposit returns the typed transaction encoding of a deposit, as it is
+// included in L2 blocks.
+func EncodeDeposit(dep *types.DepositTx) ([]byte, error) {
+	return types.NewTx(dep).MarshalBinary()
+}
+
+// DepositHash returns the L2 transaction hash of a deposit, the keccak256 hash
+// of its typed encoding.
+func DepositHash(dep *types.DepositTx) common.Hash {
+	return types.NewTx(dep).Hash()
+}
+
+// Deposit is the test vector of a user deposit. The input fields are the
+// arguments of the deposit on L1 and the position of its event, the output
+// fields are the opaque data of the event and the derived L2 transaction.
+type Deposit struct {
+	Name string `json:"name"`
+
+	From        common.Address  `json:"from"`
+	To          *common.Address `json:"to"` // nil for contract creations
+	Mint        *hexutil.Big    `json:"mint"`
+	Value       *hexutil.Big    `json:"value"`
+	GasLimit    hexutil.Uint64  `json:"gasLimit"`
+	IsCreation  bool            `json:"isCreation"`
+	Data        hexutil.Bytes   `json:"data"`
+	L1BlockHash common.Hash     `json:"l1BlockHash"`
+	LogIndex    hexutil.Uint64  `json:"logIndex"`
+
+	OpaqueData hexutil.Bytes `json:"opaqueData"`
+	SourceHash common.Hash   `json:"sourceHash"`
+	Encoded    hexutil.Bytes `json:"encoded"`
+	Hash       common.Hash   `json:"hash"`
+}
+
+// NewDeposit computes the test vector of a deposit emitted by the log with the
+// given index in the L1 block.
+func NewDeposit(name string, l1BlockHash common.Hash, logIndex uint64, dep *types.DepositTx) (*Deposit, error) {
+	dep.SourceHash = types.UserDepositSourceHash(l1BlockHash, logIndex)
+	enc, err := EncodeDeposit(dep)
+	if err != nil {
+		return nil, err
+	}
+	mint := new(big.Int)
+	if dep.Mint != nil {
+		mint.Set(dep.Mint)
+	}
+	return &Deposit{
+		Name:        name,
+		From:        dep.From,
+		To:          dep.To,
+		Mint:        (*hexutil.Big)(mint),
+		Value:       (*hexutil.Big)(dep.Value),
+		GasLimit:    hexutil.Uint64(dep.Gas),
+		IsCreation:  dep.To == nil,
+		Data:        dep.Data,
+		L1BlockHash: l1BlockHash,
+		LogIndex:    hexutil.Uint64(logIndex),
+		OpaqueData:  derive.MarshalDepositOpaqueData(dep),
+		SourceHash:  dep.SourceHash,
+		Encoded:     enc,
+		Hash:        DepositHash(dep),
+	}, nil
+}
+
+// Deposits returns the test vectors of the user deposits, which cover the
+// optional fields and the bounds of the numeric ones.
+func Deposits() ([]*Deposit, error) {
+	var (
+		blockHash = common.HexToHash("0xd1a4c8a6f9f3e1b2c5d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f9a0b1c2d3")
+		from      = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		to        = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		maxUint   = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1)
+	)
+	cases := []struct {
+		name     string
+		logIndex uint64
+		dep      *types.DepositTx
+	}{
+		{
+			name: "transfer",
+			dep:  &types.DepositTx{From: from, To: &to, Mint: big.NewInt(1e18), Value: big.NewInt(1e18), Gas: 21_000},
+		},
+		{
+			name:     "call",
+			logIndex: 1,
+			dep:      &types.DepositTx{From: from, To: &to, Value: new(big.Int), Gas: 100_000, Data: common.FromHex("0xa9059cbb")},
+		},
+		{
+			name:     "creation",
+			logIndex: 2,
+			dep:      &types.DepositTx{From: from, Mint: big.NewInt(7), Value: big.NewInt(7), Gas: 1_000_000, Data: common.FromHex("0x6080604052348015600f57600080fd5b50")},
+		},
+		{
+			name:     "empty",
+			logIndex: 3,
+			dep:      &types.DepositTx{Value: new(big.Int)},
+		},
+		{
+			name:     "max",
+			logIndex: 1<<64 - 1,
+			dep:      &types.DepositTx{From: from, To: &to, Mint: maxUint, Value: maxUint, Gas: 1<<64 - 1, Data: make([]byte, 100)},
+		},
+	}
+	vectors := make([]*Deposit, len(cases))
+	for i, c := range cases {
+		v, err := NewDeposit(c.name, blockHash, c.logIndex, c.dep)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package testvectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+// To regenerate the test vectors, run
+//
+//	go test -run TestDepositVectors -write-test-vectors
+var writeTestVectorsFlag = flag.Bool("write-test-vectors", false, "Overwrite the test vectors in testdata/")
+
+func TestDepositVectors(t *testing.T) {
+	vectors, err := Deposits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc = append(enc, '\n')
+
+	file := filepath.Join("testdata", "deposits.json")
+	if *writeTestVectorsFlag {
+		if err := os.WriteFile(file, enc, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("deposit encoding changed, the contract tests must be updated along with %s", file)
+	}
+}
+
+// TestDepositVectorsDerivation checks that the derivation turns the deposit
+// events into the transactions of the vectors.
+func TestDepositVectorsDerivation(t *testing.T) {
+	vectors, err := Deposits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	portal := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	for _, v := range vectors {
+		var to common.Address
+		if v.To != nil {
+			to = *v.To
+		}
+		ev := derive.MarshalDepositLogEvent(portal, &types.DepositTx{
+			From:  v.From,
+			To:    v.To,
+			Mint:  v.Mint.ToInt(),
+			Value: v.Value.ToInt(),
+			Gas:   uint64(v.GasLimit),
+			Data:  v.Data,
+		})
+		ev.BlockHash, ev.Index = v.L1BlockHash, uint(v.LogIndex)
+		if ev.Topics[2] != common.BytesToHash(to[:]) {
+			t.Fatalf("%s: wrong target topic %s", v.Name, ev.Topics[2])
+		}
+		dep, err := derive.UnmarshalDepositLogEvent(ev)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		enc, err := EncodeDeposit(dep)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if !bytes.Equal(enc, v.Encoded) || DepositHash(dep) != v.Hash {
+			t.Fatalf("%s: derived deposit %x, want %x", v.Name, enc, v.Encoded)
+		}
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(v.Encoded); err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		if tx.Hash() != v.Hash || tx.SourceHash() != v.SourceHash {
+			t.Fatalf("%s: decoded deposit has hash %s and source hash %s", v.Name, tx.Hash(), tx.SourceHash())
+		}
+	}
+}
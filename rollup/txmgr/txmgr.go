@@ -0,0 +1,179 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package txmgr implements the sending of L1 transactions that the rollup
+// services share: the batch submitter, the output submitter and the message
+// relayer. It prices, signs and sends the transactions, replaces them with
+// higher fees, and finds the ones that were included. The services keep track
+// of their nonces and of the transactions they are waiting for.
+package txmgr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// MaxFeeBumps is the number of times the fees of a transaction are bumped when
+// the transaction pool holds a transaction with the same nonce of unknown fees.
+const MaxFeeBumps = 20
+
+// Config contains the settings of a transaction manager.
+type Config struct {
+	ChainID *big.Int
+	// MaxGasPrice is the highest fee cap the sender is willing to pay. When L1
+	// is more expensive, transactions are postponed. Nil means no limit.
+	MaxGasPrice *big.Int
+}
+
+// L1Client is the L1 API used by the transaction manager. It is implemented by
+// ethclient.Client.
+type L1Client interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// Manager sends the L1 transactions of a single account.
+type Manager struct {
+	cfg    Config
+	l1     L1Client
+	key    *ecdsa.PrivateKey
+	from   common.Address
+	signer types.Signer
+	log    log.Logger
+}
+
+// New creates a transaction manager that signs with the given key.
+func New(cfg Config, l1 L1Client, key *ecdsa.PrivateKey, logger log.Logger) *Manager {
+	return &Manager{
+		cfg:    cfg,
+		l1:     l1,
+		key:    key,
+		from:   crypto.PubkeyToAddress(key.PublicKey),
+		signer: types.LatestSignerForChainID(cfg.ChainID),
+		log:    logger,
+	}
+}
+
+// From returns the account that sends the transactions.
+func (m *Manager) From() common.Address {
+	return m.from
+}
+
+// Fees returns the gas tip and fee cap of a new transaction. If it replaces a
+// previous transaction, the fees are raised enough for the pool to accept the
+// replacement. It returns nil fees if L1 is too expensive.
+func (m *Manager) Fees(ctx context.Context, replaced *types.Transaction) (*big.Int, *big.Int, error) {
+	head, err := m.l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch L1 head: %w", err)
+	}
+	tip, err := m.l1.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch gas tip: %w", err)
+	}
+	feeCap := new(big.Int).Add(tip, new(big.Int).Mul(head.BaseFee, common.Big2))
+	if replaced != nil {
+		tip = math.BigMax(tip, BumpFee(replaced.GasTipCap()))
+		feeCap = math.BigMax(feeCap, BumpFee(replaced.GasFeeCap()))
+	}
+	if m.cfg.MaxGasPrice != nil && feeCap.Cmp(m.cfg.MaxGasPrice) > 0 {
+		m.log.Warn("L1 gas price above limit, postponing transaction", "feecap", feeCap, "limit", m.cfg.MaxGasPrice)
+		return nil, nil, nil
+	}
+	return tip, feeCap, nil
+}
+
+// EstimateGas estimates the gas of a transaction with the given fees.
+func (m *Manager) EstimateGas(ctx context.Context, to common.Address, data []byte, tip, feeCap *big.Int) (uint64, error) {
+	return m.l1.EstimateGas(ctx, ethereum.CallMsg{
+		From:      m.from,
+		To:        &to,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Data:      data,
+	})
+}
+
+// Send signs and sends a transaction with the given nonce, gas and fees. If the
+// transaction pool holds another transaction with the same nonce, like one sent
+// before a restart, the fees are bumped until they replace it.
+func (m *Manager) Send(ctx context.Context, nonce uint64, to common.Address, gas uint64, data []byte, tip, feeCap *big.Int) (*types.Transaction, error) {
+	for i := 0; ; i++ {
+		tx, err := types.SignNewTx(m.key, m.signer, &types.DynamicFeeTx{
+			ChainID:   m.cfg.ChainID,
+			Nonce:     nonce,
+			GasTipCap: tip,
+			GasFeeCap: feeCap,
+			Gas:       gas,
+			To:        &to,
+			Data:      data,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = m.l1.SendTransaction(ctx, tx)
+		if err == nil {
+			return tx, nil
+		}
+		if !strings.Contains(err.Error(), core.ErrReplaceUnderpriced.Error()) || i == MaxFeeBumps {
+			return nil, err
+		}
+		tip, feeCap = BumpFee(tip), BumpFee(feeCap)
+		if m.cfg.MaxGasPrice != nil && feeCap.Cmp(m.cfg.MaxGasPrice) > 0 {
+			return nil, fmt.Errorf("replacing nonce %d needs a fee cap above the limit: %w", nonce, err)
+		}
+	}
+}
+
+// Receipt returns the receipt of the first of the given transactions that was
+// included, or nil if none of them was included yet. The transactions are the
+// ones sent with the same nonce, any of which may be included after a
+// replacement.
+func (m *Manager) Receipt(ctx context.Context, txs []*types.Transaction) (*types.Receipt, error) {
+	for _, tx := range txs {
+		receipt, err := m.l1.TransactionReceipt(ctx, tx.Hash())
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to fetch receipt of %s: %w", tx.Hash(), err)
+		}
+		return receipt, nil
+	}
+	return nil, nil
+}
+
+// BumpFee raises a fee by the 10% that the transaction pool requires for a
+// replacement, rounded up.
+func BumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(110))
+	bumped.Add(bumped, big.NewInt(99))
+	return bumped.Div(bumped, big.NewInt(100))
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txmgr
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// testL1 is an L1 whose pool requires a 10% fee bump to replace a transaction.
+type testL1 struct {
+	pool     map[uint64]*types.Transaction // pending transaction of every nonce
+	receipts map[common.Hash]*types.Receipt
+}
+
+func newTestL1() *testL1 {
+	return &testL1{pool: make(map[uint64]*types.Transaction), receipts: make(map[common.Hash]*types.Receipt)}
+}
+
+func (l *testL1) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(10)}, nil
+}
+
+func (l *testL1) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (l *testL1) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 50_000, nil
+}
+
+func (l *testL1) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if old := l.pool[tx.Nonce()]; old != nil && (tx.GasTipCap().Cmp(BumpFee(old.GasTipCap())) < 0 || tx.GasFeeCap().Cmp(BumpFee(old.GasFeeCap())) < 0) {
+		return core.ErrReplaceUnderpriced
+	}
+	l.pool[tx.Nonce()] = tx
+	return nil
+}
+
+func (l *testL1) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	if r, ok := l.receipts[hash]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func TestManagerFees(t *testing.T) {
+	var (
+		ctx = context.Background()
+		l1  = newTestL1()
+		m   = New(Config{ChainID: big.NewInt(1)}, l1, testKey, log.New())
+	)
+	tip, feeCap, err := m.Fees(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Int64() != 1 || feeCap.Int64() != 21 {
+		t.Fatalf("fees %v/%v, want 1/21", tip, feeCap)
+	}
+	// A replacement bids at least 10% more than the replaced transaction.
+	replaced := types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(10), GasFeeCap: big.NewInt(100)})
+	if tip, feeCap, err = m.Fees(ctx, replaced); err != nil {
+		t.Fatal(err)
+	}
+	if tip.Int64() != 11 || feeCap.Int64() != 110 {
+		t.Fatalf("replacement fees %v/%v, want 11/110", tip, feeCap)
+	}
+	// Above the limit, the transaction is postponed.
+	m = New(Config{ChainID: big.NewInt(1), MaxGasPrice: big.NewInt(20)}, l1, testKey, log.New())
+	if tip, feeCap, err = m.Fees(ctx, nil); err != nil || tip != nil || feeCap != nil {
+		t.Fatalf("fees %v/%v, err %v above the limit", tip, feeCap, err)
+	}
+}
+
+func TestManagerSendReplaces(t *testing.T) {
+	var (
+		ctx = context.Background()
+		l1  = newTestL1()
+		m   = New(Config{ChainID: big.NewInt(1)}, l1, testKey, log.New())
+		to  = common.HexToAddress("0xff")
+	)
+	// A transaction of a previous run holds the nonce with unknown fees.
+	l1.pool[0] = types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(5), GasFeeCap: big.NewInt(50)})
+	tx, err := m.Send(ctx, 0, to, 21000, nil, big.NewInt(1), big.NewInt(21))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l1.pool[0] != tx || tx.GasFeeCap().Cmp(big.NewInt(55)) < 0 {
+		t.Fatalf("previous transaction not replaced, fee cap %v", tx.GasFeeCap())
+	}
+	if from, _ := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), tx); from != m.From() {
+		t.Fatalf("sent from %s, want %s", from, m.From())
+	}
+	// The bumps stop at the gas price limit.
+	m = New(Config{ChainID: big.NewInt(1), MaxGasPrice: big.NewInt(100)}, l1, testKey, log.New())
+	l1.pool[1] = types.NewTx(&types.DynamicFeeTx{GasTipCap: big.NewInt(50), GasFeeCap: big.NewInt(500)})
+	if _, err := m.Send(ctx, 1, to, 21000, nil, big.NewInt(1), big.NewInt(21)); !errors.Is(err, core.ErrReplaceUnderpriced) {
+		t.Fatalf("replaced above the limit: %v", err)
+	}
+}
+
+func TestManagerReceipt(t *testing.T) {
+	var (
+		ctx = context.Background()
+		l1  = newTestL1()
+		m   = New(Config{ChainID: big.NewInt(1)}, l1, testKey, log.New())
+		txs = []*types.Transaction{
+			types.NewTx(&types.DynamicFeeTx{Nonce: 3, GasFeeCap: big.NewInt(1)}),
+			types.NewTx(&types.DynamicFeeTx{Nonce: 3, GasFeeCap: big.NewInt(2)}),
+		}
+	)
+	if r, err := m.Receipt(ctx, txs); err != nil || r != nil {
+		t.Fatalf("receipt %v, err %v before inclusion", r, err)
+	}
+	// The replaced transaction may be the one that is included.
+	want := &types.Receipt{TxHash: txs[0].Hash()}
+	l1.receipts[want.TxHash] = want
+	if r, err := m.Receipt(ctx, txs); err != nil || r != want {
+		t.Fatalf("receipt %v, err %v, want %v", r, err, want)
+	}
+}
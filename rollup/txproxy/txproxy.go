@@ -0,0 +1,215 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package txproxy implements the transaction ingress proxy, which accepts the
+// transactions of users and replicas and forwards them to the sequencer.
+//
+// The proxy is the edge of the sequencer: it rejects transactions that the
+// sequencer would never include, like deposits and oversized transactions, and
+// limits the rate of transactions in total and per sender, before any of them
+// reach the sequencer.
+package txproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/time/rate"
+)
+
+// Config contains the settings of the proxy.
+type Config struct {
+	// L2ChainID is the chain ID that transactions must be signed for.
+	L2ChainID *big.Int
+
+	// MaxTxSize is the largest accepted transaction encoding, in bytes.
+	MaxTxSize uint64
+	// Rate is the number of transactions per second forwarded in total, with
+	// bursts of up to Burst transactions. A zero rate means no limit.
+	Rate  float64
+	Burst int
+	// SenderRate and SenderBurst limit the transactions of every sender the
+	// same way. A zero rate means no limit.
+	SenderRate  float64
+	SenderBurst int
+	// Timeout is the time the sequencer has to accept a transaction.
+	Timeout time.Duration
+}
+
+// DefaultConfig contains reasonable default settings.
+var DefaultConfig = Config{
+	MaxTxSize:   128 * 1024, // the limit of the transaction pool
+	Rate:        500,
+	Burst:       1000,
+	SenderRate:  5,
+	SenderBurst: 20,
+	Timeout:     5 * time.Second,
+}
+
+// senderLimiters is the number of senders whose rate limiters are kept.
+const senderLimiters = 16384
+
+// Errors of transactions that are rejected by the proxy.
+var (
+	ErrDepositTx   = errors.New("deposit transactions cannot be submitted")
+	ErrOversizedTx = errors.New("transaction too large")
+	ErrRateLimited = errors.New("transaction rate limit exceeded")
+)
+
+// Proxy forwards transactions to the current sequencer. If the sequencer cannot
+// be reached, the proxy fails over to the next of the configured sequencers,
+// which stays the current one until it cannot be reached either.
+type Proxy struct {
+	cfg        Config
+	signer     types.Signer
+	sequencers []*rpc.Client
+	limiter    *rate.Limiter
+	senders    *lru.Cache // common.Address -> *rate.Limiter
+	log        log.Logger
+
+	mu      sync.Mutex // protects current and the sender limiters
+	current int
+}
+
+// New creates a proxy that forwards to the given sequencers, the first of which
+// is the current one.
+func New(cfg Config, sequencers []*rpc.Client, logger log.Logger) (*Proxy, error) {
+	if len(sequencers) == 0 {
+		return nil, errors.New("no sequencer")
+	}
+	if cfg.L2ChainID == nil {
+		return nil, errors.New("missing L2 chain ID")
+	}
+	limit := rate.Inf
+	if cfg.Rate > 0 {
+		limit = rate.Limit(cfg.Rate)
+	}
+	senders, _ := lru.New(senderLimiters)
+	return &Proxy{
+		cfg:        cfg,
+		signer:     types.LatestSignerForChainID(cfg.L2ChainID),
+		sequencers: sequencers,
+		limiter:    rate.NewLimiter(limit, cfg.Burst),
+		senders:    senders,
+		log:        logger,
+	}, nil
+}
+
+// APIs returns the RPC API of the proxy.
+func (p *Proxy) APIs() []rpc.API {
+	return []rpc.API{{Namespace: "eth", Service: &API{p}}}
+}
+
+// API is the part of the eth namespace that the proxy serves.
+type API struct {
+	p *Proxy
+}
+
+// SendRawTransaction checks the signed transaction and forwards it to the
+// sequencer.
+func (api *API) SendRawTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+	return api.p.Send(ctx, input)
+}
+
+// Send checks the encoded transaction and forwards it to the sequencer. It
+// returns the error of the sequencer if the sequencer rejects it.
+func (p *Proxy) Send(ctx context.Context, input []byte) (common.Hash, error) {
+	if uint64(len(input)) > p.cfg.MaxTxSize {
+		rejectedMeter.Mark(1)
+		return common.Hash{}, fmt.Errorf("%w: %d bytes, limit %d", ErrOversizedTx, len(input), p.cfg.MaxTxSize)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		rejectedMeter.Mark(1)
+		return common.Hash{}, err
+	}
+	if tx.Type() == types.DepositTxType {
+		rejectedMeter.Mark(1)
+		return common.Hash{}, ErrDepositTx
+	}
+	from, err := types.Sender(p.signer, tx)
+	if err != nil {
+		rejectedMeter.Mark(1)
+		return common.Hash{}, fmt.Errorf("invalid sender: %w", err)
+	}
+	if !p.allow(from) {
+		rateLimitedMeter.Mark(1)
+		return common.Hash{}, ErrRateLimited
+	}
+	if err := p.forward(ctx, input); err != nil {
+		return common.Hash{}, err
+	}
+	forwardedMeter.Mark(1)
+	p.log.Debug("Forwarded transaction", "hash", tx.Hash(), "from", from, "nonce", tx.Nonce())
+	return tx.Hash(), nil
+}
+
+// allow reports whether a transaction of the sender is within the rate limits.
+func (p *Proxy) allow(from common.Address) bool {
+	if p.cfg.SenderRate > 0 {
+		p.mu.Lock()
+		var limiter *rate.Limiter
+		if l, ok := p.senders.Get(from); ok {
+			limiter = l.(*rate.Limiter)
+		} else {
+			limiter = rate.NewLimiter(rate.Limit(p.cfg.SenderRate), p.cfg.SenderBurst)
+			p.senders.Add(from, limiter)
+		}
+		p.mu.Unlock()
+		if !limiter.Allow() {
+			return false
+		}
+	}
+	return p.limiter.Allow()
+}
+
+// forward sends the transaction to the current sequencer. Errors returned by
+// the sequencer are returned as they are, while a sequencer that cannot be
+// reached is replaced by the next one.
+func (p *Proxy) forward(ctx context.Context, input []byte) error {
+	var err error
+	for i := 0; i < len(p.sequencers); i++ {
+		p.mu.Lock()
+		current := p.current
+		p.mu.Unlock()
+
+		cctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+		err = p.sequencers[current].CallContext(cctx, nil, "eth_sendRawTransaction", hexutil.Bytes(input))
+		cancel()
+		var rpcErr rpc.Error
+		if err == nil || errors.As(err, &rpcErr) || ctx.Err() != nil {
+			return err
+		}
+		failoverMeter.Mark(1)
+		p.mu.Lock()
+		if p.current == current {
+			p.current = (current + 1) % len(p.sequencers)
+			p.log.Warn("Sequencer unreachable, failing over", "from", current, "to", p.current, "err", err)
+		}
+		p.mu.Unlock()
+	}
+	return fmt.Errorf("no sequencer reachable: %w", err)
+}
@@ -0,0 +1,219 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txproxy
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testKey2, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	testChainID = big.NewInt(901)
+)
+
+// testSequencer is the eth namespace of a sequencer, which rejects
+// transactions with nonce 99.
+type testSequencer struct {
+	mu  sync.Mutex
+	txs []*types.Transaction
+}
+
+func (s *testSequencer) SendRawTransaction(input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if tx.Nonce() == 99 {
+		return common.Hash{}, errors.New("nonce too high")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txs = append(s.txs, tx)
+	return tx.Hash(), nil
+}
+
+func (s *testSequencer) received() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.txs)
+}
+
+func startSequencer(t *testing.T) (*testSequencer, *rpc.Client) {
+	t.Helper()
+	seq := new(testSequencer)
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", seq); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+	return seq, rpc.DialInProc(srv)
+}
+
+// startProxy serves the proxy in process and returns a client of it.
+func startProxy(t *testing.T, p *Proxy) *rpc.Client {
+	t.Helper()
+	srv := rpc.NewServer()
+	for _, api := range p.APIs() {
+		if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(srv.Stop)
+	client := rpc.DialInProc(srv)
+	t.Cleanup(client.Close)
+	return client
+}
+
+func signedTx(t *testing.T, nonce uint64, size int) hexutil.Bytes {
+	t.Helper()
+	tx, err := types.SignNewTx(testKey, types.LatestSignerForChainID(testChainID), &types.DynamicFeeTx{
+		ChainID:   testChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Gas:       1_000_000,
+		To:        &common.Address{},
+		Data:      make([]byte, size),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := tx.MarshalBinary()
+	return enc
+}
+
+func sendRaw(client *rpc.Client, input hexutil.Bytes) (common.Hash, error) {
+	var hash common.Hash
+	err := client.CallContext(context.Background(), &hash, "eth_sendRawTransaction", input)
+	return hash, err
+}
+
+func TestProxyRejects(t *testing.T) {
+	seq, seqClient := startSequencer(t)
+	cfg := DefaultConfig
+	cfg.L2ChainID = testChainID
+	cfg.MaxTxSize = 1024
+	p, err := New(cfg, []*rpc.Client{seqClient}, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := startProxy(t, p)
+
+	deposit, _ := types.NewTx(&types.DepositTx{From: common.HexToAddress("0x1111"), Gas: 100_000}).MarshalBinary()
+	otherChain, _ := types.SignNewTx(testKey, types.LatestSignerForChainID(big.NewInt(1)), &types.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 21000, To: &common.Address{}})
+	otherChainEnc, _ := otherChain.MarshalBinary()
+	for name, input := range map[string]hexutil.Bytes{
+		"deposit":     deposit,
+		"oversized":   signedTx(t, 0, 2048),
+		"undecodable": {0x02, 0x01},
+		"other chain": otherChainEnc,
+	} {
+		if _, err := sendRaw(client, input); err == nil {
+			t.Errorf("%s transaction accepted", name)
+		}
+	}
+	if seq.received() != 0 {
+		t.Fatalf("sequencer received %d rejected transactions", seq.received())
+	}
+	// Errors of the sequencer are returned to the client.
+	if _, err := sendRaw(client, signedTx(t, 99, 0)); err == nil || err.Error() != "nonce too high" {
+		t.Fatalf("sequencer error not returned: %v", err)
+	}
+	input := signedTx(t, 0, 100)
+	hash, err := sendRaw(client, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq.received() != 1 || seq.txs[0].Hash() != hash {
+		t.Fatal("transaction not forwarded")
+	}
+}
+
+func TestProxyRateLimit(t *testing.T) {
+	_, seqClient := startSequencer(t)
+	cfg := DefaultConfig
+	cfg.L2ChainID = testChainID
+	cfg.Rate, cfg.Burst = 1e-9, 5
+	cfg.SenderRate, cfg.SenderBurst = 1e-9, 3
+	p, err := New(cfg, []*rpc.Client{seqClient}, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := p.Send(context.Background(), signedTx(t, uint64(i), 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.Send(context.Background(), signedTx(t, 3, 0)); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("sender not limited: %v", err)
+	}
+	// Other senders are only limited by the total rate.
+	signer := types.LatestSignerForChainID(testChainID)
+	for i := 0; i < 3; i++ {
+		tx, _ := types.SignNewTx(testKey2, signer, &types.DynamicFeeTx{ChainID: testChainID, Nonce: uint64(i), Gas: 21000, To: &common.Address{}})
+		enc, _ := tx.MarshalBinary()
+		_, err := p.Send(context.Background(), enc)
+		if i < 2 && err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 && !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("total rate not limited: %v", err)
+		}
+	}
+}
+
+func TestProxyFailover(t *testing.T) {
+	seq1, client1 := startSequencer(t)
+	seq2, client2 := startSequencer(t)
+	cfg := DefaultConfig
+	cfg.L2ChainID = testChainID
+	p, err := New(cfg, []*rpc.Client{client1, client2}, log.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := p.Send(ctx, signedTx(t, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	// Once the current sequencer is unreachable, the next one takes over and
+	// stays current.
+	client1.Close()
+	for i := 1; i < 3; i++ {
+		if _, err := p.Send(ctx, signedTx(t, uint64(i), 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if seq1.received() != 1 || seq2.received() != 2 || p.current != 1 {
+		t.Fatalf("received %d and %d transactions, current %d", seq1.received(), seq2.received(), p.current)
+	}
+	client2.Close()
+	if _, err := p.Send(ctx, signedTx(t, 3, 0)); err == nil {
+		t.Fatal("transaction accepted without reachable sequencer")
+	}
+}
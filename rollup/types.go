@@ -0,0 +1,198 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rollup contains the configuration and the common types of the rollup
+// node, which derives the L2 chain from data posted to L1.
+package rollup
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// BlockID identifies a block by hash and number.
+type BlockID struct {
+	Hash   common.Hash `json:"hash"`
+	Number uint64      `json:"number"`
+}
+
+// String implements fmt.Stringer.
+func (id BlockID) String() string {
+	return fmt.Sprintf("%s:%d", id.Hash.TerminalString(), id.Number)
+}
+
+// L1BlockRef is a reference to an L1 block, with just enough information to
+// follow the chain and to use the block as an L1 origin.
+type L1BlockRef struct {
+	Hash       common.Hash `json:"hash"`
+	Number     uint64      `json:"number"`
+	ParentHash common.Hash `json:"parentHash"`
+	Time       uint64      `json:"timestamp"`
+}
+
+// L1BlockRefFromHeader creates a reference to the given L1 header.
+func L1BlockRefFromHeader(h *types.Header) L1BlockRef {
+	return L1BlockRef{
+		Hash:       h.Hash(),
+		Number:     h.Number.Uint64(),
+		ParentHash: h.ParentHash,
+		Time:       h.Time,
+	}
+}
+
+// ID returns the hash and number of the block.
+func (r L1BlockRef) ID() BlockID {
+	return BlockID{Hash: r.Hash, Number: r.Number}
+}
+
+// ParentID returns the hash and number of the parent block.
+func (r L1BlockRef) ParentID() BlockID {
+	n := r.Number
+	if n > 0 {
+		n--
+	}
+	return BlockID{Hash: r.ParentHash, Number: n}
+}
+
+// String implements fmt.Stringer.
+func (r L1BlockRef) String() string {
+	return r.ID().String()
+}
+
+// L2BlockRef is a reference to an L2 block, including the L1 block it was
+// derived from.
+type L2BlockRef struct {
+	Hash           common.Hash `json:"hash"`
+	Number         uint64      `json:"number"`
+	ParentHash     common.Hash `json:"parentHash"`
+	Time           uint64      `json:"timestamp"`
+	L1Origin       BlockID     `json:"l1origin"`
+	SequenceNumber uint64      `json:"sequenceNumber"` // distance to the first block of the epoch
+}
+
+// ID returns the hash and number of the block.
+func (r L2BlockRef) ID() BlockID {
+	return BlockID{Hash: r.Hash, Number: r.Number}
+}
+
+// ParentID returns the hash and number of the parent block.
+func (r L2BlockRef) ParentID() BlockID {
+	n := r.Number
+	if n > 0 {
+		n--
+	}
+	return BlockID{Hash: r.ParentHash, Number: n}
+}
+
+// String implements fmt.Stringer.
+func (r L2BlockRef) String() string {
+	return r.ID().String()
+}
+
+// L2Block is an L2 block with its deposits separated from the transactions of
+// users, along with the L1 origin it was derived from.
+type L2Block struct {
+	L2BlockRef
+	// L1OriginTime and L1BaseFee are the L1 info of the block. They are not
+	// set for the genesis block, which has no L1 info deposit.
+	L1OriginTime uint64       `json:"l1OriginTimestamp"`
+	L1BaseFee    *hexutil.Big `json:"l1BaseFee"`
+	// Deposits are the deposits of the block, starting with the L1 info
+	// deposit, and Transactions the transactions that follow them.
+	Deposits     []*types.Transaction `json:"deposits"`
+	Transactions []*types.Transaction `json:"transactions"`
+}
+
+// SyncStatus reports the progress of a rollup node. The L1 origin of the L2
+// head is part of UnsafeL2.
+type SyncStatus struct {
+	// HeadL1 is the head of the L1 chain.
+	HeadL1 L1BlockRef `json:"headL1"`
+	// CurrentL1 is the last L1 block that batches were read from.
+	CurrentL1 L1BlockRef `json:"currentL1"`
+	// UnsafeL2 is the head of the L2 chain, which may not be derivable from
+	// L1 yet.
+	UnsafeL2 L2BlockRef `json:"unsafeL2"`
+	// SafeL2 is the last L2 block that was derived from L1.
+	SafeL2 L2BlockRef `json:"safeL2"`
+	// FinalizedL2 is the last L2 block that can no longer be reorged.
+	FinalizedL2 L2BlockRef `json:"finalizedL2"`
+}
+
+// SequencerStatus reports the health of the sequencer of a rollup node, for
+// an external coordinator to decide which node sequences.
+type SequencerStatus struct {
+	// Active is whether the node sequences blocks.
+	Active bool `json:"active"`
+	// Draining is whether the node keeps its sequenced blocks to itself
+	// instead of gossiping them.
+	Draining bool `json:"draining"`
+	// Head is the last block built by the sequencer, which another sequencer
+	// takes over at.
+	Head L2BlockRef `json:"head"`
+	// LastBuiltTime is the time at which the sequencer last built a block, in
+	// seconds since the epoch, zero if it has not built one since the node
+	// started.
+	LastBuiltTime uint64 `json:"lastBuiltTime"`
+	// LastBuiltAge is the number of seconds since the sequencer last built a
+	// block, zero if it has not built one.
+	LastBuiltAge uint64 `json:"lastBuiltAge"`
+}
+
+// Kinds of node events, which are also the topics that RPC clients subscribe
+// to.
+const (
+	EventUnsafeHead      = "unsafeHead"
+	EventSafeHead        = "safeHead"
+	EventFinalizedHead   = "finalizedHead"
+	EventDerivationError = "derivationError"
+)
+
+// NodeEvent is a change of the state of a rollup node: a new unsafe, safe or
+// finalized L2 head, or a failed derivation step.
+type NodeEvent struct {
+	Kind string `json:"kind"`
+	// Head is the new head of the head events.
+	Head *L2BlockRef `json:"head,omitempty"`
+	// Error is the error of a failed derivation step, and Class how the node
+	// recovers from it: temporary, reset or critical.
+	Error string `json:"error,omitempty"`
+	Class string `json:"class,omitempty"`
+}
+
+// SystemConfig holds the parameters of the rollup that the system config
+// contract on L1 can change at runtime. The derivation applies an update from
+// the L1 block that emitted it on, so every node changes over at the same L2
+// block.
+type SystemConfig struct {
+	// BatcherAddr is the L1 account that is allowed to post batches.
+	BatcherAddr common.Address `json:"batcherAddr"`
+	// Overhead and Scalar are the L1 data fee parameters of the gas price
+	// oracle. Zero means the value of the L2 genesis state.
+	Overhead uint64 `json:"overhead"`
+	Scalar   uint64 `json:"scalar"`
+	// GasLimit is the gas limit of the L2 blocks. Zero leaves the gas limit to
+	// the engine.
+	GasLimit uint64 `json:"gasLimit"`
+	// UnsafeBlockSigners are the keys that are allowed to sign the unsafe
+	// payloads of the gossip. It is nil until the system config updates the
+	// signers, and the sequencer address configured on the node is trusted
+	// instead. Once updated, an empty list authorizes no key at all.
+	UnsafeBlockSigners []common.Address `json:"unsafeBlockSigners"`
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rollup
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Withdrawal is a message from L2 to L1, as initiated with the message passer.
+type Withdrawal struct {
+	Nonce    *big.Int       `json:"nonce"`
+	Sender   common.Address `json:"sender"`
+	Target   common.Address `json:"target"`
+	Value    *big.Int       `json:"value"`
+	GasLimit *big.Int       `json:"gasLimit"`
+	Data     hexutil.Bytes  `json:"data"`
+}
+
+var withdrawalArgs = func() abi.Arguments {
+	uint256, _ := abi.NewType("uint256", "", nil)
+	address, _ := abi.NewType("address", "", nil)
+	bytes, _ := abi.NewType("bytes", "", nil)
+	return abi.Arguments{{Type: uint256}, {Type: address}, {Type: address}, {Type: uint256}, {Type: uint256}, {Type: bytes}}
+}()
+
+// Hash returns the hash that identifies the withdrawal, the keccak256 hash of
+// its ABI encoding.
+func (w *Withdrawal) Hash() (common.Hash, error) {
+	enc, err := withdrawalArgs.Pack(w.Nonce, w.Sender, w.Target, w.Value, w.GasLimit, []byte(w.Data))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(enc), nil
+}
+
+// StorageSlot returns the slot of the message passer that records the
+// withdrawal. The sentMessages mapping is at slot 0 of the contract.
+func (w *Withdrawal) StorageSlot() (common.Hash, error) {
+	hash, err := w.Hash()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(hash[:], common.Hash{}.Bytes()), nil
+}
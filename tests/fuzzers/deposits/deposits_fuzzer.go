@@ -0,0 +1,69 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package deposits
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rollup/derive"
+)
+
+var depositContract = common.HexToAddress("0xdeadbeef")
+
+// Fuzz decodes deposit event logs built from the input. If the first byte is
+// odd, the following 96 bytes are used as the from, to and version topics,
+// otherwise the topics are valid. The rest of the input is the log data.
+//
+// Any log that decodes must encode back to the exact same log.
+func Fuzz(input []byte) int {
+	if len(input) == 0 || len(input) > 128*1024 {
+		return 0
+	}
+	ev := &types.Log{
+		Address: depositContract,
+		Topics:  []common.Hash{derive.DepositEventABIHash, {}, {}, derive.DepositEventVersion0},
+	}
+	flags, input := input[0], input[1:]
+	if flags&1 == 1 {
+		if len(input) < 96 {
+			return 0
+		}
+		for i := 1; i < 4; i++ {
+			ev.Topics[i] = common.BytesToHash(input[:32])
+			input = input[32:]
+		}
+	}
+	ev.Data = input
+
+	dep, err := derive.UnmarshalDepositLogEvent(ev)
+	if err != nil {
+		return 0
+	}
+	enc := derive.MarshalDepositLogEvent(depositContract, dep)
+	for i := range ev.Topics {
+		if enc.Topics[i] != ev.Topics[i] {
+			panic(fmt.Sprintf("topic %d mismatch: have %x, want %x", i, enc.Topics[i], ev.Topics[i]))
+		}
+	}
+	if !bytes.Equal(enc.Data, ev.Data) {
+		panic(fmt.Sprintf("data mismatch:\nhave %x\nwant %x", enc.Data, ev.Data))
+	}
+	return 1
+}
@@ -126,5 +126,18 @@ func Fuzz(input []byte) int {
 		var rs types.Receipts
 		decodeEncode(input, &rs, i)
 	}
+	{
+		// Typed transactions, including deposits, use the binary encoding.
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(input); err == nil {
+			output, err := tx.MarshalBinary()
+			if err != nil {
+				panic(err)
+			}
+			if !bytes.Equal(input, output) {
+				panic(fmt.Sprintf("binary transaction encode-decode is not equal, \ninput : %x\noutput: %x", input, output))
+			}
+		}
+	}
 	return 1
 }